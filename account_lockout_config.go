@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateAccountLockoutConfig validates the optional per-account lockout
+// configuration shared by both authentication flows. Zero values fall back
+// to the defaults.
+func validateAccountLockoutConfig(config *types.AccountLockoutConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.MaxFailures < 0 {
+		return errors.New("auth: AccountLockout MaxFailures cannot be negative")
+	}
+
+	if config.BaseDelay < 0 || config.MaxDelay < 0 || config.ResetAfter < 0 {
+		return errors.New("auth: AccountLockout durations cannot be negative")
+	}
+
+	if config.BaseDelay > 0 && config.MaxDelay > 0 && config.BaseDelay > config.MaxDelay {
+		return errors.New("auth: AccountLockout BaseDelay cannot exceed MaxDelay")
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateAccountLockoutConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *types.AccountLockoutConfig
+		expected string
+	}{
+		{"nil config", nil, ""},
+		{"defaults", &types.AccountLockoutConfig{}, ""},
+		{"valid", &types.AccountLockoutConfig{MaxFailures: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: 24 * time.Hour}, ""},
+		{"negative failures", &types.AccountLockoutConfig{MaxFailures: -1}, "auth: AccountLockout MaxFailures cannot be negative"},
+		{"negative delay", &types.AccountLockoutConfig{BaseDelay: -time.Minute}, "auth: AccountLockout durations cannot be negative"},
+		{"negative reset", &types.AccountLockoutConfig{ResetAfter: -time.Minute}, "auth: AccountLockout durations cannot be negative"},
+		{"base above max", &types.AccountLockoutConfig{BaseDelay: time.Hour, MaxDelay: time.Minute}, "auth: AccountLockout BaseDelay cannot exceed MaxDelay"},
+	}
+
+	for _, tt := range tests {
+		err := validateAccountLockoutConfig(tt.config)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestAccountLockout_UnlockByEmail(t *testing.T) {
+	store := map[string]string{}
+	unlockLinks := map[string]string{}
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.DisableRateLimit = true
+	config.FuncTemporaryKeyGet = func(key string) (string, error) { return store[key], nil }
+	config.FuncTemporaryKeySet = func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	}
+	config.AccountLockout = &types.AccountLockoutConfig{
+		MaxFailures: 2,
+		FuncUnlockEmailSend: func(ctx context.Context, account string, unlockLink string, options types.UserAuthOptions) error {
+			unlockLinks[account] = unlockLink
+			return nil
+		},
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() string {
+		form := url.Values{"email": {"user@test.com"}, "password": {"wrong"}}
+		req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+
+	login()
+	login()
+	if body := login(); !strings.Contains(body, "Too many failed attempts") {
+		t.Fatalf("expected the account to be locked, got %s", body)
+	}
+
+	unlockLink := unlockLinks["user@test.com"]
+	if !strings.HasPrefix(unlockLink, "http://localhost/auth/"+PathAccountUnlock+"?t=") {
+		t.Fatalf("expected an unlock link, got %q", unlockLink)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, unlockLink, nil)
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+	if !strings.Contains(recorder.Body.String(), "Your account has been unlocked") {
+		t.Fatalf("expected the account to be unlocked, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	if body := login(); !strings.Contains(body, "Invalid credentials") {
+		t.Fatalf("expected the password to be checked again, got %s", body)
+	}
+}
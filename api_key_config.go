@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateApiKeyConfig validates the optional API key configuration shared
+// by both authentication flows. Scopes are posted as a comma separated list,
+// so they cannot contain commas or whitespace.
+func validateApiKeyConfig(config *types.ApiKeyConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Store == nil {
+		return errors.New("auth: ApiKeys Store is required")
+	}
+
+	if config.MaxExpiration < 0 {
+		return errors.New("auth: ApiKeys MaxExpiration cannot be negative")
+	}
+
+	for _, scope := range config.Scopes {
+		if scope == "" || strings.ContainsAny(scope, ", \t\r\n") {
+			return errors.New(`auth: ApiKeys scope "` + scope + `" is not valid`)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/middlewares"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateApiKeyConfig(t *testing.T) {
+	store := testutils.NewApiKeyStore()
+
+	tests := []struct {
+		name     string
+		config   *types.ApiKeyConfig
+		expected string
+	}{
+		{"nil config", nil, ""},
+		{"valid", &types.ApiKeyConfig{Store: store, Scopes: []string{"orders:read", "orders:write"}, MaxExpiration: 90 * 24 * time.Hour}, ""},
+		{"missing store", &types.ApiKeyConfig{}, "auth: ApiKeys Store is required"},
+		{"negative expiration", &types.ApiKeyConfig{Store: store, MaxExpiration: -time.Hour}, "auth: ApiKeys MaxExpiration cannot be negative"},
+		{"empty scope", &types.ApiKeyConfig{Store: store, Scopes: []string{""}}, `auth: ApiKeys scope "" is not valid`},
+		{"scope with comma", &types.ApiKeyConfig{Store: store, Scopes: []string{"read,write"}}, `auth: ApiKeys scope "read,write" is not valid`},
+	}
+
+	for _, tt := range tests {
+		err := validateApiKeyConfig(tt.config)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestRouter_ApiKeysRequireConfig(t *testing.T) {
+	authShared, err := NewUsernameAndPasswordAuth(testutils.NewUsernameAndPasswordConfigForTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{authShared.LinkApiApiKeys(), authShared.LinkApiApiKeyCreate(), authShared.LinkApiApiKeyRename(), authShared.LinkApiApiKeyRevoke()} {
+		req := httptest.NewRequest(http.MethodPost, link, nil)
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusTemporaryRedirect || recorder.Header().Get("Location") != authShared.LinkLogin() {
+			t.Fatalf("expected %s to be disabled, got %d", link, recorder.Code)
+		}
+	}
+}
+
+func TestRouter_ApiKeysLifecycle(t *testing.T) {
+	store := testutils.NewApiKeyStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = testutils.NewSessionStore()
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+	config.ApiKeys = &types.ApiKeyConfig{
+		Store:         store,
+		Scopes:        []string{"orders:read", "orders:write"},
+		MaxExpiration: 90 * 24 * time.Hour,
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	var session *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == CookieName {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatalf("expected auth cookie, got %s", recorder.Body.String())
+	}
+
+	post := func(link string, form url.Values) string {
+		req := httptest.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(session)
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+
+	if body := post(authShared.LinkApiApiKeyCreate(), url.Values{"name": {"CI"}, "scopes": {"orders:delete"}}); !strings.Contains(body, "Scope orders:delete is not available") {
+		t.Fatalf("expected an unknown scope to be rejected, got %s", body)
+	}
+
+	var created struct {
+		Data struct {
+			ApiKey string `json:"api_key"`
+			Key    struct {
+				ID        string    `json:"id"`
+				ExpiresAt time.Time `json:"expires_at"`
+			} `json:"key"`
+		} `json:"data"`
+	}
+	body := post(authShared.LinkApiApiKeyCreate(), url.Values{"name": {"CI"}, "scopes": {"orders:read"}})
+	if err := json.Unmarshal([]byte(body), &created); err != nil || created.Data.ApiKey == "" {
+		t.Fatalf("expected the key to be created, got %s", body)
+	}
+	if created.Data.Key.ExpiresAt.IsZero() {
+		t.Fatalf("expected the key to get the maximum expiration, got %s", body)
+	}
+	if stored := store.Keys[created.Data.Key.ID]; stored.SecretHash == "" || strings.Contains(created.Data.ApiKey, stored.SecretHash) {
+		t.Fatalf("expected only the secret hash to be stored, got %+v", stored)
+	}
+
+	protected := middlewares.ApiAuthOrErrorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := types.ApiKeyScopesFromContext(r.Context())
+		if !ok {
+			t.Fatal("expected the request to be authenticated with the key")
+		}
+		w.Write([]byte(authShared.GetCurrentUserID(r) + " " + strings.Join(scopes, ",")))
+	}), authShared)
+
+	call := func(apiKey string) string {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		recorder := httptest.NewRecorder()
+		protected.ServeHTTP(recorder, req)
+		return recorder.Body.String()
+	}
+
+	if got := call(created.Data.ApiKey); got != "user-1 orders:read" {
+		t.Fatalf("expected the key to authenticate with its scopes, got %q", got)
+	}
+
+	if body := post(authShared.LinkApiApiKeyRename(), url.Values{"key_id": {created.Data.Key.ID}, "name": {"Deploys"}}); !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("expected the key to be renamed, got %s", body)
+	}
+
+	body = post(authShared.LinkApiApiKeys(), url.Values{})
+	if !strings.Contains(body, `"name":"Deploys"`) || !strings.Contains(body, `"last_used_at"`) || strings.Contains(body, created.Data.ApiKey) {
+		t.Fatalf("expected the renamed key without its secret, got %s", body)
+	}
+
+	// Keys cannot be used to manage keys
+	req = httptest.NewRequest(http.MethodPost, authShared.LinkApiApiKeyCreate(), nil)
+	req.Header.Set("Authorization", "Bearer "+created.Data.ApiKey)
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a key not to sign in to the key management, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	if body := post(authShared.LinkApiApiKeyRevoke(), url.Values{"key_id": {created.Data.Key.ID}}); !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("expected the key to be revoked, got %s", body)
+	}
+
+	if got := call(created.Data.ApiKey); !strings.Contains(got, "api key is invalid") {
+		t.Fatalf("expected the revoked key to be rejected, got %q", got)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/dracory/auth/internal/links"
 	"github.com/dracory/auth/middlewares"
 	"github.com/dracory/auth/types"
-	"github.com/dracory/auth/utils"
 )
 
 // Auth defines the structure for the authentication
@@ -26,9 +25,13 @@ type authImplementation struct {
 	funcLayout              func(content string) string
 	funcTemporaryKeyGet     func(key string) (value string, err error)
 	funcTemporaryKeySet     func(key string, value string, expiresSeconds int) (err error)
+	funcTemporaryKeyDelete  func(key string) (err error)
 	funcUserFindByAuthToken func(ctx context.Context, token string, options types.UserAuthOptions) (userID string, err error)
 	funcUserLogout          func(ctx context.Context, userID string, options types.UserAuthOptions) (err error)
 	funcUserStoreAuthToken  func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error
+	sessionStore            types.SessionStore
+	sessionLifetime         time.Duration
+	sessionIdleTimeout      time.Duration
 	// ===== END: shared by all implementations
 
 	// ===== START: username(email) and password options
@@ -39,10 +42,59 @@ type authImplementation struct {
 	funcUserLogin                    func(ctx context.Context, username string, password string, options types.UserAuthOptions) (userID string, err error)
 	funcUserPasswordChange           func(ctx context.Context, username string, newPassword string, options types.UserAuthOptions) (err error)
 	funcUserRegister                 func(ctx context.Context, username string, password string, first_name string, last_name string, options types.UserAuthOptions) (err error)
+	funcUserRegisterWithPasswordHash func(ctx context.Context, username string, passwordHash string, firstName string, lastName string, options types.UserAuthOptions) (err error)
+	funcPasswordHash                 func(password string) (passwordHash string, err error)
 	funcUserFindByUsername           func(ctx context.Context, username string, first_name string, last_name string, options types.UserAuthOptions) (userID string, err error)
 	passwordStrength                 *types.PasswordStrengthConfig
 	// ===== END: username(email) and password options
 
+	// ===== START: two-factor authentication (TOTP) options
+	funcUserTotpSecretFind  func(ctx context.Context, userID string, options types.UserAuthOptions) (secret string, err error)
+	funcUserTotpSecretStore func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) (err error)
+	totpIssuer              string
+
+	funcUserRecoveryCodesStore  func(ctx context.Context, userID string, codeHashes []string, options types.UserAuthOptions) (err error)
+	funcUserRecoveryCodeConsume func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (consumed bool, err error)
+	// ===== END: two-factor authentication (TOTP) options
+
+	// ===== START: passkeys (WebAuthn)
+	webAuthn *types.WebAuthnConfig
+	// ===== END: passkeys (WebAuthn)
+
+	// ===== START: OpenID Connect providers
+	oidc *types.OIDCConfig
+	// ===== END: OpenID Connect providers
+
+	// ===== START: JWT access tokens
+	jwt *types.JWTConfig
+	// ===== END: JWT access tokens
+
+	// ===== START: refresh tokens
+	refreshToken *types.RefreshTokenConfig
+	// ===== END: refresh tokens
+
+	// ===== START: remember me
+	rememberMe *types.RememberMeConfig
+	// ===== END: remember me
+
+	// ===== START: auth token hashing
+	authTokenHashing *types.AuthTokenHashingConfig
+	// ===== END: auth token hashing
+
+	// ===== START: API keys
+	apiKeys *types.ApiKeyConfig
+
+	accountLockout *types.AccountLockoutConfig
+	// counterStore keeps the account lockout and attempt counters; see
+	// temporaryKeyCounterStore for the default
+	counterStore types.CounterStore
+	// ===== END: API keys
+
+	// ===== START: authorization
+	funcUserRoles       func(ctx context.Context, userID string, options types.UserAuthOptions) (roles []string, err error)
+	funcUserPermissions func(ctx context.Context, userID string, options types.UserAuthOptions) (permissions []string, err error)
+	// ===== END: authorization
+
 	// ===== START: passwordless options
 	passwordless                              bool
 	passwordlessFuncUserFindByEmail           func(ctx context.Context, email string, options types.UserAuthOptions) (userID string, err error)
@@ -50,12 +102,25 @@ type authImplementation struct {
 	passwordlessFuncEmailTemplateRegisterCode func(ctx context.Context, email string, passwordRestoreLink string, options types.UserAuthOptions) string // optional
 	passwordlessFuncEmailSend                 func(ctx context.Context, email string, emailSubject string, emailBody string) (err error)
 	passwordlessFuncUserRegister              func(ctx context.Context, email string, firstName string, lastName string, options types.UserAuthOptions) (err error)
+	passwordlessDisableLoginCodeBinding       bool
+	passwordlessMagicLink                     *types.MagicLinkConfig
+	passwordlessChannel                       types.PasswordlessChannel
+	passwordlessFuncUserFindByPhone           func(ctx context.Context, phone string, options types.UserAuthOptions) (userID string, err error)
+	passwordlessFuncSmsTemplateLoginCode      func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string
+	passwordlessFuncSmsSend                   func(ctx context.Context, phone string, message string) (err error)
 	// ===== END: passwordless options
 
 	// ===== START: rate limiting
 	disableRateLimit   bool
 	funcCheckRateLimit func(ip string, endpoint string) (allowed bool, retryAfter time.Duration, err error)
-	rateLimiter        *utils.InMemoryRateLimiter
+	// funcRateLimitReset reports successful requests to a custom rate
+	// limiter
+	funcRateLimitReset func(ip string, endpoint string) error
+	rateLimiter        types.RateLimiter
+	// rateLimitPolicy is the policy of endpoints without a built-in or
+	// configured one in rateLimitPolicies
+	rateLimitPolicy   types.RateLimitPolicy
+	rateLimitPolicies map[string]types.RateLimitPolicy
 	// ===== END: rate limiting
 
 	cookieConfig CookieConfig
@@ -101,6 +166,11 @@ func (a authImplementation) IsVerificationEnabled() bool {
 	return a.enableVerification
 }
 
+func (a authImplementation) IsLoginCodeBindingEnabled() bool {
+	// The short numeric codes sent by text message are always bound
+	return a.passwordless && (!a.passwordlessDisableLoginCodeBinding || a.GetPasswordlessChannel() == types.PasswordlessChannelSms)
+}
+
 // GetLogger returns the configured structured logger for this Auth instance.
 // If no logger was explicitly provided, it falls back to slog.Default().
 // Under normal library usage this method always returns a non-nil *slog.Logger.
@@ -115,9 +185,14 @@ func (a *authImplementation) SetLogger(logger *slog.Logger) {
 	a.logger = logger
 }
 
-// GetCurrentUserID returns the authenticated user ID stored in the request
-// context, or an empty string if no user ID is attached.
+// GetCurrentUserID returns the user ID of the principal of the request, or
+// the authenticated user ID stored in the request context, or an empty
+// string if no user ID is attached.
 func (a authImplementation) GetCurrentUserID(r *http.Request) string {
+	if principal := types.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.UserID
+	}
+
 	authenticatedUserID := r.Context().Value(AuthenticatedUserID{})
 	if authenticatedUserID == nil {
 		return ""
@@ -141,6 +216,30 @@ func (a *authImplementation) SetFuncUserFindByAuthToken(fn func(ctx context.Cont
 	a.funcUserFindByAuthToken = fn
 }
 
+func (a authImplementation) GetSessionStore() types.SessionStore {
+	return a.sessionStore
+}
+
+func (a *authImplementation) SetSessionStore(store types.SessionStore) {
+	a.sessionStore = store
+}
+
+func (a authImplementation) GetSessionLifetime() time.Duration {
+	return a.sessionLifetime
+}
+
+func (a *authImplementation) SetSessionLifetime(lifetime time.Duration) {
+	a.sessionLifetime = lifetime
+}
+
+func (a authImplementation) GetSessionIdleTimeout() time.Duration {
+	return a.sessionIdleTimeout
+}
+
+func (a *authImplementation) SetSessionIdleTimeout(timeout time.Duration) {
+	a.sessionIdleTimeout = timeout
+}
+
 func (a authImplementation) GetDisableRateLimit() bool {
 	return a.disableRateLimit
 }
@@ -181,6 +280,22 @@ func (a *authImplementation) SetFuncUserRegister(fn func(ctx context.Context, us
 	a.funcUserRegister = fn
 }
 
+func (a authImplementation) GetFuncUserRegisterWithPasswordHash() func(ctx context.Context, username, passwordHash, firstName, lastName string, options types.UserAuthOptions) error {
+	return a.funcUserRegisterWithPasswordHash
+}
+
+func (a *authImplementation) SetFuncUserRegisterWithPasswordHash(fn func(ctx context.Context, username, passwordHash, firstName, lastName string, options types.UserAuthOptions) error) {
+	a.funcUserRegisterWithPasswordHash = fn
+}
+
+func (a authImplementation) GetFuncPasswordHash() func(password string) (string, error) {
+	return a.funcPasswordHash
+}
+
+func (a *authImplementation) SetFuncPasswordHash(fn func(password string) (string, error)) {
+	a.funcPasswordHash = fn
+}
+
 func (a authImplementation) GetFuncUserPasswordChange() func(ctx context.Context, userID, password string, options types.UserAuthOptions) error {
 	return a.funcUserPasswordChange
 }
@@ -229,6 +344,41 @@ func (a *authImplementation) SetPasswordlessFuncEmailSend(fn func(ctx context.Co
 	a.passwordlessFuncEmailSend = fn
 }
 
+func (a authImplementation) GetPasswordlessChannel() types.PasswordlessChannel {
+	if a.passwordlessChannel == "" {
+		return types.PasswordlessChannelEmail
+	}
+	return a.passwordlessChannel
+}
+
+func (a *authImplementation) SetPasswordlessChannel(channel types.PasswordlessChannel) {
+	a.passwordlessChannel = channel
+}
+
+func (a authImplementation) GetPasswordlessUserFindByPhone() func(ctx context.Context, phone string, options types.UserAuthOptions) (string, error) {
+	return a.passwordlessFuncUserFindByPhone
+}
+
+func (a *authImplementation) SetPasswordlessUserFindByPhone(fn func(ctx context.Context, phone string, options types.UserAuthOptions) (string, error)) {
+	a.passwordlessFuncUserFindByPhone = fn
+}
+
+func (a authImplementation) GetPasswordlessFuncSmsTemplateLoginCode() func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string {
+	return a.passwordlessFuncSmsTemplateLoginCode
+}
+
+func (a *authImplementation) SetPasswordlessFuncSmsTemplateLoginCode(fn func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string) {
+	a.passwordlessFuncSmsTemplateLoginCode = fn
+}
+
+func (a authImplementation) GetPasswordlessFuncSmsSend() func(ctx context.Context, phone string, message string) error {
+	return a.passwordlessFuncSmsSend
+}
+
+func (a *authImplementation) SetPasswordlessFuncSmsSend(fn func(ctx context.Context, phone string, message string) error) {
+	a.passwordlessFuncSmsSend = fn
+}
+
 func (a authImplementation) GetFuncUserFindByUsername() func(ctx context.Context, username, firstName, lastName string, options types.UserAuthOptions) (string, error) {
 	return a.funcUserFindByUsername
 }
@@ -269,6 +419,130 @@ func (a *authImplementation) SetFuncUserStoreAuthToken(fn func(ctx context.Conte
 	a.funcUserStoreAuthToken = fn
 }
 
+func (a authImplementation) GetWebAuthn() *types.WebAuthnConfig {
+	return a.webAuthn
+}
+
+func (a *authImplementation) SetWebAuthn(config *types.WebAuthnConfig) {
+	a.webAuthn = config
+}
+
+func (a authImplementation) GetOIDC() *types.OIDCConfig {
+	return a.oidc
+}
+
+func (a *authImplementation) SetOIDC(config *types.OIDCConfig) {
+	a.oidc = config
+}
+
+func (a authImplementation) GetJWT() *types.JWTConfig {
+	return a.jwt
+}
+
+func (a *authImplementation) SetJWT(config *types.JWTConfig) {
+	a.jwt = config
+}
+
+func (a authImplementation) GetRefreshToken() *types.RefreshTokenConfig {
+	return a.refreshToken
+}
+
+func (a *authImplementation) SetRefreshToken(config *types.RefreshTokenConfig) {
+	a.refreshToken = config
+}
+
+func (a authImplementation) GetRememberMe() *types.RememberMeConfig {
+	return a.rememberMe
+}
+
+func (a *authImplementation) SetRememberMe(config *types.RememberMeConfig) {
+	a.rememberMe = config
+}
+
+func (a authImplementation) GetMagicLink() *types.MagicLinkConfig {
+	return a.passwordlessMagicLink
+}
+
+func (a *authImplementation) SetMagicLink(config *types.MagicLinkConfig) {
+	a.passwordlessMagicLink = config
+}
+
+func (a authImplementation) GetAuthTokenHashing() *types.AuthTokenHashingConfig {
+	return a.authTokenHashing
+}
+
+func (a *authImplementation) SetAuthTokenHashing(config *types.AuthTokenHashingConfig) {
+	a.authTokenHashing = config
+}
+
+func (a authImplementation) GetApiKeys() *types.ApiKeyConfig {
+	return a.apiKeys
+}
+
+func (a *authImplementation) SetApiKeys(config *types.ApiKeyConfig) {
+	a.apiKeys = config
+}
+
+func (a authImplementation) GetAccountLockout() *types.AccountLockoutConfig {
+	return a.accountLockout
+}
+
+func (a *authImplementation) SetAccountLockout(config *types.AccountLockoutConfig) {
+	a.accountLockout = config
+}
+
+func (a authImplementation) GetFuncUserRoles() func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+	return a.funcUserRoles
+}
+
+func (a *authImplementation) SetFuncUserRoles(fn func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error)) {
+	a.funcUserRoles = fn
+}
+
+func (a authImplementation) GetFuncUserPermissions() func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+	return a.funcUserPermissions
+}
+
+func (a *authImplementation) SetFuncUserPermissions(fn func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error)) {
+	a.funcUserPermissions = fn
+}
+
+func (a authImplementation) GetFuncUserTotpSecretFind() func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error) {
+	return a.funcUserTotpSecretFind
+}
+
+func (a *authImplementation) SetFuncUserTotpSecretFind(fn func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error)) {
+	a.funcUserTotpSecretFind = fn
+}
+
+func (a authImplementation) GetFuncUserTotpSecretStore() func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error {
+	return a.funcUserTotpSecretStore
+}
+
+func (a *authImplementation) SetFuncUserTotpSecretStore(fn func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error) {
+	a.funcUserTotpSecretStore = fn
+}
+
+func (a authImplementation) GetFuncUserRecoveryCodesStore() func(ctx context.Context, userID string, codeHashes []string, options types.UserAuthOptions) error {
+	return a.funcUserRecoveryCodesStore
+}
+
+func (a *authImplementation) SetFuncUserRecoveryCodesStore(fn func(ctx context.Context, userID string, codeHashes []string, options types.UserAuthOptions) error) {
+	a.funcUserRecoveryCodesStore = fn
+}
+
+func (a authImplementation) GetFuncUserRecoveryCodeConsume() func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (bool, error) {
+	return a.funcUserRecoveryCodeConsume
+}
+
+func (a *authImplementation) SetFuncUserRecoveryCodeConsume(fn func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (bool, error)) {
+	a.funcUserRecoveryCodeConsume = fn
+}
+
+func (a authImplementation) GetTotpIssuer() string {
+	return a.totpIssuer
+}
+
 func (a authImplementation) SetAuthCookie(w http.ResponseWriter, r *http.Request, token string) {
 	a.setAuthCookie(w, r, token)
 }
@@ -277,6 +551,22 @@ func (a authImplementation) RemoveAuthCookie(w http.ResponseWriter, r *http.Requ
 	a.removeAuthCookie(w, r)
 }
 
+func (a authImplementation) SetRememberCookie(w http.ResponseWriter, r *http.Request, value string, expiresAt time.Time) {
+	a.setRememberCookie(w, r, value, expiresAt)
+}
+
+func (a authImplementation) GetCounterStore() types.CounterStore {
+	return a.counterStore
+}
+
+func (a *authImplementation) SetCounterStore(store types.CounterStore) {
+	a.counterStore = store
+}
+
+func (a authImplementation) RemoveRememberCookie(w http.ResponseWriter, r *http.Request) {
+	a.removeRememberCookie(w, r)
+}
+
 func (a authImplementation) AuthenticateViaUsername(w http.ResponseWriter, r *http.Request, email, firstName, lastName string) {
 	a.authenticateViaUsername(w, r, email, firstName, lastName)
 }
@@ -297,6 +587,14 @@ func (a *authImplementation) SetFuncTemporaryKeySet(fn func(key string, value st
 	a.funcTemporaryKeySet = fn
 }
 
+func (a authImplementation) GetFuncTemporaryKeyDelete() func(key string) error {
+	return a.funcTemporaryKeyDelete
+}
+
+func (a *authImplementation) SetFuncTemporaryKeyDelete(fn func(key string) error) {
+	a.funcTemporaryKeyDelete = fn
+}
+
 func (a authImplementation) LinkApiLogin() string {
 	return links.ApiLogin(a.endpoint)
 }
@@ -305,6 +603,10 @@ func (a authImplementation) LinkApiLoginCodeVerify() string {
 	return links.ApiLoginCodeVerify(a.endpoint)
 }
 
+func (a authImplementation) LinkApiLogin2faVerify() string {
+	return links.ApiLogin2faVerify(a.endpoint)
+}
+
 func (a authImplementation) LinkApiLogout() string {
 	return links.ApiLogout(a.endpoint)
 }
@@ -333,6 +635,117 @@ func (a authImplementation) LinkLoginCodeVerify() string {
 	return links.LoginCodeVerify(a.endpoint)
 }
 
+func (a authImplementation) LinkLogin2faVerify() string {
+	return links.Login2faVerify(a.endpoint)
+}
+
+func (a authImplementation) LinkTwoFactorEnroll() string {
+	return links.TwoFactorEnroll(a.endpoint)
+}
+
+func (a authImplementation) LinkApiTwoFactorEnroll() string {
+	return links.ApiTwoFactorEnroll(a.endpoint)
+}
+
+func (a authImplementation) LinkApiTwoFactorEnrollConfirm() string {
+	return links.ApiTwoFactorEnrollConfirm(a.endpoint)
+}
+
+func (a authImplementation) LinkApiTwoFactorRecoveryCodes() string {
+	return links.ApiTwoFactorRecoveryCodes(a.endpoint)
+}
+
+func (a authImplementation) LinkApiWebAuthnRegisterBegin() string {
+	return links.ApiWebAuthnRegisterBegin(a.endpoint)
+}
+
+func (a authImplementation) LinkApiWebAuthnRegisterFinish() string {
+	return links.ApiWebAuthnRegisterFinish(a.endpoint)
+}
+
+func (a authImplementation) LinkApiWebAuthnLoginBegin() string {
+	return links.ApiWebAuthnLoginBegin(a.endpoint)
+}
+
+func (a authImplementation) LinkApiWebAuthnLoginFinish() string {
+	return links.ApiWebAuthnLoginFinish(a.endpoint)
+}
+
+// LinkApiTokenRefresh - returns the refresh token exchange API URL
+func (a authImplementation) LinkApiTokenRefresh() string {
+	return links.ApiTokenRefresh(a.endpoint)
+}
+
+// LinkApiReauth - returns the re-authentication API URL
+func (a authImplementation) LinkApiReauth() string {
+	return links.ApiReauth(a.endpoint)
+}
+
+// LinkApiSessions - returns the active sessions listing API URL
+func (a authImplementation) LinkApiSessions() string {
+	return links.ApiSessions(a.endpoint)
+}
+
+// LinkApiSessionRevoke - returns the session revocation API URL
+func (a authImplementation) LinkApiSessionRevoke() string {
+	return links.ApiSessionRevoke(a.endpoint)
+}
+
+// LinkApiSessionsRevokeOthers - returns the API URL revoking all other sessions
+func (a authImplementation) LinkApiSessionsRevokeOthers() string {
+	return links.ApiSessionsRevokeOthers(a.endpoint)
+}
+
+// LinkApiApiKeys - returns the API keys listing API URL
+func (a authImplementation) LinkApiApiKeys() string {
+	return links.ApiApiKeys(a.endpoint)
+}
+
+// LinkApiApiKeyCreate - returns the API key creation API URL
+func (a authImplementation) LinkApiApiKeyCreate() string {
+	return links.ApiApiKeyCreate(a.endpoint)
+}
+
+// LinkApiApiKeyRename - returns the API key rename API URL
+func (a authImplementation) LinkApiApiKeyRename() string {
+	return links.ApiApiKeyRename(a.endpoint)
+}
+
+// LinkApiApiKeyRevoke - returns the API key revocation API URL
+func (a authImplementation) LinkApiApiKeyRevoke() string {
+	return links.ApiApiKeyRevoke(a.endpoint)
+}
+
+// LinkReauth - returns the re-authentication page URL
+func (a authImplementation) LinkReauth() string {
+	return links.Reauth(a.endpoint)
+}
+
+// LinkAccountUnlock - returns the account unlock URL
+func (a authImplementation) LinkAccountUnlock(token string) string {
+	return links.AccountUnlock(a.endpoint) + "?t=" + token
+}
+
+// LinkSessions - returns the active sessions page URL
+func (a authImplementation) LinkSessions() string {
+	return links.Sessions(a.endpoint)
+}
+
+// LinkPasskeys - returns the passkey management URL
+func (a authImplementation) LinkPasskeys() string {
+	return links.Passkeys(a.endpoint)
+}
+
+// LinkOIDCLogin - returns the URL starting the sign-in with the OpenID Connect provider
+func (a authImplementation) LinkOIDCLogin(provider string) string {
+	return links.OIDCLogin(a.endpoint, provider)
+}
+
+// LinkOIDCCallback - returns the callback URL of the OpenID Connect provider
+func (a authImplementation) LinkOIDCCallback(provider string) string {
+	return links.OIDCCallback(a.endpoint, provider)
+}
+
 func (a authImplementation) LinkLogout() string {
 	return links.Logout(a.endpoint)
 }
@@ -382,3 +795,19 @@ func (a *authImplementation) ApiAuthOrErrorMiddleware(next http.Handler) http.Ha
 func (a *authImplementation) WebAppendUserIdIfExistsMiddleware(next http.Handler) http.Handler {
 	return middlewares.WebAppendUserIdIfExistsMiddleware(next, a)
 }
+
+func (a *authImplementation) RequireRole(next http.Handler, roles ...string) http.Handler {
+	return middlewares.RequireRole(next, a, roles...)
+}
+
+func (a *authImplementation) RequireAnyPermission(next http.Handler, permissions ...string) http.Handler {
+	return middlewares.RequireAnyPermission(next, a, permissions...)
+}
+
+func (a *authImplementation) RequireAllPermissions(next http.Handler, permissions ...string) http.Handler {
+	return middlewares.RequireAllPermissions(next, a, permissions...)
+}
+
+func (a *authImplementation) RequireRecentAuth(next http.Handler, maxAge time.Duration) http.Handler {
+	return middlewares.RequireRecentAuth(next, a, maxAge)
+}
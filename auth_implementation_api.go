@@ -3,14 +3,31 @@ package auth
 import (
 	"net/http"
 
+	"github.com/dracory/auth/internal/api/api_api_key_create"
+	"github.com/dracory/auth/internal/api/api_api_key_rename"
+	"github.com/dracory/auth/internal/api/api_api_key_revoke"
+	"github.com/dracory/auth/internal/api/api_api_keys"
 	"github.com/dracory/auth/internal/api/api_authenticate_via_username"
 	"github.com/dracory/auth/internal/api/api_login"
+	"github.com/dracory/auth/internal/api/api_login_2fa_verify"
 	"github.com/dracory/auth/internal/api/api_login_code_verify"
 	"github.com/dracory/auth/internal/api/api_logout"
 	"github.com/dracory/auth/internal/api/api_password_reset"
 	"github.com/dracory/auth/internal/api/api_password_restore"
+	"github.com/dracory/auth/internal/api/api_reauth"
 	"github.com/dracory/auth/internal/api/api_register"
 	"github.com/dracory/auth/internal/api/api_register_code_verify"
+	"github.com/dracory/auth/internal/api/api_session_revoke"
+	"github.com/dracory/auth/internal/api/api_sessions"
+	"github.com/dracory/auth/internal/api/api_sessions_revoke_others"
+	"github.com/dracory/auth/internal/api/api_token_refresh"
+	"github.com/dracory/auth/internal/api/api_two_factor_enroll"
+	"github.com/dracory/auth/internal/api/api_two_factor_enroll_confirm"
+	"github.com/dracory/auth/internal/api/api_two_factor_recovery_codes"
+	"github.com/dracory/auth/internal/api/api_webauthn_login_begin"
+	"github.com/dracory/auth/internal/api/api_webauthn_login_finish"
+	"github.com/dracory/auth/internal/api/api_webauthn_register_begin"
+	"github.com/dracory/auth/internal/api/api_webauthn_register_finish"
 )
 
 func (a authImplementation) apiLogin(w http.ResponseWriter, r *http.Request) {
@@ -37,6 +54,74 @@ func (a authImplementation) apiLoginCodeVerify(w http.ResponseWriter, r *http.Re
 	api_login_code_verify.ApiLoginCodeVerifyWithAuth(w, r, &a)
 }
 
+func (a authImplementation) apiLogin2faVerify(w http.ResponseWriter, r *http.Request) {
+	api_login_2fa_verify.ApiLogin2faVerifyWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiTokenRefresh(w http.ResponseWriter, r *http.Request) {
+	api_token_refresh.ApiTokenRefreshWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiReauth(w http.ResponseWriter, r *http.Request) {
+	api_reauth.ApiReauthWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiSessions(w http.ResponseWriter, r *http.Request) {
+	api_sessions.ApiSessionsWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	api_session_revoke.ApiSessionRevokeWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiSessionsRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	api_sessions_revoke_others.ApiSessionsRevokeOthersWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiApiKeys(w http.ResponseWriter, r *http.Request) {
+	api_api_keys.ApiApiKeysWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiApiKeyCreate(w http.ResponseWriter, r *http.Request) {
+	api_api_key_create.ApiApiKeyCreateWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiApiKeyRename(w http.ResponseWriter, r *http.Request) {
+	api_api_key_rename.ApiApiKeyRenameWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiApiKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	api_api_key_revoke.ApiApiKeyRevokeWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	api_two_factor_enroll.ApiTwoFactorEnrollWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiTwoFactorEnrollConfirm(w http.ResponseWriter, r *http.Request) {
+	api_two_factor_enroll_confirm.ApiTwoFactorEnrollConfirmWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	api_two_factor_recovery_codes.ApiTwoFactorRecoveryCodesWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiWebAuthnRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	api_webauthn_register_begin.ApiWebAuthnRegisterBeginWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiWebAuthnRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	api_webauthn_register_finish.ApiWebAuthnRegisterFinishWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiWebAuthnLoginBegin(w http.ResponseWriter, r *http.Request) {
+	api_webauthn_login_begin.ApiWebAuthnLoginBeginWithAuth(w, r, &a)
+}
+
+func (a authImplementation) apiWebAuthnLoginFinish(w http.ResponseWriter, r *http.Request) {
+	api_webauthn_login_finish.ApiWebAuthnLoginFinishWithAuth(w, r, &a)
+}
+
 func (a authImplementation) apiRegisterCodeVerify(w http.ResponseWriter, r *http.Request) {
 	api_register_code_verify.ApiRegisterCodeVerifyWithAuth(w, r, &a)
 }
@@ -3,6 +3,8 @@ package auth
 import (
 	"net/http"
 	"time"
+
+	authtypes "github.com/dracory/auth/types"
 )
 
 func (a authImplementation) setAuthCookie(w http.ResponseWriter, r *http.Request, token string) {
@@ -10,7 +12,7 @@ func (a authImplementation) setAuthCookie(w http.ResponseWriter, r *http.Request
 	if cfg == (CookieConfig{}) {
 		cfg = defaultCookieConfig()
 	}
-	setCookieWithConfig(w, r, token, cfg)
+	setCookieWithConfig(w, r, CookieName, token, cfg)
 }
 
 func (a authImplementation) removeAuthCookie(w http.ResponseWriter, r *http.Request) {
@@ -18,7 +20,30 @@ func (a authImplementation) removeAuthCookie(w http.ResponseWriter, r *http.Requ
 	if cfg == (CookieConfig{}) {
 		cfg = defaultCookieConfig()
 	}
-	removeCookieWithConfig(w, r, cfg)
+	removeCookieWithConfig(w, r, CookieName, cfg)
+}
+
+// setRememberCookie sets the remember-me cookie with the attributes of the
+// auth cookie, but living until the remember-me token expires.
+func (a authImplementation) setRememberCookie(w http.ResponseWriter, r *http.Request, value string, expiresAt time.Time) {
+	cfg := a.cookieConfig
+	if cfg == (CookieConfig{}) {
+		cfg = defaultCookieConfig()
+	}
+	cfg.MaxAge = int(time.Until(expiresAt).Seconds())
+	if cfg.MaxAge <= 0 {
+		removeCookieWithConfig(w, r, authtypes.RememberCookieName, cfg)
+		return
+	}
+	setCookieWithConfig(w, r, authtypes.RememberCookieName, value, cfg)
+}
+
+func (a authImplementation) removeRememberCookie(w http.ResponseWriter, r *http.Request) {
+	cfg := a.cookieConfig
+	if cfg == (CookieConfig{}) {
+		cfg = defaultCookieConfig()
+	}
+	removeCookieWithConfig(w, r, authtypes.RememberCookieName, cfg)
 }
 
 func defaultCookieConfig() CookieConfig {
@@ -31,7 +56,7 @@ func defaultCookieConfig() CookieConfig {
 	}
 }
 
-func setCookieWithConfig(w http.ResponseWriter, r *http.Request, token string, cfg CookieConfig) {
+func setCookieWithConfig(w http.ResponseWriter, r *http.Request, name string, token string, cfg CookieConfig) {
 	sameSite := cfg.SameSite
 	if sameSite == 0 {
 		sameSite = http.SameSiteLaxMode
@@ -55,7 +80,7 @@ func setCookieWithConfig(w http.ResponseWriter, r *http.Request, token string, c
 	expires := time.Now().Add(time.Duration(maxAge) * time.Second)
 
 	cookie := http.Cookie{
-		Name:     CookieName,
+		Name:     name,
 		Value:    token,
 		HttpOnly: cfg.HttpOnly,
 		Secure:   secure,
@@ -69,7 +94,7 @@ func setCookieWithConfig(w http.ResponseWriter, r *http.Request, token string, c
 	http.SetCookie(w, &cookie)
 }
 
-func removeCookieWithConfig(w http.ResponseWriter, r *http.Request, cfg CookieConfig) {
+func removeCookieWithConfig(w http.ResponseWriter, r *http.Request, name string, cfg CookieConfig) {
 	sameSite := cfg.SameSite
 	if sameSite == 0 {
 		sameSite = http.SameSiteLaxMode
@@ -86,7 +111,7 @@ func removeCookieWithConfig(w http.ResponseWriter, r *http.Request, cfg CookieCo
 	}
 
 	cookie := http.Cookie{
-		Name:     CookieName,
+		Name:     name,
 		Value:    "none",
 		HttpOnly: cfg.HttpOnly,
 		Secure:   secure,
@@ -3,13 +3,22 @@ package auth
 import (
 	"net/http"
 
+	page_account_unlock "github.com/dracory/auth/internal/ui/page_account_unlock"
 	"github.com/dracory/auth/internal/ui/page_login"
+	page_login_2fa_verify "github.com/dracory/auth/internal/ui/page_login_2fa_verify"
 	page_login_code_verify "github.com/dracory/auth/internal/ui/page_login_code_verify"
+	page_login_link "github.com/dracory/auth/internal/ui/page_login_link"
 	page_logout "github.com/dracory/auth/internal/ui/page_logout"
+	page_oidc_callback "github.com/dracory/auth/internal/ui/page_oidc_callback"
+	page_oidc_login "github.com/dracory/auth/internal/ui/page_oidc_login"
+	page_passkeys "github.com/dracory/auth/internal/ui/page_passkeys"
 	page_password_reset "github.com/dracory/auth/internal/ui/page_password_reset"
 	page_password_restore "github.com/dracory/auth/internal/ui/page_password_restore"
+	page_reauth "github.com/dracory/auth/internal/ui/page_reauth"
 	page_register "github.com/dracory/auth/internal/ui/page_register"
 	page_register_code_verify "github.com/dracory/auth/internal/ui/page_register_code_verify"
+	page_sessions "github.com/dracory/auth/internal/ui/page_sessions"
+	page_two_factor_enroll "github.com/dracory/auth/internal/ui/page_two_factor_enroll"
 )
 
 func (a authImplementation) pageLogin(w http.ResponseWriter, r *http.Request) {
@@ -39,3 +48,39 @@ func (a authImplementation) pagePasswordReset(w http.ResponseWriter, r *http.Req
 func (a authImplementation) pageLoginCodeVerify(w http.ResponseWriter, r *http.Request) {
 	page_login_code_verify.PageLoginCodeVerify(w, r, &a)
 }
+
+func (a authImplementation) pageLoginLink(w http.ResponseWriter, r *http.Request) {
+	page_login_link.PageLoginLinkWithAuth(w, r, &a)
+}
+
+func (a authImplementation) pageLogin2faVerify(w http.ResponseWriter, r *http.Request) {
+	page_login_2fa_verify.PageLogin2faVerify(w, r, &a)
+}
+
+func (a authImplementation) pageTwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	page_two_factor_enroll.PageTwoFactorEnroll(w, r, &a)
+}
+
+func (a authImplementation) pagePasskeys(w http.ResponseWriter, r *http.Request) {
+	page_passkeys.PagePasskeys(w, r, &a)
+}
+
+func (a authImplementation) pageSessions(w http.ResponseWriter, r *http.Request) {
+	page_sessions.PageSessions(w, r, &a)
+}
+
+func (a authImplementation) pageReauth(w http.ResponseWriter, r *http.Request) {
+	page_reauth.PageReauth(w, r, &a)
+}
+
+func (a authImplementation) pageAccountUnlock(w http.ResponseWriter, r *http.Request) {
+	page_account_unlock.PageAccountUnlockWithAuth(w, r, &a)
+}
+
+func (a authImplementation) pageOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	page_oidc_login.PageOIDCLoginWithAuth(w, r, &a)
+}
+
+func (a authImplementation) pageOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	page_oidc_callback.PageOIDCCallbackWithAuth(w, r, &a)
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateAuthTokenHashingConfig validates the optional auth token hashing
+// configuration shared by both authentication flows. JWT access tokens are
+// not stored, so there is nothing to hash.
+func validateAuthTokenHashingConfig(config *types.AuthTokenHashingConfig, jwt *types.JWTConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if jwt != nil {
+		return errors.New("auth: AuthTokenHashing cannot be used with JWT")
+	}
+
+	if len(config.Pepper) < types.MinAuthTokenPepperLength {
+		return errors.New("auth: AuthTokenHashing Pepper must be at least " + strconv.Itoa(types.MinAuthTokenPepperLength) + " characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateAuthTokenHashingConfig(t *testing.T) {
+	pepper := strings.Repeat("p", types.MinAuthTokenPepperLength)
+
+	tests := []struct {
+		name     string
+		config   *types.AuthTokenHashingConfig
+		jwt      *types.JWTConfig
+		expected string
+	}{
+		{"nil config", nil, nil, ""},
+		{"valid", &types.AuthTokenHashingConfig{Pepper: pepper}, nil, ""},
+		{"short pepper", &types.AuthTokenHashingConfig{Pepper: "secret"}, nil, "auth: AuthTokenHashing Pepper must be at least 32 characters"},
+		{"jwt", &types.AuthTokenHashingConfig{Pepper: pepper}, &types.JWTConfig{}, "auth: AuthTokenHashing cannot be used with JWT"},
+	}
+
+	for _, tt := range tests {
+		err := validateAuthTokenHashingConfig(tt.config, tt.jwt)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestAuthorization_MiddlewareMethods(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncUserFindByAuthToken = func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+		if token == "valid" {
+			return "user-1", nil
+		}
+		return "", nil
+	}
+	config.FuncUserRoles = func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		return []string{"editor"}, nil
+	}
+	config.FuncUserPermissions = func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		return []string{"orders:read"}, nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		allowed bool
+	}{
+		{"role", authShared.RequireRole(next, "admin", "editor"), true},
+		{"missing role", authShared.RequireRole(next, "admin"), false},
+		{"any permission", authShared.RequireAnyPermission(next, "orders:read", "orders:write"), true},
+		{"all permissions", authShared.RequireAllPermissions(next, "orders:read", "orders:write"), false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.AddCookie(&http.Cookie{Name: CookieName, Value: "valid"})
+		recorder := httptest.NewRecorder()
+		authShared.WebAuthOrRedirectMiddleware(tt.handler).ServeHTTP(recorder, req)
+
+		if tt.allowed && (recorder.Code != http.StatusOK || recorder.Body.String() != "ok") {
+			t.Fatalf("%s: expected the request to pass, got %d %s", tt.name, recorder.Code, recorder.Body.String())
+		}
+		if !tt.allowed && recorder.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d %s", tt.name, recorder.Code, recorder.Body.String())
+		}
+	}
+}
@@ -12,7 +12,9 @@ func (c contextKey) String() string {
 	return string(c)
 }
 
-type AuthenticatedUserID struct{}
+// AuthenticatedUserID is the context key the middlewares store the
+// authenticated user ID under.
+type AuthenticatedUserID = authtypes.AuthenticatedUserID
 
 type CookieConfig = authtypes.CookieConfig
 
@@ -27,6 +29,57 @@ const (
 	// PathApiLoginCodeVerify contains the path to api login code verification endpoint
 	PathApiLoginCodeVerify string = "api/login-code-verify"
 
+	// PathApiLogin2faVerify contains the path to api two-factor login verification endpoint
+	PathApiLogin2faVerify string = "api/login-2fa-verify"
+
+	// PathApiTwoFactorEnroll contains the path to api two-factor enrollment endpoint
+	PathApiTwoFactorEnroll string = "api/2fa-enroll"
+
+	// PathApiTwoFactorEnrollConfirm contains the path to api two-factor enrollment confirmation endpoint
+	PathApiTwoFactorEnrollConfirm string = "api/2fa-enroll-confirm"
+
+	// PathApiTwoFactorRecoveryCodes contains the path to api recovery codes regeneration endpoint
+	PathApiTwoFactorRecoveryCodes string = "api/2fa-recovery-codes"
+
+	// PathApiWebAuthnRegisterBegin contains the path to api passkey registration start endpoint
+	PathApiWebAuthnRegisterBegin string = "api/webauthn-register-begin"
+
+	// PathApiWebAuthnRegisterFinish contains the path to api passkey registration completion endpoint
+	PathApiWebAuthnRegisterFinish string = "api/webauthn-register-finish"
+
+	// PathApiWebAuthnLoginBegin contains the path to api passkey login start endpoint
+	PathApiWebAuthnLoginBegin string = "api/webauthn-login-begin"
+
+	// PathApiWebAuthnLoginFinish contains the path to api passkey login completion endpoint
+	PathApiWebAuthnLoginFinish string = "api/webauthn-login-finish"
+
+	// PathApiTokenRefresh contains the path to api refresh token exchange endpoint
+	PathApiTokenRefresh string = "api/token/refresh"
+
+	// PathApiReauth contains the path to api re-authentication endpoint
+	PathApiReauth string = "api/reauth"
+
+	// PathApiSessions contains the path to api active sessions listing endpoint
+	PathApiSessions string = "api/sessions"
+
+	// PathApiSessionRevoke contains the path to api session revocation endpoint
+	PathApiSessionRevoke string = "api/session-revoke"
+
+	// PathApiSessionsRevokeOthers contains the path to api endpoint revoking all other sessions
+	PathApiSessionsRevokeOthers string = "api/sessions-revoke-others"
+
+	// PathApiApiKeys contains the path to api API keys listing endpoint
+	PathApiApiKeys string = "api/api-keys"
+
+	// PathApiApiKeyCreate contains the path to api API key creation endpoint
+	PathApiApiKeyCreate string = "api/api-key-create"
+
+	// PathApiApiKeyRename contains the path to api API key rename endpoint
+	PathApiApiKeyRename string = "api/api-key-rename"
+
+	// PathApiApiKeyRevoke contains the path to api API key revocation endpoint
+	PathApiApiKeyRevoke string = "api/api-key-revoke"
+
 	// PathApiLogout contains the path to api logout endpoint
 	PathApiLogout string = "api/logout"
 
@@ -48,6 +101,33 @@ const (
 	// PathLoginCodeVerify contains the path to login code verification page
 	PathLoginCodeVerify string = "login-code-verify"
 
+	// PathLoginLink contains the path to the magic link login page
+	PathLoginLink string = "login-link"
+
+	// PathLogin2faVerify contains the path to two-factor login verification page
+	PathLogin2faVerify string = "login-2fa-verify"
+
+	// PathTwoFactorEnroll contains the path to two-factor enrollment page
+	PathTwoFactorEnroll string = "2fa-enroll"
+
+	// PathPasskeys contains the path to passkey management page
+	PathPasskeys string = "passkeys"
+
+	// PathReauth contains the path to re-authentication page
+	PathReauth string = "reauth"
+
+	// PathAccountUnlock contains the path to account unlock page
+	PathAccountUnlock string = "account-unlock"
+
+	// PathSessions contains the path to active sessions page
+	PathSessions string = "sessions"
+
+	// PathOIDCLogin contains the path prefix of OpenID Connect login, followed by the provider name
+	PathOIDCLogin string = "login/"
+
+	// PathOIDCCallback contains the path prefix of OpenID Connect callback, followed by the provider name
+	PathOIDCCallback string = "callback/"
+
 	// PathLogout contains the path to logout page
 	PathLogout string = "logout"
 
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+)
+
+// temporaryKeyCounterStore returns the default counter store, over the
+// temporary key callbacks of the auth as they are when a counter is used.
+// Its increments are atomic within the process only.
+func temporaryKeyCounterStore(a *authImplementation) types.CounterStore {
+	return utils.NewTemporaryKeyCounterStore(
+		func(key string) (string, error) {
+			if a.funcTemporaryKeyGet == nil {
+				return "", errors.New("auth: FuncTemporaryKeyGet is not configured")
+			}
+			return a.funcTemporaryKeyGet(key)
+		},
+		func(key string, value string, expiresSeconds int) error {
+			if a.funcTemporaryKeySet == nil {
+				return errors.New("auth: FuncTemporaryKeySet is not configured")
+			}
+			return a.funcTemporaryKeySet(key, value, expiresSeconds)
+		},
+		func(key string) error {
+			if a.funcTemporaryKeyDelete != nil {
+				return a.funcTemporaryKeyDelete(key)
+			}
+			if a.funcTemporaryKeySet == nil {
+				return errors.New("auth: FuncTemporaryKeySet is not configured")
+			}
+			return a.funcTemporaryKeySet(key, "", 1)
+		},
+	)
+}
@@ -11,6 +11,11 @@ type LoginUsernameAndPasswordResponse struct {
 	ErrorMessage   string
 	SuccessMessage string
 	Token          string
+
+	// TwoFactorRequired is true when the user has TOTP enabled. No token is
+	// issued; the TwoFactorToken must be completed via the 2FA verify endpoint.
+	TwoFactorRequired bool
+	TwoFactorToken    string
 }
 
 // LoginWithUsernameAndPassword is a standalone helper that performs the
@@ -28,6 +33,9 @@ func LoginWithUsernameAndPassword(
 		ErrorMessage:   res.ErrorMessage,
 		SuccessMessage: res.SuccessMessage,
 		Token:          res.Token,
+
+		TwoFactorRequired: res.TwoFactorRequired,
+		TwoFactorToken:    res.TwoFactorToken,
 	}
 }
 
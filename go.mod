@@ -9,6 +9,7 @@ require (
 	github.com/dracory/req v0.1.0
 	github.com/dracory/str v0.17.0
 	github.com/dracory/uncdn v0.9.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	golang.org/x/crypto v0.45.0
 )
 
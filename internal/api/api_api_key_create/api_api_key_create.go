@@ -0,0 +1,235 @@
+package api_api_key_create
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for creating an API key
+// for the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// Scopes lists the scopes keys can be granted.
+	Scopes []string
+
+	// MaxExpiration caps the lifetime of new keys; zero allows keys that
+	// never expire.
+	MaxExpiration time.Duration
+
+	// ApiKeyCreate generates and stores the key, returning it with the
+	// stored record. A zero expiresAt creates a key that does not expire.
+	ApiKeyCreate func(ctx context.Context, userID string, name string, scopes []string, expiresAt time.Time) (string, types.ApiKey, error)
+}
+
+// ApiKeyCreateErrorCode categorizes error sources.
+type ApiKeyCreateErrorCode string
+
+const (
+	ApiKeyCreateErrorCodeNone            ApiKeyCreateErrorCode = ""
+	ApiKeyCreateErrorCodeUnauthenticated ApiKeyCreateErrorCode = "unauthenticated"
+	ApiKeyCreateErrorCodeValidation      ApiKeyCreateErrorCode = "validation"
+	ApiKeyCreateErrorCodeCreate          ApiKeyCreateErrorCode = "create"
+)
+
+// ApiKeyCreateError represents a structured error in the API key creation
+// flow.
+type ApiKeyCreateError struct {
+	Code    ApiKeyCreateErrorCode
+	Message string
+	Err     error
+}
+
+func (e *ApiKeyCreateError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiKeyCreateInput holds the details of the key to create.
+type ApiKeyCreateInput struct {
+	Name   string
+	Scopes []string
+	// ExpiresInDays is the lifetime of the key, zero for the longest one
+	// allowed
+	ExpiresInDays int
+}
+
+// ApiKeyCreateResult is a created key. The key itself is only shown once.
+type ApiKeyCreateResult struct {
+	ApiKey string
+	Info   core.ApiKeyInfo
+}
+
+// ApiApiKeyCreate is the HTTP-level helper that wires request/response
+// handling to the core ApiKeyCreate business logic using the provided
+// dependencies.
+//
+// The scopes are posted as a comma separated list, and expires_in_days may
+// be left empty.
+func ApiApiKeyCreate(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	input := ApiKeyCreateInput{
+		Name: req.GetStringTrimmed(r, "name"),
+	}
+
+	for _, scope := range strings.Split(req.GetStringTrimmed(r, "scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			input.Scopes = append(input.Scopes, scope)
+		}
+	}
+
+	if days := req.GetStringTrimmed(r, "expires_in_days"); days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value < 1 {
+			api.Respond(w, r, api.Error("Expiry must be a whole number of days"))
+			return
+		}
+		input.ExpiresInDays = value
+	}
+
+	result, perr := ApiKeyCreate(r.Context(), r, input, deps)
+	if perr != nil {
+		switch perr.Code {
+		case ApiKeyCreateErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case ApiKeyCreateErrorCodeValidation:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("api key created", map[string]any{
+		"api_key": result.ApiKey,
+		"key":     result.Info,
+	}))
+}
+
+// ApiApiKeyCreateWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiApiKeyCreateWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	config := a.GetApiKeys()
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+	}
+
+	if config != nil {
+		deps.Scopes = config.Scopes
+		deps.MaxExpiration = config.MaxExpiration
+		deps.ApiKeyCreate = func(ctx context.Context, userID string, name string, scopes []string, expiresAt time.Time) (string, types.ApiKey, error) {
+			return core.ApiKeyCreate(ctx, config, userID, name, scopes, expiresAt, options, time.Now())
+		}
+	}
+
+	ApiApiKeyCreate(w, r, deps)
+}
+
+// ApiKeyCreate creates an API key for the authenticated user with a subset
+// of the available scopes. It does not write HTTP responses.
+func ApiKeyCreate(ctx context.Context, r *http.Request, input ApiKeyCreateInput, deps Dependencies) (*ApiKeyCreateResult, *ApiKeyCreateError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &ApiKeyCreateError{
+			Code:    ApiKeyCreateErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if msg := core.ApiKeyValidateName(input.Name); msg != "" {
+		return nil, &ApiKeyCreateError{
+			Code:    ApiKeyCreateErrorCodeValidation,
+			Message: msg,
+		}
+	}
+
+	scopes := []string{}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(deps.Scopes, scope) {
+			return nil, &ApiKeyCreateError{
+				Code:    ApiKeyCreateErrorCodeValidation,
+				Message: "Scope " + scope + " is not available",
+			}
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if input.ExpiresInDays < 0 {
+		return nil, &ApiKeyCreateError{
+			Code:    ApiKeyCreateErrorCodeValidation,
+			Message: "Expiry must be a whole number of days",
+		}
+	}
+
+	now := time.Now()
+	expiresAt := time.Time{}
+	lifetime := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+
+	if deps.MaxExpiration > 0 && lifetime > deps.MaxExpiration {
+		return nil, &ApiKeyCreateError{
+			Code:    ApiKeyCreateErrorCodeValidation,
+			Message: "Expiry cannot be more than " + strconv.Itoa(int(deps.MaxExpiration/(24*time.Hour))) + " days",
+		}
+	}
+
+	if lifetime == 0 {
+		lifetime = deps.MaxExpiration
+	}
+
+	if lifetime > 0 {
+		expiresAt = now.Add(lifetime)
+	}
+
+	if deps.ApiKeyCreate == nil {
+		return nil, &ApiKeyCreateError{
+			Code: ApiKeyCreateErrorCodeCreate,
+			Err:  errors.New("api keys are not configured"),
+		}
+	}
+
+	token, record, errCreate := deps.ApiKeyCreate(ctx, userID, input.Name, scopes, expiresAt)
+	if errCreate != nil {
+		return nil, &ApiKeyCreateError{
+			Code: ApiKeyCreateErrorCodeCreate,
+			Err:  errCreate,
+		}
+	}
+
+	return &ApiKeyCreateResult{
+		ApiKey: token,
+		Info:   core.ApiKeyInfoFrom(record),
+	}, nil
+}
@@ -0,0 +1,96 @@
+package api_api_key_create
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(userID string, created *types.ApiKey) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return userID },
+		Scopes:        []string{"read", "write"},
+		MaxExpiration: 30 * 24 * time.Hour,
+		ApiKeyCreate: func(ctx context.Context, userID string, name string, scopes []string, expiresAt time.Time) (string, types.ApiKey, error) {
+			*created = types.ApiKey{ID: "0123456789abcdef", UserID: userID, Name: name, Scopes: scopes, ExpiresAt: expiresAt}
+			return "ak_0123456789abcdef_secret", *created, nil
+		},
+	}
+}
+
+func TestApiApiKeyCreateRequiresUser(t *testing.T) {
+	var created types.ApiKey
+	recorder, req := testutils.MakePostRequest(t, "/api/api-key-create", url.Values{"name": {"CI"}})
+	ApiApiKeyCreate(recorder, req, newTestDeps("", &created))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected unauthenticated, got %s", body)
+	}
+}
+
+func TestApiApiKeyCreateValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		values url.Values
+		want   string
+	}{
+		{"missing name", url.Values{}, "Name is required field"},
+		{"long name", url.Values{"name": {strings.Repeat("n", 101)}}, "Name cannot be longer than 100 characters"},
+		{"unknown scope", url.Values{"name": {"CI"}, "scopes": {"read,admin"}}, "Scope admin is not available"},
+		{"invalid expiry", url.Values{"name": {"CI"}, "expires_in_days": {"soon"}}, "Expiry must be a whole number of days"},
+		{"expiry too long", url.Values{"name": {"CI"}, "expires_in_days": {"31"}}, "Expiry cannot be more than 30 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created types.ApiKey
+			recorder, req := testutils.MakePostRequest(t, "/api/api-key-create", tt.values)
+			ApiApiKeyCreate(recorder, req, newTestDeps("user-1", &created))
+
+			if body := recorder.Body.String(); !strings.Contains(body, tt.want) {
+				t.Fatalf("expected %q, got %s", tt.want, body)
+			}
+			if created.ID != "" {
+				t.Fatal("expected no key to be created")
+			}
+		})
+	}
+}
+
+func TestApiApiKeyCreateSuccess(t *testing.T) {
+	var created types.ApiKey
+	values := url.Values{"name": {"CI"}, "scopes": {"write, read,write"}, "expires_in_days": {"7"}}
+	recorder, req := testutils.MakePostRequest(t, "/api/api-key-create", values)
+	ApiApiKeyCreate(recorder, req, newTestDeps("user-1", &created))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"api_key":"ak_0123456789abcdef_secret"`) || !strings.Contains(body, `"scopes":["write","read"]`) {
+		t.Fatalf("expected the key with its scopes, got %s", body)
+	}
+	if created.UserID != "user-1" || created.Name != "CI" {
+		t.Fatalf("expected the key to be created for user-1, got %+v", created)
+	}
+	if lifetime := time.Until(created.ExpiresAt); lifetime < 6*24*time.Hour || lifetime > 7*24*time.Hour {
+		t.Fatalf("expected the key to expire in 7 days, got %v", created.ExpiresAt)
+	}
+}
+
+func TestApiKeyCreateWithoutMaxExpirationDoesNotExpire(t *testing.T) {
+	var created types.ApiKey
+	deps := newTestDeps("user-1", &created)
+	deps.MaxExpiration = 0
+
+	_, req := testutils.MakePostRequest(t, "/api/api-key-create", url.Values{})
+	if _, perr := ApiKeyCreate(context.Background(), req, ApiKeyCreateInput{Name: "CI"}, deps); perr != nil {
+		t.Fatalf("unexpected error %v", perr)
+	}
+	if !created.ExpiresAt.IsZero() {
+		t.Fatalf("expected the key not to expire, got %v", created.ExpiresAt)
+	}
+}
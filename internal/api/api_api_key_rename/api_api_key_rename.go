@@ -0,0 +1,171 @@
+package api_api_key_rename
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for renaming one of the
+// API keys of the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// ApiKeyFind returns the key with the ID, or nil when not found.
+	ApiKeyFind func(ctx context.Context, id string) (*types.ApiKey, error)
+
+	// ApiKeyRename changes the name of the key with the ID.
+	ApiKeyRename func(ctx context.Context, id string, name string) error
+}
+
+// ApiKeyRenameErrorCode categorizes error sources.
+type ApiKeyRenameErrorCode string
+
+const (
+	ApiKeyRenameErrorCodeNone            ApiKeyRenameErrorCode = ""
+	ApiKeyRenameErrorCodeUnauthenticated ApiKeyRenameErrorCode = "unauthenticated"
+	ApiKeyRenameErrorCodeValidation      ApiKeyRenameErrorCode = "validation"
+	ApiKeyRenameErrorCodeNotFound        ApiKeyRenameErrorCode = "not_found"
+	ApiKeyRenameErrorCodeFind            ApiKeyRenameErrorCode = "find"
+	ApiKeyRenameErrorCodeRename          ApiKeyRenameErrorCode = "rename"
+)
+
+// ApiKeyRenameError represents a structured error in the API key rename
+// flow.
+type ApiKeyRenameError struct {
+	Code    ApiKeyRenameErrorCode
+	Message string
+	Err     error
+}
+
+func (e *ApiKeyRenameError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiApiKeyRename is the HTTP-level helper that wires request/response
+// handling to the core ApiKeyRename business logic using the provided
+// dependencies.
+func ApiApiKeyRename(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	keyID := req.GetStringTrimmed(r, "key_id")
+	name := req.GetStringTrimmed(r, "name")
+
+	perr := ApiKeyRename(r.Context(), r, keyID, name, deps)
+	if perr != nil {
+		switch perr.Code {
+		case ApiKeyRenameErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case ApiKeyRenameErrorCodeValidation,
+			ApiKeyRenameErrorCodeNotFound:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.Success("api key renamed"))
+}
+
+// ApiApiKeyRenameWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiApiKeyRenameWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+	}
+
+	if config := a.GetApiKeys(); config != nil && config.Store != nil {
+		deps.ApiKeyFind = func(ctx context.Context, id string) (*types.ApiKey, error) {
+			return config.Store.Get(types.ContextWithUserAuthOptions(ctx, options), id)
+		}
+		deps.ApiKeyRename = func(ctx context.Context, id string, name string) error {
+			return config.Store.Rename(types.ContextWithUserAuthOptions(ctx, options), id, name)
+		}
+	}
+
+	ApiApiKeyRename(w, r, deps)
+}
+
+// ApiKeyRename renames the API key with the ID of the authenticated user.
+// Keys of other users are never found. It does not write HTTP responses.
+func ApiKeyRename(ctx context.Context, r *http.Request, keyID string, name string, deps Dependencies) *ApiKeyRenameError {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return &ApiKeyRenameError{
+			Code:    ApiKeyRenameErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if keyID == "" {
+		return &ApiKeyRenameError{
+			Code:    ApiKeyRenameErrorCodeValidation,
+			Message: "Key ID is required field",
+		}
+	}
+
+	if msg := core.ApiKeyValidateName(name); msg != "" {
+		return &ApiKeyRenameError{
+			Code:    ApiKeyRenameErrorCodeValidation,
+			Message: msg,
+		}
+	}
+
+	if deps.ApiKeyFind == nil || deps.ApiKeyRename == nil {
+		return &ApiKeyRenameError{
+			Code: ApiKeyRenameErrorCodeFind,
+			Err:  errors.New("api keys are not configured"),
+		}
+	}
+
+	key, errFind := deps.ApiKeyFind(ctx, keyID)
+	if errFind != nil {
+		return &ApiKeyRenameError{
+			Code: ApiKeyRenameErrorCodeFind,
+			Err:  errFind,
+		}
+	}
+
+	if key == nil || key.UserID != userID {
+		return &ApiKeyRenameError{
+			Code:    ApiKeyRenameErrorCodeNotFound,
+			Message: "API key not found",
+		}
+	}
+
+	if errRename := deps.ApiKeyRename(ctx, key.ID, name); errRename != nil {
+		return &ApiKeyRenameError{
+			Code: ApiKeyRenameErrorCodeRename,
+			Err:  errRename,
+		}
+	}
+
+	return nil
+}
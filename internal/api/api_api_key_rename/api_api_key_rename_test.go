@@ -0,0 +1,65 @@
+package api_api_key_rename
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(userID string, keys map[string]types.ApiKey) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return userID },
+		ApiKeyFind: func(ctx context.Context, id string) (*types.ApiKey, error) {
+			if key, ok := keys[id]; ok {
+				return &key, nil
+			}
+			return nil, nil
+		},
+		ApiKeyRename: func(ctx context.Context, id string, name string) error {
+			key := keys[id]
+			key.Name = name
+			keys[id] = key
+			return nil
+		},
+	}
+}
+
+func TestApiApiKeyRename(t *testing.T) {
+	keys := map[string]types.ApiKey{
+		"key-1": {ID: "key-1", UserID: "user-1", Name: "CI"},
+		"key-2": {ID: "key-2", UserID: "user-2", Name: "Other"},
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		values url.Values
+		want   string
+	}{
+		{"unauthenticated", "", url.Values{"key_id": {"key-1"}, "name": {"Deploys"}}, `"status":"unauthenticated"`},
+		{"missing key ID", "user-1", url.Values{"name": {"Deploys"}}, "Key ID is required field"},
+		{"missing name", "user-1", url.Values{"key_id": {"key-1"}}, "Name is required field"},
+		{"key of another user", "user-1", url.Values{"key_id": {"key-2"}, "name": {"Deploys"}}, "API key not found"},
+		{"renamed", "user-1", url.Values{"key_id": {"key-1"}, "name": {"Deploys"}}, `"status":"success"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, req := testutils.MakePostRequest(t, "/api/api-key-rename", tt.values)
+			ApiApiKeyRename(recorder, req, newTestDeps(tt.userID, keys))
+
+			if body := recorder.Body.String(); !strings.Contains(body, tt.want) {
+				t.Fatalf("expected %q, got %s", tt.want, body)
+			}
+		})
+	}
+
+	if keys["key-1"].Name != "Deploys" || keys["key-2"].Name != "Other" {
+		t.Fatalf("expected only the own key to be renamed, got %+v", keys)
+	}
+}
@@ -0,0 +1,163 @@
+package api_api_key_revoke
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for revoking one of the
+// API keys of the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// ApiKeyFind returns the key with the ID, or nil when not found.
+	ApiKeyFind func(ctx context.Context, id string) (*types.ApiKey, error)
+
+	// ApiKeyDelete deletes the key with the ID.
+	ApiKeyDelete func(ctx context.Context, id string) error
+}
+
+// ApiKeyRevokeErrorCode categorizes error sources.
+type ApiKeyRevokeErrorCode string
+
+const (
+	ApiKeyRevokeErrorCodeNone            ApiKeyRevokeErrorCode = ""
+	ApiKeyRevokeErrorCodeUnauthenticated ApiKeyRevokeErrorCode = "unauthenticated"
+	ApiKeyRevokeErrorCodeValidation      ApiKeyRevokeErrorCode = "validation"
+	ApiKeyRevokeErrorCodeNotFound        ApiKeyRevokeErrorCode = "not_found"
+	ApiKeyRevokeErrorCodeFind            ApiKeyRevokeErrorCode = "find"
+	ApiKeyRevokeErrorCodeDelete          ApiKeyRevokeErrorCode = "delete"
+)
+
+// ApiKeyRevokeError represents a structured error in the API key
+// revocation flow.
+type ApiKeyRevokeError struct {
+	Code    ApiKeyRevokeErrorCode
+	Message string
+	Err     error
+}
+
+func (e *ApiKeyRevokeError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiApiKeyRevoke is the HTTP-level helper that wires request/response
+// handling to the core ApiKeyRevoke business logic using the provided
+// dependencies.
+func ApiApiKeyRevoke(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	keyID := req.GetStringTrimmed(r, "key_id")
+
+	perr := ApiKeyRevoke(r.Context(), r, keyID, deps)
+	if perr != nil {
+		switch perr.Code {
+		case ApiKeyRevokeErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case ApiKeyRevokeErrorCodeValidation,
+			ApiKeyRevokeErrorCodeNotFound:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.Success("api key revoked"))
+}
+
+// ApiApiKeyRevokeWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiApiKeyRevokeWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+	}
+
+	if config := a.GetApiKeys(); config != nil && config.Store != nil {
+		deps.ApiKeyFind = func(ctx context.Context, id string) (*types.ApiKey, error) {
+			return config.Store.Get(types.ContextWithUserAuthOptions(ctx, options), id)
+		}
+		deps.ApiKeyDelete = func(ctx context.Context, id string) error {
+			return config.Store.Delete(types.ContextWithUserAuthOptions(ctx, options), id)
+		}
+	}
+
+	ApiApiKeyRevoke(w, r, deps)
+}
+
+// ApiKeyRevoke deletes the API key with the ID of the authenticated user.
+// Keys of other users are never found. It does not write HTTP responses.
+func ApiKeyRevoke(ctx context.Context, r *http.Request, keyID string, deps Dependencies) *ApiKeyRevokeError {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return &ApiKeyRevokeError{
+			Code:    ApiKeyRevokeErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if keyID == "" {
+		return &ApiKeyRevokeError{
+			Code:    ApiKeyRevokeErrorCodeValidation,
+			Message: "Key ID is required field",
+		}
+	}
+
+	if deps.ApiKeyFind == nil || deps.ApiKeyDelete == nil {
+		return &ApiKeyRevokeError{
+			Code: ApiKeyRevokeErrorCodeFind,
+			Err:  errors.New("api keys are not configured"),
+		}
+	}
+
+	key, errFind := deps.ApiKeyFind(ctx, keyID)
+	if errFind != nil {
+		return &ApiKeyRevokeError{
+			Code: ApiKeyRevokeErrorCodeFind,
+			Err:  errFind,
+		}
+	}
+
+	if key == nil || key.UserID != userID {
+		return &ApiKeyRevokeError{
+			Code:    ApiKeyRevokeErrorCodeNotFound,
+			Message: "API key not found",
+		}
+	}
+
+	if errDelete := deps.ApiKeyDelete(ctx, key.ID); errDelete != nil {
+		return &ApiKeyRevokeError{
+			Code: ApiKeyRevokeErrorCodeDelete,
+			Err:  errDelete,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package api_api_key_revoke
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(userID string, keys map[string]types.ApiKey) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return userID },
+		ApiKeyFind: func(ctx context.Context, id string) (*types.ApiKey, error) {
+			if key, ok := keys[id]; ok {
+				return &key, nil
+			}
+			return nil, nil
+		},
+		ApiKeyDelete: func(ctx context.Context, id string) error {
+			delete(keys, id)
+			return nil
+		},
+	}
+}
+
+func TestApiApiKeyRevoke(t *testing.T) {
+	keys := map[string]types.ApiKey{
+		"key-1": {ID: "key-1", UserID: "user-1"},
+		"key-2": {ID: "key-2", UserID: "user-2"},
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		values url.Values
+		want   string
+	}{
+		{"unauthenticated", "", url.Values{"key_id": {"key-1"}}, `"status":"unauthenticated"`},
+		{"missing key ID", "user-1", url.Values{}, "Key ID is required field"},
+		{"unknown key", "user-1", url.Values{"key_id": {"key-3"}}, "API key not found"},
+		{"key of another user", "user-1", url.Values{"key_id": {"key-2"}}, "API key not found"},
+		{"revoked", "user-1", url.Values{"key_id": {"key-1"}}, `"status":"success"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, req := testutils.MakePostRequest(t, "/api/api-key-revoke", tt.values)
+			ApiApiKeyRevoke(recorder, req, newTestDeps(tt.userID, keys))
+
+			if body := recorder.Body.String(); !strings.Contains(body, tt.want) {
+				t.Fatalf("expected %q, got %s", tt.want, body)
+			}
+		})
+	}
+
+	if _, ok := keys["key-1"]; ok {
+		t.Fatal("expected the own key to be deleted")
+	}
+	if _, ok := keys["key-2"]; !ok {
+		t.Fatal("expected the key of another user to be kept")
+	}
+}
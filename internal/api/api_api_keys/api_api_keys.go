@@ -0,0 +1,132 @@
+package api_api_keys
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for listing the API keys
+// of the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// ApiKeysList returns the keys of the user.
+	ApiKeysList func(ctx context.Context, userID string) ([]types.ApiKey, error)
+}
+
+// ApiKeysErrorCode categorizes error sources.
+type ApiKeysErrorCode string
+
+const (
+	ApiKeysErrorCodeNone            ApiKeysErrorCode = ""
+	ApiKeysErrorCodeUnauthenticated ApiKeysErrorCode = "unauthenticated"
+	ApiKeysErrorCodeList            ApiKeysErrorCode = "list"
+)
+
+// ApiKeysError represents a structured error in the API keys listing flow.
+type ApiKeysError struct {
+	Code    ApiKeysErrorCode
+	Message string
+	Err     error
+}
+
+func (e *ApiKeysError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiApiKeys is the HTTP-level helper that wires request/response handling
+// to the core ApiKeys business logic using the provided dependencies.
+func ApiApiKeys(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	keys, perr := ApiKeys(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case ApiKeysErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("api keys found", map[string]any{
+		"keys": keys,
+	}))
+}
+
+// ApiApiKeysWithAuth is a convenience wrapper that allows callers to pass a
+// types.AuthSharedInterface (such as authImplementation) instead of manually
+// wiring Dependencies.
+func ApiApiKeysWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+	}
+
+	if config := a.GetApiKeys(); config != nil && config.Store != nil {
+		deps.ApiKeysList = func(ctx context.Context, userID string) ([]types.ApiKey, error) {
+			return config.Store.ListForUser(types.ContextWithUserAuthOptions(ctx, options), userID)
+		}
+	}
+
+	ApiApiKeys(w, r, deps)
+}
+
+// ApiKeys lists the API keys of the authenticated user, without their
+// secret hashes. It does not write HTTP responses.
+func ApiKeys(ctx context.Context, r *http.Request, deps Dependencies) ([]core.ApiKeyInfo, *ApiKeysError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &ApiKeysError{
+			Code:    ApiKeysErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if deps.ApiKeysList == nil {
+		return nil, &ApiKeysError{
+			Code: ApiKeysErrorCodeList,
+			Err:  errors.New("api keys are not configured"),
+		}
+	}
+
+	keys, errList := deps.ApiKeysList(ctx, userID)
+	if errList != nil {
+		return nil, &ApiKeysError{
+			Code: ApiKeysErrorCodeList,
+			Err:  errList,
+		}
+	}
+
+	infos := make([]core.ApiKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, core.ApiKeyInfoFrom(key))
+	}
+
+	return infos, nil
+}
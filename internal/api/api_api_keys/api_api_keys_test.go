@@ -0,0 +1,49 @@
+package api_api_keys
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(userID string) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return userID },
+		ApiKeysList: func(ctx context.Context, userID string) ([]types.ApiKey, error) {
+			return []types.ApiKey{
+				{ID: "0123456789abcdef", SecretHash: "secret-hash", UserID: userID, Name: "CI", Scopes: []string{"read"}, CreatedAt: time.Now()},
+				{ID: "fedcba9876543210", SecretHash: "secret-hash", UserID: userID, Name: "Backups", CreatedAt: time.Now()},
+			}, nil
+		},
+	}
+}
+
+func TestApiApiKeysRequiresUser(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/api-keys", url.Values{})
+	ApiApiKeys(recorder, req, newTestDeps(""))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected unauthenticated, got %s", body)
+	}
+}
+
+func TestApiApiKeysListsKeysWithoutSecrets(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/api-keys", url.Values{})
+	ApiApiKeys(recorder, req, newTestDeps("user-1"))
+
+	body := recorder.Body.String()
+	for _, expected := range []string{`"id":"0123456789abcdef"`, `"name":"Backups"`, `"scopes":["read"]`, `"scopes":[]`} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected %s in the keys, got %s", expected, body)
+		}
+	}
+	if strings.Contains(body, "secret-hash") || strings.Contains(body, "last_used_at") || strings.Contains(body, "expires_at") {
+		t.Fatalf("expected no secret hash nor unset times, got %s", body)
+	}
+}
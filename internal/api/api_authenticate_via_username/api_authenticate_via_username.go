@@ -3,8 +3,10 @@ package api_authenticate_via_username
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/types"
 	"github.com/dracory/req"
 	"github.com/dracory/str"
@@ -16,12 +18,28 @@ type Dependencies struct {
 	Passwordless bool
 
 	PasswordlessUserFindByEmail func(ctx context.Context, email string) (string, error)
+	// PasswordlessUserFindByPhone replaces PasswordlessUserFindByEmail when
+	// the login codes are sent by text message
+	PasswordlessUserFindByPhone func(ctx context.Context, phone string) (string, error)
 	UserFindByUsername          func(ctx context.Context, username, firstName, lastName string) (string, error)
 
 	UserStoreAuthToken func(ctx context.Context, token, userID string) error
 
+	// AuthTokenIssue, when set, mints the auth token instead of generating
+	// and storing an opaque one, e.g. to issue JWT access tokens.
+	AuthTokenIssue func(ctx context.Context, userID string) (string, error)
+
+	// RefreshTokenIssue issues a refresh token returned next to the auth
+	// token in the API response, linked to its session. Optional.
+	RefreshTokenIssue func(ctx context.Context, userID string, token string) (string, error)
+
 	UseCookies    bool
 	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	// RememberMeStart issues a remember-me token linked to the session of the
+	// auth token and sets its cookie. It is only used when the request asks
+	// to be remembered. Optional.
+	RememberMeStart func(w http.ResponseWriter, r *http.Request, userID string, token string) error
 }
 
 // AuthenticateErrorCode categorizes error sources in the authentication flow.
@@ -56,7 +74,8 @@ func (e *AuthenticateError) Error() string {
 
 // AuthenticateResult represents a successful authentication.
 type AuthenticateResult struct {
-	Token string
+	UserID string
+	Token  string
 }
 
 // ApiAuthenticateViaUsername is the HTTP-level helper that wires
@@ -70,13 +89,31 @@ func ApiAuthenticateViaUsername(w http.ResponseWriter, r *http.Request, username
 		return
 	}
 
+	data := map[string]any{
+		"token": result.Token,
+	}
+
+	if deps.RefreshTokenIssue != nil {
+		refreshToken, err := deps.RefreshTokenIssue(r.Context(), result.UserID, result.Token)
+		if err != nil {
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+		data["refresh_token"] = refreshToken
+	}
+
 	if deps.UseCookies && deps.SetAuthCookie != nil {
 		deps.SetAuthCookie(w, r, result.Token)
 	}
 
-	api.Respond(w, r, api.SuccessWithData("login success", map[string]any{
-		"token": result.Token,
-	}))
+	if deps.UseCookies && deps.RememberMeStart != nil && core.RememberMeRequested(r) {
+		if err := deps.RememberMeStart(w, r, result.UserID, result.Token); err != nil {
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	api.Respond(w, r, api.SuccessWithData("login success", data))
 }
 
 // ApiAuthenticateViaUsernameWithAuth is a convenience wrapper that allows
@@ -84,6 +121,12 @@ func ApiAuthenticateViaUsername(w http.ResponseWriter, r *http.Request, username
 // instead of manually wiring Dependencies. It constructs the Dependencies
 // struct using the interface accessors and preserves the existing behaviour.
 func ApiAuthenticateViaUsernameWithAuth(w http.ResponseWriter, r *http.Request, username, firstName, lastName string, a types.AuthSharedInterface) {
+	ApiAuthenticateViaUsername(w, r, username, firstName, lastName, DependenciesWithAuth(r, a))
+}
+
+// DependenciesWithAuth wires the Dependencies of the request from a
+// types.AuthSharedInterface.
+func DependenciesWithAuth(r *http.Request, a types.AuthSharedInterface) Dependencies {
 	deps := Dependencies{
 		Passwordless: a.IsPasswordless(),
 		UseCookies:   a.GetUseCookies(),
@@ -98,6 +141,15 @@ func ApiAuthenticateViaUsernameWithAuth(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
+	if fn := a.GetPasswordlessUserFindByPhone(); fn != nil && a.GetPasswordlessChannel() == types.PasswordlessChannelSms {
+		deps.PasswordlessUserFindByPhone = func(ctx context.Context, phone string) (string, error) {
+			return fn(ctx, phone, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
 	if fn := a.GetFuncUserFindByUsername(); fn != nil {
 		deps.UserFindByUsername = func(ctx context.Context, username, firstName, lastName string) (string, error) {
 			return fn(ctx, username, firstName, lastName, types.UserAuthOptions{
@@ -107,20 +159,47 @@ func ApiAuthenticateViaUsernameWithAuth(w http.ResponseWriter, r *http.Request,
 		}
 	}
 
-	if fn := a.GetFuncUserStoreAuthToken(); fn != nil {
+	if core.SessionStore(a) != nil {
 		deps.UserStoreAuthToken = func(ctx context.Context, token, userID string) error {
-			return fn(ctx, token, userID, types.UserAuthOptions{
+			return core.SessionCreate(ctx, a, token, userID, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
+	if a.GetJWT() != nil {
+		deps.AuthTokenIssue = func(ctx context.Context, userID string) (string, error) {
+			return core.AuthTokenIssue(ctx, a, userID, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
+	if a.GetRememberMe() != nil {
+		deps.RememberMeStart = func(w http.ResponseWriter, r *http.Request, userID string, token string) error {
+			return core.RememberMeStart(w, r, a, userID, token, types.UserAuthOptions{
 				UserIp:    req.GetIP(r),
 				UserAgent: r.UserAgent(),
 			})
 		}
 	}
 
+	if config := a.GetRefreshToken(); config != nil {
+		deps.RefreshTokenIssue = func(ctx context.Context, userID string, token string) (string, error) {
+			return core.RefreshTokenIssue(ctx, config, userID, "", core.AuthTokenSessionID(a, token), types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			}, time.Now())
+		}
+	}
+
 	deps.SetAuthCookie = func(w http.ResponseWriter, r *http.Request, token string) {
 		a.SetAuthCookie(w, r, token)
 	}
 
-	ApiAuthenticateViaUsername(w, r, username, firstName, lastName, deps)
+	return deps
 }
 
 // AuthenticateViaUsername contains the core business logic for authenticating
@@ -130,7 +209,9 @@ func AuthenticateViaUsername(ctx context.Context, username, firstName, lastName
 	var userID string
 	var errUser error
 
-	if deps.Passwordless {
+	if deps.Passwordless && deps.PasswordlessUserFindByPhone != nil {
+		userID, errUser = deps.PasswordlessUserFindByPhone(ctx, username)
+	} else if deps.Passwordless {
 		if deps.PasswordlessUserFindByEmail == nil {
 			return nil, &AuthenticateError{
 				Code:    AuthenticateErrorCodeUserLookup,
@@ -163,6 +244,33 @@ func AuthenticateViaUsername(ctx context.Context, username, firstName, lastName
 		}
 	}
 
+	return AuthenticateUserID(ctx, userID, deps)
+}
+
+// AuthenticateUserID issues and stores an auth token for an already
+// identified user. It is the final step of AuthenticateViaUsername and is
+// shared with flows that resolve the user by other means, such as OpenID
+// Connect sign-in.
+func AuthenticateUserID(ctx context.Context, userID string, deps Dependencies) (*AuthenticateResult, *AuthenticateError) {
+	if userID == "" {
+		return nil, &AuthenticateError{
+			Code:    AuthenticateErrorCodeUserLookup,
+			Message: "Invalid credentials",
+		}
+	}
+
+	if deps.AuthTokenIssue != nil {
+		token, errIssue := deps.AuthTokenIssue(ctx, userID)
+		if errIssue != nil {
+			return nil, &AuthenticateError{
+				Code:    AuthenticateErrorCodeTokenStore,
+				Message: "Failed to process request. Please try again later",
+				Err:     errIssue,
+			}
+		}
+		return &AuthenticateResult{UserID: userID, Token: token}, nil
+	}
+
 	token, errRandomFromGamma := str.RandomFromGamma(32, "BCDFGHJKLMNPQRSTVXYZ")
 	if errRandomFromGamma != nil {
 		return nil, &AuthenticateError{
@@ -187,5 +295,5 @@ func AuthenticateViaUsername(ctx context.Context, username, firstName, lastName
 		}
 	}
 
-	return &AuthenticateResult{Token: token}, nil
+	return &AuthenticateResult{UserID: userID, Token: token}, nil
 }
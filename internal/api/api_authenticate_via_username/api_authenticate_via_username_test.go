@@ -365,6 +365,34 @@ func TestAuthenticateViaUsername_UsernamePassword_Success(t *testing.T) {
 	}
 }
 
+// TestAuthenticateUserID tests token issuance for an already identified user,
+// without any user lookup.
+func TestAuthenticateUserID(t *testing.T) {
+	var storedUserID string
+
+	deps := Dependencies{
+		UserStoreAuthToken: func(ctx context.Context, token, userID string) error {
+			storedUserID = userID
+			return nil
+		},
+	}
+
+	result, aerr := AuthenticateUserID(context.Background(), "user-789", deps)
+	if aerr != nil {
+		t.Fatalf("expected no error, got %+v", aerr)
+	}
+	if result.Token == "" {
+		t.Error("expected non-empty token")
+	}
+	if storedUserID != "user-789" {
+		t.Errorf("expected stored userID %q, got %q", "user-789", storedUserID)
+	}
+
+	if _, aerr := AuthenticateUserID(context.Background(), "", deps); aerr == nil || aerr.Code != AuthenticateErrorCodeUserLookup {
+		t.Fatalf("expected user lookup error for empty user ID, got %+v", aerr)
+	}
+}
+
 // TestApiAuthenticateViaUsername_Passwordless_Success tests the HTTP handler
 // for a successful passwordless authentication.
 func TestApiAuthenticateViaUsername_Passwordless_Success(t *testing.T) {
@@ -3,9 +3,11 @@ package api_login
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/dracory/api"
 	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/emails"
 	"github.com/dracory/auth/types"
 	"github.com/dracory/req"
 )
@@ -15,12 +17,28 @@ import (
 type LoginPasswordlessDeps struct {
 	DisableRateLimit bool
 
+	// Channel selects email or text message codes (default: email)
+	Channel types.PasswordlessChannel
+
 	TemporaryKeySet func(key string, value string, expiresSeconds int) error
 
+	// LoginFlowCreate, when set, binds the code to the email it is sent to.
+	// The code is stored under a new flow instead of under the code itself,
+	// and the flow ID is returned to the client.
+	LoginFlowCreate func(email string, code string, expiresSeconds int) (string, error)
+
 	ExpiresSeconds int
 
 	EmailTemplate func(ctx context.Context, email string, verificationCode string) string
 	EmailSend     func(ctx context.Context, email string, subject string, body string) error
+
+	// LoginLinkCreate, when set, returns a magic link redeeming the code,
+	// which is then sent with EmailTemplateWithLink instead of EmailTemplate.
+	LoginLinkCreate       func(code string, flowID string, expiresSeconds int) (string, error)
+	EmailTemplateWithLink func(ctx context.Context, email string, verificationCode string, link string) string
+
+	SmsTemplate func(ctx context.Context, phone string, verificationCode string) string
+	SmsSend     func(ctx context.Context, phone string, message string) error
 }
 
 // ApiLogin is the HTTP-level handler that combines passwordless and
@@ -39,12 +57,22 @@ func ApiLogin(w http.ResponseWriter, r *http.Request, dependencies Dependencies)
 			case LoginPasswordlessErrorCodeEmailSend:
 				api.Respond(w, r, api.Error("Failed to send email. Please try again later"))
 				return
+			case LoginPasswordlessErrorCodeSmsSend:
+				api.Respond(w, r, api.Error("Failed to send text message. Please try again later"))
+				return
 			default:
 				api.Respond(w, r, api.Error("Internal server error. Please try again later"))
 				return
 			}
 		}
 
+		if result.FlowID != "" {
+			api.Respond(w, r, api.SuccessWithData(result.SuccessMessage, map[string]any{
+				"flow_id": result.FlowID,
+			}))
+			return
+		}
+
 		api.Respond(w, r, api.Success(result.SuccessMessage))
 		return
 	}
@@ -59,19 +87,49 @@ func ApiLogin(w http.ResponseWriter, r *http.Request, dependencies Dependencies)
 	ip := req.GetIP(r)
 	userAgent := r.UserAgent()
 
-	successMessage, token, errMessage := dependencies.LoginWithUsernameAndPassword(r.Context(), email, password, ip, userAgent)
-	if errMessage != "" {
-		api.Respond(w, r, api.Error(errMessage))
+	result := dependencies.LoginWithUsernameAndPassword(r.Context(), email, password, ip, userAgent)
+	if result.ErrorMessage != "" {
+		api.Respond(w, r, api.Error(result.ErrorMessage))
+		return
+	}
+
+	// The password was right, which clears the failed attempts of the rate
+	// limit, even if a two-factor code is still required
+	core.RateLimitSucceeded(r.Context())
+
+	if result.TwoFactorRequired {
+		api.Respond(w, r, api.SuccessWithData(result.SuccessMessage, map[string]any{
+			"two_factor_required": true,
+			"two_factor_token":    result.TwoFactorToken,
+		}))
 		return
 	}
 
+	data := map[string]any{
+		"token": result.Token,
+	}
+
+	if dependencies.RefreshTokenIssue != nil {
+		refreshToken, err := dependencies.RefreshTokenIssue(r.Context(), result.UserID, result.Token)
+		if err != nil {
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+		data["refresh_token"] = refreshToken
+	}
+
 	if dependencies.UseCookies && dependencies.SetAuthCookie != nil {
-		dependencies.SetAuthCookie(w, r, token)
+		dependencies.SetAuthCookie(w, r, result.Token)
+	}
+
+	if dependencies.UseCookies && dependencies.RememberMeStart != nil && core.RememberMeRequested(r) {
+		if err := dependencies.RememberMeStart(w, r, result.UserID, result.Token); err != nil {
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
 	}
 
-	api.Respond(w, r, api.SuccessWithData(successMessage, map[string]any{
-		"token": token,
-	}))
+	api.Respond(w, r, api.SuccessWithData(result.SuccessMessage, data))
 }
 
 // ApiLoginWithAuth is a convenience wrapper that allows callers to pass a
@@ -92,6 +150,7 @@ func ApiLoginWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthShared
 		Passwordless: a.IsPasswordless(),
 		PasswordlessDependencies: LoginPasswordlessDeps{
 			DisableRateLimit: a.GetDisableRateLimit(),
+			Channel:          a.GetPasswordlessChannel(),
 			TemporaryKeySet:  a.GetFuncTemporaryKeySet(),
 			ExpiresSeconds:   0, // let business logic apply default
 			EmailTemplate: func(ctx context.Context, email string, verificationCode string) string {
@@ -111,13 +170,37 @@ func ApiLoginWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthShared
 				}
 				return fn(ctx, email, subject, body)
 			},
+			SmsTemplate: func(ctx context.Context, phone string, verificationCode string) string {
+				fn := a.GetPasswordlessFuncSmsTemplateLoginCode()
+				if fn == nil {
+					return ""
+				}
+				return fn(ctx, phone, verificationCode, types.UserAuthOptions{
+					UserIp:    req.GetIP(r),
+					UserAgent: r.UserAgent(),
+				})
+			},
+			SmsSend: func(ctx context.Context, phone string, message string) error {
+				fn := a.GetPasswordlessFuncSmsSend()
+				if fn == nil {
+					return nil
+				}
+				return fn(ctx, phone, message)
+			},
 		},
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
 			res := core.LoginWithUsernameAndPassword(ctx, passwordAuth, email, password, types.UserAuthOptions{
 				UserIp:    ip,
 				UserAgent: userAgent,
 			})
-			return res.SuccessMessage, res.Token, res.ErrorMessage
+			return LoginResult{
+				SuccessMessage:    res.SuccessMessage,
+				ErrorMessage:      res.ErrorMessage,
+				Token:             res.Token,
+				UserID:            res.UserID,
+				TwoFactorRequired: res.TwoFactorRequired,
+				TwoFactorToken:    res.TwoFactorToken,
+			}
 		},
 		UseCookies: a.GetUseCookies(),
 		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
@@ -125,5 +208,44 @@ func ApiLoginWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthShared
 		},
 	}
 
+	if a.IsLoginCodeBindingEnabled() {
+		deps.PasswordlessDependencies.LoginFlowCreate = func(email string, code string, expiresSeconds int) (string, error) {
+			return core.LoginFlowCreate(a, email, code, expiresSeconds)
+		}
+	}
+
+	if config := a.GetMagicLink(); config != nil {
+		deps.PasswordlessDependencies.LoginLinkCreate = func(code string, flowID string, expiresSeconds int) (string, error) {
+			return core.MagicLinkCreate(w, r, a, code, flowID, expiresSeconds)
+		}
+		deps.PasswordlessDependencies.EmailTemplateWithLink = func(ctx context.Context, email string, verificationCode string, link string) string {
+			if config.FuncEmailTemplate == nil {
+				return emails.EmailLoginLinkTemplate(email, verificationCode, link)
+			}
+			return config.FuncEmailTemplate(ctx, email, verificationCode, link, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
+	if a.GetRememberMe() != nil {
+		deps.RememberMeStart = func(w http.ResponseWriter, r *http.Request, userID string, token string) error {
+			return core.RememberMeStart(w, r, a, userID, token, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
+	if config := a.GetRefreshToken(); config != nil {
+		deps.RefreshTokenIssue = func(ctx context.Context, userID string, token string) (string, error) {
+			return core.RefreshTokenIssue(ctx, config, userID, "", core.AuthTokenSessionID(a, token), types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			}, time.Now())
+		}
+	}
+
 	ApiLogin(w, r, deps)
 }
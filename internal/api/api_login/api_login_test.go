@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/dracory/auth/types"
 )
 
 // helper to build a POST request with form values
@@ -28,11 +30,11 @@ func makePostRequest(t *testing.T, path string, values url.Values) (*httptest.Re
 func TestApiLoginUsernameAndPasswordRequiresEmail(t *testing.T) {
 	deps := Dependencies{
 		Passwordless: false,
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
 			if email == "" {
-				return "", "", "Email is required field"
+				return LoginResult{ErrorMessage: "Email is required field"}
 			}
-			return "", "", ""
+			return LoginResult{}
 		},
 	}
 
@@ -51,11 +53,11 @@ func TestApiLoginUsernameAndPasswordRequiresEmail(t *testing.T) {
 func TestApiLoginUsernameAndPasswordRequiresPassword(t *testing.T) {
 	deps := Dependencies{
 		Passwordless: false,
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
 			if password == "" {
-				return "", "", "Password is required field"
+				return LoginResult{ErrorMessage: "Password is required field"}
 			}
-			return "", "", ""
+			return LoginResult{}
 		},
 	}
 
@@ -74,8 +76,8 @@ func TestApiLoginUsernameAndPasswordRequiresPassword(t *testing.T) {
 func TestApiLoginUsernameAndPasswordUserLoginError(t *testing.T) {
 	deps := Dependencies{
 		Passwordless: false,
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
-			return "", "", "Invalid credentials"
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
+			return LoginResult{ErrorMessage: "Invalid credentials"}
 		},
 	}
 
@@ -95,9 +97,9 @@ func TestApiLoginUsernameAndPasswordUserLoginError(t *testing.T) {
 func TestApiLoginUsernameAndPasswordUserNotFound(t *testing.T) {
 	deps := Dependencies{
 		Passwordless: false,
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
 			// Simulate user not found by returning empty token and error message
-			return "", "", "Invalid credentials"
+			return LoginResult{ErrorMessage: "Invalid credentials"}
 		},
 	}
 
@@ -117,8 +119,8 @@ func TestApiLoginUsernameAndPasswordUserNotFound(t *testing.T) {
 func TestApiLoginUsernameAndPasswordTokenStoreError(t *testing.T) {
 	deps := Dependencies{
 		Passwordless: false,
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
-			return "", "", "Failed to process request. Please try again later"
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
+			return LoginResult{ErrorMessage: "Failed to process request. Please try again later"}
 		},
 	}
 
@@ -139,8 +141,8 @@ func TestApiLoginUsernameAndPasswordSuccess(t *testing.T) {
 	deps := Dependencies{
 		Passwordless: false,
 		UseCookies:   false,
-		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) (string, string, string) {
-			return "login success", "token-123", ""
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
+			return LoginResult{SuccessMessage: "login success", Token: "token-123"}
 		},
 	}
 
@@ -314,3 +316,179 @@ func TestApiLoginPasswordlessSuccess(t *testing.T) {
 		t.Fatalf("expected success message, got %q", body)
 	}
 }
+
+func TestApiLoginUsernameAndPasswordTwoFactorRequired(t *testing.T) {
+	cookieSet := false
+	deps := Dependencies{
+		Passwordless: false,
+		UseCookies:   true,
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			cookieSet = true
+		},
+		LoginWithUsernameAndPassword: func(ctx context.Context, email, password, ip, userAgent string) LoginResult {
+			return LoginResult{
+				SuccessMessage:    "two-factor authentication required",
+				TwoFactorRequired: true,
+				TwoFactorToken:    "challenge-123",
+			}
+		},
+	}
+
+	values := url.Values{
+		"email":    {"test@test.com"},
+		"password": {"1234"},
+	}
+	recorder, req := makePostRequest(t, "/api/login", values)
+	ApiLogin(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"two_factor_required":true`) {
+		t.Fatalf("expected two_factor_required flag, got %q", body)
+	}
+	if !strings.Contains(body, `"two_factor_token":"challenge-123"`) {
+		t.Fatalf("expected two_factor_token in response, got %q", body)
+	}
+	if strings.Contains(body, `"token":`) {
+		t.Fatalf("expected no auth token before second factor, got %q", body)
+	}
+	if cookieSet {
+		t.Fatalf("expected no auth cookie before second factor")
+	}
+}
+
+func TestApiLoginPasswordlessReturnsFlowID(t *testing.T) {
+	var flowEmail, flowCode string
+	deps := Dependencies{
+		Passwordless: true,
+		PasswordlessDependencies: LoginPasswordlessDeps{
+			TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+				t.Fatalf("expected the code not to be stored under itself, got key %q", key)
+				return nil
+			},
+			LoginFlowCreate: func(email string, code string, expiresSeconds int) (string, error) {
+				flowEmail, flowCode = email, code
+				return "flow-1", nil
+			},
+			EmailTemplate: func(ctx context.Context, email string, code string) string {
+				return code
+			},
+			EmailSend: func(ctx context.Context, email string, subject string, body string) error {
+				if body != flowCode {
+					t.Fatalf("expected the bound code to be sent, got %q", body)
+				}
+				return nil
+			},
+		},
+	}
+
+	recorder, req := makePostRequest(t, "/api/login", url.Values{"email": {"test@test.com"}})
+	ApiLogin(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) || !strings.Contains(body, `"flow_id":"flow-1"`) {
+		t.Fatalf("expected the flow ID in the response, got %q", body)
+	}
+	if flowEmail != "test@test.com" || flowCode == "" {
+		t.Fatalf("expected the code to be bound to the email, got %q %q", flowEmail, flowCode)
+	}
+}
+
+func TestApiLoginPasswordlessSmsSendsBoundNumericCode(t *testing.T) {
+	var flowPhone, flowCode, sentPhone, sentMessage string
+	deps := Dependencies{
+		Passwordless: true,
+		PasswordlessDependencies: LoginPasswordlessDeps{
+			Channel: types.PasswordlessChannelSms,
+			TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+				t.Fatalf("expected the code not to be stored under itself, got key %q", key)
+				return nil
+			},
+			LoginFlowCreate: func(recipient string, code string, expiresSeconds int) (string, error) {
+				flowPhone, flowCode = recipient, code
+				return "flow-1", nil
+			},
+			SmsTemplate: func(ctx context.Context, phone string, code string) string {
+				return "code " + code
+			},
+			SmsSend: func(ctx context.Context, phone string, message string) error {
+				sentPhone, sentMessage = phone, message
+				return nil
+			},
+		},
+	}
+
+	recorder, req := makePostRequest(t, "/api/login", url.Values{"phone": {"+44 (7700) 900-123"}})
+	ApiLogin(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) || !strings.Contains(body, `"flow_id":"flow-1"`) {
+		t.Fatalf("expected the flow ID in the response, got %q", body)
+	}
+	if flowPhone != "+447700900123" || sentPhone != "+447700900123" {
+		t.Fatalf("expected the normalised phone number, got %q and %q", flowPhone, sentPhone)
+	}
+	if len(flowCode) != 6 || strings.Trim(flowCode, "0123456789") != "" {
+		t.Fatalf("expected a 6-digit code, got %q", flowCode)
+	}
+	if sentMessage != "code "+flowCode {
+		t.Fatalf("expected the templated code to be sent, got %q", sentMessage)
+	}
+}
+
+func TestApiLoginPasswordlessSmsInvalidPhone(t *testing.T) {
+	tests := []struct {
+		name  string
+		phone string
+		want  string
+	}{
+		{"missing", "", "Phone number is required field"},
+		{"no_country_code", "07700 900123", "This is not a valid phone number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := Dependencies{
+				Passwordless: true,
+				PasswordlessDependencies: LoginPasswordlessDeps{
+					Channel: types.PasswordlessChannelSms,
+					SmsSend: func(ctx context.Context, phone string, message string) error {
+						t.Fatalf("expected no text message to be sent")
+						return nil
+					},
+				},
+			}
+
+			recorder, req := makePostRequest(t, "/api/login", url.Values{"phone": {tt.phone}})
+			ApiLogin(recorder, req, deps)
+
+			if body := recorder.Body.String(); !strings.Contains(body, tt.want) {
+				t.Fatalf("expected %q in the response, got %q", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestApiLoginPasswordlessSmsSendError(t *testing.T) {
+	deps := Dependencies{
+		Passwordless: true,
+		PasswordlessDependencies: LoginPasswordlessDeps{
+			Channel:         types.PasswordlessChannelSms,
+			TemporaryKeySet: func(key string, value string, expiresSeconds int) error { return nil },
+			LoginFlowCreate: func(recipient string, code string, expiresSeconds int) (string, error) {
+				return "flow-1", nil
+			},
+			SmsTemplate: func(ctx context.Context, phone string, code string) string { return code },
+			SmsSend: func(ctx context.Context, phone string, message string) error {
+				return errors.New("gateway down")
+			},
+		},
+	}
+
+	recorder, req := makePostRequest(t, "/api/login", url.Values{"phone": {"+447700900123"}})
+	ApiLogin(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, "Failed to send text message") || strings.Contains(body, "gateway down") {
+		t.Fatalf("expected a generic text message error, got %q", body)
+	}
+}
@@ -6,4 +6,5 @@ const (
 	LoginPasswordlessErrorCodeCodeGeneration LoginPasswordlessErrorCode = "code_generation"
 	LoginPasswordlessErrorCodeTokenStore     LoginPasswordlessErrorCode = "token_store"
 	LoginPasswordlessErrorCodeEmailSend      LoginPasswordlessErrorCode = "email_send"
+	LoginPasswordlessErrorCodeSmsSend        LoginPasswordlessErrorCode = "sms_send"
 )
@@ -16,13 +16,13 @@ type Dependencies struct {
 	// passwordless login flow.
 	PasswordlessDependencies LoginPasswordlessDeps
 
-	// LoginWithUsernameAndPassword performs the username+password login flow
-	// and returns success message, token and error message. If error message is
-	// non-empty, the operation is considered failed.
+	// LoginWithUsernameAndPassword performs the username+password login flow.
+	// If the result's ErrorMessage is non-empty, the operation is considered
+	// failed.
 	LoginWithUsernameAndPassword func(
 		ctx context.Context,
 		email, password, ip, userAgent string,
-	) (successMessage, token, errorMessage string)
+	) LoginResult
 
 	// UseCookies controls whether the auth token should be written as a cookie
 	// when the username+password flow succeeds.
@@ -31,4 +31,27 @@ type Dependencies struct {
 	// SetAuthCookie writes the auth cookie. It is only used when UseCookies is
 	// true and must be non-nil in that case.
 	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	// RefreshTokenIssue issues a refresh token returned next to the auth
+	// token of the username+password flow, linked to its session. Optional.
+	RefreshTokenIssue func(ctx context.Context, userID string, token string) (string, error)
+
+	// RememberMeStart issues a remember-me token linked to the session of the
+	// auth token and sets its cookie. It is only used when the request asks
+	// to be remembered. Optional.
+	RememberMeStart func(w http.ResponseWriter, r *http.Request, userID string, token string) error
+}
+
+// LoginResult is the outcome of the username+password login flow.
+type LoginResult struct {
+	SuccessMessage string
+	ErrorMessage   string
+	Token          string
+	UserID         string
+
+	// TwoFactorRequired signals that the password was accepted but a TOTP
+	// code is still required. No auth token is issued in that case; the
+	// client must send TwoFactorToken to the 2FA verification endpoint.
+	TwoFactorRequired bool
+	TwoFactorToken    string
 }
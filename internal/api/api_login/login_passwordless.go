@@ -7,6 +7,7 @@ import (
 
 	"github.com/dracory/req"
 
+	"github.com/dracory/auth/types"
 	"github.com/dracory/auth/utils"
 )
 
@@ -38,6 +39,9 @@ func (e *LoginPasswordlessError) Error() string {
 // LoginPasswordlessResult represents a successful passwordless login operation.
 type LoginPasswordlessResult struct {
 	SuccessMessage string
+
+	// FlowID identifies the bound code, when login codes are bound
+	FlowID string
 }
 
 // LoginPasswordless contains the core business logic of the passwordless login API.
@@ -45,6 +49,10 @@ type LoginPasswordlessResult struct {
 // the email contents. It does *not* perform logging; callers are responsible for
 // logging and mapping structured errors to their own error types.
 func loginPasswordless(ctx context.Context, r *http.Request, deps LoginPasswordlessDeps) (*LoginPasswordlessResult, *LoginPasswordlessError) {
+	if deps.Channel == types.PasswordlessChannelSms {
+		return loginPasswordlessSms(ctx, r, deps)
+	}
+
 	email := req.GetStringTrimmed(r, "email")
 
 	if email == "" {
@@ -69,45 +77,143 @@ func loginPasswordless(ctx context.Context, r *http.Request, deps LoginPasswordl
 		}
 	}
 
-	if deps.TemporaryKeySet == nil {
+	flowID, expires, perr := loginPasswordlessStoreCode(email, verificationCode, deps)
+	if perr != nil {
+		return nil, perr
+	}
+
+	if deps.EmailTemplate == nil || deps.EmailSend == nil {
 		return nil, &LoginPasswordlessError{
-			Code: LoginPasswordlessErrorCodeTokenStore,
-			Err:  errors.New("temporary key store is not configured"),
+			Code: LoginPasswordlessErrorCodeEmailSend,
+			Err:  errors.New("email template or sender is not configured"),
 		}
 	}
 
-	expires := deps.ExpiresSeconds
-	if expires <= 0 {
-		// Fallback to one hour if the caller forgets to configure this; this keeps
-		// the business logic package independent from the auth package constants
-		// while still providing a safe default.
-		expires = 3600
+	var emailContent string
+	if deps.LoginLinkCreate != nil && deps.EmailTemplateWithLink != nil {
+		link, errLink := deps.LoginLinkCreate(verificationCode, flowID, expires)
+		if errLink != nil {
+			return nil, &LoginPasswordlessError{
+				Code: LoginPasswordlessErrorCodeTokenStore,
+				Err:  errLink,
+			}
+		}
+		emailContent = deps.EmailTemplateWithLink(ctx, email, verificationCode, link)
+	} else {
+		emailContent = deps.EmailTemplate(ctx, email, verificationCode)
+	}
+
+	if errEmail := deps.EmailSend(ctx, email, "Login Code", emailContent); errEmail != nil {
+		return nil, &LoginPasswordlessError{
+			Code: LoginPasswordlessErrorCodeEmailSend,
+			Err:  errEmail,
+		}
+	}
+
+	return &LoginPasswordlessResult{
+		SuccessMessage: "Login code was sent successfully",
+		FlowID:         flowID,
+	}, nil
+}
+
+// loginPasswordlessSms sends a short numeric code by text message to the
+// phone number, normalised to E.164. The code is always bound to a new login
+// flow, as a 6-digit code looked up by itself could be guessed for anyone.
+func loginPasswordlessSms(ctx context.Context, r *http.Request, deps LoginPasswordlessDeps) (*LoginPasswordlessResult, *LoginPasswordlessError) {
+	phone := req.GetStringTrimmed(r, "phone")
+
+	if phone == "" {
+		return nil, &LoginPasswordlessError{
+			Code:    LoginPasswordlessErrorCodeValidation,
+			Message: "Phone number is required field",
+		}
+	}
+
+	if msg := utils.ValidatePhoneFormat(phone); msg != "" {
+		return nil, &LoginPasswordlessError{
+			Code:    LoginPasswordlessErrorCodeValidation,
+			Message: msg,
+		}
 	}
 
-	if errTemp := deps.TemporaryKeySet(verificationCode, email, expires); errTemp != nil {
+	phone = utils.NormalizePhone(phone)
+
+	if deps.LoginFlowCreate == nil {
 		return nil, &LoginPasswordlessError{
 			Code: LoginPasswordlessErrorCodeTokenStore,
-			Err:  errTemp,
+			Err:  errors.New("text message codes require login flows"),
 		}
 	}
 
-	if deps.EmailTemplate == nil || deps.EmailSend == nil {
+	verificationCode, err := utils.GeneratePhoneVerificationCode(deps.DisableRateLimit)
+	if err != nil {
 		return nil, &LoginPasswordlessError{
-			Code: LoginPasswordlessErrorCodeEmailSend,
-			Err:  errors.New("email template or sender is not configured"),
+			Code: LoginPasswordlessErrorCodeCodeGeneration,
+			Err:  err,
 		}
 	}
 
-	emailContent := deps.EmailTemplate(ctx, email, verificationCode)
+	flowID, _, perr := loginPasswordlessStoreCode(phone, verificationCode, deps)
+	if perr != nil {
+		return nil, perr
+	}
 
-	if errEmail := deps.EmailSend(ctx, email, "Login Code", emailContent); errEmail != nil {
+	if deps.SmsTemplate == nil || deps.SmsSend == nil {
 		return nil, &LoginPasswordlessError{
-			Code: LoginPasswordlessErrorCodeEmailSend,
-			Err:  errEmail,
+			Code: LoginPasswordlessErrorCodeSmsSend,
+			Err:  errors.New("text message template or sender is not configured"),
+		}
+	}
+
+	if errSms := deps.SmsSend(ctx, phone, deps.SmsTemplate(ctx, phone, verificationCode)); errSms != nil {
+		return nil, &LoginPasswordlessError{
+			Code: LoginPasswordlessErrorCodeSmsSend,
+			Err:  errSms,
 		}
 	}
 
 	return &LoginPasswordlessResult{
 		SuccessMessage: "Login code was sent successfully",
+		FlowID:         flowID,
 	}, nil
 }
+
+// loginPasswordlessStoreCode stores the code sent to the recipient, under a
+// new login flow when codes are bound, and returns the flow ID and the
+// expiry of the code.
+func loginPasswordlessStoreCode(recipient string, verificationCode string, deps LoginPasswordlessDeps) (string, int, *LoginPasswordlessError) {
+	if deps.TemporaryKeySet == nil {
+		return "", 0, &LoginPasswordlessError{
+			Code: LoginPasswordlessErrorCodeTokenStore,
+			Err:  errors.New("temporary key store is not configured"),
+		}
+	}
+
+	expires := deps.ExpiresSeconds
+	if expires <= 0 {
+		// Fallback to one hour if the caller forgets to configure this; this keeps
+		// the business logic package independent from the auth package constants
+		// while still providing a safe default.
+		expires = 3600
+	}
+
+	if deps.LoginFlowCreate != nil {
+		flowID, errFlow := deps.LoginFlowCreate(recipient, verificationCode, expires)
+		if errFlow != nil {
+			return "", 0, &LoginPasswordlessError{
+				Code: LoginPasswordlessErrorCodeTokenStore,
+				Err:  errFlow,
+			}
+		}
+		return flowID, expires, nil
+	}
+
+	if errTemp := deps.TemporaryKeySet(verificationCode, recipient, expires); errTemp != nil {
+		return "", 0, &LoginPasswordlessError{
+			Code: LoginPasswordlessErrorCodeTokenStore,
+			Err:  errTemp,
+		}
+	}
+
+	return "", expires, nil
+}
@@ -0,0 +1,456 @@
+package api_login_2fa_verify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+	"github.com/dracory/str"
+)
+
+// Dependencies defines the dependencies required for completing a login
+// with a TOTP code after the password has been verified.
+type Dependencies struct {
+	TemporaryKeyGet func(key string) (string, error)
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// TemporaryKeyDelete consumes the challenge once the login completes.
+	// Optional: without it the challenge is overwritten with an empty value.
+	TemporaryKeyDelete func(key string) error
+
+	// VerificationAttemptFailed records a wrong code against the challenge,
+	// reporting when the challenge has been invalidated because of too many.
+	// Optional.
+	VerificationAttemptFailed func(key string) (exhausted bool, err error)
+
+	// AccountLocked, AccountFailed and AccountReset count wrong codes
+	// against the user across challenges, refusing codes while the user is
+	// locked. Optional.
+	AccountLocked func(userID string) bool
+	AccountFailed func(ctx context.Context, userID string) error
+	AccountReset  func(userID string) error
+
+	// UserTotpSecretFind returns the base32 TOTP secret of the user.
+	UserTotpSecretFind func(ctx context.Context, userID string) (string, error)
+
+	// TotpStepAccept records the time step of a valid TOTP code of the user,
+	// refusing a step already used, so a code cannot be replayed within its
+	// window. Optional.
+	TotpStepAccept func(userID string, step uint64) (bool, error)
+
+	// UserRecoveryCodeConsume marks the recovery code hash as used, reporting
+	// whether it was one of the user's unused codes. Optional.
+	UserRecoveryCodeConsume func(ctx context.Context, userID string, codeHash string) (bool, error)
+
+	// AuthTokenIssue issues and stores the auth token for the user once the
+	// second factor has been verified. The method is the first factor of the
+	// challenge.
+	AuthTokenIssue func(ctx context.Context, userID string, method types.AuthMethod) (string, error)
+
+	// RefreshTokenIssue issues a refresh token returned next to the auth
+	// token, linked to its session. Optional.
+	RefreshTokenIssue func(ctx context.Context, userID string, token string) (string, error)
+
+	UseCookies    bool
+	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	// RememberMeStart issues a remember-me token linked to the session of the
+	// auth token and sets its cookie. It is only used when the request asks
+	// to be remembered. Optional.
+	RememberMeStart func(w http.ResponseWriter, r *http.Request, userID string, token string) error
+
+	// Now returns the current time. Defaults to time.Now when nil.
+	Now func() time.Time
+}
+
+// Login2faVerifyErrorCode categorizes error sources.
+type Login2faVerifyErrorCode string
+
+const (
+	Login2faVerifyErrorCodeNone             Login2faVerifyErrorCode = ""
+	Login2faVerifyErrorCodeValidation       Login2faVerifyErrorCode = "validation"
+	Login2faVerifyErrorCodeChallengeExpired Login2faVerifyErrorCode = "challenge_expired"
+	Login2faVerifyErrorCodeSecretLookup     Login2faVerifyErrorCode = "secret_lookup"
+	Login2faVerifyErrorCodeInvalidCode      Login2faVerifyErrorCode = "invalid_code"
+	Login2faVerifyErrorCodeRecoveryCode     Login2faVerifyErrorCode = "recovery_code"
+	Login2faVerifyErrorCodeTokenStore       Login2faVerifyErrorCode = "token_store"
+	Login2faVerifyErrorCodeLocked           Login2faVerifyErrorCode = "locked"
+)
+
+// Login2faVerifyError represents a structured error in the two-factor
+// verification flow.
+type Login2faVerifyError struct {
+	Code    Login2faVerifyErrorCode
+	Message string
+	Err     error
+}
+
+func (e *Login2faVerifyError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// Login2faVerifyResult represents a successful verification.
+type Login2faVerifyResult struct {
+	UserID string
+	Token  string
+}
+
+// ApiLogin2faVerify is the HTTP-level helper that wires request/response
+// handling to the core Login2faVerify business logic using the provided
+// dependencies.
+func ApiLogin2faVerify(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, perr := Login2faVerify(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case Login2faVerifyErrorCodeValidation,
+			Login2faVerifyErrorCodeChallengeExpired,
+			Login2faVerifyErrorCodeInvalidCode,
+			Login2faVerifyErrorCodeLocked:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+
+	data := map[string]any{
+		"token": result.Token,
+	}
+
+	if deps.RefreshTokenIssue != nil {
+		refreshToken, err := deps.RefreshTokenIssue(r.Context(), result.UserID, result.Token)
+		if err != nil {
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+		data["refresh_token"] = refreshToken
+	}
+
+	if deps.UseCookies && deps.SetAuthCookie != nil {
+		deps.SetAuthCookie(w, r, result.Token)
+	}
+
+	if deps.UseCookies && deps.RememberMeStart != nil && core.RememberMeRequested(r) {
+		if err := deps.RememberMeStart(w, r, result.UserID, result.Token); err != nil {
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	api.Respond(w, r, api.SuccessWithData("login success", data))
+}
+
+// ApiLogin2faVerifyWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiLogin2faVerifyWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		TemporaryKeyGet: a.GetFuncTemporaryKeyGet(),
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
+		VerificationAttemptFailed: func(key string) (bool, error) {
+			return core.VerificationAttemptFailed(a, key)
+		},
+		TotpStepAccept: func(userID string, step uint64) (bool, error) {
+			return core.TotpStepAccept(a, userID, step)
+		},
+		UseCookies: a.GetUseCookies(),
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			a.SetAuthCookie(w, r, token)
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string, method types.AuthMethod) (string, error) {
+			ctx = core.ContextWithLogin(ctx, method, types.MFALevelMultiFactor, time.Now())
+			return core.AuthTokenIssue(ctx, a, userID, options)
+		},
+	}
+
+	if fn := a.GetFuncUserTotpSecretFind(); fn != nil {
+		deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+			return fn(ctx, userID, options)
+		}
+	}
+
+	if fn := a.GetFuncUserRecoveryCodeConsume(); fn != nil {
+		deps.UserRecoveryCodeConsume = func(ctx context.Context, userID string, codeHash string) (bool, error) {
+			return fn(ctx, userID, codeHash, options)
+		}
+	}
+
+	if a.GetAccountLockout() != nil {
+		deps.AccountLocked = func(userID string) bool {
+			return !core.TwoFactorLockedUntil(a, userID, time.Now()).IsZero()
+		}
+		deps.AccountFailed = func(ctx context.Context, userID string) error {
+			return core.TwoFactorFailureRecord(ctx, a, userID, options, time.Now())
+		}
+		deps.AccountReset = func(userID string) error {
+			return core.TwoFactorFailuresReset(a, userID)
+		}
+	}
+
+	if a.GetRememberMe() != nil {
+		deps.RememberMeStart = func(w http.ResponseWriter, r *http.Request, userID string, token string) error {
+			return core.RememberMeStart(w, r, a, userID, token, options)
+		}
+	}
+
+	if config := a.GetRefreshToken(); config != nil {
+		deps.RefreshTokenIssue = func(ctx context.Context, userID string, token string) (string, error) {
+			return core.RefreshTokenIssue(ctx, config, userID, "", core.AuthTokenSessionID(a, token), options, time.Now())
+		}
+	}
+
+	ApiLogin2faVerify(w, r, deps)
+}
+
+// Login2faVerify encapsulates the core business logic for completing a
+// login with a TOTP code, or with a single-use recovery code in its place.
+// On success the pending challenge is invalidated and an auth token is
+// issued. It does not write HTTP responses.
+func Login2faVerify(ctx context.Context, r *http.Request, deps Dependencies) (*Login2faVerifyResult, *Login2faVerifyError) {
+	challengeToken := req.GetStringTrimmed(r, "two_factor_token")
+	code := req.GetStringTrimmed(r, "code")
+	recoveryCode := req.GetStringTrimmed(r, "recovery_code")
+
+	if challengeToken == "" {
+		return nil, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeValidation,
+			Message: "Two-factor token is required field",
+		}
+	}
+
+	if code == "" && recoveryCode == "" {
+		return nil, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeValidation,
+			Message: "Code is required field",
+		}
+	}
+
+	if recoveryCode == "" && (len(code) != utils.TotpDigits || !str.ContainsOnly(code, "0123456789")) {
+		return nil, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeValidation,
+			Message: "Code must be 6 digits",
+		}
+	}
+
+	if deps.TemporaryKeyGet == nil {
+		return nil, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeChallengeExpired,
+			Message: "Two-factor session has expired. Please log in again",
+			Err:     errors.New("temporary key store is not configured"),
+		}
+	}
+
+	challengeKey := core.TwoFactorChallengeKeyPrefix + challengeToken
+
+	value, errChallenge := deps.TemporaryKeyGet(challengeKey)
+	userID, method := core.TwoFactorChallengeUser(value)
+	if errChallenge != nil || userID == "" {
+		return nil, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeChallengeExpired,
+			Message: "Two-factor session has expired. Please log in again",
+			Err:     errChallenge,
+		}
+	}
+
+	if deps.AccountLocked != nil && deps.AccountLocked(userID) {
+		return nil, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeLocked,
+			Message: core.AccountLockedMessage,
+		}
+	}
+
+	if recoveryCode != "" {
+		if errRecovery := recoveryCodeRedeem(ctx, deps, userID, recoveryCode); errRecovery != nil {
+			if errRecovery.Code == Login2faVerifyErrorCodeInvalidCode {
+				return nil, login2faAttemptFailed(ctx, deps, challengeKey, userID, errRecovery)
+			}
+			return nil, errRecovery
+		}
+		return login2faComplete(ctx, deps, challengeKey, userID, method)
+	}
+
+	if deps.UserTotpSecretFind == nil {
+		return nil, &Login2faVerifyError{
+			Code: Login2faVerifyErrorCodeSecretLookup,
+			Err:  errors.New("TOTP secret lookup is not configured"),
+		}
+	}
+
+	secret, errSecret := deps.UserTotpSecretFind(ctx, userID)
+	if errSecret != nil {
+		return nil, &Login2faVerifyError{
+			Code: Login2faVerifyErrorCodeSecretLookup,
+			Err:  errSecret,
+		}
+	}
+
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+
+	step, valid := utils.TotpCodeStep(secret, code, now())
+	if secret == "" || !valid {
+		return nil, login2faAttemptFailed(ctx, deps, challengeKey, userID, &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeInvalidCode,
+			Message: "Invalid two-factor code",
+		})
+	}
+
+	if deps.TotpStepAccept != nil {
+		accepted, errStep := deps.TotpStepAccept(userID, step)
+		if errStep != nil {
+			return nil, &Login2faVerifyError{
+				Code: Login2faVerifyErrorCodeTokenStore,
+				Err:  errStep,
+			}
+		}
+
+		if !accepted {
+			return nil, login2faAttemptFailed(ctx, deps, challengeKey, userID, &Login2faVerifyError{
+				Code:    Login2faVerifyErrorCodeInvalidCode,
+				Message: "This code has already been used. Please wait for the next one",
+			})
+		}
+	}
+
+	return login2faComplete(ctx, deps, challengeKey, userID, method)
+}
+
+// recoveryCodeRedeem consumes the recovery code of the user, so it cannot be
+// used again.
+func recoveryCodeRedeem(ctx context.Context, deps Dependencies, userID string, recoveryCode string) *Login2faVerifyError {
+	if deps.UserRecoveryCodeConsume == nil {
+		return &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeValidation,
+			Message: "Recovery codes are not supported",
+		}
+	}
+
+	consumed, errConsume := deps.UserRecoveryCodeConsume(ctx, userID, utils.HashRecoveryCode(recoveryCode))
+	if errConsume != nil {
+		return &Login2faVerifyError{
+			Code: Login2faVerifyErrorCodeRecoveryCode,
+			Err:  errConsume,
+		}
+	}
+
+	if !consumed {
+		return &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeInvalidCode,
+			Message: "Invalid recovery code",
+		}
+	}
+
+	return nil
+}
+
+// login2faAttemptFailed counts the wrong code against the challenge, so a
+// six digit code cannot be brute forced within one challenge. Once the
+// challenge is invalidated the user has to log in again. With account
+// lockout, it is counted against the user as well, so it cannot be brute
+// forced across challenges either.
+func login2faAttemptFailed(ctx context.Context, deps Dependencies, challengeKey string, userID string, perr *Login2faVerifyError) *Login2faVerifyError {
+	if deps.AccountFailed != nil {
+		if err := deps.AccountFailed(ctx, userID); err != nil {
+			return &Login2faVerifyError{
+				Code: Login2faVerifyErrorCodeTokenStore,
+				Err:  err,
+			}
+		}
+	}
+
+	if deps.VerificationAttemptFailed == nil {
+		return perr
+	}
+
+	exhausted, err := deps.VerificationAttemptFailed(challengeKey)
+	if err != nil {
+		return &Login2faVerifyError{
+			Code: Login2faVerifyErrorCodeTokenStore,
+			Err:  err,
+		}
+	}
+
+	if exhausted {
+		return &Login2faVerifyError{
+			Code:    Login2faVerifyErrorCodeChallengeExpired,
+			Message: "Too many invalid codes. Please log in again",
+		}
+	}
+
+	return perr
+}
+
+// login2faComplete invalidates the challenge and issues the auth token once
+// the second factor has been verified.
+func login2faComplete(ctx context.Context, deps Dependencies, challengeKey string, userID string, method types.AuthMethod) (*Login2faVerifyResult, *Login2faVerifyError) {
+	// Invalidate the challenge so it cannot be replayed with a later code.
+	if deps.TemporaryKeyDelete != nil {
+		if errConsume := deps.TemporaryKeyDelete(challengeKey); errConsume != nil {
+			return nil, &Login2faVerifyError{
+				Code: Login2faVerifyErrorCodeTokenStore,
+				Err:  errConsume,
+			}
+		}
+	} else if deps.TemporaryKeySet != nil {
+		if errConsume := deps.TemporaryKeySet(challengeKey, "", 1); errConsume != nil {
+			return nil, &Login2faVerifyError{
+				Code: Login2faVerifyErrorCodeTokenStore,
+				Err:  errConsume,
+			}
+		}
+	}
+
+	if deps.AccountReset != nil {
+		if errReset := deps.AccountReset(userID); errReset != nil {
+			return nil, &Login2faVerifyError{
+				Code: Login2faVerifyErrorCodeTokenStore,
+				Err:  errReset,
+			}
+		}
+	}
+
+	if deps.AuthTokenIssue == nil {
+		return nil, &Login2faVerifyError{
+			Code: Login2faVerifyErrorCodeTokenStore,
+			Err:  errors.New("auth token issuer is not configured"),
+		}
+	}
+
+	token, errToken := deps.AuthTokenIssue(ctx, userID, method)
+	if errToken != nil {
+		return nil, &Login2faVerifyError{
+			Code: Login2faVerifyErrorCodeTokenStore,
+			Err:  errToken,
+		}
+	}
+
+	return &Login2faVerifyResult{UserID: userID, Token: token}, nil
+}
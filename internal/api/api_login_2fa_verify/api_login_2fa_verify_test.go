@@ -0,0 +1,363 @@
+package api_login_2fa_verify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+)
+
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newTestDeps(store map[string]string) Dependencies {
+	return Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			v, ok := store[key]
+			if !ok {
+				return "", errors.New("not found")
+			}
+			return v, nil
+		},
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		},
+		UserTotpSecretFind: func(ctx context.Context, userID string) (string, error) {
+			if userID == "user-1" {
+				return testSecret, nil
+			}
+			return "", nil
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string, method types.AuthMethod) (string, error) {
+			return "token-for-" + userID, nil
+		},
+	}
+}
+
+func TestApiLogin2faVerifyRequiresToken(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"code": {"123456"},
+	})
+	ApiLogin2faVerify(recorder, req, newTestDeps(map[string]string{}))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Two-factor token is required field"`) {
+		t.Fatalf("expected token required message, got %q", body)
+	}
+}
+
+func TestApiLogin2faVerifyRejectsMalformedCode(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {"12ab56"},
+	})
+	ApiLogin2faVerify(recorder, req, newTestDeps(map[string]string{}))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Code must be 6 digits"`) {
+		t.Fatalf("expected malformed code message, got %q", body)
+	}
+}
+
+func TestApiLogin2faVerifyUnknownChallenge(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"unknown"},
+		"code":             {"123456"},
+	})
+	ApiLogin2faVerify(recorder, req, newTestDeps(map[string]string{}))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Two-factor session has expired. Please log in again"`) {
+		t.Fatalf("expected expired challenge message, got %q", body)
+	}
+}
+
+func TestApiLogin2faVerifyInvalidCode(t *testing.T) {
+	store := map[string]string{core.TwoFactorChallengeKeyPrefix + "challenge": "user-1"}
+	now := time.Unix(1700000000, 0)
+
+	deps := newTestDeps(store)
+	deps.Now = func() time.Time { return now }
+
+	wrong, _ := utils.TotpCode(testSecret, now.Add(-10*utils.TotpPeriod))
+
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {wrong},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Invalid two-factor code"`) {
+		t.Fatalf("expected invalid code message, got %q", body)
+	}
+	if store[core.TwoFactorChallengeKeyPrefix+"challenge"] != "user-1" {
+		t.Fatalf("expected challenge to survive a wrong code")
+	}
+}
+
+func TestApiLogin2faVerifySuccessConsumesChallenge(t *testing.T) {
+	store := map[string]string{core.TwoFactorChallengeKeyPrefix + "challenge": "user-1"}
+	now := time.Unix(1700000000, 0)
+
+	cookieToken := ""
+	deps := newTestDeps(store)
+	deps.Now = func() time.Time { return now }
+	deps.UseCookies = true
+	deps.SetAuthCookie = func(w http.ResponseWriter, r *http.Request, token string) {
+		cookieToken = token
+	}
+
+	code, _ := utils.TotpCode(testSecret, now)
+
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {code},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("expected success, got %q", body)
+	}
+	if !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected issued token in response, got %q", body)
+	}
+	if cookieToken != "token-for-user-1" {
+		t.Fatalf("expected auth cookie to be set, got %q", cookieToken)
+	}
+
+	// Replaying the same challenge must fail.
+	recorder, req = testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {code},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body = recorder.Body.String()
+	if !strings.Contains(body, `"message":"Two-factor session has expired. Please log in again"`) {
+		t.Fatalf("expected replay to be rejected, got %q", body)
+	}
+}
+
+func TestApiLogin2faVerifyRejectsReusedCode(t *testing.T) {
+	store := map[string]string{
+		core.TwoFactorChallengeKeyPrefix + "challenge":  "user-1",
+		core.TwoFactorChallengeKeyPrefix + "challenge2": "user-1",
+	}
+	now := time.Unix(1700000000, 0)
+
+	lastSteps := map[string]uint64{}
+	deps := newTestDeps(store)
+	deps.Now = func() time.Time { return now }
+	deps.TotpStepAccept = func(userID string, step uint64) (bool, error) {
+		if last, ok := lastSteps[userID]; ok && step <= last {
+			return false, nil
+		}
+		lastSteps[userID] = step
+		return true, nil
+	}
+
+	code, _ := utils.TotpCode(testSecret, now)
+
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {code},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("expected success, got %q", body)
+	}
+
+	// The same code seen by an attacker must not complete another challenge
+	recorder, req = testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge2"},
+		"code":             {code},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"This code has already been used. Please wait for the next one"`) {
+		t.Fatalf("expected reused code to be rejected, got %q", body)
+	}
+	if store[core.TwoFactorChallengeKeyPrefix+"challenge2"] != "user-1" {
+		t.Fatalf("expected challenge to survive a reused code")
+	}
+}
+
+func TestApiLogin2faVerifyKeepsTheFirstFactorOfTheChallenge(t *testing.T) {
+	store := map[string]string{core.TwoFactorChallengeKeyPrefix + "challenge": "oidc:user-1"}
+	now := time.Unix(1700000000, 0)
+
+	issuedMethod := types.AuthMethodUnknown
+	deps := newTestDeps(store)
+	deps.Now = func() time.Time { return now }
+	deps.AuthTokenIssue = func(ctx context.Context, userID string, method types.AuthMethod) (string, error) {
+		issuedMethod = method
+		return "token-for-" + userID, nil
+	}
+
+	code, _ := utils.TotpCode(testSecret, now)
+
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {code},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected issued token in response, got %q", body)
+	}
+	if issuedMethod != types.AuthMethodOIDC {
+		t.Fatalf("expected the token to be issued for an oidc login, got %q", issuedMethod)
+	}
+}
+
+func TestApiLogin2faVerifyRecoveryCode(t *testing.T) {
+	store := map[string]string{core.TwoFactorChallengeKeyPrefix + "challenge": "user-1"}
+	unused := map[string]bool{utils.HashRecoveryCode("BCDF-GHJK-LMNP-QRST"): true}
+
+	deps := newTestDeps(store)
+	deps.UserRecoveryCodeConsume = func(ctx context.Context, userID string, codeHash string) (bool, error) {
+		if !unused[codeHash] {
+			return false, nil
+		}
+		delete(unused, codeHash)
+		return true, nil
+	}
+
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"recovery_code":    {"bcdf-ghjk-lmnp-qrst"},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected issued token in response, got %q", body)
+	}
+	if len(unused) != 0 {
+		t.Fatalf("expected recovery code to be consumed")
+	}
+
+	// The same recovery code cannot be used twice.
+	store[core.TwoFactorChallengeKeyPrefix+"challenge2"] = "user-1"
+	recorder, req = testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge2"},
+		"recovery_code":    {"BCDF-GHJK-LMNP-QRST"},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+
+	body = recorder.Body.String()
+	if !strings.Contains(body, `"message":"Invalid recovery code"`) {
+		t.Fatalf("expected reused recovery code to be rejected, got %q", body)
+	}
+	if store[core.TwoFactorChallengeKeyPrefix+"challenge2"] != "user-1" {
+		t.Fatalf("expected challenge to survive an invalid recovery code")
+	}
+}
+
+func TestApiLogin2faVerifyTooManyInvalidCodes(t *testing.T) {
+	challengeKey := core.TwoFactorChallengeKeyPrefix + "challenge"
+	store := map[string]string{challengeKey: "user-1"}
+	now := time.Unix(1700000000, 0)
+
+	failures := 0
+	deps := newTestDeps(store)
+	deps.Now = func() time.Time { return now }
+	deps.VerificationAttemptFailed = func(key string) (bool, error) {
+		if key != challengeKey {
+			t.Fatalf("expected the attempt to count against the challenge, got %q", key)
+		}
+		failures++
+		if failures < core.MaxVerificationAttempts {
+			return false, nil
+		}
+		delete(store, key)
+		return true, nil
+	}
+
+	wrong, _ := utils.TotpCode(testSecret, now.Add(-10*utils.TotpPeriod))
+
+	var body string
+	for i := 0; i < core.MaxVerificationAttempts; i++ {
+		recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+			"two_factor_token": {"challenge"},
+			"code":             {wrong},
+		})
+		ApiLogin2faVerify(recorder, req, deps)
+		body = recorder.Body.String()
+	}
+
+	if !strings.Contains(body, `"message":"Too many invalid codes. Please log in again"`) {
+		t.Fatalf("expected the challenge to be invalidated, got %q", body)
+	}
+
+	right, _ := utils.TotpCode(testSecret, now)
+	recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+		"two_factor_token": {"challenge"},
+		"code":             {right},
+	})
+	ApiLogin2faVerify(recorder, req, deps)
+	if !strings.Contains(recorder.Body.String(), "Two-factor session has expired") {
+		t.Fatalf("expected the invalidated challenge to be rejected, got %q", recorder.Body.String())
+	}
+}
+
+func TestApiLogin2faVerifyAccountLockout(t *testing.T) {
+	store := map[string]string{core.TwoFactorChallengeKeyPrefix + "challenge": "user-1"}
+	now := time.Unix(1700000000, 0)
+
+	failed := map[string]int{}
+	reset := ""
+	deps := newTestDeps(store)
+	deps.Now = func() time.Time { return now }
+	deps.AccountLocked = func(userID string) bool {
+		return failed[userID] >= 2
+	}
+	deps.AccountFailed = func(ctx context.Context, userID string) error {
+		failed[userID]++
+		return nil
+	}
+	deps.AccountReset = func(userID string) error {
+		reset = userID
+		return nil
+	}
+
+	wrong, _ := utils.TotpCode(testSecret, now.Add(-10*utils.TotpPeriod))
+	right, _ := utils.TotpCode(testSecret, now)
+
+	verify := func(code string) string {
+		recorder, req := testutils.MakePostRequest(t, "/api/login-2fa-verify", url.Values{
+			"two_factor_token": {"challenge"},
+			"code":             {code},
+		})
+		ApiLogin2faVerify(recorder, req, deps)
+		return recorder.Body.String()
+	}
+
+	verify(wrong)
+	verify(wrong)
+	if failed["user-1"] != 2 {
+		t.Fatalf("expected wrong codes to count against the user, got %v", failed)
+	}
+
+	if body := verify(right); !strings.Contains(body, `"message":"`+core.AccountLockedMessage+`"`) {
+		t.Fatalf("expected the locked user to be refused, got %q", body)
+	}
+
+	failed["user-1"] = 0
+	if body := verify(right); !strings.Contains(body, `"token":"token-for-user-1"`) || reset != "user-1" {
+		t.Fatalf("expected the right code to clear the failures, got %q (%q)", body, reset)
+	}
+}
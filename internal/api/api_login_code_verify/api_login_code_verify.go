@@ -2,10 +2,13 @@ package api_login_code_verify
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/types"
 	"github.com/dracory/auth/utils"
 	"github.com/dracory/req"
@@ -17,12 +20,35 @@ import (
 type Dependencies struct {
 	DisableRateLimit bool
 
+	// Channel the codes were sent through, which sets their format
+	Channel types.PasswordlessChannel
+
 	TemporaryKeyGet func(key string) (string, error)
 
+	// TemporaryKeyDelete consumes the code once it is accepted, so it cannot
+	// be used again.
+	TemporaryKeyDelete func(key string) error
+
+	// LoginFlowFind, when set, requires the flow ID returned by api/login
+	// along with the code, which is then checked against the code issued for
+	// that flow only.
+	LoginFlowFind func(flowID string) (*core.LoginFlow, error)
+
+	// VerificationAttemptFailed records a wrong code against the flow,
+	// reporting when the flow has run out of attempts.
+	VerificationAttemptFailed func(key string) (exhausted bool, err error)
+
+	// AccountLocked, AccountFailed and AccountReset, when set, count wrong
+	// codes of bound flows against their recipient, refusing codes while it
+	// is locked.
+	AccountLocked func(recipient string) bool
+	AccountFailed func(recipient string) error
+	AccountReset  func(recipient string) error
+
 	// AuthenticateViaUsername is called on successful code verification
 	// to perform authentication (token generation, cookies, etc.) and send
 	// the final HTTP response.
-	AuthenticateViaUsername func(w http.ResponseWriter, r *http.Request, email string)
+	AuthenticateViaUsername func(w http.ResponseWriter, r *http.Request, recipient string)
 }
 
 // LoginCodeVerifyErrorCode categorizes error sources.
@@ -32,6 +58,9 @@ const (
 	LoginCodeVerifyErrorCodeNone        LoginCodeVerifyErrorCode = ""
 	LoginCodeVerifyErrorCodeValidation  LoginCodeVerifyErrorCode = "validation"
 	LoginCodeVerifyErrorCodeCodeExpired LoginCodeVerifyErrorCode = "code_expired"
+	LoginCodeVerifyErrorCodeCodeConsume LoginCodeVerifyErrorCode = "code_consume"
+	LoginCodeVerifyErrorCodeInvalidCode LoginCodeVerifyErrorCode = "invalid_code"
+	LoginCodeVerifyErrorCodeLocked      LoginCodeVerifyErrorCode = "locked"
 )
 
 // LoginCodeVerifyError represents a structured error in the login code
@@ -57,7 +86,7 @@ func (e *LoginCodeVerifyError) Error() string {
 
 // LoginCodeVerifyResult represents a successful verification.
 type LoginCodeVerifyResult struct {
-	Email string
+	Recipient string // the email, or the phone number with the sms channel
 }
 
 // ApiLoginCodeVerify is the HTTP-level helper that wires request/response
@@ -68,21 +97,28 @@ func ApiLoginCodeVerify(w http.ResponseWriter, r *http.Request, deps Dependencie
 	if perr != nil {
 		switch perr.Code {
 		case LoginCodeVerifyErrorCodeValidation,
-			LoginCodeVerifyErrorCodeCodeExpired:
+			LoginCodeVerifyErrorCodeCodeExpired,
+			LoginCodeVerifyErrorCodeInvalidCode,
+			LoginCodeVerifyErrorCodeLocked:
 			api.Respond(w, r, api.Error(perr.Message))
 			return
+		case LoginCodeVerifyErrorCodeCodeConsume:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
 		default:
 			api.Respond(w, r, api.Error("Verification code has expired"))
 			return
 		}
 	}
 
+	core.RateLimitSucceeded(r.Context())
+
 	if deps.AuthenticateViaUsername == nil {
 		api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
 		return
 	}
 
-	deps.AuthenticateViaUsername(w, r, result.Email)
+	deps.AuthenticateViaUsername(w, r, result.Recipient)
 }
 
 // ApiLoginCodeVerifyWithAuth is a convenience wrapper that allows callers to
@@ -90,24 +126,67 @@ func ApiLoginCodeVerify(w http.ResponseWriter, r *http.Request, deps Dependencie
 // manually wiring Dependencies. It constructs the Dependencies struct using
 // the interface accessors and preserves the existing behaviour.
 func ApiLoginCodeVerifyWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	deps := DependenciesWithAuth(r, a)
+
+	deps.AuthenticateViaUsername = func(w http.ResponseWriter, r *http.Request, recipient string) {
+		ctx := core.ContextWithLogin(r.Context(), types.AuthMethodCode, types.MFALevelSingleFactor, time.Now())
+		a.AuthenticateViaUsername(w, r.WithContext(ctx), recipient, "", "")
+	}
+
+	ApiLoginCodeVerify(w, r, deps)
+}
+
+// DependenciesWithAuth wires the Dependencies checking login codes from a
+// types.AuthSharedInterface, leaving AuthenticateViaUsername to the caller.
+func DependenciesWithAuth(r *http.Request, a types.AuthSharedInterface) Dependencies {
 	deps := Dependencies{
 		DisableRateLimit: a.GetDisableRateLimit(),
+		Channel:          a.GetPasswordlessChannel(),
 		TemporaryKeyGet:  a.GetFuncTemporaryKeyGet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
 	}
 
-	deps.AuthenticateViaUsername = func(w http.ResponseWriter, r *http.Request, email string) {
-		a.AuthenticateViaUsername(w, r, email, "", "")
+	if a.IsLoginCodeBindingEnabled() {
+		deps.LoginFlowFind = func(flowID string) (*core.LoginFlow, error) {
+			return core.LoginFlowFind(a, flowID)
+		}
+		deps.VerificationAttemptFailed = func(key string) (bool, error) {
+			return core.VerificationAttemptFailed(a, key)
+		}
 	}
 
-	ApiLoginCodeVerify(w, r, deps)
+	if a.IsLoginCodeBindingEnabled() && a.GetAccountLockout() != nil {
+		options := types.UserAuthOptions{
+			UserIp:    req.GetIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		deps.AccountLocked = func(recipient string) bool {
+			return !core.AccountLockedUntil(a, recipient, time.Now()).IsZero()
+		}
+		deps.AccountFailed = func(recipient string) error {
+			return core.AccountFailureRecord(r.Context(), a, recipient, options, time.Now())
+		}
+		deps.AccountReset = func(recipient string) error {
+			return core.AccountFailuresReset(a, recipient)
+		}
+	}
+
+	return deps
 }
 
 // LoginCodeVerify encapsulates the core business logic for verifying a
 // passwordless login code. It mirrors the original validation rules and error
 // messages but does not perform authentication or write HTTP responses.
 func LoginCodeVerify(ctx context.Context, r *http.Request, deps Dependencies) (*LoginCodeVerifyResult, *LoginCodeVerifyError) {
-	verificationCode := req.GetStringTrimmed(r, "verification_code")
+	return LoginCodeCheck(req.GetStringTrimmed(r, "verification_code"), req.GetStringTrimmed(r, "flow_id"), deps)
+}
 
+// LoginCodeCheck verifies the code, and consumes it when it is valid. The
+// flow ID is only used when login codes are bound to their flow. It is
+// shared by the typed codes and the magic links.
+func LoginCodeCheck(verificationCode string, flowID string, deps Dependencies) (*LoginCodeVerifyResult, *LoginCodeVerifyError) {
 	if verificationCode == "" {
 		return nil, &LoginCodeVerifyError{
 			Code:    LoginCodeVerifyErrorCodeValidation,
@@ -115,20 +194,39 @@ func LoginCodeVerify(ctx context.Context, r *http.Request, deps Dependencies) (*
 		}
 	}
 
-	if len(verificationCode) != utils.LoginCodeLength(deps.DisableRateLimit) {
+	codeLength, codeGamma := utils.LoginCodeLength(deps.DisableRateLimit), utils.LoginCodeGamma(deps.DisableRateLimit)
+	if deps.Channel == types.PasswordlessChannelSms {
+		codeLength, codeGamma = utils.PhoneCodeLength(deps.DisableRateLimit), utils.PhoneCodeGamma()
+	}
+
+	if len(verificationCode) != codeLength {
 		return nil, &LoginCodeVerifyError{
 			Code:    LoginCodeVerifyErrorCodeValidation,
 			Message: "Verification code is invalid length",
 		}
 	}
 
-	if !str.ContainsOnly(verificationCode, utils.LoginCodeGamma(deps.DisableRateLimit)) {
+	if !str.ContainsOnly(verificationCode, codeGamma) {
 		return nil, &LoginCodeVerifyError{
 			Code:    LoginCodeVerifyErrorCodeValidation,
 			Message: "Verification code contains invalid characters",
 		}
 	}
 
+	if deps.LoginFlowFind != nil {
+		return loginCodeVerifyBound(flowID, verificationCode, deps)
+	}
+
+	// Text message codes are short enough to guess when looked up by
+	// themselves, so they are only ever accepted through their flow
+	if deps.Channel == types.PasswordlessChannelSms {
+		return nil, &LoginCodeVerifyError{
+			Code:    LoginCodeVerifyErrorCodeCodeExpired,
+			Message: "Verification code has expired",
+			Err:     errors.New("text message codes require login flows"),
+		}
+	}
+
 	if deps.TemporaryKeyGet == nil {
 		return nil, &LoginCodeVerifyError{
 			Code:    LoginCodeVerifyErrorCodeCodeExpired,
@@ -137,8 +235,8 @@ func LoginCodeVerify(ctx context.Context, r *http.Request, deps Dependencies) (*
 		}
 	}
 
-	email, errCode := deps.TemporaryKeyGet(verificationCode)
-	if errCode != nil {
+	recipient, errCode := deps.TemporaryKeyGet(verificationCode)
+	if errCode != nil || recipient == "" {
 		return nil, &LoginCodeVerifyError{
 			Code:    LoginCodeVerifyErrorCodeCodeExpired,
 			Message: "Verification code has expired",
@@ -146,5 +244,94 @@ func LoginCodeVerify(ctx context.Context, r *http.Request, deps Dependencies) (*
 		}
 	}
 
-	return &LoginCodeVerifyResult{Email: email}, nil
+	if deps.TemporaryKeyDelete != nil {
+		if errDelete := deps.TemporaryKeyDelete(verificationCode); errDelete != nil {
+			return nil, &LoginCodeVerifyError{
+				Code: LoginCodeVerifyErrorCodeCodeConsume,
+				Err:  errDelete,
+			}
+		}
+	}
+
+	return &LoginCodeVerifyResult{Recipient: recipient}, nil
+}
+
+// loginCodeVerifyBound checks the code against the one issued for the flow.
+// Wrong codes count against the flow, which is dropped once it runs out of
+// attempts, so the user has to request a new code.
+func loginCodeVerifyBound(flowID string, verificationCode string, deps Dependencies) (*LoginCodeVerifyResult, *LoginCodeVerifyError) {
+	if flowID == "" {
+		return nil, &LoginCodeVerifyError{
+			Code:    LoginCodeVerifyErrorCodeValidation,
+			Message: "Flow ID is required field",
+		}
+	}
+
+	flow, errFlow := deps.LoginFlowFind(flowID)
+	if errFlow != nil || flow == nil {
+		return nil, &LoginCodeVerifyError{
+			Code:    LoginCodeVerifyErrorCodeCodeExpired,
+			Message: "Verification code has expired",
+			Err:     errFlow,
+		}
+	}
+
+	flowKey := core.LoginFlowKeyPrefix + flowID
+
+	if deps.AccountLocked != nil && deps.AccountLocked(flow.Recipient) {
+		return nil, &LoginCodeVerifyError{
+			Code:    LoginCodeVerifyErrorCodeLocked,
+			Message: core.AccountLockedMessage,
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(flow.Code), []byte(verificationCode)) != 1 {
+		if deps.AccountFailed != nil {
+			if errFailed := deps.AccountFailed(flow.Recipient); errFailed != nil {
+				return nil, &LoginCodeVerifyError{
+					Code: LoginCodeVerifyErrorCodeCodeConsume,
+					Err:  errFailed,
+				}
+			}
+		}
+
+		message := "Verification code is invalid"
+		if deps.VerificationAttemptFailed != nil {
+			exhausted, errAttempt := deps.VerificationAttemptFailed(flowKey)
+			if errAttempt != nil {
+				return nil, &LoginCodeVerifyError{
+					Code: LoginCodeVerifyErrorCodeCodeConsume,
+					Err:  errAttempt,
+				}
+			}
+			if exhausted {
+				message = "Too many invalid codes. Please request a new code"
+			}
+		}
+
+		return nil, &LoginCodeVerifyError{
+			Code:    LoginCodeVerifyErrorCodeInvalidCode,
+			Message: message,
+		}
+	}
+
+	if deps.TemporaryKeyDelete != nil {
+		if errDelete := deps.TemporaryKeyDelete(flowKey); errDelete != nil {
+			return nil, &LoginCodeVerifyError{
+				Code: LoginCodeVerifyErrorCodeCodeConsume,
+				Err:  errDelete,
+			}
+		}
+	}
+
+	if deps.AccountReset != nil {
+		if errReset := deps.AccountReset(flow.Recipient); errReset != nil {
+			return nil, &LoginCodeVerifyError{
+				Code: LoginCodeVerifyErrorCodeCodeConsume,
+				Err:  errReset,
+			}
+		}
+	}
+
+	return &LoginCodeVerifyResult{Recipient: flow.Recipient}, nil
 }
@@ -1,12 +1,16 @@
 package api_login_code_verify
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
 )
 
 func makePostRequest(t *testing.T, path string, values url.Values) (*httptest.ResponseRecorder, *http.Request) {
@@ -209,3 +213,170 @@ func TestApiLoginCodeVerifySuccess(t *testing.T) {
 		t.Fatalf("expected token in response, got %q", body)
 	}
 }
+
+func TestLoginCodeVerifyConsumesCode(t *testing.T) {
+	store := map[string]string{"BCDFGHJK": "user@example.com"}
+	deps := Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			return store[key], nil
+		},
+		TemporaryKeyDelete: func(key string) error {
+			delete(store, key)
+			return nil
+		},
+	}
+
+	values := url.Values{"verification_code": {"BCDFGHJK"}}
+
+	_, req := makePostRequest(t, "/api/login-code-verify", values)
+	result, perr := LoginCodeVerify(context.Background(), req, deps)
+	if perr != nil || result.Recipient != "user@example.com" {
+		t.Fatalf("expected the code to be accepted, got %+v (%v)", result, perr)
+	}
+
+	_, req = makePostRequest(t, "/api/login-code-verify", values)
+	if _, perr := LoginCodeVerify(context.Background(), req, deps); perr == nil || perr.Code != LoginCodeVerifyErrorCodeCodeExpired {
+		t.Fatalf("expected the used code to be rejected, got %v", perr)
+	}
+}
+
+func TestLoginCodeVerifyBound(t *testing.T) {
+	flows := map[string]*core.LoginFlow{
+		"flow-1": {Recipient: "user@example.com", Code: "BCDFGHJK"},
+	}
+	attempts := 0
+	deps := Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			t.Fatalf("expected unbound codes not to be looked up, got %q", key)
+			return "", nil
+		},
+		LoginFlowFind: func(flowID string) (*core.LoginFlow, error) {
+			return flows[flowID], nil
+		},
+		VerificationAttemptFailed: func(key string) (bool, error) {
+			if key != core.LoginFlowKeyPrefix+"flow-1" {
+				t.Fatalf("expected the attempt to count against the flow, got %q", key)
+			}
+			attempts++
+			return attempts >= 2, nil
+		},
+		TemporaryKeyDelete: func(key string) error {
+			delete(flows, strings.TrimPrefix(key, core.LoginFlowKeyPrefix))
+			return nil
+		},
+	}
+
+	verify := func(values url.Values) (*LoginCodeVerifyResult, *LoginCodeVerifyError) {
+		_, req := makePostRequest(t, "/api/login-code-verify", values)
+		return LoginCodeVerify(context.Background(), req, deps)
+	}
+
+	if _, perr := verify(url.Values{"verification_code": {"BCDFGHJK"}}); perr == nil || perr.Message != "Flow ID is required field" {
+		t.Fatalf("expected the flow ID to be required, got %v", perr)
+	}
+
+	if _, perr := verify(url.Values{"verification_code": {"BCDFGHJK"}, "flow_id": {"flow-2"}}); perr == nil || perr.Code != LoginCodeVerifyErrorCodeCodeExpired {
+		t.Fatalf("expected an unknown flow to be rejected, got %v", perr)
+	}
+
+	if _, perr := verify(url.Values{"verification_code": {"CDFGHJKL"}, "flow_id": {"flow-1"}}); perr == nil || perr.Message != "Verification code is invalid" {
+		t.Fatalf("expected the wrong code to be rejected, got %v", perr)
+	}
+
+	result, perr := verify(url.Values{"verification_code": {"BCDFGHJK"}, "flow_id": {"flow-1"}})
+	if perr != nil || result.Recipient != "user@example.com" {
+		t.Fatalf("expected the code to be accepted, got %+v (%v)", result, perr)
+	}
+	if _, ok := flows["flow-1"]; ok {
+		t.Fatal("expected the flow to be consumed")
+	}
+}
+
+func TestLoginCodeVerifyBoundTooManyInvalidCodes(t *testing.T) {
+	deps := Dependencies{
+		LoginFlowFind: func(flowID string) (*core.LoginFlow, error) {
+			return &core.LoginFlow{Recipient: "user@example.com", Code: "BCDFGHJK"}, nil
+		},
+		VerificationAttemptFailed: func(key string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	recorder, req := makePostRequest(t, "/api/login-code-verify", url.Values{"verification_code": {"CDFGHJKL"}, "flow_id": {"flow-1"}})
+	ApiLoginCodeVerify(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, "\"message\":\"Too many invalid codes. Please request a new code\"") {
+		t.Fatalf("expected the flow to be exhausted, got %q", body)
+	}
+}
+
+func TestLoginCodeCheckSmsCodes(t *testing.T) {
+	deps := Dependencies{
+		Channel: types.PasswordlessChannelSms,
+		LoginFlowFind: func(flowID string) (*core.LoginFlow, error) {
+			return &core.LoginFlow{Recipient: "+447700900123", Code: "123456"}, nil
+		},
+	}
+
+	if _, perr := LoginCodeCheck("BCDFGHJK", "flow-1", deps); perr == nil || perr.Message != "Verification code is invalid length" {
+		t.Fatalf("expected an email code to be rejected, got %v", perr)
+	}
+
+	if _, perr := LoginCodeCheck("12345A", "flow-1", deps); perr == nil || perr.Message != "Verification code contains invalid characters" {
+		t.Fatalf("expected a non-numeric code to be rejected, got %v", perr)
+	}
+
+	result, perr := LoginCodeCheck("123456", "flow-1", deps)
+	if perr != nil || result.Recipient != "+447700900123" {
+		t.Fatalf("expected the numeric code to be accepted, got %+v (%v)", result, perr)
+	}
+
+	deps.LoginFlowFind = nil
+	deps.TemporaryKeyGet = func(key string) (string, error) {
+		t.Fatalf("expected text message codes not to be looked up by themselves, got %q", key)
+		return "", nil
+	}
+	if _, perr := LoginCodeCheck("123456", "", deps); perr == nil || perr.Code != LoginCodeVerifyErrorCodeCodeExpired {
+		t.Fatalf("expected an unbound text message code to be rejected, got %v", perr)
+	}
+}
+
+func TestLoginCodeVerifyBoundAccountLockout(t *testing.T) {
+	failed := map[string]int{}
+	reset := ""
+	deps := Dependencies{
+		LoginFlowFind: func(flowID string) (*core.LoginFlow, error) {
+			return &core.LoginFlow{Recipient: "user@example.com", Code: "BCDFGHJK"}, nil
+		},
+		AccountLocked: func(recipient string) bool {
+			return failed[recipient] >= 2
+		},
+		AccountFailed: func(recipient string) error {
+			failed[recipient]++
+			return nil
+		},
+		AccountReset: func(recipient string) error {
+			reset = recipient
+			return nil
+		},
+	}
+
+	if _, perr := LoginCodeCheck("CDFGHJKL", "flow-1", deps); perr == nil || perr.Code != LoginCodeVerifyErrorCodeInvalidCode {
+		t.Fatalf("expected the wrong code to be rejected, got %v", perr)
+	}
+	if failed["user@example.com"] != 1 {
+		t.Fatalf("expected the wrong code to count against the recipient, got %v", failed)
+	}
+
+	if _, perr := LoginCodeCheck("BCDFGHJK", "flow-1", deps); perr != nil || reset != "user@example.com" {
+		t.Fatalf("expected the right code to clear the failures, got %v (%q)", perr, reset)
+	}
+
+	failed["user@example.com"] = 2
+	recorder, req := makePostRequest(t, "/api/login-code-verify", url.Values{"verification_code": {"BCDFGHJK"}, "flow_id": {"flow-1"}})
+	ApiLoginCodeVerify(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"`+core.AccountLockedMessage+`"`) {
+		t.Fatalf("expected the locked recipient to be refused, got %q", body)
+	}
+}
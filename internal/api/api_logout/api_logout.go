@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/types"
 	"github.com/dracory/auth/utils"
 	"github.com/dracory/req"
@@ -58,6 +59,20 @@ func ApiLogout(w http.ResponseWriter, r *http.Request, deps Dependencies) {
 		}
 	}
 
+	if deps.RefreshTokenRevoke != nil {
+		if err := deps.RefreshTokenRevoke(r.Context(), req.GetStringTrimmed(r, "refresh_token")); err != nil {
+			api.Respond(w, r, api.Error("Logout failed. Please try again later"))
+			return
+		}
+	}
+
+	if deps.UseCookies && deps.RememberMeForget != nil {
+		if err := deps.RememberMeForget(w, r); err != nil {
+			api.Respond(w, r, api.Error("Logout failed. Please try again later"))
+			return
+		}
+	}
+
 	if deps.UseCookies && deps.RemoveAuthCookie != nil {
 		deps.RemoveAuthCookie(w, r)
 	}
@@ -80,18 +95,37 @@ func ApiLogoutWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthShare
 		},
 	}
 
-	if fn := a.GetFuncUserFindByAuthToken(); fn != nil {
+	if a.GetJWT() != nil || core.SessionStore(a) != nil {
 		deps.UserFromToken = func(ctx context.Context, token string) (string, error) {
-			return fn(ctx, token, types.UserAuthOptions{
+			return core.AuthTokenUserID(ctx, a, token, types.UserAuthOptions{
 				UserIp:    req.GetIP(r),
 				UserAgent: r.UserAgent(),
 			})
 		}
 	}
 
-	if fn := a.GetFuncUserLogout(); fn != nil {
+	if a.GetSessionStore() != nil || a.GetFuncUserLogout() != nil {
 		deps.LogoutUser = func(ctx context.Context, userID string) error {
-			return fn(ctx, userID, types.UserAuthOptions{
+			token := utils.AuthTokenRetrieve(r, a.GetUseCookies())
+			return core.SessionLogout(ctx, a, token, userID, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
+	if a.GetRememberMe() != nil {
+		deps.RememberMeForget = func(w http.ResponseWriter, r *http.Request) error {
+			return core.RememberMeForget(w, r, a, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+	}
+
+	if config := a.GetRefreshToken(); config != nil {
+		deps.RefreshTokenRevoke = func(ctx context.Context, refreshToken string) error {
+			return core.RefreshTokenRevoke(ctx, config, refreshToken, types.UserAuthOptions{
 				UserIp:    req.GetIP(r),
 				UserAgent: r.UserAgent(),
 			})
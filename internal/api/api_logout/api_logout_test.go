@@ -82,3 +82,33 @@ func TestApiLogoutTokenValidationError(t *testing.T) {
 		t.Fatalf("expected logout failed message, got %q", body)
 	}
 }
+
+func TestApiLogoutRevokesRefreshToken(t *testing.T) {
+	var revoked string
+	deps := Dependencies{
+		AuthTokenRetrieve: func(r *http.Request, useCookies bool) string {
+			return "token"
+		},
+		UserFromToken: func(ctx context.Context, token string) (string, error) {
+			return "user-1", nil
+		},
+		LogoutUser: func(ctx context.Context, userID string) error {
+			return nil
+		},
+		RefreshTokenRevoke: func(ctx context.Context, refreshToken string) error {
+			revoked = refreshToken
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/logout?refresh_token=refresh-1", nil)
+	recorder := httptest.NewRecorder()
+	ApiLogout(recorder, req, deps)
+
+	if body := recorder.Body.String(); !strings.Contains(body, "\"status\":\"success\"") {
+		t.Fatalf("expected status success, got %q", body)
+	}
+	if revoked != "refresh-1" {
+		t.Fatalf("expected refresh token to be revoked, got %q", revoked)
+	}
+}
@@ -27,4 +27,13 @@ type Dependencies struct {
 	// RemoveAuthCookie removes the authentication cookie after a successful
 	// logout when UseCookies is true.
 	RemoveAuthCookie func(w http.ResponseWriter, r *http.Request)
+
+	// RefreshTokenRevoke revokes the refresh token sent with the logout
+	// request, together with every token rotated from the same login.
+	// Optional.
+	RefreshTokenRevoke func(ctx context.Context, refreshToken string) error
+
+	// RememberMeForget revokes the remember-me token of the request and
+	// removes its cookie when UseCookies is true. Optional.
+	RememberMeForget func(w http.ResponseWriter, r *http.Request) error
 }
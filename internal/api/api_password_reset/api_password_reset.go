@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/types"
 	"github.com/dracory/auth/utils"
 	"github.com/dracory/req"
@@ -21,6 +22,7 @@ const (
 	PasswordResetErrorCodePasswordStrength PasswordResetErrorCode = "password_strength"
 	PasswordResetErrorCodeTokenLookup      PasswordResetErrorCode = "token_lookup"
 	PasswordResetErrorCodeTokenInvalid     PasswordResetErrorCode = "token_invalid"
+	PasswordResetErrorCodeTokenConsume     PasswordResetErrorCode = "token_consume"
 	PasswordResetErrorCodePasswordChange   PasswordResetErrorCode = "password_change"
 	PasswordResetErrorCodeLogout           PasswordResetErrorCode = "logout"
 	PasswordResetErrorCodeInternal         PasswordResetErrorCode = "internal"
@@ -87,6 +89,7 @@ func ApiPasswordReset(w http.ResponseWriter, r *http.Request, deps Dependencies)
 		}
 	}
 
+	core.RateLimitSucceeded(r.Context())
 	api.Respond(w, r, api.SuccessWithData(result.SuccessMessage, map[string]any{
 		"token": result.Token,
 	}))
@@ -100,6 +103,9 @@ func ApiPasswordResetWithAuth(w http.ResponseWriter, r *http.Request, a types.Au
 	deps := Dependencies{
 		PasswordStrength: a.GetPasswordStrength(),
 		TemporaryKeyGet:  a.GetFuncTemporaryKeyGet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
 	}
 
 	if fn := a.GetFuncUserPasswordChange(); fn != nil {
@@ -111,9 +117,9 @@ func ApiPasswordResetWithAuth(w http.ResponseWriter, r *http.Request, a types.Au
 		}
 	}
 
-	if fn := a.GetFuncUserLogout(); fn != nil {
+	if a.GetSessionStore() != nil || a.GetFuncUserLogout() != nil || a.GetRememberMe() != nil || a.GetRefreshToken() != nil {
 		deps.LogoutUser = func(ctx context.Context, userID string) error {
-			return fn(ctx, userID, types.UserAuthOptions{
+			return core.SessionsDeleteAllForUser(ctx, a, userID, types.UserAuthOptions{
 				UserIp:    req.GetIP(r),
 				UserAgent: r.UserAgent(),
 			})
@@ -183,6 +189,16 @@ func PasswordReset(ctx context.Context, r *http.Request, deps Dependencies) (*Pa
 		}
 	}
 
+	if deps.TemporaryKeyDelete != nil {
+		if errDelete := deps.TemporaryKeyDelete(token); errDelete != nil {
+			return nil, &PasswordResetError{
+				Code:   PasswordResetErrorCodeTokenConsume,
+				Err:    errDelete,
+				UserID: userID,
+			}
+		}
+	}
+
 	if deps.UserPasswordChange == nil {
 		return nil, &PasswordResetError{
 			Code:   PasswordResetErrorCodePasswordChange,
@@ -181,3 +181,40 @@ func TestApiPasswordResetSuccess(t *testing.T) {
 		t.Fatalf("LogoutUser should be called on successful password reset")
 	}
 }
+
+func TestPasswordResetConsumesToken(t *testing.T) {
+	store := map[string]string{"valid-token": "user123"}
+	changes := 0
+	deps := Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			return store[key], nil
+		},
+		TemporaryKeyDelete: func(key string) error {
+			delete(store, key)
+			return nil
+		},
+		UserPasswordChange: func(ctx context.Context, userID, password string) error {
+			changes++
+			return nil
+		},
+	}
+
+	values := url.Values{
+		"token":            {"valid-token"},
+		"password":         {"password123"},
+		"password_confirm": {"password123"},
+	}
+
+	_, req := makePostRequest(t, "/api/password-reset", values)
+	if _, perr := PasswordReset(context.Background(), req, deps); perr != nil {
+		t.Fatalf("unexpected error %v", perr)
+	}
+
+	_, req = makePostRequest(t, "/api/password-reset", values)
+	if _, perr := PasswordReset(context.Background(), req, deps); perr == nil || perr.Code != PasswordResetErrorCodeTokenInvalid {
+		t.Fatalf("expected the used link to be rejected, got %v", perr)
+	}
+	if changes != 1 {
+		t.Fatalf("expected a single password change, got %d", changes)
+	}
+}
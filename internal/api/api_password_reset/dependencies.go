@@ -13,6 +13,10 @@ type Dependencies struct {
 
 	TemporaryKeyGet func(key string) (string, error)
 
+	// TemporaryKeyDelete consumes the reset token once it is accepted, so the
+	// link cannot be used again.
+	TemporaryKeyDelete func(key string) error
+
 	UserPasswordChange func(ctx context.Context, userID, password string) error
 	LogoutUser         func(ctx context.Context, userID string) error
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/types"
 	"github.com/dracory/auth/utils"
 	"github.com/dracory/req"
@@ -57,6 +58,7 @@ func ApiPasswordRestore(w http.ResponseWriter, r *http.Request, deps dependencie
 		return
 	}
 
+	core.RateLimitSucceeded(r.Context())
 	api.Respond(w, r, api.Success(successMessage))
 }
 
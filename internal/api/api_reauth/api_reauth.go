@@ -0,0 +1,703 @@
+package api_reauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+	"github.com/dracory/str"
+)
+
+// ReauthKeyPrefix namespaces the re-authentication codes of signed in users
+// in the temporary key store. The code is keyed by user ID, so a new code
+// replaces the previous one.
+const ReauthKeyPrefix = "reauth:"
+
+// reauthCodeExpiresSeconds is how long a re-authentication code is valid.
+const reauthCodeExpiresSeconds = 600
+
+// reauthTotpAttemptsKeyPrefix namespaces the wrong TOTP codes entered by
+// signed in users re-authenticating. After core.MaxVerificationAttempts of
+// them the user has to wait reauthTotpAttemptsExpiresSeconds, so the code
+// cannot be brute forced from a session.
+const (
+	reauthTotpAttemptsKeyPrefix      = "reauth_totp:"
+	reauthTotpAttemptsExpiresSeconds = 900
+)
+
+// Dependencies defines the dependencies required for re-authenticating the
+// signed in user before a sensitive action.
+type Dependencies struct {
+	// CurrentPrincipal returns the authenticated caller of the request.
+	CurrentPrincipal func(r *http.Request) *types.Principal
+
+	// Passwordless selects the code flow instead of the password check.
+	Passwordless bool
+
+	// UserLogin checks the credentials, returning the ID of their user.
+	// Required with passwords.
+	UserLogin func(ctx context.Context, username string, password string) (string, error)
+
+	// UserTotpSecretFind returns the base32 TOTP secret of the user, or an
+	// empty string when two-factor authentication is not enabled. With a
+	// secret the code from the authenticator app is required as well.
+	// Optional.
+	UserTotpSecretFind func(ctx context.Context, userID string) (string, error)
+
+	// Channel selects email or text message codes (default: email)
+	Channel          types.PasswordlessChannel
+	DisableRateLimit bool
+
+	// UserFindByEmail and UserFindByPhone return the ID of the user with the
+	// email address or phone number, for the channel in use.
+	UserFindByEmail func(ctx context.Context, email string) (string, error)
+	UserFindByPhone func(ctx context.Context, phone string) (string, error)
+
+	TemporaryKeyGet    func(key string) (string, error)
+	TemporaryKeySet    func(key string, value string, expiresSeconds int) error
+	TemporaryKeyDelete func(key string) error
+
+	// VerificationAttemptFailed records a wrong code, reporting when the
+	// code has been invalidated because of too many. Optional.
+	VerificationAttemptFailed func(key string) (exhausted bool, err error)
+
+	// AccountLocked, AccountFailed and AccountReset count wrong passwords
+	// against the account as typed, like on login, refusing the password
+	// while the account is locked. Optional.
+	AccountLocked func(account string) bool
+	AccountFailed func(ctx context.Context, account string) error
+	AccountReset  func(account string) error
+
+	// TwoFactorLocked, TwoFactorFailed and TwoFactorReset count wrong TOTP
+	// codes against the user, like the two-factor login step. Optional.
+	TwoFactorLocked func(userID string) bool
+	TwoFactorFailed func(ctx context.Context, userID string) error
+	TwoFactorReset  func(userID string) error
+
+	EmailTemplate func(ctx context.Context, email string, verificationCode string) string
+	EmailSend     func(ctx context.Context, email string, subject string, body string) error
+	SmsTemplate   func(ctx context.Context, phone string, verificationCode string) string
+	SmsSend       func(ctx context.Context, phone string, message string) error
+
+	// AuthTokenIssue issues and stores a new auth token for the user,
+	// recording the re-authentication as how they signed in.
+	AuthTokenIssue func(ctx context.Context, userID string, method types.AuthMethod, mfaLevel types.MFALevel) (string, error)
+
+	// SessionDelete ends the session replaced by the new token. Optional:
+	// JWT access tokens cannot be ended and expire by themselves.
+	SessionDelete func(ctx context.Context, sessionID string) error
+
+	UseCookies    bool
+	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	// Now returns the current time. Defaults to time.Now when nil.
+	Now func() time.Time
+}
+
+// ReauthErrorCode categorizes error sources.
+type ReauthErrorCode string
+
+const (
+	ReauthErrorCodeNone            ReauthErrorCode = ""
+	ReauthErrorCodeUnauthenticated ReauthErrorCode = "unauthenticated"
+	ReauthErrorCodeImpersonated    ReauthErrorCode = "impersonated"
+	ReauthErrorCodeValidation      ReauthErrorCode = "validation"
+	ReauthErrorCodeInvalid         ReauthErrorCode = "invalid"
+	ReauthErrorCodeLocked          ReauthErrorCode = "locked"
+	ReauthErrorCodeCodeExpired     ReauthErrorCode = "code_expired"
+	ReauthErrorCodeCodeGeneration  ReauthErrorCode = "code_generation"
+	ReauthErrorCodeCodeStore       ReauthErrorCode = "code_store"
+	ReauthErrorCodeSend            ReauthErrorCode = "send"
+	ReauthErrorCodeUserLookup      ReauthErrorCode = "user_lookup"
+	ReauthErrorCodeTokenStore      ReauthErrorCode = "token_store"
+)
+
+// ReauthError represents a structured error in the re-authentication flow.
+type ReauthError struct {
+	Code    ReauthErrorCode
+	Message string
+	Err     error
+}
+
+func (e *ReauthError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ReauthResult represents a successful step of the re-authentication. Token
+// is empty when a code was sent and has to be entered next.
+type ReauthResult struct {
+	CodeSent bool
+	Token    string
+}
+
+// ApiReauth is the HTTP-level helper that wires request/response handling
+// to the core Reauth business logic using the provided dependencies.
+func ApiReauth(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, perr := Reauth(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case ReauthErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case ReauthErrorCodeImpersonated:
+			api.Respond(w, r, api.Forbidden(perr.Message))
+			return
+		case ReauthErrorCodeValidation,
+			ReauthErrorCodeInvalid,
+			ReauthErrorCodeLocked,
+			ReauthErrorCodeCodeExpired:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	if result.CodeSent {
+		api.Respond(w, r, api.Success("Verification code was sent successfully"))
+		return
+	}
+
+	core.RateLimitSucceeded(r.Context())
+
+	if deps.UseCookies && deps.SetAuthCookie != nil {
+		deps.SetAuthCookie(w, r, result.Token)
+	}
+
+	api.Respond(w, r, api.SuccessWithData("reauthentication success", map[string]any{
+		"token": result.Token,
+	}))
+}
+
+// ApiReauthWithAuth is a convenience wrapper that allows callers to pass a
+// types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiReauthWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	ApiReauth(w, r, DependenciesWithAuth(r, a))
+}
+
+// DependenciesWithAuth builds the Dependencies of the request from the auth
+// configuration.
+func DependenciesWithAuth(r *http.Request, a types.AuthSharedInterface) Dependencies {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentPrincipal: func(r *http.Request) *types.Principal {
+			return types.PrincipalFromContext(r.Context())
+		},
+		Passwordless:     a.IsPasswordless(),
+		Channel:          a.GetPasswordlessChannel(),
+		DisableRateLimit: a.GetDisableRateLimit(),
+		TemporaryKeyGet:  a.GetFuncTemporaryKeyGet(),
+		TemporaryKeySet:  a.GetFuncTemporaryKeySet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
+		VerificationAttemptFailed: func(key string) (bool, error) {
+			return core.VerificationAttemptFailed(a, key)
+		},
+		UserTotpSecretFind: func(ctx context.Context, userID string) (string, error) {
+			return core.TwoFactorSecretFind(ctx, a, userID, options)
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string, method types.AuthMethod, mfaLevel types.MFALevel) (string, error) {
+			ctx = core.ContextWithLogin(ctx, method, mfaLevel, time.Now())
+			return core.AuthTokenIssue(ctx, a, userID, options)
+		},
+		UseCookies: a.GetUseCookies(),
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			a.SetAuthCookie(w, r, token)
+		},
+	}
+
+	if fn := a.GetFuncUserLogin(); fn != nil {
+		deps.UserLogin = func(ctx context.Context, username string, password string) (string, error) {
+			return fn(ctx, username, password, options)
+		}
+	}
+
+	if fn := a.GetPasswordlessUserFindByEmail(); fn != nil {
+		deps.UserFindByEmail = func(ctx context.Context, email string) (string, error) {
+			return fn(ctx, email, options)
+		}
+	}
+
+	if fn := a.GetPasswordlessUserFindByPhone(); fn != nil {
+		deps.UserFindByPhone = func(ctx context.Context, phone string) (string, error) {
+			return fn(ctx, phone, options)
+		}
+	}
+
+	if template, send := a.GetPasswordlessFuncEmailTemplateLoginCode(), a.GetPasswordlessFuncEmailSend(); template != nil && send != nil {
+		deps.EmailTemplate = func(ctx context.Context, email string, verificationCode string) string {
+			return template(ctx, email, verificationCode, options)
+		}
+		deps.EmailSend = send
+	}
+
+	if template, send := a.GetPasswordlessFuncSmsTemplateLoginCode(), a.GetPasswordlessFuncSmsSend(); template != nil && send != nil {
+		deps.SmsTemplate = func(ctx context.Context, phone string, verificationCode string) string {
+			return template(ctx, phone, verificationCode, options)
+		}
+		deps.SmsSend = send
+	}
+
+	if a.GetAccountLockout() != nil {
+		deps.AccountLocked = func(account string) bool {
+			return !core.AccountLockedUntil(a, account, time.Now()).IsZero()
+		}
+		deps.AccountFailed = func(ctx context.Context, account string) error {
+			return core.AccountFailureRecord(ctx, a, account, options, time.Now())
+		}
+		deps.AccountReset = func(account string) error {
+			return core.AccountFailuresReset(a, account)
+		}
+		deps.TwoFactorLocked = func(userID string) bool {
+			return !core.TwoFactorLockedUntil(a, userID, time.Now()).IsZero()
+		}
+		deps.TwoFactorFailed = func(ctx context.Context, userID string) error {
+			return core.TwoFactorFailureRecord(ctx, a, userID, options, time.Now())
+		}
+		deps.TwoFactorReset = func(userID string) error {
+			return core.TwoFactorFailuresReset(a, userID)
+		}
+	}
+
+	if a.GetSessionStore() != nil && a.GetJWT() == nil {
+		deps.SessionDelete = func(ctx context.Context, sessionID string) error {
+			return core.SessionDelete(ctx, a, sessionID, options)
+		}
+	}
+
+	return deps
+}
+
+// Reauth encapsulates the core business logic of re-authenticating the
+// signed in user. With passwords the user enters their credentials, and the
+// code of their authenticator app when two-factor authentication is enabled.
+// Passwordless users first ask for a code, sent like a login code, then
+// enter it.
+//
+// The credentials must belong to the signed in user. On success the session
+// is replaced by a new one recording the re-authentication, so
+// RequireRecentAuth lets the user through. It does not write HTTP
+// responses.
+func Reauth(ctx context.Context, r *http.Request, deps Dependencies) (*ReauthResult, *ReauthError) {
+	var principal *types.Principal
+	if deps.CurrentPrincipal != nil {
+		principal = deps.CurrentPrincipal(r)
+	}
+
+	if principal == nil || principal.UserID == "" {
+		return nil, &ReauthError{
+			Code:    ReauthErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if principal.IsImpersonated() {
+		return nil, &ReauthError{
+			Code:    ReauthErrorCodeImpersonated,
+			Message: "Impersonation sessions cannot re-authenticate",
+		}
+	}
+
+	var method types.AuthMethod
+	var mfaLevel types.MFALevel
+	if deps.Passwordless {
+		verificationCode := req.GetStringTrimmed(r, "verification_code")
+		if verificationCode == "" {
+			if perr := reauthCodeSend(ctx, r, deps, principal.UserID); perr != nil {
+				return nil, perr
+			}
+			return &ReauthResult{CodeSent: true}, nil
+		}
+
+		if perr := reauthCodeCheck(deps, principal.UserID, verificationCode); perr != nil {
+			return nil, perr
+		}
+		method, mfaLevel = types.AuthMethodCode, types.MFALevelSingleFactor
+	} else {
+		level, perr := reauthPasswordCheck(ctx, r, deps, principal.UserID)
+		if perr != nil {
+			return nil, perr
+		}
+		method, mfaLevel = types.AuthMethodPassword, level
+	}
+
+	if deps.AuthTokenIssue == nil {
+		return nil, &ReauthError{
+			Code: ReauthErrorCodeTokenStore,
+			Err:  errors.New("auth token issuing is not configured"),
+		}
+	}
+
+	token, errToken := deps.AuthTokenIssue(ctx, principal.UserID, method, mfaLevel)
+	if errToken != nil {
+		return nil, &ReauthError{
+			Code: ReauthErrorCodeTokenStore,
+			Err:  errToken,
+		}
+	}
+
+	if deps.SessionDelete != nil && principal.SessionID != "" {
+		if errDelete := deps.SessionDelete(ctx, principal.SessionID); errDelete != nil {
+			return nil, &ReauthError{
+				Code: ReauthErrorCodeTokenStore,
+				Err:  errDelete,
+			}
+		}
+	}
+
+	return &ReauthResult{Token: token}, nil
+}
+
+// reauthPasswordCheck checks the credentials of the signed in user, and the
+// TOTP code when two-factor authentication is enabled for them, returning
+// the number of factors checked. Wrong passwords and codes count towards
+// the lockouts of the login, and wrong codes are capped on their own as
+// well.
+func reauthPasswordCheck(ctx context.Context, r *http.Request, deps Dependencies, userID string) (types.MFALevel, *ReauthError) {
+	email := req.GetStringTrimmed(r, "email")
+	password := req.GetStringTrimmed(r, "password")
+
+	if email == "" {
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeValidation,
+			Message: "Email is required field",
+		}
+	}
+
+	if password == "" {
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeValidation,
+			Message: "Password is required field",
+		}
+	}
+
+	if deps.UserLogin == nil {
+		return types.MFALevelUnknown, &ReauthError{
+			Code: ReauthErrorCodeUserLookup,
+			Err:  errors.New("user login is not configured"),
+		}
+	}
+
+	if deps.AccountLocked != nil && deps.AccountLocked(email) {
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeLocked,
+			Message: core.AccountLockedMessage,
+		}
+	}
+
+	// Credentials of another account are as wrong as a wrong password
+	loginUserID, errLogin := deps.UserLogin(ctx, email, password)
+	if errLogin != nil || loginUserID != userID {
+		if deps.AccountFailed != nil {
+			if errLockout := deps.AccountFailed(ctx, email); errLockout != nil {
+				return types.MFALevelUnknown, &ReauthError{
+					Code: ReauthErrorCodeCodeStore,
+					Err:  errLockout,
+				}
+			}
+		}
+
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeInvalid,
+			Message: "Invalid credentials",
+			Err:     errLogin,
+		}
+	}
+
+	if deps.AccountReset != nil {
+		if errReset := deps.AccountReset(email); errReset != nil {
+			return types.MFALevelUnknown, &ReauthError{
+				Code: ReauthErrorCodeCodeStore,
+				Err:  errReset,
+			}
+		}
+	}
+
+	if deps.UserTotpSecretFind == nil {
+		return types.MFALevelSingleFactor, nil
+	}
+
+	secret, errSecret := deps.UserTotpSecretFind(ctx, userID)
+	if errSecret != nil {
+		return types.MFALevelUnknown, &ReauthError{
+			Code: ReauthErrorCodeUserLookup,
+			Err:  errSecret,
+		}
+	}
+
+	if secret == "" {
+		return types.MFALevelSingleFactor, nil
+	}
+
+	code := req.GetStringTrimmed(r, "code")
+	if code == "" {
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeValidation,
+			Message: "Code is required field",
+		}
+	}
+
+	if len(code) != utils.TotpDigits || !str.ContainsOnly(code, "0123456789") {
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeValidation,
+			Message: "Code must be 6 digits",
+		}
+	}
+
+	attemptsKey := reauthTotpAttemptsKeyPrefix + userID
+	attempts := reauthTotpAttempts(deps, attemptsKey)
+
+	if attempts >= core.MaxVerificationAttempts || (deps.TwoFactorLocked != nil && deps.TwoFactorLocked(userID)) {
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeLocked,
+			Message: core.AccountLockedMessage,
+		}
+	}
+
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+
+	if !utils.ValidateTotpCode(secret, code, now()) {
+		if deps.TwoFactorFailed != nil {
+			if errLockout := deps.TwoFactorFailed(ctx, userID); errLockout != nil {
+				return types.MFALevelUnknown, &ReauthError{
+					Code: ReauthErrorCodeCodeStore,
+					Err:  errLockout,
+				}
+			}
+		}
+
+		if deps.TemporaryKeySet != nil {
+			if errSet := deps.TemporaryKeySet(attemptsKey, strconv.Itoa(attempts+1), reauthTotpAttemptsExpiresSeconds); errSet != nil {
+				return types.MFALevelUnknown, &ReauthError{
+					Code: ReauthErrorCodeCodeStore,
+					Err:  errSet,
+				}
+			}
+		}
+
+		return types.MFALevelUnknown, &ReauthError{
+			Code:    ReauthErrorCodeInvalid,
+			Message: "Invalid two-factor code",
+		}
+	}
+
+	if attempts > 0 && deps.TemporaryKeyDelete != nil {
+		if errDelete := deps.TemporaryKeyDelete(attemptsKey); errDelete != nil {
+			return types.MFALevelUnknown, &ReauthError{
+				Code: ReauthErrorCodeCodeStore,
+				Err:  errDelete,
+			}
+		}
+	}
+
+	if deps.TwoFactorReset != nil {
+		if errReset := deps.TwoFactorReset(userID); errReset != nil {
+			return types.MFALevelUnknown, &ReauthError{
+				Code: ReauthErrorCodeCodeStore,
+				Err:  errReset,
+			}
+		}
+	}
+
+	return types.MFALevelMultiFactor, nil
+}
+
+// reauthTotpAttempts returns the wrong TOTP codes recently entered by the
+// user. Stores report unknown keys as errors.
+func reauthTotpAttempts(deps Dependencies, key string) int {
+	if deps.TemporaryKeyGet == nil {
+		return 0
+	}
+
+	value, err := deps.TemporaryKeyGet(key)
+	if err != nil {
+		return 0
+	}
+
+	attempts, _ := strconv.Atoi(value)
+	return attempts
+}
+
+// reauthCodeSend sends a new code to the email address or phone number of
+// the signed in user, which they enter to confirm it is theirs.
+func reauthCodeSend(ctx context.Context, r *http.Request, deps Dependencies, userID string) *ReauthError {
+	sms := deps.Channel == types.PasswordlessChannelSms
+
+	recipient := req.GetStringTrimmed(r, "email")
+	find := deps.UserFindByEmail
+	if sms {
+		recipient = req.GetStringTrimmed(r, "phone")
+		find = deps.UserFindByPhone
+	}
+
+	if recipient == "" {
+		message := "Email is required field"
+		if sms {
+			message = "Phone number is required field"
+		}
+		return &ReauthError{
+			Code:    ReauthErrorCodeValidation,
+			Message: message,
+		}
+	}
+
+	message := utils.ValidateEmailFormat(recipient)
+	if sms {
+		message = utils.ValidatePhoneFormat(recipient)
+		recipient = utils.NormalizePhone(recipient)
+	}
+
+	if message != "" {
+		return &ReauthError{
+			Code:    ReauthErrorCodeValidation,
+			Message: message,
+		}
+	}
+
+	if find == nil {
+		return &ReauthError{
+			Code: ReauthErrorCodeUserLookup,
+			Err:  errors.New("user lookup is not configured"),
+		}
+	}
+
+	// Codes are only sent to the signed in user, without telling whether
+	// the recipient belongs to another account
+	recipientUserID, errFind := find(ctx, recipient)
+	if errFind != nil || recipientUserID != userID {
+		return &ReauthError{
+			Code:    ReauthErrorCodeInvalid,
+			Message: "This is not the email address or phone number of your account",
+			Err:     errFind,
+		}
+	}
+
+	generate := utils.GenerateVerificationCode
+	if sms {
+		generate = utils.GeneratePhoneVerificationCode
+	}
+
+	verificationCode, errCode := generate(deps.DisableRateLimit)
+	if errCode != nil {
+		return &ReauthError{
+			Code: ReauthErrorCodeCodeGeneration,
+			Err:  errCode,
+		}
+	}
+
+	if deps.TemporaryKeySet == nil {
+		return &ReauthError{
+			Code: ReauthErrorCodeCodeStore,
+			Err:  errors.New("temporary key store is not configured"),
+		}
+	}
+
+	if errSet := deps.TemporaryKeySet(ReauthKeyPrefix+userID, verificationCode, reauthCodeExpiresSeconds); errSet != nil {
+		return &ReauthError{
+			Code: ReauthErrorCodeCodeStore,
+			Err:  errSet,
+		}
+	}
+
+	var errSend error
+	switch {
+	case sms && deps.SmsTemplate != nil && deps.SmsSend != nil:
+		errSend = deps.SmsSend(ctx, recipient, deps.SmsTemplate(ctx, recipient, verificationCode))
+	case !sms && deps.EmailTemplate != nil && deps.EmailSend != nil:
+		errSend = deps.EmailSend(ctx, recipient, "Verification Code", deps.EmailTemplate(ctx, recipient, verificationCode))
+	default:
+		errSend = errors.New("code template or sender is not configured")
+	}
+
+	if errSend != nil {
+		return &ReauthError{
+			Code: ReauthErrorCodeSend,
+			Err:  errSend,
+		}
+	}
+
+	return nil
+}
+
+// reauthCodeCheck checks the code sent to the signed in user, and consumes
+// it when it is valid. Wrong codes count against the code, which is dropped
+// once it runs out of attempts.
+func reauthCodeCheck(deps Dependencies, userID string, verificationCode string) *ReauthError {
+	key := ReauthKeyPrefix + userID
+
+	if deps.TemporaryKeyGet == nil {
+		return &ReauthError{
+			Code:    ReauthErrorCodeCodeExpired,
+			Message: "Verification code has expired",
+			Err:     errors.New("temporary key store is not configured"),
+		}
+	}
+
+	expected, errGet := deps.TemporaryKeyGet(key)
+	if errGet != nil || expected == "" {
+		return &ReauthError{
+			Code:    ReauthErrorCodeCodeExpired,
+			Message: "Verification code has expired",
+			Err:     errGet,
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(verificationCode)) != 1 {
+		message := "Verification code is invalid"
+		if deps.VerificationAttemptFailed != nil {
+			exhausted, errAttempt := deps.VerificationAttemptFailed(key)
+			if errAttempt != nil {
+				return &ReauthError{
+					Code: ReauthErrorCodeCodeStore,
+					Err:  errAttempt,
+				}
+			}
+			if exhausted {
+				message = "Too many invalid codes. Please request a new code"
+			}
+		}
+
+		return &ReauthError{
+			Code:    ReauthErrorCodeInvalid,
+			Message: message,
+		}
+	}
+
+	if deps.TemporaryKeyDelete != nil {
+		if errDelete := deps.TemporaryKeyDelete(key); errDelete != nil {
+			return &ReauthError{
+				Code: ReauthErrorCodeCodeStore,
+				Err:  errDelete,
+			}
+		}
+	}
+
+	return nil
+}
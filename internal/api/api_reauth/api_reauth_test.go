@@ -0,0 +1,343 @@
+package api_reauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+)
+
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// reauthTestState records what the dependencies were called with
+type reauthTestState struct {
+	store    map[string]string
+	sent     []string
+	issued   []string
+	deleted  []string
+	mfaLevel types.MFALevel
+	method   types.AuthMethod
+}
+
+func newTestDeps(state *reauthTestState, principal *types.Principal) Dependencies {
+	state.store = map[string]string{}
+
+	return Dependencies{
+		CurrentPrincipal: func(r *http.Request) *types.Principal {
+			return principal
+		},
+		UserLogin: func(ctx context.Context, username string, password string) (string, error) {
+			if password != "secret" {
+				return "", errors.New("invalid password")
+			}
+			return map[string]string{"one@test.com": "user-1", "two@test.com": "user-2"}[username], nil
+		},
+		UserTotpSecretFind: func(ctx context.Context, userID string) (string, error) {
+			return "", nil
+		},
+		UserFindByEmail: func(ctx context.Context, email string) (string, error) {
+			return map[string]string{"one@test.com": "user-1", "two@test.com": "user-2"}[email], nil
+		},
+		TemporaryKeyGet: func(key string) (string, error) {
+			v, ok := state.store[key]
+			if !ok {
+				return "", errors.New("not found")
+			}
+			return v, nil
+		},
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			state.store[key] = value
+			return nil
+		},
+		TemporaryKeyDelete: func(key string) error {
+			delete(state.store, key)
+			return nil
+		},
+		EmailTemplate: func(ctx context.Context, email string, verificationCode string) string {
+			return verificationCode
+		},
+		EmailSend: func(ctx context.Context, email string, subject string, body string) error {
+			state.sent = append(state.sent, email+":"+body)
+			return nil
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string, method types.AuthMethod, mfaLevel types.MFALevel) (string, error) {
+			state.issued = append(state.issued, userID)
+			state.method, state.mfaLevel = method, mfaLevel
+			return "token-for-" + userID, nil
+		},
+		SessionDelete: func(ctx context.Context, sessionID string) error {
+			state.deleted = append(state.deleted, sessionID)
+			return nil
+		},
+	}
+}
+
+func TestApiReauthPassword(t *testing.T) {
+	state := &reauthTestState{}
+	deps := newTestDeps(state, &types.Principal{UserID: "user-1", SessionID: "session-1"})
+
+	recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{
+		"email":    {"one@test.com"},
+		"password": {"secret"},
+	})
+	ApiReauth(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) || !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected the new token, got %s", body)
+	}
+	if state.method != types.AuthMethodPassword || state.mfaLevel != types.MFALevelSingleFactor {
+		t.Fatalf("expected a single factor password sign in, got %q %d", state.method, state.mfaLevel)
+	}
+	if len(state.deleted) != 1 || state.deleted[0] != "session-1" {
+		t.Fatalf("expected the replaced session to be ended, got %v", state.deleted)
+	}
+}
+
+func TestApiReauthPasswordRejectsOtherCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		form    url.Values
+		message string
+	}{
+		{"missing email", url.Values{"password": {"secret"}}, "Email is required field"},
+		{"missing password", url.Values{"email": {"one@test.com"}}, "Password is required field"},
+		{"wrong password", url.Values{"email": {"one@test.com"}, "password": {"wrong"}}, "Invalid credentials"},
+		{"another account", url.Values{"email": {"two@test.com"}, "password": {"secret"}}, "Invalid credentials"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &reauthTestState{}
+			deps := newTestDeps(state, &types.Principal{UserID: "user-1", SessionID: "session-1"})
+
+			recorder, req := testutils.MakePostRequest(t, "/api/reauth", tt.form)
+			ApiReauth(recorder, req, deps)
+
+			if body := recorder.Body.String(); !strings.Contains(body, `"message":"`+tt.message+`"`) {
+				t.Fatalf("expected %q, got %s", tt.message, body)
+			}
+			if len(state.issued) != 0 || len(state.deleted) != 0 {
+				t.Fatalf("expected the session to be kept, got %v %v", state.issued, state.deleted)
+			}
+		})
+	}
+}
+
+func TestApiReauthPasswordWithTwoFactor(t *testing.T) {
+	state := &reauthTestState{}
+	deps := newTestDeps(state, &types.Principal{UserID: "user-1", SessionID: "session-1"})
+	deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+		return testSecret, nil
+	}
+	now := time.Now()
+	deps.Now = func() time.Time { return now }
+
+	code, err := utils.TotpCode(testSecret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		code    string
+		message string
+	}{
+		{"", "Code is required field"},
+		{"12ab56", "Code must be 6 digits"},
+		{wrongTotpCode(code), "Invalid two-factor code"},
+	} {
+		recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{
+			"email":    {"one@test.com"},
+			"password": {"secret"},
+			"code":     {tt.code},
+		})
+		ApiReauth(recorder, req, deps)
+
+		if body := recorder.Body.String(); !strings.Contains(body, `"message":"`+tt.message+`"`) {
+			t.Fatalf("code %q: expected %q, got %s", tt.code, tt.message, body)
+		}
+	}
+
+	recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{
+		"email":    {"one@test.com"},
+		"password": {"secret"},
+		"code":     {code},
+	})
+	ApiReauth(recorder, req, deps)
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected the new token, got %s", body)
+	}
+	if state.mfaLevel != types.MFALevelMultiFactor {
+		t.Fatalf("expected a multi-factor sign in, got %d", state.mfaLevel)
+	}
+}
+
+func TestApiReauthPasswordless(t *testing.T) {
+	state := &reauthTestState{}
+	deps := newTestDeps(state, &types.Principal{UserID: "user-1", SessionID: "session-1"})
+	deps.Passwordless = true
+	deps.DisableRateLimit = true
+
+	// Codes are only sent to the signed in user
+	recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{"email": {"two@test.com"}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"error"`) || len(state.sent) != 0 {
+		t.Fatalf("expected no code for another account, got %s %v", body, state.sent)
+	}
+
+	recorder, req = testutils.MakePostRequest(t, "/api/reauth", url.Values{"email": {"one@test.com"}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Verification code was sent successfully"`) {
+		t.Fatalf("expected the code to be sent, got %s", body)
+	}
+
+	code := state.store[ReauthKeyPrefix+"user-1"]
+	if code == "" || len(state.sent) != 1 || state.sent[0] != "one@test.com:"+code {
+		t.Fatalf("expected the stored code to be sent, got %q %v", code, state.sent)
+	}
+
+	recorder, req = testutils.MakePostRequest(t, "/api/reauth", url.Values{"verification_code": {"WRONG"}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Verification code is invalid"`) {
+		t.Fatalf("expected an invalid code, got %s", body)
+	}
+
+	recorder, req = testutils.MakePostRequest(t, "/api/reauth", url.Values{"verification_code": {code}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected the new token, got %s", body)
+	}
+	if state.method != types.AuthMethodCode {
+		t.Fatalf("expected a code sign in, got %q", state.method)
+	}
+
+	// The code cannot be used twice
+	recorder, req = testutils.MakePostRequest(t, "/api/reauth", url.Values{"verification_code": {code}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Verification code has expired"`) {
+		t.Fatalf("expected the used code to be rejected, got %s", body)
+	}
+}
+
+func TestApiReauthRejectsImpersonationAndAnonymous(t *testing.T) {
+	state := &reauthTestState{}
+	deps := newTestDeps(state, &types.Principal{UserID: "user-1", ImpersonatorID: "admin-1"})
+
+	recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{"email": {"one@test.com"}, "password": {"secret"}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"forbidden"`) {
+		t.Fatalf("expected impersonation sessions to be refused, got %s", body)
+	}
+
+	deps = newTestDeps(state, nil)
+	recorder, req = testutils.MakePostRequest(t, "/api/reauth", url.Values{"email": {"one@test.com"}, "password": {"secret"}})
+	ApiReauth(recorder, req, deps)
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected anonymous requests to be refused, got %s", body)
+	}
+
+	if len(state.issued) != 0 {
+		t.Fatalf("expected no token, got %v", state.issued)
+	}
+}
+
+// wrongTotpCode returns a well formed code different from code
+func wrongTotpCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func TestApiReauthPasswordCountsTowardsLockout(t *testing.T) {
+	state := &reauthTestState{}
+	deps := newTestDeps(state, &types.Principal{UserID: "user-1", SessionID: "session-1"})
+
+	failures := map[string]int{}
+	deps.AccountLocked = func(account string) bool { return failures[account] >= 2 }
+	deps.AccountFailed = func(ctx context.Context, account string) error {
+		failures[account]++
+		return nil
+	}
+	deps.AccountReset = func(account string) error {
+		delete(failures, account)
+		return nil
+	}
+
+	post := func(password string) string {
+		recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{
+			"email":    {"one@test.com"},
+			"password": {password},
+		})
+		ApiReauth(recorder, req, deps)
+		return recorder.Body.String()
+	}
+
+	if body := post("wrong"); !strings.Contains(body, `"message":"Invalid credentials"`) {
+		t.Fatalf("expected invalid credentials, got %s", body)
+	}
+	if body := post("secret"); !strings.Contains(body, `"token":"token-for-user-1"`) || len(failures) != 0 {
+		t.Fatalf("expected the failures to be reset on success, got %s %v", body, failures)
+	}
+
+	post("wrong")
+	post("wrong")
+	if body := post("secret"); !strings.Contains(body, `"message":"Too many failed attempts. Please try again later"`) {
+		t.Fatalf("expected the locked account to be refused, got %s", body)
+	}
+}
+
+func TestApiReauthTwoFactorAttemptsAreCapped(t *testing.T) {
+	state := &reauthTestState{}
+	deps := newTestDeps(state, &types.Principal{UserID: "user-1", SessionID: "session-1"})
+	deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+		return testSecret, nil
+	}
+	now := time.Now()
+	deps.Now = func() time.Time { return now }
+
+	recorded := 0
+	deps.TwoFactorFailed = func(ctx context.Context, userID string) error {
+		recorded++
+		return nil
+	}
+
+	code, err := utils.TotpCode(testSecret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(code string) string {
+		recorder, req := testutils.MakePostRequest(t, "/api/reauth", url.Values{
+			"email":    {"one@test.com"},
+			"password": {"secret"},
+			"code":     {code},
+		})
+		ApiReauth(recorder, req, deps)
+		return recorder.Body.String()
+	}
+
+	for range 5 {
+		if body := post(wrongTotpCode(code)); !strings.Contains(body, `"message":"Invalid two-factor code"`) {
+			t.Fatalf("expected an invalid code, got %s", body)
+		}
+	}
+	if recorded != 5 {
+		t.Fatalf("expected every wrong code to count towards the lockout, got %d", recorded)
+	}
+
+	if body := post(code); !strings.Contains(body, `"message":"Too many failed attempts. Please try again later"`) {
+		t.Fatalf("expected the right code to be refused once capped, got %s", body)
+	}
+	if len(state.issued) != 0 {
+		t.Fatalf("expected no token to be issued, got %v", state.issued)
+	}
+}
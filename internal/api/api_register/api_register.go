@@ -39,6 +39,7 @@ func ApiRegister(w http.ResponseWriter, r *http.Request, deps Dependencies) {
 			}
 		}
 
+		core.RateLimitSucceeded(r.Context())
 		api.Respond(w, r, api.Success(result.SuccessMessage))
 		return
 	}
@@ -61,6 +62,7 @@ func ApiRegister(w http.ResponseWriter, r *http.Request, deps Dependencies) {
 		return
 	}
 
+	core.RateLimitSucceeded(r.Context())
 	api.Respond(w, r, api.Success(successMessage))
 }
 
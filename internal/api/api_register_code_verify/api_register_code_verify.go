@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
 	types "github.com/dracory/auth/types"
 	authutils "github.com/dracory/auth/utils"
 	"github.com/dracory/req"
@@ -20,6 +22,10 @@ type Dependencies struct {
 
 	TemporaryKeyGet func(key string) (string, error)
 
+	// TemporaryKeyDelete consumes the code once it is accepted, so it cannot
+	// be used again.
+	TemporaryKeyDelete func(key string) error
+
 	PasswordStrength *types.PasswordStrengthConfig
 
 	Passwordless bool
@@ -27,6 +33,12 @@ type Dependencies struct {
 	PasswordlessUserRegister func(ctx context.Context, email, firstName, lastName string) error
 	UserRegister             func(ctx context.Context, email, password, firstName, lastName string) error
 
+	// UserRegisterWithPasswordHash, when set, is used instead of UserRegister.
+	// Registrations pending since before it was configured still carry the
+	// plaintext password, which is hashed with PasswordHash first.
+	UserRegisterWithPasswordHash func(ctx context.Context, email, passwordHash, firstName, lastName string) error
+	PasswordHash                 func(password string) (string, error)
+
 	// AuthenticateViaUsername is called on successful registration to
 	// authenticate the user and produce the final HTTP response.
 	AuthenticateViaUsername func(w http.ResponseWriter, r *http.Request, email, firstName, lastName string)
@@ -40,6 +52,7 @@ const (
 	RegisterCodeVerifyErrorCodeNone               RegisterCodeVerifyErrorCode = ""
 	RegisterCodeVerifyErrorCodeValidation         RegisterCodeVerifyErrorCode = "validation"
 	RegisterCodeVerifyErrorCodeCodeExpired        RegisterCodeVerifyErrorCode = "code_expired"
+	RegisterCodeVerifyErrorCodeCodeConsume        RegisterCodeVerifyErrorCode = "code_consume"
 	RegisterCodeVerifyErrorCodeDeserialize        RegisterCodeVerifyErrorCode = "deserialize"
 	RegisterCodeVerifyErrorCodePasswordValidation RegisterCodeVerifyErrorCode = "password_validation"
 	RegisterCodeVerifyErrorCodeRegister           RegisterCodeVerifyErrorCode = "register"
@@ -108,6 +121,8 @@ func ApiRegisterCodeVerify(w http.ResponseWriter, r *http.Request, deps Dependen
 		return
 	}
 
+	core.RateLimitSucceeded(r.Context())
+
 	// Delegate final authentication and response to the provided callback so
 	// that existing behaviour (including token generation and cookies) is
 	// preserved.
@@ -122,6 +137,9 @@ func ApiRegisterCodeVerifyWithAuth(w http.ResponseWriter, r *http.Request, a typ
 	deps := Dependencies{
 		DisableRateLimit: a.GetDisableRateLimit(),
 		TemporaryKeyGet:  a.GetFuncTemporaryKeyGet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
 		PasswordStrength: a.GetPasswordStrength(),
 		Passwordless:     a.IsPasswordless(),
 	}
@@ -144,8 +162,21 @@ func ApiRegisterCodeVerifyWithAuth(w http.ResponseWriter, r *http.Request, a typ
 		}
 	}
 
+	if fn := a.GetFuncUserRegisterWithPasswordHash(); fn != nil {
+		deps.UserRegisterWithPasswordHash = func(ctx context.Context, email, passwordHash, firstName, lastName string) error {
+			return fn(ctx, email, passwordHash, firstName, lastName, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+		}
+		deps.PasswordHash = func(password string) (string, error) {
+			return core.PasswordHash(a, password)
+		}
+	}
+
 	deps.AuthenticateViaUsername = func(w http.ResponseWriter, r *http.Request, email, firstName, lastName string) {
-		a.AuthenticateViaUsername(w, r, email, firstName, lastName)
+		ctx := core.ContextWithLogin(r.Context(), types.AuthMethodCode, types.MFALevelSingleFactor, time.Now())
+		a.AuthenticateViaUsername(w, r.WithContext(ctx), email, firstName, lastName)
 	}
 
 	ApiRegisterCodeVerify(w, r, deps)
@@ -187,7 +218,7 @@ func RegisterCodeVerify(ctx context.Context, r *http.Request, deps Dependencies)
 	}
 
 	registerJSON, errCode := deps.TemporaryKeyGet(verificationCode)
-	if errCode != nil {
+	if errCode != nil || registerJSON == "" {
 		return nil, &RegisterCodeVerifyError{
 			Code:    RegisterCodeVerifyErrorCodeCodeExpired,
 			Message: "Verification code has expired",
@@ -234,6 +265,24 @@ func RegisterCodeVerify(ctx context.Context, r *http.Request, deps Dependencies)
 		}
 	}
 
+	passwordHash := ""
+	if val, ok := registerMap["password_hash"]; ok {
+		if s, ok := val.(string); ok {
+			passwordHash = s
+		}
+	}
+
+	// Consume the code before registering, so concurrent requests with the
+	// same code cannot register twice
+	if deps.TemporaryKeyDelete != nil {
+		if errDelete := deps.TemporaryKeyDelete(verificationCode); errDelete != nil {
+			return nil, &RegisterCodeVerifyError{
+				Code: RegisterCodeVerifyErrorCodeCodeConsume,
+				Err:  errDelete,
+			}
+		}
+	}
+
 	// Perform registration
 	var errRegister error
 
@@ -245,6 +294,16 @@ func RegisterCodeVerify(ctx context.Context, r *http.Request, deps Dependencies)
 			}
 		}
 		errRegister = deps.PasswordlessUserRegister(ctx, email, firstName, lastName)
+	} else if passwordHash != "" {
+		// The password strength was validated before it was hashed
+		if deps.UserRegisterWithPasswordHash == nil {
+			return nil, &RegisterCodeVerifyError{
+				Code: RegisterCodeVerifyErrorCodeRegister,
+				Err:  errors.New("user register with password hash function is not configured"),
+			}
+		}
+
+		errRegister = deps.UserRegisterWithPasswordHash(ctx, email, passwordHash, firstName, lastName)
 	} else {
 		// Username/password flow with strength validation
 		if deps.PasswordStrength != nil {
@@ -256,14 +315,24 @@ func RegisterCodeVerify(ctx context.Context, r *http.Request, deps Dependencies)
 			}
 		}
 
-		if deps.UserRegister == nil {
+		switch {
+		case deps.UserRegisterWithPasswordHash != nil && deps.PasswordHash != nil:
+			hash, errHash := deps.PasswordHash(password)
+			if errHash != nil {
+				return nil, &RegisterCodeVerifyError{
+					Code: RegisterCodeVerifyErrorCodeRegister,
+					Err:  errHash,
+				}
+			}
+			errRegister = deps.UserRegisterWithPasswordHash(ctx, email, hash, firstName, lastName)
+		case deps.UserRegister != nil:
+			errRegister = deps.UserRegister(ctx, email, password, firstName, lastName)
+		default:
 			return nil, &RegisterCodeVerifyError{
 				Code: RegisterCodeVerifyErrorCodeRegister,
 				Err:  errors.New("user register function is not configured"),
 			}
 		}
-
-		errRegister = deps.UserRegister(ctx, email, password, firstName, lastName)
 	}
 
 	if errRegister != nil {
@@ -278,3 +278,87 @@ func TestApiRegisterCodeVerifySuccess(t *testing.T) {
 		t.Fatalf("expected token in response, got %q", body)
 	}
 }
+
+func TestRegisterCodeVerifyWithPasswordHash(t *testing.T) {
+	var registeredHash string
+	deps := Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			return `{"email":"test@test.com","first_name":"John","last_name":"Doe","password_hash":"hashed-1234"}`, nil
+		},
+		UserRegister: func(ctx context.Context, email, password, firstName, lastName string) error {
+			t.Fatal("expected UserRegister not to be called")
+			return nil
+		},
+		UserRegisterWithPasswordHash: func(ctx context.Context, email, passwordHash, firstName, lastName string) error {
+			registeredHash = passwordHash
+			return nil
+		},
+	}
+
+	_, req := makePostRequest(t, "/api/register-code-verify", url.Values{"verification_code": {"BCDFGHJK"}})
+	result, perr := RegisterCodeVerify(context.Background(), req, deps)
+	if perr != nil {
+		t.Fatalf("unexpected error %v", perr)
+	}
+	if result.Email != "test@test.com" || registeredHash != "hashed-1234" {
+		t.Fatalf("expected the stored hash to be registered, got %q for %+v", registeredHash, result)
+	}
+}
+
+func TestRegisterCodeVerifyWithPasswordHash_PendingPlaintext(t *testing.T) {
+	var registeredHash string
+	deps := Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			return `{"email":"test@test.com","first_name":"John","last_name":"Doe","password":"1234"}`, nil
+		},
+		UserRegisterWithPasswordHash: func(ctx context.Context, email, passwordHash, firstName, lastName string) error {
+			registeredHash = passwordHash
+			return nil
+		},
+		PasswordHash: func(password string) (string, error) {
+			return "hashed-" + password, nil
+		},
+	}
+
+	_, req := makePostRequest(t, "/api/register-code-verify", url.Values{"verification_code": {"BCDFGHJK"}})
+	if _, perr := RegisterCodeVerify(context.Background(), req, deps); perr != nil {
+		t.Fatalf("unexpected error %v", perr)
+	}
+	if registeredHash != "hashed-1234" {
+		t.Fatalf("expected the pending password to be hashed, got %q", registeredHash)
+	}
+}
+
+func TestRegisterCodeVerifyConsumesCode(t *testing.T) {
+	store := map[string]string{"BCDFGHJK": `{"email":"test@test.com","first_name":"John","last_name":"Doe"}`}
+	registered := 0
+	deps := Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			return store[key], nil
+		},
+		TemporaryKeyDelete: func(key string) error {
+			delete(store, key)
+			return nil
+		},
+		Passwordless: true,
+		PasswordlessUserRegister: func(ctx context.Context, email, firstName, lastName string) error {
+			registered++
+			return nil
+		},
+	}
+
+	values := url.Values{"verification_code": {"BCDFGHJK"}}
+
+	_, req := makePostRequest(t, "/api/register-code-verify", values)
+	if _, perr := RegisterCodeVerify(context.Background(), req, deps); perr != nil {
+		t.Fatalf("unexpected error %v", perr)
+	}
+
+	_, req = makePostRequest(t, "/api/register-code-verify", values)
+	if _, perr := RegisterCodeVerify(context.Background(), req, deps); perr == nil || perr.Code != RegisterCodeVerifyErrorCodeCodeExpired {
+		t.Fatalf("expected the used code to be rejected, got %v", perr)
+	}
+	if registered != 1 {
+		t.Fatalf("expected a single registration, got %d", registered)
+	}
+}
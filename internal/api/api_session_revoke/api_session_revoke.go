@@ -0,0 +1,192 @@
+package api_session_revoke
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for revoking one of the
+// sessions of the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// CurrentSessionID returns the ID of the session the request is
+	// authenticated with.
+	CurrentSessionID func(r *http.Request) string
+
+	// SessionsList returns the sessions of the user.
+	SessionsList func(ctx context.Context, userID string) ([]types.Session, error)
+
+	// SessionDelete ends the session with the ID.
+	SessionDelete func(ctx context.Context, sessionID string) error
+}
+
+// SessionRevokeErrorCode categorizes error sources.
+type SessionRevokeErrorCode string
+
+const (
+	SessionRevokeErrorCodeNone            SessionRevokeErrorCode = ""
+	SessionRevokeErrorCodeUnauthenticated SessionRevokeErrorCode = "unauthenticated"
+	SessionRevokeErrorCodeValidation      SessionRevokeErrorCode = "validation"
+	SessionRevokeErrorCodeNotFound        SessionRevokeErrorCode = "not_found"
+	SessionRevokeErrorCodeCurrent         SessionRevokeErrorCode = "current"
+	SessionRevokeErrorCodeList            SessionRevokeErrorCode = "list"
+	SessionRevokeErrorCodeDelete          SessionRevokeErrorCode = "delete"
+)
+
+// SessionRevokeError represents a structured error in the session
+// revocation flow.
+type SessionRevokeError struct {
+	Code    SessionRevokeErrorCode
+	Message string
+	Err     error
+}
+
+func (e *SessionRevokeError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiSessionRevoke is the HTTP-level helper that wires request/response
+// handling to the core SessionRevoke business logic using the provided
+// dependencies.
+func ApiSessionRevoke(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	sessionID := req.GetStringTrimmed(r, "session_id")
+
+	perr := SessionRevoke(r.Context(), r, sessionID, deps)
+	if perr != nil {
+		switch perr.Code {
+		case SessionRevokeErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case SessionRevokeErrorCodeValidation,
+			SessionRevokeErrorCodeNotFound,
+			SessionRevokeErrorCodeCurrent:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.Success("session revoked"))
+}
+
+// ApiSessionRevokeWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiSessionRevokeWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+		CurrentSessionID: func(r *http.Request) string {
+			token := utils.AuthTokenRetrieve(r, a.GetUseCookies())
+			sessionID, err := core.SessionIDFromAuthToken(r.Context(), a, token, options)
+			if err != nil {
+				return ""
+			}
+			return sessionID
+		},
+		SessionsList: func(ctx context.Context, userID string) ([]types.Session, error) {
+			return core.SessionsList(ctx, a, userID, options)
+		},
+		SessionDelete: func(ctx context.Context, sessionID string) error {
+			return core.SessionDelete(ctx, a, sessionID, options)
+		},
+	}
+
+	ApiSessionRevoke(w, r, deps)
+}
+
+// SessionRevoke ends the session of the authenticated user with the public
+// ID. Sessions of other users are never found, and the current session has
+// to be ended with logout instead. It does not write HTTP responses.
+func SessionRevoke(ctx context.Context, r *http.Request, publicID string, deps Dependencies) *SessionRevokeError {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return &SessionRevokeError{
+			Code:    SessionRevokeErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if publicID == "" {
+		return &SessionRevokeError{
+			Code:    SessionRevokeErrorCodeValidation,
+			Message: "Session ID is required field",
+		}
+	}
+
+	if deps.SessionsList == nil || deps.SessionDelete == nil {
+		return &SessionRevokeError{
+			Code: SessionRevokeErrorCodeList,
+			Err:  errors.New("sessions are not configured"),
+		}
+	}
+
+	sessions, errList := deps.SessionsList(ctx, userID)
+	if errList != nil {
+		return &SessionRevokeError{
+			Code: SessionRevokeErrorCodeList,
+			Err:  errList,
+		}
+	}
+
+	currentSessionID := ""
+	if deps.CurrentSessionID != nil {
+		currentSessionID = deps.CurrentSessionID(r)
+	}
+
+	for _, session := range sessions {
+		if core.SessionPublicID(session.ID) != publicID {
+			continue
+		}
+
+		if session.ID == currentSessionID {
+			return &SessionRevokeError{
+				Code:    SessionRevokeErrorCodeCurrent,
+				Message: "This is your current session. Log out to end it",
+			}
+		}
+
+		if errDelete := deps.SessionDelete(ctx, session.ID); errDelete != nil {
+			return &SessionRevokeError{
+				Code: SessionRevokeErrorCodeDelete,
+				Err:  errDelete,
+			}
+		}
+
+		return nil
+	}
+
+	return &SessionRevokeError{
+		Code:    SessionRevokeErrorCodeNotFound,
+		Message: "Session not found",
+	}
+}
@@ -0,0 +1,89 @@
+package api_session_revoke
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(store *testutils.SessionStore) Dependencies {
+	return Dependencies{
+		CurrentUserID:    func(r *http.Request) string { return "user-1" },
+		CurrentSessionID: func(r *http.Request) string { return "token-1" },
+		SessionsList:     store.ListForUser,
+		SessionDelete:    store.Delete,
+	}
+}
+
+func newTestStore() *testutils.SessionStore {
+	store := testutils.NewSessionStore()
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1"}
+	store.Sessions["token-2"] = types.Session{ID: "token-2", UserID: "user-1"}
+	store.Sessions["token-3"] = types.Session{ID: "token-3", UserID: "user-2"}
+	return store
+}
+
+func TestApiSessionRevokeRevokesSession(t *testing.T) {
+	store := newTestStore()
+
+	recorder, req := testutils.MakePostRequest(t, "/api/session-revoke", url.Values{
+		"session_id": {core.SessionPublicID("token-2")},
+	})
+	ApiSessionRevoke(recorder, req, newTestDeps(store))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"session revoked"`) {
+		t.Fatalf("expected success, got %q", body)
+	}
+	if _, ok := store.Sessions["token-2"]; ok {
+		t.Fatal("expected the session to be deleted")
+	}
+	if len(store.Sessions) != 2 {
+		t.Fatalf("expected the other sessions to be kept, got %d", len(store.Sessions))
+	}
+}
+
+func TestApiSessionRevokeRejectsOtherUsersSession(t *testing.T) {
+	store := newTestStore()
+
+	recorder, req := testutils.MakePostRequest(t, "/api/session-revoke", url.Values{
+		"session_id": {core.SessionPublicID("token-3")},
+	})
+	ApiSessionRevoke(recorder, req, newTestDeps(store))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Session not found"`) {
+		t.Fatalf("expected session not found, got %q", body)
+	}
+	if _, ok := store.Sessions["token-3"]; !ok {
+		t.Fatal("expected the session of the other user to be kept")
+	}
+}
+
+func TestApiSessionRevokeRejectsCurrentSession(t *testing.T) {
+	store := newTestStore()
+
+	recorder, req := testutils.MakePostRequest(t, "/api/session-revoke", url.Values{
+		"session_id": {core.SessionPublicID("token-1")},
+	})
+	ApiSessionRevoke(recorder, req, newTestDeps(store))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"This is your current session. Log out to end it"`) {
+		t.Fatalf("expected the current session to be refused, got %q", body)
+	}
+	if _, ok := store.Sessions["token-1"]; !ok {
+		t.Fatal("expected the current session to be kept")
+	}
+}
+
+func TestApiSessionRevokeRequiresSessionID(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/session-revoke", url.Values{})
+	ApiSessionRevoke(recorder, req, newTestDeps(newTestStore()))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Session ID is required field"`) {
+		t.Fatalf("expected validation error, got %q", body)
+	}
+}
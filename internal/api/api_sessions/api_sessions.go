@@ -0,0 +1,168 @@
+package api_sessions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for listing the sessions
+// of the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// CurrentSessionID returns the ID of the session the request is
+	// authenticated with.
+	CurrentSessionID func(r *http.Request) string
+
+	// SessionsList returns the sessions of the user.
+	SessionsList func(ctx context.Context, userID string) ([]types.Session, error)
+}
+
+// SessionsErrorCode categorizes error sources.
+type SessionsErrorCode string
+
+const (
+	SessionsErrorCodeNone            SessionsErrorCode = ""
+	SessionsErrorCodeUnauthenticated SessionsErrorCode = "unauthenticated"
+	SessionsErrorCodeList            SessionsErrorCode = "list"
+)
+
+// SessionsError represents a structured error in the sessions listing flow.
+type SessionsError struct {
+	Code    SessionsErrorCode
+	Message string
+	Err     error
+}
+
+func (e *SessionsError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// SessionInfo is a session as shown to its user. The session ID is the auth
+// token, so sessions are identified by their public ID instead.
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	UserIp     string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// ApiSessions is the HTTP-level helper that wires request/response handling
+// to the core Sessions business logic using the provided dependencies.
+func ApiSessions(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	sessions, perr := Sessions(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case SessionsErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("sessions found", map[string]any{
+		"sessions": sessions,
+	}))
+}
+
+// ApiSessionsWithAuth is a convenience wrapper that allows callers to pass a
+// types.AuthSharedInterface (such as authImplementation) instead of manually
+// wiring Dependencies.
+func ApiSessionsWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+		CurrentSessionID: func(r *http.Request) string {
+			token := utils.AuthTokenRetrieve(r, a.GetUseCookies())
+			sessionID, err := core.SessionIDFromAuthToken(r.Context(), a, token, options)
+			if err != nil {
+				return ""
+			}
+			return sessionID
+		},
+		SessionsList: func(ctx context.Context, userID string) ([]types.Session, error) {
+			return core.SessionsList(ctx, a, userID, options)
+		},
+	}
+
+	ApiSessions(w, r, deps)
+}
+
+// Sessions lists the sessions of the authenticated user, marking the one
+// the request is made with. It does not write HTTP responses.
+func Sessions(ctx context.Context, r *http.Request, deps Dependencies) ([]SessionInfo, *SessionsError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &SessionsError{
+			Code:    SessionsErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if deps.SessionsList == nil {
+		return nil, &SessionsError{
+			Code: SessionsErrorCodeList,
+			Err:  errors.New("sessions are not configured"),
+		}
+	}
+
+	sessions, errList := deps.SessionsList(ctx, userID)
+	if errList != nil {
+		return nil, &SessionsError{
+			Code: SessionsErrorCodeList,
+			Err:  errList,
+		}
+	}
+
+	currentSessionID := ""
+	if deps.CurrentSessionID != nil {
+		currentSessionID = deps.CurrentSessionID(r)
+	}
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, SessionInfo{
+			ID:         core.SessionPublicID(session.ID),
+			Device:     core.SessionDevice(session.UserAgent),
+			UserIp:     session.UserIp,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    currentSessionID != "" && session.ID == currentSessionID,
+		})
+	}
+
+	return infos, nil
+}
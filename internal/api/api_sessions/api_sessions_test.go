@@ -0,0 +1,65 @@
+package api_sessions
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(userID string) Dependencies {
+	now := time.Now()
+
+	return Dependencies{
+		CurrentUserID:    func(r *http.Request) string { return userID },
+		CurrentSessionID: func(r *http.Request) string { return "token-1" },
+		SessionsList: func(ctx context.Context, userID string) ([]types.Session, error) {
+			return []types.Session{
+				{ID: "token-1", UserID: userID, UserIp: "10.0.0.1", UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0", LastSeenAt: now},
+				{ID: "token-2", UserID: userID, UserIp: "10.0.0.2", LastSeenAt: now.Add(-time.Hour)},
+			}, nil
+		},
+	}
+}
+
+func TestApiSessionsRequiresUser(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/sessions", url.Values{})
+	ApiSessions(recorder, req, newTestDeps(""))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected unauthenticated response, got %q", body)
+	}
+}
+
+func TestApiSessionsListsSessions(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/sessions", url.Values{})
+	ApiSessions(recorder, req, newTestDeps("user-1"))
+
+	body := recorder.Body.String()
+
+	expected := []string{
+		`"id":"` + core.SessionPublicID("token-1") + `"`,
+		`"device":"Firefox on Linux"`,
+		`"ip":"10.0.0.1"`,
+		`"current":true`,
+		`"id":"` + core.SessionPublicID("token-2") + `"`,
+		`"device":"Unknown device"`,
+		`"current":false`,
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("expected %s in response, got %q", v, body)
+		}
+	}
+
+	if strings.Contains(body, "token-1") || strings.Contains(body, "token-2") {
+		t.Fatalf("expected the auth tokens not to be exposed, got %q", body)
+	}
+}
@@ -0,0 +1,181 @@
+package api_sessions_revoke_others
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for revoking every session
+// of the authenticated user except the current one.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// CurrentSessionID returns the ID of the session the request is
+	// authenticated with.
+	CurrentSessionID func(r *http.Request) string
+
+	// SessionsList returns the sessions of the user.
+	SessionsList func(ctx context.Context, userID string) ([]types.Session, error)
+
+	// SessionDelete ends the session with the ID.
+	SessionDelete func(ctx context.Context, sessionID string) error
+}
+
+// RevokeOthersErrorCode categorizes error sources.
+type RevokeOthersErrorCode string
+
+const (
+	RevokeOthersErrorCodeNone            RevokeOthersErrorCode = ""
+	RevokeOthersErrorCodeUnauthenticated RevokeOthersErrorCode = "unauthenticated"
+	RevokeOthersErrorCodeCurrent         RevokeOthersErrorCode = "current"
+	RevokeOthersErrorCodeList            RevokeOthersErrorCode = "list"
+	RevokeOthersErrorCodeDelete          RevokeOthersErrorCode = "delete"
+)
+
+// RevokeOthersError represents a structured error in the flow revoking the
+// other sessions.
+type RevokeOthersError struct {
+	Code    RevokeOthersErrorCode
+	Message string
+	Err     error
+}
+
+func (e *RevokeOthersError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiSessionsRevokeOthers is the HTTP-level helper that wires
+// request/response handling to the core SessionsRevokeOthers business logic
+// using the provided dependencies.
+func ApiSessionsRevokeOthers(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	revoked, perr := SessionsRevokeOthers(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case RevokeOthersErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case RevokeOthersErrorCodeCurrent:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("other sessions revoked", map[string]any{
+		"revoked": revoked,
+	}))
+}
+
+// ApiSessionsRevokeOthersWithAuth is a convenience wrapper that allows
+// callers to pass a types.AuthSharedInterface (such as authImplementation)
+// instead of manually wiring Dependencies.
+func ApiSessionsRevokeOthersWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+		CurrentSessionID: func(r *http.Request) string {
+			token := utils.AuthTokenRetrieve(r, a.GetUseCookies())
+			sessionID, err := core.SessionIDFromAuthToken(r.Context(), a, token, options)
+			if err != nil {
+				return ""
+			}
+			return sessionID
+		},
+		SessionsList: func(ctx context.Context, userID string) ([]types.Session, error) {
+			return core.SessionsList(ctx, a, userID, options)
+		},
+		SessionDelete: func(ctx context.Context, sessionID string) error {
+			return core.SessionDelete(ctx, a, sessionID, options)
+		},
+	}
+
+	ApiSessionsRevokeOthers(w, r, deps)
+}
+
+// SessionsRevokeOthers ends every session of the authenticated user except
+// the one the request is made with, and returns how many were ended. It
+// does not write HTTP responses.
+func SessionsRevokeOthers(ctx context.Context, r *http.Request, deps Dependencies) (int, *RevokeOthersError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return 0, &RevokeOthersError{
+			Code:    RevokeOthersErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if deps.SessionsList == nil || deps.SessionDelete == nil {
+		return 0, &RevokeOthersError{
+			Code: RevokeOthersErrorCodeList,
+			Err:  errors.New("sessions are not configured"),
+		}
+	}
+
+	sessions, errList := deps.SessionsList(ctx, userID)
+	if errList != nil {
+		return 0, &RevokeOthersError{
+			Code: RevokeOthersErrorCodeList,
+			Err:  errList,
+		}
+	}
+
+	currentSessionID := ""
+	if deps.CurrentSessionID != nil {
+		currentSessionID = deps.CurrentSessionID(r)
+	}
+
+	// Without the current session every session would be "other", which
+	// would sign the caller out as well
+	if currentSessionID == "" {
+		return 0, &RevokeOthersError{
+			Code:    RevokeOthersErrorCodeCurrent,
+			Message: "Your current session could not be found. Please log in again",
+		}
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+
+		if errDelete := deps.SessionDelete(ctx, session.ID); errDelete != nil {
+			return revoked, &RevokeOthersError{
+				Code: RevokeOthersErrorCodeDelete,
+				Err:  errDelete,
+			}
+		}
+
+		revoked++
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,70 @@
+package api_sessions_revoke_others
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestApiSessionsRevokeOthersKeepsCurrentSession(t *testing.T) {
+	store := testutils.NewSessionStore()
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1"}
+	store.Sessions["token-2"] = types.Session{ID: "token-2", UserID: "user-1"}
+	store.Sessions["token-3"] = types.Session{ID: "token-3", UserID: "user-1"}
+	store.Sessions["token-4"] = types.Session{ID: "token-4", UserID: "user-2"}
+
+	recorder, req := testutils.MakePostRequest(t, "/api/sessions-revoke-others", url.Values{})
+	ApiSessionsRevokeOthers(recorder, req, Dependencies{
+		CurrentUserID:    func(r *http.Request) string { return "user-1" },
+		CurrentSessionID: func(r *http.Request) string { return "token-1" },
+		SessionsList:     store.ListForUser,
+		SessionDelete:    store.Delete,
+	})
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"revoked":2`) {
+		t.Fatalf("expected two sessions to be revoked, got %q", body)
+	}
+
+	for _, id := range []string{"token-1", "token-4"} {
+		if _, ok := store.Sessions[id]; !ok {
+			t.Errorf("expected session %s to be kept", id)
+		}
+	}
+	if len(store.Sessions) != 2 {
+		t.Fatalf("expected two sessions to be left, got %d", len(store.Sessions))
+	}
+}
+
+func TestApiSessionsRevokeOthersRequiresUser(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/sessions-revoke-others", url.Values{})
+	ApiSessionsRevokeOthers(recorder, req, Dependencies{})
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected unauthenticated response, got %q", body)
+	}
+}
+
+func TestApiSessionsRevokeOthersRequiresCurrentSession(t *testing.T) {
+	store := testutils.NewSessionStore()
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1"}
+	store.Sessions["token-2"] = types.Session{ID: "token-2", UserID: "user-1"}
+
+	recorder, req := testutils.MakePostRequest(t, "/api/sessions-revoke-others", url.Values{})
+	ApiSessionsRevokeOthers(recorder, req, Dependencies{
+		CurrentUserID:    func(r *http.Request) string { return "user-1" },
+		CurrentSessionID: func(r *http.Request) string { return "" },
+		SessionsList:     store.ListForUser,
+		SessionDelete:    store.Delete,
+	})
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"error"`) {
+		t.Fatalf("expected an error, got %q", body)
+	}
+	if len(store.Sessions) != 2 {
+		t.Fatalf("expected every session to be kept, got %d", len(store.Sessions))
+	}
+}
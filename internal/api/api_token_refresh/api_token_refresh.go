@@ -0,0 +1,298 @@
+package api_token_refresh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for exchanging a refresh
+// token for a new auth token and refresh token.
+type Dependencies struct {
+	RefreshTokenFindByHash   func(ctx context.Context, tokenHash string) (*types.RefreshToken, error)
+	RefreshTokenMarkUsed     func(ctx context.Context, tokenHash string) (bool, error)
+	RefreshTokenFamilyRevoke func(ctx context.Context, familyID string) error
+
+	// RefreshTokenFindByFamily and SessionDelete end the sessions linked to
+	// the tokens of a revoked family. Optional: without them only the tokens
+	// are revoked, e.g. with JWT access tokens.
+	RefreshTokenFindByFamily func(ctx context.Context, familyID string) ([]types.RefreshToken, error)
+	SessionDelete            func(ctx context.Context, sessionID string) error
+
+	// RefreshTokenIssue issues the next refresh token of the family, linked
+	// to the session of the new auth token.
+	RefreshTokenIssue func(ctx context.Context, userID string, familyID string, token string) (string, error)
+
+	// AuthTokenIssue issues a new auth token for the user.
+	AuthTokenIssue func(ctx context.Context, userID string) (string, error)
+
+	UseCookies    bool
+	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	// Now returns the current time. Defaults to time.Now when nil.
+	Now func() time.Time
+}
+
+// TokenRefreshErrorCode categorizes error sources.
+type TokenRefreshErrorCode string
+
+const (
+	TokenRefreshErrorCodeNone          TokenRefreshErrorCode = ""
+	TokenRefreshErrorCodeValidation    TokenRefreshErrorCode = "validation"
+	TokenRefreshErrorCodeInvalidToken  TokenRefreshErrorCode = "invalid_token"
+	TokenRefreshErrorCodeExpired       TokenRefreshErrorCode = "expired"
+	TokenRefreshErrorCodeReuseDetected TokenRefreshErrorCode = "reuse_detected"
+	TokenRefreshErrorCodeTokenLookup   TokenRefreshErrorCode = "token_lookup"
+	TokenRefreshErrorCodeTokenStore    TokenRefreshErrorCode = "token_store"
+)
+
+// TokenRefreshError represents a structured error in the refresh flow.
+type TokenRefreshError struct {
+	Code    TokenRefreshErrorCode
+	Message string
+	Err     error
+}
+
+func (e *TokenRefreshError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// TokenRefreshResult represents a successful refresh.
+type TokenRefreshResult struct {
+	UserID       string
+	Token        string
+	RefreshToken string
+}
+
+// ApiTokenRefresh is the HTTP-level helper that wires request/response
+// handling to the core TokenRefresh business logic using the provided
+// dependencies.
+func ApiTokenRefresh(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, rerr := TokenRefresh(r.Context(), req.GetStringTrimmed(r, "refresh_token"), deps)
+	if rerr != nil {
+		switch rerr.Code {
+		case TokenRefreshErrorCodeValidation:
+			api.Respond(w, r, api.Error(rerr.Message))
+			return
+		case TokenRefreshErrorCodeInvalidToken,
+			TokenRefreshErrorCodeExpired,
+			TokenRefreshErrorCodeReuseDetected:
+			api.Respond(w, r, api.Unauthenticated(rerr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	if deps.UseCookies && deps.SetAuthCookie != nil {
+		deps.SetAuthCookie(w, r, result.Token)
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("token refreshed", map[string]any{
+		"token":         result.Token,
+		"refresh_token": result.RefreshToken,
+	}))
+}
+
+// ApiTokenRefreshWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiTokenRefreshWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	config := a.GetRefreshToken()
+	if config == nil {
+		api.Respond(w, r, api.Error("Refresh tokens are not enabled"))
+		return
+	}
+
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		RefreshTokenFindByHash: func(ctx context.Context, tokenHash string) (*types.RefreshToken, error) {
+			return config.FuncRefreshTokenFindByHash(ctx, tokenHash, options)
+		},
+		RefreshTokenMarkUsed: func(ctx context.Context, tokenHash string) (bool, error) {
+			return config.FuncRefreshTokenMarkUsed(ctx, tokenHash, options)
+		},
+		RefreshTokenFamilyRevoke: func(ctx context.Context, familyID string) error {
+			return config.FuncRefreshTokenFamilyRevoke(ctx, familyID, options)
+		},
+		RefreshTokenFindByFamily: func(ctx context.Context, familyID string) ([]types.RefreshToken, error) {
+			return config.FuncRefreshTokenFindByFamily(ctx, familyID, options)
+		},
+		RefreshTokenIssue: func(ctx context.Context, userID string, familyID string, token string) (string, error) {
+			return core.RefreshTokenIssue(ctx, config, userID, familyID, core.AuthTokenSessionID(a, token), options, time.Now())
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string) (string, error) {
+			// The refresh token is not a sign in, so the token is not a recent one
+			ctx = core.ContextWithLogin(ctx, types.AuthMethodRefreshToken, types.MFALevelUnknown, time.Time{})
+			return core.AuthTokenIssue(ctx, a, userID, options)
+		},
+		UseCookies: a.GetUseCookies(),
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			a.SetAuthCookie(w, r, token)
+		},
+	}
+
+	if store := core.SessionStore(a); store != nil {
+		deps.SessionDelete = func(ctx context.Context, sessionID string) error {
+			return store.Delete(types.ContextWithUserAuthOptions(ctx, options), sessionID)
+		}
+	}
+
+	ApiTokenRefresh(w, r, deps)
+}
+
+// TokenRefresh encapsulates the core business logic for exchanging a refresh
+// token. The token is marked used and a new pair is issued in the same
+// family. A token that was already used means it leaked, as the legitimate
+// client only ever holds the latest one, so the whole family is revoked.
+// It does not write HTTP responses.
+func TokenRefresh(ctx context.Context, refreshToken string, deps Dependencies) (*TokenRefreshResult, *TokenRefreshError) {
+	if refreshToken == "" {
+		return nil, &TokenRefreshError{
+			Code:    TokenRefreshErrorCodeValidation,
+			Message: "Refresh token is required field",
+		}
+	}
+
+	if deps.RefreshTokenFindByHash == nil || deps.RefreshTokenMarkUsed == nil || deps.RefreshTokenFamilyRevoke == nil {
+		return nil, &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenLookup,
+			Err:  errors.New("refresh token store is not configured"),
+		}
+	}
+
+	tokenHash := core.RefreshTokenHash(refreshToken)
+
+	record, errFind := deps.RefreshTokenFindByHash(ctx, tokenHash)
+	if errFind != nil {
+		return nil, &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenLookup,
+			Err:  errFind,
+		}
+	}
+
+	if record == nil || record.UserID == "" {
+		return nil, &TokenRefreshError{
+			Code:    TokenRefreshErrorCodeInvalidToken,
+			Message: "Invalid refresh token",
+		}
+	}
+
+	if record.Used {
+		return nil, familyRevoke(ctx, deps, record.FamilyID)
+	}
+
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+
+	if !now().Before(record.ExpiresAt) {
+		return nil, &TokenRefreshError{
+			Code:    TokenRefreshErrorCodeExpired,
+			Message: "Refresh token has expired. Please log in again",
+		}
+	}
+
+	marked, errMark := deps.RefreshTokenMarkUsed(ctx, tokenHash)
+	if errMark != nil {
+		return nil, &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenStore,
+			Err:  errMark,
+		}
+	}
+
+	if !marked {
+		// Another request exchanged the token in the meantime.
+		return nil, familyRevoke(ctx, deps, record.FamilyID)
+	}
+
+	if deps.RefreshTokenIssue == nil || deps.AuthTokenIssue == nil {
+		return nil, &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenStore,
+			Err:  errors.New("token issuer is not configured"),
+		}
+	}
+
+	token, errToken := deps.AuthTokenIssue(ctx, record.UserID)
+	if errToken != nil {
+		return nil, &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenStore,
+			Err:  errToken,
+		}
+	}
+
+	newRefreshToken, errRefresh := deps.RefreshTokenIssue(ctx, record.UserID, record.FamilyID, token)
+	if errRefresh != nil {
+		return nil, &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenStore,
+			Err:  errRefresh,
+		}
+	}
+
+	return &TokenRefreshResult{
+		UserID:       record.UserID,
+		Token:        token,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+// familyRevoke revokes every token of the family after a reused refresh
+// token was presented, and ends the sessions issued with them, as one of
+// them belongs to whoever copied the token.
+func familyRevoke(ctx context.Context, deps Dependencies, familyID string) *TokenRefreshError {
+	if deps.RefreshTokenFindByFamily != nil && deps.SessionDelete != nil {
+		tokens, errFind := deps.RefreshTokenFindByFamily(ctx, familyID)
+		if errFind != nil {
+			return &TokenRefreshError{
+				Code: TokenRefreshErrorCodeTokenLookup,
+				Err:  errFind,
+			}
+		}
+
+		for _, token := range tokens {
+			if token.SessionID == "" {
+				continue
+			}
+			if errDelete := deps.SessionDelete(ctx, token.SessionID); errDelete != nil {
+				return &TokenRefreshError{
+					Code: TokenRefreshErrorCodeTokenStore,
+					Err:  errDelete,
+				}
+			}
+		}
+	}
+
+	if errRevoke := deps.RefreshTokenFamilyRevoke(ctx, familyID); errRevoke != nil {
+		return &TokenRefreshError{
+			Code: TokenRefreshErrorCodeTokenStore,
+			Err:  errRevoke,
+		}
+	}
+
+	return &TokenRefreshError{
+		Code:    TokenRefreshErrorCodeReuseDetected,
+		Message: "Invalid refresh token",
+	}
+}
@@ -0,0 +1,200 @@
+package api_token_refresh
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(store *testutils.RefreshTokenStore) Dependencies {
+	config := store.Config()
+	options := types.UserAuthOptions{}
+
+	return Dependencies{
+		RefreshTokenFindByHash: func(ctx context.Context, tokenHash string) (*types.RefreshToken, error) {
+			return config.FuncRefreshTokenFindByHash(ctx, tokenHash, options)
+		},
+		RefreshTokenMarkUsed: func(ctx context.Context, tokenHash string) (bool, error) {
+			return config.FuncRefreshTokenMarkUsed(ctx, tokenHash, options)
+		},
+		RefreshTokenFamilyRevoke: func(ctx context.Context, familyID string) error {
+			return config.FuncRefreshTokenFamilyRevoke(ctx, familyID, options)
+		},
+		RefreshTokenIssue: func(ctx context.Context, userID string, familyID string, token string) (string, error) {
+			return core.RefreshTokenIssue(ctx, config, userID, familyID, token, options, time.Now())
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string) (string, error) {
+			return "token-for-" + userID, nil
+		},
+	}
+}
+
+func issueForTest(t *testing.T, store *testutils.RefreshTokenStore) string {
+	t.Helper()
+	token, err := core.RefreshTokenIssue(context.Background(), store.Config(), "user-1", "", "", types.UserAuthOptions{}, time.Now())
+	if err != nil {
+		t.Fatalf("RefreshTokenIssue failed: %v", err)
+	}
+	return token
+}
+
+func TestApiTokenRefreshRequiresToken(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/token/refresh", url.Values{})
+	ApiTokenRefresh(recorder, req, newTestDeps(testutils.NewRefreshTokenStore()))
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Refresh token is required field"`) {
+		t.Fatalf("expected required message, got %q", body)
+	}
+}
+
+func TestApiTokenRefreshRotatesToken(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+	refreshToken := issueForTest(t, store)
+
+	recorder, req := testutils.MakePostRequest(t, "/api/token/refresh", url.Values{
+		"refresh_token": {refreshToken},
+	})
+	ApiTokenRefresh(recorder, req, newTestDeps(store))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) || !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected success with a new token, got %q", body)
+	}
+	if strings.Contains(body, refreshToken) || !strings.Contains(body, `"refresh_token":"`) {
+		t.Fatalf("expected a new refresh token, got %q", body)
+	}
+
+	if !store.Tokens[core.RefreshTokenHash(refreshToken)].Used {
+		t.Fatal("expected the exchanged refresh token to be marked used")
+	}
+	if len(store.Tokens) != 2 {
+		t.Fatalf("expected the rotated token to be stored, got %d tokens", len(store.Tokens))
+	}
+}
+
+func TestTokenRefreshReuseRevokesFamily(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+	deps := newTestDeps(store)
+	stolen := issueForTest(t, store)
+	other := issueForTest(t, store)
+
+	result, rerr := TokenRefresh(context.Background(), stolen, deps)
+	if rerr != nil {
+		t.Fatalf("unexpected error %v", rerr)
+	}
+
+	// The attacker replays the stolen token after the legitimate client rotated it.
+	if _, rerr := TokenRefresh(context.Background(), stolen, deps); rerr == nil || rerr.Code != TokenRefreshErrorCodeReuseDetected {
+		t.Fatalf("expected reuse to be detected, got %v", rerr)
+	}
+
+	// The legitimate client's latest token is revoked with the family.
+	if _, rerr := TokenRefresh(context.Background(), result.RefreshToken, deps); rerr == nil || rerr.Code != TokenRefreshErrorCodeInvalidToken {
+		t.Fatalf("expected the family to be revoked, got %v", rerr)
+	}
+
+	// Other logins are not affected.
+	if _, rerr := TokenRefresh(context.Background(), other, deps); rerr != nil {
+		t.Fatalf("expected other families to remain valid, got %v", rerr)
+	}
+}
+
+func TestTokenRefreshReuseDeletesSessionsOfFamily(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+	config := store.Config()
+	sessions := testutils.NewSessionStore()
+	for _, sessionID := range []string{"session-1", "token-for-user-1", "session-2"} {
+		sessions.Sessions[sessionID] = types.Session{ID: sessionID, UserID: "user-1"}
+	}
+
+	deps := newTestDeps(store)
+	deps.RefreshTokenFindByFamily = func(ctx context.Context, familyID string) ([]types.RefreshToken, error) {
+		return config.FuncRefreshTokenFindByFamily(ctx, familyID, types.UserAuthOptions{})
+	}
+	deps.SessionDelete = sessions.Delete
+
+	stolen, err := core.RefreshTokenIssue(context.Background(), config, "user-1", "", "session-1", types.UserAuthOptions{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := core.RefreshTokenIssue(context.Background(), config, "user-1", "", "session-2", types.UserAuthOptions{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, rerr := TokenRefresh(context.Background(), stolen, deps); rerr != nil {
+		t.Fatalf("unexpected error %v", rerr)
+	}
+	if _, rerr := TokenRefresh(context.Background(), stolen, deps); rerr == nil || rerr.Code != TokenRefreshErrorCodeReuseDetected {
+		t.Fatalf("expected reuse to be detected, got %v", rerr)
+	}
+
+	// Both the original session and the one issued on rotation are gone
+	for _, sessionID := range []string{"session-1", "token-for-user-1"} {
+		if _, ok := sessions.Sessions[sessionID]; ok {
+			t.Fatalf("expected session %s to be deleted", sessionID)
+		}
+	}
+	if _, ok := sessions.Sessions["session-2"]; !ok {
+		t.Fatal("expected the session of another family to remain")
+	}
+	if _, rerr := TokenRefresh(context.Background(), other, deps); rerr != nil {
+		t.Fatalf("expected other families to remain valid, got %v", rerr)
+	}
+}
+
+func TestTokenRefreshConcurrentUseRevokesFamily(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+	deps := newTestDeps(store)
+	refreshToken := issueForTest(t, store)
+
+	// Another request marks the token used between the lookup and the update.
+	deps.RefreshTokenMarkUsed = func(ctx context.Context, tokenHash string) (bool, error) {
+		return false, nil
+	}
+
+	if _, rerr := TokenRefresh(context.Background(), refreshToken, deps); rerr == nil || rerr.Code != TokenRefreshErrorCodeReuseDetected {
+		t.Fatalf("expected reuse to be detected, got %v", rerr)
+	}
+	if len(store.Tokens) != 0 {
+		t.Fatalf("expected the family to be revoked, got %d tokens", len(store.Tokens))
+	}
+}
+
+func TestTokenRefreshRejectsExpiredToken(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+	deps := newTestDeps(store)
+	refreshToken := issueForTest(t, store)
+	deps.Now = func() time.Time { return time.Now().Add(types.DefaultRefreshTokenExpiration + time.Minute) }
+
+	if _, rerr := TokenRefresh(context.Background(), refreshToken, deps); rerr == nil || rerr.Code != TokenRefreshErrorCodeExpired {
+		t.Fatalf("expected expired error, got %v", rerr)
+	}
+}
+
+func TestTokenRefreshRejectsUnknownToken(t *testing.T) {
+	if _, rerr := TokenRefresh(context.Background(), "unknown", newTestDeps(testutils.NewRefreshTokenStore())); rerr == nil || rerr.Code != TokenRefreshErrorCodeInvalidToken {
+		t.Fatalf("expected invalid token error, got %v", rerr)
+	}
+}
+
+func TestTokenRefreshLookupError(t *testing.T) {
+	deps := newTestDeps(testutils.NewRefreshTokenStore())
+	deps.RefreshTokenFindByHash = func(ctx context.Context, tokenHash string) (*types.RefreshToken, error) {
+		return nil, errors.New("db down")
+	}
+
+	recorder, req := testutils.MakePostRequest(t, "/api/token/refresh", url.Values{"refresh_token": {"token"}})
+	ApiTokenRefresh(recorder, req, deps)
+
+	if body := recorder.Body.String(); !strings.Contains(body, "Failed to process request. Please try again later") {
+		t.Fatalf("expected generic error, got %q", body)
+	}
+}
@@ -0,0 +1,185 @@
+package api_two_factor_enroll
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+)
+
+// qrCodeSize is the width and height, in pixels, of the generated QR code.
+const qrCodeSize = 256
+
+// Dependencies defines the dependencies required for starting a TOTP
+// enrollment for the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// GenerateSecret returns a new base32 TOTP secret.
+	GenerateSecret func() (string, error)
+
+	// QrCode renders the provisioning URI as an image data URI.
+	QrCode func(content string) (string, error)
+
+	// Issuer is the name shown in authenticator apps. Defaults to the
+	// request host when empty.
+	Issuer string
+}
+
+// TwoFactorEnrollErrorCode categorizes error sources.
+type TwoFactorEnrollErrorCode string
+
+const (
+	TwoFactorEnrollErrorCodeNone            TwoFactorEnrollErrorCode = ""
+	TwoFactorEnrollErrorCodeUnauthenticated TwoFactorEnrollErrorCode = "unauthenticated"
+	TwoFactorEnrollErrorCodeSecretGenerate  TwoFactorEnrollErrorCode = "secret_generate"
+	TwoFactorEnrollErrorCodeStore           TwoFactorEnrollErrorCode = "store"
+	TwoFactorEnrollErrorCodeQrCode          TwoFactorEnrollErrorCode = "qr_code"
+)
+
+// TwoFactorEnrollError represents a structured error in the enrollment flow.
+type TwoFactorEnrollError struct {
+	Code    TwoFactorEnrollErrorCode
+	Message string
+	Err     error
+}
+
+func (e *TwoFactorEnrollError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// TwoFactorEnrollResult contains everything the user needs to add the
+// account to an authenticator app.
+type TwoFactorEnrollResult struct {
+	Secret          string
+	ProvisioningURI string
+	QrCode          string
+}
+
+// ApiTwoFactorEnroll is the HTTP-level helper that wires request/response
+// handling to the core TwoFactorEnroll business logic using the provided
+// dependencies.
+func ApiTwoFactorEnroll(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, perr := TwoFactorEnroll(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case TwoFactorEnrollErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("two-factor enrollment started", map[string]any{
+		"secret":           result.Secret,
+		"provisioning_uri": result.ProvisioningURI,
+		"qr_code":          result.QrCode,
+	}))
+}
+
+// ApiTwoFactorEnrollWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiTwoFactorEnrollWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	deps := Dependencies{
+		CurrentUserID:   a.GetCurrentUserID,
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		GenerateSecret:  utils.GenerateTotpSecret,
+		QrCode: func(content string) (string, error) {
+			return utils.QrCodeDataURI(content, qrCodeSize)
+		},
+		Issuer: a.GetTotpIssuer(),
+	}
+
+	ApiTwoFactorEnroll(w, r, deps)
+}
+
+// TwoFactorEnroll generates a new TOTP secret for the authenticated user and
+// keeps it as pending in the temporary key store. The secret is not persisted
+// until the user confirms a code. It does not write HTTP responses.
+func TwoFactorEnroll(ctx context.Context, r *http.Request, deps Dependencies) (*TwoFactorEnrollResult, *TwoFactorEnrollError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &TwoFactorEnrollError{
+			Code:    TwoFactorEnrollErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if deps.GenerateSecret == nil {
+		return nil, &TwoFactorEnrollError{
+			Code: TwoFactorEnrollErrorCodeSecretGenerate,
+			Err:  errors.New("secret generator is not configured"),
+		}
+	}
+
+	secret, errSecret := deps.GenerateSecret()
+	if errSecret != nil {
+		return nil, &TwoFactorEnrollError{
+			Code: TwoFactorEnrollErrorCodeSecretGenerate,
+			Err:  errSecret,
+		}
+	}
+
+	if deps.TemporaryKeySet == nil {
+		return nil, &TwoFactorEnrollError{
+			Code: TwoFactorEnrollErrorCodeStore,
+			Err:  errors.New("temporary key store is not configured"),
+		}
+	}
+
+	if errStore := deps.TemporaryKeySet(core.TwoFactorEnrollKeyPrefix+userID, secret, core.TwoFactorEnrollExpiresSeconds); errStore != nil {
+		return nil, &TwoFactorEnrollError{
+			Code: TwoFactorEnrollErrorCodeStore,
+			Err:  errStore,
+		}
+	}
+
+	issuer := deps.Issuer
+	if issuer == "" {
+		issuer = r.Host
+	}
+
+	provisioningURI := utils.TotpProvisioningURI(issuer, userID, secret)
+
+	qrCode := ""
+	if deps.QrCode != nil {
+		var errQrCode error
+		qrCode, errQrCode = deps.QrCode(provisioningURI)
+		if errQrCode != nil {
+			return nil, &TwoFactorEnrollError{
+				Code: TwoFactorEnrollErrorCodeQrCode,
+				Err:  errQrCode,
+			}
+		}
+	}
+
+	return &TwoFactorEnrollResult{
+		Secret:          secret,
+		ProvisioningURI: provisioningURI,
+		QrCode:          qrCode,
+	}, nil
+}
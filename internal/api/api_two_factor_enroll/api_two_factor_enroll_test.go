@@ -0,0 +1,57 @@
+package api_two_factor_enroll
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func newTestDeps(userID string, store map[string]string) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return userID },
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		},
+		GenerateSecret: func() (string, error) { return "JBSWY3DPEHPK3PXP", nil },
+		QrCode:         func(content string) (string, error) { return "data:image/png;base64,QR", nil },
+		Issuer:         "Acme",
+	}
+}
+
+func TestApiTwoFactorEnrollRequiresUser(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-enroll", url.Values{})
+	ApiTwoFactorEnroll(recorder, req, newTestDeps("", map[string]string{}))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected unauthenticated response, got %q", body)
+	}
+}
+
+func TestApiTwoFactorEnrollStoresPendingSecret(t *testing.T) {
+	store := map[string]string{}
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-enroll", url.Values{})
+	ApiTwoFactorEnroll(recorder, req, newTestDeps("user-1", store))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) {
+		t.Fatalf("expected success, got %q", body)
+	}
+	if !strings.Contains(body, `"secret":"JBSWY3DPEHPK3PXP"`) {
+		t.Fatalf("expected secret in response, got %q", body)
+	}
+	if !strings.Contains(body, `otpauth://totp/Acme:user-1?`) {
+		t.Fatalf("expected provisioning URI in response, got %q", body)
+	}
+	if !strings.Contains(body, `"qr_code":"data:image/png;base64,QR"`) {
+		t.Fatalf("expected QR code in response, got %q", body)
+	}
+	if store[core.TwoFactorEnrollKeyPrefix+"user-1"] != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected pending secret to be stored, got %v", store)
+	}
+}
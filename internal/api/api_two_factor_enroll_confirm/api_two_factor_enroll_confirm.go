@@ -0,0 +1,238 @@
+package api_two_factor_enroll_confirm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+	"github.com/dracory/str"
+)
+
+// Dependencies defines the dependencies required for confirming a pending
+// TOTP enrollment and persisting the secret.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	TemporaryKeyGet func(key string) (string, error)
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// UserTotpSecretStore persists the confirmed secret for the user.
+	UserTotpSecretStore func(ctx context.Context, userID string, secret string) error
+
+	// RecoveryCodesIssue generates and stores a fresh batch of recovery
+	// codes, returning the plain codes. Optional.
+	RecoveryCodesIssue func(ctx context.Context, userID string) ([]string, error)
+
+	// Now returns the current time. Defaults to time.Now when nil.
+	Now func() time.Time
+}
+
+// TwoFactorEnrollConfirmErrorCode categorizes error sources.
+type TwoFactorEnrollConfirmErrorCode string
+
+const (
+	TwoFactorEnrollConfirmErrorCodeNone            TwoFactorEnrollConfirmErrorCode = ""
+	TwoFactorEnrollConfirmErrorCodeUnauthenticated TwoFactorEnrollConfirmErrorCode = "unauthenticated"
+	TwoFactorEnrollConfirmErrorCodeValidation      TwoFactorEnrollConfirmErrorCode = "validation"
+	TwoFactorEnrollConfirmErrorCodeExpired         TwoFactorEnrollConfirmErrorCode = "expired"
+	TwoFactorEnrollConfirmErrorCodeInvalidCode     TwoFactorEnrollConfirmErrorCode = "invalid_code"
+	TwoFactorEnrollConfirmErrorCodeSecretStore     TwoFactorEnrollConfirmErrorCode = "secret_store"
+	TwoFactorEnrollConfirmErrorCodeRecoveryCodes   TwoFactorEnrollConfirmErrorCode = "recovery_codes"
+)
+
+// TwoFactorEnrollConfirmError represents a structured error in the
+// enrollment confirmation flow.
+type TwoFactorEnrollConfirmError struct {
+	Code    TwoFactorEnrollConfirmErrorCode
+	Message string
+	Err     error
+}
+
+func (e *TwoFactorEnrollConfirmError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// TwoFactorEnrollConfirmResult represents a successful enrollment.
+type TwoFactorEnrollConfirmResult struct {
+	// RecoveryCodes are the plain recovery codes, empty when recovery codes
+	// are not configured.
+	RecoveryCodes []string
+}
+
+// ApiTwoFactorEnrollConfirm is the HTTP-level helper that wires
+// request/response handling to the core TwoFactorEnrollConfirm business
+// logic using the provided dependencies.
+func ApiTwoFactorEnrollConfirm(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, perr := TwoFactorEnrollConfirm(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case TwoFactorEnrollConfirmErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case TwoFactorEnrollConfirmErrorCodeValidation,
+			TwoFactorEnrollConfirmErrorCodeExpired,
+			TwoFactorEnrollConfirmErrorCodeInvalidCode:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+
+	if len(result.RecoveryCodes) > 0 {
+		api.Respond(w, r, api.SuccessWithData("two-factor authentication enabled", map[string]any{
+			"recovery_codes": result.RecoveryCodes,
+		}))
+		return
+	}
+
+	api.Respond(w, r, api.Success("two-factor authentication enabled"))
+}
+
+// ApiTwoFactorEnrollConfirmWithAuth is a convenience wrapper that allows
+// callers to pass a types.AuthSharedInterface (such as authImplementation)
+// instead of manually wiring Dependencies.
+func ApiTwoFactorEnrollConfirmWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID:   a.GetCurrentUserID,
+		TemporaryKeyGet: a.GetFuncTemporaryKeyGet(),
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+	}
+
+	if fn := a.GetFuncUserTotpSecretStore(); fn != nil {
+		deps.UserTotpSecretStore = func(ctx context.Context, userID string, secret string) error {
+			return fn(ctx, userID, secret, options)
+		}
+	}
+
+	if a.GetFuncUserRecoveryCodesStore() != nil {
+		deps.RecoveryCodesIssue = func(ctx context.Context, userID string) ([]string, error) {
+			return core.RecoveryCodesIssue(ctx, a, userID, options)
+		}
+	}
+
+	ApiTwoFactorEnrollConfirm(w, r, deps)
+}
+
+// TwoFactorEnrollConfirm checks the submitted code against the pending secret
+// of the authenticated user and, when valid, persists the secret together with
+// a first batch of recovery codes. It does not write HTTP responses.
+func TwoFactorEnrollConfirm(ctx context.Context, r *http.Request, deps Dependencies) (*TwoFactorEnrollConfirmResult, *TwoFactorEnrollConfirmError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code:    TwoFactorEnrollConfirmErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	code := req.GetStringTrimmed(r, "code")
+
+	if code == "" {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code:    TwoFactorEnrollConfirmErrorCodeValidation,
+			Message: "Code is required field",
+		}
+	}
+
+	if len(code) != utils.TotpDigits || !str.ContainsOnly(code, "0123456789") {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code:    TwoFactorEnrollConfirmErrorCodeValidation,
+			Message: "Code must be 6 digits",
+		}
+	}
+
+	if deps.TemporaryKeyGet == nil {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code:    TwoFactorEnrollConfirmErrorCodeExpired,
+			Message: "Two-factor enrollment has expired. Please start again",
+			Err:     errors.New("temporary key store is not configured"),
+		}
+	}
+
+	pendingKey := core.TwoFactorEnrollKeyPrefix + userID
+
+	secret, errPending := deps.TemporaryKeyGet(pendingKey)
+	if errPending != nil || secret == "" {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code:    TwoFactorEnrollConfirmErrorCodeExpired,
+			Message: "Two-factor enrollment has expired. Please start again",
+			Err:     errPending,
+		}
+	}
+
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+
+	if !utils.ValidateTotpCode(secret, code, now()) {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code:    TwoFactorEnrollConfirmErrorCodeInvalidCode,
+			Message: "Invalid two-factor code",
+		}
+	}
+
+	if deps.UserTotpSecretStore == nil {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code: TwoFactorEnrollConfirmErrorCodeSecretStore,
+			Err:  errors.New("TOTP secret store is not configured"),
+		}
+	}
+
+	// Issue recovery codes before the secret is persisted, so a user never
+	// ends up with two-factor enabled and no way to recover the account.
+	result := &TwoFactorEnrollConfirmResult{}
+	if deps.RecoveryCodesIssue != nil {
+		codes, errCodes := deps.RecoveryCodesIssue(ctx, userID)
+		if errCodes != nil {
+			return nil, &TwoFactorEnrollConfirmError{
+				Code: TwoFactorEnrollConfirmErrorCodeRecoveryCodes,
+				Err:  errCodes,
+			}
+		}
+		result.RecoveryCodes = codes
+	}
+
+	if errStore := deps.UserTotpSecretStore(ctx, userID, secret); errStore != nil {
+		return nil, &TwoFactorEnrollConfirmError{
+			Code: TwoFactorEnrollConfirmErrorCodeSecretStore,
+			Err:  errStore,
+		}
+	}
+
+	// The secret is persisted; drop the pending copy.
+	if deps.TemporaryKeySet != nil {
+		_ = deps.TemporaryKeySet(pendingKey, "", 1)
+	}
+
+	return result, nil
+}
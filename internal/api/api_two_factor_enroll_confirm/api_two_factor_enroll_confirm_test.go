@@ -0,0 +1,123 @@
+package api_two_factor_enroll_confirm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/utils"
+)
+
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newTestDeps(store map[string]string, stored map[string]string) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return "user-1" },
+		TemporaryKeyGet: func(key string) (string, error) {
+			v, ok := store[key]
+			if !ok {
+				return "", errors.New("not found")
+			}
+			return v, nil
+		},
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		},
+		UserTotpSecretStore: func(ctx context.Context, userID string, secret string) error {
+			stored[userID] = secret
+			return nil
+		},
+	}
+}
+
+func TestApiTwoFactorEnrollConfirmWithoutPendingEnrollment(t *testing.T) {
+	stored := map[string]string{}
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-enroll-confirm", url.Values{
+		"code": {"123456"},
+	})
+	ApiTwoFactorEnrollConfirm(recorder, req, newTestDeps(map[string]string{}, stored))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Two-factor enrollment has expired. Please start again"`) {
+		t.Fatalf("expected expired message, got %q", body)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("expected no secret to be persisted")
+	}
+}
+
+func TestApiTwoFactorEnrollConfirmInvalidCodeDoesNotPersist(t *testing.T) {
+	store := map[string]string{core.TwoFactorEnrollKeyPrefix + "user-1": testSecret}
+	stored := map[string]string{}
+	now := time.Unix(1700000000, 0)
+	code, _ := utils.TotpCode(testSecret, now.Add(-10*time.Minute))
+
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-enroll-confirm", url.Values{
+		"code": {code},
+	})
+	deps := newTestDeps(store, stored)
+	deps.Now = func() time.Time { return now }
+	ApiTwoFactorEnrollConfirm(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Invalid two-factor code"`) {
+		t.Fatalf("expected invalid code message, got %q", body)
+	}
+	if len(stored) != 0 {
+		t.Fatalf("expected no secret to be persisted")
+	}
+}
+
+func TestApiTwoFactorEnrollConfirmSuccessPersistsSecret(t *testing.T) {
+	store := map[string]string{core.TwoFactorEnrollKeyPrefix + "user-1": testSecret}
+	stored := map[string]string{}
+	now := time.Unix(1700000000, 0)
+	code, _ := utils.TotpCode(testSecret, now)
+
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-enroll-confirm", url.Values{
+		"code": {code},
+	})
+	deps := newTestDeps(store, stored)
+	deps.Now = func() time.Time { return now }
+	ApiTwoFactorEnrollConfirm(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"two-factor authentication enabled"`) {
+		t.Fatalf("expected success message, got %q", body)
+	}
+	if stored["user-1"] != testSecret {
+		t.Fatalf("expected secret to be persisted, got %v", stored)
+	}
+	if store[core.TwoFactorEnrollKeyPrefix+"user-1"] != "" {
+		t.Fatalf("expected pending secret to be cleared")
+	}
+}
+
+func TestApiTwoFactorEnrollConfirmReturnsRecoveryCodes(t *testing.T) {
+	store := map[string]string{core.TwoFactorEnrollKeyPrefix + "user-1": testSecret}
+	stored := map[string]string{}
+	now := time.Unix(1700000000, 0)
+	code, _ := utils.TotpCode(testSecret, now)
+
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-enroll-confirm", url.Values{
+		"code": {code},
+	})
+	deps := newTestDeps(store, stored)
+	deps.Now = func() time.Time { return now }
+	deps.RecoveryCodesIssue = func(ctx context.Context, userID string) ([]string, error) {
+		return []string{"BCDF-GHJK-LMNP-QRST"}, nil
+	}
+	ApiTwoFactorEnrollConfirm(recorder, req, deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"recovery_codes":["BCDF-GHJK-LMNP-QRST"]`) {
+		t.Fatalf("expected recovery codes in response, got %q", body)
+	}
+}
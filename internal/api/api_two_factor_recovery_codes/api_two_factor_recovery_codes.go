@@ -0,0 +1,160 @@
+package api_two_factor_recovery_codes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for regenerating the
+// recovery codes of the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	// UserTotpSecretFind returns the user's TOTP secret. When set, codes are
+	// only issued to users that have two-factor authentication enabled.
+	UserTotpSecretFind func(ctx context.Context, userID string) (string, error)
+
+	// RecoveryCodesIssue generates and stores a fresh batch of recovery
+	// codes, invalidating the previous ones, and returns the plain codes.
+	RecoveryCodesIssue func(ctx context.Context, userID string) ([]string, error)
+}
+
+// RecoveryCodesErrorCode categorizes error sources.
+type RecoveryCodesErrorCode string
+
+const (
+	RecoveryCodesErrorCodeNone            RecoveryCodesErrorCode = ""
+	RecoveryCodesErrorCodeUnauthenticated RecoveryCodesErrorCode = "unauthenticated"
+	RecoveryCodesErrorCodeNotEnabled      RecoveryCodesErrorCode = "not_enabled"
+	RecoveryCodesErrorCodeSecretLookup    RecoveryCodesErrorCode = "secret_lookup"
+	RecoveryCodesErrorCodeIssue           RecoveryCodesErrorCode = "issue"
+)
+
+// RecoveryCodesError represents a structured error in the recovery codes
+// regeneration flow.
+type RecoveryCodesError struct {
+	Code    RecoveryCodesErrorCode
+	Message string
+	Err     error
+}
+
+func (e *RecoveryCodesError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiTwoFactorRecoveryCodes is the HTTP-level helper that wires
+// request/response handling to the core RecoveryCodesRegenerate business
+// logic using the provided dependencies.
+func ApiTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	codes, perr := RecoveryCodesRegenerate(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case RecoveryCodesErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case RecoveryCodesErrorCodeNotEnabled:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("recovery codes generated", map[string]any{
+		"recovery_codes": codes,
+	}))
+}
+
+// ApiTwoFactorRecoveryCodesWithAuth is a convenience wrapper that allows
+// callers to pass a types.AuthSharedInterface (such as authImplementation)
+// instead of manually wiring Dependencies.
+func ApiTwoFactorRecoveryCodesWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		CurrentUserID: a.GetCurrentUserID,
+		RecoveryCodesIssue: func(ctx context.Context, userID string) ([]string, error) {
+			return core.RecoveryCodesIssue(ctx, a, userID, options)
+		},
+	}
+
+	if fn := a.GetFuncUserTotpSecretFind(); fn != nil {
+		deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+			return fn(ctx, userID, options)
+		}
+	}
+
+	ApiTwoFactorRecoveryCodes(w, r, deps)
+}
+
+// RecoveryCodesRegenerate replaces the recovery codes of the authenticated
+// user with a new batch and returns the plain codes. It does not write HTTP
+// responses.
+func RecoveryCodesRegenerate(ctx context.Context, r *http.Request, deps Dependencies) ([]string, *RecoveryCodesError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &RecoveryCodesError{
+			Code:    RecoveryCodesErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	if deps.UserTotpSecretFind != nil {
+		secret, errSecret := deps.UserTotpSecretFind(ctx, userID)
+		if errSecret != nil {
+			return nil, &RecoveryCodesError{
+				Code: RecoveryCodesErrorCodeSecretLookup,
+				Err:  errSecret,
+			}
+		}
+
+		if secret == "" {
+			return nil, &RecoveryCodesError{
+				Code:    RecoveryCodesErrorCodeNotEnabled,
+				Message: "Two-factor authentication is not enabled",
+			}
+		}
+	}
+
+	if deps.RecoveryCodesIssue == nil {
+		return nil, &RecoveryCodesError{
+			Code: RecoveryCodesErrorCodeIssue,
+			Err:  errors.New("recovery codes are not configured"),
+		}
+	}
+
+	codes, errIssue := deps.RecoveryCodesIssue(ctx, userID)
+	if errIssue != nil {
+		return nil, &RecoveryCodesError{
+			Code: RecoveryCodesErrorCodeIssue,
+			Err:  errIssue,
+		}
+	}
+
+	return codes, nil
+}
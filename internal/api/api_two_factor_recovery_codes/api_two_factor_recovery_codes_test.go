@@ -0,0 +1,52 @@
+package api_two_factor_recovery_codes
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func newTestDeps(secret string, issued *int) Dependencies {
+	return Dependencies{
+		CurrentUserID: func(r *http.Request) string { return "user-1" },
+		UserTotpSecretFind: func(ctx context.Context, userID string) (string, error) {
+			return secret, nil
+		},
+		RecoveryCodesIssue: func(ctx context.Context, userID string) ([]string, error) {
+			*issued++
+			return []string{"BCDF-GHJK-LMNP-QRST", "VXYZ-BCDF-GHJK-LMNP"}, nil
+		},
+	}
+}
+
+func TestApiTwoFactorRecoveryCodesRequiresTwoFactor(t *testing.T) {
+	issued := 0
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-recovery-codes", url.Values{})
+	ApiTwoFactorRecoveryCodes(recorder, req, newTestDeps("", &issued))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"message":"Two-factor authentication is not enabled"`) {
+		t.Fatalf("expected not enabled message, got %q", body)
+	}
+	if issued != 0 {
+		t.Fatalf("expected no codes to be issued")
+	}
+}
+
+func TestApiTwoFactorRecoveryCodesRegenerates(t *testing.T) {
+	issued := 0
+	recorder, req := testutils.MakePostRequest(t, "/api/2fa-recovery-codes", url.Values{})
+	ApiTwoFactorRecoveryCodes(recorder, req, newTestDeps("JBSWY3DPEHPK3PXP", &issued))
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"recovery_codes":["BCDF-GHJK-LMNP-QRST","VXYZ-BCDF-GHJK-LMNP"]`) {
+		t.Fatalf("expected recovery codes in response, got %q", body)
+	}
+	if issued != 1 {
+		t.Fatalf("expected codes to be issued once, got %d", issued)
+	}
+}
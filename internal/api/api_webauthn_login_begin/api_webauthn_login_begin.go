@@ -0,0 +1,78 @@
+package api_webauthn_login_begin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+)
+
+// loginChallengePending is the value stored for a pending login challenge.
+// Logins use discoverable credentials, so no user is known yet.
+const loginChallengePending = "pending"
+
+// Dependencies defines the dependencies required for starting a passkey
+// login.
+type Dependencies struct {
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	RelyingParty webauthn.RelyingParty
+
+	// NewChallenge defaults to webauthn.NewChallenge when nil.
+	NewChallenge func() (string, error)
+}
+
+// ApiWebAuthnLoginBegin creates a login challenge and responds with the
+// PublicKeyCredentialRequestOptions for navigator.credentials.get().
+func ApiWebAuthnLoginBegin(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	options, err := LoginBegin(deps)
+	if err != nil {
+		api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+		return
+	}
+
+	api.Respond(w, r, api.SuccessWithData("passkey login started", map[string]any{
+		"public_key": options,
+	}))
+}
+
+// ApiWebAuthnLoginBeginWithAuth is a convenience wrapper that allows callers
+// to pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiWebAuthnLoginBeginWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	ApiWebAuthnLoginBegin(w, r, Dependencies{
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		RelyingParty:    webauthn.RelyingPartyFromConfig(a.GetWebAuthn()),
+	})
+}
+
+// LoginBegin stores a new login challenge and returns the request options.
+// It does not write HTTP responses.
+func LoginBegin(deps Dependencies) (map[string]any, error) {
+	newChallenge := webauthn.NewChallenge
+	if deps.NewChallenge != nil {
+		newChallenge = deps.NewChallenge
+	}
+
+	challenge, err := newChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	if deps.TemporaryKeySet == nil {
+		return nil, errors.New("temporary key store is not configured")
+	}
+
+	if err := deps.TemporaryKeySet(webauthn.LoginChallengeKeyPrefix+challenge, loginChallengePending, webauthn.ChallengeExpiresSeconds); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"challenge":        challenge,
+		"rpId":             deps.RelyingParty.ID,
+		"timeout":          webauthn.ChallengeExpiresSeconds * 1000,
+		"userVerification": "preferred",
+	}, nil
+}
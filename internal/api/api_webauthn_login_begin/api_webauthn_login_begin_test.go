@@ -0,0 +1,31 @@
+package api_webauthn_login_begin
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/internal/webauthn"
+)
+
+func TestApiWebAuthnLoginBeginStoresChallenge(t *testing.T) {
+	store := map[string]string{}
+	recorder, req := testutils.MakePostRequest(t, "/api/webauthn-login-begin", url.Values{})
+	ApiWebAuthnLoginBegin(recorder, req, Dependencies{
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		},
+		RelyingParty: webauthn.RelyingParty{ID: "example.com"},
+		NewChallenge: func() (string, error) { return "challenge-1", nil },
+	})
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"challenge":"challenge-1"`) || !strings.Contains(body, `"rpId":"example.com"`) {
+		t.Fatalf("expected request options in response, got %q", body)
+	}
+	if store[webauthn.LoginChallengeKeyPrefix+"challenge-1"] == "" {
+		t.Fatalf("expected pending login challenge to be stored, got %v", store)
+	}
+}
@@ -0,0 +1,317 @@
+package api_webauthn_login_finish
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for completing a passkey
+// login.
+type Dependencies struct {
+	TemporaryKeyGet func(key string) (string, error)
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// TemporaryKeyDelete consumes the challenge. Optional: without it the
+	// challenge is overwritten with an empty value.
+	TemporaryKeyDelete func(key string) error
+
+	RelyingParty webauthn.RelyingParty
+
+	// CredentialFindByID returns nil when the credential is not registered.
+	CredentialFindByID        func(ctx context.Context, credentialID string) (*types.WebAuthnCredential, error)
+	CredentialSignCountUpdate func(ctx context.Context, credentialID string, signCount uint32) error
+
+	// UserTotpSecretFind returns the base32 TOTP secret of the user, or an
+	// empty string when two-factor authentication is not enabled. Users
+	// with a secret must verify the passkey with a PIN or biometric.
+	// Optional.
+	UserTotpSecretFind func(ctx context.Context, userID string) (string, error)
+
+	// AuthTokenIssue issues and stores the auth token for the user.
+	AuthTokenIssue func(ctx context.Context, userID string) (string, error)
+
+	UseCookies    bool
+	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+}
+
+// LoginFinishErrorCode categorizes error sources.
+type LoginFinishErrorCode string
+
+const (
+	LoginFinishErrorCodeNone             LoginFinishErrorCode = ""
+	LoginFinishErrorCodeValidation       LoginFinishErrorCode = "validation"
+	LoginFinishErrorCodeChallengeExpired LoginFinishErrorCode = "challenge_expired"
+	LoginFinishErrorCodeCredentialFind   LoginFinishErrorCode = "credential_find"
+	LoginFinishErrorCodeUnknownPasskey   LoginFinishErrorCode = "unknown_passkey"
+	LoginFinishErrorCodeVerification     LoginFinishErrorCode = "verification"
+	LoginFinishErrorCodeSignCount        LoginFinishErrorCode = "sign_count"
+	LoginFinishErrorCodeUserVerification LoginFinishErrorCode = "user_verification"
+	LoginFinishErrorCodeSecretLookup     LoginFinishErrorCode = "secret_lookup"
+	LoginFinishErrorCodeTokenStore       LoginFinishErrorCode = "token_store"
+)
+
+// LoginFinishError represents a structured error in the passkey login.
+type LoginFinishError struct {
+	Code    LoginFinishErrorCode
+	Message string
+	Err     error
+}
+
+func (e *LoginFinishError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// LoginFinishResult represents a successful passkey login.
+type LoginFinishResult struct {
+	UserID string
+	Token  string
+}
+
+// ApiWebAuthnLoginFinish is the HTTP-level helper that wires request/response
+// handling to the core LoginFinish business logic using the provided
+// dependencies.
+func ApiWebAuthnLoginFinish(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, perr := LoginFinish(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case LoginFinishErrorCodeValidation,
+			LoginFinishErrorCodeChallengeExpired,
+			LoginFinishErrorCodeUnknownPasskey,
+			LoginFinishErrorCodeVerification,
+			LoginFinishErrorCodeSignCount,
+			LoginFinishErrorCodeUserVerification:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+
+	if deps.UseCookies && deps.SetAuthCookie != nil {
+		deps.SetAuthCookie(w, r, result.Token)
+	}
+
+	api.Respond(w, r, api.SuccessWithData("login success", map[string]any{
+		"token": result.Token,
+	}))
+}
+
+// ApiWebAuthnLoginFinishWithAuth is a convenience wrapper that allows callers
+// to pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func ApiWebAuthnLoginFinishWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	config := a.GetWebAuthn()
+
+	deps := Dependencies{
+		TemporaryKeyGet: a.GetFuncTemporaryKeyGet(),
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
+		RelyingParty: webauthn.RelyingPartyFromConfig(config),
+		AuthTokenIssue: func(ctx context.Context, userID string) (string, error) {
+			return core.AuthTokenIssue(ctx, a, userID, options)
+		},
+		UseCookies: a.GetUseCookies(),
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			a.SetAuthCookie(w, r, token)
+		},
+	}
+
+	if config != nil {
+		deps.CredentialFindByID = func(ctx context.Context, credentialID string) (*types.WebAuthnCredential, error) {
+			return config.FuncCredentialFindByID(ctx, credentialID, options)
+		}
+		deps.CredentialSignCountUpdate = func(ctx context.Context, credentialID string, signCount uint32) error {
+			return config.FuncCredentialSignCountUpdate(ctx, credentialID, signCount, options)
+		}
+	}
+
+	if a.GetFuncUserTotpSecretFind() != nil {
+		deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+			return core.TwoFactorSecretFind(ctx, a, userID, options)
+		}
+	}
+
+	ApiWebAuthnLoginFinish(w, r, deps)
+}
+
+// LoginFinish verifies the assertion returned by navigator.credentials.get()
+// against the pending challenge and the stored credential, updates the
+// signature counter and issues an auth token. It does not write HTTP
+// responses.
+func LoginFinish(ctx context.Context, r *http.Request, deps Dependencies) (*LoginFinishResult, *LoginFinishError) {
+	credentialID := req.GetStringTrimmed(r, "credential_id")
+	clientDataJSON, errClientData := webauthn.DecodeBase64URL(req.GetStringTrimmed(r, "client_data_json"))
+	authenticatorData, errAuthData := webauthn.DecodeBase64URL(req.GetStringTrimmed(r, "authenticator_data"))
+	signature, errSignature := webauthn.DecodeBase64URL(req.GetStringTrimmed(r, "signature"))
+
+	if credentialID == "" || errClientData != nil || errAuthData != nil || errSignature != nil ||
+		len(clientDataJSON) == 0 || len(authenticatorData) == 0 || len(signature) == 0 {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeValidation,
+			Message: "Passkey response is required",
+		}
+	}
+
+	challenge, errChallenge := webauthn.ChallengeFromClientData(clientDataJSON)
+	if errChallenge != nil {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeValidation,
+			Message: "Passkey response is invalid",
+			Err:     errChallenge,
+		}
+	}
+
+	if deps.TemporaryKeyGet == nil {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeChallengeExpired,
+			Message: "Passkey login has expired. Please try again",
+			Err:     errors.New("temporary key store is not configured"),
+		}
+	}
+
+	challengeKey := webauthn.LoginChallengeKeyPrefix + challenge
+
+	pending, errGet := deps.TemporaryKeyGet(challengeKey)
+	if errGet != nil || pending == "" {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeChallengeExpired,
+			Message: "Passkey login has expired. Please try again",
+			Err:     errGet,
+		}
+	}
+
+	// Challenges are single use, whatever the outcome of the verification.
+	if deps.TemporaryKeyDelete != nil {
+		if errConsume := deps.TemporaryKeyDelete(challengeKey); errConsume != nil {
+			return nil, &LoginFinishError{
+				Code: LoginFinishErrorCodeTokenStore,
+				Err:  errConsume,
+			}
+		}
+	} else if deps.TemporaryKeySet != nil {
+		if errConsume := deps.TemporaryKeySet(challengeKey, "", 1); errConsume != nil {
+			return nil, &LoginFinishError{
+				Code: LoginFinishErrorCodeTokenStore,
+				Err:  errConsume,
+			}
+		}
+	}
+
+	if deps.CredentialFindByID == nil {
+		return nil, &LoginFinishError{
+			Code: LoginFinishErrorCodeCredentialFind,
+			Err:  errors.New("credential lookup is not configured"),
+		}
+	}
+
+	credential, errFind := deps.CredentialFindByID(ctx, credentialID)
+	if errFind != nil {
+		return nil, &LoginFinishError{
+			Code: LoginFinishErrorCodeCredentialFind,
+			Err:  errFind,
+		}
+	}
+
+	if credential == nil || credential.UserID == "" {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeUnknownPasskey,
+			Message: "Passkey is not registered",
+		}
+	}
+
+	signCount, errVerify := webauthn.VerifyAssertion(deps.RelyingParty, challenge, credential.PublicKey, credential.SignCount, clientDataJSON, authenticatorData, signature)
+	if errors.Is(errVerify, webauthn.ErrSignCount) {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeSignCount,
+			Message: "Passkey verification failed",
+			Err:     errVerify,
+		}
+	}
+	if errVerify != nil {
+		return nil, &LoginFinishError{
+			Code:    LoginFinishErrorCodeVerification,
+			Message: "Passkey verification failed",
+			Err:     errVerify,
+		}
+	}
+
+	if deps.CredentialSignCountUpdate != nil {
+		if errUpdate := deps.CredentialSignCountUpdate(ctx, credentialID, signCount); errUpdate != nil {
+			return nil, &LoginFinishError{
+				Code: LoginFinishErrorCodeCredentialFind,
+				Err:  errUpdate,
+			}
+		}
+	}
+
+	if deps.AuthTokenIssue == nil {
+		return nil, &LoginFinishError{
+			Code: LoginFinishErrorCodeTokenStore,
+			Err:  errors.New("auth token issuer is not configured"),
+		}
+	}
+
+	// A passkey verified with a PIN or biometric is a second factor itself
+	mfaLevel := types.MFALevelSingleFactor
+	if authData, err := webauthn.ParseAuthenticatorData(authenticatorData); err == nil && authData.UserVerified() {
+		mfaLevel = types.MFALevelMultiFactor
+	}
+
+	// Otherwise the passkey is only something the user has, which must not
+	// replace both the password and the code of users with two-factor
+	// authentication
+	if mfaLevel != types.MFALevelMultiFactor && deps.UserTotpSecretFind != nil {
+		secret, errSecret := deps.UserTotpSecretFind(ctx, credential.UserID)
+		if errSecret != nil {
+			return nil, &LoginFinishError{
+				Code: LoginFinishErrorCodeSecretLookup,
+				Err:  errSecret,
+			}
+		}
+
+		if secret != "" {
+			return nil, &LoginFinishError{
+				Code:    LoginFinishErrorCodeUserVerification,
+				Message: "Please unlock your passkey with its PIN or biometric, or sign in with your password",
+			}
+		}
+	}
+
+	ctx = core.ContextWithLogin(ctx, types.AuthMethodPasskey, mfaLevel, time.Now())
+	token, errToken := deps.AuthTokenIssue(ctx, credential.UserID)
+	if errToken != nil {
+		return nil, &LoginFinishError{
+			Code: LoginFinishErrorCodeTokenStore,
+			Err:  errToken,
+		}
+	}
+
+	return &LoginFinishResult{UserID: credential.UserID, Token: token}, nil
+}
@@ -0,0 +1,201 @@
+package api_webauthn_login_finish
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+)
+
+type testEnv struct {
+	store         map[string]string
+	authenticator *testutils.WebAuthnAuthenticator
+	credential    types.WebAuthnCredential
+	deps          Dependencies
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	authenticator, err := testutils.NewWebAuthnAuthenticator("example.com", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := webauthn.RelyingParty{ID: "example.com", Origins: []string{"https://example.com"}}
+
+	clientData, attestation, err := authenticator.Create("register-challenge", webauthn.AttestationFormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registration, err := webauthn.VerifyRegistration(rp, "register-challenge", clientData, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &testEnv{
+		store:         map[string]string{},
+		authenticator: authenticator,
+		credential: types.WebAuthnCredential{
+			ID:        authenticator.CredentialIDString(),
+			UserID:    "user-1",
+			PublicKey: registration.PublicKey,
+			SignCount: registration.SignCount,
+		},
+	}
+	env.deps = Dependencies{
+		TemporaryKeyGet: func(key string) (string, error) {
+			v, ok := env.store[key]
+			if !ok {
+				return "", errors.New("not found")
+			}
+			return v, nil
+		},
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			env.store[key] = value
+			return nil
+		},
+		RelyingParty: rp,
+		CredentialFindByID: func(ctx context.Context, credentialID string) (*types.WebAuthnCredential, error) {
+			if credentialID != env.credential.ID {
+				return nil, nil
+			}
+			credential := env.credential
+			return &credential, nil
+		},
+		CredentialSignCountUpdate: func(ctx context.Context, credentialID string, signCount uint32) error {
+			env.credential.SignCount = signCount
+			return nil
+		},
+		AuthTokenIssue: func(ctx context.Context, userID string) (string, error) {
+			return "token-for-" + userID, nil
+		},
+	}
+	return env
+}
+
+func (env *testEnv) login(t *testing.T, challenge string) string {
+	t.Helper()
+	env.store[webauthn.LoginChallengeKeyPrefix+challenge] = "pending"
+
+	clientData, authData, signature, err := env.authenticator.Get(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, req := testutils.MakePostRequest(t, "/api/webauthn-login-finish", url.Values{
+		"credential_id":      {env.authenticator.CredentialIDString()},
+		"client_data_json":   {webauthn.EncodeBase64URL(clientData)},
+		"authenticator_data": {webauthn.EncodeBase64URL(authData)},
+		"signature":          {webauthn.EncodeBase64URL(signature)},
+	})
+	ApiWebAuthnLoginFinish(recorder, req, env.deps)
+	return recorder.Body.String()
+}
+
+func TestApiWebAuthnLoginFinishSuccess(t *testing.T) {
+	env := newTestEnv(t)
+
+	body := env.login(t, "login-1")
+	if !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected token in response, got %q", body)
+	}
+	if env.credential.SignCount != 1 {
+		t.Fatalf("expected sign count to be updated to 1, got %d", env.credential.SignCount)
+	}
+	if env.store[webauthn.LoginChallengeKeyPrefix+"login-1"] != "" {
+		t.Fatalf("expected challenge to be consumed")
+	}
+
+	body = env.login(t, "login-2")
+	if !strings.Contains(body, `"status":"success"`) || env.credential.SignCount != 2 {
+		t.Fatalf("expected second login to succeed with sign count 2, got %q (%d)", body, env.credential.SignCount)
+	}
+}
+
+func TestApiWebAuthnLoginFinishRejectsClonedAuthenticator(t *testing.T) {
+	env := newTestEnv(t)
+	env.credential.SignCount = 10
+
+	body := env.login(t, "login-1")
+	if !strings.Contains(body, `"message":"Passkey verification failed"`) {
+		t.Fatalf("expected sign count regression to be rejected, got %q", body)
+	}
+	if env.credential.SignCount != 10 {
+		t.Fatalf("expected sign count to be left untouched")
+	}
+}
+
+func TestApiWebAuthnLoginFinishRejectsUnknownChallenge(t *testing.T) {
+	env := newTestEnv(t)
+
+	clientData, authData, signature, err := env.authenticator.Get("never-issued")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, req := testutils.MakePostRequest(t, "/api/webauthn-login-finish", url.Values{
+		"credential_id":      {env.authenticator.CredentialIDString()},
+		"client_data_json":   {webauthn.EncodeBase64URL(clientData)},
+		"authenticator_data": {webauthn.EncodeBase64URL(authData)},
+		"signature":          {webauthn.EncodeBase64URL(signature)},
+	})
+	ApiWebAuthnLoginFinish(recorder, req, env.deps)
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"message":"Passkey login has expired. Please try again"`) {
+		t.Fatalf("expected unknown challenge to be rejected, got %q", body)
+	}
+}
+
+func TestApiWebAuthnLoginFinishRejectsUnknownCredential(t *testing.T) {
+	env := newTestEnv(t)
+	env.credential.ID = "another-credential"
+
+	body := env.login(t, "login-1")
+	if !strings.Contains(body, `"message":"Passkey is not registered"`) {
+		t.Fatalf("expected unknown credential to be rejected, got %q", body)
+	}
+}
+
+func TestApiWebAuthnLoginFinishFailsWhenChallengeCannotBeConsumed(t *testing.T) {
+	env := newTestEnv(t)
+	env.deps.TemporaryKeyDelete = func(key string) error {
+		return errors.New("store unavailable")
+	}
+
+	body := env.login(t, "login-1")
+	if strings.Contains(body, `"status":"success"`) || strings.Contains(body, "token-for-user-1") {
+		t.Fatalf("expected the login to fail, got %q", body)
+	}
+	if env.credential.SignCount != 0 {
+		t.Fatalf("expected the passkey not to be verified, got sign count %d", env.credential.SignCount)
+	}
+}
+
+func TestApiWebAuthnLoginFinishRequiresUserVerificationWithTwoFactor(t *testing.T) {
+	env := newTestEnv(t)
+	env.authenticator.WithoutUserVerification = true
+
+	secrets := map[string]string{}
+	env.deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+		return secrets[userID], nil
+	}
+
+	// Users without two-factor authentication sign in with presence only
+	if body := env.login(t, "login-1"); !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected a token without two-factor authentication, got %q", body)
+	}
+
+	secrets["user-1"] = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	if body := env.login(t, "login-2"); !strings.Contains(body, `"message":"Please unlock your passkey with its PIN or biometric, or sign in with your password"`) {
+		t.Fatalf("expected an unverified passkey to be refused, got %q", body)
+	}
+
+	env.authenticator.WithoutUserVerification = false
+	if body := env.login(t, "login-3"); !strings.Contains(body, `"token":"token-for-user-1"`) {
+		t.Fatalf("expected a verified passkey to sign in, got %q", body)
+	}
+}
@@ -0,0 +1,198 @@
+package api_webauthn_register_begin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for starting a passkey
+// registration for the authenticated user.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// CredentialsFindByUserID returns the passkeys the user already has, so
+	// the browser can refuse to register the same authenticator twice. Optional.
+	CredentialsFindByUserID func(ctx context.Context, userID string) ([]types.WebAuthnCredential, error)
+
+	RelyingParty webauthn.RelyingParty
+
+	// NewChallenge defaults to webauthn.NewChallenge when nil.
+	NewChallenge func() (string, error)
+}
+
+// RegisterBeginErrorCode categorizes error sources.
+type RegisterBeginErrorCode string
+
+const (
+	RegisterBeginErrorCodeNone            RegisterBeginErrorCode = ""
+	RegisterBeginErrorCodeUnauthenticated RegisterBeginErrorCode = "unauthenticated"
+	RegisterBeginErrorCodeChallenge       RegisterBeginErrorCode = "challenge"
+	RegisterBeginErrorCodeCredentialsFind RegisterBeginErrorCode = "credentials_find"
+)
+
+// RegisterBeginError represents a structured error in the registration start.
+type RegisterBeginError struct {
+	Code    RegisterBeginErrorCode
+	Message string
+	Err     error
+}
+
+func (e *RegisterBeginError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiWebAuthnRegisterBegin is the HTTP-level helper that wires
+// request/response handling to the core RegisterBegin business logic using
+// the provided dependencies.
+func ApiWebAuthnRegisterBegin(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	options, perr := RegisterBegin(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case RegisterBeginErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.SuccessWithData("passkey registration started", map[string]any{
+		"public_key": options,
+	}))
+}
+
+// ApiWebAuthnRegisterBeginWithAuth is a convenience wrapper that allows
+// callers to pass a types.AuthSharedInterface (such as authImplementation)
+// instead of manually wiring Dependencies.
+func ApiWebAuthnRegisterBeginWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	config := a.GetWebAuthn()
+
+	deps := Dependencies{
+		CurrentUserID:   a.GetCurrentUserID,
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		RelyingParty:    webauthn.RelyingPartyFromConfig(config),
+	}
+
+	if config != nil && config.FuncCredentialsFindByUserID != nil {
+		deps.CredentialsFindByUserID = func(ctx context.Context, userID string) ([]types.WebAuthnCredential, error) {
+			return config.FuncCredentialsFindByUserID(ctx, userID, options)
+		}
+	}
+
+	ApiWebAuthnRegisterBegin(w, r, deps)
+}
+
+// RegisterBegin creates a registration challenge bound to the authenticated
+// user and returns the PublicKeyCredentialCreationOptions for
+// navigator.credentials.create(). It does not write HTTP responses.
+func RegisterBegin(ctx context.Context, r *http.Request, deps Dependencies) (map[string]any, *RegisterBeginError) {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return nil, &RegisterBeginError{
+			Code:    RegisterBeginErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	excludeCredentials := []map[string]any{}
+	if deps.CredentialsFindByUserID != nil {
+		credentials, errFind := deps.CredentialsFindByUserID(ctx, userID)
+		if errFind != nil {
+			return nil, &RegisterBeginError{
+				Code: RegisterBeginErrorCodeCredentialsFind,
+				Err:  errFind,
+			}
+		}
+		for _, credential := range credentials {
+			excludeCredentials = append(excludeCredentials, map[string]any{
+				"type": "public-key",
+				"id":   credential.ID,
+			})
+		}
+	}
+
+	newChallenge := webauthn.NewChallenge
+	if deps.NewChallenge != nil {
+		newChallenge = deps.NewChallenge
+	}
+
+	challenge, errChallenge := newChallenge()
+	if errChallenge != nil {
+		return nil, &RegisterBeginError{
+			Code: RegisterBeginErrorCodeChallenge,
+			Err:  errChallenge,
+		}
+	}
+
+	if deps.TemporaryKeySet == nil {
+		return nil, &RegisterBeginError{
+			Code: RegisterBeginErrorCodeChallenge,
+			Err:  errors.New("temporary key store is not configured"),
+		}
+	}
+
+	if errStore := deps.TemporaryKeySet(webauthn.RegistrationChallengeKeyPrefix+challenge, userID, webauthn.ChallengeExpiresSeconds); errStore != nil {
+		return nil, &RegisterBeginError{
+			Code: RegisterBeginErrorCodeChallenge,
+			Err:  errStore,
+		}
+	}
+
+	return map[string]any{
+		"challenge": challenge,
+		"rp": map[string]any{
+			"id":   deps.RelyingParty.ID,
+			"name": deps.RelyingParty.Name,
+		},
+		"user": map[string]any{
+			"id":          webauthn.EncodeBase64URL([]byte(userID)),
+			"name":        userID,
+			"displayName": userID,
+		},
+		"pubKeyCredParams": []map[string]any{
+			{"type": "public-key", "alg": webauthn.AlgES256},
+			{"type": "public-key", "alg": webauthn.AlgEdDSA},
+			{"type": "public-key", "alg": webauthn.AlgRS256},
+		},
+		"timeout":     webauthn.ChallengeExpiresSeconds * 1000,
+		"attestation": webauthn.AttestationFormatNone,
+		"authenticatorSelection": map[string]any{
+			// Login does not send allowCredentials, so passkeys must be
+			// discoverable to be usable.
+			"residentKey":        "required",
+			"requireResidentKey": true,
+			"userVerification":   "preferred",
+		},
+		"excludeCredentials": excludeCredentials,
+	}, nil
+}
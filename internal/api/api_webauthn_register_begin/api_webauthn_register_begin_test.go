@@ -0,0 +1,57 @@
+package api_webauthn_register_begin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+)
+
+func TestApiWebAuthnRegisterBeginRequiresUser(t *testing.T) {
+	recorder, req := testutils.MakePostRequest(t, "/api/webauthn-register-begin", url.Values{})
+	ApiWebAuthnRegisterBegin(recorder, req, Dependencies{
+		CurrentUserID: func(r *http.Request) string { return "" },
+	})
+
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"unauthenticated"`) {
+		t.Fatalf("expected unauthenticated response, got %q", body)
+	}
+}
+
+func TestApiWebAuthnRegisterBeginStoresChallenge(t *testing.T) {
+	store := map[string]string{}
+	recorder, req := testutils.MakePostRequest(t, "/api/webauthn-register-begin", url.Values{})
+	ApiWebAuthnRegisterBegin(recorder, req, Dependencies{
+		CurrentUserID: func(r *http.Request) string { return "user-1" },
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		},
+		CredentialsFindByUserID: func(ctx context.Context, userID string) ([]types.WebAuthnCredential, error) {
+			return []types.WebAuthnCredential{{ID: "existing-credential"}}, nil
+		},
+		RelyingParty: webauthn.RelyingParty{ID: "example.com", Name: "Example"},
+		NewChallenge: func() (string, error) { return "challenge-1", nil },
+	})
+
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`"challenge":"challenge-1"`,
+		`"rp":{"id":"example.com","name":"Example"}`,
+		`"attestation":"none"`,
+		`"id":"existing-credential"`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in response, got %q", expected, body)
+		}
+	}
+
+	if store[webauthn.RegistrationChallengeKeyPrefix+"challenge-1"] != "user-1" {
+		t.Fatalf("expected challenge to be bound to the user, got %v", store)
+	}
+}
@@ -0,0 +1,248 @@
+package api_webauthn_register_finish
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for completing a passkey
+// registration.
+type Dependencies struct {
+	// CurrentUserID returns the authenticated user ID of the request.
+	CurrentUserID func(r *http.Request) string
+
+	TemporaryKeyGet func(key string) (string, error)
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// TemporaryKeyDelete consumes the challenge. Optional: without it the
+	// challenge is overwritten with an empty value.
+	TemporaryKeyDelete func(key string) error
+
+	RelyingParty webauthn.RelyingParty
+
+	// CredentialFindByID returns nil when the credential is not registered.
+	CredentialFindByID func(ctx context.Context, credentialID string) (*types.WebAuthnCredential, error)
+	CredentialStore    func(ctx context.Context, credential types.WebAuthnCredential) error
+}
+
+// RegisterFinishErrorCode categorizes error sources.
+type RegisterFinishErrorCode string
+
+const (
+	RegisterFinishErrorCodeNone             RegisterFinishErrorCode = ""
+	RegisterFinishErrorCodeUnauthenticated  RegisterFinishErrorCode = "unauthenticated"
+	RegisterFinishErrorCodeValidation       RegisterFinishErrorCode = "validation"
+	RegisterFinishErrorCodeChallengeExpired RegisterFinishErrorCode = "challenge_expired"
+	RegisterFinishErrorCodeVerification     RegisterFinishErrorCode = "verification"
+	RegisterFinishErrorCodeDuplicate        RegisterFinishErrorCode = "duplicate"
+	RegisterFinishErrorCodeCredentialStore  RegisterFinishErrorCode = "credential_store"
+	RegisterFinishErrorCodeTokenStore       RegisterFinishErrorCode = "token_store"
+)
+
+// RegisterFinishError represents a structured error in the registration
+// completion.
+type RegisterFinishError struct {
+	Code    RegisterFinishErrorCode
+	Message string
+	Err     error
+}
+
+func (e *RegisterFinishError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// ApiWebAuthnRegisterFinish is the HTTP-level helper that wires
+// request/response handling to the core RegisterFinish business logic using
+// the provided dependencies.
+func ApiWebAuthnRegisterFinish(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	perr := RegisterFinish(r.Context(), r, deps)
+	if perr != nil {
+		switch perr.Code {
+		case RegisterFinishErrorCodeUnauthenticated:
+			api.Respond(w, r, api.Unauthenticated(perr.Message))
+			return
+		case RegisterFinishErrorCodeValidation,
+			RegisterFinishErrorCodeChallengeExpired,
+			RegisterFinishErrorCodeVerification,
+			RegisterFinishErrorCodeDuplicate:
+			api.Respond(w, r, api.Error(perr.Message))
+			return
+		default:
+			api.Respond(w, r, api.Error("Failed to process request. Please try again later"))
+			return
+		}
+	}
+
+	core.RateLimitSucceeded(r.Context())
+	api.Respond(w, r, api.Success("passkey registered"))
+}
+
+// ApiWebAuthnRegisterFinishWithAuth is a convenience wrapper that allows
+// callers to pass a types.AuthSharedInterface (such as authImplementation)
+// instead of manually wiring Dependencies.
+func ApiWebAuthnRegisterFinishWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	config := a.GetWebAuthn()
+
+	deps := Dependencies{
+		CurrentUserID:   a.GetCurrentUserID,
+		TemporaryKeyGet: a.GetFuncTemporaryKeyGet(),
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
+		RelyingParty: webauthn.RelyingPartyFromConfig(config),
+	}
+
+	if config != nil {
+		deps.CredentialFindByID = func(ctx context.Context, credentialID string) (*types.WebAuthnCredential, error) {
+			return config.FuncCredentialFindByID(ctx, credentialID, options)
+		}
+		deps.CredentialStore = func(ctx context.Context, credential types.WebAuthnCredential) error {
+			return config.FuncCredentialStore(ctx, credential, options)
+		}
+	}
+
+	ApiWebAuthnRegisterFinish(w, r, deps)
+}
+
+// RegisterFinish verifies the attestation returned by
+// navigator.credentials.create() against the pending challenge of the
+// authenticated user and stores the new credential. It does not write HTTP
+// responses.
+func RegisterFinish(ctx context.Context, r *http.Request, deps Dependencies) *RegisterFinishError {
+	userID := ""
+	if deps.CurrentUserID != nil {
+		userID = deps.CurrentUserID(r)
+	}
+
+	if userID == "" {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeUnauthenticated,
+			Message: "user id is required",
+		}
+	}
+
+	clientDataJSON, errClientData := webauthn.DecodeBase64URL(req.GetStringTrimmed(r, "client_data_json"))
+	attestationObject, errAttestation := webauthn.DecodeBase64URL(req.GetStringTrimmed(r, "attestation_object"))
+	if errClientData != nil || errAttestation != nil || len(clientDataJSON) == 0 || len(attestationObject) == 0 {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeValidation,
+			Message: "Passkey response is required",
+		}
+	}
+
+	challenge, errChallenge := webauthn.ChallengeFromClientData(clientDataJSON)
+	if errChallenge != nil {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeValidation,
+			Message: "Passkey response is invalid",
+			Err:     errChallenge,
+		}
+	}
+
+	if deps.TemporaryKeyGet == nil {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeChallengeExpired,
+			Message: "Passkey registration has expired. Please try again",
+			Err:     errors.New("temporary key store is not configured"),
+		}
+	}
+
+	challengeKey := webauthn.RegistrationChallengeKeyPrefix + challenge
+
+	challengeUserID, errGet := deps.TemporaryKeyGet(challengeKey)
+	if errGet != nil || challengeUserID == "" || challengeUserID != userID {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeChallengeExpired,
+			Message: "Passkey registration has expired. Please try again",
+			Err:     errGet,
+		}
+	}
+
+	// Challenges are single use, whatever the outcome of the verification.
+	if deps.TemporaryKeyDelete != nil {
+		if errConsume := deps.TemporaryKeyDelete(challengeKey); errConsume != nil {
+			return &RegisterFinishError{
+				Code: RegisterFinishErrorCodeTokenStore,
+				Err:  errConsume,
+			}
+		}
+	} else if deps.TemporaryKeySet != nil {
+		if errConsume := deps.TemporaryKeySet(challengeKey, "", 1); errConsume != nil {
+			return &RegisterFinishError{
+				Code: RegisterFinishErrorCodeTokenStore,
+				Err:  errConsume,
+			}
+		}
+	}
+
+	result, errVerify := webauthn.VerifyRegistration(deps.RelyingParty, challenge, clientDataJSON, attestationObject)
+	if errVerify != nil {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeVerification,
+			Message: "Passkey verification failed",
+			Err:     errVerify,
+		}
+	}
+
+	credentialID := webauthn.EncodeBase64URL(result.CredentialID)
+
+	if deps.CredentialFindByID == nil || deps.CredentialStore == nil {
+		return &RegisterFinishError{
+			Code: RegisterFinishErrorCodeCredentialStore,
+			Err:  errors.New("credential storage is not configured"),
+		}
+	}
+
+	existing, errFind := deps.CredentialFindByID(ctx, credentialID)
+	if errFind != nil {
+		return &RegisterFinishError{
+			Code: RegisterFinishErrorCodeCredentialStore,
+			Err:  errFind,
+		}
+	}
+
+	if existing != nil {
+		return &RegisterFinishError{
+			Code:    RegisterFinishErrorCodeDuplicate,
+			Message: "Passkey is already registered",
+		}
+	}
+
+	errStore := deps.CredentialStore(ctx, types.WebAuthnCredential{
+		ID:                credentialID,
+		UserID:            userID,
+		PublicKey:         result.PublicKey,
+		SignCount:         result.SignCount,
+		AttestationFormat: result.AttestationFormat,
+	})
+	if errStore != nil {
+		return &RegisterFinishError{
+			Code: RegisterFinishErrorCodeCredentialStore,
+			Err:  errStore,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package api_webauthn_register_finish
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/internal/webauthn"
+	"github.com/dracory/auth/types"
+)
+
+type testEnv struct {
+	store       map[string]string
+	credentials map[string]types.WebAuthnCredential
+	deps        Dependencies
+}
+
+func newTestEnv(userID string) *testEnv {
+	env := &testEnv{
+		store:       map[string]string{},
+		credentials: map[string]types.WebAuthnCredential{},
+	}
+	env.deps = Dependencies{
+		CurrentUserID: func(r *http.Request) string { return userID },
+		TemporaryKeyGet: func(key string) (string, error) {
+			v, ok := env.store[key]
+			if !ok {
+				return "", errors.New("not found")
+			}
+			return v, nil
+		},
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			env.store[key] = value
+			return nil
+		},
+		RelyingParty: webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}},
+		CredentialFindByID: func(ctx context.Context, credentialID string) (*types.WebAuthnCredential, error) {
+			if credential, ok := env.credentials[credentialID]; ok {
+				return &credential, nil
+			}
+			return nil, nil
+		},
+		CredentialStore: func(ctx context.Context, credential types.WebAuthnCredential) error {
+			env.credentials[credential.ID] = credential
+			return nil
+		},
+	}
+	return env
+}
+
+func finishRequest(t *testing.T, env *testEnv, clientData []byte, attestation []byte) string {
+	t.Helper()
+	recorder, req := testutils.MakePostRequest(t, "/api/webauthn-register-finish", url.Values{
+		"client_data_json":   {webauthn.EncodeBase64URL(clientData)},
+		"attestation_object": {webauthn.EncodeBase64URL(attestation)},
+	})
+	ApiWebAuthnRegisterFinish(recorder, req, env.deps)
+	return recorder.Body.String()
+}
+
+func TestApiWebAuthnRegisterFinishStoresCredential(t *testing.T) {
+	for _, format := range []string{webauthn.AttestationFormatNone, webauthn.AttestationFormatPacked} {
+		env := newTestEnv("user-1")
+		env.store[webauthn.RegistrationChallengeKeyPrefix+"challenge-1"] = "user-1"
+
+		authenticator, err := testutils.NewWebAuthnAuthenticator("example.com", "https://example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clientData, attestation, err := authenticator.Create("challenge-1", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := finishRequest(t, env, clientData, attestation)
+		if !strings.Contains(body, `"message":"passkey registered"`) {
+			t.Fatalf("%s: expected success, got %q", format, body)
+		}
+
+		credential, ok := env.credentials[authenticator.CredentialIDString()]
+		if !ok {
+			t.Fatalf("%s: expected credential to be stored", format)
+		}
+		if credential.UserID != "user-1" || credential.AttestationFormat != format || len(credential.PublicKey) == 0 {
+			t.Fatalf("%s: unexpected stored credential %+v", format, credential)
+		}
+		if env.store[webauthn.RegistrationChallengeKeyPrefix+"challenge-1"] != "" {
+			t.Fatalf("%s: expected challenge to be consumed", format)
+		}
+	}
+}
+
+func TestApiWebAuthnRegisterFinishRejectsChallengeOfAnotherUser(t *testing.T) {
+	env := newTestEnv("user-2")
+	env.store[webauthn.RegistrationChallengeKeyPrefix+"challenge-1"] = "user-1"
+
+	authenticator, err := testutils.NewWebAuthnAuthenticator("example.com", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, attestation, err := authenticator.Create("challenge-1", webauthn.AttestationFormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := finishRequest(t, env, clientData, attestation)
+	if !strings.Contains(body, `"message":"Passkey registration has expired. Please try again"`) {
+		t.Fatalf("expected challenge to be rejected, got %q", body)
+	}
+	if len(env.credentials) != 0 {
+		t.Fatalf("expected no credential to be stored")
+	}
+}
+
+func TestApiWebAuthnRegisterFinishFailsWhenChallengeCannotBeConsumed(t *testing.T) {
+	env := newTestEnv("user-1")
+	env.store[webauthn.RegistrationChallengeKeyPrefix+"challenge-1"] = "user-1"
+	env.deps.TemporaryKeyDelete = func(key string) error {
+		return errors.New("store unavailable")
+	}
+
+	authenticator, err := testutils.NewWebAuthnAuthenticator("example.com", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, attestation, err := authenticator.Create("challenge-1", webauthn.AttestationFormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := finishRequest(t, env, clientData, attestation)
+	if strings.Contains(body, `"message":"passkey registered"`) {
+		t.Fatalf("expected the registration to fail, got %q", body)
+	}
+	if len(env.credentials) != 0 {
+		t.Fatalf("expected no credential to be stored")
+	}
+}
+
+func TestApiWebAuthnRegisterFinishRejectsWrongOrigin(t *testing.T) {
+	env := newTestEnv("user-1")
+	env.store[webauthn.RegistrationChallengeKeyPrefix+"challenge-1"] = "user-1"
+
+	authenticator, err := testutils.NewWebAuthnAuthenticator("example.com", "https://evil.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, attestation, err := authenticator.Create("challenge-1", webauthn.AttestationFormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := finishRequest(t, env, clientData, attestation)
+	if !strings.Contains(body, `"message":"Passkey verification failed"`) {
+		t.Fatalf("expected verification failure, got %q", body)
+	}
+}
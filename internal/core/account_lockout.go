@@ -0,0 +1,241 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/types"
+	authutils "github.com/dracory/auth/utils"
+	"github.com/dracory/str"
+)
+
+// AccountLockedMessage is shown for sign ins refused because the account is
+// locked. It is the same for every account, whether it exists or not.
+const AccountLockedMessage = "Too many failed attempts. Please try again later"
+
+// accountLockoutKeyPrefix and twoFactorLockoutKeyPrefix namespace the
+// failure counters of typed accounts and of users entering two-factor
+// codes in the counter store.
+const (
+	accountLockoutKeyPrefix   = "lockout:"
+	twoFactorLockoutKeyPrefix = "lockout_2fa:"
+)
+
+// AccountUnlockKeyPrefix namespaces the tokens of account unlock links in
+// the temporary key store.
+const AccountUnlockKeyPrefix = "account_unlock:"
+
+// accountUnlockExpiresSeconds is how long an unlock link is valid.
+const accountUnlockExpiresSeconds = 3600
+
+// AccountLockedUntil returns until when the account, as typed by the user,
+// is locked, or the zero time when it is not locked or lockout is not
+// configured.
+func AccountLockedUntil(a types.AuthSharedInterface, account string, now time.Time) time.Time {
+	return accountLockoutLockedUntil(a, accountLockoutKey(accountLockoutKeyPrefix, account), now)
+}
+
+// AccountFailureRecord counts a failed sign in against the account, locking
+// it once it has too many. When the account is first locked, the unlock
+// link is sent with FuncUnlockEmailSend.
+func AccountFailureRecord(ctx context.Context, a types.AuthSharedInterface, account string, options types.UserAuthOptions, now time.Time) error {
+	return accountLockoutFailureRecord(ctx, a, accountLockoutKeyPrefix, account, true, options, now)
+}
+
+// AccountFailuresReset clears the failures of the account after a
+// successful sign in.
+func AccountFailuresReset(a types.AuthSharedInterface, account string) error {
+	return accountLockoutReset(a, accountLockoutKey(accountLockoutKeyPrefix, account))
+}
+
+// TwoFactorLockedUntil works like AccountLockedUntil for the two-factor
+// codes of the user.
+func TwoFactorLockedUntil(a types.AuthSharedInterface, userID string, now time.Time) time.Time {
+	return accountLockoutLockedUntil(a, accountLockoutKey(twoFactorLockoutKeyPrefix, userID), now)
+}
+
+// TwoFactorFailureRecord works like AccountFailureRecord for a wrong
+// two-factor code of the user. No unlock link is sent, as the password was
+// right; the lock expires by itself.
+func TwoFactorFailureRecord(ctx context.Context, a types.AuthSharedInterface, userID string, options types.UserAuthOptions, now time.Time) error {
+	return accountLockoutFailureRecord(ctx, a, twoFactorLockoutKeyPrefix, userID, false, options, now)
+}
+
+// TwoFactorFailuresReset clears the wrong two-factor codes of the user.
+func TwoFactorFailuresReset(a types.AuthSharedInterface, userID string) error {
+	return accountLockoutReset(a, accountLockoutKey(twoFactorLockoutKeyPrefix, userID))
+}
+
+// AccountUnlock clears the failures of the account the unlock link was sent
+// for, and consumes the link. It reports false for unknown or expired
+// links.
+func AccountUnlock(a types.AuthSharedInterface, token string) (bool, error) {
+	get := a.GetFuncTemporaryKeyGet()
+	if a.GetAccountLockout() == nil || get == nil || token == "" {
+		return false, nil
+	}
+
+	unlockKey := AccountUnlockKeyPrefix + token
+
+	key, err := get(unlockKey)
+	if err != nil || key == "" {
+		return false, nil
+	}
+
+	if err := TemporaryKeyDelete(a, unlockKey); err != nil {
+		return false, err
+	}
+
+	return true, accountLockoutReset(a, key)
+}
+
+// accountLockoutKey returns the key of the counter of the account. Typed
+// accounts are compared case-insensitively, and only their hash is used.
+func accountLockoutKey(prefix string, account string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(account))))
+	return prefix + hex.EncodeToString(sum[:])
+}
+
+// accountLockoutLockSuffix names the lock next to the failure counter of an
+// account in the temporary key store. It holds until when the account is
+// locked, in Unix seconds.
+const accountLockoutLockSuffix = ":locked"
+
+func accountLockoutLockedUntil(a types.AuthSharedInterface, key string, now time.Time) time.Time {
+	get := a.GetFuncTemporaryKeyGet()
+	if a.GetAccountLockout() == nil || get == nil {
+		return time.Time{}
+	}
+
+	// Stores report unknown keys as errors
+	value, err := get(key + accountLockoutLockSuffix)
+	if err != nil || value == "" {
+		return time.Time{}
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return time.Time{}
+	}
+
+	return time.Unix(unix, 0)
+}
+
+func accountLockoutFailureRecord(ctx context.Context, a types.AuthSharedInterface, prefix string, account string, unlockable bool, options types.UserAuthOptions, now time.Time) error {
+	config := a.GetAccountLockout()
+	if config == nil {
+		return nil
+	}
+
+	store := a.GetCounterStore()
+	if store == nil {
+		return errFuncNotConfigured("CounterStore")
+	}
+
+	set := a.GetFuncTemporaryKeySet()
+	if set == nil {
+		return errFuncNotConfigured("FuncTemporaryKeySet")
+	}
+
+	key := accountLockoutKey(prefix, account)
+
+	if !accountLockoutLockedUntil(a, key, now).IsZero() {
+		// Attempts while locked are refused before the credentials are
+		// checked, so they are never failures
+		return nil
+	}
+
+	resetAfter := config.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = types.DefaultAccountLockoutResetAfter
+	}
+
+	// Counted atomically, so concurrent failures are never lost
+	failures, err := store.Increment(key, resetAfter)
+	if err != nil {
+		return err
+	}
+
+	maxFailures := int64(config.MaxFailures)
+	if maxFailures <= 0 {
+		maxFailures = types.DefaultAccountLockoutMaxFailures
+	}
+
+	if failures < maxFailures {
+		return nil
+	}
+
+	delay := accountLockoutDelay(config, int(failures-maxFailures))
+	lockedUntil := now.Add(delay)
+
+	if err := set(key+accountLockoutLockSuffix, strconv.FormatInt(lockedUntil.Unix(), 10), max(int(delay.Seconds()), 1)); err != nil {
+		return err
+	}
+
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+
+	if config.FuncAccountLocked != nil {
+		if err := config.FuncAccountLocked(ctx, account, lockedUntil, options); err != nil {
+			return err
+		}
+	}
+
+	// The link is only sent when the account is first locked, so failing
+	// on and on does not flood the owner with emails
+	if unlockable && failures == maxFailures && config.FuncUnlockEmailSend != nil {
+		token, err := str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
+		if err != nil {
+			return err
+		}
+
+		if err := set(AccountUnlockKeyPrefix+token, key, accountUnlockExpiresSeconds); err != nil {
+			return err
+		}
+
+		return config.FuncUnlockEmailSend(ctx, account, a.LinkAccountUnlock(token), options)
+	}
+
+	return nil
+}
+
+// accountLockoutDelay returns how long the account is locked after the
+// given number of failures beyond MaxFailures: BaseDelay, doubling with
+// every further failure, up to MaxDelay.
+func accountLockoutDelay(config *types.AccountLockoutConfig, beyond int) time.Duration {
+	delay := config.BaseDelay
+	if delay <= 0 {
+		delay = types.DefaultAccountLockoutBaseDelay
+	}
+
+	maxDelay := config.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = types.DefaultAccountLockoutMaxDelay
+	}
+
+	for i := 0; i < beyond && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
+
+func accountLockoutReset(a types.AuthSharedInterface, key string) error {
+	if a.GetAccountLockout() == nil {
+		return nil
+	}
+
+	store := a.GetCounterStore()
+	if store == nil {
+		return errFuncNotConfigured("CounterStore")
+	}
+
+	if err := store.Delete(key); err != nil {
+		return err
+	}
+
+	return TemporaryKeyDelete(a, key+accountLockoutLockSuffix)
+}
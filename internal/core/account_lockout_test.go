@@ -0,0 +1,184 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestAccountFailureRecord(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	newTemporaryKeyStoreForTest(a)
+
+	var locks []time.Time
+	var unlockLinks []string
+	a.SetAccountLockout(&types.AccountLockoutConfig{
+		MaxFailures: 3,
+		BaseDelay:   time.Minute,
+		MaxDelay:    3 * time.Minute,
+		FuncAccountLocked: func(ctx context.Context, account string, lockedUntil time.Time, options types.UserAuthOptions) error {
+			locks = append(locks, lockedUntil)
+			return nil
+		},
+		FuncUnlockEmailSend: func(ctx context.Context, account string, unlockLink string, options types.UserAuthOptions) error {
+			unlockLinks = append(unlockLinks, unlockLink)
+			return nil
+		},
+	})
+
+	now := time.Unix(1700000000, 0)
+	fail := func() {
+		if err := AccountFailureRecord(context.Background(), a, "user@test.com", types.UserAuthOptions{}, now); err != nil {
+			t.Fatalf("AccountFailureRecord failed: %v", err)
+		}
+	}
+
+	fail()
+	fail()
+	if !AccountLockedUntil(a, "user@test.com", now).IsZero() {
+		t.Fatal("expected the account to be unlocked below the limit")
+	}
+
+	fail()
+	if got := AccountLockedUntil(a, " USER@test.com", now); !got.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the account to be locked for a minute, got %v", got)
+	}
+
+	// Failures while locked are not counted
+	fail()
+	if len(locks) != 1 {
+		t.Fatalf("expected one lock, got %d", len(locks))
+	}
+
+	// Every further failure doubles the lock, up to MaxDelay
+	for _, expected := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		now = AccountLockedUntil(a, "user@test.com", now)
+		fail()
+		if got := AccountLockedUntil(a, "user@test.com", now); !got.Equal(now.Add(expected)) {
+			t.Fatalf("expected the account to be locked for %v, got %v", expected, got.Sub(now))
+		}
+	}
+
+	if len(locks) != 4 {
+		t.Fatalf("expected every lock to be reported, got %d", len(locks))
+	}
+	if len(unlockLinks) != 1 || !strings.HasPrefix(unlockLinks[0], a.LinkAccountUnlock("")) {
+		t.Fatalf("expected one unlock link, got %v", unlockLinks)
+	}
+
+	if err := AccountFailuresReset(a, "user@test.com"); err != nil {
+		t.Fatal(err)
+	}
+	if !AccountLockedUntil(a, "user@test.com", now).IsZero() {
+		t.Fatal("expected the reset account to be unlocked")
+	}
+}
+
+func TestAccountFailureRecord_ConcurrentFailuresAreCounted(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	var mu sync.Mutex
+	store := map[string]string{}
+	a.SetFuncTemporaryKeyGet(func(key string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return store[key], nil
+	})
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		store[key] = value
+		return nil
+	})
+
+	var unlockLinks atomic.Int32
+	a.SetAccountLockout(&types.AccountLockoutConfig{
+		MaxFailures: 3,
+		FuncUnlockEmailSend: func(ctx context.Context, account string, unlockLink string, options types.UserAuthOptions) error {
+			unlockLinks.Add(1)
+			return nil
+		},
+	})
+
+	now := time.Now()
+
+	// Parallel guesses all pass the lock check before any is counted
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := AccountFailureRecord(context.Background(), a, "user@test.com", types.UserAuthOptions{}, now); err != nil {
+				t.Errorf("AccountFailureRecord failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if AccountLockedUntil(a, "user@test.com", now).IsZero() {
+		t.Fatal("expected the concurrent failures to lock the account")
+	}
+	if got := unlockLinks.Load(); got != 1 {
+		t.Fatalf("expected one unlock link, got %d", got)
+	}
+}
+
+func TestAccountUnlock(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	newTemporaryKeyStoreForTest(a)
+
+	var unlockLink string
+	a.SetAccountLockout(&types.AccountLockoutConfig{
+		MaxFailures: 1,
+		FuncUnlockEmailSend: func(ctx context.Context, account string, link string, options types.UserAuthOptions) error {
+			unlockLink = link
+			return nil
+		},
+	})
+
+	now := time.Now()
+	if err := AccountFailureRecord(context.Background(), a, "unknown@test.com", types.UserAuthOptions{}, now); err != nil {
+		t.Fatal(err)
+	}
+	if AccountLockedUntil(a, "unknown@test.com", now).IsZero() {
+		t.Fatal("expected unknown accounts to be locked the same way")
+	}
+
+	_, token, _ := strings.Cut(unlockLink, "?t=")
+
+	if unlocked, err := AccountUnlock(a, "forged"); err != nil || unlocked {
+		t.Fatalf("expected a forged link to be refused, got %v (%v)", unlocked, err)
+	}
+
+	if unlocked, err := AccountUnlock(a, token); err != nil || !unlocked {
+		t.Fatalf("expected the link to unlock the account, got %v (%v)", unlocked, err)
+	}
+	if !AccountLockedUntil(a, "unknown@test.com", now).IsZero() {
+		t.Fatal("expected the account to be unlocked")
+	}
+
+	if unlocked, _ := AccountUnlock(a, token); unlocked {
+		t.Fatal("expected the link to be single use")
+	}
+}
+
+func TestAccountLockoutDisabled(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := newTemporaryKeyStoreForTest(a)
+
+	for i := 0; i < 10; i++ {
+		if err := TwoFactorFailureRecord(context.Background(), a, "user-1", types.UserAuthOptions{}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store) != 0 || !TwoFactorLockedUntil(a, "user-1", time.Now()).IsZero() {
+		t.Fatalf("expected nothing to be counted without lockout, got %v", store)
+	}
+}
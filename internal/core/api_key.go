@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// apiKeySecretBytes is the entropy of the secret part of an API key (256 bits).
+const apiKeySecretBytes = 32
+
+// IsApiKeyToken reports whether the token looks like an API key rather than
+// a session token.
+func IsApiKeyToken(token string) bool {
+	return strings.HasPrefix(token, types.ApiKeyTokenPrefix)
+}
+
+// ApiKeyParse splits an API key into the ID it is stored under and its
+// secret. The ID is hex encoded, so the first underscore after the prefix
+// ends it.
+func ApiKeyParse(token string) (string, string, bool) {
+	if !IsApiKeyToken(token) {
+		return "", "", false
+	}
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, types.ApiKeyTokenPrefix), "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+
+	return id, secret, true
+}
+
+// ApiKeyCreate generates an API key for the user and stores it with the hash
+// of its secret. It returns the key, to be shown to the user once, and the
+// stored record. A zero expiresAt creates a key that does not expire.
+func ApiKeyCreate(ctx context.Context, config *types.ApiKeyConfig, userID string, name string, scopes []string, expiresAt time.Time, options types.UserAuthOptions, now time.Time) (string, types.ApiKey, error) {
+	if config == nil || config.Store == nil {
+		return "", types.ApiKey{}, errors.New("api keys are not configured")
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", types.ApiKey{}, err
+	}
+
+	secret, err := randomURLString(apiKeySecretBytes)
+	if err != nil {
+		return "", types.ApiKey{}, err
+	}
+
+	record := types.ApiKey{
+		ID:         hex.EncodeToString(id),
+		SecretHash: RefreshTokenHash(secret),
+		UserID:     userID,
+		Name:       name,
+		Scopes:     scopes,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt,
+	}
+
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+	if err := config.Store.Create(ctx, record); err != nil {
+		return "", types.ApiKey{}, err
+	}
+
+	return types.ApiKeyTokenPrefix + record.ID + "_" + secret, record, nil
+}
+
+// ApiKeyAuthenticate looks the API key up and records its use. It returns
+// nil when the key is unknown, does not match or has expired.
+func ApiKeyAuthenticate(ctx context.Context, config *types.ApiKeyConfig, token string, options types.UserAuthOptions, now time.Time) (*types.ApiKey, error) {
+	id, secret, ok := ApiKeyParse(token)
+	if config == nil || config.Store == nil || !ok {
+		return nil, nil
+	}
+
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+	record, err := config.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if record == nil || record.UserID == "" {
+		return nil, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(RefreshTokenHash(secret)), []byte(record.SecretHash)) != 1 {
+		return nil, nil
+	}
+
+	if !record.ExpiresAt.IsZero() && !now.Before(record.ExpiresAt) {
+		return nil, nil
+	}
+
+	if err := config.Store.Touch(ctx, record.ID, now); err != nil {
+		return nil, err
+	}
+	record.LastUsedAt = now
+
+	return record, nil
+}
+
+// apiKeyNameMaxLength is the maximum length of the name of an API key.
+const apiKeyNameMaxLength = 100
+
+// ApiKeyValidateName returns a user-facing message when the name cannot be
+// given to an API key, or an empty string when it can.
+func ApiKeyValidateName(name string) string {
+	if name == "" {
+		return "Name is required field"
+	}
+
+	if len(name) > apiKeyNameMaxLength {
+		return "Name cannot be longer than 100 characters"
+	}
+
+	return ""
+}
+
+// ApiKeyInfo is an API key as shown to its user, without the secret hash.
+type ApiKeyInfo struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+}
+
+// ApiKeyInfoFrom returns the public details of the stored key.
+func ApiKeyInfoFrom(key types.ApiKey) ApiKeyInfo {
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return ApiKeyInfo{
+		ID:         key.ID,
+		Name:       key.Name,
+		Scopes:     scopes,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestApiKeyCreate_StoresOnlyTheSecretHash(t *testing.T) {
+	store := testutils.NewApiKeyStore()
+	config := &types.ApiKeyConfig{Store: store}
+	now := time.Now()
+
+	token, record, err := ApiKeyCreate(context.Background(), config, "user-1", "CI", []string{"read"}, time.Time{}, types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatalf("ApiKeyCreate failed: %v", err)
+	}
+
+	id, secret, ok := ApiKeyParse(token)
+	if !ok || id != record.ID || !strings.HasPrefix(token, types.ApiKeyTokenPrefix+record.ID+"_") {
+		t.Fatalf("expected the key to start with its ID, got %q", token)
+	}
+
+	stored := store.Keys[record.ID]
+	if stored.SecretHash == secret || stored.SecretHash != RefreshTokenHash(secret) {
+		t.Fatalf("expected only the secret hash to be stored, got %+v", stored)
+	}
+	if stored.UserID != "user-1" || stored.Name != "CI" || !stored.HasScope("read") {
+		t.Fatalf("expected the key details to be stored, got %+v", stored)
+	}
+}
+
+func TestApiKeyAuthenticate(t *testing.T) {
+	store := testutils.NewApiKeyStore()
+	config := &types.ApiKeyConfig{Store: store}
+	now := time.Now()
+	options := types.UserAuthOptions{}
+
+	token, record, err := ApiKeyCreate(context.Background(), config, "user-1", "CI", nil, now.Add(time.Hour), options, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ApiKeyAuthenticate(context.Background(), config, token, options, now.Add(time.Minute))
+	if err != nil || key == nil || key.UserID != "user-1" {
+		t.Fatalf("expected the key to authenticate user-1, got %+v (%v)", key, err)
+	}
+	if !store.Keys[record.ID].LastUsedAt.Equal(now.Add(time.Minute)) {
+		t.Fatal("expected the use of the key to be recorded")
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+		now   time.Time
+	}{
+		{"session token", "session-token", now},
+		{"wrong secret", types.ApiKeyTokenPrefix + record.ID + "_wrong", now},
+		{"unknown ID", types.ApiKeyTokenPrefix + "0000000000000000_" + strings.SplitN(token, "_", 3)[2], now},
+		{"expired", token, now.Add(time.Hour)},
+	}
+
+	for _, tt := range rejected {
+		if key, err := ApiKeyAuthenticate(context.Background(), config, tt.token, options, tt.now); err != nil || key != nil {
+			t.Errorf("%s: expected the key to be rejected, got %+v (%v)", tt.name, key, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/dracory/auth/internal/jwt"
+	"github.com/dracory/auth/types"
+	authutils "github.com/dracory/auth/utils"
+	"github.com/dracory/str"
+)
+
+// AuthTokenIssue generates a new auth token for the user and creates its
+// session in the session store. It is the single place where session
+// tokens are minted, so every login flow issues tokens the same way.
+//
+// When JWT access tokens are configured a signed JWT is returned instead,
+// and nothing is stored.
+func AuthTokenIssue(ctx context.Context, a types.AuthSharedInterface, userID string, options types.UserAuthOptions) (string, error) {
+	if config := a.GetJWT(); config != nil {
+		return JWTIssue(ctx, config, userID, options, time.Now())
+	}
+
+	// Reuse the shared login/verification gamma from utils to avoid
+	// duplicating the character set. We keep length at 32 for auth tokens.
+	token, err := str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
+	if err != nil {
+		return "", err
+	}
+
+	if err := SessionCreate(ctx, a, token, userID, options); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// AuthTokenUserID resolves the user the auth token was issued to. JWT access
+// tokens are verified locally, and checked against FuncTokenRevoked when it is
+// set; opaque tokens are looked up in the session store, by their hash when
+// auth token hashing is configured. An empty user ID without an error means
+// the token is not valid.
+func AuthTokenUserID(ctx context.Context, a types.AuthSharedInterface, token string, options types.UserAuthOptions) (string, error) {
+	principal, _, err := authTokenResolve(ctx, a, token, options, time.Now(), false)
+	if principal == nil {
+		return "", err
+	}
+	return principal.UserID, err
+}
+
+// AuthTokenAuthenticate resolves the caller of the auth token for the
+// middlewares, returning nil when the token is not valid. It also records
+// the activity on the session when an idle timeout is configured.
+// To avoid a write on every request the session is touched at most once per
+// tenth of the idle timeout; touched reports when it was, so the caller can
+// re-issue the auth cookie.
+func AuthTokenAuthenticate(ctx context.Context, a types.AuthSharedInterface, token string, options types.UserAuthOptions) (principal *types.Principal, touched bool, err error) {
+	return authTokenResolve(ctx, a, token, options, time.Now(), true)
+}
+
+func authTokenResolve(ctx context.Context, a types.AuthSharedInterface, token string, options types.UserAuthOptions, now time.Time, touch bool) (*types.Principal, bool, error) {
+	if config := a.GetJWT(); config != nil {
+		principal, err := JWTPrincipal(ctx, config, token, options, now)
+		return principal, false, err
+	}
+
+	store := SessionStore(a)
+	if store == nil {
+		return nil, false, errFuncNotConfigured("SessionStore")
+	}
+
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+
+	var session *types.Session
+	sessionID := ""
+	for _, id := range authTokenSessionIDs(a, token) {
+		found, err := store.Get(ctx, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if found != nil {
+			session, sessionID = found, id
+			break
+		}
+	}
+
+	if session == nil || SessionExpired(a, *session, now) {
+		return nil, false, nil
+	}
+
+	idleTimeout := a.GetSessionIdleTimeout()
+	if !touch || idleTimeout <= 0 || now.Sub(session.LastSeenAt) < idleTimeout/10 {
+		return sessionPrincipal(*session, sessionID), false, nil
+	}
+
+	if err := store.Touch(ctx, sessionID, now); err != nil {
+		return nil, false, err
+	}
+
+	return sessionPrincipal(*session, sessionID), true, nil
+}
+
+// JWTIssue signs a JWT access token for the user. Additional claims from
+// FuncClaims are added first, so they cannot override the registered ones.
+// How the user signed in is taken from the context, see ContextWithLogin.
+func JWTIssue(ctx context.Context, config *types.JWTConfig, userID string, options types.UserAuthOptions, now time.Time) (string, error) {
+	tokenID, err := jwt.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	expiration := config.Expiration
+	if expiration <= 0 {
+		expiration = types.DefaultJWTExpiration
+	}
+
+	claims := map[string]any{}
+	if config.FuncClaims != nil {
+		extra, err := config.FuncClaims(ctx, userID, options)
+		if err != nil {
+			return "", err
+		}
+		for name, value := range extra {
+			claims[name] = value
+		}
+	}
+
+	for _, name := range []string{"iss", "aud", "nbf", "amr", "auth_time"} {
+		delete(claims, name)
+	}
+
+	jwtLoginClaims(claims, loginFromContext(ctx, now))
+
+	claims["sub"] = userID
+	claims["jti"] = tokenID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(expiration).Unix()
+	if config.Issuer != "" {
+		claims["iss"] = config.Issuer
+	}
+	if config.Audience != "" {
+		claims["aud"] = config.Audience
+	}
+
+	return jwt.Sign(jwt.KeyFromConfig(config), claims)
+}
+
+// JWTUserID verifies a JWT access token and returns its subject. Invalid,
+// expired and revoked tokens resolve to an empty user ID.
+func JWTUserID(ctx context.Context, config *types.JWTConfig, token string, options types.UserAuthOptions, now time.Time) (string, error) {
+	principal, err := JWTPrincipal(ctx, config, token, options, now)
+	if principal == nil {
+		return "", err
+	}
+	return principal.UserID, nil
+}
+
+// JWTPrincipal verifies a JWT access token and returns the caller it was
+// issued to. Invalid, expired and revoked tokens resolve to nil.
+func JWTPrincipal(ctx context.Context, config *types.JWTConfig, token string, options types.UserAuthOptions, now time.Time) (*types.Principal, error) {
+	claims, err := jwt.Verify(jwt.KeyFromConfig(config), token, jwt.Expectations{
+		Issuer:   config.Issuer,
+		Audience: config.Audience,
+		Now:      now,
+	})
+	if err != nil {
+		return nil, nil
+	}
+
+	if config.FuncTokenRevoked != nil {
+		if claims.ID == "" {
+			return nil, nil
+		}
+
+		revoked, err := config.FuncTokenRevoked(ctx, claims.ID, options)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, nil
+		}
+	}
+
+	return jwtPrincipal(claims), nil
+}
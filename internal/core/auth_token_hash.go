@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/dracory/auth/types"
+)
+
+// AuthTokenHash returns the ID the session of the auth token is stored
+// under: the hex encoded HMAC-SHA256 of the token keyed with the pepper when
+// auth token hashing is configured, otherwise the token itself.
+func AuthTokenHash(a types.AuthSharedInterface, token string) string {
+	config := a.GetAuthTokenHashing()
+	if config == nil {
+		return token
+	}
+
+	mac := hmac.New(sha256.New, []byte(config.Pepper))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AuthTokenSessionID returns the ID of the session just created for the auth
+// token, to link other tokens issued on the same login to it. It is empty
+// with JWT access tokens, which have no session.
+func AuthTokenSessionID(a types.AuthSharedInterface, token string) string {
+	if a.GetJWT() != nil {
+		return ""
+	}
+	return AuthTokenHash(a, token)
+}
+
+// authTokenSessionIDs returns the IDs the session of the auth token may be
+// stored under, in lookup order. While AcceptUnhashed is set, sessions stored
+// before hashing was enabled are found by the token itself.
+func authTokenSessionIDs(a types.AuthSharedInterface, token string) []string {
+	hash := AuthTokenHash(a, token)
+	if config := a.GetAuthTokenHashing(); config != nil && config.AcceptUnhashed && hash != token {
+		return []string{hash, token}
+	}
+	return []string{hash}
+}
+
+// SessionIDFromAuthToken returns the ID of the stored session of the auth
+// token, to tell the current session apart in session listings. It is empty
+// when the session is not found.
+func SessionIDFromAuthToken(ctx context.Context, a types.AuthSharedInterface, token string, options types.UserAuthOptions) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	ids := authTokenSessionIDs(a, token)
+	if len(ids) == 1 {
+		return ids[0], nil
+	}
+
+	store := SessionStore(a)
+	if store == nil {
+		return "", errFuncNotConfigured("SessionStore")
+	}
+
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+	for _, id := range ids {
+		session, err := store.Get(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if session != nil {
+			return id, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestAuthTokenHash(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	if hash := AuthTokenHash(a, "token-1"); hash != "token-1" {
+		t.Fatalf("expected the token as is without hashing, got %q", hash)
+	}
+
+	a.SetAuthTokenHashing(&types.AuthTokenHashingConfig{Pepper: strings.Repeat("p", 32)})
+	hash := AuthTokenHash(a, "token-1")
+	if hash == "token-1" || len(hash) != 64 || hash != AuthTokenHash(a, "token-1") {
+		t.Fatalf("expected a stable hex encoded HMAC, got %q", hash)
+	}
+
+	a.SetAuthTokenHashing(&types.AuthTokenHashingConfig{Pepper: strings.Repeat("q", 32)})
+	if AuthTokenHash(a, "token-1") == hash {
+		t.Fatal("expected the hash to depend on the pepper")
+	}
+}
+
+func TestAuthTokenHashing_LegacyCallbacksOnlySeeHash(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	a.SetAuthTokenHashing(&types.AuthTokenHashingConfig{Pepper: strings.Repeat("p", 32)})
+
+	stored := map[string]string{}
+	a.SetFuncUserStoreAuthToken(func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error {
+		stored[token] = userID
+		return nil
+	})
+	a.SetFuncUserFindByAuthToken(func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+		return stored[token], nil
+	})
+
+	token, err := AuthTokenIssue(context.Background(), a, "user-1", types.UserAuthOptions{})
+	if err != nil {
+		t.Fatalf("AuthTokenIssue failed: %v", err)
+	}
+
+	if _, ok := stored[token]; ok || stored[AuthTokenHash(a, token)] != "user-1" {
+		t.Fatalf("expected only the hash of the token to be stored, got %v", stored)
+	}
+
+	if userID, err := AuthTokenUserID(context.Background(), a, token, types.UserAuthOptions{}); err != nil || userID != "user-1" {
+		t.Fatalf("expected user-1, got %q (%v)", userID, err)
+	}
+
+	// The stored value itself is not a valid token
+	if userID, err := AuthTokenUserID(context.Background(), a, AuthTokenHash(a, token), types.UserAuthOptions{}); err != nil || userID != "" {
+		t.Fatalf("expected the hash to be rejected as a token, got %q (%v)", userID, err)
+	}
+}
+
+func TestAuthTokenHashing_AcceptUnhashed(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+	store.Sessions["old-token"] = types.Session{ID: "old-token", UserID: "user-1"}
+
+	config := &types.AuthTokenHashingConfig{Pepper: strings.Repeat("p", 32)}
+	a.SetAuthTokenHashing(config)
+
+	if userID, err := AuthTokenUserID(context.Background(), a, "old-token", types.UserAuthOptions{}); err != nil || userID != "" {
+		t.Fatalf("expected the unhashed session to be rejected, got %q (%v)", userID, err)
+	}
+
+	config.AcceptUnhashed = true
+	if userID, err := AuthTokenUserID(context.Background(), a, "old-token", types.UserAuthOptions{}); err != nil || userID != "user-1" {
+		t.Fatalf("expected the unhashed session to be accepted during the transition, got %q (%v)", userID, err)
+	}
+	if sessionID, err := SessionIDFromAuthToken(context.Background(), a, "old-token", types.UserAuthOptions{}); err != nil || sessionID != "old-token" {
+		t.Fatalf("expected the unhashed session ID, got %q (%v)", sessionID, err)
+	}
+
+	token, err := AuthTokenIssue(context.Background(), a, "user-1", types.UserAuthOptions{})
+	if err != nil {
+		t.Fatalf("AuthTokenIssue failed: %v", err)
+	}
+	if _, ok := store.Sessions[AuthTokenHash(a, token)]; !ok {
+		t.Fatal("expected new sessions to be stored hashed during the transition")
+	}
+	if sessionID, err := SessionIDFromAuthToken(context.Background(), a, token, types.UserAuthOptions{}); err != nil || sessionID != AuthTokenHash(a, token) {
+		t.Fatalf("expected the hashed session ID, got %q (%v)", sessionID, err)
+	}
+
+	if err := SessionLogout(context.Background(), a, "old-token", "user-1", types.UserAuthOptions{}); err != nil {
+		t.Fatalf("SessionLogout failed: %v", err)
+	}
+	if _, ok := store.Sessions["old-token"]; ok {
+		t.Fatal("expected the unhashed session to be deleted on logout")
+	}
+}
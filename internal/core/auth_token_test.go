@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/jwt"
+	"github.com/dracory/auth/types"
+)
+
+func TestJWTIssue_ExtraClaimsCannotOverrideRegisteredClaims(t *testing.T) {
+	config := &types.JWTConfig{
+		Secret: []byte(strings.Repeat("s", 32)),
+		FuncClaims: func(ctx context.Context, userID string, options types.UserAuthOptions) (map[string]any, error) {
+			return map[string]any{"sub": "admin", "exp": 9999999999, "aud": "other", "role": "editor"}, nil
+		},
+	}
+	now := time.Now()
+
+	token, err := JWTIssue(context.Background(), config, "user-1", types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatalf("JWTIssue failed: %v", err)
+	}
+
+	claims, err := jwt.Verify(jwt.KeyFromConfig(config), token, jwt.Expectations{Now: now})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if claims.Subject != "user-1" {
+		t.Fatalf("expected subject user-1, got %q", claims.Subject)
+	}
+	if claims.ExpiresAt.Unix() != now.Add(types.DefaultJWTExpiration).Unix() {
+		t.Fatalf("expected default expiration, got %v", claims.ExpiresAt)
+	}
+	if claims.All["aud"] != nil {
+		t.Fatalf("expected no audience, got %v", claims.All["aud"])
+	}
+	if claims.All["role"] != "editor" || claims.ID == "" {
+		t.Fatalf("unexpected claims %v", claims.All)
+	}
+}
+
+func TestJWTUserID(t *testing.T) {
+	config := &types.JWTConfig{
+		Secret:     []byte(strings.Repeat("s", 32)),
+		Expiration: time.Minute,
+	}
+	now := time.Now()
+
+	token, err := JWTIssue(context.Background(), config, "user-1", types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatalf("JWTIssue failed: %v", err)
+	}
+
+	if userID, err := JWTUserID(context.Background(), config, token, types.UserAuthOptions{}, now); err != nil || userID != "user-1" {
+		t.Fatalf("expected user-1, got %q (%v)", userID, err)
+	}
+
+	if userID, err := JWTUserID(context.Background(), config, token, types.UserAuthOptions{}, now.Add(time.Hour)); err != nil || userID != "" {
+		t.Fatalf("expected expired token to be rejected, got %q (%v)", userID, err)
+	}
+
+	errStore := errors.New("revocation list unavailable")
+	config.FuncTokenRevoked = func(ctx context.Context, tokenID string, options types.UserAuthOptions) (bool, error) {
+		return false, errStore
+	}
+	if _, err := JWTUserID(context.Background(), config, token, types.UserAuthOptions{}, now); !errors.Is(err, errStore) {
+		t.Fatalf("expected revocation lookup error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dracory/auth/types"
+	authutils "github.com/dracory/auth/utils"
+	"github.com/dracory/str"
+)
+
+// LoginFlowKeyPrefix namespaces bound login codes in the temporary key
+// store. Unless DisableLoginCodeBinding is set, a code is stored under the
+// flow it was issued for, instead of under the code itself.
+const LoginFlowKeyPrefix = "login_flow:"
+
+// LoginFlow is a pending passwordless login, identified by the opaque flow ID
+// returned by api/login.
+type LoginFlow struct {
+	Recipient string `json:"recipient"` // the email, or the phone number with the sms channel
+	Code      string `json:"code"`
+}
+
+// LoginFlowCreate stores the code sent to the recipient under a new flow and
+// returns the flow ID, which has to be presented together with the code.
+func LoginFlowCreate(a types.AuthSharedInterface, recipient string, code string, expiresSeconds int) (string, error) {
+	temporaryKeySet := a.GetFuncTemporaryKeySet()
+	if temporaryKeySet == nil {
+		return "", errors.New("FuncTemporaryKeySet is not configured")
+	}
+
+	flowID, err := str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
+	if err != nil {
+		return "", err
+	}
+
+	value, err := json.Marshal(LoginFlow{Recipient: recipient, Code: code})
+	if err != nil {
+		return "", err
+	}
+
+	if err := temporaryKeySet(LoginFlowKeyPrefix+flowID, string(value), expiresSeconds); err != nil {
+		return "", err
+	}
+
+	return flowID, nil
+}
+
+// LoginFlowFind returns the pending login of the flow, or nil when it does
+// not exist, has expired or has been used.
+func LoginFlowFind(a types.AuthSharedInterface, flowID string) (*LoginFlow, error) {
+	temporaryKeyGet := a.GetFuncTemporaryKeyGet()
+	if temporaryKeyGet == nil {
+		return nil, errors.New("FuncTemporaryKeyGet is not configured")
+	}
+
+	if flowID == "" || !str.ContainsOnly(flowID, authutils.LoginCodeGamma(false)) {
+		return nil, nil
+	}
+
+	value, err := temporaryKeyGet(LoginFlowKeyPrefix + flowID)
+	if err != nil || value == "" {
+		return nil, nil
+	}
+
+	flow := LoginFlow{}
+	if err := json.Unmarshal([]byte(value), &flow); err != nil {
+		return nil, err
+	}
+
+	return &flow, nil
+}
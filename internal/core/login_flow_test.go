@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func TestLoginFlow(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := newTemporaryKeyStoreForTest(a)
+
+	flowID, err := LoginFlowCreate(a, "user@test.com", "BCDFGHJK", 3600)
+	if err != nil || flowID == "" {
+		t.Fatalf("LoginFlowCreate failed: %q (%v)", flowID, err)
+	}
+	if _, ok := store["BCDFGHJK"]; ok {
+		t.Fatal("expected the code not to be stored under itself")
+	}
+
+	flow, err := LoginFlowFind(a, flowID)
+	if err != nil || flow == nil || flow.Recipient != "user@test.com" || flow.Code != "BCDFGHJK" {
+		t.Fatalf("expected the flow of user@test.com, got %+v (%v)", flow, err)
+	}
+
+	otherID, err := LoginFlowCreate(a, "other@test.com", "BCDFGHJK", 3600)
+	if err != nil || otherID == flowID {
+		t.Fatalf("expected a new flow for the same code, got %q (%v)", otherID, err)
+	}
+	if flow, _ := LoginFlowFind(a, flowID); flow == nil || flow.Recipient != "user@test.com" {
+		t.Fatalf("expected flows with the same code not to collide, got %+v", flow)
+	}
+
+	for _, id := range []string{"", "unknown", "not a flow id"} {
+		if flow, err := LoginFlowFind(a, id); err != nil || flow != nil {
+			t.Errorf("LoginFlowFind(%q): expected no flow, got %+v (%v)", id, flow, err)
+		}
+	}
+
+	if err := TemporaryKeyDelete(a, LoginFlowKeyPrefix+flowID); err != nil {
+		t.Fatal(err)
+	}
+	if flow, err := LoginFlowFind(a, flowID); err != nil || flow != nil {
+		t.Fatalf("expected the used flow to be gone, got %+v (%v)", flow, err)
+	}
+}
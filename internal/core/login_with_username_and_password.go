@@ -2,16 +2,23 @@ package core
 
 import (
 	"context"
+	"time"
 
 	"github.com/dracory/auth/types"
 	authutils "github.com/dracory/auth/utils"
-	"github.com/dracory/str"
 )
 
 type LoginWithUsernameAndPasswordResult struct {
 	ErrorMessage   string
 	SuccessMessage string
 	Token          string
+	UserID         string
+
+	// TwoFactorRequired is set when the password was correct but the user
+	// must still enter a TOTP code. Token is empty in that case and the
+	// client must present TwoFactorToken to the 2FA verification endpoint.
+	TwoFactorRequired bool
+	TwoFactorToken    string
 }
 
 func LoginWithUsernameAndPassword(
@@ -39,14 +46,20 @@ func LoginWithUsernameAndPassword(
 	}
 
 	loginFn := a.GetFuncUserLogin()
-	storeFn := a.GetFuncUserStoreAuthToken()
 	logger := a.GetLogger()
 
+	// Locked accounts are refused before the password is checked, the same
+	// way whether the account exists or not
+	if !AccountLockedUntil(a, email, time.Now()).IsZero() {
+		response.ErrorMessage = AccountLockedMessage
+		return response
+	}
+
 	userID, err := loginFn(ctx, email, password, options)
 
-	if err != nil {
+	if err != nil || userID == "" {
 		response.ErrorMessage = "Invalid credentials"
-		if logger != nil {
+		if err != nil && logger != nil {
 			logger.Error("login with username and password failed",
 				"error", err,
 				"email", email,
@@ -54,24 +67,37 @@ func LoginWithUsernameAndPassword(
 				"user_agent", options.UserAgent,
 			)
 		}
+		if errLockout := AccountFailureRecord(ctx, a, email, options, time.Now()); errLockout != nil && logger != nil {
+			logger.Error("account lockout failure record failed",
+				"error", errLockout,
+				"email", email,
+				"ip", options.UserIp,
+				"user_agent", options.UserAgent,
+			)
+		}
 		return response
 	}
 
-	if userID == "" {
-		response.ErrorMessage = "Invalid credentials"
-		return response
+	// The password was right; wrong two-factor codes are counted against
+	// the user separately
+	if errLockout := AccountFailuresReset(a, email); errLockout != nil && logger != nil {
+		logger.Error("account lockout reset failed",
+			"error", errLockout,
+			"email", email,
+			"user_id", userID,
+			"ip", options.UserIp,
+			"user_agent", options.UserAgent,
+		)
 	}
 
-	// Reuse the shared login/verification gamma from utils to avoid
-	// duplicating the character set. We keep length at 32 for auth tokens.
-	token, errRandom := str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
-	if errRandom != nil {
-		response.ErrorMessage = "Failed to generate verification code. Please try again later"
+	totpSecret, errTotp := TwoFactorSecretFind(ctx, a, userID, options)
+	if errTotp != nil {
+		response.ErrorMessage = "Failed to process request. Please try again later"
 		if logger != nil {
-			logger.Error("auth token generation failed",
-				"error", errRandom,
-				"error_code", "CODE_GENERATION_FAILED",
+			logger.Error("two-factor secret lookup failed",
+				"error", errTotp,
 				"email", email,
+				"user_id", userID,
 				"ip", options.UserIp,
 				"user_agent", options.UserAgent,
 			)
@@ -79,13 +105,36 @@ func LoginWithUsernameAndPassword(
 		return response
 	}
 
-	errSession := storeFn(ctx, token, userID, options)
+	if totpSecret != "" {
+		challengeToken, errChallenge := TwoFactorChallengeCreate(a, userID, types.AuthMethodPassword)
+		if errChallenge != nil {
+			response.ErrorMessage = "Failed to process request. Please try again later"
+			if logger != nil {
+				logger.Error("two-factor challenge store failed",
+					"error", errChallenge,
+					"error_code", "TOKEN_STORE_FAILED",
+					"email", email,
+					"user_id", userID,
+					"ip", options.UserIp,
+					"user_agent", options.UserAgent,
+				)
+			}
+			return response
+		}
+
+		response.SuccessMessage = "two-factor authentication required"
+		response.TwoFactorRequired = true
+		response.TwoFactorToken = challengeToken
+		return response
+	}
 
-	if errSession != nil {
+	ctx = ContextWithLogin(ctx, types.AuthMethodPassword, types.MFALevelSingleFactor, time.Now())
+	token, errToken := AuthTokenIssue(ctx, a, userID, options)
+	if errToken != nil {
 		response.ErrorMessage = "Failed to process request. Please try again later"
 		if logger != nil {
 			logger.Error("auth token store failed",
-				"error", errSession,
+				"error", errToken,
 				"error_code", "TOKEN_STORE_FAILED",
 				"email", email,
 				"user_id", userID,
@@ -98,5 +147,6 @@ func LoginWithUsernameAndPassword(
 
 	response.SuccessMessage = "login success"
 	response.Token = token
+	response.UserID = userID
 	return response
 }
@@ -72,3 +72,113 @@ func TestCoreLoginWithUsernameAndPassword_Success(t *testing.T) {
 		t.Fatalf("expected stored userID 'user123', got %q", storedUserID)
 	}
 }
+
+func TestCoreLoginWithUsernameAndPassword_TwoFactorRequired(t *testing.T) {
+	a := newPasswordAuthForLoginTest(t)
+
+	a.SetFuncUserLogin(func(ctx context.Context, email, password string, options types.UserAuthOptions) (string, error) {
+		return "user123", nil
+	})
+
+	tokenStored := false
+	a.SetFuncUserStoreAuthToken(func(ctx context.Context, token, userID string, options types.UserAuthOptions) error {
+		tokenStored = true
+		return nil
+	})
+
+	a.SetFuncUserTotpSecretFind(func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error) {
+		return "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", nil
+	})
+
+	challenges := map[string]string{}
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		challenges[key] = value
+		return nil
+	})
+
+	resp := core.LoginWithUsernameAndPassword(context.Background(), a, "test@test.com", "password", types.UserAuthOptions{})
+
+	if resp.ErrorMessage != "" {
+		t.Fatalf("expected no error, got %q", resp.ErrorMessage)
+	}
+	if !resp.TwoFactorRequired {
+		t.Fatalf("expected two-factor to be required")
+	}
+	if resp.Token != "" || tokenStored {
+		t.Fatalf("expected no auth token to be issued before the second factor")
+	}
+	if got := challenges[core.TwoFactorChallengeKeyPrefix+resp.TwoFactorToken]; got != "password:user123" {
+		t.Fatalf("expected pending challenge for user123, got %q", got)
+	}
+}
+
+func TestCoreLoginWithUsernameAndPassword_NoSecretSkipsTwoFactor(t *testing.T) {
+	a := newPasswordAuthForLoginTest(t)
+
+	a.SetFuncUserLogin(func(ctx context.Context, email, password string, options types.UserAuthOptions) (string, error) {
+		return "user123", nil
+	})
+	a.SetFuncUserStoreAuthToken(func(ctx context.Context, token, userID string, options types.UserAuthOptions) error {
+		return nil
+	})
+	a.SetFuncUserTotpSecretFind(func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error) {
+		return "", nil
+	})
+
+	resp := core.LoginWithUsernameAndPassword(context.Background(), a, "test@test.com", "password", types.UserAuthOptions{})
+
+	if resp.TwoFactorRequired {
+		t.Fatalf("expected two-factor not to be required for users without a secret")
+	}
+	if resp.Token == "" {
+		t.Fatalf("expected auth token to be issued")
+	}
+}
+
+func TestCoreLoginWithUsernameAndPassword_AccountLockout(t *testing.T) {
+	a := newPasswordAuthForLoginTest(t)
+
+	store := map[string]string{}
+	a.SetFuncTemporaryKeyGet(func(key string) (string, error) { return store[key], nil })
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	})
+	a.SetAccountLockout(&types.AccountLockoutConfig{MaxFailures: 2})
+
+	logins := 0
+	a.SetFuncUserLogin(func(ctx context.Context, email, password string, options types.UserAuthOptions) (string, error) {
+		logins++
+		if email == "test@test.com" && password == "right" {
+			return "user123", nil
+		}
+		return "", nil
+	})
+	a.SetFuncUserStoreAuthToken(func(ctx context.Context, token, userID string, options types.UserAuthOptions) error {
+		return nil
+	})
+
+	// A success clears the failures before the limit
+	core.LoginWithUsernameAndPassword(context.Background(), a, "test@test.com", "wrong", types.UserAuthOptions{})
+	if resp := core.LoginWithUsernameAndPassword(context.Background(), a, "test@test.com", "right", types.UserAuthOptions{}); resp.Token == "" {
+		t.Fatalf("expected a token, got %q", resp.ErrorMessage)
+	}
+
+	for _, email := range []string{"test@test.com", "unknown@test.com"} {
+		for i := 0; i < 2; i++ {
+			if resp := core.LoginWithUsernameAndPassword(context.Background(), a, email, "wrong", types.UserAuthOptions{}); resp.ErrorMessage != "Invalid credentials" {
+				t.Fatalf("%s: expected %q, got %q", email, "Invalid credentials", resp.ErrorMessage)
+			}
+		}
+	}
+
+	logins = 0
+	for _, email := range []string{"test@test.com", "unknown@test.com"} {
+		if resp := core.LoginWithUsernameAndPassword(context.Background(), a, email, "right", types.UserAuthOptions{}); resp.ErrorMessage != core.AccountLockedMessage {
+			t.Fatalf("%s: expected %q, got %q", email, core.AccountLockedMessage, resp.ErrorMessage)
+		}
+	}
+	if logins != 0 {
+		t.Fatalf("expected locked accounts not to be checked, got %d logins", logins)
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/types"
+	authutils "github.com/dracory/auth/utils"
+	"github.com/dracory/str"
+)
+
+// MagicLinkCookieName is the cookie binding magic links to the browser the
+// code was requested from, when MagicLinkConfig.RequireSameBrowser is set.
+const MagicLinkCookieName = "login_link"
+
+var (
+	// ErrMagicLinkInvalid is returned for links that are malformed or not
+	// signed with the configured secret.
+	ErrMagicLinkInvalid = errors.New("magic link is invalid")
+
+	// ErrMagicLinkExpired is returned for links past their expiry time, or
+	// whose code is no longer stored.
+	ErrMagicLinkExpired = errors.New("magic link has expired")
+
+	// ErrMagicLinkOtherBrowser is returned for links opened in another
+	// browser than the one the code was requested from.
+	ErrMagicLinkOtherBrowser = errors.New("magic link was requested from another browser")
+)
+
+// MagicLink is the content of a verified magic link.
+type MagicLink struct {
+	Code   string
+	FlowID string // set when login codes are bound to their flow
+}
+
+// magicLinkKeyPrefix namespaces the codes of the magic links in the
+// temporary key store, keyed by the random token of the link.
+const magicLinkKeyPrefix = "magic_link:"
+
+// magicLinkPayload is signed into the token of the link. Token is the random
+// key the code is stored under, so the link never reveals the code. Browser
+// is the SHA-256 of the browser cookie, so the link does not reveal it
+// either.
+type magicLinkPayload struct {
+	Token     string `json:"t"`
+	ExpiresAt int64  `json:"e"`
+	Browser   string `json:"b,omitempty"`
+}
+
+// magicLinkCode is what the token of a link is stored with.
+type magicLinkCode struct {
+	Code   string `json:"c"`
+	FlowID string `json:"f,omitempty"`
+}
+
+// MagicLinkCreate returns the absolute login link redeeming the code, valid
+// for as long as the code. The code is stored under a random token of the
+// link, and never put in the link itself. With RequireSameBrowser it also sets the cookie
+// the link is bound to, reusing the one of an earlier request so the links
+// sent before keep working.
+func MagicLinkCreate(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface, code string, flowID string, expiresSeconds int) (string, error) {
+	config := a.GetMagicLink()
+	if config == nil {
+		return "", errFuncNotConfigured("MagicLink")
+	}
+
+	set := a.GetFuncTemporaryKeySet()
+	if set == nil {
+		return "", errFuncNotConfigured("FuncTemporaryKeySet")
+	}
+
+	linkToken, err := str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
+	if err != nil {
+		return "", err
+	}
+
+	stored, err := json.Marshal(magicLinkCode{Code: code, FlowID: flowID})
+	if err != nil {
+		return "", err
+	}
+
+	if err := set(magicLinkKeyPrefix+linkToken, string(stored), expiresSeconds); err != nil {
+		return "", err
+	}
+
+	payload := magicLinkPayload{
+		Token:     linkToken,
+		ExpiresAt: time.Now().Add(time.Duration(expiresSeconds) * time.Second).Unix(),
+	}
+
+	if config.RequireSameBrowser {
+		browser := magicLinkBrowserCookie(r)
+		if browser == "" {
+			browser, err = str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
+			if err != nil {
+				return "", err
+			}
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     MagicLinkCookieName,
+			Value:    browser,
+			Path:     "/",
+			MaxAge:   expiresSeconds,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode, // the link is opened from the mail client
+		})
+
+		payload.Browser = magicLinkHash(browser)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	token := encoded + "." + magicLinkSign(config.Secret, encoded)
+
+	return links.Absolute(r, links.LoginLink(a.GetEndpoint())) + "?token=" + url.QueryEscape(token), nil
+}
+
+// MagicLinkVerify checks the signature and the expiry time of the token of a
+// magic link and, with RequireSameBrowser, that it is opened in the browser
+// the code was requested from, and returns the code stored for the link. The
+// code itself is checked and consumed by the caller.
+func MagicLinkVerify(r *http.Request, a types.AuthSharedInterface, token string, now time.Time) (*MagicLink, error) {
+	config := a.GetMagicLink()
+	if config == nil {
+		return nil, errFuncNotConfigured("MagicLink")
+	}
+
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(magicLinkSign(config.Secret, encoded))) {
+		return nil, ErrMagicLinkInvalid
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrMagicLinkInvalid
+	}
+
+	payload := magicLinkPayload{}
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Token == "" {
+		return nil, ErrMagicLinkInvalid
+	}
+
+	if !now.Before(time.Unix(payload.ExpiresAt, 0)) {
+		return nil, ErrMagicLinkExpired
+	}
+
+	if config.RequireSameBrowser {
+		browser := magicLinkBrowserCookie(r)
+		if browser == "" || payload.Browser == "" || subtle.ConstantTimeCompare([]byte(magicLinkHash(browser)), []byte(payload.Browser)) != 1 {
+			return nil, ErrMagicLinkOtherBrowser
+		}
+	}
+
+	get := a.GetFuncTemporaryKeyGet()
+	if get == nil {
+		return nil, errFuncNotConfigured("FuncTemporaryKeyGet")
+	}
+
+	// Stores report unknown keys as errors
+	value, err := get(magicLinkKeyPrefix + payload.Token)
+	if err != nil || value == "" {
+		return nil, ErrMagicLinkExpired
+	}
+
+	stored := magicLinkCode{}
+	if err := json.Unmarshal([]byte(value), &stored); err != nil || stored.Code == "" {
+		return nil, ErrMagicLinkInvalid
+	}
+
+	return &MagicLink{Code: stored.Code, FlowID: stored.FlowID}, nil
+}
+
+// MagicLinkClearCookie removes the browser cookie once the link is used.
+func MagicLinkClearCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     MagicLinkCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func magicLinkBrowserCookie(r *http.Request) string {
+	cookie, err := r.Cookie(MagicLinkCookieName)
+	if err != nil || !str.ContainsOnly(cookie.Value, authutils.LoginCodeGamma(false)) {
+		return ""
+	}
+	return cookie.Value
+}
+
+func magicLinkSign(secret string, encoded string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func magicLinkHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
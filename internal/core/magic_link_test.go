@@ -0,0 +1,128 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func magicLinkToken(t *testing.T, link string) string {
+	t.Helper()
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Query().Get("token")
+}
+
+func TestMagicLink(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := newTemporaryKeyStoreForTest(a)
+	a.SetMagicLink(&types.MagicLinkConfig{Secret: strings.Repeat("s", types.MinMagicLinkSecretLength)})
+
+	r := httptest.NewRequest(http.MethodPost, "http://app.test/auth/api/login", nil)
+	link, err := MagicLinkCreate(httptest.NewRecorder(), r, a, "BCDFGHJK", "flow-1", 3600)
+	if err != nil {
+		t.Fatalf("MagicLinkCreate failed: %v", err)
+	}
+	if !strings.HasPrefix(link, "http://localhost/auth/login-link?token=") {
+		t.Fatalf("unexpected link %q", link)
+	}
+
+	token := magicLinkToken(t, link)
+	open := httptest.NewRequest(http.MethodGet, link, nil)
+
+	// The link carries a random token, never the code it redeems
+	encoded, signature, _ := strings.Cut(token, ".")
+	if payload, _ := base64.RawURLEncoding.DecodeString(encoded); strings.Contains(string(payload), "BCDFGHJK") {
+		t.Fatalf("expected the link not to reveal the code, got %s", payload)
+	}
+
+	magicLink, err := MagicLinkVerify(open, a, token, time.Now())
+	if err != nil || magicLink.Code != "BCDFGHJK" || magicLink.FlowID != "flow-1" {
+		t.Fatalf("expected the code of the link, got %+v (%v)", magicLink, err)
+	}
+
+	if _, err := MagicLinkVerify(open, a, token, time.Now().Add(time.Hour)); !errors.Is(err, ErrMagicLinkExpired) {
+		t.Fatalf("expected the link to expire, got %v", err)
+	}
+
+	for _, tampered := range []string{"", encoded, encoded + ".", encoded + "x." + signature, "e30." + signature} {
+		if _, err := MagicLinkVerify(open, a, tampered, time.Now()); !errors.Is(err, ErrMagicLinkInvalid) {
+			t.Errorf("expected %q to be rejected, got %v", tampered, err)
+		}
+	}
+
+	// Once the code is gone, so is the link
+	for key := range store {
+		delete(store, key)
+	}
+	if _, err := MagicLinkVerify(open, a, token, time.Now()); !errors.Is(err, ErrMagicLinkExpired) {
+		t.Fatalf("expected a link without its code to be rejected, got %v", err)
+	}
+
+	a.SetMagicLink(&types.MagicLinkConfig{Secret: strings.Repeat("t", types.MinMagicLinkSecretLength)})
+	if _, err := MagicLinkVerify(open, a, token, time.Now()); !errors.Is(err, ErrMagicLinkInvalid) {
+		t.Fatalf("expected a link signed with another secret to be rejected, got %v", err)
+	}
+}
+
+func TestMagicLink_RequireSameBrowser(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	newTemporaryKeyStoreForTest(a)
+	a.SetMagicLink(&types.MagicLinkConfig{
+		Secret:             strings.Repeat("s", types.MinMagicLinkSecretLength),
+		RequireSameBrowser: true,
+	})
+
+	recorder := httptest.NewRecorder()
+	link, err := MagicLinkCreate(recorder, httptest.NewRequest(http.MethodPost, "/auth/api/login", nil), a, "BCDFGHJK", "", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != MagicLinkCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("expected the browser cookie, got %+v", cookies)
+	}
+
+	// A second request from the same browser keeps the cookie, so both
+	// links work
+	second := httptest.NewRequest(http.MethodPost, "/auth/api/login", nil)
+	second.AddCookie(cookies[0])
+	recorder = httptest.NewRecorder()
+	secondLink, err := MagicLinkCreate(recorder, second, a, "CDFGHJKL", "", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := recorder.Result().Cookies()[0].Value; value != cookies[0].Value {
+		t.Fatalf("expected the browser cookie to be reused, got %q", value)
+	}
+
+	for _, l := range []string{link, secondLink} {
+		open := httptest.NewRequest(http.MethodGet, l, nil)
+		if _, err := MagicLinkVerify(open, a, magicLinkToken(t, l), time.Now()); !errors.Is(err, ErrMagicLinkOtherBrowser) {
+			t.Fatalf("expected the link to be rejected without the cookie, got %v", err)
+		}
+
+		open.AddCookie(cookies[0])
+		if _, err := MagicLinkVerify(open, a, magicLinkToken(t, l), time.Now()); err != nil {
+			t.Fatalf("expected the link to be accepted with the cookie, got %v", err)
+		}
+	}
+
+	other := httptest.NewRequest(http.MethodGet, link, nil)
+	other.AddCookie(&http.Cookie{Name: MagicLinkCookieName, Value: "BCDFGHJKBCDFGHJKBCDFGHJKBCDFGHJK"})
+	if _, err := MagicLinkVerify(other, a, magicLinkToken(t, link), time.Now()); !errors.Is(err, ErrMagicLinkOtherBrowser) {
+		t.Fatalf("expected the cookie of another browser to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"github.com/dracory/auth/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHash hashes the password for FuncUserRegisterWithPasswordHash with
+// FuncPasswordHash, or bcrypt when it is not set.
+func PasswordHash(a types.AuthSharedInterface, password string) (string, error) {
+	if fn := a.GetFuncPasswordHash(); fn != nil {
+		return fn(password)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/dracory/auth/internal/jwt"
+	"github.com/dracory/auth/types"
+)
+
+type loginContextKey struct{}
+
+// login records how the user signed in, for the session or JWT issued with
+// it.
+type login struct {
+	method          types.AuthMethod
+	mfaLevel        types.MFALevel
+	authenticatedAt time.Time
+}
+
+// ContextWithLogin returns a copy of ctx recording how the user signed in,
+// stored with the session or in the JWT issued with the context. A zero
+// authenticatedAt marks sign ins where the user did not prove their
+// identity, e.g. with a remember-me token.
+func ContextWithLogin(ctx context.Context, method types.AuthMethod, mfaLevel types.MFALevel, authenticatedAt time.Time) context.Context {
+	return context.WithValue(ctx, loginContextKey{}, login{
+		method:          method,
+		mfaLevel:        mfaLevel,
+		authenticatedAt: authenticatedAt,
+	})
+}
+
+// loginFromContext returns how the user signed in. Flows that do not record
+// it are taken as signing in now, by unknown means.
+func loginFromContext(ctx context.Context, now time.Time) login {
+	if details, ok := ctx.Value(loginContextKey{}).(login); ok {
+		return details
+	}
+	return login{authenticatedAt: now}
+}
+
+// sessionPrincipal returns the caller authenticated with the session stored
+// under sessionID.
+func sessionPrincipal(session types.Session, sessionID string) *types.Principal {
+	authenticatedAt := session.AuthenticatedAt
+	if authenticatedAt.IsZero() && session.AuthMethod == types.AuthMethodUnknown {
+		// Sessions without the login details were signed in when created
+		authenticatedAt = session.CreatedAt
+	}
+
+	return &types.Principal{
+		UserID:          session.UserID,
+		SessionID:       sessionID,
+		AuthMethod:      session.AuthMethod,
+		AuthenticatedAt: authenticatedAt,
+		MFALevel:        session.MFALevel,
+		ImpersonatorID:  session.ImpersonatorID,
+	}
+}
+
+// jwtLoginClaims adds the login details to the claims of a JWT, as the
+// "amr" and "auth_time" claims of OpenID Connect.
+func jwtLoginClaims(claims map[string]any, details login) {
+	if details.method != types.AuthMethodUnknown {
+		amr := []string{string(details.method)}
+		if details.mfaLevel == types.MFALevelMultiFactor {
+			amr = append(amr, "mfa")
+		}
+		claims["amr"] = amr
+	}
+
+	if !details.authenticatedAt.IsZero() {
+		claims["auth_time"] = details.authenticatedAt.Unix()
+	}
+}
+
+// jwtPrincipal returns the caller authenticated with the verified JWT.
+func jwtPrincipal(claims *jwt.Claims) *types.Principal {
+	principal := &types.Principal{
+		UserID:    claims.Subject,
+		SessionID: claims.ID,
+	}
+
+	if amr, ok := claims.All["amr"].([]any); ok && len(amr) > 0 {
+		methods := []string{}
+		for _, value := range amr {
+			if method, ok := value.(string); ok {
+				methods = append(methods, method)
+			}
+		}
+
+		if len(methods) > 0 {
+			principal.AuthMethod = types.AuthMethod(methods[0])
+			principal.MFALevel = types.MFALevelSingleFactor
+		}
+		if slices.Contains(methods, "mfa") {
+			principal.MFALevel = types.MFALevelMultiFactor
+		}
+	}
+
+	if authTime, ok := claims.All["auth_time"].(float64); ok {
+		principal.AuthenticatedAt = time.Unix(int64(authTime), 0)
+	}
+
+	return principal
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestSessionCreate_RecordsLogin(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+
+	authenticatedAt := time.Now().Add(-time.Second)
+	ctx := ContextWithLogin(context.Background(), types.AuthMethodPassword, types.MFALevelMultiFactor, authenticatedAt)
+	if err := SessionCreate(ctx, a, "token-1", "user-1", types.UserAuthOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	principal, _, err := AuthTokenAuthenticate(context.Background(), a, "token-1", types.UserAuthOptions{})
+	if err != nil || principal == nil {
+		t.Fatalf("expected the session to resolve, got %v", err)
+	}
+	if principal.AuthMethod != types.AuthMethodPassword || principal.MFALevel != types.MFALevelMultiFactor || !principal.AuthenticatedAt.Equal(authenticatedAt) {
+		t.Fatalf("expected the login of the session, got %+v", principal)
+	}
+
+	// Flows that do not record the login, and sessions stored without it,
+	// count as signed in when the session was created
+	if err := SessionCreate(context.Background(), a, "token-2", "user-1", types.UserAuthOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	store.Sessions["token-3"] = types.Session{ID: "token-3", UserID: "user-1", CreatedAt: authenticatedAt}
+
+	for _, token := range []string{"token-2", "token-3"} {
+		principal, _, err := AuthTokenAuthenticate(context.Background(), a, token, types.UserAuthOptions{})
+		if err != nil || principal == nil || principal.AuthMethod != types.AuthMethodUnknown || principal.AuthenticatedAt.IsZero() {
+			t.Fatalf("%s: expected an unknown method signed in at creation, got %+v (%v)", token, principal, err)
+		}
+	}
+
+	// Restored sessions are not a recent sign in
+	ctx = ContextWithLogin(context.Background(), types.AuthMethodRememberMe, types.MFALevelSingleFactor, time.Time{})
+	if err := SessionCreate(ctx, a, "token-4", "user-1", types.UserAuthOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if principal, _, _ := AuthTokenAuthenticate(context.Background(), a, "token-4", types.UserAuthOptions{}); principal == nil || !principal.AuthenticatedAt.IsZero() {
+		t.Fatalf("expected no sign in time for a remember-me session, got %+v", principal)
+	}
+}
+
+func TestJWTPrincipal_LoginClaims(t *testing.T) {
+	config := &types.JWTConfig{
+		Secret: []byte(strings.Repeat("s", 32)),
+		FuncClaims: func(ctx context.Context, userID string, options types.UserAuthOptions) (map[string]any, error) {
+			return map[string]any{"amr": []string{"passkey", "mfa"}, "auth_time": 9999999999}, nil
+		},
+	}
+	now := time.Now()
+
+	ctx := ContextWithLogin(context.Background(), types.AuthMethodPassword, types.MFALevelMultiFactor, now.Add(-time.Minute))
+	token, err := JWTIssue(ctx, config, "user-1", types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := JWTPrincipal(context.Background(), config, token, types.UserAuthOptions{}, now)
+	if err != nil || principal == nil {
+		t.Fatalf("expected the token to resolve, got %v", err)
+	}
+	if principal.UserID != "user-1" || principal.SessionID == "" || principal.AuthMethod != types.AuthMethodPassword || principal.MFALevel != types.MFALevelMultiFactor {
+		t.Fatalf("expected the login of the token, got %+v", principal)
+	}
+	if principal.AuthenticatedAt.Unix() != now.Add(-time.Minute).Unix() {
+		t.Fatalf("expected the sign in time of the token, got %v", principal.AuthenticatedAt)
+	}
+
+	// Tokens issued without a sign in carry no login claims
+	ctx = ContextWithLogin(context.Background(), types.AuthMethodRefreshToken, types.MFALevelUnknown, time.Time{})
+	config.FuncClaims = nil
+	token, err = JWTIssue(ctx, config, "user-1", types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, _ = JWTPrincipal(context.Background(), config, token, types.UserAuthOptions{}, now)
+	if principal == nil || principal.AuthMethod != types.AuthMethodRefreshToken || principal.MFALevel != types.MFALevelSingleFactor || !principal.AuthenticatedAt.IsZero() {
+		t.Fatalf("expected a refreshed token without a sign in time, got %+v", principal)
+	}
+}
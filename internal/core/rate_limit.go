@@ -0,0 +1,40 @@
+package core
+
+import "context"
+
+type rateLimitOutcomeContextKey struct{}
+
+// RateLimitOutcome records whether a rate limited request succeeded. The
+// rate limit counts requests that do not report a success as failed
+// attempts, and clears the failed attempts on success.
+type RateLimitOutcome struct {
+	succeeded bool
+
+	// parent is the outcome of the rate limit wrapping this one, e.g. the
+	// per-IP limit around a per-recipient limit
+	parent *RateLimitOutcome
+}
+
+// Succeeded reports whether the request reported a success.
+func (o *RateLimitOutcome) Succeeded() bool {
+	return o != nil && o.succeeded
+}
+
+// ContextWithRateLimitOutcome returns a copy of ctx the rate limited handler
+// reports its success to, and the outcome read once it has returned.
+func ContextWithRateLimitOutcome(ctx context.Context) (context.Context, *RateLimitOutcome) {
+	parent, _ := ctx.Value(rateLimitOutcomeContextKey{}).(*RateLimitOutcome)
+	outcome := &RateLimitOutcome{parent: parent}
+	return context.WithValue(ctx, rateLimitOutcomeContextKey{}, outcome), outcome
+}
+
+// RateLimitSucceeded reports that the request succeeded, e.g. the password
+// or code was right, so the failed attempts are cleared instead of the
+// request being counted as one. It does nothing outside rate limited
+// requests.
+func RateLimitSucceeded(ctx context.Context) {
+	outcome, _ := ctx.Value(rateLimitOutcomeContextKey{}).(*RateLimitOutcome)
+	for ; outcome != nil; outcome = outcome.parent {
+		outcome.succeeded = true
+	}
+}
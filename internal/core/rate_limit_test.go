@@ -0,0 +1,23 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRateLimitSucceeded(t *testing.T) {
+	// Outside rate limited requests nothing happens
+	RateLimitSucceeded(context.Background())
+
+	ctx, outer := ContextWithRateLimitOutcome(context.Background())
+	if outer.Succeeded() {
+		t.Fatal("expected requests not to succeed until reported")
+	}
+
+	ctx, inner := ContextWithRateLimitOutcome(ctx)
+	RateLimitSucceeded(ctx)
+
+	if !inner.Succeeded() || !outer.Succeeded() {
+		t.Fatalf("expected the success to reach every rate limit, got %v %v", inner.Succeeded(), outer.Succeeded())
+	}
+}
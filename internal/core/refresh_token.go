@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// refreshTokenBytes is the entropy of a refresh token (256 bits).
+const refreshTokenBytes = 32
+
+// RefreshTokenHash returns the hash under which a refresh token is stored.
+// Refresh tokens are random, so a plain SHA-256 is sufficient.
+func RefreshTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshTokenIssue generates a refresh token for the user and stores its
+// hash. An empty familyID starts a new family, as on login; rotations pass
+// the family of the exchanged token. The token is linked to the session
+// issued with it, so revoking the session revokes the family.
+func RefreshTokenIssue(ctx context.Context, config *types.RefreshTokenConfig, userID string, familyID string, sessionID string, options types.UserAuthOptions, now time.Time) (string, error) {
+	if config == nil || config.FuncRefreshTokenStore == nil {
+		return "", errors.New("refresh tokens are not configured")
+	}
+
+	token, err := randomURLString(refreshTokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	if familyID == "" {
+		familyID, err = randomURLString(16)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	expiration := config.Expiration
+	if expiration <= 0 {
+		expiration = types.DefaultRefreshTokenExpiration
+	}
+
+	record := types.RefreshToken{
+		TokenHash: RefreshTokenHash(token),
+		FamilyID:  familyID,
+		UserID:    userID,
+		SessionID: sessionID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiration),
+	}
+
+	if err := config.FuncRefreshTokenStore(ctx, record, options); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RefreshTokenRevoke revokes the family of the refresh token, e.g. on
+// logout. Unknown tokens are ignored.
+func RefreshTokenRevoke(ctx context.Context, config *types.RefreshTokenConfig, token string, options types.UserAuthOptions) error {
+	if config == nil || token == "" {
+		return nil
+	}
+
+	record, err := config.FuncRefreshTokenFindByHash(ctx, RefreshTokenHash(token), options)
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return nil
+	}
+
+	return config.FuncRefreshTokenFamilyRevoke(ctx, record.FamilyID, options)
+}
+
+func randomURLString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -58,13 +58,44 @@ func RegisterWithUsernameAndPassword(
 	}
 
 	registerFn := a.GetFuncUserRegister()
-	if registerFn == nil {
+	registerWithHashFn := a.GetFuncUserRegisterWithPasswordHash()
+	if registerFn == nil && registerWithHashFn == nil {
 		response.ErrorMessage = "registration failed. FuncUserRegister function not defined"
 		return response
 	}
 
+	logger := a.GetLogger()
+
+	// With FuncUserRegisterWithPasswordHash the password is hashed right
+	// away, so the plaintext is never stored, not even while pending
+	passwordHash := ""
+	if registerWithHashFn != nil {
+		var errHash error
+		passwordHash, errHash = PasswordHash(a, password)
+		if errHash != nil {
+			response.ErrorMessage = "Failed to process request. Please try again later"
+			if logger != nil {
+				logger.Error("registration password hashing failed",
+					"error", errHash,
+					"error_code", "PASSWORD_HASH_FAILED",
+					"email", email,
+					"ip", options.UserIp,
+					"user_agent", options.UserAgent,
+				)
+			}
+			return response
+		}
+	}
+
 	if !a.IsVerificationEnabled() {
-		if err := registerFn(ctx, email, password, firstName, lastName, options); err != nil {
+		var err error
+		if registerWithHashFn != nil {
+			err = registerWithHashFn(ctx, email, passwordHash, firstName, lastName, options)
+		} else {
+			err = registerFn(ctx, email, password, firstName, lastName, options)
+		}
+
+		if err != nil {
 			response.ErrorMessage = "registration failed."
 			return response
 		}
@@ -73,8 +104,6 @@ func RegisterWithUsernameAndPassword(
 		return response
 	}
 
-	logger := a.GetLogger()
-
 	verificationCode, errRandom := authutils.GenerateVerificationCode(a.GetDisableRateLimit())
 	if errRandom != nil {
 		response.ErrorMessage = "Failed to generate verification code. Please try again later"
@@ -90,12 +119,18 @@ func RegisterWithUsernameAndPassword(
 		return response
 	}
 
-	jsonPayload, errJson := json.Marshal(map[string]string{
+	payload := map[string]string{
 		"email":      email,
 		"first_name": firstName,
 		"last_name":  lastName,
-		"password":   password,
-	})
+	}
+	if registerWithHashFn != nil {
+		payload["password_hash"] = passwordHash
+	} else {
+		payload["password"] = password
+	}
+
+	jsonPayload, errJson := json.Marshal(payload)
 	if errJson != nil {
 		response.ErrorMessage = "Failed to process request. Please try again later"
 		if logger != nil {
@@ -2,12 +2,14 @@ package core_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/internal/testutils"
 	"github.com/dracory/auth/types"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func newPasswordAuthForRegisterTest(t *testing.T) types.AuthPasswordInterface {
@@ -99,6 +101,63 @@ func TestCoreRegisterWithUsernameAndPassword_VerificationEnabled_Success(t *test
 	}
 }
 
+func TestCoreRegisterWithUsernameAndPassword_PasswordHash(t *testing.T) {
+	a := newPasswordAuthForRegisterTest(t)
+	a.SetPasswordStrength(&types.PasswordStrengthConfig{MinLength: 4})
+
+	var registeredHash string
+	a.SetFuncUserRegisterWithPasswordHash(func(ctx context.Context, email, passwordHash, firstName, lastName string, options types.UserAuthOptions) error {
+		registeredHash = passwordHash
+		return nil
+	})
+
+	resp := core.RegisterWithUsernameAndPassword(context.Background(), "test@test.com", "pass", "John", "Doe", types.UserAuthOptions{}, a, time.Hour)
+	if resp.SuccessMessage != "registration success" {
+		t.Fatalf("expected success, got %+v", resp)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(registeredHash), []byte("pass")) != nil {
+		t.Fatalf("expected a bcrypt hash of the password, got %q", registeredHash)
+	}
+}
+
+func TestCoreRegisterWithUsernameAndPassword_PasswordHash_PendingPayload(t *testing.T) {
+	a := newPasswordAuthForRegisterTest(t)
+	a.SetPasswordStrength(&types.PasswordStrengthConfig{MinLength: 4})
+	SetVerificationForTest(a, true)
+
+	a.SetFuncUserRegisterWithPasswordHash(func(ctx context.Context, email, passwordHash, firstName, lastName string, options types.UserAuthOptions) error {
+		return nil
+	})
+	a.SetFuncPasswordHash(func(password string) (string, error) {
+		return "hashed-" + password, nil
+	})
+
+	var storedValue string
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		storedValue = value
+		return nil
+	})
+	a.SetFuncEmailTemplateRegisterCode(func(ctx context.Context, email string, code string, options types.UserAuthOptions) string {
+		return "body"
+	})
+	a.SetFuncEmailSend(func(ctx context.Context, userID string, subject string, body string) error {
+		return nil
+	})
+
+	resp := core.RegisterWithUsernameAndPassword(context.Background(), "test@test.com", "pass", "John", "Doe", types.UserAuthOptions{}, a, time.Hour)
+	if resp.ErrorMessage != "" {
+		t.Fatalf("expected no error, got %q", resp.ErrorMessage)
+	}
+
+	payload := map[string]string{}
+	if err := json.Unmarshal([]byte(storedValue), &payload); err != nil {
+		t.Fatalf("unexpected payload %q: %v", storedValue, err)
+	}
+	if _, ok := payload["password"]; ok || payload["password_hash"] != "hashed-pass" {
+		t.Fatalf("expected only the password hash to be stored, got %q", storedValue)
+	}
+}
+
 // SetVerificationForTest toggles verification flag on the underlying authSharedTest
 // used by the internal testutils helper. This relies on the concrete type used there.
 func SetVerificationForTest(a types.AuthSharedInterface, verification bool) {
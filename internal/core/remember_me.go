@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// rememberValidatorBytes is the entropy of a remember-me validator (256 bits).
+const rememberValidatorBytes = 32
+
+// RememberMeRequested reports whether the login request asks to be
+// remembered.
+func RememberMeRequested(r *http.Request) bool {
+	switch strings.ToLower(req.GetStringTrimmed(r, "remember")) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// RememberMeIssue generates a remember-me token for the user and stores it,
+// linked to the session started with it. It returns the cookie value,
+// "selector:validator", and its expiry. A zero expiresAt starts a new token
+// lifetime, rotations keep the original one.
+func RememberMeIssue(ctx context.Context, config *types.RememberMeConfig, userID string, sessionID string, expiresAt time.Time, options types.UserAuthOptions, now time.Time) (string, time.Time, error) {
+	if config == nil || config.FuncRememberTokenStore == nil {
+		return "", time.Time{}, errors.New("remember me is not configured")
+	}
+
+	selector, err := randomURLString(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	validator, err := randomURLString(rememberValidatorBytes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if expiresAt.IsZero() {
+		expiration := config.Expiration
+		if expiration <= 0 {
+			expiration = types.DefaultRememberMeExpiration
+		}
+		expiresAt = now.Add(expiration)
+	}
+
+	record := types.RememberToken{
+		Selector:      selector,
+		ValidatorHash: RefreshTokenHash(validator),
+		UserID:        userID,
+		SessionID:     sessionID,
+		CreatedAt:     now,
+		ExpiresAt:     expiresAt,
+	}
+
+	if err := config.FuncRememberTokenStore(ctx, record, options); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return selector + ":" + validator, expiresAt, nil
+}
+
+// RememberMeConsume validates the remember-me cookie value and deletes its
+// token, so it can only be used once. It returns the user ID with the expiry
+// of the token, which its rotation keeps, or an empty user ID when the value
+// is not valid.
+func RememberMeConsume(ctx context.Context, config *types.RememberMeConfig, value string, options types.UserAuthOptions, now time.Time) (string, time.Time, error) {
+	selector, validator, ok := strings.Cut(value, ":")
+	if config == nil || !ok || selector == "" || validator == "" {
+		return "", time.Time{}, nil
+	}
+
+	record, err := config.FuncRememberTokenFindBySelector(ctx, selector, options)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if record == nil {
+		return "", time.Time{}, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(RefreshTokenHash(validator)), []byte(record.ValidatorHash)) != 1 {
+		// The selector is known but the validator is not: the cookie was
+		// stolen and already used, or forged. Revoke everything.
+		if err := config.FuncRememberTokenDeleteAllForUser(ctx, record.UserID, options); err != nil {
+			return "", time.Time{}, err
+		}
+		return "", time.Time{}, nil
+	}
+
+	deleted, err := config.FuncRememberTokenDelete(ctx, selector, options)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if !deleted || !now.Before(record.ExpiresAt) {
+		return "", time.Time{}, nil
+	}
+
+	return record.UserID, record.ExpiresAt, nil
+}
+
+// RememberMeRevoke deletes the remember-me token of the cookie value, e.g.
+// on logout. Unknown values are ignored.
+func RememberMeRevoke(ctx context.Context, config *types.RememberMeConfig, value string, options types.UserAuthOptions) error {
+	selector, _, ok := strings.Cut(value, ":")
+	if config == nil || !ok || selector == "" {
+		return nil
+	}
+
+	_, err := config.FuncRememberTokenDelete(ctx, selector, options)
+	return err
+}
+
+// RememberMeStart issues a remember-me token after a login with the auth
+// token and sets its cookie. It does nothing unless remember me is
+// configured and cookies are used.
+func RememberMeStart(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface, userID string, token string, options types.UserAuthOptions) error {
+	config := a.GetRememberMe()
+	if config == nil || !a.GetUseCookies() {
+		return nil
+	}
+
+	value, expiresAt, err := RememberMeIssue(r.Context(), config, userID, AuthTokenSessionID(a, token), time.Time{}, options, time.Now())
+	if err != nil {
+		return err
+	}
+
+	a.SetRememberCookie(w, r, value, expiresAt)
+	return nil
+}
+
+// RememberMeLogin signs the user in again from the remember-me cookie: the
+// token is rotated, a new session is started and both cookies are set. It
+// returns the caller of the new session, or nil when there is no valid
+// remember-me cookie, in which case the cookie is removed.
+func RememberMeLogin(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface, options types.UserAuthOptions) (*types.Principal, error) {
+	config := a.GetRememberMe()
+	if config == nil || !a.GetUseCookies() {
+		return nil, nil
+	}
+
+	cookie, err := r.Cookie(types.RememberCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	userID, expiresAt, err := RememberMeConsume(r.Context(), config, cookie.Value, options, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		a.RemoveRememberCookie(w, r)
+		return nil, nil
+	}
+
+	// The user did not sign in again, so the session is not a recent sign in
+	ctx := ContextWithLogin(r.Context(), types.AuthMethodRememberMe, types.MFALevelSingleFactor, time.Time{})
+	token, err := AuthTokenIssue(ctx, a, userID, options)
+	if err != nil {
+		return nil, err
+	}
+
+	value, expiresAt, err := RememberMeIssue(r.Context(), config, userID, AuthTokenSessionID(a, token), expiresAt, options, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	a.SetAuthCookie(w, r, token)
+	a.SetRememberCookie(w, r, value, expiresAt)
+
+	principal, _, err := authTokenResolve(r.Context(), a, token, options, time.Now(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+// RememberMeForget revokes the remember-me token of the request and removes
+// its cookie, e.g. on logout.
+func RememberMeForget(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface, options types.UserAuthOptions) error {
+	config := a.GetRememberMe()
+	if config == nil {
+		return nil
+	}
+
+	cookie, err := r.Cookie(types.RememberCookieName)
+	if err != nil {
+		return nil
+	}
+
+	if err := RememberMeRevoke(r.Context(), config, cookie.Value, options); err != nil {
+		return err
+	}
+
+	a.RemoveRememberCookie(w, r)
+	return nil
+}
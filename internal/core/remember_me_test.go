@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestRememberMeConsume_UsesTokenOnce(t *testing.T) {
+	store := testutils.NewRememberTokenStore()
+	config := store.Config()
+	now := time.Now()
+	options := types.UserAuthOptions{}
+
+	value, expiresAt, err := RememberMeIssue(context.Background(), config, "user-1", "session-1", time.Time{}, options, now)
+	if err != nil {
+		t.Fatalf("RememberMeIssue failed: %v", err)
+	}
+	if !expiresAt.Equal(now.Add(types.DefaultRememberMeExpiration)) {
+		t.Fatalf("expected the default expiration, got %v", expiresAt)
+	}
+
+	selector, validator, _ := strings.Cut(value, ":")
+	if record := store.Tokens[selector]; record.ValidatorHash == validator || record.ValidatorHash != RefreshTokenHash(validator) {
+		t.Fatalf("expected only the validator hash to be stored, got %+v", record)
+	}
+	if record := store.Tokens[selector]; record.SessionID != "session-1" {
+		t.Fatalf("expected the token to be linked to its session, got %+v", record)
+	}
+
+	userID, consumedExpiresAt, err := RememberMeConsume(context.Background(), config, value, options, now.Add(time.Hour))
+	if err != nil || userID != "user-1" {
+		t.Fatalf("expected user-1, got %q (%v)", userID, err)
+	}
+	if !consumedExpiresAt.Equal(expiresAt) {
+		t.Fatalf("expected the original expiry to be kept, got %v", consumedExpiresAt)
+	}
+	if len(store.Tokens) != 0 {
+		t.Fatalf("expected the token to be deleted, got %d tokens", len(store.Tokens))
+	}
+
+	if userID, _, err := RememberMeConsume(context.Background(), config, value, options, now); err != nil || userID != "" {
+		t.Fatalf("expected the used token to be rejected, got %q (%v)", userID, err)
+	}
+}
+
+func TestRememberMeConsume_WrongValidatorRevokesAll(t *testing.T) {
+	store := testutils.NewRememberTokenStore()
+	config := store.Config()
+	now := time.Now()
+
+	value, _, err := RememberMeIssue(context.Background(), config, "user-1", "", time.Time{}, types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RememberMeIssue(context.Background(), config, "user-1", "", time.Time{}, types.UserAuthOptions{}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	selector, _, _ := strings.Cut(value, ":")
+	userID, _, err := RememberMeConsume(context.Background(), config, selector+":forged", types.UserAuthOptions{}, now)
+	if err != nil || userID != "" {
+		t.Fatalf("expected the forged value to be rejected, got %q (%v)", userID, err)
+	}
+	if len(store.Tokens) != 0 {
+		t.Fatalf("expected every token of the user to be revoked, got %d", len(store.Tokens))
+	}
+}
+
+func TestRememberMeConsume_RejectsExpiredToken(t *testing.T) {
+	store := testutils.NewRememberTokenStore()
+	config := store.Config()
+	config.Expiration = time.Hour
+	now := time.Now()
+
+	value, _, err := RememberMeIssue(context.Background(), config, "user-1", "", time.Time{}, types.UserAuthOptions{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, _, err := RememberMeConsume(context.Background(), config, value, types.UserAuthOptions{}, now.Add(2*time.Hour))
+	if err != nil || userID != "" {
+		t.Fatalf("expected the expired token to be rejected, got %q (%v)", userID, err)
+	}
+	if len(store.Tokens) != 0 {
+		t.Fatalf("expected the expired token to be deleted, got %d", len(store.Tokens))
+	}
+}
+
+func TestRememberMeConsume_IgnoresMalformedValue(t *testing.T) {
+	config := testutils.NewRememberTokenStore().Config()
+
+	for _, value := range []string{"", "no-separator", ":validator", "selector:"} {
+		if userID, _, err := RememberMeConsume(context.Background(), config, value, types.UserAuthOptions{}, time.Now()); err != nil || userID != "" {
+			t.Errorf("expected %q to be rejected, got %q (%v)", value, userID, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// NewLegacySessionStore adapts the FuncUserStoreAuthToken,
+// FuncUserFindByAuthToken and FuncUserLogout callbacks to a
+// types.SessionStore, so configurations without a SessionStore keep working.
+//
+// The callbacks have no notion of session metadata, so Get only fills in the
+// ID and user ID, Touch and Delete do nothing (logout calls FuncUserLogout
+// itself), and ListForUser returns types.ErrSessionListingNotSupported.
+func NewLegacySessionStore(
+	store func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error,
+	find func(ctx context.Context, token string, options types.UserAuthOptions) (string, error),
+	logout func(ctx context.Context, userID string, options types.UserAuthOptions) error,
+) types.SessionStore {
+	return legacySessionStore{store: store, find: find, logout: logout}
+}
+
+type legacySessionStore struct {
+	store  func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error
+	find   func(ctx context.Context, token string, options types.UserAuthOptions) (string, error)
+	logout func(ctx context.Context, userID string, options types.UserAuthOptions) error
+}
+
+var _ types.SessionStore = legacySessionStore{}
+
+func (s legacySessionStore) Create(ctx context.Context, session types.Session) error {
+	if s.store == nil {
+		return errFuncNotConfigured("FuncUserStoreAuthToken")
+	}
+	return s.store(ctx, session.ID, session.UserID, types.UserAuthOptions{
+		UserIp:    session.UserIp,
+		UserAgent: session.UserAgent,
+	})
+}
+
+func (s legacySessionStore) Get(ctx context.Context, sessionID string) (*types.Session, error) {
+	if s.find == nil {
+		return nil, errFuncNotConfigured("FuncUserFindByAuthToken")
+	}
+
+	userID, err := s.find(ctx, sessionID, types.UserAuthOptionsFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if userID == "" {
+		return nil, nil
+	}
+
+	return &types.Session{ID: sessionID, UserID: userID}, nil
+}
+
+func (s legacySessionStore) Touch(ctx context.Context, sessionID string, lastSeenAt time.Time) error {
+	return nil
+}
+
+func (s legacySessionStore) Delete(ctx context.Context, sessionID string) error {
+	return nil
+}
+
+func (s legacySessionStore) DeleteAllForUser(ctx context.Context, userID string) error {
+	if s.logout == nil {
+		return errFuncNotConfigured("FuncUserLogout")
+	}
+	return s.logout(ctx, userID, types.UserAuthOptionsFromContext(ctx))
+}
+
+func (s legacySessionStore) ListForUser(ctx context.Context, userID string) ([]types.Session, error) {
+	return nil, types.ErrSessionListingNotSupported
+}
+
+// SessionStore returns the configured session store, or the adapter over the
+// legacy callbacks when none is configured. It is nil when neither is
+// available, e.g. with JWT access tokens only.
+func SessionStore(a types.AuthSharedInterface) types.SessionStore {
+	if store := a.GetSessionStore(); store != nil {
+		return store
+	}
+
+	if a.GetFuncUserStoreAuthToken() == nil && a.GetFuncUserFindByAuthToken() == nil {
+		return nil
+	}
+
+	return NewLegacySessionStore(a.GetFuncUserStoreAuthToken(), a.GetFuncUserFindByAuthToken(), a.GetFuncUserLogout())
+}
+
+// SessionCreate stores a new session for the auth token, under the hash of
+// the token when auth token hashing is configured. How the user signed in is
+// taken from the context, see ContextWithLogin.
+func SessionCreate(ctx context.Context, a types.AuthSharedInterface, token string, userID string, options types.UserAuthOptions) error {
+	store := SessionStore(a)
+	if store == nil {
+		return errFuncNotConfigured("SessionStore")
+	}
+
+	now := time.Now()
+	details := loginFromContext(ctx, now)
+
+	return store.Create(types.ContextWithUserAuthOptions(ctx, options), types.Session{
+		ID:              AuthTokenHash(a, token),
+		UserID:          userID,
+		UserIp:          options.UserIp,
+		UserAgent:       options.UserAgent,
+		CreatedAt:       now,
+		LastSeenAt:      now,
+		ExpiresAt:       now.Add(sessionLifetime(a)),
+		AuthMethod:      details.method,
+		MFALevel:        details.mfaLevel,
+		AuthenticatedAt: details.authenticatedAt,
+	})
+}
+
+// SessionExpired reports whether the session is past its expiry time, its
+// absolute lifetime or its idle timeout. The lifetime is checked against the
+// creation time as well, so lowering it also applies to existing sessions.
+// Timestamps the store does not know, e.g. with the legacy callbacks, are
+// not checked.
+func SessionExpired(a types.AuthSharedInterface, session types.Session, now time.Time) bool {
+	if !session.ExpiresAt.IsZero() && !now.Before(session.ExpiresAt) {
+		return true
+	}
+
+	if !session.CreatedAt.IsZero() && !now.Before(session.CreatedAt.Add(sessionLifetime(a))) {
+		return true
+	}
+
+	idleTimeout := a.GetSessionIdleTimeout()
+	if idleTimeout > 0 && !session.LastSeenAt.IsZero() && !now.Before(session.LastSeenAt.Add(idleTimeout)) {
+		return true
+	}
+
+	return false
+}
+
+func sessionLifetime(a types.AuthSharedInterface) time.Duration {
+	if lifetime := a.GetSessionLifetime(); lifetime > 0 {
+		return lifetime
+	}
+	return types.DefaultSessionExpiration
+}
+
+// SessionLogout ends the session of the auth token, then notifies
+// FuncUserLogout when it is set.
+func SessionLogout(ctx context.Context, a types.AuthSharedInterface, token string, userID string, options types.UserAuthOptions) error {
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+
+	if store := a.GetSessionStore(); store != nil && a.GetJWT() == nil && token != "" {
+		for _, id := range authTokenSessionIDs(a, token) {
+			if err := store.Delete(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if fn := a.GetFuncUserLogout(); fn != nil {
+		return fn(ctx, userID, options)
+	}
+
+	return nil
+}
+
+// SessionsDeleteAllForUser ends every session of the user, e.g. after a
+// password reset, including the remember-me and refresh tokens that would
+// start new ones, then notifies FuncUserLogout when it is set.
+func SessionsDeleteAllForUser(ctx context.Context, a types.AuthSharedInterface, userID string, options types.UserAuthOptions) error {
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+
+	if store := a.GetSessionStore(); store != nil {
+		if err := store.DeleteAllForUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	if config := a.GetRememberMe(); config != nil {
+		if err := config.FuncRememberTokenDeleteAllForUser(ctx, userID, options); err != nil {
+			return err
+		}
+	}
+
+	if config := a.GetRefreshToken(); config != nil {
+		if err := config.FuncRefreshTokenRevokeAllForUser(ctx, userID, options); err != nil {
+			return err
+		}
+	}
+
+	if fn := a.GetFuncUserLogout(); fn != nil {
+		return fn(ctx, userID, options)
+	}
+
+	return nil
+}
+
+// SessionsList returns the sessions of the user from the configured session
+// store. The legacy callbacks cannot list sessions, so without a store
+// types.ErrSessionListingNotSupported is returned.
+func SessionsList(ctx context.Context, a types.AuthSharedInterface, userID string, options types.UserAuthOptions) ([]types.Session, error) {
+	store := a.GetSessionStore()
+	if store == nil {
+		return nil, types.ErrSessionListingNotSupported
+	}
+
+	return store.ListForUser(types.ContextWithUserAuthOptions(ctx, options), userID)
+}
+
+// SessionDelete ends a single session in the configured session store,
+// including the remember-me and refresh tokens linked to it that would
+// start new ones.
+func SessionDelete(ctx context.Context, a types.AuthSharedInterface, sessionID string, options types.UserAuthOptions) error {
+	store := a.GetSessionStore()
+	if store == nil {
+		return errFuncNotConfigured("SessionStore")
+	}
+
+	ctx = types.ContextWithUserAuthOptions(ctx, options)
+
+	if err := store.Delete(ctx, sessionID); err != nil {
+		return err
+	}
+
+	if config := a.GetRememberMe(); config != nil {
+		if err := config.FuncRememberTokenDeleteBySession(ctx, sessionID, options); err != nil {
+			return err
+		}
+	}
+
+	if config := a.GetRefreshToken(); config != nil {
+		if err := config.FuncRefreshTokenRevokeBySession(ctx, sessionID, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SessionPublicID derives the ID a session is shown with to the user. The
+// session ID is the auth token itself, so it must never leave the server.
+func SessionPublicID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// SessionDevice describes the device of a session from its user agent, e.g.
+// "Firefox on Windows".
+func SessionDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+
+	// Order matters: Android and iOS user agents also mention Linux and Mac OS
+	systems := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser := ""
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	system := ""
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+
+	return "Unknown device"
+}
+
+func errFuncNotConfigured(name string) error {
+	return errors.New(name + " is not configured")
+}
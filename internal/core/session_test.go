@@ -0,0 +1,284 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestLegacySessionStore(t *testing.T) {
+	options := types.UserAuthOptions{UserIp: "127.0.0.1", UserAgent: "test"}
+	tokens := map[string]string{}
+	var storeOptions, findOptions types.UserAuthOptions
+	var loggedOut string
+
+	store := NewLegacySessionStore(
+		func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error {
+			storeOptions = options
+			tokens[token] = userID
+			return nil
+		},
+		func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+			findOptions = options
+			return tokens[token], nil
+		},
+		func(ctx context.Context, userID string, options types.UserAuthOptions) error {
+			loggedOut = userID
+			return nil
+		},
+	)
+
+	ctx := types.ContextWithUserAuthOptions(context.Background(), options)
+
+	if err := store.Create(ctx, types.Session{ID: "token-1", UserID: "user-1", UserIp: options.UserIp, UserAgent: options.UserAgent}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if storeOptions != options {
+		t.Fatalf("expected the session IP and user agent to be passed, got %+v", storeOptions)
+	}
+
+	session, err := store.Get(ctx, "token-1")
+	if err != nil || session == nil || session.UserID != "user-1" {
+		t.Fatalf("expected session of user-1, got %+v (%v)", session, err)
+	}
+	if findOptions != options {
+		t.Fatalf("expected the request options from the context, got %+v", findOptions)
+	}
+
+	if session, err := store.Get(ctx, "unknown"); err != nil || session != nil {
+		t.Fatalf("expected no session, got %+v (%v)", session, err)
+	}
+
+	if err := store.DeleteAllForUser(ctx, "user-1"); err != nil || loggedOut != "user-1" {
+		t.Fatalf("expected FuncUserLogout to be called, got %q (%v)", loggedOut, err)
+	}
+
+	if _, err := store.ListForUser(ctx, "user-1"); !errors.Is(err, types.ErrSessionListingNotSupported) {
+		t.Fatalf("expected listing to be unsupported, got %v", err)
+	}
+}
+
+func TestAuthTokenIssue_CreatesSessionInStore(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+
+	options := types.UserAuthOptions{UserIp: "127.0.0.1", UserAgent: "test"}
+	token, err := AuthTokenIssue(context.Background(), a, "user-1", options)
+	if err != nil {
+		t.Fatalf("AuthTokenIssue failed: %v", err)
+	}
+
+	session := store.Sessions[token]
+	if session.UserID != "user-1" || session.UserIp != options.UserIp || session.UserAgent != options.UserAgent {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	if session.CreatedAt.IsZero() || !session.ExpiresAt.After(session.CreatedAt) {
+		t.Fatalf("expected creation and expiry times, got %+v", session)
+	}
+
+	if userID, err := AuthTokenUserID(context.Background(), a, token, options); err != nil || userID != "user-1" {
+		t.Fatalf("expected user-1, got %q (%v)", userID, err)
+	}
+}
+
+func TestAuthTokenUserID_RejectsExpiredSession(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	if userID, err := AuthTokenUserID(context.Background(), a, "token-1", types.UserAuthOptions{}); err != nil || userID != "" {
+		t.Fatalf("expected expired session to be rejected, got %q (%v)", userID, err)
+	}
+}
+
+func TestSessionLogout_DeletesSessionAndNotifies(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1"}
+	store.Sessions["token-2"] = types.Session{ID: "token-2", UserID: "user-1"}
+
+	var notified string
+	a.SetFuncUserLogout(func(ctx context.Context, userID string, options types.UserAuthOptions) error {
+		notified = userID
+		return nil
+	})
+
+	if err := SessionLogout(context.Background(), a, "token-1", "user-1", types.UserAuthOptions{}); err != nil {
+		t.Fatalf("SessionLogout failed: %v", err)
+	}
+	if _, ok := store.Sessions["token-1"]; ok {
+		t.Fatal("expected the session to be deleted")
+	}
+	if _, ok := store.Sessions["token-2"]; !ok {
+		t.Fatal("expected other sessions to be kept")
+	}
+	if notified != "user-1" {
+		t.Fatalf("expected FuncUserLogout to be notified, got %q", notified)
+	}
+
+	if err := SessionsDeleteAllForUser(context.Background(), a, "user-1", types.UserAuthOptions{}); err != nil {
+		t.Fatalf("SessionsDeleteAllForUser failed: %v", err)
+	}
+	if len(store.Sessions) != 0 {
+		t.Fatalf("expected every session to be deleted, got %d", len(store.Sessions))
+	}
+}
+
+func TestSessionDelete_RevokesLinkedTokens(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+	remember := testutils.NewRememberTokenStore()
+	a.SetRememberMe(remember.Config())
+	refresh := testutils.NewRefreshTokenStore()
+	a.SetRefreshToken(refresh.Config())
+
+	ctx := context.Background()
+	options := types.UserAuthOptions{}
+	now := time.Now()
+
+	for _, token := range []string{"token-1", "token-2"} {
+		if err := SessionCreate(ctx, a, token, "user-1", options); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := RememberMeIssue(ctx, a.GetRememberMe(), "user-1", AuthTokenSessionID(a, token), time.Time{}, options, now); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RefreshTokenIssue(ctx, a.GetRefreshToken(), "user-1", "", AuthTokenSessionID(a, token), options, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := SessionDelete(ctx, a, "token-1", options); err != nil {
+		t.Fatalf("SessionDelete failed: %v", err)
+	}
+
+	if _, ok := store.Sessions["token-1"]; ok {
+		t.Fatal("expected the session to be deleted")
+	}
+	for _, token := range remember.Tokens {
+		if token.SessionID != "token-2" {
+			t.Fatalf("expected only the remember-me token of the other session to be kept, got %+v", token)
+		}
+	}
+	for _, token := range refresh.Tokens {
+		if token.SessionID != "token-2" {
+			t.Fatalf("expected only the refresh token of the other session to be kept, got %+v", token)
+		}
+	}
+	if len(remember.Tokens) != 1 || len(refresh.Tokens) != 1 {
+		t.Fatalf("expected the tokens of the other session to be kept, got %d and %d", len(remember.Tokens), len(refresh.Tokens))
+	}
+}
+
+func TestSessionsList_RequiresSessionStore(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	if _, err := SessionsList(context.Background(), a, "user-1", types.UserAuthOptions{}); !errors.Is(err, types.ErrSessionListingNotSupported) {
+		t.Fatalf("expected listing to be unsupported, got %v", err)
+	}
+
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1"}
+	store.Sessions["token-2"] = types.Session{ID: "token-2", UserID: "user-2"}
+
+	sessions, err := SessionsList(context.Background(), a, "user-1", types.UserAuthOptions{})
+	if err != nil || len(sessions) != 1 || sessions[0].ID != "token-1" {
+		t.Fatalf("expected the session of user-1, got %+v (%v)", sessions, err)
+	}
+}
+
+func TestSessionPublicID(t *testing.T) {
+	id := SessionPublicID("token-1")
+	if id == "" || id == "token-1" || id != SessionPublicID("token-1") {
+		t.Fatalf("expected a stable ID that differs from the token, got %q", id)
+	}
+	if id == SessionPublicID("token-2") {
+		t.Fatal("expected different sessions to have different IDs")
+	}
+}
+
+func TestSessionDevice(t *testing.T) {
+	tests := map[string]string{
+		"": "Unknown device",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0":                                                 "Firefox on Windows",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Safari/605.1.15":            "Safari on macOS",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36":            "Chrome on Android",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0":    "Edge on Windows",
+		"curl/8.5.0": "Unknown device",
+	}
+
+	for userAgent, expected := range tests {
+		if device := SessionDevice(userAgent); device != expected {
+			t.Errorf("SessionDevice(%q) = %q, expected %q", userAgent, device, expected)
+		}
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	a.SetSessionLifetime(8 * time.Hour)
+	a.SetSessionIdleTimeout(30 * time.Minute)
+
+	now := time.Now()
+	tests := []struct {
+		name     string
+		session  types.Session
+		expected bool
+	}{
+		{"active", types.Session{CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Minute)}, false},
+		{"idle", types.Session{CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-31 * time.Minute)}, true},
+		{"past lifetime", types.Session{CreatedAt: now.Add(-9 * time.Hour), LastSeenAt: now}, true},
+		{"past expiry", types.Session{CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(-time.Second)}, true},
+		{"no timestamps", types.Session{}, false},
+	}
+
+	for _, tt := range tests {
+		if expired := SessionExpired(a, tt.session, now); expired != tt.expected {
+			t.Errorf("%s: expected expired %v, got %v", tt.name, tt.expected, expired)
+		}
+	}
+}
+
+func TestAuthTokenAuthenticate_ThrottlesTouch(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := testutils.NewSessionStore()
+	a.SetSessionStore(store)
+	a.SetSessionIdleTimeout(time.Hour)
+
+	now := time.Now()
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1", CreatedAt: now, LastSeenAt: now.Add(-time.Minute)}
+	store.Sessions["token-2"] = types.Session{ID: "token-2", UserID: "user-1", CreatedAt: now, LastSeenAt: now.Add(-10 * time.Minute)}
+
+	principal, touched, err := AuthTokenAuthenticate(context.Background(), a, "token-1", types.UserAuthOptions{})
+	if err != nil || principal == nil || principal.UserID != "user-1" || touched {
+		t.Fatalf("expected a recently seen session not to be touched, got %+v %v (%v)", principal, touched, err)
+	}
+
+	principal, touched, err = AuthTokenAuthenticate(context.Background(), a, "token-2", types.UserAuthOptions{})
+	if err != nil || principal == nil || principal.UserID != "user-1" || !touched {
+		t.Fatalf("expected the session to be touched, got %+v %v (%v)", principal, touched, err)
+	}
+	if !store.Sessions["token-2"].LastSeenAt.After(now.Add(-time.Minute)) {
+		t.Fatalf("expected the last seen time to be updated, got %v", store.Sessions["token-2"].LastSeenAt)
+	}
+
+	// AuthTokenUserID only reads the session
+	store.Sessions["token-3"] = types.Session{ID: "token-3", UserID: "user-1", CreatedAt: now, LastSeenAt: now.Add(-10 * time.Minute)}
+	if userID, err := AuthTokenUserID(context.Background(), a, "token-3", types.UserAuthOptions{}); err != nil || userID != "user-1" {
+		t.Fatalf("expected user-1, got %q (%v)", userID, err)
+	}
+	if !store.Sessions["token-3"].LastSeenAt.Equal(now.Add(-10 * time.Minute)) {
+		t.Fatal("expected AuthTokenUserID not to touch the session")
+	}
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// MaxVerificationAttempts is the number of wrong codes accepted for an
+// issued code before it is invalidated, independently of the per-IP rate
+// limit.
+const MaxVerificationAttempts = 5
+
+// verificationAttemptsKeyPrefix namespaces the wrong-guess counters of
+// issued codes in the counter store.
+const verificationAttemptsKeyPrefix = "attempts:"
+
+// verificationAttemptsExpiration keeps a counter at least as long as the
+// longest lived code it can belong to.
+const verificationAttemptsExpiration = time.Hour
+
+// TemporaryKeyDelete removes a key from the temporary key store, e.g. a used
+// verification code, so it cannot be used again. Without
+// FuncTemporaryKeyDelete the key is overwritten with an empty value that
+// expires right away, which every lookup treats as not found.
+func TemporaryKeyDelete(a types.AuthSharedInterface, key string) error {
+	if fn := a.GetFuncTemporaryKeyDelete(); fn != nil {
+		return fn(key)
+	}
+
+	if fn := a.GetFuncTemporaryKeySet(); fn != nil {
+		return fn(key, "", 1)
+	}
+
+	return errors.New("FuncTemporaryKeySet is not configured")
+}
+
+// VerificationAttemptFailed records a wrong guess against the code stored
+// under key, with an atomic increment so parallel guesses are all counted.
+// Once MaxVerificationAttempts is reached the code is deleted, so it cannot
+// be guessed any further, and exhausted is true.
+//
+// Only codes bound to a flow or challenge have a key to count against.
+// Unbound login codes (DisableLoginCodeBinding) have no per-code attempt
+// limit and are only protected by the per-IP rate limit.
+func VerificationAttemptFailed(a types.AuthSharedInterface, key string) (exhausted bool, err error) {
+	store := a.GetCounterStore()
+	if store == nil {
+		return false, errFuncNotConfigured("CounterStore")
+	}
+
+	attemptsKey := verificationAttemptsKeyPrefix + key
+
+	attempts, err := store.Increment(attemptsKey, verificationAttemptsExpiration)
+	if err != nil {
+		return false, err
+	}
+
+	if attempts < MaxVerificationAttempts {
+		return false, nil
+	}
+
+	if err := TemporaryKeyDelete(a, key); err != nil {
+		return false, err
+	}
+
+	return true, store.Delete(attemptsKey)
+}
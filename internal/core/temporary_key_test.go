@@ -0,0 +1,112 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func newTemporaryKeyStoreForTest(a types.AuthSharedInterface) map[string]string {
+	store := map[string]string{}
+	a.SetFuncTemporaryKeyGet(func(key string) (string, error) {
+		return store[key], nil
+	})
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	})
+	return store
+}
+
+func TestTemporaryKeyDelete(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := newTemporaryKeyStoreForTest(a)
+	store["code"] = "user@test.com"
+
+	// Without FuncTemporaryKeyDelete the value is emptied
+	if err := TemporaryKeyDelete(a, "code"); err != nil || store["code"] != "" {
+		t.Fatalf("expected the code to be emptied, got %q (%v)", store["code"], err)
+	}
+
+	store["code"] = "user@test.com"
+	a.SetFuncTemporaryKeyDelete(func(key string) error {
+		delete(store, key)
+		return nil
+	})
+	if err := TemporaryKeyDelete(a, "code"); err != nil {
+		t.Fatalf("TemporaryKeyDelete failed: %v", err)
+	}
+	if _, ok := store["code"]; ok {
+		t.Fatal("expected FuncTemporaryKeyDelete to remove the code")
+	}
+}
+
+func TestVerificationAttemptFailed(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	store := newTemporaryKeyStoreForTest(a)
+	store["code"] = "user@test.com"
+
+	for i := 1; i < MaxVerificationAttempts; i++ {
+		exhausted, err := VerificationAttemptFailed(a, "code")
+		if err != nil || exhausted {
+			t.Fatalf("attempt %d: expected the code to survive, got %v (%v)", i, exhausted, err)
+		}
+	}
+	if store["code"] != "user@test.com" {
+		t.Fatal("expected the code to be kept below the limit")
+	}
+
+	exhausted, err := VerificationAttemptFailed(a, "code")
+	if err != nil || !exhausted {
+		t.Fatalf("expected the code to be exhausted, got %v (%v)", exhausted, err)
+	}
+	if store["code"] != "" {
+		t.Fatal("expected the exhausted code to be invalidated")
+	}
+}
+
+func TestVerificationAttemptFailed_ConcurrentGuessesAreCounted(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	var mu sync.Mutex
+	store := map[string]string{"code": "user@test.com"}
+	a.SetFuncTemporaryKeyGet(func(key string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return store[key], nil
+	})
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		store[key] = value
+		return nil
+	})
+
+	// Parallel wrong guesses exhaust the code exactly once
+	var exhaustedCount atomic.Int32
+	var wg sync.WaitGroup
+	for range MaxVerificationAttempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exhausted, err := VerificationAttemptFailed(a, "code")
+			if err != nil {
+				t.Errorf("VerificationAttemptFailed failed: %v", err)
+			}
+			if exhausted {
+				exhaustedCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if exhaustedCount.Load() != 1 {
+		t.Fatalf("expected the code to be exhausted once, got %d", exhaustedCount.Load())
+	}
+	if store["code"] != "" {
+		t.Fatal("expected the exhausted code to be invalidated")
+	}
+}
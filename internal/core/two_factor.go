@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/types"
+	authutils "github.com/dracory/auth/utils"
+	"github.com/dracory/str"
+)
+
+// TwoFactorChallengeKeyPrefix namespaces pending two-factor challenges in the
+// temporary key store, so a challenge token can never be confused with a
+// verification code or a password reset token.
+const TwoFactorChallengeKeyPrefix = "login_2fa:"
+
+// twoFactorChallengeExpiresSeconds is how long a user has to enter the TOTP
+// code after the first factor has been verified.
+const twoFactorChallengeExpiresSeconds = 300
+
+// TwoFactorSecretFind returns the user's TOTP secret, or an empty string if
+// two-factor authentication is not configured or not enabled for the user.
+func TwoFactorSecretFind(ctx context.Context, a types.AuthSharedInterface, userID string, options types.UserAuthOptions) (string, error) {
+	findFn := a.GetFuncUserTotpSecretFind()
+	if findFn == nil {
+		return "", nil
+	}
+	return findFn(ctx, userID, options)
+}
+
+// TwoFactorChallengeCreate stores a pending two-factor challenge for the user
+// in the temporary key store and returns the opaque challenge token the client
+// must present together with the TOTP code. The method is the first factor
+// the user signed in with, recorded on the session once the code is verified.
+func TwoFactorChallengeCreate(a types.AuthSharedInterface, userID string, method types.AuthMethod) (string, error) {
+	temporaryKeySet := a.GetFuncTemporaryKeySet()
+	if temporaryKeySet == nil {
+		return "", errors.New("FuncTemporaryKeySet is not configured")
+	}
+
+	challengeToken, err := str.RandomFromGamma(32, authutils.LoginCodeGamma(false))
+	if err != nil {
+		return "", err
+	}
+
+	value := string(method) + ":" + userID
+	if err := temporaryKeySet(TwoFactorChallengeKeyPrefix+challengeToken, value, twoFactorChallengeExpiresSeconds); err != nil {
+		return "", err
+	}
+
+	return challengeToken, nil
+}
+
+// TwoFactorChallengeUser returns the user and first factor of the stored
+// value of a challenge. A value without a method is a password login.
+func TwoFactorChallengeUser(value string) (userID string, method types.AuthMethod) {
+	methodValue, userID, found := strings.Cut(value, ":")
+	if !found {
+		return value, types.AuthMethodPassword
+	}
+	return userID, types.AuthMethod(methodValue)
+}
+
+// totpStepKeyPrefix namespaces the time steps of the TOTP codes each user
+// signed in with in the counter store.
+const totpStepKeyPrefix = "totp_step:"
+
+// totpStepExpiresSeconds is the longest a code is accepted for, with one
+// step of drift either side of its own.
+const totpStepExpiresSeconds = 90
+
+// TotpStepAccept records the time step of a valid TOTP code of the user. The
+// step is claimed with an atomic increment of its own counter, so a code is
+// accepted once even when it is posted by parallel requests.
+func TotpStepAccept(a types.AuthSharedInterface, userID string, step uint64) (accepted bool, err error) {
+	store := a.GetCounterStore()
+	if store == nil {
+		return false, errFuncNotConfigured("CounterStore")
+	}
+
+	key := totpStepKeyPrefix + userID + ":" + strconv.FormatUint(step, 10)
+
+	count, err := store.Increment(key, totpStepExpiresSeconds*time.Second)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
+}
+
+// TwoFactorEnrollKeyPrefix namespaces pending TOTP enrollments in the
+// temporary key store. The pending secret is keyed by user ID and is only
+// persisted once the user confirms a valid code.
+const TwoFactorEnrollKeyPrefix = "totp_enroll:"
+
+// TwoFactorEnrollExpiresSeconds is how long a generated secret waits for
+// confirmation before the user has to start the enrollment again.
+const TwoFactorEnrollExpiresSeconds = 600
+
+// RecoveryCodesIssue generates a new batch of recovery codes for the user,
+// hands their hashes to FuncUserRecoveryCodesStore (replacing any previous
+// batch) and returns the plain codes, which must only be shown once.
+func RecoveryCodesIssue(ctx context.Context, a types.AuthSharedInterface, userID string, options types.UserAuthOptions) ([]string, error) {
+	storeFn := a.GetFuncUserRecoveryCodesStore()
+	if storeFn == nil {
+		return nil, errors.New("FuncUserRecoveryCodesStore is not configured")
+	}
+
+	codes, err := authutils.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, authutils.HashRecoveryCode(code))
+	}
+
+	if err := storeFn(ctx, userID, hashes, options); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
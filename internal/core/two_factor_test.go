@@ -0,0 +1,80 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestTwoFactorChallengeUser(t *testing.T) {
+	if userID, method := TwoFactorChallengeUser("oidc:user-1"); userID != "user-1" || method != types.AuthMethodOIDC {
+		t.Fatalf("expected user-1 signed in with oidc, got %q %q", userID, method)
+	}
+	if userID, method := TwoFactorChallengeUser("user-1"); userID != "user-1" || method != types.AuthMethodPassword {
+		t.Fatalf("expected user-1 signed in with a password, got %q %q", userID, method)
+	}
+}
+
+func TestTotpStepAccept(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	newTemporaryKeyStoreForTest(a)
+
+	if accepted, err := TotpStepAccept(a, "user-1", 100); err != nil || !accepted {
+		t.Fatalf("expected the first code to be accepted, got %v (%v)", accepted, err)
+	}
+	if accepted, _ := TotpStepAccept(a, "user-1", 100); accepted {
+		t.Fatal("expected the same step to be refused")
+	}
+	if accepted, _ := TotpStepAccept(a, "user-1", 99); !accepted {
+		t.Fatal("expected an unused earlier step to be accepted")
+	}
+	if accepted, _ := TotpStepAccept(a, "user-2", 100); !accepted {
+		t.Fatal("expected the step of another user to be accepted")
+	}
+	if accepted, _ := TotpStepAccept(a, "user-1", 101); !accepted {
+		t.Fatal("expected a later step to be accepted")
+	}
+}
+
+func TestTotpStepAccept_ConcurrentRequestsAcceptOnce(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	var mu sync.Mutex
+	store := map[string]string{}
+	a.SetFuncTemporaryKeyGet(func(key string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return store[key], nil
+	})
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		store[key] = value
+		return nil
+	})
+
+	// The same code posted in parallel completes a single login
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := TotpStepAccept(a, "user-1", 100)
+			if err != nil {
+				t.Errorf("TotpStepAccept failed: %v", err)
+			}
+			if ok {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted.Load() != 1 {
+		t.Fatalf("expected the step to be accepted once, got %d", accepted.Load())
+	}
+}
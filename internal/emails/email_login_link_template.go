@@ -0,0 +1,75 @@
+package emails
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+)
+
+// EmailLoginLinkTemplate returns the template for the login email with both
+// the login code and the magic link
+func EmailLoginLinkTemplate(email string, code string, link string) string {
+	msg := `
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html>
+<head></head>
+<body>
+	<p>
+		Hello!
+	<p>
+	<p>
+		Someone requested to login with your email {{.Email}}. Please click the link below to log in.
+	</p>
+	<p>
+		<a href="{{.Link}}">Log in</a>
+	</p>
+	<p>
+		Or use the code below on the login page.
+	</p>
+	<p>
+		{{.Code}}
+	</p>
+	<p>
+		If you did not request to login no further action is required.
+	</p>
+	<p>
+		Thanks,
+		<br />
+		The Admin Team
+	</p>
+</body>
+<html>
+`
+	data := struct {
+		Email string
+		Code  string
+		Link  string
+	}{
+		Email: email,
+		Code:  code,
+		Link:  link,
+	}
+
+	t, err := template.New("template").Parse(msg)
+	if err != nil {
+		slog.Error("login link email template parse failed",
+			"error", err,
+			"email", email,
+		)
+		return ""
+	}
+
+	var doc bytes.Buffer
+	errExecute := t.Execute(&doc, data)
+
+	if errExecute != nil {
+		slog.Error("login link email template execute failed",
+			"error", errExecute,
+			"email", email,
+		)
+		return ""
+	}
+
+	s := doc.String()
+	return s
+}
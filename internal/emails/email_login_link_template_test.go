@@ -0,0 +1,25 @@
+package emails
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+func TestEmailLoginLinkTemplate_IncludesCodeAndLink(t *testing.T) {
+	email := "user@example.com"
+	code := "ABC12345"
+	link := "https://example.com/auth/login-link?token=abc.def&x=1"
+
+	result := EmailLoginLinkTemplate(email, code, link)
+
+	if result == "" {
+		t.Fatalf("expected non-empty template output")
+	}
+
+	for _, expected := range []string{email, code, html.EscapeString(link)} {
+		if !strings.Contains(result, expected) {
+			t.Fatalf("expected template to contain %q, got %q", expected, result)
+		}
+	}
+}
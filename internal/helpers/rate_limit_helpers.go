@@ -6,25 +6,44 @@ import (
 	"time"
 
 	"github.com/dracory/api"
-	"github.com/dracory/auth/utils"
+	"github.com/dracory/auth/types"
 )
 
 // CheckRateLimit verifies if a request should be allowed based on rate limiting rules.
-// It returns true if allowed, false if rate limited.
+// It returns true if allowed, false if rate limited. The limiter counts an
+// allowed request as an attempt under the policy of the endpoint; report a
+// success with RecordRateLimit to clear the attempts.
 func CheckRateLimit(
 	w http.ResponseWriter,
 	r *http.Request,
 	endpoint string,
 	disableRateLimit bool,
 	customCheck func(ip string, endpoint string) (allowed bool, retryAfter time.Duration, err error),
-	limiter *utils.InMemoryRateLimiter,
+	limiter types.RateLimiter,
+	policy types.RateLimitPolicy,
+) bool {
+	return CheckRateLimitForKey(w, r, GetClientIP(r), endpoint, disableRateLimit, customCheck, limiter, policy)
+}
+
+// CheckRateLimitForKey works like CheckRateLimit but counts requests against
+// the given key instead of the client IP, e.g. a normalised phone number.
+// It returns true if allowed, false if rate limited.
+func CheckRateLimitForKey(
+	w http.ResponseWriter,
+	r *http.Request,
+	key string,
+	endpoint string,
+	disableRateLimit bool,
+	customCheck func(ip string, endpoint string) (allowed bool, retryAfter time.Duration, err error),
+	limiter types.RateLimiter,
+	policy types.RateLimitPolicy,
 ) bool {
 	// If rate limiting is disabled, allow all requests
 	if disableRateLimit {
 		return true
 	}
 
-	ip := GetClientIP(r)
+	ip := key
 
 	// Use custom rate limit function if provided
 	if customCheck != nil {
@@ -43,13 +62,13 @@ func CheckRateLimit(
 		return true
 	}
 
-	// Use default in-memory rate limiter
+	// Use the configured or default in-memory rate limiter
 	if limiter == nil {
 		// This shouldn't happen if properly initialized, but fail open for safety
 		return true
 	}
 
-	result := limiter.Check(ip, endpoint)
+	result := limiter.Allow(ip, endpoint, policy)
 	if !result.Allowed {
 		w.WriteHeader(http.StatusTooManyRequests)
 		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", result.RetryAfter.Seconds()))
@@ -60,6 +79,34 @@ func CheckRateLimit(
 	return true
 }
 
+// RecordRateLimit reports the outcome of a request allowed by
+// CheckRateLimitForKey: a success clears the attempts counted against the
+// key, while a failure keeps the attempt the check took. Without a limiter,
+// the optional reset hook of a custom rate limiter is used.
+func RecordRateLimit(
+	key string,
+	endpoint string,
+	succeeded bool,
+	disableRateLimit bool,
+	reset func(ip string, endpoint string) error,
+	limiter types.RateLimiter,
+) error {
+	if disableRateLimit || !succeeded {
+		return nil
+	}
+
+	if limiter != nil {
+		limiter.Reset(key, endpoint)
+		return nil
+	}
+
+	if reset != nil {
+		return reset(key, endpoint)
+	}
+
+	return nil
+}
+
 // GetClientIP extracts the client IP from the request.
 // It checks X-Forwarded-For and X-Real-IP headers first, then falls back to RemoteAddr.
 func GetClientIP(r *http.Request) string {
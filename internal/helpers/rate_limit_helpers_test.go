@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dracory/auth/types"
 	"github.com/dracory/auth/utils"
 )
 
@@ -13,7 +14,7 @@ func TestCheckRateLimit_DisabledAlwaysAllows(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/login", nil)
 	w := httptest.NewRecorder()
 
-	allowed := CheckRateLimit(w, req, "/login", true, nil, nil)
+	allowed := CheckRateLimit(w, req, "/login", true, nil, nil, types.RateLimitPolicy{})
 	if !allowed {
 		t.Fatalf("expected request to be allowed when rate limiting is disabled")
 	}
@@ -34,7 +35,7 @@ func TestCheckRateLimit_UsesCustomFunction(t *testing.T) {
 		return false, 5 * time.Second, nil
 	}
 
-	allowed := CheckRateLimit(w, req, "/login", false, custom, nil)
+	allowed := CheckRateLimit(w, req, "/login", false, custom, nil, types.RateLimitPolicy{})
 	if allowed {
 		t.Fatalf("expected request to be blocked by custom rate limiter")
 	}
@@ -54,15 +55,16 @@ func TestCheckRateLimit_DefaultLimiter_AllowsAndBlocks(t *testing.T) {
 	// First request should pass
 	req1 := httptest.NewRequest(http.MethodPost, endpoint, nil)
 	w1 := httptest.NewRecorder()
-	allowed1 := CheckRateLimit(w1, req1, endpoint, false, nil, limiter)
+	allowed1 := CheckRateLimit(w1, req1, endpoint, false, nil, limiter, types.RateLimitPolicy{})
 	if !allowed1 {
 		t.Fatalf("expected first request to be allowed")
 	}
 
-	// Second request from same IP/endpoint should be blocked
+	// The first request took the only attempt, so the second from the same
+	// IP/endpoint should be blocked
 	req2 := httptest.NewRequest(http.MethodPost, endpoint, nil)
 	w2 := httptest.NewRecorder()
-	allowed2 := CheckRateLimit(w2, req2, endpoint, false, nil, limiter)
+	allowed2 := CheckRateLimit(w2, req2, endpoint, false, nil, limiter, types.RateLimitPolicy{})
 	if allowed2 {
 		t.Fatalf("expected second request to be rate limited")
 	}
@@ -72,6 +74,54 @@ func TestCheckRateLimit_DefaultLimiter_AllowsAndBlocks(t *testing.T) {
 	}
 }
 
+func TestRecordRateLimit_SuccessClearsAttempts(t *testing.T) {
+	limiter := utils.NewInMemoryRateLimiter(2, time.Minute, time.Minute)
+	defer limiter.Stop()
+
+	attempt := func(succeeded bool) bool {
+		allowed := limiter.Allow("1.1.1.1", "login", types.RateLimitPolicy{}).Allowed
+		if err := RecordRateLimit("1.1.1.1", "login", succeeded, false, nil, limiter); err != nil {
+			t.Fatal(err)
+		}
+		return allowed
+	}
+
+	// Logging in again and again never locks the user out
+	for i := 0; i < 5; i++ {
+		attempt(false)
+		if !attempt(true) {
+			t.Fatalf("login %d: expected successful logins to clear the attempts", i+1)
+		}
+	}
+
+	attempt(false)
+	attempt(false)
+	if attempt(true) {
+		t.Fatalf("expected repeated failures to lock the IP out")
+	}
+}
+
+func TestRecordRateLimit_UsesCustomResetHook(t *testing.T) {
+	var calls []string
+	reset := func(ip, endpoint string) error {
+		calls = append(calls, "reset:"+ip+":"+endpoint)
+		return nil
+	}
+
+	_ = RecordRateLimit("1.1.1.1", "login", false, false, reset, nil)
+	_ = RecordRateLimit("1.1.1.1", "login", true, false, reset, nil)
+	_ = RecordRateLimit("1.1.1.1", "login", true, true, reset, nil)
+
+	if len(calls) != 1 || calls[0] != "reset:1.1.1.1:login" {
+		t.Fatalf("expected a single reset, got %v", calls)
+	}
+
+	// Custom rate limiters without the hook keep every attempt
+	if err := RecordRateLimit("1.1.1.1", "login", true, false, nil, nil); err != nil {
+		t.Fatalf("expected no error without hooks, got %v", err)
+	}
+}
+
 func TestGetClientIP_PrefersHeadersThenRemoteAddr(t *testing.T) {
 	// X-Forwarded-For with multiple IPs
 	req1 := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -0,0 +1,273 @@
+// Package jwt signs and verifies the compact JWS tokens used as stateless
+// access tokens. Only the algorithms offered by types.JWTConfig are
+// supported, and a token is only accepted with the algorithm of the key, so
+// an attacker cannot downgrade it with the "alg" header.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// clockSkew is the tolerance applied to the time based claims.
+const clockSkew = time.Minute
+
+// minSecretLength is the minimum HS256 secret length, matching the size of
+// the SHA-256 output as recommended by RFC 7518 section 3.2.
+const minSecretLength = 32
+
+var (
+	// ErrInvalidToken is returned when the token is malformed or its
+	// signature does not verify.
+	ErrInvalidToken = errors.New("jwt: invalid token")
+
+	// ErrExpired is returned when the token is past its expiry.
+	ErrExpired = errors.New("jwt: token has expired")
+)
+
+// Key is the key material used to sign and verify tokens.
+type Key struct {
+	Algorithm  string // types.JWTAlgorithmHS256, RS256 or EdDSA
+	KeyID      string
+	Secret     []byte            // HS256
+	PrivateKey crypto.PrivateKey // RS256: *rsa.PrivateKey, EdDSA: ed25519.PrivateKey
+}
+
+// KeyFromConfig returns the key of the configuration, defaulting the
+// algorithm to HS256.
+func KeyFromConfig(config *types.JWTConfig) Key {
+	algorithm := config.Algorithm
+	if algorithm == "" {
+		algorithm = types.JWTAlgorithmHS256
+	}
+	return Key{
+		Algorithm:  algorithm,
+		KeyID:      config.KeyID,
+		Secret:     config.Secret,
+		PrivateKey: config.PrivateKey,
+	}
+}
+
+// Validate checks that the key material matches the algorithm.
+func (k Key) Validate() error {
+	switch k.Algorithm {
+	case types.JWTAlgorithmHS256:
+		if len(k.Secret) < minSecretLength {
+			return fmt.Errorf("jwt: HS256 secret must be at least %d bytes", minSecretLength)
+		}
+	case types.JWTAlgorithmRS256:
+		rsaKey, ok := k.PrivateKey.(*rsa.PrivateKey)
+		if !ok {
+			return errors.New("jwt: RS256 requires an *rsa.PrivateKey")
+		}
+		if rsaKey.N.BitLen() < 2048 {
+			return errors.New("jwt: RS256 key must be at least 2048 bits")
+		}
+	case types.JWTAlgorithmEdDSA:
+		if _, ok := k.PrivateKey.(ed25519.PrivateKey); !ok {
+			return errors.New("jwt: EdDSA requires an ed25519.PrivateKey")
+		}
+	default:
+		return fmt.Errorf("jwt: unsupported algorithm %q", k.Algorithm)
+	}
+	return nil
+}
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	ID        string // "jti"
+	Subject   string // "sub"
+	Issuer    string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	All       map[string]any // every claim, including the registered ones
+}
+
+// Expectations are the values a token must match. Empty values are not
+// checked.
+type Expectations struct {
+	Issuer   string
+	Audience string
+	Now      time.Time
+}
+
+// NewTokenID returns a random token identifier for the "jti" claim.
+func NewTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// Sign serializes the claims and signs them with the key.
+func Sign(key Key, claims map[string]any) (string, error) {
+	if err := key.Validate(); err != nil {
+		return "", err
+	}
+
+	header := map[string]string{"alg": key.Algorithm, "typ": "JWT"}
+	if key.KeyID != "" {
+		header["kid"] = key.KeyID
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	signature, err := sign(key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature of the token with the key, then its
+// registered claims against the expectations. The "exp" and "sub" claims are
+// required.
+func Verify(key Key, token string, expect Expectations) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, errHeader := base64.RawURLEncoding.DecodeString(parts[0])
+	claimsJSON, errClaims := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, errSignature := base64.RawURLEncoding.DecodeString(parts[2])
+	if errHeader != nil || errClaims != nil || errSignature != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if header.Algorithm != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	if !verify(key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	all := map[string]any{}
+	if err := json.Unmarshal(claimsJSON, &all); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{All: all}
+	claims.ID, _ = all["jti"].(string)
+	claims.Subject, _ = all["sub"].(string)
+	claims.Issuer, _ = all["iss"].(string)
+	claims.Audience = audienceClaim(all["aud"])
+
+	exp, hasExp := all["exp"].(float64)
+	if !hasExp || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	claims.ExpiresAt = time.Unix(int64(exp), 0)
+	if iat, ok := all["iat"].(float64); ok {
+		claims.IssuedAt = time.Unix(int64(iat), 0)
+	}
+
+	now := expect.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if !now.Add(-clockSkew).Before(claims.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	if nbf, ok := all["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrInvalidToken
+	}
+
+	if expect.Issuer != "" && claims.Issuer != expect.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+
+	if expect.Audience != "" && !slices.Contains(claims.Audience, expect.Audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// audienceClaim accepts both the single string and the array form of "aud".
+func audienceClaim(value any) []string {
+	switch aud := value.(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		audience := make([]string, 0, len(aud))
+		for _, item := range aud {
+			if s, ok := item.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
+
+func sign(key Key, signingInput []byte) ([]byte, error) {
+	switch key.Algorithm {
+	case types.JWTAlgorithmHS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case types.JWTAlgorithmRS256:
+		digest := sha256.Sum256(signingInput)
+		return rsa.SignPKCS1v15(nil, key.PrivateKey.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case types.JWTAlgorithmEdDSA:
+		return ed25519.Sign(key.PrivateKey.(ed25519.PrivateKey), signingInput), nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported algorithm %q", key.Algorithm)
+}
+
+func verify(key Key, signingInput []byte, signature []byte) bool {
+	if key.Validate() != nil {
+		return false
+	}
+
+	switch key.Algorithm {
+	case types.JWTAlgorithmHS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(signingInput)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case types.JWTAlgorithmRS256:
+		digest := sha256.Sum256(signingInput)
+		publicKey := &key.PrivateKey.(*rsa.PrivateKey).PublicKey
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil
+	case types.JWTAlgorithmEdDSA:
+		publicKey := key.PrivateKey.(ed25519.PrivateKey).Public().(ed25519.PublicKey)
+		return ed25519.Verify(publicKey, signingInput, signature)
+	}
+	return false
+}
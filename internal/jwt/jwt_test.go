@@ -0,0 +1,184 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/jwt"
+	"github.com/dracory/auth/types"
+)
+
+func testKeys(t *testing.T) map[string]jwt.Key {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	return map[string]jwt.Key{
+		types.JWTAlgorithmHS256: {Algorithm: types.JWTAlgorithmHS256, Secret: []byte(strings.Repeat("s", 32))},
+		types.JWTAlgorithmRS256: {Algorithm: types.JWTAlgorithmRS256, PrivateKey: rsaKey, KeyID: "rsa-1"},
+		types.JWTAlgorithmEdDSA: {Algorithm: types.JWTAlgorithmEdDSA, PrivateKey: edKey},
+	}
+}
+
+func testClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"sub":  "user-1",
+		"jti":  "token-1",
+		"iss":  "https://example.com",
+		"aud":  "api",
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Hour).Unix(),
+		"role": "admin",
+	}
+}
+
+func TestSignAndVerify_RoundTrip(t *testing.T) {
+	now := time.Now()
+
+	for algorithm, key := range testKeys(t) {
+		t.Run(algorithm, func(t *testing.T) {
+			token, err := jwt.Sign(key, testClaims(now))
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+
+			claims, err := jwt.Verify(key, token, jwt.Expectations{
+				Issuer:   "https://example.com",
+				Audience: "api",
+				Now:      now,
+			})
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+
+			if claims.Subject != "user-1" || claims.ID != "token-1" {
+				t.Fatalf("unexpected claims: %+v", claims)
+			}
+			if claims.All["role"] != "admin" {
+				t.Fatalf("expected custom claim to round trip, got %v", claims.All["role"])
+			}
+			if claims.ExpiresAt.Unix() != now.Add(time.Hour).Unix() {
+				t.Fatalf("unexpected expiry %v", claims.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestVerify_RejectsInvalidTokens(t *testing.T) {
+	now := time.Now()
+	keys := testKeys(t)
+	key := keys[types.JWTAlgorithmHS256]
+
+	sign := func(claims map[string]any) string {
+		token, err := jwt.Sign(key, claims)
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return token
+	}
+
+	valid := sign(testClaims(now))
+
+	expired := testClaims(now)
+	expired["exp"] = now.Add(-time.Hour).Unix()
+
+	notYetValid := testClaims(now)
+	notYetValid["nbf"] = now.Add(time.Hour).Unix()
+
+	noSubject := testClaims(now)
+	delete(noSubject, "sub")
+
+	noExpiry := testClaims(now)
+	delete(noExpiry, "exp")
+
+	otherKey := jwt.Key{Algorithm: types.JWTAlgorithmHS256, Secret: []byte(strings.Repeat("o", 32))}
+	otherKeyToken, err := jwt.Sign(otherKey, testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	rsaToken, err := jwt.Sign(keys[types.JWTAlgorithmRS256], testClaims(now))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name    string
+		token   string
+		expect  jwt.Expectations
+		wantErr error
+	}{
+		{name: "malformed", token: "not-a-token", wantErr: jwt.ErrInvalidToken},
+		{name: "tampered claims", token: tampered, wantErr: jwt.ErrInvalidToken},
+		{name: "alg none", token: unsigned, wantErr: jwt.ErrInvalidToken},
+		{name: "other secret", token: otherKeyToken, wantErr: jwt.ErrInvalidToken},
+		{name: "other algorithm", token: rsaToken, wantErr: jwt.ErrInvalidToken},
+		{name: "expired", token: sign(expired), wantErr: jwt.ErrExpired},
+		{name: "not yet valid", token: sign(notYetValid), wantErr: jwt.ErrInvalidToken},
+		{name: "missing subject", token: sign(noSubject), wantErr: jwt.ErrInvalidToken},
+		{name: "missing expiry", token: sign(noExpiry), wantErr: jwt.ErrInvalidToken},
+		{name: "wrong issuer", token: valid, expect: jwt.Expectations{Issuer: "https://other.example.com"}, wantErr: jwt.ErrInvalidToken},
+		{name: "wrong audience", token: valid, expect: jwt.Expectations{Audience: "other"}, wantErr: jwt.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect := tt.expect
+			expect.Now = now
+
+			_, err := jwt.Verify(key, tt.token, expect)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyValidate(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name    string
+		key     jwt.Key
+		wantErr bool
+	}{
+		{name: "short secret", key: jwt.Key{Algorithm: types.JWTAlgorithmHS256, Secret: []byte("short")}, wantErr: true},
+		{name: "RS256 without RSA key", key: jwt.Key{Algorithm: types.JWTAlgorithmRS256, PrivateKey: edKey}, wantErr: true},
+		{name: "EdDSA without Ed25519 key", key: jwt.Key{Algorithm: types.JWTAlgorithmEdDSA}, wantErr: true},
+		{name: "unsupported algorithm", key: jwt.Key{Algorithm: "none"}, wantErr: true},
+		{name: "EdDSA", key: jwt.Key{Algorithm: types.JWTAlgorithmEdDSA, PrivateKey: edKey}, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.key.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyFromConfig_DefaultsToHS256(t *testing.T) {
+	key := jwt.KeyFromConfig(&types.JWTConfig{Secret: []byte(strings.Repeat("s", 32))})
+	if key.Algorithm != types.JWTAlgorithmHS256 {
+		t.Fatalf("expected HS256, got %q", key.Algorithm)
+	}
+}
@@ -1,6 +1,9 @@
 package links
 
-import "strings"
+import (
+	"net/http"
+	"strings"
+)
 
 // Join combines the base endpoint and a relative URI in a consistent way.
 // If the endpoint already has a trailing slash, the URI is appended directly;
@@ -12,8 +15,41 @@ func Join(endpoint, uri string) string {
 	return endpoint + "/" + uri
 }
 
-func ApiLogin(endpoint string) string              { return Join(endpoint, "api/login") }
-func ApiLoginCodeVerify(endpoint string) string    { return Join(endpoint, "api/login-code-verify") }
+func ApiLogin(endpoint string) string           { return Join(endpoint, "api/login") }
+func ApiLoginCodeVerify(endpoint string) string { return Join(endpoint, "api/login-code-verify") }
+func ApiLogin2faVerify(endpoint string) string  { return Join(endpoint, "api/login-2fa-verify") }
+func ApiTwoFactorEnroll(endpoint string) string { return Join(endpoint, "api/2fa-enroll") }
+func ApiTwoFactorEnrollConfirm(endpoint string) string {
+	return Join(endpoint, "api/2fa-enroll-confirm")
+}
+func ApiTwoFactorRecoveryCodes(endpoint string) string {
+	return Join(endpoint, "api/2fa-recovery-codes")
+}
+func ApiWebAuthnRegisterBegin(endpoint string) string {
+	return Join(endpoint, "api/webauthn-register-begin")
+}
+func ApiWebAuthnRegisterFinish(endpoint string) string {
+	return Join(endpoint, "api/webauthn-register-finish")
+}
+func ApiWebAuthnLoginBegin(endpoint string) string {
+	return Join(endpoint, "api/webauthn-login-begin")
+}
+func ApiWebAuthnLoginFinish(endpoint string) string {
+	return Join(endpoint, "api/webauthn-login-finish")
+}
+func ApiTokenRefresh(endpoint string) string {
+	return Join(endpoint, "api/token/refresh")
+}
+func ApiReauth(endpoint string) string        { return Join(endpoint, "api/reauth") }
+func ApiSessions(endpoint string) string      { return Join(endpoint, "api/sessions") }
+func ApiSessionRevoke(endpoint string) string { return Join(endpoint, "api/session-revoke") }
+func ApiSessionsRevokeOthers(endpoint string) string {
+	return Join(endpoint, "api/sessions-revoke-others")
+}
+func ApiApiKeys(endpoint string) string            { return Join(endpoint, "api/api-keys") }
+func ApiApiKeyCreate(endpoint string) string       { return Join(endpoint, "api/api-key-create") }
+func ApiApiKeyRename(endpoint string) string       { return Join(endpoint, "api/api-key-rename") }
+func ApiApiKeyRevoke(endpoint string) string       { return Join(endpoint, "api/api-key-revoke") }
 func ApiLogout(endpoint string) string             { return Join(endpoint, "api/logout") }
 func ApiRegister(endpoint string) string           { return Join(endpoint, "api/register") }
 func ApiRegisterCodeVerify(endpoint string) string { return Join(endpoint, "api/register-code-verify") }
@@ -22,8 +58,38 @@ func ApiPasswordReset(endpoint string) string      { return Join(endpoint, "api/
 
 func Login(endpoint string) string              { return Join(endpoint, "login") }
 func LoginCodeVerify(endpoint string) string    { return Join(endpoint, "login-code-verify") }
+func LoginLink(endpoint string) string          { return Join(endpoint, "login-link") }
+func Login2faVerify(endpoint string) string     { return Join(endpoint, "login-2fa-verify") }
+func TwoFactorEnroll(endpoint string) string    { return Join(endpoint, "2fa-enroll") }
+func Passkeys(endpoint string) string           { return Join(endpoint, "passkeys") }
+func Reauth(endpoint string) string             { return Join(endpoint, "reauth") }
+func AccountUnlock(endpoint string) string      { return Join(endpoint, "account-unlock") }
+func Sessions(endpoint string) string           { return Join(endpoint, "sessions") }
 func Logout(endpoint string) string             { return Join(endpoint, "logout") }
 func PasswordRestore(endpoint string) string    { return Join(endpoint, "password-restore") }
 func PasswordReset(endpoint string) string      { return Join(endpoint, "password-reset") }
 func Register(endpoint string) string           { return Join(endpoint, "register") }
 func RegisterCodeVerify(endpoint string) string { return Join(endpoint, "register-code-verify") }
+
+// OIDCLogin and OIDCCallback are the routes of an OpenID Connect provider.
+func OIDCLogin(endpoint, provider string) string    { return Join(endpoint, "login/"+provider) }
+func OIDCCallback(endpoint, provider string) string { return Join(endpoint, "callback/"+provider) }
+
+// Absolute makes the link absolute using the scheme and host of the request,
+// unless it already is, e.g. for links sent by email.
+func Absolute(r *http.Request, link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+
+	if !strings.HasPrefix(link, "/") {
+		link = "/" + link
+	}
+
+	return scheme + "://" + r.Host + link
+}
@@ -1,6 +1,10 @@
 package middlewares
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/core"
+)
 
 // RateLimitConfig holds configuration for rate limiting.
 type RateLimitConfig struct {
@@ -9,6 +13,11 @@ type RateLimitConfig struct {
 	// have already written the appropriate HTTP response.
 	Check func(w http.ResponseWriter, r *http.Request, endpoint string) bool
 
+	// Record, when set, is called with the outcome of allowed requests once
+	// the handler has returned. Handlers report a success with
+	// core.RateLimitSucceeded; any other outcome is a failed attempt.
+	Record func(r *http.Request, endpoint string, succeeded bool)
+
 	// Endpoint is the logical endpoint name used for rate limiting, e.g.
 	// "login", "register", etc.
 	Endpoint string
@@ -23,6 +32,13 @@ func WithRateLimit(cfg RateLimitConfig, next http.HandlerFunc) http.HandlerFunc
 			}
 		}
 
-		next(w, r)
+		if cfg.Record == nil {
+			next(w, r)
+			return
+		}
+
+		ctx, outcome := core.ContextWithRateLimitOutcome(r.Context())
+		next(w, r.WithContext(ctx))
+		cfg.Record(r, cfg.Endpoint, outcome.Succeeded())
 	}
 }
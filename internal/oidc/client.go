@@ -0,0 +1,317 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+const (
+	// StateKeyPrefix namespaces pending sign-ins in the temporary key store.
+	StateKeyPrefix = "oidc_state:"
+
+	// StateExpiresSeconds is how long the user has to complete the sign-in
+	// at the provider.
+	StateExpiresSeconds = 600
+
+	// StateCookieName binds a pending sign-in to the browser that started it.
+	StateCookieName = "oidc_state"
+
+	// maxResponseBytes caps the size of provider responses.
+	maxResponseBytes = 1 << 20
+
+	// jwksMinRefreshInterval throttles key set refreshes triggered by
+	// unknown key IDs.
+	jwksMinRefreshInterval = time.Minute
+)
+
+// DefaultScopes are requested when the provider does not configure any.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// PendingLogin is what is remembered, under the state, between redirecting
+// to the provider and handling its callback.
+type PendingLogin struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	RedirectURL  string `json:"redirect_url"`
+}
+
+// Client talks to one OIDC provider. It caches the discovery document and
+// the signing keys, so it should be reused; see ClientFor.
+type Client struct {
+	provider   types.OIDCProvider
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	endpoints     *endpoints
+	keys          []JSONWebKey
+	keysFetchedAt time.Time
+}
+
+type endpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*Client{}
+)
+
+// ClientFor returns the shared client of the provider.
+func ClientFor(provider types.OIDCProvider) *Client {
+	key := strings.Join([]string{
+		provider.Name,
+		provider.Issuer,
+		provider.ClientID,
+		provider.AuthorizationEndpoint,
+		provider.TokenEndpoint,
+		provider.JWKSURI,
+	}, "|")
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if client, ok := clients[key]; ok {
+		return client
+	}
+
+	client := NewClient(provider)
+	clients[key] = client
+	return client
+}
+
+// NewClient creates a client for the provider.
+func NewClient(provider types.OIDCProvider) *Client {
+	httpClient := provider.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	client := &Client{provider: provider, httpClient: httpClient}
+	if provider.AuthorizationEndpoint != "" && provider.TokenEndpoint != "" && provider.JWKSURI != "" {
+		client.endpoints = &endpoints{
+			Issuer:                provider.Issuer,
+			AuthorizationEndpoint: provider.AuthorizationEndpoint,
+			TokenEndpoint:         provider.TokenEndpoint,
+			JWKSURI:               provider.JWKSURI,
+		}
+	}
+
+	return client
+}
+
+// AuthorizationURL builds the URL the user is redirected to for signing in
+// with the authorization code flow and PKCE.
+func (c *Client) AuthorizationURL(ctx context.Context, redirectURL string, state string, nonce string, codeVerifier string) (string, error) {
+	ep, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := c.provider.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.provider.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallengeS256(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(ep.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return ep.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns
+// the raw ID token.
+func (c *Client) Exchange(ctx context.Context, code string, codeVerifier string, redirectURL string) (string, error) {
+	ep, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", c.provider.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if c.provider.ClientSecret != "" {
+		form.Set("client_secret", c.provider.ClientSecret)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := c.doJSON(request, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if status != http.StatusOK || response.Error != "" {
+		return "", fmt.Errorf("oidc: token exchange failed with status %d: %s %s", status, response.Error, response.ErrorDescription)
+	}
+
+	if response.IDToken == "" {
+		return "", errors.New("oidc: token response has no ID token")
+	}
+
+	return response.IDToken, nil
+}
+
+// VerifyIDToken verifies the ID token against the provider keys, refreshing
+// them once when the token is signed with a key that is not known yet.
+func (c *Client) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string, now time.Time) (*IDTokenClaims, error) {
+	keys, err := c.signingKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	expect := IDTokenExpectations{
+		Issuer:   c.provider.Issuer,
+		ClientID: c.provider.ClientID,
+		Nonce:    nonce,
+		Now:      now,
+	}
+
+	claims, err := VerifyIDToken(rawIDToken, keys, expect)
+	if !errors.Is(err, ErrKeyNotFound) {
+		return claims, err
+	}
+
+	// The provider may have rotated its keys
+	keys, err = c.signingKeys(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return VerifyIDToken(rawIDToken, keys, expect)
+}
+
+func (c *Client) discover(ctx context.Context) (*endpoints, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.endpoints != nil {
+		return c.endpoints, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(c.provider.Issuer, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ep endpoints
+	status, err := c.doJSON(request, &ep)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery failed with status %d", status)
+	}
+
+	if strings.TrimSuffix(ep.Issuer, "/") != strings.TrimSuffix(c.provider.Issuer, "/") {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", ep.Issuer, c.provider.Issuer)
+	}
+
+	if ep.AuthorizationEndpoint == "" || ep.TokenEndpoint == "" || ep.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is incomplete")
+	}
+
+	c.endpoints = &ep
+	return c.endpoints, nil
+}
+
+func (c *Client) signingKeys(ctx context.Context, refresh bool) ([]JSONWebKey, error) {
+	ep, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.keys != nil && (!refresh || time.Since(c.keysFetchedAt) < jwksMinRefreshInterval) {
+		return c.keys, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: JWKS request failed with status %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := ParseJWKS(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.keys = keys
+	c.keysFetchedAt = time.Now()
+	return keys, nil
+}
+
+func (c *Client) doJSON(request *http.Request, v any) (int, error) {
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseBytes))
+	if err != nil {
+		return response.StatusCode, err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil && response.StatusCode == http.StatusOK {
+		return response.StatusCode, fmt.Errorf("oidc: invalid JSON response: %w", err)
+	}
+
+	return response.StatusCode, nil
+}
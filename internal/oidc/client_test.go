@@ -0,0 +1,92 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/oidc"
+	"github.com/dracory/auth/internal/testutils"
+)
+
+// authorize signs in at the provider and returns the authorization code.
+func authorize(t *testing.T, authorizationURL string) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect from the provider, got %d", response.StatusCode)
+	}
+	location, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code")
+}
+
+func TestClient_AuthorizationCodeFlow(t *testing.T) {
+	provider, err := testutils.NewOIDCProvider("client-1", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Server.Close()
+
+	client := oidc.NewClient(provider.Config("test"))
+	ctx := context.Background()
+	redirectURL := "http://app.test/auth/callback/test"
+
+	authorizationURL, err := client.AuthorizationURL(ctx, redirectURL, "state-1", "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := authorize(t, authorizationURL)
+
+	if _, err := client.Exchange(ctx, code, "wrong-verifier", redirectURL); err == nil {
+		t.Fatal("expected exchange with a wrong PKCE verifier to fail")
+	}
+
+	code = authorize(t, authorizationURL)
+	rawIDToken, err := client.Exchange(ctx, code, "verifier-verifier-verifier-verifier-verifier", redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := client.VerifyIDToken(ctx, rawIDToken, "nonce-1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != provider.Subject || claims.Email != provider.Email {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	if _, err := client.VerifyIDToken(ctx, rawIDToken, "nonce-2", time.Now()); err == nil {
+		t.Fatal("expected nonce mismatch to be rejected")
+	}
+}
+
+func TestClient_DiscoveryIssuerMismatch(t *testing.T) {
+	provider, err := testutils.NewOIDCProvider("client-1", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Server.Close()
+
+	config := provider.Config("test")
+	config.Issuer = provider.Issuer() + "/other/.."
+
+	_, err = oidc.NewClient(config).AuthorizationURL(context.Background(), "http://app.test/cb", "s", "n", "v")
+	if err == nil {
+		t.Fatal("expected discovery with a different issuer to fail")
+	}
+}
@@ -0,0 +1,52 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/dracory/auth/internal/links"
+)
+
+// RedirectURL makes the callback link absolute using the scheme and host of
+// the request, unless it already is.
+func RedirectURL(r *http.Request, callbackLink string) string {
+	return links.Absolute(r, callbackLink)
+}
+
+// SetStateCookie remembers the state in the browser, so the callback can
+// check that the sign-in is completed by the browser that started it. The
+// cookie is SameSite=Lax, as the callback is a cross-site redirect.
+func SetStateCookie(w http.ResponseWriter, r *http.Request, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     StateCookieName,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   StateExpiresSeconds,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearStateCookie removes the state cookie.
+func ClearStateCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     StateCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// StateCookieMatches reports whether the request carries the state cookie
+// of the given state.
+func StateCookieMatches(r *http.Request, state string) bool {
+	cookie, err := r.Cookie(StateCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) == 1
+}
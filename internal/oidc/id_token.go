@@ -0,0 +1,225 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerance applied to the time based claims.
+const clockSkew = time.Minute
+
+// ErrKeyNotFound is returned when no key of the set matches the ID token, so
+// the caller can refresh the key set once and retry.
+var ErrKeyNotFound = errors.New("oidc: signing key not found")
+
+// IDTokenClaims are the claims of an ID token used for signing in.
+type IDTokenClaims struct {
+	Issuer          string       `json:"iss"`
+	Subject         string       `json:"sub"`
+	Audience        audience     `json:"aud"`
+	AuthorizedParty string       `json:"azp"`
+	ExpiresAt       float64      `json:"exp"`
+	IssuedAt        float64      `json:"iat"`
+	Nonce           string       `json:"nonce"`
+	Email           string       `json:"email"`
+	EmailVerified   flexibleBool `json:"email_verified"`
+	Name            string       `json:"name"`
+	GivenName       string       `json:"given_name"`
+	FamilyName      string       `json:"family_name"`
+}
+
+// audience accepts both the single string and the array form of "aud".
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// flexibleBool accepts booleans sent as strings, as some providers do for
+// "email_verified".
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// IDTokenExpectations are the values an ID token must match.
+type IDTokenExpectations struct {
+	Issuer   string
+	ClientID string
+	Nonce    string
+	Now      time.Time
+}
+
+// VerifyIDToken verifies the signature of the compact serialized ID token
+// with the keys and validates its claims (OpenID Connect Core section 3.1.3.7).
+func VerifyIDToken(rawIDToken string, keys []JSONWebKey, expect IDTokenExpectations) (*IDTokenClaims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed ID token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("oidc: malformed ID token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("oidc: malformed ID token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed ID token signature")
+	}
+
+	key, err := findKey(keys, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("oidc: malformed ID token payload")
+	}
+	var claims IDTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("oidc: malformed ID token payload")
+	}
+
+	if err := claims.validate(expect); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+func (c IDTokenClaims) validate(expect IDTokenExpectations) error {
+	if strings.TrimSuffix(c.Issuer, "/") != strings.TrimSuffix(expect.Issuer, "/") {
+		return fmt.Errorf("oidc: unexpected issuer %q", c.Issuer)
+	}
+
+	if !slices.Contains(c.Audience, expect.ClientID) {
+		return errors.New("oidc: ID token is not issued for this client")
+	}
+
+	if len(c.Audience) > 1 && c.AuthorizedParty != "" && c.AuthorizedParty != expect.ClientID {
+		return errors.New("oidc: ID token is authorized for another party")
+	}
+
+	now := expect.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if c.ExpiresAt == 0 || now.Add(-clockSkew).After(time.Unix(int64(c.ExpiresAt), 0)) {
+		return errors.New("oidc: ID token has expired")
+	}
+
+	if c.IssuedAt != 0 && time.Unix(int64(c.IssuedAt), 0).After(now.Add(clockSkew)) {
+		return errors.New("oidc: ID token is issued in the future")
+	}
+
+	if c.Subject == "" {
+		return errors.New("oidc: ID token has no subject")
+	}
+
+	if expect.Nonce == "" || c.Nonce != expect.Nonce {
+		return errors.New("oidc: nonce mismatch")
+	}
+
+	return nil
+}
+
+func findKey(keys []JSONWebKey, kid string, alg string) (crypto.PublicKey, error) {
+	var candidates []JSONWebKey
+	for _, key := range keys {
+		if kid != "" && key.KeyID != kid {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+
+	// Without a key ID, the key is only unambiguous if it is the single one
+	if len(candidates) == 0 || (kid == "" && len(candidates) > 1) {
+		return nil, ErrKeyNotFound
+	}
+
+	return candidates[0].Key, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("oidc: key does not match algorithm")
+		}
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("oidc: invalid ID token signature")
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return errors.New("oidc: key does not match algorithm")
+		}
+		digest := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return errors.New("oidc: invalid ID token signature")
+		}
+		return nil
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("oidc: key does not match algorithm")
+		}
+		if !ed25519.Verify(pub, signed, signature) {
+			return errors.New("oidc: invalid ID token signature")
+		}
+		return nil
+	}
+
+	// "none" and the symmetric algorithms are rejected on purpose
+	return fmt.Errorf("oidc: unsupported ID token algorithm %q", alg)
+}
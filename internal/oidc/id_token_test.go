@@ -0,0 +1,191 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+func testClaims() map[string]any {
+	return map[string]any{
+		"iss":            "https://issuer.test",
+		"sub":            "subject-1",
+		"aud":            "client-1",
+		"iat":            testNow.Unix(),
+		"exp":            testNow.Add(5 * time.Minute).Unix(),
+		"nonce":          "nonce-1",
+		"email":          "user@test.com",
+		"email_verified": "true",
+	}
+}
+
+func testExpectations() IDTokenExpectations {
+	return IDTokenExpectations{Issuer: "https://issuer.test", ClientID: "client-1", Nonce: "nonce-1", Now: testNow}
+}
+
+func signTestToken(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]any{"alg": alg, "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = sig
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyIDToken_Algorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	keys := []JSONWebKey{
+		{KeyID: "rsa", Algorithm: "RS256", Key: &rsaKey.PublicKey},
+		{KeyID: "ec", Algorithm: "ES256", Key: &ecKey.PublicKey},
+		{KeyID: "ed", Key: edKey.Public()},
+	}
+
+	tests := []struct {
+		alg string
+		kid string
+		key crypto.Signer
+	}{
+		{"RS256", "rsa", rsaKey},
+		{"ES256", "ec", ecKey},
+		{"EdDSA", "ed", edKey},
+	}
+
+	for _, tt := range tests {
+		token := signTestToken(t, tt.alg, tt.kid, tt.key, testClaims())
+		claims, err := VerifyIDToken(token, keys, testExpectations())
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tt.alg, err)
+		}
+		if claims.Subject != "subject-1" || claims.Email != "user@test.com" || !bool(claims.EmailVerified) {
+			t.Fatalf("%s: unexpected claims %+v", tt.alg, claims)
+		}
+	}
+}
+
+func TestVerifyIDToken_Rejections(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keys := []JSONWebKey{{KeyID: "rsa", Algorithm: "RS256", Key: &rsaKey.PublicKey}}
+
+	withClaim := func(name string, value any) map[string]any {
+		claims := testClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	otherParty := withClaim("aud", []string{"client-1", "client-2"})
+	otherParty["azp"] = "client-2"
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong nonce", signTestToken(t, "RS256", "rsa", rsaKey, withClaim("nonce", "other"))},
+		{"missing nonce", signTestToken(t, "RS256", "rsa", rsaKey, withClaim("nonce", nil))},
+		{"wrong audience", signTestToken(t, "RS256", "rsa", rsaKey, withClaim("aud", "client-2"))},
+		{"wrong issuer", signTestToken(t, "RS256", "rsa", rsaKey, withClaim("iss", "https://evil.test"))},
+		{"expired", signTestToken(t, "RS256", "rsa", rsaKey, withClaim("exp", testNow.Add(-2*time.Minute).Unix()))},
+		{"issued in the future", signTestToken(t, "RS256", "rsa", rsaKey, withClaim("iat", testNow.Add(time.Hour).Unix()))},
+		{"other party", signTestToken(t, "RS256", "rsa", rsaKey, otherParty)},
+		{"wrong key", signTestToken(t, "RS256", "rsa", otherKey, testClaims())},
+		{"algorithm mismatch", signTestToken(t, "ES256", "rsa", rsaKey, testClaims())},
+		{"malformed", "abc.def"},
+	}
+
+	for _, tt := range tests {
+		if _, err := VerifyIDToken(tt.token, keys, testExpectations()); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestVerifyIDToken_RejectsUnsignedToken(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(testClaims())
+	token := header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+
+	keys := []JSONWebKey{{KeyID: "", Key: ed25519.PublicKey(make([]byte, ed25519.PublicKeySize))}}
+	if _, err := VerifyIDToken(token, keys, testExpectations()); err == nil {
+		t.Fatal("expected unsigned token to be rejected")
+	}
+}
+
+func TestVerifyIDToken_UnknownKeyID(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keys := []JSONWebKey{{KeyID: "old", Key: &rsaKey.PublicKey}}
+
+	token := signTestToken(t, "RS256", "new", rsaKey, testClaims())
+	if _, err := VerifyIDToken(token, keys, testExpectations()); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecBytes, _ := ecKey.PublicKey.Bytes()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecBytes[1:33]), "y": b64(ecBytes[33:])},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+	}})
+
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].KeyID != "rsa" || keys[1].KeyID != "ec" {
+		t.Fatalf("expected the rsa and ec signing keys, got %+v", keys)
+	}
+
+	if pub, ok := keys[0].Key.(*rsa.PublicKey); !ok || pub.E != 65537 || pub.N.Cmp(rsaKey.N) != 0 {
+		t.Fatalf("unexpected RSA key %+v", keys[0].Key)
+	}
+	if pub, ok := keys[1].Key.(*ecdsa.PublicKey); !ok || !pub.Equal(&ecKey.PublicKey) {
+		t.Fatalf("unexpected EC key %+v", keys[1].Key)
+	}
+}
@@ -0,0 +1,93 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is a public key of a JSON Web Key Set (RFC 7517).
+type JSONWebKey struct {
+	KeyID     string
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+type jsonWebKeyRaw struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. Encryption keys and keys of
+// unsupported types are skipped.
+func ParseJWKS(data []byte) ([]JSONWebKey, error) {
+	var set struct {
+		Keys []jsonWebKeyRaw `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("oidc: invalid JWKS: %w", err)
+	}
+
+	keys := make([]JSONWebKey, 0, len(set.Keys))
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		key, err := raw.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, JSONWebKey{KeyID: raw.Kid, Algorithm: raw.Alg, Key: key})
+	}
+
+	return keys, nil
+}
+
+func (raw jsonWebKeyRaw) publicKey() (crypto.PublicKey, error) {
+	switch raw.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(raw.N)
+		e, errE := base64.RawURLEncoding.DecodeString(raw.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("oidc: invalid RSA key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+	case "EC":
+		if raw.Crv != "P-256" {
+			return nil, errors.New("oidc: unsupported curve")
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(raw.X)
+		y, errY := base64.RawURLEncoding.DecodeString(raw.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("oidc: invalid EC key")
+		}
+		point := append([]byte{0x04}, append(x, y...)...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	case "OKP":
+		if raw.Crv != "Ed25519" {
+			return nil, errors.New("oidc: unsupported curve")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(raw.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("oidc: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.New("oidc: unsupported key type")
+}
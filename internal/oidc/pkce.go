@@ -0,0 +1,27 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// randomBytes is the entropy of states, nonces and code verifiers (256 bits).
+const randomBytes = 32
+
+// NewRandomString returns a random base64url encoded string, suitable as
+// state, nonce or PKCE code verifier (RFC 7636 section 4.1).
+func NewRandomString() (string, error) {
+	b := make([]byte, randomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallengeS256 derives the PKCE code challenge of the verifier using the
+// S256 method (RFC 7636 section 4.2).
+func CodeChallengeS256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,27 @@
+package oidc
+
+import "testing"
+
+func TestCodeChallengeS256(t *testing.T) {
+	// RFC 7636 appendix B
+	challenge := CodeChallengeS256("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("unexpected code challenge %q", challenge)
+	}
+}
+
+func TestNewRandomString(t *testing.T) {
+	a, err := NewRandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewRandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 32 bytes, base64url without padding; also a valid PKCE verifier (43-128 chars)
+	if len(a) != 43 || a == b {
+		t.Fatalf("expected distinct 43 character strings, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,82 @@
+package testutils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// ApiKeyStore is an in-memory types.ApiKeyStore for tests.
+type ApiKeyStore struct {
+	mu   sync.Mutex
+	Keys map[string]types.ApiKey // by key ID
+}
+
+var _ types.ApiKeyStore = (*ApiKeyStore)(nil)
+
+// NewApiKeyStore returns an empty ApiKeyStore.
+func NewApiKeyStore() *ApiKeyStore {
+	return &ApiKeyStore{Keys: map[string]types.ApiKey{}}
+}
+
+func (s *ApiKeyStore) Create(ctx context.Context, key types.ApiKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Keys[key.ID] = key
+	return nil
+}
+
+func (s *ApiKeyStore) Get(ctx context.Context, id string) (*types.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (s *ApiKeyStore) ListForUser(ctx context.Context, userID string) ([]types.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []types.ApiKey{}
+	for _, key := range s.Keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *ApiKeyStore) Rename(ctx context.Context, id string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.Keys[id]; ok {
+		key.Name = name
+		s.Keys[id] = key
+	}
+	return nil
+}
+
+func (s *ApiKeyStore) Touch(ctx context.Context, id string, lastUsedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.Keys[id]; ok {
+		key.LastUsedAt = lastUsedAt
+		s.Keys[id] = key
+	}
+	return nil
+}
+
+func (s *ApiKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Keys, id)
+	return nil
+}
@@ -2,10 +2,14 @@ package testutils
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/dracory/auth/internal/links"
 	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
 )
 
 func NewAuthSharedForTest() types.AuthSharedInterface {
@@ -14,9 +18,39 @@ func NewAuthSharedForTest() types.AuthSharedInterface {
 	a.SetRedirectOnSuccess("http://localhost/dashboard")
 	a.SetLayout(func(content string) string { return content })
 	a.SetLogger(slog.Default())
+	a.SetCounterStore(newTemporaryKeyCounterStore(a))
 	return a
 }
 
+// newTemporaryKeyCounterStore returns a counter store over the temporary
+// key callbacks of the auth as they are when a counter is used, like the
+// default of the constructors
+func newTemporaryKeyCounterStore(a *authSharedTest) types.CounterStore {
+	return utils.NewTemporaryKeyCounterStore(
+		func(key string) (string, error) {
+			if a.temporaryKeyGet == nil {
+				return "", errors.New("FuncTemporaryKeyGet is not configured")
+			}
+			return a.temporaryKeyGet(key)
+		},
+		func(key string, value string, expiresSeconds int) error {
+			if a.temporaryKeySet == nil {
+				return errors.New("FuncTemporaryKeySet is not configured")
+			}
+			return a.temporaryKeySet(key, value, expiresSeconds)
+		},
+		func(key string) error {
+			if a.temporaryKeyDelete != nil {
+				return a.temporaryKeyDelete(key)
+			}
+			if a.temporaryKeySet == nil {
+				return errors.New("FuncTemporaryKeySet is not configured")
+			}
+			return a.temporaryKeySet(key, "", 1)
+		},
+	)
+}
+
 type authSharedTest struct {
 	endpoint                              string
 	layout                                func(content string) string
@@ -24,9 +58,14 @@ type authSharedTest struct {
 	registration                          bool
 	passwordless                          bool
 	verification                          bool
+	loginCodeBinding                      bool
 	temporaryKeyGet                       func(key string) (string, error)
+	temporaryKeyDelete                    func(key string) error
 	temporaryKeySet                       func(key string, value string, expiresSeconds int) error
 	funcUserFindByAuthToken               func(ctx context.Context, token string, options types.UserAuthOptions) (string, error)
+	sessionStore                          types.SessionStore
+	sessionLifetime                       time.Duration
+	sessionIdleTimeout                    time.Duration
 	redirectOnSuccess                     string
 	loginURL                              string
 	useCookies                            bool
@@ -35,9 +74,15 @@ type authSharedTest struct {
 	funcUserLogin                         func(ctx context.Context, username, password string, options types.UserAuthOptions) (string, error)
 	passwordlessUserRegister              func(ctx context.Context, email, firstName, lastName string, options types.UserAuthOptions) error
 	funcUserRegister                      func(ctx context.Context, username, password, firstName, lastName string, options types.UserAuthOptions) error
+	funcUserRegisterWithPasswordHash      func(ctx context.Context, username, passwordHash, firstName, lastName string, options types.UserAuthOptions) error
+	funcPasswordHash                      func(password string) (string, error)
 	funcUserPasswordChange                func(ctx context.Context, userID, password string, options types.UserAuthOptions) error
 	funcUserLogout                        func(ctx context.Context, userID string, options types.UserAuthOptions) error
 	passwordlessUserFindByEmail           func(ctx context.Context, email string, options types.UserAuthOptions) (string, error)
+	passwordlessChannel                   types.PasswordlessChannel
+	passwordlessUserFindByPhone           func(ctx context.Context, phone string, options types.UserAuthOptions) (string, error)
+	passwordlessSmsTemplateLoginCode      func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string
+	passwordlessSmsSend                   func(ctx context.Context, phone string, message string) error
 	funcUserFindByUsername                func(ctx context.Context, username, firstName, lastName string, options types.UserAuthOptions) (string, error)
 	funcUserStoreAuthToken                func(ctx context.Context, token, userID string, options types.UserAuthOptions) error
 	emailTemplatePasswordRestore          func(ctx context.Context, userID string, passwordRestoreLink string, options types.UserAuthOptions) string
@@ -46,6 +91,23 @@ type authSharedTest struct {
 	passwordlessEmailTemplateLoginCode    func(ctx context.Context, email string, passwordRestoreLink string, options types.UserAuthOptions) string
 	passwordlessEmailTemplateRegisterCode func(ctx context.Context, email string, passwordRestoreLink string, options types.UserAuthOptions) string
 	passwordlessEmailSend                 func(ctx context.Context, email string, emailSubject, emailBody string) error
+	webAuthn                              *types.WebAuthnConfig
+	oidc                                  *types.OIDCConfig
+	jwt                                   *types.JWTConfig
+	refreshToken                          *types.RefreshTokenConfig
+	rememberMe                            *types.RememberMeConfig
+	authTokenHashing                      *types.AuthTokenHashingConfig
+	apiKeys                               *types.ApiKeyConfig
+	accountLockout                        *types.AccountLockoutConfig
+	counterStore                          types.CounterStore
+	funcUserRoles                         func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error)
+	funcUserPermissions                   func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error)
+	magicLink                             *types.MagicLinkConfig
+	funcUserTotpSecretFind                func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error)
+	funcUserTotpSecretStore               func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error
+	totpIssuer                            string
+	funcUserRecoveryCodesStore            func(ctx context.Context, userID string, codeHashes []string, options types.UserAuthOptions) error
+	funcUserRecoveryCodeConsume           func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (bool, error)
 }
 
 func (a *authSharedTest) Router() *http.ServeMux { return http.NewServeMux() }
@@ -56,6 +118,10 @@ func (a *authSharedTest) IsPasswordless() bool { return a.passwordless }
 
 func (a *authSharedTest) IsVerificationEnabled() bool { return a.verification }
 
+func (a *authSharedTest) IsLoginCodeBindingEnabled() bool {
+	return a.loginCodeBinding || a.GetPasswordlessChannel() == types.PasswordlessChannelSms
+}
+
 func (a *authSharedTest) WebAuthOrRedirectMiddleware(next http.Handler) http.Handler { return next }
 
 func (a *authSharedTest) ApiAuthOrErrorMiddleware(next http.Handler) http.Handler { return next }
@@ -64,6 +130,20 @@ func (a *authSharedTest) WebAppendUserIdIfExistsMiddleware(next http.Handler) ht
 	return next
 }
 
+func (a *authSharedTest) RequireRole(next http.Handler, roles ...string) http.Handler { return next }
+
+func (a *authSharedTest) RequireAnyPermission(next http.Handler, permissions ...string) http.Handler {
+	return next
+}
+
+func (a *authSharedTest) RequireAllPermissions(next http.Handler, permissions ...string) http.Handler {
+	return next
+}
+
+func (a *authSharedTest) RequireRecentAuth(next http.Handler, maxAge time.Duration) http.Handler {
+	return next
+}
+
 func (a *authSharedTest) GetCurrentUserID(r *http.Request) string { return "" }
 
 func (a *authSharedTest) GetUseCookies() bool { return a.useCookies }
@@ -91,6 +171,18 @@ func (a *authSharedTest) SetFuncUserFindByAuthToken(fn func(ctx context.Context,
 	a.funcUserFindByAuthToken = fn
 }
 
+func (a *authSharedTest) GetSessionStore() types.SessionStore { return a.sessionStore }
+
+func (a *authSharedTest) SetSessionStore(store types.SessionStore) { a.sessionStore = store }
+
+func (a *authSharedTest) GetSessionLifetime() time.Duration { return a.sessionLifetime }
+
+func (a *authSharedTest) SetSessionLifetime(lifetime time.Duration) { a.sessionLifetime = lifetime }
+
+func (a *authSharedTest) GetSessionIdleTimeout() time.Duration { return a.sessionIdleTimeout }
+
+func (a *authSharedTest) SetSessionIdleTimeout(timeout time.Duration) { a.sessionIdleTimeout = timeout }
+
 func (a *authSharedTest) SetFuncTemporaryKeyGet(fn func(key string) (string, error)) {
 	a.temporaryKeyGet = fn
 }
@@ -99,6 +191,14 @@ func (a *authSharedTest) SetFuncTemporaryKeySet(fn func(key string, value string
 	a.temporaryKeySet = fn
 }
 
+func (a *authSharedTest) GetFuncTemporaryKeyDelete() func(key string) error {
+	return a.temporaryKeyDelete
+}
+
+func (a *authSharedTest) SetFuncTemporaryKeyDelete(fn func(key string) error) {
+	a.temporaryKeyDelete = fn
+}
+
 func (a *authSharedTest) GetDisableRateLimit() bool { return a.disableRateLimit }
 
 func (a *authSharedTest) SetDisableRateLimit(disable bool) { a.disableRateLimit = disable }
@@ -151,6 +251,41 @@ func (a *authSharedTest) SetPasswordlessFuncEmailSend(fn func(ctx context.Contex
 	a.passwordlessEmailSend = fn
 }
 
+func (a *authSharedTest) GetPasswordlessChannel() types.PasswordlessChannel {
+	if a.passwordlessChannel == "" {
+		return types.PasswordlessChannelEmail
+	}
+	return a.passwordlessChannel
+}
+
+func (a *authSharedTest) SetPasswordlessChannel(channel types.PasswordlessChannel) {
+	a.passwordlessChannel = channel
+}
+
+func (a *authSharedTest) GetPasswordlessUserFindByPhone() func(ctx context.Context, phone string, options types.UserAuthOptions) (string, error) {
+	return a.passwordlessUserFindByPhone
+}
+
+func (a *authSharedTest) SetPasswordlessUserFindByPhone(fn func(ctx context.Context, phone string, options types.UserAuthOptions) (string, error)) {
+	a.passwordlessUserFindByPhone = fn
+}
+
+func (a *authSharedTest) GetPasswordlessFuncSmsTemplateLoginCode() func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string {
+	return a.passwordlessSmsTemplateLoginCode
+}
+
+func (a *authSharedTest) SetPasswordlessFuncSmsTemplateLoginCode(fn func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string) {
+	a.passwordlessSmsTemplateLoginCode = fn
+}
+
+func (a *authSharedTest) GetPasswordlessFuncSmsSend() func(ctx context.Context, phone string, message string) error {
+	return a.passwordlessSmsSend
+}
+
+func (a *authSharedTest) SetPasswordlessFuncSmsSend(fn func(ctx context.Context, phone string, message string) error) {
+	a.passwordlessSmsSend = fn
+}
+
 func (a *authSharedTest) RegisterUserWithPassword(ctx context.Context, email, password, firstName, lastName string, options types.UserAuthOptions) (string, string, string) {
 	// Default test double: no-op registration.
 	return "", "", ""
@@ -169,6 +304,22 @@ func (a *authSharedTest) SetFuncUserRegister(fn func(ctx context.Context, userna
 	a.funcUserRegister = fn
 }
 
+func (a *authSharedTest) GetFuncUserRegisterWithPasswordHash() func(ctx context.Context, username, passwordHash, firstName, lastName string, options types.UserAuthOptions) error {
+	return a.funcUserRegisterWithPasswordHash
+}
+
+func (a *authSharedTest) SetFuncUserRegisterWithPasswordHash(fn func(ctx context.Context, username, passwordHash, firstName, lastName string, options types.UserAuthOptions) error) {
+	a.funcUserRegisterWithPasswordHash = fn
+}
+
+func (a *authSharedTest) GetFuncPasswordHash() func(password string) (string, error) {
+	return a.funcPasswordHash
+}
+
+func (a *authSharedTest) SetFuncPasswordHash(fn func(password string) (string, error)) {
+	a.funcPasswordHash = fn
+}
+
 func (a *authSharedTest) GetFuncUserPasswordChange() func(ctx context.Context, userID, password string, options types.UserAuthOptions) error {
 	return a.funcUserPasswordChange
 }
@@ -233,6 +384,112 @@ func (a *authSharedTest) SetFuncUserStoreAuthToken(fn func(ctx context.Context,
 	a.funcUserStoreAuthToken = fn
 }
 
+func (a *authSharedTest) GetWebAuthn() *types.WebAuthnConfig { return a.webAuthn }
+
+func (a *authSharedTest) SetWebAuthn(config *types.WebAuthnConfig) { a.webAuthn = config }
+
+func (a *authSharedTest) GetOIDC() *types.OIDCConfig { return a.oidc }
+
+func (a *authSharedTest) SetOIDC(config *types.OIDCConfig) { a.oidc = config }
+
+func (a *authSharedTest) GetJWT() *types.JWTConfig { return a.jwt }
+
+func (a *authSharedTest) SetJWT(config *types.JWTConfig) { a.jwt = config }
+
+func (a *authSharedTest) GetRefreshToken() *types.RefreshTokenConfig { return a.refreshToken }
+
+func (a *authSharedTest) SetRefreshToken(config *types.RefreshTokenConfig) {
+	a.refreshToken = config
+}
+
+func (a *authSharedTest) GetRememberMe() *types.RememberMeConfig { return a.rememberMe }
+
+func (a *authSharedTest) SetRememberMe(config *types.RememberMeConfig) {
+	a.rememberMe = config
+}
+
+func (a *authSharedTest) GetMagicLink() *types.MagicLinkConfig { return a.magicLink }
+
+func (a *authSharedTest) SetMagicLink(config *types.MagicLinkConfig) {
+	a.magicLink = config
+}
+
+func (a *authSharedTest) GetAuthTokenHashing() *types.AuthTokenHashingConfig {
+	return a.authTokenHashing
+}
+
+func (a *authSharedTest) SetAuthTokenHashing(config *types.AuthTokenHashingConfig) {
+	a.authTokenHashing = config
+}
+
+func (a *authSharedTest) GetApiKeys() *types.ApiKeyConfig { return a.apiKeys }
+
+func (a *authSharedTest) SetApiKeys(config *types.ApiKeyConfig) {
+	a.apiKeys = config
+}
+
+func (a *authSharedTest) GetAccountLockout() *types.AccountLockoutConfig { return a.accountLockout }
+
+func (a *authSharedTest) SetAccountLockout(config *types.AccountLockoutConfig) {
+	a.accountLockout = config
+}
+
+func (a *authSharedTest) GetCounterStore() types.CounterStore { return a.counterStore }
+
+func (a *authSharedTest) SetCounterStore(store types.CounterStore) {
+	a.counterStore = store
+}
+
+func (a *authSharedTest) GetFuncUserRoles() func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+	return a.funcUserRoles
+}
+
+func (a *authSharedTest) SetFuncUserRoles(fn func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error)) {
+	a.funcUserRoles = fn
+}
+
+func (a *authSharedTest) GetFuncUserPermissions() func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+	return a.funcUserPermissions
+}
+
+func (a *authSharedTest) SetFuncUserPermissions(fn func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error)) {
+	a.funcUserPermissions = fn
+}
+
+func (a *authSharedTest) GetFuncUserTotpSecretFind() func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error) {
+	return a.funcUserTotpSecretFind
+}
+
+func (a *authSharedTest) SetFuncUserTotpSecretFind(fn func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error)) {
+	a.funcUserTotpSecretFind = fn
+}
+
+func (a *authSharedTest) GetFuncUserTotpSecretStore() func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error {
+	return a.funcUserTotpSecretStore
+}
+
+func (a *authSharedTest) SetFuncUserTotpSecretStore(fn func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error) {
+	a.funcUserTotpSecretStore = fn
+}
+
+func (a *authSharedTest) GetFuncUserRecoveryCodesStore() func(ctx context.Context, userID string, codeHashes []string, options types.UserAuthOptions) error {
+	return a.funcUserRecoveryCodesStore
+}
+
+func (a *authSharedTest) SetFuncUserRecoveryCodesStore(fn func(ctx context.Context, userID string, codeHashes []string, options types.UserAuthOptions) error) {
+	a.funcUserRecoveryCodesStore = fn
+}
+
+func (a *authSharedTest) GetFuncUserRecoveryCodeConsume() func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (bool, error) {
+	return a.funcUserRecoveryCodeConsume
+}
+
+func (a *authSharedTest) SetFuncUserRecoveryCodeConsume(fn func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (bool, error)) {
+	a.funcUserRecoveryCodeConsume = fn
+}
+
+func (a *authSharedTest) GetTotpIssuer() string { return a.totpIssuer }
+
 func (a *authSharedTest) SetAuthCookie(w http.ResponseWriter, r *http.Request, token string) {
 	// test double: no-op
 }
@@ -241,6 +498,14 @@ func (a *authSharedTest) RemoveAuthCookie(w http.ResponseWriter, r *http.Request
 	// test double: no-op
 }
 
+func (a *authSharedTest) SetRememberCookie(w http.ResponseWriter, r *http.Request, value string, expiresAt time.Time) {
+	// test double: no-op
+}
+
+func (a *authSharedTest) RemoveRememberCookie(w http.ResponseWriter, r *http.Request) {
+	// test double: no-op
+}
+
 func (a *authSharedTest) TemporaryKeyGet(token string) (string, error) { return "", nil }
 
 func (a *authSharedTest) AuthenticateViaUsername(w http.ResponseWriter, r *http.Request, email, firstName, lastName string) {
@@ -265,6 +530,37 @@ func (a *authSharedTest) LinkApiRegister() string { return "" }
 
 func (a *authSharedTest) LinkApiRegisterCodeVerify() string { return "" }
 
+func (a *authSharedTest) LinkPasskeys() string { return "" }
+
+func (a *authSharedTest) LinkSessions() string { return "" }
+
+func (a *authSharedTest) LinkReauth() string { return links.Reauth(a.endpoint) }
+
+func (a *authSharedTest) LinkAccountUnlock(token string) string {
+	return links.AccountUnlock(a.endpoint) + "?t=" + token
+}
+
+func (a *authSharedTest) LinkOIDCLogin(provider string) string { return "" }
+
+func (a *authSharedTest) LinkOIDCCallback(provider string) string { return "" }
+
+func (a *authSharedTest) LinkApiWebAuthnRegisterBegin() string { return "" }
+
+func (a *authSharedTest) LinkApiWebAuthnRegisterFinish() string { return "" }
+
+func (a *authSharedTest) LinkApiWebAuthnLoginBegin() string { return "" }
+
+func (a *authSharedTest) LinkApiWebAuthnLoginFinish() string  { return "" }
+func (a *authSharedTest) LinkApiTokenRefresh() string         { return "" }
+func (a *authSharedTest) LinkApiReauth() string               { return links.ApiReauth(a.endpoint) }
+func (a *authSharedTest) LinkApiSessions() string             { return "" }
+func (a *authSharedTest) LinkApiSessionRevoke() string        { return "" }
+func (a *authSharedTest) LinkApiSessionsRevokeOthers() string { return "" }
+func (a *authSharedTest) LinkApiApiKeys() string              { return "" }
+func (a *authSharedTest) LinkApiApiKeyCreate() string         { return "" }
+func (a *authSharedTest) LinkApiApiKeyRename() string         { return "" }
+func (a *authSharedTest) LinkApiApiKeyRevoke() string         { return "" }
+
 // AuthPasswordInterface additional URL helpers. For tests we can return
 // empty strings as they are not used by the core logic under test.
 func (a *authSharedTest) LinkPasswordRestore() string { return "" }
@@ -275,6 +571,18 @@ func (a *authSharedTest) LinkApiPasswordRestore() string { return "" }
 
 func (a *authSharedTest) LinkApiPasswordReset() string { return "" }
 
+func (a *authSharedTest) LinkLogin2faVerify() string { return "" }
+
+func (a *authSharedTest) LinkApiLogin2faVerify() string { return "" }
+
+func (a *authSharedTest) LinkTwoFactorEnroll() string { return "" }
+
+func (a *authSharedTest) LinkApiTwoFactorEnroll() string { return "" }
+
+func (a *authSharedTest) LinkApiTwoFactorEnrollConfirm() string { return "" }
+
+func (a *authSharedTest) LinkApiTwoFactorRecoveryCodes() string { return "" }
+
 func (a *authSharedTest) GetEndpoint() string { return a.endpoint }
 
 func (a *authSharedTest) SetEndpoint(endpoint string) { a.endpoint = endpoint }
@@ -318,6 +626,12 @@ func SetVerificationForTest(a types.AuthSharedInterface, verification bool) {
 	}
 }
 
+func SetLoginCodeBindingForTest(a types.AuthSharedInterface, binding bool) {
+	if v, ok := a.(*authSharedTest); ok {
+		v.loginCodeBinding = binding
+	}
+}
+
 // SetFuncUserFindByAuthTokenForTest allows tests to control auth-token lookup behaviour.
 func SetFuncUserFindByAuthTokenForTest(a types.AuthSharedInterface, fn func(ctx context.Context, token string, options types.UserAuthOptions) (string, error)) {
 	if v, ok := a.(*authSharedTest); ok {
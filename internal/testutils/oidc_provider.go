@@ -0,0 +1,251 @@
+package testutils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// OIDCProvider is an in-process OpenID Connect provider for tests. It
+// implements discovery, the authorization endpoint (signing in the configured
+// user without any prompt), the token endpoint with PKCE and the JWKS
+// endpoint. ID tokens are signed with RS256.
+type OIDCProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	PrivateKey   *rsa.PrivateKey
+	KeyID        string
+
+	// Claims of the user signing in at the authorization endpoint
+	Subject       string
+	Email         string
+	EmailVerified bool
+
+	// TokenClaims, when set, are merged into the next ID tokens, e.g. to
+	// issue tokens with a wrong nonce or audience
+	TokenClaims map[string]any
+
+	mu    sync.Mutex
+	codes map[string]oidcAuthorization
+}
+
+type oidcAuthorization struct {
+	ClientID      string
+	RedirectURI   string
+	Nonce         string
+	CodeChallenge string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// NewOIDCProvider starts an OIDC provider for the client. Close it with
+// Server.Close.
+func NewOIDCProvider(clientID string, clientSecret string) (*OIDCProvider, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &OIDCProvider{
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		PrivateKey:    privateKey,
+		KeyID:         "test-key",
+		Subject:       "external-user-1",
+		Email:         "external@test.com",
+		EmailVerified: true,
+		codes:         map[string]oidcAuthorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("/authorize", p.handleAuthorize)
+	mux.HandleFunc("/token", p.handleToken)
+	mux.HandleFunc("/jwks", p.handleJWKS)
+	p.Server = httptest.NewServer(mux)
+
+	return p, nil
+}
+
+// Issuer returns the issuer URL of the provider.
+func (p *OIDCProvider) Issuer() string {
+	return p.Server.URL
+}
+
+// Config returns the provider configuration pointing at this provider.
+func (p *OIDCProvider) Config(name string) types.OIDCProvider {
+	return types.OIDCProvider{
+		Name:         name,
+		Issuer:       p.Issuer(),
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+	}
+}
+
+// SignIDToken signs the claims as an RS256 ID token with the provider key.
+func (p *OIDCProvider) SignIDToken(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]any{"alg": "RS256", "typ": "JWT", "kid": p.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (p *OIDCProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/jwks",
+	})
+}
+
+func (p *OIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+
+	if query.Get("client_id") != p.ClientID || redirectURI == "" {
+		http.Error(w, "invalid client", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code := RandomTestString()
+
+	p.mu.Lock()
+	p.codes[code] = oidcAuthorization{
+		ClientID:      p.ClientID,
+		RedirectURI:   redirectURI,
+		Nonce:         query.Get("nonce"),
+		CodeChallenge: query.Get("code_challenge"),
+		Subject:       p.Subject,
+		Email:         p.Email,
+		EmailVerified: p.EmailVerified,
+	}
+	p.mu.Unlock()
+
+	callback, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect uri", http.StatusBadRequest)
+		return
+	}
+	values := callback.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	callback.RawQuery = values.Encode()
+
+	http.Redirect(w, r, callback.String(), http.StatusFound)
+}
+
+func (p *OIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	authorization, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	if r.PostForm.Get("client_id") != p.ClientID || r.PostForm.Get("client_secret") != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+
+	verifierSum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("redirect_uri") != authorization.RedirectURI ||
+		base64.RawURLEncoding.EncodeToString(verifierSum[:]) != authorization.CodeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":            p.Issuer(),
+		"sub":            authorization.Subject,
+		"aud":            p.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          authorization.Nonce,
+		"email":          authorization.Email,
+		"email_verified": authorization.EmailVerified,
+	}
+	for k, v := range p.TokenClaims {
+		claims[k] = v
+	}
+
+	idToken, err := p.SignIDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": RandomTestString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *OIDCProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	publicKey := p.PrivateKey.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": p.KeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}},
+	})
+}
+
+// RandomTestString returns a random URL safe string.
+func RandomTestString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,98 @@
+package testutils
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dracory/auth/types"
+)
+
+// RefreshTokenStore is an in-memory refresh token store for tests.
+type RefreshTokenStore struct {
+	mu     sync.Mutex
+	Tokens map[string]types.RefreshToken // by token hash
+}
+
+// NewRefreshTokenStore returns an empty RefreshTokenStore.
+func NewRefreshTokenStore() *RefreshTokenStore {
+	return &RefreshTokenStore{Tokens: map[string]types.RefreshToken{}}
+}
+
+// Config returns a RefreshTokenConfig backed by the store.
+func (s *RefreshTokenStore) Config() *types.RefreshTokenConfig {
+	return &types.RefreshTokenConfig{
+		FuncRefreshTokenStore: func(ctx context.Context, token types.RefreshToken, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.Tokens[token.TokenHash] = token
+			return nil
+		},
+		FuncRefreshTokenFindByHash: func(ctx context.Context, tokenHash string, options types.UserAuthOptions) (*types.RefreshToken, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			token, ok := s.Tokens[tokenHash]
+			if !ok {
+				return nil, nil
+			}
+			return &token, nil
+		},
+		FuncRefreshTokenMarkUsed: func(ctx context.Context, tokenHash string, options types.UserAuthOptions) (bool, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			token, ok := s.Tokens[tokenHash]
+			if !ok || token.Used {
+				return false, nil
+			}
+			token.Used = true
+			s.Tokens[tokenHash] = token
+			return true, nil
+		},
+		FuncRefreshTokenFindByFamily: func(ctx context.Context, familyID string, options types.UserAuthOptions) ([]types.RefreshToken, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			tokens := []types.RefreshToken{}
+			for _, token := range s.Tokens {
+				if token.FamilyID == familyID {
+					tokens = append(tokens, token)
+				}
+			}
+			return tokens, nil
+		},
+		FuncRefreshTokenFamilyRevoke: func(ctx context.Context, familyID string, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for hash, token := range s.Tokens {
+				if token.FamilyID == familyID {
+					delete(s.Tokens, hash)
+				}
+			}
+			return nil
+		},
+		FuncRefreshTokenRevokeAllForUser: func(ctx context.Context, userID string, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for hash, token := range s.Tokens {
+				if token.UserID == userID {
+					delete(s.Tokens, hash)
+				}
+			}
+			return nil
+		},
+		FuncRefreshTokenRevokeBySession: func(ctx context.Context, sessionID string, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			families := map[string]bool{}
+			for _, token := range s.Tokens {
+				if token.SessionID == sessionID {
+					families[token.FamilyID] = true
+				}
+			}
+			for hash, token := range s.Tokens {
+				if families[token.FamilyID] {
+					delete(s.Tokens, hash)
+				}
+			}
+			return nil
+		},
+	}
+}
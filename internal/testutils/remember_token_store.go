@@ -0,0 +1,69 @@
+package testutils
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dracory/auth/types"
+)
+
+// RememberTokenStore is an in-memory remember-me token store for tests.
+type RememberTokenStore struct {
+	mu     sync.Mutex
+	Tokens map[string]types.RememberToken // by selector
+}
+
+// NewRememberTokenStore returns an empty RememberTokenStore.
+func NewRememberTokenStore() *RememberTokenStore {
+	return &RememberTokenStore{Tokens: map[string]types.RememberToken{}}
+}
+
+// Config returns a RememberMeConfig backed by the store.
+func (s *RememberTokenStore) Config() *types.RememberMeConfig {
+	return &types.RememberMeConfig{
+		FuncRememberTokenStore: func(ctx context.Context, token types.RememberToken, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.Tokens[token.Selector] = token
+			return nil
+		},
+		FuncRememberTokenFindBySelector: func(ctx context.Context, selector string, options types.UserAuthOptions) (*types.RememberToken, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			token, ok := s.Tokens[selector]
+			if !ok {
+				return nil, nil
+			}
+			return &token, nil
+		},
+		FuncRememberTokenDelete: func(ctx context.Context, selector string, options types.UserAuthOptions) (bool, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if _, ok := s.Tokens[selector]; !ok {
+				return false, nil
+			}
+			delete(s.Tokens, selector)
+			return true, nil
+		},
+		FuncRememberTokenDeleteAllForUser: func(ctx context.Context, userID string, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for selector, token := range s.Tokens {
+				if token.UserID == userID {
+					delete(s.Tokens, selector)
+				}
+			}
+			return nil
+		},
+		FuncRememberTokenDeleteBySession: func(ctx context.Context, sessionID string, options types.UserAuthOptions) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for selector, token := range s.Tokens {
+				if token.SessionID == sessionID {
+					delete(s.Tokens, selector)
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package testutils
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// SessionStore is an in-memory types.SessionStore for tests.
+type SessionStore struct {
+	mu       sync.Mutex
+	Sessions map[string]types.Session // by session ID
+}
+
+var _ types.SessionStore = (*SessionStore)(nil)
+
+// NewSessionStore returns an empty SessionStore.
+func NewSessionStore() *SessionStore {
+	return &SessionStore{Sessions: map[string]types.Session{}}
+}
+
+func (s *SessionStore) Create(ctx context.Context, session types.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sessions[session.ID] = session
+	return nil
+}
+
+func (s *SessionStore) Get(ctx context.Context, sessionID string) (*types.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.Sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (s *SessionStore) Touch(ctx context.Context, sessionID string, lastSeenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.Sessions[sessionID]; ok {
+		session.LastSeenAt = lastSeenAt
+		s.Sessions[sessionID] = session
+	}
+	return nil
+}
+
+func (s *SessionStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Sessions, sessionID)
+	return nil
+}
+
+func (s *SessionStore) DeleteAllForUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.Sessions {
+		if session.UserID == userID {
+			delete(s.Sessions, id)
+		}
+	}
+	return nil
+}
+
+func (s *SessionStore) ListForUser(ctx context.Context, userID string) ([]types.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := []types.Session{}
+	for _, session := range s.Sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
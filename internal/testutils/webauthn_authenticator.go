@@ -0,0 +1,134 @@
+package testutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/dracory/auth/internal/webauthn"
+)
+
+// WebAuthnAuthenticator is a software authenticator backed by an ES256 key,
+// used to drive WebAuthn ceremonies in tests without real hardware.
+type WebAuthnAuthenticator struct {
+	RPID   string
+	Origin string
+
+	CredentialID []byte
+	PrivateKey   *ecdsa.PrivateKey
+	SignCount    uint32
+
+	// WithoutUserVerification makes Get report user presence only, like a
+	// security key without a PIN.
+	WithoutUserVerification bool
+}
+
+// NewWebAuthnAuthenticator creates a software authenticator with a fresh
+// P-256 key and a random credential ID.
+func NewWebAuthnAuthenticator(rpID string, origin string) (*WebAuthnAuthenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnAuthenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		CredentialID: credentialID,
+		PrivateKey:   key,
+	}, nil
+}
+
+// CredentialIDString returns the base64url encoded credential ID.
+func (a *WebAuthnAuthenticator) CredentialIDString() string {
+	return webauthn.EncodeBase64URL(a.CredentialID)
+}
+
+// ClientDataJSON builds the clientDataJSON a browser would send.
+func (a *WebAuthnAuthenticator) ClientDataJSON(ceremonyType string, challenge string) []byte {
+	data, _ := json.Marshal(webauthn.ClientData{
+		Type:      ceremonyType,
+		Challenge: challenge,
+		Origin:    a.Origin,
+	})
+	return data
+}
+
+// Create answers a registration challenge. format is either "none" or
+// "packed" (self attestation).
+func (a *WebAuthnAuthenticator) Create(challenge string, format string) (clientDataJSON []byte, attestationObject []byte, err error) {
+	clientDataJSON = a.ClientDataJSON(webauthn.ClientDataTypeCreate, challenge)
+
+	publicKey, err := webauthn.EncodeES256PublicKey(&a.PrivateKey.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authData := a.authenticatorData(webauthn.FlagUserPresent | webauthn.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, publicKey...)
+
+	statement := map[any]any{}
+	if format == webauthn.AttestationFormatPacked {
+		signature, errSign := a.sign(authData, clientDataJSON)
+		if errSign != nil {
+			return nil, nil, errSign
+		}
+		statement["alg"] = webauthn.AlgES256
+		statement["sig"] = signature
+	}
+
+	attestationObject, err = webauthn.CborEncode(map[any]any{
+		"fmt":      format,
+		"attStmt":  statement,
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return clientDataJSON, attestationObject, nil
+}
+
+// Get answers a login challenge, incrementing the signature counter.
+func (a *WebAuthnAuthenticator) Get(challenge string) (clientDataJSON []byte, authenticatorData []byte, signature []byte, err error) {
+	a.SignCount++
+
+	clientDataJSON = a.ClientDataJSON(webauthn.ClientDataTypeGet, challenge)
+
+	flags := webauthn.FlagUserPresent | webauthn.FlagUserVerified
+	if a.WithoutUserVerification {
+		flags = webauthn.FlagUserPresent
+	}
+	authenticatorData = a.authenticatorData(flags)
+
+	signature, err = a.sign(authenticatorData, clientDataJSON)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return clientDataJSON, authenticatorData, signature, nil
+}
+
+func (a *WebAuthnAuthenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *WebAuthnAuthenticator) sign(authenticatorData []byte, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, a.PrivateKey, digest[:])
+}
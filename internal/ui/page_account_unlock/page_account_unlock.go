@@ -0,0 +1,75 @@
+package page_account_unlock
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for unlocking an account
+// with the link of the unlock email.
+type Dependencies struct {
+	// Unlock clears the failures of the account the link was sent for and
+	// consumes the link, reporting false for unknown or expired links
+	Unlock func(token string) (bool, error)
+
+	UrlLogin string
+	Layout   func(content string) string
+	Logger   *slog.Logger
+}
+
+// PageAccountUnlock unlocks the account with the link of the unlock email
+// and tells the user they can log in again.
+func PageAccountUnlock(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Unlock Account",
+		Layout:     deps.Layout,
+		Content:    shared.MessageContent("Unlock Account", AccountUnlock(r, deps), deps.UrlLogin, "Go to login"),
+		Logger:     deps.Logger,
+		LogMessage: "failed to write account unlock page response",
+	})
+}
+
+// PageAccountUnlockWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func PageAccountUnlockWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	deps := Dependencies{
+		Unlock: func(token string) (bool, error) {
+			return core.AccountUnlock(a, token)
+		},
+		UrlLogin: links.Login(a.GetEndpoint()),
+		Layout:   a.GetLayout(),
+		Logger:   a.GetLogger(),
+	}
+
+	PageAccountUnlock(w, r, deps)
+}
+
+// AccountUnlock unlocks the account of the link and returns the message
+// shown to the user. It does not write HTTP responses.
+func AccountUnlock(r *http.Request, deps Dependencies) string {
+	token := req.GetStringTrimmed(r, "t")
+	if token == "" || deps.Unlock == nil {
+		return "Unlock link is invalid"
+	}
+
+	unlocked, err := deps.Unlock(token)
+	if err != nil {
+		if deps.Logger != nil {
+			deps.Logger.Error("account unlock failed", "error", err)
+		}
+		return "Failed to process request. Please try again later"
+	}
+
+	if !unlocked {
+		return "Unlock link has expired or has already been used"
+	}
+
+	return "Your account has been unlocked. You can log in again"
+}
@@ -0,0 +1,42 @@
+package page_account_unlock
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPageAccountUnlock(t *testing.T) {
+	deps := Dependencies{
+		Unlock: func(token string) (bool, error) {
+			switch token {
+			case "valid":
+				return true, nil
+			case "broken":
+				return false, errors.New("store unavailable")
+			}
+			return false, nil
+		},
+		UrlLogin: "http://localhost/auth/login",
+		Layout:   func(content string) string { return content },
+	}
+
+	tests := map[string]string{
+		"":       "Unlock link is invalid",
+		"used":   "Unlock link has expired or has already been used",
+		"broken": "Failed to process request. Please try again later",
+		"valid":  "Your account has been unlocked. You can log in again",
+	}
+
+	for token, expected := range tests {
+		recorder := httptest.NewRecorder()
+		PageAccountUnlock(recorder, httptest.NewRequest(http.MethodGet, "/auth/account-unlock?t="+token, nil), deps)
+
+		body := recorder.Body.String()
+		if !strings.Contains(body, expected) || !strings.Contains(body, "http://localhost/auth/login") {
+			t.Errorf("token %q: expected %q with a link to log in, got %s", token, expected, body)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package page_forbidden
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+)
+
+// Dependencies defines the dependencies required for rendering the page
+// shown to signed in users lacking a role or permission.
+type Dependencies struct {
+	// UrlBack is where the back link of the page leads
+	UrlBack string
+	Layout  func(content string) string
+	Logger  *slog.Logger
+}
+
+// PageForbidden renders the forbidden page with a 403 status.
+func PageForbidden(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Forbidden",
+		Layout:     deps.Layout,
+		Content:    shared.MessageContent("Forbidden", "You do not have permission to access this page", deps.UrlBack, "Back"),
+		Logger:     deps.Logger,
+		LogMessage: "failed to write forbidden page response",
+		StatusCode: http.StatusForbidden,
+	})
+}
+
+// PageForbiddenWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func PageForbiddenWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	PageForbidden(w, r, Dependencies{
+		UrlBack: a.LinkRedirectOnSuccess(),
+		Layout:  a.GetLayout(),
+		Logger:  a.GetLogger(),
+	})
+}
@@ -0,0 +1,29 @@
+package page_forbidden
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func TestPageForbidden(t *testing.T) {
+	auth := testutils.NewAuthSharedForTest()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	recorder := httptest.NewRecorder()
+	PageForbiddenWithAuth(recorder, req, auth)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	for _, expected := range []string{"Forbidden", "You do not have permission to access this page", `href="http://localhost/dashboard"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in the page, got %s", expected, body)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package page_login
 import "github.com/dracory/hb"
 
 // LoginPasswordlessContent builds the HTML content for the passwordless login page.
-func LoginPasswordlessContent(enableRegistration bool, urlRegister string) string {
+func LoginPasswordlessContent(enableRegistration bool, enablePasskeys bool, enableRememberMe bool, usePhone bool, providers []LoginProvider, urlRegister string) string {
 	// Elements for the form
 	alertSuccess := hb.NewDiv().
 		Class("alert alert-success").
@@ -31,6 +31,19 @@ func LoginPasswordlessContent(enableRegistration bool, urlRegister string) strin
 		Child(emailLabel).
 		Child(emailInput)
 
+	phoneLabel := hb.NewLabel().
+		Text("Phone Number")
+	phoneInput := hb.NewInput().
+		Type(hb.TYPE_TEL).
+		Class("form-control").
+		Name("phone").
+		Attr("autocomplete", "tel").
+		Placeholder("Enter phone number with country code, e.g. +44")
+	phoneFormGroup := hb.NewDiv().
+		Class("form-group mt-3").
+		Child(phoneLabel).
+		Child(phoneInput)
+
 	buttonLogin := hb.NewButton().
 		Class("ButtonLogin btn btn-lg btn-success btn-block w-100").
 		OnClick("loginFormValidate()").
@@ -61,8 +74,12 @@ func LoginPasswordlessContent(enableRegistration bool, urlRegister string) strin
 		Class("card-body").
 		Children([]hb.TagInterface{
 			alertGroup,
-			emailFormGroup,
+			hb.If(!usePhone, emailFormGroup),
+			hb.If(usePhone, phoneFormGroup),
+			hb.If(enableRememberMe, loginRememberMeFormGroup()),
 			buttonLoginFormGroup,
+			hb.If(enablePasskeys, loginPasskeyFormGroup()),
+			hb.If(len(providers) > 0, loginProvidersFormGroup(providers)),
 		})
 	cardFooter := hb.NewDiv().
 		Class("card-footer").
@@ -86,10 +103,16 @@ func LoginPasswordlessContent(enableRegistration bool, urlRegister string) strin
 }
 
 // LoginPasswordlessScripts builds the JavaScript for the passwordless login page.
-func LoginPasswordlessScripts(urlApiLogin, urlSuccess string) string {
+func LoginPasswordlessScripts(urlApiLogin, urlSuccess string, usePhone bool) string {
+	field, fieldRequired := "email", "Email is required"
+	if usePhone {
+		field, fieldRequired = "phone", "Phone number is required"
+	}
+
 	return `
 		var urlApiLogin = "` + urlApiLogin + `";
 		var urlOnSuccess = "` + urlSuccess + `";
+		var loginField = "` + field + `";
 		/**
 		 * Raises an error message
 		 * @param  {String} error
@@ -118,15 +141,16 @@ func LoginPasswordlessScripts(urlApiLogin, urlSuccess string) string {
 		 * @returns  {Boolean}
 		 */
 		function loginFormValidate() {
-			var email = $.trim($('input[name=email]').val());
+			var value = $.trim($('input[name=' + loginField + ']').val());
 
-			if (email === '') {
-				return loginFormRaiseError('Email is required');
+			if (value === '') {
+				return loginFormRaiseError('` + fieldRequired + `');
 			}
 
 			$('.ButtonLogin .ImgLoading').show();
 
-			var data = {"email": email};
+			var data = {};
+			data[loginField] = value;
 
 			$.post(urlApiLogin, data).then(function (response) {
 				$('.ButtonLogin .ImgLoading').hide();
@@ -135,10 +159,20 @@ func LoginPasswordlessScripts(urlApiLogin, urlSuccess string) string {
 					return loginFormRaiseError(response.message);
 				}
 
+				var params = [];
+				if (response.data && response.data.flow_id) {
+					params.push("flow_id=" + encodeURIComponent(response.data.flow_id));
+				}
+				if ($('input[name=remember]').is(':checked')) {
+					params.push("remember=1");
+				}
+
+				var urlNext = urlOnSuccess + (params.length > 0 ? "?" + params.join("&") : "");
+
 				loginFormRaiseSuccess('Success');
 				$('div.alert-danger').html('').hide();
 				setTimeout(function () {
-					$$.to(urlOnSuccess);
+					$$.to(urlNext);
 				}, 2000);
 				return;
 			}).fail(function (error) {
@@ -154,7 +188,7 @@ func LoginPasswordlessScripts(urlApiLogin, urlSuccess string) string {
 }
 
 // LoginContent builds the HTML content for the standard login page.
-func LoginContent(enableRegistration bool, urlRegister, urlPasswordRestore string) string {
+func LoginContent(enableRegistration bool, enablePasskeys bool, enableRememberMe bool, providers []LoginProvider, urlRegister, urlPasswordRestore string) string {
 	// Elements for the form
 	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
 	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
@@ -218,7 +252,10 @@ func LoginContent(enableRegistration bool, urlRegister, urlPasswordRestore strin
 			alertGroup,
 			emailFormGroup,
 			passwordFormGroup,
+			hb.If(enableRememberMe, loginRememberMeFormGroup()),
 			buttonLoginFormGroup,
+			hb.If(enablePasskeys, loginPasskeyFormGroup()),
+			hb.If(len(providers) > 0, loginProvidersFormGroup(providers)),
 		})
 	cardFooter := hb.NewDiv().Class("card-footer").AddChildren([]hb.TagInterface{
 		buttonForgotPassword,
@@ -240,9 +277,10 @@ func LoginContent(enableRegistration bool, urlRegister, urlPasswordRestore strin
 }
 
 // LoginScripts builds the JavaScript for the standard login page.
-func LoginScripts(urlApiLogin, urlOnSuccess string) string {
+func LoginScripts(urlApiLogin, urlLogin2faVerify, urlOnSuccess string) string {
 	return `
 		var urlApiLogin = "` + urlApiLogin + `";
+		var urlLogin2faVerify = "` + urlLogin2faVerify + `";
 		var urlOnSuccess = "` + urlOnSuccess + `";
 		/**
 		 * Raises an error message
@@ -286,6 +324,10 @@ func LoginScripts(urlApiLogin, urlOnSuccess string) string {
 			$('.ButtonLogin .ImgLoading').show();
 
 			var data = {"email": email, "password": password};
+			var remember = $('input[name=remember]').is(':checked');
+			if (remember) {
+				data.remember = "1";
+			}
 
 			$.post(urlApiLogin, data).then(function (response) {
 				$('.ButtonLogin .ImgLoading').hide();
@@ -294,6 +336,11 @@ func LoginScripts(urlApiLogin, urlOnSuccess string) string {
 					return loginFormRaiseError(response.message);
 				}
 
+				if (response.data && response.data.two_factor_required) {
+					$$.to(urlLogin2faVerify + "?t=" + encodeURIComponent(response.data.two_factor_token) + (remember ? "&remember=1" : ""));
+					return;
+				}
+
 				$$.setAuthToken(response.data.token);
 				$$.setAuthUser(response.data.user);
 				loginFormRaiseSuccess('Success');
@@ -313,3 +360,115 @@ func LoginScripts(urlApiLogin, urlOnSuccess string) string {
 		});
 	`
 }
+
+// loginRememberMeFormGroup builds the "Remember me" checkbox.
+func loginRememberMeFormGroup() hb.TagInterface {
+	rememberInput := hb.NewInput().
+		Class("form-check-input").
+		Type(hb.TYPE_CHECKBOX).
+		Name("remember").
+		ID("remember").
+		Value("1")
+	rememberLabel := hb.NewLabel().
+		Class("form-check-label").
+		For("remember").
+		Text("Remember me")
+
+	return hb.NewDiv().Class("form-check mt-3").
+		Child(rememberInput).
+		Child(rememberLabel)
+}
+
+// LoginProvider is an OpenID Connect provider offered on the login page.
+type LoginProvider struct {
+	Label string
+	URL   string
+}
+
+// loginProvidersFormGroup builds the "Sign in with ..." buttons of the
+// OpenID Connect providers.
+func loginProvidersFormGroup(providers []LoginProvider) hb.TagInterface {
+	formGroup := hb.NewDiv().Class("form-group mb-3")
+	for _, provider := range providers {
+		formGroup.Child(hb.NewHyperlink().
+			Class("ButtonProvider btn btn-outline-primary w-100 mb-2").
+			Children([]hb.TagInterface{
+				hb.NewI().Class("bi bi-box-arrow-in-right").Style("margin-right:8px;margin-top:-2px;"),
+				hb.NewSpan().Text("Sign in with " + provider.Label),
+			}).
+			Href(provider.URL))
+	}
+	return formGroup
+}
+
+// loginPasskeyFormGroup builds the button for signing in with a passkey.
+func loginPasskeyFormGroup() hb.TagInterface {
+	buttonPasskey := hb.NewButton().
+		Class("ButtonPasskey btn btn-lg btn-outline-secondary btn-block w-100").
+		Children([]hb.TagInterface{
+			hb.NewI().Class("bi bi-fingerprint").Style("margin-right:8px;margin-top:-2px;"),
+			hb.NewSpan().Text("Sign in with a passkey"),
+			hb.NewDiv().
+				Class("ImgLoading spinner-border spinner-border-sm").
+				Style("display:none;margin-left:10px;"),
+		}).
+		OnClick("loginPasskey()")
+
+	return hb.NewDiv().Class("form-group mb-3").Child(buttonPasskey)
+}
+
+// LoginPasskeyScripts builds the JavaScript for signing in with a passkey.
+// It relies on the helpers from shared.WebAuthnScripts and on the
+// loginFormRaiseError function of the login page.
+func LoginPasskeyScripts(urlApiWebAuthnLoginBegin, urlApiWebAuthnLoginFinish, urlOnSuccess string) string {
+	return `
+		var urlApiWebAuthnLoginBegin = "` + urlApiWebAuthnLoginBegin + `";
+		var urlApiWebAuthnLoginFinish = "` + urlApiWebAuthnLoginFinish + `";
+		var urlPasskeyOnSuccess = "` + urlOnSuccess + `";
+
+		/**
+		 * Signs in with a passkey stored on the device
+		 * @returns  {Boolean}
+		 */
+		function loginPasskey() {
+			if (!webauthnSupported()) {
+				return loginFormRaiseError('Passkeys are not supported by this browser');
+			}
+
+			$('.ButtonPasskey .ImgLoading').show();
+
+			$.post(urlApiWebAuthnLoginBegin, {}).then(function (response) {
+				if (response.status !== "success") {
+					throw new Error(response.message);
+				}
+
+				var publicKey = webauthnRequestOptions(response.data.public_key);
+				return navigator.credentials.get({publicKey: publicKey});
+			}).then(function (credential) {
+				var data = {
+					"credential_id": webauthnFromBuffer(credential.rawId),
+					"client_data_json": webauthnFromBuffer(credential.response.clientDataJSON),
+					"authenticator_data": webauthnFromBuffer(credential.response.authenticatorData),
+					"signature": webauthnFromBuffer(credential.response.signature)
+				};
+				return $.post(urlApiWebAuthnLoginFinish, data);
+			}).then(function (response) {
+				$('.ButtonPasskey .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return loginFormRaiseError(response.message);
+				}
+
+				$$.setAuthToken(response.data.token);
+				loginFormRaiseSuccess('Success');
+				setTimeout(function () {
+					$$.to(urlPasskeyOnSuccess);
+				}, 2000);
+			}).catch(function (error) {
+				console.log(error);
+				$('.ButtonPasskey .ImgLoading').hide();
+				return loginFormRaiseError(error && error.message ? error.message : 'There was an error. Try again later!');
+			});
+		}
+	`
+}
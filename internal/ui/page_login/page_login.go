@@ -14,20 +14,38 @@ import (
 func PageLogin(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
 	content := ""
 	scripts := ""
+	enablePasskeys := a.GetWebAuthn() != nil
+	enableRememberMe := a.GetRememberMe() != nil && a.GetUseCookies()
+	providers := loginProviders(a)
 	if a.IsPasswordless() {
-		content = LoginPasswordlessContent(a.IsRegistrationEnabled(), links.Register(a.GetEndpoint()))
+		usePhone := a.GetPasswordlessChannel() == types.PasswordlessChannelSms
+		content = LoginPasswordlessContent(a.IsRegistrationEnabled(), enablePasskeys, enableRememberMe, usePhone, providers, links.Register(a.GetEndpoint()))
 		scripts = LoginPasswordlessScripts(
 			links.ApiLogin(a.GetEndpoint()),
 			links.LoginCodeVerify(a.GetEndpoint()),
+			usePhone,
 		)
 	} else {
 		content = LoginContent(
 			a.IsRegistrationEnabled(),
+			enablePasskeys,
+			enableRememberMe,
+			providers,
 			links.Register(a.GetEndpoint()),
 			links.PasswordRestore(a.GetEndpoint()),
 		)
 		scripts = LoginScripts(
 			links.ApiLogin(a.GetEndpoint()),
+			links.Login2faVerify(a.GetEndpoint()),
+			a.LinkRedirectOnSuccess(),
+		)
+	}
+
+	if enablePasskeys {
+		scripts += shared.WebAuthnScripts()
+		scripts += LoginPasskeyScripts(
+			links.ApiWebAuthnLoginBegin(a.GetEndpoint()),
+			links.ApiWebAuthnLoginFinish(a.GetEndpoint()),
 			a.LinkRedirectOnSuccess(),
 		)
 	}
@@ -41,3 +59,25 @@ func PageLogin(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterfa
 		LogMessage: "failed to write login page response",
 	})
 }
+
+// loginProviders lists the configured OpenID Connect providers.
+func loginProviders(a types.AuthSharedInterface) []LoginProvider {
+	config := a.GetOIDC()
+	if config == nil {
+		return nil
+	}
+
+	providers := make([]LoginProvider, 0, len(config.Providers))
+	for _, provider := range config.Providers {
+		label := provider.DisplayName
+		if label == "" {
+			label = provider.Name
+		}
+		providers = append(providers, LoginProvider{
+			Label: label,
+			URL:   links.OIDCLogin(a.GetEndpoint(), provider.Name),
+		})
+	}
+
+	return providers
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
 )
 
 func TestPageLogin_UsernameAndPassword(t *testing.T) {
@@ -30,6 +31,7 @@ func TestPageLogin_UsernameAndPassword(t *testing.T) {
 	expected := []string{
 		`<span>Log in</span>`,
 		`var urlApiLogin = "http://localhost/auth/api/login";`,
+		`var urlLogin2faVerify = "http://localhost/auth/login-2fa-verify";`,
 		`var urlOnSuccess = "http://localhost/dashboard";`,
 	}
 
@@ -71,3 +73,135 @@ func TestPageLogin_Passwordless(t *testing.T) {
 		}
 	}
 }
+
+func TestPageLogin_PasswordlessSms(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	testutils.SetPasswordlessForTest(a, true)
+	a.SetPasswordlessChannel(types.PasswordlessChannelSms)
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	PageLogin(recorder, req, a)
+
+	body := recorder.Body.String()
+
+	expected := []string{
+		`<label>Phone Number</label>`,
+		`name="phone"`,
+		`type="tel"`,
+		`var loginField = "phone";`,
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+
+	if strings.Contains(body, `name="email"`) {
+		t.Errorf("expected no email input, got %s", body)
+	}
+}
+
+func TestPageLogin_Passkeys(t *testing.T) {
+	for _, passwordless := range []bool{false, true} {
+		a := testutils.NewAuthSharedForTest()
+		testutils.SetPasswordlessForTest(a, passwordless)
+
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		PageLogin(recorder, req, a)
+		if strings.Contains(recorder.Body.String(), "Sign in with a passkey") {
+			t.Fatalf("passwordless=%v: expected no passkey button without WebAuthn config", passwordless)
+		}
+
+		a.SetWebAuthn(&types.WebAuthnConfig{RPID: "localhost"})
+
+		recorder = httptest.NewRecorder()
+		PageLogin(recorder, req, a)
+		body := recorder.Body.String()
+
+		expected := []string{
+			`<span>Sign in with a passkey</span>`,
+			`var urlApiWebAuthnLoginBegin = "http://localhost/auth/api/webauthn-login-begin";`,
+			`var urlApiWebAuthnLoginFinish = "http://localhost/auth/api/webauthn-login-finish";`,
+			`function webauthnRequestOptions(publicKey)`,
+		}
+
+		for _, v := range expected {
+			if !strings.Contains(body, v) {
+				t.Errorf("passwordless=%v: handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", passwordless, v, body)
+			}
+		}
+	}
+}
+
+func TestPageLogin_OIDCProviders(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	a.SetOIDC(&types.OIDCConfig{Providers: []types.OIDCProvider{
+		{Name: "google", DisplayName: "Google"},
+		{Name: "corp"},
+	}})
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	PageLogin(recorder, req, a)
+	body := recorder.Body.String()
+
+	expected := []string{
+		`href="http://localhost/auth/login/google"`,
+		`<span>Sign in with Google</span>`,
+		`href="http://localhost/auth/login/corp"`,
+		`<span>Sign in with corp</span>`,
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+}
+
+func TestPageLogin_RememberMe(t *testing.T) {
+	for _, passwordless := range []bool{false, true} {
+		a := testutils.NewAuthSharedForTest()
+		testutils.SetPasswordlessForTest(a, passwordless)
+
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recorder := httptest.NewRecorder()
+		PageLogin(recorder, req, a)
+
+		if strings.Contains(recorder.Body.String(), "Remember me") {
+			t.Fatalf("expected no remember me checkbox without configuration (passwordless=%v)", passwordless)
+		}
+
+		a.SetUseCookies(true)
+		a.SetRememberMe(testutils.NewRememberTokenStore().Config())
+
+		recorder = httptest.NewRecorder()
+		PageLogin(recorder, req, a)
+
+		body := recorder.Body.String()
+		for _, v := range []string{`name="remember"`, "Remember me"} {
+			if !strings.Contains(body, v) {
+				t.Errorf("expected %s on the login page (passwordless=%v), got %s", v, passwordless, body)
+			}
+		}
+	}
+}
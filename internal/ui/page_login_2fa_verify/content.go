@@ -0,0 +1,155 @@
+package page_login_2fa_verify
+
+import "github.com/dracory/hb"
+
+// Login2faVerifyContent builds the HTML for the two-factor verification page.
+// When remember is set, the choice made on the login page is posted along
+// with the code.
+func Login2faVerifyContent(twoFactorToken string, urlBack string, recoveryCodesEnabled bool, remember bool) string {
+	// Elements for the form
+	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
+	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
+	alertGroup := hb.NewDiv().Class("alert-group").AddChild(alertSuccess).AddChild(alertDanger)
+
+	header := hb.NewHeading5().Text("Two-Factor Verification").Style("margin:0px;")
+	infoParagraph := hb.NewParagraph().Class("text-info").Text("Enter the 6-digit code from your authenticator app")
+	tokenInput := hb.NewInput().Type(hb.TYPE_HIDDEN).Name("two_factor_token").Value(twoFactorToken)
+	rememberInput := hb.NewInput().Type(hb.TYPE_HIDDEN).Name("remember").Value("1")
+	codeLabel := hb.NewLabel().Text("Authentication code")
+	codeInput := hb.NewInput().Class("form-control").Name("code").Placeholder("123456").Attr("autocomplete", "one-time-code").Attr("inputmode", "numeric")
+	codeFormGroup := hb.NewDiv().Class("CodeFormGroup form-group mt-3").Child(codeLabel).AddChild(codeInput)
+	recoveryCodeLabel := hb.NewLabel().Text("Recovery code")
+	recoveryCodeInput := hb.NewInput().Class("form-control").Name("recovery_code").Placeholder("XXXX-XXXX-XXXX-XXXX").Attr("autocomplete", "off")
+	recoveryCodeFormGroup := hb.NewDiv().Class("RecoveryCodeFormGroup form-group mt-3").Style("display:none").Child(recoveryCodeLabel).AddChild(recoveryCodeInput)
+	recoveryCodeToggle := hb.NewHyperlink().Class("RecoveryCodeToggle small").Href("#").Text("Lost your device? Use a recovery code").OnClick("return twoFactorRecoveryToggle()")
+	buttonVerify := hb.NewButton().Class("ButtonVerify btn btn-lg btn-success btn-block w-100 text-white").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-shield-check").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Verify"),
+		hb.NewDiv().Class("ImgLoading spinner-border spinner-border-sm text-light").Style("display:none;margin-left:10px;"),
+	}).OnClick("twoFactorFormValidate()")
+	buttonVerifyFormGroup := hb.NewDiv().Class("form-group mt-3 mb-3").AddChild(buttonVerify)
+	buttonBack := hb.NewHyperlink().Class("btn btn-info text-white float-start").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-chevron-left").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Back to login"),
+	}).Href(urlBack)
+
+	// Add elements in a card
+	cardHeader := hb.NewDiv().Class("card-header").Child(header)
+	cardBody := hb.NewDiv().Class("card-body").Children([]hb.TagInterface{
+		alertGroup,
+		infoParagraph,
+		tokenInput,
+		hb.If(remember, rememberInput),
+		codeFormGroup,
+		hb.If(recoveryCodesEnabled, recoveryCodeFormGroup),
+		buttonVerifyFormGroup,
+		hb.If(recoveryCodesEnabled, recoveryCodeToggle),
+	})
+	cardFooter := hb.NewDiv().Class("card-footer").Children([]hb.TagInterface{
+		buttonBack,
+	})
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 360px;").Children([]hb.TagInterface{
+		cardHeader,
+		cardBody,
+		cardFooter,
+	})
+
+	container := hb.NewDiv().Class("container").Child(card)
+
+	return container.ToHTML()
+}
+
+// Login2faVerifyScripts builds the JS for the two-factor verification page.
+func Login2faVerifyScripts(urlApiLogin2faVerify, urlOnSuccess string) string {
+	return `
+		var urlApiLogin2faVerify = "` + urlApiLogin2faVerify + `";
+		var urlOnSuccess = "` + urlOnSuccess + `";
+		/**
+		 * Raises an error message
+		 * @param  {String} error
+		 * @returns  {Boolean}
+		 */
+		function twoFactorFormRaiseError(error) {
+			$('div.alert-success').html('').hide();
+			$('div.alert-danger').html(error).show();
+			setTimeout(function () {
+				$('div.alert-danger').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		function twoFactorFormRaiseSuccess(success) {
+			$('div.alert-danger').html('').hide();
+			$('div.alert-success').html(success).show();
+			setTimeout(function () {
+				$('div.alert-success').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		/**
+		 * Switches between the authenticator code and a recovery code
+		 * @returns  {Boolean}
+		 */
+		function twoFactorRecoveryToggle() {
+			$('.CodeFormGroup').toggle();
+			$('.RecoveryCodeFormGroup').toggle();
+			$('input[name=code]').val('');
+			$('input[name=recovery_code]').val('');
+			return false;
+		}
+
+		/**
+		 * Validate Two-Factor Form
+		 * @returns  {Boolean}
+		 */
+		function twoFactorFormValidate() {
+			var twoFactorToken = $.trim($('input[name=two_factor_token]').val());
+			var code = $.trim($('input[name=code]').val());
+			var recoveryCode = $.trim($('input[name=recovery_code]').val());
+
+			if (twoFactorToken === '') {
+				return twoFactorFormRaiseError('Two-factor session has expired. Please log in again');
+			}
+
+			if (code === '' && recoveryCode === '') {
+				return twoFactorFormRaiseError('Code is required');
+			}
+
+			$('.ButtonVerify .ImgLoading').show();
+
+			var data = {"two_factor_token": twoFactorToken};
+			if ($('input[name=remember]').val() === "1") {
+				data.remember = "1";
+			}
+			if (recoveryCode !== '') {
+				data.recovery_code = recoveryCode;
+			} else {
+				data.code = code;
+			}
+
+			$.post(urlApiLogin2faVerify, data).then(function (response) {
+				$('.ButtonVerify .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return twoFactorFormRaiseError(response.message);
+				}
+
+				$$.setAuthToken(response.data.token);
+				twoFactorFormRaiseSuccess('Verification successful');
+				setTimeout(function () {
+					$$.to(urlOnSuccess);
+				}, 2000);
+				return;
+			}).fail(function (error) {
+				console.log(error);
+				$('.ButtonVerify .ImgLoading').hide();
+				return twoFactorFormRaiseError('There was an error. Try again later!');
+			});
+		}
+		$(function () {
+			$('input[name=code]').focus();
+		});
+	`
+}
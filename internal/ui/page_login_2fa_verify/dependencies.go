@@ -0,0 +1,14 @@
+package page_login_2fa_verify
+
+import "log/slog"
+
+// Dependencies contains the dependencies required to render the two-factor
+// verification page.
+type Dependencies struct {
+	Endpoint          string
+	RedirectOnSuccess string
+
+	Layout func(content string) string
+
+	Logger *slog.Logger
+}
@@ -0,0 +1,34 @@
+package page_login_2fa_verify
+
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// PageLogin2faVerify renders the page where the user enters the code from
+// their authenticator app after the password has been verified.
+func PageLogin2faVerify(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	content := Login2faVerifyContent(
+		req.GetStringTrimmed(r, "t"),
+		links.Login(a.GetEndpoint()),
+		a.GetFuncUserRecoveryCodeConsume() != nil,
+		req.GetStringTrimmed(r, "remember") == "1",
+	)
+	scripts := Login2faVerifyScripts(
+		links.ApiLogin2faVerify(a.GetEndpoint()),
+		a.LinkRedirectOnSuccess(),
+	)
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Two-Factor Verification",
+		Layout:     a.GetLayout(),
+		Content:    content,
+		Scripts:    scripts,
+		Logger:     a.GetLogger(),
+		LogMessage: "failed to write login 2fa verify page response",
+	})
+}
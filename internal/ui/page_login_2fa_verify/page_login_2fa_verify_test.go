@@ -0,0 +1,73 @@
+package page_login_2fa_verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestPageLogin2faVerify(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	req, err := http.NewRequest("GET", "/?t=challenge123", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	PageLogin2faVerify(recorder, req, a)
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	body := recorder.Body.String()
+
+	expected := []string{
+		"Two-Factor Verification",
+		"Enter the 6-digit code from your authenticator app",
+		`value="challenge123"`,
+		"var urlApiLogin2faVerify = \"http://localhost/auth/api/login-2fa-verify\";",
+		"var urlOnSuccess = \"http://localhost/dashboard\";",
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+}
+
+func TestPageLogin2faVerifyRecoveryCodeOption(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	render := func() string {
+		req, err := http.NewRequest("GET", "/?t=challenge123", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder := httptest.NewRecorder()
+		PageLogin2faVerify(recorder, req, a)
+		return recorder.Body.String()
+	}
+
+	if strings.Contains(render(), `name="recovery_code"`) {
+		t.Fatalf("expected no recovery code input when recovery codes are not configured")
+	}
+
+	a.SetFuncUserRecoveryCodeConsume(func(ctx context.Context, userID string, codeHash string, options types.UserAuthOptions) (bool, error) {
+		return false, nil
+	})
+
+	body := render()
+	for _, v := range []string{`name="recovery_code"`, "Use a recovery code"} {
+		if !strings.Contains(body, v) {
+			t.Errorf("expected %q in page, got %s", v, body)
+		}
+	}
+}
@@ -1,18 +1,34 @@
 package page_login_code_verify
 
-import "github.com/dracory/hb"
+import (
+	"strings"
+
+	"github.com/dracory/hb"
+)
 
 // LoginCodeVerifyContent builds the HTML for the login code verification page.
-func LoginCodeVerifyContent(urlBack string) string {
+// When remember is set, the choice made on the login page is posted along
+// with the code. When the code is bound to a login flow, the flow ID is
+// posted as well and the page names the email or the phone number the code
+// was sent to.
+func LoginCodeVerifyContent(urlBack string, remember bool, flowID string, recipient string) string {
 	// Elements for the form
 	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
 	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
 	alertGroup := hb.NewDiv().Class("alert-group").AddChild(alertSuccess).AddChild(alertDanger)
 
 	header := hb.NewHeading5().Text("Login Code Verification").Style("margin:0px;")
-	infoParagraph := hb.NewParagraph().Class("text-info").Text("We sent you a login code to your email. Please check your mailbox")
+	infoText := "We sent you a login code to your email. Please check your mailbox"
+	if strings.HasPrefix(recipient, "+") {
+		infoText = "We sent a login code by text message to " + recipient
+	} else if recipient != "" {
+		infoText = "We sent a login code to " + recipient + ". Please check your mailbox"
+	}
+	infoParagraph := hb.NewParagraph().Class("text-info").Text(infoText)
 	verificationCodeLabel := hb.NewLabel().Text("Verification code")
 	verificationCodeInput := hb.NewInput().Class("form-control").Name("verification_code").Placeholder("Enter verification code")
+	rememberInput := hb.NewInput().Type(hb.TYPE_HIDDEN).Name("remember").Value("1")
+	flowIDInput := hb.NewInput().Type(hb.TYPE_HIDDEN).Name("flow_id").Value(flowID)
 	verificationCodeFormGroup := hb.NewDiv().Class("form-group mt-3").Child(verificationCodeLabel).AddChild(verificationCodeInput)
 	buttonLogin := hb.NewButton().Class("ButtonLogin btn btn-lg btn-success btn-block w-100 text-white").Children([]hb.TagInterface{
 		hb.NewI().Class("bi bi-send").Style("margin-right:8px;margin-top:-2px;"),
@@ -30,6 +46,8 @@ func LoginCodeVerifyContent(urlBack string) string {
 	cardBody := hb.NewDiv().Class("card-body").Children([]hb.TagInterface{
 		alertGroup,
 		infoParagraph,
+		hb.If(remember, rememberInput),
+		hb.If(flowID != "", flowIDInput),
 		verificationCodeFormGroup,
 		buttonLoginFormGroup,
 	})
@@ -90,6 +108,12 @@ func LoginCodeVerifyScripts(urlApiLoginCodeVerify, urlOnSuccess string) string {
 			$('.ButtonLogin .ImgLoading').show();
 
 			var data = {"verification_code": verificationCode};
+			if ($('input[name=remember]').val() === "1") {
+				data.remember = "1";
+			}
+			if ($('input[name=flow_id]').length > 0) {
+				data.flow_id = $('input[name=flow_id]').val();
+			}
 
 			$.post(urlApiLoginCodeVerify, data).then(function (response) {
 				$('.ButtonLogin .ImgLoading').hide();
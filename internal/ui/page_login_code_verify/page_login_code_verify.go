@@ -3,16 +3,28 @@ package page_login_code_verify
 import (
 	"net/http"
 
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/internal/links"
 	"github.com/dracory/auth/internal/ui/shared"
 	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
 )
 
 // PageLoginCodeVerify renders the login code verification page using the
 // provided dependencies and writes the result to the ResponseWriter.
 
 func PageLoginCodeVerify(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
-	content := LoginCodeVerifyContent(links.Login(a.GetEndpoint()))
+	flowID, recipient := "", ""
+	if a.IsLoginCodeBindingEnabled() {
+		flowID = req.GetStringTrimmed(r, "flow_id")
+		// The flow is only read to show where the code was sent; an unknown
+		// or expired flow is reported when the code is posted.
+		if flow, err := core.LoginFlowFind(a, flowID); err == nil && flow != nil {
+			recipient = flow.Recipient
+		}
+	}
+
+	content := LoginCodeVerifyContent(links.Login(a.GetEndpoint()), req.GetStringTrimmed(r, "remember") == "1", flowID, recipient)
 	scripts := LoginCodeVerifyScripts(
 		links.ApiLoginCodeVerify(a.GetEndpoint()),
 		a.LinkRedirectOnSuccess(),
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dracory/auth/internal/core"
 	"github.com/dracory/auth/internal/testutils"
 )
 
@@ -39,3 +40,36 @@ func TestPageLoginCodeVerify(t *testing.T) {
 		}
 	}
 }
+
+func TestPageLoginCodeVerifyShowsFlowEmail(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	testutils.SetPasswordlessForTest(a, true)
+	testutils.SetLoginCodeBindingForTest(a, true)
+
+	store := map[string]string{}
+	a.SetFuncTemporaryKeyGet(func(key string) (string, error) { return store[key], nil })
+	a.SetFuncTemporaryKeySet(func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	})
+
+	flowID, err := core.LoginFlowCreate(a, "user@example.com", "BCDFGHJK", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/?flow_id="+flowID, nil)
+	recorder := httptest.NewRecorder()
+	PageLoginCodeVerify(recorder, req, a)
+
+	body := recorder.Body.String()
+	for _, v := range []string{
+		"We sent a login code to user@example.com. Please check your mailbox",
+		`name="flow_id"`,
+		flowID,
+	} {
+		if !strings.Contains(body, v) {
+			t.Errorf("expected %q in the page, got %s", v, body)
+		}
+	}
+}
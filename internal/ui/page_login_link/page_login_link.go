@@ -0,0 +1,313 @@
+package page_login_link
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dracory/auth/internal/api/api_authenticate_via_username"
+	"github.com/dracory/auth/internal/api/api_login_code_verify"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/hb"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for logging in with a
+// magic link.
+type Dependencies struct {
+	// VerifyLink checks the signature, the expiry time and, when required,
+	// the browser of the link
+	VerifyLink func(r *http.Request, token string) (*core.MagicLink, error)
+
+	// CodeVerify checks and consumes the code of the link, the same way as a
+	// typed code
+	CodeVerify api_login_code_verify.Dependencies
+
+	// AuthenticateEmail issues the auth token of the user with the email
+	AuthenticateEmail func(ctx context.Context, email string) (string, error)
+
+	// ClearBrowserCookie removes the cookie the link was bound to
+	ClearBrowserCookie func(w http.ResponseWriter, r *http.Request)
+
+	UseCookies    bool
+	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	RedirectOnSuccess string
+	UrlLogin          string
+	Layout            func(content string) string
+	Logger            *slog.Logger
+}
+
+// LoginLinkErrorCode categorizes error sources.
+type LoginLinkErrorCode string
+
+const (
+	LoginLinkErrorCodeNone          LoginLinkErrorCode = ""
+	LoginLinkErrorCodeInvalid       LoginLinkErrorCode = "invalid"
+	LoginLinkErrorCodeExpired       LoginLinkErrorCode = "expired"
+	LoginLinkErrorCodeOtherBrowser  LoginLinkErrorCode = "other_browser"
+	LoginLinkErrorCodeLocked        LoginLinkErrorCode = "locked"
+	LoginLinkErrorCodeUserLookup    LoginLinkErrorCode = "user_lookup"
+	LoginLinkErrorCodeTokenStore    LoginLinkErrorCode = "token_store"
+	LoginLinkErrorCodeNotConfigured LoginLinkErrorCode = "not_configured"
+)
+
+// LoginLinkError represents a structured error in the magic link flow.
+type LoginLinkError struct {
+	Code    LoginLinkErrorCode
+	Message string
+	Err     error
+}
+
+func (e *LoginLinkError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// LoginLinkResult represents a successful login.
+type LoginLinkResult struct {
+	Email string
+	Token string
+}
+
+// PageLoginLink logs the user in with the magic link of the login email.
+// Opening the link only asks the user to confirm, as email scanners open
+// links too; the code is redeemed once the confirmation is posted. With
+// cookies, the auth cookie is then set and the user is redirected; with
+// local storage, a page stores the token before redirecting.
+func PageLoginLink(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	if r.Method != http.MethodPost {
+		if lerr := LoginLinkCheck(r, deps); lerr != nil {
+			pageLoginLinkError(w, lerr, deps)
+			return
+		}
+
+		shared.PageRender(w, shared.PageOptions{
+			Title:      "Login",
+			Layout:     deps.Layout,
+			Content:    LoginLinkConfirmContent(r.URL.Path, req.GetStringTrimmed(r, "token")),
+			Logger:     deps.Logger,
+			LogMessage: "failed to write login link page response",
+		})
+		return
+	}
+
+	result, lerr := LoginLink(r.Context(), r, deps)
+	if lerr != nil {
+		pageLoginLinkError(w, lerr, deps)
+		return
+	}
+
+	if deps.ClearBrowserCookie != nil {
+		deps.ClearBrowserCookie(w, r)
+	}
+
+	if deps.UseCookies && deps.SetAuthCookie != nil {
+		deps.SetAuthCookie(w, r, result.Token)
+		http.Redirect(w, r, deps.RedirectOnSuccess, http.StatusSeeOther)
+		return
+	}
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Login",
+		Layout:     deps.Layout,
+		Content:    "",
+		Scripts:    LoginLinkScripts(result.Token, deps.RedirectOnSuccess),
+		Logger:     deps.Logger,
+		LogMessage: "failed to write login link page response",
+	})
+}
+
+// pageLoginLinkError renders the message of the error with a link back to
+// the login page.
+func pageLoginLinkError(w http.ResponseWriter, lerr *LoginLinkError, deps Dependencies) {
+	message := lerr.Message
+	if message == "" {
+		message = "Failed to process request. Please try again later"
+	}
+
+	if lerr.Err != nil && deps.Logger != nil {
+		deps.Logger.Error("login link failed", "code", string(lerr.Code), "error", lerr.Err)
+	}
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Login",
+		Layout:     deps.Layout,
+		Content:    shared.MessageContent("Login", message, deps.UrlLogin, "Back to login"),
+		Logger:     deps.Logger,
+		LogMessage: "failed to write login link page response",
+	})
+}
+
+// LoginLinkConfirmContent builds the HTML asking the user to confirm the
+// login, posting the token of the link back to the page.
+func LoginLinkConfirmContent(action string, token string) string {
+	header := hb.NewHeading5().Text("Login").Style("margin:0px;")
+	tokenInput := hb.NewInput().Type("hidden").Name("token").Value(token)
+	buttonLogin := hb.NewButton().Type("submit").Class("btn btn-lg btn-success btn-block w-100").Text("Log in")
+
+	form := hb.NewForm().Method(http.MethodPost).Action(action).Children([]hb.TagInterface{
+		hb.NewParagraph().Text("Continue to log in to your account."),
+		tokenInput,
+		hb.NewDiv().Class("form-group mt-3").Child(buttonLogin),
+	})
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 360px;").Children([]hb.TagInterface{
+		hb.NewDiv().Class("card-header").Child(header),
+		hb.NewDiv().Class("card-body").Child(form),
+	})
+
+	return hb.NewDiv().Class("container").Child(card).ToHTML()
+}
+
+// LoginLinkScripts builds the JS storing the token in local storage and
+// redirecting to the application.
+func LoginLinkScripts(token string, urlOnSuccess string) string {
+	tokenJSON, _ := json.Marshal(token)
+	urlJSON, _ := json.Marshal(urlOnSuccess)
+	return `
+		$$.setAuthToken(` + string(tokenJSON) + `);
+		$$.to(` + string(urlJSON) + `);
+	`
+}
+
+// PageLoginLinkWithAuth is a convenience wrapper that allows callers to pass
+// a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func PageLoginLinkWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	deps := Dependencies{
+		VerifyLink: func(r *http.Request, token string) (*core.MagicLink, error) {
+			return core.MagicLinkVerify(r, a, token, time.Now())
+		},
+		CodeVerify: api_login_code_verify.DependenciesWithAuth(r, a),
+		AuthenticateEmail: func(ctx context.Context, email string) (string, error) {
+			ctx = core.ContextWithLogin(ctx, types.AuthMethodMagicLink, types.MFALevelSingleFactor, time.Now())
+			result, aerr := api_authenticate_via_username.AuthenticateViaUsername(ctx, email, "", "", api_authenticate_via_username.DependenciesWithAuth(r, a))
+			if aerr != nil {
+				return "", aerr
+			}
+			return result.Token, nil
+		},
+		ClearBrowserCookie: core.MagicLinkClearCookie,
+		UseCookies:         a.GetUseCookies(),
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			a.SetAuthCookie(w, r, token)
+		},
+		RedirectOnSuccess: a.LinkRedirectOnSuccess(),
+		UrlLogin:          links.Login(a.GetEndpoint()),
+		Layout:            a.GetLayout(),
+		Logger:            a.GetLogger(),
+	}
+
+	PageLoginLink(w, r, deps)
+}
+
+// LoginLinkCheck verifies the link without redeeming its code, for the
+// page asking the user to confirm. It does not write HTTP responses.
+func LoginLinkCheck(r *http.Request, deps Dependencies) *LoginLinkError {
+	_, lerr := loginLinkVerify(r, deps)
+	return lerr
+}
+
+// LoginLink encapsulates the business logic of the magic link: it verifies
+// the link, redeems its code and issues the auth token. It does not write
+// HTTP responses.
+func LoginLink(ctx context.Context, r *http.Request, deps Dependencies) (*LoginLinkResult, *LoginLinkError) {
+	link, lerr := loginLinkVerify(r, deps)
+	if lerr != nil {
+		return nil, lerr
+	}
+
+	result, cerr := api_login_code_verify.LoginCodeCheck(link.Code, link.FlowID, deps.CodeVerify)
+	if cerr != nil {
+		if cerr.Code == api_login_code_verify.LoginCodeVerifyErrorCodeCodeConsume {
+			return nil, &LoginLinkError{
+				Code: LoginLinkErrorCodeTokenStore,
+				Err:  cerr.Err,
+			}
+		}
+
+		if cerr.Code == api_login_code_verify.LoginCodeVerifyErrorCodeLocked {
+			return nil, &LoginLinkError{
+				Code:    LoginLinkErrorCodeLocked,
+				Message: cerr.Message,
+			}
+		}
+
+		return nil, &LoginLinkError{
+			Code:    LoginLinkErrorCodeExpired,
+			Message: "Login link has expired or has already been used. Please request a new one",
+		}
+	}
+
+	authToken, errToken := deps.AuthenticateEmail(ctx, result.Recipient)
+	var aerr *api_authenticate_via_username.AuthenticateError
+	if errors.As(errToken, &aerr) && aerr.Code == api_authenticate_via_username.AuthenticateErrorCodeUserLookup {
+		return nil, &LoginLinkError{
+			Code:    LoginLinkErrorCodeUserLookup,
+			Message: aerr.Message,
+			Err:     aerr.Err,
+		}
+	}
+	if errToken != nil {
+		return nil, &LoginLinkError{
+			Code: LoginLinkErrorCodeTokenStore,
+			Err:  errToken,
+		}
+	}
+
+	return &LoginLinkResult{Email: result.Recipient, Token: authToken}, nil
+}
+
+// loginLinkVerify checks the token of the request and returns the link.
+func loginLinkVerify(r *http.Request, deps Dependencies) (*core.MagicLink, *LoginLinkError) {
+	token := req.GetStringTrimmed(r, "token")
+	if token == "" {
+		return nil, &LoginLinkError{
+			Code:    LoginLinkErrorCodeInvalid,
+			Message: "Login link is invalid",
+		}
+	}
+
+	if deps.VerifyLink == nil || deps.AuthenticateEmail == nil {
+		return nil, &LoginLinkError{
+			Code: LoginLinkErrorCodeNotConfigured,
+			Err:  errors.New("login link dependencies are not configured"),
+		}
+	}
+
+	link, errLink := deps.VerifyLink(r, token)
+	switch {
+	case errors.Is(errLink, core.ErrMagicLinkExpired):
+		return nil, &LoginLinkError{
+			Code:    LoginLinkErrorCodeExpired,
+			Message: "Login link has expired. Please request a new one",
+		}
+	case errors.Is(errLink, core.ErrMagicLinkOtherBrowser):
+		return nil, &LoginLinkError{
+			Code:    LoginLinkErrorCodeOtherBrowser,
+			Message: "Please open the login link in the browser you requested it from, or enter the code instead",
+		}
+	case errLink != nil:
+		return nil, &LoginLinkError{
+			Code:    LoginLinkErrorCodeInvalid,
+			Message: "Login link is invalid",
+		}
+	}
+
+	return link, nil
+}
@@ -0,0 +1,103 @@
+package page_login_link
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/api/api_login_code_verify"
+	"github.com/dracory/auth/internal/core"
+)
+
+func newTestDeps(store map[string]string) Dependencies {
+	return Dependencies{
+		VerifyLink: func(r *http.Request, token string) (*core.MagicLink, error) {
+			switch token {
+			case "valid":
+				return &core.MagicLink{Code: "BCDFGHJK"}, nil
+			case "expired":
+				return nil, core.ErrMagicLinkExpired
+			case "other-browser":
+				return nil, core.ErrMagicLinkOtherBrowser
+			}
+			return nil, core.ErrMagicLinkInvalid
+		},
+		CodeVerify: api_login_code_verify.Dependencies{
+			TemporaryKeyGet: func(key string) (string, error) { return store[key], nil },
+			TemporaryKeyDelete: func(key string) error {
+				delete(store, key)
+				return nil
+			},
+		},
+		AuthenticateEmail: func(ctx context.Context, email string) (string, error) {
+			if email != "user@example.com" {
+				return "", errors.New("unexpected email " + email)
+			}
+			return "token-1", nil
+		},
+		RedirectOnSuccess: "http://localhost/dashboard",
+		UrlLogin:          "http://localhost/auth/login",
+		Layout:            func(content string) string { return content },
+	}
+}
+
+func TestLoginLink_Errors(t *testing.T) {
+	deps := newTestDeps(map[string]string{})
+
+	tests := map[string]string{
+		"":              "Login link is invalid",
+		"forged":        "Login link is invalid",
+		"expired":       "Login link has expired. Please request a new one",
+		"other-browser": "Please open the login link in the browser you requested it from, or enter the code instead",
+		"valid":         "Login link has expired or has already been used. Please request a new one",
+	}
+
+	for token, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/auth/login-link?token="+token, nil)
+		if _, lerr := LoginLink(context.Background(), req, deps); lerr == nil || lerr.Message != expected {
+			t.Errorf("token %q: expected %q, got %v", token, expected, lerr)
+		}
+	}
+}
+
+func TestPageLoginLink_OpeningAsksToConfirm(t *testing.T) {
+	store := map[string]string{"BCDFGHJK": "user@example.com"}
+	deps := newTestDeps(store)
+
+	// Email scanners open the link too, so opening it does not log in
+	recorder := httptest.NewRecorder()
+	PageLoginLink(recorder, httptest.NewRequest(http.MethodGet, "/auth/login-link?token=valid", nil), deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `method="POST"`) || !strings.Contains(body, `action="/auth/login-link"`) || !strings.Contains(body, `value="valid"`) {
+		t.Fatalf("expected a form confirming the login, got %s", body)
+	}
+	if _, ok := store["BCDFGHJK"]; !ok {
+		t.Fatal("expected the code of the link to be kept until the login is confirmed")
+	}
+
+	recorder = httptest.NewRecorder()
+	PageLoginLink(recorder, httptest.NewRequest(http.MethodGet, "/auth/login-link?token=expired", nil), deps)
+	if !strings.Contains(recorder.Body.String(), "Login link has expired. Please request a new one") {
+		t.Fatalf("expected an expired link to be rejected when opened, got %s", recorder.Body.String())
+	}
+}
+
+func TestPageLoginLink_LocalStorage(t *testing.T) {
+	store := map[string]string{"BCDFGHJK": "user@example.com"}
+	deps := newTestDeps(store)
+
+	recorder := httptest.NewRecorder()
+	PageLoginLink(recorder, httptest.NewRequest(http.MethodPost, "/auth/login-link?token=valid", nil), deps)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `$$.setAuthToken("token-1");`) || !strings.Contains(body, `$$.to("http://localhost/dashboard");`) {
+		t.Fatalf("expected the token to be stored, got %s", body)
+	}
+	if _, ok := store["BCDFGHJK"]; ok {
+		t.Fatal("expected the code of the link to be consumed")
+	}
+}
@@ -0,0 +1,378 @@
+package page_oidc_callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dracory/auth/internal/api/api_authenticate_via_username"
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/oidc"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// Dependencies defines the dependencies required for completing a sign-in
+// with an OpenID Connect provider.
+type Dependencies struct {
+	// Provider returns the configured provider with the name, nil if none
+	Provider func(name string) *types.OIDCProvider
+
+	TemporaryKeyGet func(key string) (string, error)
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// TemporaryKeyDelete consumes the state. Optional: without it the state
+	// is overwritten with an empty value.
+	TemporaryKeyDelete func(key string) error
+
+	// IdentityFromCode redeems the authorization code and returns the
+	// identity of the verified ID token
+	IdentityFromCode func(ctx context.Context, provider types.OIDCProvider, code string, pending oidc.PendingLogin) (*types.ExternalIdentity, error)
+
+	// UserFindOrCreateByExternalIdentity returns the ID of the user linked
+	// to the identity
+	UserFindOrCreateByExternalIdentity func(ctx context.Context, identity types.ExternalIdentity) (string, error)
+
+	// UserTotpSecretFind returns the base32 TOTP secret of the user, or an
+	// empty string when two-factor authentication is not enabled. Optional.
+	UserTotpSecretFind func(ctx context.Context, userID string) (string, error)
+
+	// TwoFactorChallengeCreate stores a pending two-factor challenge for the
+	// user and returns its token. Required with UserTotpSecretFind.
+	TwoFactorChallengeCreate func(userID string) (string, error)
+
+	// AuthenticateUserID issues the auth token of the user
+	AuthenticateUserID func(ctx context.Context, userID string) (string, error)
+
+	UseCookies    bool
+	SetAuthCookie func(w http.ResponseWriter, r *http.Request, token string)
+
+	RedirectOnSuccess string
+	UrlLogin          string
+	UrlLogin2faVerify string
+	Layout            func(content string) string
+	Logger            *slog.Logger
+}
+
+// OIDCCallbackErrorCode categorizes error sources.
+type OIDCCallbackErrorCode string
+
+const (
+	OIDCCallbackErrorCodeNone            OIDCCallbackErrorCode = ""
+	OIDCCallbackErrorCodeDenied          OIDCCallbackErrorCode = "denied"
+	OIDCCallbackErrorCodeValidation      OIDCCallbackErrorCode = "validation"
+	OIDCCallbackErrorCodeStateExpired    OIDCCallbackErrorCode = "state_expired"
+	OIDCCallbackErrorCodeStateMismatch   OIDCCallbackErrorCode = "state_mismatch"
+	OIDCCallbackErrorCodeIdentity        OIDCCallbackErrorCode = "identity"
+	OIDCCallbackErrorCodeUserLookup      OIDCCallbackErrorCode = "user_lookup"
+	OIDCCallbackErrorCodeSecretLookup    OIDCCallbackErrorCode = "secret_lookup"
+	OIDCCallbackErrorCodeTokenStore      OIDCCallbackErrorCode = "token_store"
+	OIDCCallbackErrorCodeNotConfigured   OIDCCallbackErrorCode = "not_configured"
+	OIDCCallbackErrorCodeUnknownProvider OIDCCallbackErrorCode = "unknown_provider"
+)
+
+// OIDCCallbackError represents a structured error in the callback flow.
+type OIDCCallbackError struct {
+	Code    OIDCCallbackErrorCode
+	Message string
+	Err     error
+}
+
+func (e *OIDCCallbackError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// OIDCCallbackResult represents a successful sign-in. Users with two-factor
+// authentication get a TwoFactorToken instead of a Token.
+type OIDCCallbackResult struct {
+	UserID         string
+	Token          string
+	TwoFactorToken string
+}
+
+// PageOIDCCallback completes the sign-in the provider redirected back from.
+// With cookies, the auth cookie is set and the user is redirected; with
+// local storage, a page stores the token before redirecting. Users with
+// two-factor authentication are redirected to enter their code first.
+func PageOIDCCallback(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, cerr := OIDCCallback(r.Context(), r, r.PathValue("provider"), deps)
+
+	oidc.ClearStateCookie(w, r)
+
+	if cerr != nil {
+		message := cerr.Message
+		if message == "" {
+			message = "Sign in failed. Please try again later"
+		}
+
+		if cerr.Err != nil && deps.Logger != nil {
+			deps.Logger.Error("oidc callback failed", "provider", r.PathValue("provider"), "code", string(cerr.Code), "error", cerr.Err)
+		}
+
+		shared.PageRender(w, shared.PageOptions{
+			Title:      "Login",
+			Layout:     deps.Layout,
+			Content:    shared.MessageContent("Login", message, deps.UrlLogin, "Back to login"),
+			Logger:     deps.Logger,
+			LogMessage: "failed to write oidc callback page response",
+		})
+		return
+	}
+
+	if result.TwoFactorToken != "" {
+		http.Redirect(w, r, deps.UrlLogin2faVerify+"?t="+url.QueryEscape(result.TwoFactorToken), http.StatusSeeOther)
+		return
+	}
+
+	if deps.UseCookies && deps.SetAuthCookie != nil {
+		deps.SetAuthCookie(w, r, result.Token)
+		http.Redirect(w, r, deps.RedirectOnSuccess, http.StatusSeeOther)
+		return
+	}
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Login",
+		Layout:     deps.Layout,
+		Content:    "",
+		Scripts:    OIDCCallbackScripts(result.Token, deps.RedirectOnSuccess),
+		Logger:     deps.Logger,
+		LogMessage: "failed to write oidc callback page response",
+	})
+}
+
+// OIDCCallbackScripts builds the JS storing the token in local storage and
+// redirecting to the application.
+func OIDCCallbackScripts(token string, urlOnSuccess string) string {
+	tokenJSON, _ := json.Marshal(token)
+	urlJSON, _ := json.Marshal(urlOnSuccess)
+	return `
+		$$.setAuthToken(` + string(tokenJSON) + `);
+		$$.to(` + string(urlJSON) + `);
+	`
+}
+
+// PageOIDCCallbackWithAuth is a convenience wrapper that allows callers to
+// pass a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func PageOIDCCallbackWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	options := types.UserAuthOptions{
+		UserIp:    req.GetIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	deps := Dependencies{
+		Provider:        a.GetOIDC().Provider,
+		TemporaryKeyGet: a.GetFuncTemporaryKeyGet(),
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		TemporaryKeyDelete: func(key string) error {
+			return core.TemporaryKeyDelete(a, key)
+		},
+		IdentityFromCode: func(ctx context.Context, provider types.OIDCProvider, code string, pending oidc.PendingLogin) (*types.ExternalIdentity, error) {
+			return IdentityFromCode(ctx, oidc.ClientFor(provider), provider, code, pending)
+		},
+		AuthenticateUserID: func(ctx context.Context, userID string) (string, error) {
+			ctx = core.ContextWithLogin(ctx, types.AuthMethodOIDC, types.MFALevelSingleFactor, time.Now())
+			result, aerr := api_authenticate_via_username.AuthenticateUserID(ctx, userID, api_authenticate_via_username.DependenciesWithAuth(r, a))
+			if aerr != nil {
+				return "", aerr
+			}
+			return result.Token, nil
+		},
+		TwoFactorChallengeCreate: func(userID string) (string, error) {
+			return core.TwoFactorChallengeCreate(a, userID, types.AuthMethodOIDC)
+		},
+		UseCookies: a.GetUseCookies(),
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			a.SetAuthCookie(w, r, token)
+		},
+		RedirectOnSuccess: a.LinkRedirectOnSuccess(),
+		UrlLogin:          links.Login(a.GetEndpoint()),
+		UrlLogin2faVerify: links.Login2faVerify(a.GetEndpoint()),
+		Layout:            a.GetLayout(),
+		Logger:            a.GetLogger(),
+	}
+
+	if config := a.GetOIDC(); config != nil && config.FuncUserFindOrCreateByExternalIdentity != nil {
+		fn := config.FuncUserFindOrCreateByExternalIdentity
+		deps.UserFindOrCreateByExternalIdentity = func(ctx context.Context, identity types.ExternalIdentity) (string, error) {
+			return fn(ctx, identity, options)
+		}
+	}
+
+	if a.GetFuncUserTotpSecretFind() != nil {
+		deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+			return core.TwoFactorSecretFind(ctx, a, userID, options)
+		}
+	}
+
+	PageOIDCCallback(w, r, deps)
+}
+
+// IdentityFromCode redeems the authorization code at the provider and
+// verifies the returned ID token against the pending sign-in.
+func IdentityFromCode(ctx context.Context, client *oidc.Client, provider types.OIDCProvider, code string, pending oidc.PendingLogin) (*types.ExternalIdentity, error) {
+	rawIDToken, err := client.Exchange(ctx, code, pending.CodeVerifier, pending.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := client.VerifyIDToken(ctx, rawIDToken, pending.Nonce, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ExternalIdentity{
+		Provider:      provider.Name,
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+	}, nil
+}
+
+// OIDCCallback encapsulates the business logic of the provider callback: it
+// consumes the pending sign-in, checks it was started by this browser,
+// resolves the identity and issues the auth token, or starts the two-factor
+// challenge of users with a TOTP secret. It does not write HTTP responses.
+func OIDCCallback(ctx context.Context, r *http.Request, providerName string, deps Dependencies) (*OIDCCallbackResult, *OIDCCallbackError) {
+	if errorCode := req.GetStringTrimmed(r, "error"); errorCode != "" {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeDenied,
+			Message: "Sign in was cancelled",
+		}
+	}
+
+	state := req.GetStringTrimmed(r, "state")
+	code := req.GetStringTrimmed(r, "code")
+	if state == "" || code == "" {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeValidation,
+			Message: "Sign in response is invalid. Please try again",
+		}
+	}
+
+	if deps.TemporaryKeyGet == nil || deps.TemporaryKeySet == nil || deps.Provider == nil ||
+		deps.IdentityFromCode == nil || deps.UserFindOrCreateByExternalIdentity == nil || deps.AuthenticateUserID == nil {
+		return nil, &OIDCCallbackError{
+			Code: OIDCCallbackErrorCodeNotConfigured,
+			Err:  errors.New("oidc callback dependencies are not configured"),
+		}
+	}
+
+	stateKey := oidc.StateKeyPrefix + state
+	value, errState := deps.TemporaryKeyGet(stateKey)
+	var pending oidc.PendingLogin
+	if errState != nil || value == "" || json.Unmarshal([]byte(value), &pending) != nil {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeStateExpired,
+			Message: "Sign in has expired. Please try again",
+			Err:     errState,
+		}
+	}
+
+	// The state is single use, whatever the outcome
+	var errConsume error
+	if deps.TemporaryKeyDelete != nil {
+		errConsume = deps.TemporaryKeyDelete(stateKey)
+	} else {
+		errConsume = deps.TemporaryKeySet(stateKey, "", 1)
+	}
+	if errConsume != nil {
+		return nil, &OIDCCallbackError{
+			Code: OIDCCallbackErrorCodeTokenStore,
+			Err:  errConsume,
+		}
+	}
+
+	if !oidc.StateCookieMatches(r, state) || pending.Provider != providerName {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeStateMismatch,
+			Message: "Sign in was started in another browser. Please try again",
+		}
+	}
+
+	provider := deps.Provider(providerName)
+	if provider == nil {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeUnknownProvider,
+			Message: "Unknown provider",
+		}
+	}
+
+	identity, errIdentity := deps.IdentityFromCode(ctx, *provider, code, pending)
+	if errIdentity != nil {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeIdentity,
+			Message: "Sign in could not be verified. Please try again",
+			Err:     errIdentity,
+		}
+	}
+
+	userID, errUser := deps.UserFindOrCreateByExternalIdentity(ctx, *identity)
+	if errUser != nil || userID == "" {
+		return nil, &OIDCCallbackError{
+			Code:    OIDCCallbackErrorCodeUserLookup,
+			Message: "Your account could not be signed in",
+			Err:     errUser,
+		}
+	}
+
+	if deps.UserTotpSecretFind != nil {
+		secret, errSecret := deps.UserTotpSecretFind(ctx, userID)
+		if errSecret != nil {
+			return nil, &OIDCCallbackError{
+				Code: OIDCCallbackErrorCodeSecretLookup,
+				Err:  errSecret,
+			}
+		}
+
+		// The provider only stands in for the password, so the TOTP code is
+		// still required
+		if secret != "" {
+			if deps.TwoFactorChallengeCreate == nil {
+				return nil, &OIDCCallbackError{
+					Code: OIDCCallbackErrorCodeNotConfigured,
+					Err:  errors.New("two-factor challenge is not configured"),
+				}
+			}
+
+			challengeToken, errChallenge := deps.TwoFactorChallengeCreate(userID)
+			if errChallenge != nil {
+				return nil, &OIDCCallbackError{
+					Code: OIDCCallbackErrorCodeTokenStore,
+					Err:  errChallenge,
+				}
+			}
+
+			return &OIDCCallbackResult{UserID: userID, TwoFactorToken: challengeToken}, nil
+		}
+	}
+
+	token, errToken := deps.AuthenticateUserID(ctx, userID)
+	if errToken != nil {
+		return nil, &OIDCCallbackError{
+			Code: OIDCCallbackErrorCodeTokenStore,
+			Err:  errToken,
+		}
+	}
+
+	return &OIDCCallbackResult{UserID: userID, Token: token}, nil
+}
@@ -0,0 +1,291 @@
+package page_oidc_callback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/oidc"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/internal/ui/page_oidc_login"
+	"github.com/dracory/auth/types"
+)
+
+type testEnv struct {
+	provider   *testutils.OIDCProvider
+	store      map[string]string
+	identities []types.ExternalIdentity
+	cookie     string
+	loginDeps  page_oidc_login.Dependencies
+	deps       Dependencies
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	provider, err := testutils.NewOIDCProvider("client-1", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(provider.Server.Close)
+
+	config := &types.OIDCConfig{Providers: []types.OIDCProvider{provider.Config("test")}}
+	env := &testEnv{provider: provider, store: map[string]string{}}
+
+	temporaryKeyGet := func(key string) (string, error) {
+		value, ok := env.store[key]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return value, nil
+	}
+	temporaryKeySet := func(key string, value string, expiresSeconds int) error {
+		env.store[key] = value
+		return nil
+	}
+
+	env.loginDeps = page_oidc_login.Dependencies{
+		Provider:        config.Provider,
+		TemporaryKeySet: temporaryKeySet,
+		RedirectURL: func(r *http.Request, provider types.OIDCProvider) string {
+			return "http://app.test/auth/callback/" + provider.Name
+		},
+		AuthorizationURL: func(ctx context.Context, provider types.OIDCProvider, redirectURL, state, nonce, codeVerifier string) (string, error) {
+			return oidc.NewClient(provider).AuthorizationURL(ctx, redirectURL, state, nonce, codeVerifier)
+		},
+	}
+
+	env.deps = Dependencies{
+		Provider:        config.Provider,
+		TemporaryKeyGet: temporaryKeyGet,
+		TemporaryKeySet: temporaryKeySet,
+		TemporaryKeyDelete: func(key string) error {
+			delete(env.store, key)
+			return nil
+		},
+		IdentityFromCode: func(ctx context.Context, provider types.OIDCProvider, code string, pending oidc.PendingLogin) (*types.ExternalIdentity, error) {
+			return IdentityFromCode(ctx, oidc.NewClient(provider), provider, code, pending)
+		},
+		UserFindOrCreateByExternalIdentity: func(ctx context.Context, identity types.ExternalIdentity) (string, error) {
+			env.identities = append(env.identities, identity)
+			return "user-" + identity.Subject, nil
+		},
+		AuthenticateUserID: func(ctx context.Context, userID string) (string, error) {
+			return "token-for-" + userID, nil
+		},
+		UseCookies: true,
+		SetAuthCookie: func(w http.ResponseWriter, r *http.Request, token string) {
+			http.SetCookie(w, &http.Cookie{Name: "authtoken", Value: token})
+		},
+		RedirectOnSuccess: "/dashboard",
+		UrlLogin:          "/auth/login",
+		Layout:            func(content string) string { return content },
+	}
+
+	return env
+}
+
+// signIn starts the sign-in and lets the provider sign the user in,
+// returning the callback URL the provider redirects back to.
+func (env *testEnv) signIn(t *testing.T) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/login/test", nil)
+	req.SetPathValue("provider", "test")
+	recorder := httptest.NewRecorder()
+	page_oidc_login.PageOIDCLogin(recorder, req, env.loginDeps)
+
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("expected redirect to the provider, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	env.cookie = recorder.Result().Cookies()[0].Value
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	return response.Header.Get("Location")
+}
+
+func (env *testEnv) callback(t *testing.T, callbackURL string, cookie string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	req.SetPathValue("provider", "test")
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: oidc.StateCookieName, Value: cookie})
+	}
+	recorder := httptest.NewRecorder()
+	PageOIDCCallback(recorder, req, env.deps)
+	return recorder
+}
+
+func TestPageOIDCCallback_Success(t *testing.T) {
+	env := newTestEnv(t)
+
+	recorder := env.callback(t, env.signIn(t), env.cookie)
+
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("expected redirect to dashboard, got %d %q: %s", recorder.Code, recorder.Header().Get("Location"), recorder.Body.String())
+	}
+	authCookie := ""
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "authtoken" {
+			authCookie = cookie.Value
+		}
+	}
+	if authCookie != "token-for-user-external-user-1" {
+		t.Fatalf("expected auth cookie, got %v", recorder.Header().Values("Set-Cookie"))
+	}
+
+	if len(env.identities) != 1 {
+		t.Fatalf("expected one identity lookup, got %d", len(env.identities))
+	}
+	identity := env.identities[0]
+	if identity.Provider != "test" || identity.Issuer != env.provider.Issuer() || identity.Subject != "external-user-1" ||
+		identity.Email != "external@test.com" || !identity.EmailVerified {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestPageOIDCCallback_LocalStorage(t *testing.T) {
+	env := newTestEnv(t)
+	env.deps.UseCookies = false
+
+	recorder := env.callback(t, env.signIn(t), env.cookie)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, `$$.setAuthToken("token-for-user-external-user-1");`) {
+		t.Fatalf("expected page storing the token, got %d: %s", recorder.Code, body)
+	}
+}
+
+func TestPageOIDCCallback_TwoFactorUserGetsChallenge(t *testing.T) {
+	env := newTestEnv(t)
+	challengedUserID := ""
+	env.deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+		return "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", nil
+	}
+	env.deps.TwoFactorChallengeCreate = func(userID string) (string, error) {
+		challengedUserID = userID
+		return "challenge-1", nil
+	}
+	env.deps.AuthenticateUserID = func(ctx context.Context, userID string) (string, error) {
+		t.Fatal("expected no auth token before the second factor")
+		return "", nil
+	}
+	env.deps.UrlLogin2faVerify = "/auth/login-2fa-verify"
+
+	recorder := env.callback(t, env.signIn(t), env.cookie)
+
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/auth/login-2fa-verify?t=challenge-1" {
+		t.Fatalf("expected redirect to the two-factor page, got %d %q: %s", recorder.Code, recorder.Header().Get("Location"), recorder.Body.String())
+	}
+	if challengedUserID != "user-external-user-1" {
+		t.Fatalf("expected a challenge for the user, got %q", challengedUserID)
+	}
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "authtoken" {
+			t.Fatalf("expected no auth cookie, got %q", cookie.Value)
+		}
+	}
+}
+
+func TestPageOIDCCallback_UserWithoutSecretSkipsTwoFactor(t *testing.T) {
+	env := newTestEnv(t)
+	env.deps.UserTotpSecretFind = func(ctx context.Context, userID string) (string, error) {
+		return "", nil
+	}
+
+	recorder := env.callback(t, env.signIn(t), env.cookie)
+
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("expected redirect to dashboard, got %d %q: %s", recorder.Code, recorder.Header().Get("Location"), recorder.Body.String())
+	}
+}
+
+func TestPageOIDCCallback_StateIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	callbackURL := env.signIn(t)
+
+	env.callback(t, callbackURL, env.cookie)
+	recorder := env.callback(t, callbackURL, env.cookie)
+
+	if !strings.Contains(recorder.Body.String(), "Sign in has expired. Please try again") {
+		t.Fatalf("expected replayed callback to be rejected, got %s", recorder.Body.String())
+	}
+	if len(env.identities) != 1 {
+		t.Fatalf("expected a single identity lookup, got %d", len(env.identities))
+	}
+}
+
+func TestPageOIDCCallback_DeletesState(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.callback(t, env.signIn(t), env.cookie)
+
+	for key := range env.store {
+		if strings.HasPrefix(key, oidc.StateKeyPrefix) {
+			t.Fatalf("expected the state to be deleted, found %q", key)
+		}
+	}
+}
+
+func TestPageOIDCCallback_FailsWhenStateCannotBeConsumed(t *testing.T) {
+	env := newTestEnv(t)
+	env.deps.TemporaryKeyDelete = func(key string) error {
+		return errors.New("store unavailable")
+	}
+
+	recorder := env.callback(t, env.signIn(t), env.cookie)
+
+	if recorder.Code == http.StatusSeeOther || !strings.Contains(recorder.Body.String(), "Sign in failed. Please try again later") {
+		t.Fatalf("expected the sign-in to fail, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if len(env.identities) != 0 {
+		t.Fatal("expected no identity lookup")
+	}
+}
+
+func TestPageOIDCCallback_RequiresStateCookie(t *testing.T) {
+	env := newTestEnv(t)
+
+	recorder := env.callback(t, env.signIn(t), "")
+
+	if !strings.Contains(recorder.Body.String(), "Sign in was started in another browser. Please try again") {
+		t.Fatalf("expected callback without state cookie to be rejected, got %s", recorder.Body.String())
+	}
+	if len(env.identities) != 0 {
+		t.Fatal("expected no identity lookup")
+	}
+}
+
+func TestPageOIDCCallback_RejectsWrongNonce(t *testing.T) {
+	env := newTestEnv(t)
+	env.provider.TokenClaims = map[string]any{"nonce": "attacker-nonce"}
+
+	recorder := env.callback(t, env.signIn(t), env.cookie)
+
+	if !strings.Contains(recorder.Body.String(), "Sign in could not be verified. Please try again") {
+		t.Fatalf("expected ID token with a wrong nonce to be rejected, got %s", recorder.Body.String())
+	}
+}
+
+func TestPageOIDCCallback_ProviderError(t *testing.T) {
+	env := newTestEnv(t)
+
+	recorder := env.callback(t, "http://app.test/auth/callback/test?"+url.Values{"error": {"access_denied"}}.Encode(), "")
+
+	if !strings.Contains(recorder.Body.String(), "Sign in was cancelled") {
+		t.Fatalf("expected cancelled message, got %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,186 @@
+package page_oidc_login
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/oidc"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+)
+
+// Dependencies defines the dependencies required for redirecting the user
+// to an OpenID Connect provider.
+type Dependencies struct {
+	// Provider returns the configured provider with the name, nil if none
+	Provider func(name string) *types.OIDCProvider
+
+	TemporaryKeySet func(key string, value string, expiresSeconds int) error
+
+	// RedirectURL returns the callback URL of the provider
+	RedirectURL func(r *http.Request, provider types.OIDCProvider) string
+
+	// AuthorizationURL returns the URL of the provider to sign in at
+	AuthorizationURL func(ctx context.Context, provider types.OIDCProvider, redirectURL, state, nonce, codeVerifier string) (string, error)
+
+	// NewRandomString generates states, nonces and code verifiers.
+	// Defaults to oidc.NewRandomString when nil.
+	NewRandomString func() (string, error)
+
+	UrlLogin string
+	Layout   func(content string) string
+	Logger   *slog.Logger
+}
+
+// OIDCLoginErrorCode categorizes error sources.
+type OIDCLoginErrorCode string
+
+const (
+	OIDCLoginErrorCodeNone            OIDCLoginErrorCode = ""
+	OIDCLoginErrorCodeUnknownProvider OIDCLoginErrorCode = "unknown_provider"
+	OIDCLoginErrorCodeStateStore      OIDCLoginErrorCode = "state_store"
+	OIDCLoginErrorCodeProvider        OIDCLoginErrorCode = "provider"
+)
+
+// OIDCLoginError represents a structured error when starting the sign-in.
+type OIDCLoginError struct {
+	Code    OIDCLoginErrorCode
+	Message string
+	Err     error
+}
+
+func (e *OIDCLoginError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+// OIDCLoginResult holds the URL to redirect the user to.
+type OIDCLoginResult struct {
+	AuthorizationURL string
+	State            string
+}
+
+// PageOIDCLogin redirects the user to the provider named in the route. On
+// failure it renders an error page.
+func PageOIDCLogin(w http.ResponseWriter, r *http.Request, deps Dependencies) {
+	result, lerr := OIDCLogin(r.Context(), r, r.PathValue("provider"), deps)
+	if lerr != nil {
+		if lerr.Code == OIDCLoginErrorCodeUnknownProvider {
+			http.Redirect(w, r, deps.UrlLogin, http.StatusTemporaryRedirect)
+			return
+		}
+
+		if deps.Logger != nil {
+			deps.Logger.Error("oidc login failed", "provider", r.PathValue("provider"), "error", lerr.Err)
+		}
+
+		shared.PageRender(w, shared.PageOptions{
+			Title:      "Login",
+			Layout:     deps.Layout,
+			Content:    shared.MessageContent("Login", "Sign in is not available right now. Please try again later", deps.UrlLogin, "Back to login"),
+			Logger:     deps.Logger,
+			LogMessage: "failed to write oidc login page response",
+		})
+		return
+	}
+
+	oidc.SetStateCookie(w, r, result.State)
+	http.Redirect(w, r, result.AuthorizationURL, http.StatusFound)
+}
+
+// PageOIDCLoginWithAuth is a convenience wrapper that allows callers to pass
+// a types.AuthSharedInterface (such as authImplementation) instead of
+// manually wiring Dependencies.
+func PageOIDCLoginWithAuth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	deps := Dependencies{
+		Provider:        a.GetOIDC().Provider,
+		TemporaryKeySet: a.GetFuncTemporaryKeySet(),
+		RedirectURL: func(r *http.Request, provider types.OIDCProvider) string {
+			if provider.RedirectURL != "" {
+				return provider.RedirectURL
+			}
+			return oidc.RedirectURL(r, links.OIDCCallback(a.GetEndpoint(), provider.Name))
+		},
+		AuthorizationURL: func(ctx context.Context, provider types.OIDCProvider, redirectURL, state, nonce, codeVerifier string) (string, error) {
+			return oidc.ClientFor(provider).AuthorizationURL(ctx, redirectURL, state, nonce, codeVerifier)
+		},
+		UrlLogin: links.Login(a.GetEndpoint()),
+		Layout:   a.GetLayout(),
+		Logger:   a.GetLogger(),
+	}
+
+	PageOIDCLogin(w, r, deps)
+}
+
+// OIDCLogin creates the state, nonce and PKCE code verifier of a new
+// sign-in, remembers them in the temporary key store and builds the
+// authorization URL of the provider. It does not write HTTP responses.
+func OIDCLogin(ctx context.Context, r *http.Request, providerName string, deps Dependencies) (*OIDCLoginResult, *OIDCLoginError) {
+	var provider *types.OIDCProvider
+	if deps.Provider != nil {
+		provider = deps.Provider(providerName)
+	}
+	if provider == nil {
+		return nil, &OIDCLoginError{
+			Code:    OIDCLoginErrorCodeUnknownProvider,
+			Message: "Unknown provider",
+		}
+	}
+
+	if deps.TemporaryKeySet == nil || deps.RedirectURL == nil || deps.AuthorizationURL == nil {
+		return nil, &OIDCLoginError{
+			Code: OIDCLoginErrorCodeStateStore,
+			Err:  errors.New("oidc login dependencies are not configured"),
+		}
+	}
+
+	newRandomString := oidc.NewRandomString
+	if deps.NewRandomString != nil {
+		newRandomString = deps.NewRandomString
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		value, err := newRandomString()
+		if err != nil {
+			return nil, &OIDCLoginError{Code: OIDCLoginErrorCodeStateStore, Err: err}
+		}
+		values[i] = value
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	redirectURL := deps.RedirectURL(r, *provider)
+
+	pending, err := json.Marshal(oidc.PendingLogin{
+		Provider:     provider.Name,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		return nil, &OIDCLoginError{Code: OIDCLoginErrorCodeStateStore, Err: err}
+	}
+
+	if err := deps.TemporaryKeySet(oidc.StateKeyPrefix+state, string(pending), oidc.StateExpiresSeconds); err != nil {
+		return nil, &OIDCLoginError{Code: OIDCLoginErrorCodeStateStore, Err: err}
+	}
+
+	authorizationURL, err := deps.AuthorizationURL(ctx, *provider, redirectURL, state, nonce, codeVerifier)
+	if err != nil {
+		return nil, &OIDCLoginError{Code: OIDCLoginErrorCodeProvider, Err: err}
+	}
+
+	return &OIDCLoginResult{AuthorizationURL: authorizationURL, State: state}, nil
+}
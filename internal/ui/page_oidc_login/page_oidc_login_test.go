@@ -0,0 +1,76 @@
+package page_oidc_login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/oidc"
+	"github.com/dracory/auth/types"
+)
+
+func newTestDeps(store map[string]string) Dependencies {
+	providers := &types.OIDCConfig{Providers: []types.OIDCProvider{{Name: "test", Issuer: "https://issuer.test", ClientID: "client-1"}}}
+	return Dependencies{
+		Provider: providers.Provider,
+		TemporaryKeySet: func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		},
+		RedirectURL: func(r *http.Request, provider types.OIDCProvider) string {
+			return "https://app.test/auth/callback/" + provider.Name
+		},
+		AuthorizationURL: func(ctx context.Context, provider types.OIDCProvider, redirectURL, state, nonce, codeVerifier string) (string, error) {
+			return provider.Issuer + "/authorize?state=" + state, nil
+		},
+		UrlLogin: "/auth/login",
+		Layout:   func(content string) string { return content },
+	}
+}
+
+func TestPageOIDCLogin_RedirectsToProvider(t *testing.T) {
+	store := map[string]string{}
+	req := httptest.NewRequest(http.MethodGet, "/auth/login/test", nil)
+	req.SetPathValue("provider", "test")
+	recorder := httptest.NewRecorder()
+
+	PageOIDCLogin(recorder, req, newTestDeps(store))
+
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, recorder.Code)
+	}
+
+	location := recorder.Header().Get("Location")
+	if !strings.HasPrefix(location, "https://issuer.test/authorize?state=") {
+		t.Fatalf("unexpected redirect %q", location)
+	}
+	state := strings.TrimPrefix(location, "https://issuer.test/authorize?state=")
+
+	var pending oidc.PendingLogin
+	if err := json.Unmarshal([]byte(store[oidc.StateKeyPrefix+state]), &pending); err != nil {
+		t.Fatalf("expected pending login to be stored under the state, got %v", store)
+	}
+	if pending.Provider != "test" || pending.Nonce == "" || pending.CodeVerifier == "" || pending.RedirectURL != "https://app.test/auth/callback/test" {
+		t.Fatalf("unexpected pending login %+v", pending)
+	}
+
+	cookie := recorder.Result().Cookies()
+	if len(cookie) != 1 || cookie[0].Name != oidc.StateCookieName || cookie[0].Value != state || !cookie[0].HttpOnly {
+		t.Fatalf("expected HttpOnly state cookie, got %+v", cookie)
+	}
+}
+
+func TestPageOIDCLogin_UnknownProviderRedirectsToLogin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/auth/login/other", nil)
+	req.SetPathValue("provider", "other")
+	recorder := httptest.NewRecorder()
+
+	PageOIDCLogin(recorder, req, newTestDeps(map[string]string{}))
+
+	if recorder.Code != http.StatusTemporaryRedirect || recorder.Header().Get("Location") != "/auth/login" {
+		t.Fatalf("expected redirect to login, got %d %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+}
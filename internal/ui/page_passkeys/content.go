@@ -0,0 +1,115 @@
+package page_passkeys
+
+import "github.com/dracory/hb"
+
+// PasskeysContent builds the HTML for the passkey management page.
+func PasskeysContent(urlBack string) string {
+	// Elements for the form
+	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
+	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
+	alertGroup := hb.NewDiv().Class("alert-group").AddChild(alertSuccess).AddChild(alertDanger)
+
+	header := hb.NewHeading5().Text("Passkeys").Style("margin:0px;")
+	infoParagraph := hb.NewParagraph().Class("text-info").Text("Passkeys let you sign in with your fingerprint, face, screen lock or security key instead of a password")
+	buttonAdd := hb.NewButton().Class("ButtonAdd btn btn-lg btn-success btn-block w-100 text-white").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-fingerprint").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Add a passkey"),
+		hb.NewDiv().Class("ImgLoading spinner-border spinner-border-sm text-light").Style("display:none;margin-left:10px;"),
+	}).OnClick("passkeyRegister()")
+	buttonAddFormGroup := hb.NewDiv().Class("form-group mt-3 mb-3").AddChild(buttonAdd)
+	buttonBack := hb.NewHyperlink().Class("btn btn-info text-white float-start").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-chevron-left").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Back"),
+	}).Href(urlBack)
+
+	// Add elements in a card
+	cardHeader := hb.NewDiv().Class("card-header").Child(header)
+	cardBody := hb.NewDiv().Class("card-body").Children([]hb.TagInterface{
+		alertGroup,
+		infoParagraph,
+		buttonAddFormGroup,
+	})
+	cardFooter := hb.NewDiv().Class("card-footer").Children([]hb.TagInterface{
+		buttonBack,
+	})
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 360px;").Children([]hb.TagInterface{
+		cardHeader,
+		cardBody,
+		cardFooter,
+	})
+
+	container := hb.NewDiv().Class("container").Child(card)
+
+	return container.ToHTML()
+}
+
+// PasskeysScripts builds the JS for the passkey management page. It relies
+// on the helpers from shared.WebAuthnScripts.
+func PasskeysScripts(urlApiWebAuthnRegisterBegin, urlApiWebAuthnRegisterFinish string) string {
+	return `
+		var urlApiWebAuthnRegisterBegin = "` + urlApiWebAuthnRegisterBegin + `";
+		var urlApiWebAuthnRegisterFinish = "` + urlApiWebAuthnRegisterFinish + `";
+		/**
+		 * Raises an error message
+		 * @param  {String} error
+		 * @returns  {Boolean}
+		 */
+		function passkeyRaiseError(error) {
+			$('div.alert-success').html('').hide();
+			$('div.alert-danger').html(error).show();
+			setTimeout(function () {
+				$('div.alert-danger').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		function passkeyRaiseSuccess(success) {
+			$('div.alert-danger').html('').hide();
+			$('div.alert-success').html(success).show();
+			setTimeout(function () {
+				$('div.alert-success').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		/**
+		 * Creates a passkey on this device and registers it with the account
+		 * @returns  {Boolean}
+		 */
+		function passkeyRegister() {
+			if (!webauthnSupported()) {
+				return passkeyRaiseError('Passkeys are not supported by this browser');
+			}
+
+			$('.ButtonAdd .ImgLoading').show();
+
+			$.post(urlApiWebAuthnRegisterBegin, {}).then(function (response) {
+				if (response.status !== "success") {
+					throw new Error(response.message);
+				}
+
+				var publicKey = webauthnCreationOptions(response.data.public_key);
+				return navigator.credentials.create({publicKey: publicKey});
+			}).then(function (credential) {
+				var data = {
+					"client_data_json": webauthnFromBuffer(credential.response.clientDataJSON),
+					"attestation_object": webauthnFromBuffer(credential.response.attestationObject)
+				};
+				return $.post(urlApiWebAuthnRegisterFinish, data);
+			}).then(function (response) {
+				$('.ButtonAdd .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return passkeyRaiseError(response.message);
+				}
+
+				return passkeyRaiseSuccess('Passkey added');
+			}).catch(function (error) {
+				console.log(error);
+				$('.ButtonAdd .ImgLoading').hide();
+				return passkeyRaiseError(error && error.message ? error.message : 'There was an error. Try again later!');
+			});
+		}
+	`
+}
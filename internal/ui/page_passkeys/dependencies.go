@@ -0,0 +1,14 @@
+package page_passkeys
+
+import "log/slog"
+
+// Dependencies contains the dependencies required to render the passkey
+// management page.
+type Dependencies struct {
+	Endpoint          string
+	RedirectOnSuccess string
+
+	Layout func(content string) string
+
+	Logger *slog.Logger
+}
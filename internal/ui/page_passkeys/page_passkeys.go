@@ -0,0 +1,28 @@
+package page_passkeys
+
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+)
+
+// PagePasskeys renders the page where an authenticated user registers a
+// passkey for the current device.
+func PagePasskeys(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	content := PasskeysContent(a.LinkRedirectOnSuccess())
+	scripts := shared.WebAuthnScripts() + PasskeysScripts(
+		links.ApiWebAuthnRegisterBegin(a.GetEndpoint()),
+		links.ApiWebAuthnRegisterFinish(a.GetEndpoint()),
+	)
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Passkeys",
+		Layout:     a.GetLayout(),
+		Content:    content,
+		Scripts:    scripts,
+		Logger:     a.GetLogger(),
+		LogMessage: "failed to write passkeys page response",
+	})
+}
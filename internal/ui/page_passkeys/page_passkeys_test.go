@@ -0,0 +1,41 @@
+package page_passkeys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func TestPagePasskeys(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	PagePasskeys(recorder, req, a)
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	body := recorder.Body.String()
+
+	expected := []string{
+		"Add a passkey",
+		"var urlApiWebAuthnRegisterBegin = \"http://localhost/auth/api/webauthn-register-begin\";",
+		"var urlApiWebAuthnRegisterFinish = \"http://localhost/auth/api/webauthn-register-finish\";",
+		"function webauthnCreationOptions(publicKey)",
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package page_reauth
+
+import "github.com/dracory/hb"
+
+// ReauthContent builds the HTML for the re-authentication page. With
+// passwordless logins the user asks for a code sent to their email address,
+// or phone number with usePhone, then enters it. Otherwise they enter their
+// credentials, and the code of their authenticator app with totpRequired.
+func ReauthContent(passwordless bool, usePhone bool, totpRequired bool, urlBack string) string {
+	// Elements for the form
+	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
+	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
+	alertGroup := hb.NewDiv().Class("alert-group").AddChild(alertSuccess).AddChild(alertDanger)
+
+	header := hb.NewHeading5().Text("Confirm it's you").Style("margin:0px;")
+	infoParagraph := hb.NewParagraph().Class("text-info").Text("For your security, please sign in again to continue")
+
+	emailLabel := hb.NewLabel().Text("E-mail Address")
+	emailInput := hb.NewInput().Class("form-control").Name("email").Placeholder("Enter e-mail address").Attr("autocomplete", "username")
+	emailFormGroup := hb.NewDiv().Class("form-group mt-3").Child(emailLabel).AddChild(emailInput)
+
+	phoneLabel := hb.NewLabel().Text("Phone Number")
+	phoneInput := hb.NewInput().Type(hb.TYPE_TEL).Class("form-control").Name("phone").Attr("autocomplete", "tel").Placeholder("Enter phone number with country code, e.g. +44")
+	phoneFormGroup := hb.NewDiv().Class("form-group mt-3").Child(phoneLabel).AddChild(phoneInput)
+
+	passwordLabel := hb.NewLabel().Text("Password")
+	passwordInput := hb.NewInput().Type(hb.TYPE_PASSWORD).Class("form-control").Name("password").Placeholder("Enter password").Attr("autocomplete", "current-password")
+	passwordFormGroup := hb.NewDiv().Class("form-group mt-3").Child(passwordLabel).AddChild(passwordInput)
+
+	codeLabel := hb.NewLabel().Text("Authentication code")
+	codeInput := hb.NewInput().Class("form-control").Name("code").Placeholder("123456").Attr("autocomplete", "one-time-code").Attr("inputmode", "numeric")
+	codeFormGroup := hb.NewDiv().Class("form-group mt-3").Child(codeLabel).AddChild(codeInput)
+
+	verificationCodeLabel := hb.NewLabel().Text("Verification code")
+	verificationCodeInput := hb.NewInput().Class("form-control").Name("verification_code").Placeholder("Enter the code you received").Attr("autocomplete", "one-time-code")
+	verificationCodeFormGroup := hb.NewDiv().Class("VerificationCodeFormGroup form-group mt-3").Style("display:none").Child(verificationCodeLabel).AddChild(verificationCodeInput)
+
+	buttonSend := hb.NewButton().Class("ButtonSend btn btn-lg btn-success btn-block w-100 text-white").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-send").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Send me a code"),
+		hb.NewDiv().Class("ImgLoading spinner-border spinner-border-sm text-light").Style("display:none;margin-left:10px;"),
+	}).OnClick("reauthCodeSend()")
+	buttonSendFormGroup := hb.NewDiv().Class("ButtonSendFormGroup form-group mt-3 mb-3").AddChild(buttonSend)
+
+	buttonConfirm := hb.NewButton().Class("ButtonConfirm btn btn-lg btn-success btn-block w-100 text-white").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-shield-check").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Confirm"),
+		hb.NewDiv().Class("ImgLoading spinner-border spinner-border-sm text-light").Style("display:none;margin-left:10px;"),
+	}).OnClick("reauthFormValidate()")
+	buttonConfirmFormGroup := hb.NewDiv().Class("ButtonConfirmFormGroup form-group mt-3 mb-3").AddChild(buttonConfirm)
+	if passwordless {
+		buttonConfirmFormGroup.Style("display:none")
+	}
+
+	buttonBack := hb.NewHyperlink().Class("btn btn-info text-white float-start").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-chevron-left").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Cancel"),
+	}).Href(urlBack)
+
+	// Add elements in a card
+	cardHeader := hb.NewDiv().Class("card-header").Child(header)
+	cardBody := hb.NewDiv().Class("card-body").Children([]hb.TagInterface{
+		alertGroup,
+		infoParagraph,
+		hb.If(!usePhone || !passwordless, emailFormGroup),
+		hb.If(usePhone && passwordless, phoneFormGroup),
+		hb.If(!passwordless, passwordFormGroup),
+		hb.If(!passwordless && totpRequired, codeFormGroup),
+		hb.If(passwordless, verificationCodeFormGroup),
+		hb.If(passwordless, buttonSendFormGroup),
+		buttonConfirmFormGroup,
+	})
+	cardFooter := hb.NewDiv().Class("card-footer").Children([]hb.TagInterface{
+		buttonBack,
+	})
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 360px;").Children([]hb.TagInterface{
+		cardHeader,
+		cardBody,
+		cardFooter,
+	})
+
+	container := hb.NewDiv().Class("container").Child(card)
+
+	return container.ToHTML()
+}
+
+// ReauthScripts builds the JS for the re-authentication page. The URLs are
+// passed as JSON encoded strings, as urlOnSuccess comes from the request.
+func ReauthScripts(urlApiReauth, urlOnSuccess string) string {
+	return `
+		var urlApiReauth = ` + urlApiReauth + `;
+		var urlOnSuccess = ` + urlOnSuccess + `;
+		/**
+		 * Raises an error message
+		 * @param  {String} error
+		 * @returns  {Boolean}
+		 */
+		function reauthFormRaiseError(error) {
+			$('div.alert-success').html('').hide();
+			$('div.alert-danger').html(error).show();
+			setTimeout(function () {
+				$('div.alert-danger').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		function reauthFormRaiseSuccess(success) {
+			$('div.alert-danger').html('').hide();
+			$('div.alert-success').html(success).show();
+			setTimeout(function () {
+				$('div.alert-success').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		/**
+		 * Collects the filled in fields of the form
+		 * @returns  {Object}
+		 */
+		function reauthFormData() {
+			var data = {};
+			$.each(['email', 'phone', 'password', 'code', 'verification_code'], function (i, name) {
+				var input = $('input[name=' + name + ']');
+				if (input.length > 0 && $.trim(input.val()) !== '') {
+					data[name] = $.trim(input.val());
+				}
+			});
+			return data;
+		}
+
+		/**
+		 * Sends a verification code to the user
+		 * @returns  {Boolean}
+		 */
+		function reauthCodeSend() {
+			var data = reauthFormData();
+			delete data.verification_code;
+
+			$('.ButtonSend .ImgLoading').show();
+
+			$.post(urlApiReauth, data).then(function (response) {
+				$('.ButtonSend .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return reauthFormRaiseError(response.message);
+				}
+
+				reauthFormRaiseSuccess(response.message);
+				$('.ButtonSendFormGroup').hide();
+				$('.VerificationCodeFormGroup').show();
+				$('.ButtonConfirmFormGroup').show();
+				$('input[name=verification_code]').focus();
+				return;
+			}).fail(function (error) {
+				console.log(error);
+				$('.ButtonSend .ImgLoading').hide();
+				return reauthFormRaiseError('There was an error. Try again later!');
+			});
+		}
+
+		/**
+		 * Validate Re-authentication Form
+		 * @returns  {Boolean}
+		 */
+		function reauthFormValidate() {
+			$('.ButtonConfirm .ImgLoading').show();
+
+			$.post(urlApiReauth, reauthFormData()).then(function (response) {
+				$('.ButtonConfirm .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return reauthFormRaiseError(response.message);
+				}
+
+				$$.setAuthToken(response.data.token);
+				reauthFormRaiseSuccess('Confirmed');
+				setTimeout(function () {
+					$$.to(urlOnSuccess);
+				}, 1000);
+				return;
+			}).fail(function (error) {
+				console.log(error);
+				$('.ButtonConfirm .ImgLoading').hide();
+				return reauthFormRaiseError('There was an error. Try again later!');
+			});
+		}
+		$(function () {
+			$('.card-body input').first().focus();
+		});
+	`
+}
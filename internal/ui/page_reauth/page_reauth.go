@@ -0,0 +1,68 @@
+package page_reauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+// PageReauth renders the page where a signed in user confirms their
+// identity again before a sensitive action, then returns to the URL in the
+// "back" query parameter.
+func PageReauth(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	totpRequired := false
+	if !a.IsPasswordless() {
+		secret, err := core.TwoFactorSecretFind(r.Context(), a, a.GetCurrentUserID(r), types.UserAuthOptions{
+			UserIp:    req.GetIP(r),
+			UserAgent: r.UserAgent(),
+		})
+		if err != nil && a.GetLogger() != nil {
+			a.GetLogger().Error("failed to look up two-factor secret", "error", err)
+		}
+		// Ask for the code when unsure; the API tells whether it is needed
+		totpRequired = err != nil || secret != ""
+	}
+
+	content := ReauthContent(
+		a.IsPasswordless(),
+		a.GetPasswordlessChannel() == types.PasswordlessChannelSms,
+		totpRequired,
+		a.LinkRedirectOnSuccess(),
+	)
+	scripts := ReauthScripts(
+		jsString(links.ApiReauth(a.GetEndpoint())),
+		jsString(ReauthBack(req.GetStringTrimmed(r, "back"), a.LinkRedirectOnSuccess())),
+	)
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Confirm it's you",
+		Layout:     a.GetLayout(),
+		Content:    content,
+		Scripts:    scripts,
+		Logger:     a.GetLogger(),
+		LogMessage: "failed to write reauth page response",
+	})
+}
+
+// ReauthBack returns the URL to return to after the re-authentication. Only
+// paths on the same site are followed, so the page cannot be used to send
+// users elsewhere; anything else returns the fallback.
+func ReauthBack(back string, fallback string) string {
+	if !strings.HasPrefix(back, "/") || strings.HasPrefix(back, "//") || strings.HasPrefix(back, "/\\") {
+		return fallback
+	}
+	return back
+}
+
+// jsString encodes the value as a JavaScript string literal, safe to embed
+// in a script element.
+func jsString(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
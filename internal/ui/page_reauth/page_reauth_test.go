@@ -0,0 +1,93 @@
+package page_reauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestPageReauth(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	render := func(target string) string {
+		recorder := httptest.NewRecorder()
+		PageReauth(recorder, httptest.NewRequest(http.MethodGet, target, nil), a)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", recorder.Code, http.StatusOK)
+		}
+		return recorder.Body.String()
+	}
+
+	body := render("/auth/reauth?back=%2Fsettings%2Femail")
+	expected := []string{
+		"Confirm it&#39;s you",
+		`name="email"`,
+		`name="password"`,
+		`var urlApiReauth = "http://localhost/auth/api/reauth";`,
+		`var urlOnSuccess = "/settings/email";`,
+	}
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+	if strings.Contains(body, `name="code"`) {
+		t.Fatalf("expected no authenticator code input without two-factor authentication")
+	}
+
+	a.SetFuncUserTotpSecretFind(func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error) {
+		return "SECRET", nil
+	})
+	if body := render("/auth/reauth"); !strings.Contains(body, `name="code"`) {
+		t.Fatalf("expected the authenticator code input with two-factor authentication")
+	}
+}
+
+func TestPageReauthPasswordless(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+	testutils.SetPasswordlessForTest(a, true)
+	a.SetPasswordlessChannel(types.PasswordlessChannelSms)
+
+	recorder := httptest.NewRecorder()
+	PageReauth(recorder, httptest.NewRequest(http.MethodGet, "/auth/reauth", nil), a)
+
+	body := recorder.Body.String()
+	for _, v := range []string{`name="phone"`, `name="verification_code"`, "Send me a code"} {
+		if !strings.Contains(body, v) {
+			t.Errorf("expected %q in page, got %s", v, body)
+		}
+	}
+	if strings.Contains(body, `name="password"`) || strings.Contains(body, `name="email"`) {
+		t.Fatalf("expected no password or email input, got %s", body)
+	}
+}
+
+func TestReauthBack(t *testing.T) {
+	tests := []struct {
+		back     string
+		expected string
+	}{
+		{"/settings/email?tab=1", "/settings/email?tab=1"},
+		{"", "/dashboard"},
+		{"https://evil.example", "/dashboard"},
+		{"//evil.example", "/dashboard"},
+		{"/\\evil.example", "/dashboard"},
+		{"javascript:alert(1)", "/dashboard"},
+	}
+
+	for _, tt := range tests {
+		if got := ReauthBack(tt.back, "/dashboard"); got != tt.expected {
+			t.Errorf("ReauthBack(%q) = %q, want %q", tt.back, got, tt.expected)
+		}
+	}
+
+	// Local paths are embedded as script strings, whatever they contain
+	if got := jsString(`/x"</script>`); got != `"/x\"\u003c/script\u003e"` {
+		t.Fatalf("expected an escaped string, got %s", got)
+	}
+}
@@ -0,0 +1,170 @@
+package page_sessions
+
+import "github.com/dracory/hb"
+
+// SessionsContent builds the HTML for the active sessions page.
+func SessionsContent(urlBack string) string {
+	// Elements for the form
+	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
+	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
+	alertGroup := hb.NewDiv().Class("alert-group").AddChild(alertSuccess).AddChild(alertDanger)
+
+	header := hb.NewHeading5().Text("Active sessions").Style("margin:0px;")
+	infoParagraph := hb.NewParagraph().Class("text-info").Text("These are the devices signed in to your account. Sign out any session you do not recognize")
+	sessionList := hb.NewDiv().Class("SessionList list-group mb-3").Child(
+		hb.NewDiv().Class("list-group-item text-muted").Text("Loading..."),
+	)
+	buttonRevokeOthers := hb.NewButton().Class("ButtonRevokeOthers btn btn-lg btn-danger btn-block w-100 text-white").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-box-arrow-right").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Sign out all other sessions"),
+		hb.NewDiv().Class("ImgLoading spinner-border spinner-border-sm text-light").Style("display:none;margin-left:10px;"),
+	}).OnClick("sessionsRevokeOthers()")
+	buttonRevokeOthersFormGroup := hb.NewDiv().Class("form-group mt-3 mb-3").AddChild(buttonRevokeOthers)
+	buttonBack := hb.NewHyperlink().Class("btn btn-info text-white float-start").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-chevron-left").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Back"),
+	}).Href(urlBack)
+
+	// Add elements in a card
+	cardHeader := hb.NewDiv().Class("card-header").Child(header)
+	cardBody := hb.NewDiv().Class("card-body").Children([]hb.TagInterface{
+		alertGroup,
+		infoParagraph,
+		sessionList,
+		buttonRevokeOthersFormGroup,
+	})
+	cardFooter := hb.NewDiv().Class("card-footer").Children([]hb.TagInterface{
+		buttonBack,
+	})
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 480px;").Children([]hb.TagInterface{
+		cardHeader,
+		cardBody,
+		cardFooter,
+	})
+
+	container := hb.NewDiv().Class("container").Child(card)
+
+	return container.ToHTML()
+}
+
+// SessionsScripts builds the JS for the active sessions page.
+func SessionsScripts(urlApiSessions, urlApiSessionRevoke, urlApiSessionsRevokeOthers string) string {
+	return `
+		var urlApiSessions = "` + urlApiSessions + `";
+		var urlApiSessionRevoke = "` + urlApiSessionRevoke + `";
+		var urlApiSessionsRevokeOthers = "` + urlApiSessionsRevokeOthers + `";
+		/**
+		 * Raises an error message
+		 * @param  {String} error
+		 * @returns  {Boolean}
+		 */
+		function sessionsRaiseError(error) {
+			$('div.alert-success').html('').hide();
+			$('div.alert-danger').html(error).show();
+			setTimeout(function () {
+				$('div.alert-danger').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		function sessionsRaiseSuccess(success) {
+			$('div.alert-danger').html('').hide();
+			$('div.alert-success').html(success).show();
+			setTimeout(function () {
+				$('div.alert-success').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		/**
+		 * Renders the sessions in the list. Values are set as text, as
+		 * the user agent is supplied by the client
+		 * @param  {Array} sessions
+		 */
+		function sessionsRender(sessions) {
+			var list = $('.SessionList').empty();
+
+			$.each(sessions, function (i, session) {
+				var item = $('<div class="list-group-item d-flex justify-content-between align-items-center"></div>');
+				var details = $('<div></div>');
+				details.append($('<div class="fw-bold"></div>').text(session.device));
+				details.append($('<small class="text-muted d-block"></small>').text(session.ip));
+				details.append($('<small class="text-muted d-block"></small>').text('Last active ' + new Date(session.last_seen_at).toLocaleString()));
+				item.append(details);
+
+				if (session.current) {
+					item.append($('<span class="badge bg-success">This device</span>'));
+				} else {
+					var button = $('<button class="btn btn-sm btn-outline-danger">Sign out</button>');
+					button.on('click', function () {
+						sessionRevoke(session.id);
+					});
+					item.append(button);
+				}
+
+				list.append(item);
+			});
+		}
+
+		/**
+		 * Loads the sessions of the user
+		 */
+		function sessionsLoad() {
+			$.post(urlApiSessions, {}).then(function (response) {
+				if (response.status !== "success") {
+					return sessionsRaiseError(response.message);
+				}
+
+				sessionsRender(response.data.sessions);
+			}).fail(function (error) {
+				console.log(error);
+				return sessionsRaiseError('There was an error. Try again later!');
+			});
+		}
+
+		/**
+		 * Signs out the session with the ID
+		 * @param  {String} sessionId
+		 */
+		function sessionRevoke(sessionId) {
+			$.post(urlApiSessionRevoke, {session_id: sessionId}).then(function (response) {
+				if (response.status !== "success") {
+					return sessionsRaiseError(response.message);
+				}
+
+				sessionsRaiseSuccess('Session signed out');
+				sessionsLoad();
+			}).fail(function (error) {
+				console.log(error);
+				return sessionsRaiseError('There was an error. Try again later!');
+			});
+		}
+
+		/**
+		 * Signs out every session except this one
+		 */
+		function sessionsRevokeOthers() {
+			$('.ButtonRevokeOthers .ImgLoading').show();
+
+			$.post(urlApiSessionsRevokeOthers, {}).then(function (response) {
+				$('.ButtonRevokeOthers .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return sessionsRaiseError(response.message);
+				}
+
+				sessionsRaiseSuccess('All other sessions signed out');
+				sessionsLoad();
+			}).fail(function (error) {
+				console.log(error);
+				$('.ButtonRevokeOthers .ImgLoading').hide();
+				return sessionsRaiseError('There was an error. Try again later!');
+			});
+		}
+
+		$(function () {
+			sessionsLoad();
+		});
+	`
+}
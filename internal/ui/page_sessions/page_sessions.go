@@ -0,0 +1,29 @@
+package page_sessions
+
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+)
+
+// PageSessions renders the page where an authenticated user sees the
+// devices signed in to the account and signs them out.
+func PageSessions(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	content := SessionsContent(a.LinkRedirectOnSuccess())
+	scripts := SessionsScripts(
+		links.ApiSessions(a.GetEndpoint()),
+		links.ApiSessionRevoke(a.GetEndpoint()),
+		links.ApiSessionsRevokeOthers(a.GetEndpoint()),
+	)
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Active sessions",
+		Layout:     a.GetLayout(),
+		Content:    content,
+		Scripts:    scripts,
+		Logger:     a.GetLogger(),
+		LogMessage: "failed to write sessions page response",
+	})
+}
@@ -0,0 +1,42 @@
+package page_sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func TestPageSessions(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	PageSessions(recorder, req, a)
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	body := recorder.Body.String()
+
+	expected := []string{
+		"Active sessions",
+		"Sign out all other sessions",
+		"var urlApiSessions = \"http://localhost/auth/api/sessions\";",
+		"var urlApiSessionRevoke = \"http://localhost/auth/api/session-revoke\";",
+		"var urlApiSessionsRevokeOthers = \"http://localhost/auth/api/sessions-revoke-others\";",
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+}
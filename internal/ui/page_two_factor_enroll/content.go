@@ -0,0 +1,159 @@
+package page_two_factor_enroll
+
+import "github.com/dracory/hb"
+
+// TwoFactorEnrollContent builds the HTML for the two-factor enrollment page.
+func TwoFactorEnrollContent(urlBack string) string {
+	// Elements for the form
+	alertSuccess := hb.NewDiv().Class("alert alert-success").Style("display:none")
+	alertDanger := hb.NewDiv().Class("alert alert-danger").Style("display:none")
+	alertGroup := hb.NewDiv().Class("alert-group").AddChild(alertSuccess).AddChild(alertDanger)
+
+	header := hb.NewHeading5().Text("Enable Two-Factor Authentication").Style("margin:0px;")
+	infoParagraph := hb.NewParagraph().Class("text-info").Text("Scan the QR code with your authenticator app, then enter the 6-digit code it shows")
+	qrCodeImage := hb.NewImage().Class("QrCode img-fluid d-block mx-auto").Alt("QR code").Style("display:none;")
+	secretParagraph := hb.NewParagraph().Class("text-center small").Children([]hb.TagInterface{
+		hb.NewSpan().Text("Or enter this key manually: "),
+		hb.NewCode().Class("TotpSecret"),
+	})
+	codeLabel := hb.NewLabel().Text("Authentication code")
+	codeInput := hb.NewInput().Class("form-control").Name("code").Placeholder("123456").Attr("autocomplete", "one-time-code").Attr("inputmode", "numeric")
+	codeFormGroup := hb.NewDiv().Class("form-group mt-3").Child(codeLabel).AddChild(codeInput)
+	buttonConfirm := hb.NewButton().Class("ButtonConfirm btn btn-lg btn-success btn-block w-100 text-white").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-shield-check").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Enable"),
+		hb.NewDiv().Class("ImgLoading spinner-border spinner-border-sm text-light").Style("display:none;margin-left:10px;"),
+	}).OnClick("twoFactorEnrollConfirm()")
+	buttonConfirmFormGroup := hb.NewDiv().Class("form-group mt-3 mb-3").AddChild(buttonConfirm)
+	recoveryCodes := hb.NewDiv().Class("RecoveryCodes").Style("display:none").Children([]hb.TagInterface{
+		hb.NewParagraph().Class("text-warning").Text("Save these recovery codes somewhere safe. Each code can be used once to sign in if you lose your device. They will not be shown again."),
+		hb.NewUL().Class("RecoveryCodesList list-unstyled font-monospace text-center"),
+		hb.NewHyperlink().Class("btn btn-success w-100 text-white").Text("Continue").Href(urlBack),
+	})
+	buttonBack := hb.NewHyperlink().Class("btn btn-info text-white float-start").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-chevron-left").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text("Cancel"),
+	}).Href(urlBack)
+
+	// Add elements in a card
+	cardHeader := hb.NewDiv().Class("card-header").Child(header)
+	cardBody := hb.NewDiv().Class("card-body").Children([]hb.TagInterface{
+		alertGroup,
+		infoParagraph,
+		qrCodeImage,
+		secretParagraph,
+		codeFormGroup,
+		buttonConfirmFormGroup,
+		recoveryCodes,
+	})
+	cardFooter := hb.NewDiv().Class("card-footer").Children([]hb.TagInterface{
+		buttonBack,
+	})
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 360px;").Children([]hb.TagInterface{
+		cardHeader,
+		cardBody,
+		cardFooter,
+	})
+
+	container := hb.NewDiv().Class("container").Child(card)
+
+	return container.ToHTML()
+}
+
+// TwoFactorEnrollScripts builds the JS for the two-factor enrollment page.
+func TwoFactorEnrollScripts(urlApiTwoFactorEnroll, urlApiTwoFactorEnrollConfirm, urlOnSuccess string) string {
+	return `
+		var urlApiTwoFactorEnroll = "` + urlApiTwoFactorEnroll + `";
+		var urlApiTwoFactorEnrollConfirm = "` + urlApiTwoFactorEnrollConfirm + `";
+		var urlOnSuccess = "` + urlOnSuccess + `";
+		/**
+		 * Raises an error message
+		 * @param  {String} error
+		 * @returns  {Boolean}
+		 */
+		function twoFactorEnrollRaiseError(error) {
+			$('div.alert-success').html('').hide();
+			$('div.alert-danger').html(error).show();
+			setTimeout(function () {
+				$('div.alert-danger').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		function twoFactorEnrollRaiseSuccess(success) {
+			$('div.alert-danger').html('').hide();
+			$('div.alert-success').html(success).show();
+			setTimeout(function () {
+				$('div.alert-success').html('').hide();
+			}, 10000);
+			return false;
+		}
+
+		/**
+		 * Starts the enrollment and shows the QR code
+		 */
+		function twoFactorEnrollStart() {
+			$.post(urlApiTwoFactorEnroll, {}).then(function (response) {
+				if (response.status !== "success") {
+					return twoFactorEnrollRaiseError(response.message);
+				}
+
+				$('img.QrCode').attr('src', response.data.qr_code).show();
+				$('code.TotpSecret').text(response.data.secret);
+			}).fail(function (error) {
+				console.log(error);
+				return twoFactorEnrollRaiseError('There was an error. Try again later!');
+			});
+		}
+
+		/**
+		 * Confirms the enrollment with a code from the authenticator app
+		 * @returns  {Boolean}
+		 */
+		function twoFactorEnrollConfirm() {
+			var code = $.trim($('input[name=code]').val());
+
+			if (code === '') {
+				return twoFactorEnrollRaiseError('Code is required');
+			}
+
+			$('.ButtonConfirm .ImgLoading').show();
+
+			var data = {"code": code};
+
+			$.post(urlApiTwoFactorEnrollConfirm, data).then(function (response) {
+				$('.ButtonConfirm .ImgLoading').hide();
+
+				if (response.status !== "success") {
+					return twoFactorEnrollRaiseError(response.message);
+				}
+
+				twoFactorEnrollRaiseSuccess('Two-factor authentication enabled');
+
+				if (response.data && response.data.recovery_codes) {
+					$('img.QrCode').hide();
+					$('.ButtonConfirm').closest('.card-body').children().not('.alert-group, .RecoveryCodes').hide();
+					$.each(response.data.recovery_codes, function (i, code) {
+						$('<li>').text(code).appendTo('ul.RecoveryCodesList');
+					});
+					$('.RecoveryCodes').show();
+					return;
+				}
+
+				setTimeout(function () {
+					$$.to(urlOnSuccess);
+				}, 2000);
+				return;
+			}).fail(function (error) {
+				console.log(error);
+				$('.ButtonConfirm .ImgLoading').hide();
+				return twoFactorEnrollRaiseError('There was an error. Try again later!');
+			});
+		}
+		$(function () {
+			twoFactorEnrollStart();
+			$('input[name=code]').focus();
+		});
+	`
+}
@@ -0,0 +1,14 @@
+package page_two_factor_enroll
+
+import "log/slog"
+
+// Dependencies contains the dependencies required to render the two-factor
+// enrollment page.
+type Dependencies struct {
+	Endpoint          string
+	RedirectOnSuccess string
+
+	Layout func(content string) string
+
+	Logger *slog.Logger
+}
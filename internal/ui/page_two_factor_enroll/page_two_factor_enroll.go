@@ -0,0 +1,29 @@
+package page_two_factor_enroll
+
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/links"
+	"github.com/dracory/auth/internal/ui/shared"
+	"github.com/dracory/auth/types"
+)
+
+// PageTwoFactorEnroll renders the page where an authenticated user scans the
+// QR code with an authenticator app and confirms the first code.
+func PageTwoFactorEnroll(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface) {
+	content := TwoFactorEnrollContent(a.LinkRedirectOnSuccess())
+	scripts := TwoFactorEnrollScripts(
+		links.ApiTwoFactorEnroll(a.GetEndpoint()),
+		links.ApiTwoFactorEnrollConfirm(a.GetEndpoint()),
+		a.LinkRedirectOnSuccess(),
+	)
+
+	shared.PageRender(w, shared.PageOptions{
+		Title:      "Enable Two-Factor Authentication",
+		Layout:     a.GetLayout(),
+		Content:    content,
+		Scripts:    scripts,
+		Logger:     a.GetLogger(),
+		LogMessage: "failed to write two-factor enroll page response",
+	})
+}
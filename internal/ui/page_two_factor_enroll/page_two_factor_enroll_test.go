@@ -0,0 +1,41 @@
+package page_two_factor_enroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+)
+
+func TestPageTwoFactorEnroll(t *testing.T) {
+	a := testutils.NewAuthSharedForTest()
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	PageTwoFactorEnroll(recorder, req, a)
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	body := recorder.Body.String()
+
+	expected := []string{
+		"Enable Two-Factor Authentication",
+		"var urlApiTwoFactorEnroll = \"http://localhost/auth/api/2fa-enroll\";",
+		"var urlApiTwoFactorEnrollConfirm = \"http://localhost/auth/api/2fa-enroll-confirm\";",
+		"var urlOnSuccess = \"http://localhost/dashboard\";",
+	}
+
+	for _, v := range expected {
+		if !strings.Contains(body, v) {
+			t.Errorf("Handler returned unexpected result.\nEXPECTED: %s\nFOUND: %s", v, body)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package shared
+
+import "github.com/dracory/hb"
+
+// MessageContent builds a card showing an error message with a link back,
+// used by the flows that end in a page rather than in a JSON response.
+func MessageContent(title string, message string, urlBack string, labelBack string) string {
+	header := hb.NewHeading5().Text(title).Style("margin:0px;")
+	alert := hb.NewDiv().Class("alert alert-danger").Text(message)
+	buttonBack := hb.NewHyperlink().Class("btn btn-info text-white float-start").Children([]hb.TagInterface{
+		hb.NewI().Class("bi bi-chevron-left").Style("margin-right:8px;margin-top:-2px;"),
+		hb.NewSpan().Text(labelBack),
+	}).Href(urlBack)
+
+	card := hb.NewDiv().Class("card card-default").Style("margin:0 auto;max-width: 360px;").Children([]hb.TagInterface{
+		hb.NewDiv().Class("card-header").Child(header),
+		hb.NewDiv().Class("card-body").Child(alert),
+		hb.NewDiv().Class("card-footer").Child(buttonBack),
+	})
+
+	return hb.NewDiv().Class("container").Child(card).ToHTML()
+}
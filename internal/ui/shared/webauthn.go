@@ -0,0 +1,48 @@
+package shared
+
+// WebAuthnScripts builds the JS helpers shared by the pages using passkeys.
+// The API exchanges binary WebAuthn values as base64url strings, while the
+// browser API expects ArrayBuffers.
+func WebAuthnScripts() string {
+	return `
+		function webauthnToBuffer(value) {
+			var base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+			while (base64.length % 4) {
+				base64 += '=';
+			}
+			var binary = atob(base64);
+			var bytes = new Uint8Array(binary.length);
+			for (var i = 0; i < binary.length; i++) {
+				bytes[i] = binary.charCodeAt(i);
+			}
+			return bytes.buffer;
+		}
+
+		function webauthnFromBuffer(buffer) {
+			var bytes = new Uint8Array(buffer);
+			var binary = '';
+			for (var i = 0; i < bytes.length; i++) {
+				binary += String.fromCharCode(bytes[i]);
+			}
+			return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+		}
+
+		function webauthnSupported() {
+			return !!(window.PublicKeyCredential && navigator.credentials);
+		}
+
+		function webauthnCreationOptions(publicKey) {
+			publicKey.challenge = webauthnToBuffer(publicKey.challenge);
+			publicKey.user.id = webauthnToBuffer(publicKey.user.id);
+			(publicKey.excludeCredentials || []).forEach(function (credential) {
+				credential.id = webauthnToBuffer(credential.id);
+			});
+			return publicKey;
+		}
+
+		function webauthnRequestOptions(publicKey) {
+			publicKey.challenge = webauthnToBuffer(publicKey.challenge);
+			return publicKey;
+		}
+	`
+}
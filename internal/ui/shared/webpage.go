@@ -15,6 +15,8 @@ type PageOptions struct {
 	Scripts    string
 	Logger     *slog.Logger
 	LogMessage string
+	// StatusCode is the HTTP status of the page, 200 when not set
+	StatusCode int
 }
 
 // buildPage composes a full HTML document using the shared UI shell
@@ -74,16 +76,21 @@ func buildPage(opts PageOptions) string {
 	return webpage.ToHTML()
 }
 
-// PageRender writes the provided HTML to the ResponseWriter using a standard
-// status code and content type. If writing fails and a logger is provided, it
-// logs the supplied error message together with the error.
+// PageRender writes the provided HTML to the ResponseWriter using the given
+// status code (200 by default) and content type. If writing fails and a logger
+// is provided, it logs the supplied error message together with the error.
 func PageRender(
 	w http.ResponseWriter,
 	opts PageOptions,
 ) {
 	html := buildPage(opts)
 
-	w.WriteHeader(http.StatusOK)
+	statusCode := opts.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "text/html")
 	if _, err := w.Write([]byte(html)); err != nil {
 		if opts.Logger != nil {
@@ -216,6 +216,26 @@ func TestPageRender_StatusCode(t *testing.T) {
 	}
 }
 
+// TestPageRender_CustomStatusCode tests that PageRender uses the status code
+// of the options when set.
+func TestPageRender_CustomStatusCode(t *testing.T) {
+	opts := PageOptions{
+		Title: "Test",
+		Layout: func(content string) string {
+			return content
+		},
+		Content:    "<p>Content</p>",
+		StatusCode: http.StatusForbidden,
+	}
+
+	recorder := httptest.NewRecorder()
+	PageRender(recorder, opts)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+}
+
 // TestPageRender_ContentType tests that PageRender sets the correct content type.
 func TestPageRender_ContentType(t *testing.T) {
 	opts := PageOptions{
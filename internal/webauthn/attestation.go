@@ -0,0 +1,130 @@
+package webauthn
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Supported attestation statement formats.
+const (
+	AttestationFormatNone   = "none"
+	AttestationFormatPacked = "packed"
+)
+
+// AttestationObject is the parsed attestationObject returned by
+// navigator.credentials.create().
+type AttestationObject struct {
+	Format       string
+	Statement    map[any]any
+	RawAuthData  []byte
+	AuthData     *AuthenticatorData
+	PublicKey    *PublicKey
+	CredentialID []byte
+}
+
+// ParseAttestationObject decodes the attestation object and the attested
+// credential data it contains.
+func ParseAttestationObject(data []byte) (*AttestationObject, error) {
+	decoded, rest, err := CborDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("webauthn: trailing data after attestation object")
+	}
+
+	object, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, errors.New("webauthn: attestation object is not a map")
+	}
+
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[any]any)
+	rawAuthData, _ := object["authData"].([]byte)
+
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, errors.New("webauthn: attestation object is incomplete")
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	if authData.Flags&FlagAttestedCredentialData == 0 || len(authData.CredentialID) == 0 {
+		return nil, errors.New("webauthn: attested credential data is missing")
+	}
+
+	publicKey, err := ParsePublicKey(authData.CredentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttestationObject{
+		Format:       format,
+		Statement:    statement,
+		RawAuthData:  rawAuthData,
+		AuthData:     authData,
+		PublicKey:    publicKey,
+		CredentialID: authData.CredentialID,
+	}, nil
+}
+
+// VerifyStatement verifies the attestation statement. The "none" format and
+// the "packed" format (self attestation, or a certificate whose key signed
+// the statement) are supported. Certificate chains are not validated against
+// trust anchors; the attestation only proves possession of the key.
+func (a *AttestationObject) VerifyStatement(clientDataHash []byte) error {
+	switch a.Format {
+	case AttestationFormatNone:
+		if len(a.Statement) != 0 {
+			return errors.New("webauthn: none attestation must have an empty statement")
+		}
+		return nil
+	case AttestationFormatPacked:
+		return a.verifyPacked(clientDataHash)
+	}
+	return fmt.Errorf("webauthn: unsupported attestation format %q", a.Format)
+}
+
+// verifyPacked implements WebAuthn Level 2, section 8.2.
+func (a *AttestationObject) verifyPacked(clientDataHash []byte) error {
+	alg, ok := a.Statement["alg"].(int64)
+	if !ok {
+		return errors.New("webauthn: packed attestation is missing alg")
+	}
+	signature, ok := a.Statement["sig"].([]byte)
+	if !ok {
+		return errors.New("webauthn: packed attestation is missing sig")
+	}
+
+	signed := append(append([]byte{}, a.RawAuthData...), clientDataHash...)
+
+	if x5c, hasX5c := a.Statement["x5c"].([]any); hasX5c {
+		if len(x5c) == 0 {
+			return errors.New("webauthn: packed attestation has an empty x5c")
+		}
+		leaf, ok := x5c[0].([]byte)
+		if !ok {
+			return errors.New("webauthn: packed attestation certificate is invalid")
+		}
+		certificate, err := x509.ParseCertificate(leaf)
+		if err != nil {
+			return fmt.Errorf("webauthn: packed attestation certificate is invalid: %w", err)
+		}
+		if !verifySignature(certificate.PublicKey, alg, signed, signature) {
+			return errors.New("webauthn: packed attestation signature is invalid")
+		}
+		return nil
+	}
+
+	// Self attestation: signed with the credential private key itself.
+	if alg != a.PublicKey.Algorithm {
+		return errors.New("webauthn: packed self attestation algorithm mismatch")
+	}
+	if !a.PublicKey.Verify(signed, signature) {
+		return errors.New("webauthn: packed self attestation signature is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// Authenticator data flags (WebAuthn Level 2, section 6.1).
+const (
+	FlagUserPresent            byte = 0x01
+	FlagUserVerified           byte = 0x04
+	FlagAttestedCredentialData byte = 0x40
+	FlagExtensionData          byte = 0x80
+)
+
+// authenticatorDataMinLength is rpIdHash (32) + flags (1) + signCount (4).
+const authenticatorDataMinLength = 37
+
+// AuthenticatorData is the parsed authenticator data structure.
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Attested credential data, only present during registration.
+	AAGUID              []byte
+	CredentialID        []byte
+	CredentialPublicKey []byte
+}
+
+// ParseAuthenticatorData parses the binary authenticator data.
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < authenticatorDataMinLength {
+		return nil, errors.New("webauthn: authenticator data is too short")
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  bytes.Clone(data[:32]),
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rest := data[authenticatorDataMinLength:]
+
+	if authData.Flags&FlagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("webauthn: attested credential data is too short")
+		}
+		authData.AAGUID = bytes.Clone(rest[:16])
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, errors.New("webauthn: credential ID is truncated")
+		}
+		authData.CredentialID = bytes.Clone(rest[:idLength])
+		rest = rest[idLength:]
+
+		_, afterKey, err := CborDecode(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.CredentialPublicKey = bytes.Clone(rest[:len(rest)-len(afterKey)])
+		rest = afterKey
+	}
+
+	if authData.Flags&FlagExtensionData != 0 {
+		_, afterExtensions, err := CborDecode(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = afterExtensions
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("webauthn: trailing data after authenticator data")
+	}
+
+	return authData, nil
+}
+
+// UserPresent reports whether the user presence flag is set.
+func (d *AuthenticatorData) UserPresent() bool {
+	return d.Flags&FlagUserPresent != 0
+}
+
+// UserVerified reports whether the user verification flag is set, i.e. the
+// authenticator checked a PIN or biometric.
+func (d *AuthenticatorData) UserVerified() bool {
+	return d.Flags&FlagUserVerified != 0
+}
+
+// VerifyRPID checks that the authenticator data belongs to the relying party.
+func (d *AuthenticatorData) VerifyRPID(rpID string) bool {
+	expected := sha256.Sum256([]byte(rpID))
+	return subtle.ConstantTimeCompare(d.RPIDHash, expected[:]) == 1
+}
@@ -0,0 +1,249 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// CBOR major types (RFC 8949 section 3.1).
+const (
+	cborMajorUnsigned = 0
+	cborMajorNegative = 1
+	cborMajorBytes    = 2
+	cborMajorText     = 3
+	cborMajorArray    = 4
+	cborMajorMap      = 5
+	cborMajorTag      = 6
+	cborMajorSimple   = 7
+)
+
+// cborMaxDepth bounds nesting to protect against maliciously deep input.
+const cborMaxDepth = 16
+
+var errCborTruncated = errors.New("cbor: unexpected end of data")
+
+// CborDecode decodes the first CBOR data item in data and returns it together
+// with the remaining bytes. Only the subset of CBOR used by WebAuthn is
+// supported: integers (as int64), byte strings, text strings, arrays
+// ([]any), maps (map[any]any), tags (ignored) and the simple values false,
+// true and null. Indefinite lengths and floats are rejected.
+func CborDecode(data []byte) (any, []byte, error) {
+	return cborDecode(data, 0)
+}
+
+func cborDecode(data []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, errors.New("cbor: nesting too deep")
+	}
+	if len(data) == 0 {
+		return nil, nil, errCborTruncated
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == cborMajorSimple {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		default:
+			return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	arg, data, err := cborArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborMajorUnsigned:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), data, nil
+	case cborMajorNegative:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), data, nil
+	case cborMajorBytes, cborMajorText:
+		if uint64(len(data)) < arg {
+			return nil, nil, errCborTruncated
+		}
+		value := data[:arg]
+		if major == cborMajorText {
+			return string(value), data[arg:], nil
+		}
+		return bytes.Clone(value), data[arg:], nil
+	case cborMajorArray:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCborTruncated
+		}
+		items := make([]any, 0, arg)
+		for range arg {
+			var item any
+			item, data, err = cborDecode(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case cborMajorMap:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCborTruncated
+		}
+		items := make(map[any]any, arg)
+		for range arg {
+			var key, value any
+			key, data, err = cborDecode(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+			value, data, err = cborDecode(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, data, nil
+	case cborMajorTag:
+		return cborDecode(data, depth+1)
+	}
+
+	return nil, nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+func cborArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, errCborTruncated
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, errCborTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, errCborTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, errCborTruncated
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	}
+	return 0, nil, errors.New("cbor: indefinite lengths are not supported")
+}
+
+// CborEncode encodes the value as CBOR. It supports the same subset as
+// CborDecode (int, int64, []byte, string, bool, nil, []any and map[any]any).
+// Map keys are sorted in canonical order, so the output is deterministic.
+func CborEncode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := cborEncode(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func cborEncode(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(cborMajorSimple<<5 | 22)
+	case bool:
+		if v {
+			buf.WriteByte(cborMajorSimple<<5 | 21)
+		} else {
+			buf.WriteByte(cborMajorSimple<<5 | 20)
+		}
+	case int:
+		return cborEncode(buf, int64(v))
+	case int64:
+		if v >= 0 {
+			cborWriteHead(buf, cborMajorUnsigned, uint64(v))
+		} else {
+			cborWriteHead(buf, cborMajorNegative, uint64(-1-v))
+		}
+	case []byte:
+		cborWriteHead(buf, cborMajorBytes, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		cborWriteHead(buf, cborMajorText, uint64(len(v)))
+		buf.WriteString(v)
+	case []any:
+		cborWriteHead(buf, cborMajorArray, uint64(len(v)))
+		for _, item := range v {
+			if err := cborEncode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		type entry struct{ key, value []byte }
+		entries := make([]entry, 0, len(v))
+		for key, item := range v {
+			encodedKey, err := CborEncode(key)
+			if err != nil {
+				return err
+			}
+			encodedValue, err := CborEncode(item)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{encodedKey, encodedValue})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if len(entries[i].key) != len(entries[j].key) {
+				return len(entries[i].key) < len(entries[j].key)
+			}
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+		cborWriteHead(buf, cborMajorMap, uint64(len(entries)))
+		for _, e := range entries {
+			buf.Write(e.key)
+			buf.Write(e.value)
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %T", value)
+	}
+	return nil
+}
+
+func cborWriteHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
@@ -0,0 +1,75 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCborRoundTrip(t *testing.T) {
+	value := map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": []byte{1, 2, 3},
+		int64(-7):  []any{int64(0), int64(23), int64(24), int64(-1), int64(65536), true, false, nil},
+	}
+
+	encoded, err := CborEncode(value)
+	if err != nil {
+		t.Fatalf("unexpected encode error: %v", err)
+	}
+
+	decoded, rest, err := CborDecode(encoded)
+	if err != nil {
+		t.Fatalf("unexpected decode error: %v", err)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("expected no trailing data, got %d bytes", len(rest))
+	}
+
+	m := decoded.(map[any]any)
+	if m["fmt"] != "none" {
+		t.Fatalf("unexpected fmt %v", m["fmt"])
+	}
+	if !bytes.Equal(m["authData"].([]byte), []byte{1, 2, 3}) {
+		t.Fatalf("unexpected authData %v", m["authData"])
+	}
+	list := m[int64(-7)].([]any)
+	if list[3] != int64(-1) || list[4] != int64(65536) || list[5] != true || list[7] != nil {
+		t.Fatalf("unexpected list %v", list)
+	}
+}
+
+func TestCborDecodeKnownVector(t *testing.T) {
+	// {1: 2, "a": [3]} from RFC 8949 appendix A style encodings.
+	data, _ := hex.DecodeString("a2010261618103")
+
+	decoded, _, err := CborDecode(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m := decoded.(map[any]any)
+	if m[int64(1)] != int64(2) {
+		t.Fatalf("unexpected value for key 1: %v", m[int64(1)])
+	}
+	if list := m["a"].([]any); len(list) != 1 || list[0] != int64(3) {
+		t.Fatalf("unexpected value for key a: %v", m["a"])
+	}
+}
+
+func TestCborDecodeRejectsMalformedInput(t *testing.T) {
+	cases := map[string]string{
+		"truncated bytes":   "430102",
+		"indefinite length": "5f",
+		"huge array":        "9bffffffffffffffff",
+		"float":             "f93c00",
+	}
+
+	for name, input := range cases {
+		data, _ := hex.DecodeString(input)
+		if _, _, err := CborDecode(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// Temporary key store prefixes for pending ceremonies. A challenge is only
+// accepted if it was issued by this server and has not been used yet.
+const (
+	RegistrationChallengeKeyPrefix = "webauthn_register:"
+	LoginChallengeKeyPrefix        = "webauthn_login:"
+)
+
+// ChallengeExpiresSeconds is how long a ceremony may take before the
+// challenge expires. It matches the timeout sent to the browser.
+const ChallengeExpiresSeconds = 300
+
+// RelyingParty identifies the server to authenticators.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// ErrSignCount is returned when the signature counter did not increase,
+// which signals that the authenticator may have been cloned.
+var ErrSignCount = errors.New("webauthn: signature counter did not increase")
+
+// RegistrationResult is a verified new credential.
+type RegistrationResult struct {
+	CredentialID      []byte
+	PublicKey         []byte
+	SignCount         uint32
+	AttestationFormat string
+}
+
+// VerifyRegistration performs the registration ceremony checks of WebAuthn
+// Level 2, section 7.1, for a challenge that the caller has already matched
+// against a pending registration.
+func VerifyRegistration(rp RelyingParty, challenge string, clientDataJSON []byte, attestationObject []byte) (*RegistrationResult, error) {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := clientData.Verify(ClientDataTypeCreate, challenge, rp.Origins); err != nil {
+		return nil, err
+	}
+
+	attestation, err := ParseAttestationObject(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	if !attestation.AuthData.VerifyRPID(rp.ID) {
+		return nil, errors.New("webauthn: relying party ID mismatch")
+	}
+	if !attestation.AuthData.UserPresent() {
+		return nil, errors.New("webauthn: user was not present")
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := attestation.VerifyStatement(clientDataHash[:]); err != nil {
+		return nil, err
+	}
+
+	return &RegistrationResult{
+		CredentialID:      attestation.CredentialID,
+		PublicKey:         attestation.AuthData.CredentialPublicKey,
+		SignCount:         attestation.AuthData.SignCount,
+		AttestationFormat: attestation.Format,
+	}, nil
+}
+
+// VerifyAssertion performs the authentication ceremony checks of WebAuthn
+// Level 2, section 7.2, against a stored credential and returns the new
+// signature counter to persist.
+func VerifyAssertion(rp RelyingParty, challenge string, publicKey []byte, storedSignCount uint32, clientDataJSON []byte, authenticatorData []byte, signature []byte) (uint32, error) {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return 0, err
+	}
+	if err := clientData.Verify(ClientDataTypeGet, challenge, rp.Origins); err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if !authData.VerifyRPID(rp.ID) {
+		return 0, errors.New("webauthn: relying party ID mismatch")
+	}
+	if !authData.UserPresent() {
+		return 0, errors.New("webauthn: user was not present")
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if !key.Verify(signed, signature) {
+		return 0, errors.New("webauthn: signature is invalid")
+	}
+
+	// Authenticators that do not implement a counter always report zero.
+	if (authData.SignCount != 0 || storedSignCount != 0) && authData.SignCount <= storedSignCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.SignCount, nil
+}
+
+// RelyingPartyFromConfig builds the relying party from the library
+// configuration. The name defaults to the RP ID.
+func RelyingPartyFromConfig(config *types.WebAuthnConfig) RelyingParty {
+	if config == nil {
+		return RelyingParty{}
+	}
+	name := config.RPName
+	if name == "" {
+		name = config.RPID
+	}
+	return RelyingParty{ID: config.RPID, Name: name, Origins: config.Origins}
+}
+
+// ChallengeFromClientData extracts the challenge from clientDataJSON, so the
+// pending ceremony can be looked up before the full verification.
+func ChallengeFromClientData(clientDataJSON []byte) (string, error) {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	if clientData.Challenge == "" {
+		return "", errors.New("webauthn: client data has no challenge")
+	}
+	return clientData.Challenge, nil
+}
@@ -0,0 +1,159 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/internal/webauthn"
+)
+
+var testRP = webauthn.RelyingParty{
+	ID:      "example.com",
+	Name:    "Example",
+	Origins: []string{"https://example.com"},
+}
+
+func newAuthenticator(t *testing.T) *testutils.WebAuthnAuthenticator {
+	t.Helper()
+	authenticator, err := testutils.NewWebAuthnAuthenticator("example.com", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+func register(t *testing.T, authenticator *testutils.WebAuthnAuthenticator, format string) *webauthn.RegistrationResult {
+	t.Helper()
+	clientData, attestation, err := authenticator.Create("challenge-1", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := webauthn.VerifyRegistration(testRP, "challenge-1", clientData, attestation)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	return result
+}
+
+func TestVerifyRegistrationNoneAttestation(t *testing.T) {
+	authenticator := newAuthenticator(t)
+
+	result := register(t, authenticator, webauthn.AttestationFormatNone)
+
+	if webauthn.EncodeBase64URL(result.CredentialID) != authenticator.CredentialIDString() {
+		t.Fatalf("unexpected credential ID")
+	}
+	if result.AttestationFormat != webauthn.AttestationFormatNone {
+		t.Fatalf("unexpected format %q", result.AttestationFormat)
+	}
+	if _, err := webauthn.ParsePublicKey(result.PublicKey); err != nil {
+		t.Fatalf("expected a parseable public key, got %v", err)
+	}
+}
+
+func TestVerifyRegistrationPackedSelfAttestation(t *testing.T) {
+	authenticator := newAuthenticator(t)
+
+	result := register(t, authenticator, webauthn.AttestationFormatPacked)
+
+	if result.AttestationFormat != webauthn.AttestationFormatPacked {
+		t.Fatalf("unexpected format %q", result.AttestationFormat)
+	}
+}
+
+func TestVerifyRegistrationRejectsForgedPackedSignature(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	clientData, attestation, err := authenticator.Create("challenge-1", webauthn.AttestationFormatPacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signed for a different client data, so the signature does not match.
+	otherClientData := authenticator.ClientDataJSON(webauthn.ClientDataTypeCreate, "challenge-2")
+	if _, err := webauthn.VerifyRegistration(testRP, "challenge-2", otherClientData, attestation); err == nil {
+		t.Fatalf("expected forged attestation to be rejected")
+	}
+	if _, err := webauthn.VerifyRegistration(testRP, "challenge-1", clientData, attestation); err != nil {
+		t.Fatalf("expected the original attestation to verify, got %v", err)
+	}
+}
+
+func TestVerifyRegistrationChecksChallengeOriginAndRPID(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	clientData, attestation, err := authenticator.Create("challenge-1", webauthn.AttestationFormatNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := webauthn.VerifyRegistration(testRP, "other-challenge", clientData, attestation); err == nil {
+		t.Errorf("expected challenge mismatch to be rejected")
+	}
+
+	otherOrigin := testRP
+	otherOrigin.Origins = []string{"https://evil.example"}
+	if _, err := webauthn.VerifyRegistration(otherOrigin, "challenge-1", clientData, attestation); err == nil {
+		t.Errorf("expected unknown origin to be rejected")
+	}
+
+	otherRP := testRP
+	otherRP.ID = "other.com"
+	if _, err := webauthn.VerifyRegistration(otherRP, "challenge-1", clientData, attestation); err == nil {
+		t.Errorf("expected RP ID mismatch to be rejected")
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	result := register(t, authenticator, webauthn.AttestationFormatNone)
+
+	clientData, authData, signature, err := authenticator.Get("login-challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signCount, err := webauthn.VerifyAssertion(testRP, "login-challenge", result.PublicKey, result.SignCount, clientData, authData, signature)
+	if err != nil {
+		t.Fatalf("unexpected assertion error: %v", err)
+	}
+	if signCount != 1 {
+		t.Fatalf("expected sign count 1, got %d", signCount)
+	}
+
+	// A tampered signature must be rejected.
+	signature[len(signature)-1] ^= 0xff
+	if _, err := webauthn.VerifyAssertion(testRP, "login-challenge", result.PublicKey, result.SignCount, clientData, authData, signature); err == nil {
+		t.Fatalf("expected tampered signature to be rejected")
+	}
+}
+
+func TestVerifyAssertionRejectsSignCountRegression(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	result := register(t, authenticator, webauthn.AttestationFormatNone)
+
+	clientData, authData, signature, err := authenticator.Get("login-challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server has already seen a higher counter: possible cloned key.
+	_, err = webauthn.VerifyAssertion(testRP, "login-challenge", result.PublicKey, 5, clientData, authData, signature)
+	if !errors.Is(err, webauthn.ErrSignCount) {
+		t.Fatalf("expected ErrSignCount, got %v", err)
+	}
+}
+
+func TestVerifyAssertionRejectsRegistrationClientData(t *testing.T) {
+	authenticator := newAuthenticator(t)
+	result := register(t, authenticator, webauthn.AttestationFormatNone)
+
+	_, authData, signature, err := authenticator.Get("login-challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createClientData := authenticator.ClientDataJSON(webauthn.ClientDataTypeCreate, "login-challenge")
+
+	if _, err := webauthn.VerifyAssertion(testRP, "login-challenge", result.PublicKey, 0, createClientData, authData, signature); err == nil {
+		t.Fatalf("expected webauthn.create client data to be rejected for login")
+	}
+}
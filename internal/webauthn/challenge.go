@@ -0,0 +1,31 @@
+package webauthn
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+// challengeBytes is the size of a generated challenge; the specification
+// requires at least 16 bytes.
+const challengeBytes = 32
+
+// NewChallenge returns a random base64url encoded challenge.
+func NewChallenge() (string, error) {
+	challenge := make([]byte, challengeBytes)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+	return EncodeBase64URL(challenge), nil
+}
+
+// EncodeBase64URL encodes data as unpadded base64url, the encoding used by
+// WebAuthn for binary values in JSON.
+func EncodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeBase64URL decodes base64url data, with or without padding.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,48 @@
+package webauthn
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// Client data types (WebAuthn Level 2, section 5.8.1).
+const (
+	ClientDataTypeCreate = "webauthn.create"
+	ClientDataTypeGet    = "webauthn.get"
+)
+
+// ClientData is the parsed clientDataJSON collected by the browser.
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// ParseClientData parses the raw clientDataJSON.
+func ParseClientData(data []byte) (*ClientData, error) {
+	clientData := &ClientData{}
+	if err := json.Unmarshal(data, clientData); err != nil {
+		return nil, errors.New("webauthn: client data is not valid JSON")
+	}
+	return clientData, nil
+}
+
+// Verify checks the ceremony type, the challenge and the origin.
+func (c *ClientData) Verify(expectedType string, expectedChallenge string, origins []string) error {
+	if c.Type != expectedType {
+		return errors.New("webauthn: unexpected client data type")
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Challenge), []byte(expectedChallenge)) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+	if !slices.Contains(origins, c.Origin) {
+		return errors.New("webauthn: origin is not allowed")
+	}
+	if c.CrossOrigin {
+		return errors.New("webauthn: cross-origin requests are not allowed")
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 9053) supported for credentials.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// COSE key parameters (RFC 9052 section 7 and RFC 9053 section 7).
+const (
+	coseKeyKty = 1
+	coseKeyAlg = 3
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseEC2Crv = -1
+	coseEC2X   = -2
+	coseEC2Y   = -3
+
+	coseOKPCrv = -1
+	coseOKPX   = -2
+
+	coseRSAN = -1
+	coseRSAE = -2
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// PublicKey is a credential public key decoded from its COSE representation.
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key. ES256 (P-256), EdDSA (Ed25519) and
+// RS256 keys are supported.
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	decoded, rest, err := CborDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("cose: trailing data after key")
+	}
+
+	key, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, errors.New("cose: key is not a map")
+	}
+
+	kty, _ := key[int64(coseKeyKty)].(int64)
+	alg, _ := key[int64(coseKeyAlg)].(int64)
+
+	switch kty {
+	case coseKtyEC2:
+		crv, _ := key[int64(coseEC2Crv)].(int64)
+		x, _ := key[int64(coseEC2X)].([]byte)
+		y, _ := key[int64(coseEC2Y)].([]byte)
+		if alg != AlgES256 || crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("cose: unsupported EC2 key")
+		}
+		point := append(append([]byte{0x04}, x...), y...)
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, fmt.Errorf("cose: invalid EC2 point: %w", err)
+		}
+		return &PublicKey{Algorithm: alg, Key: pub}, nil
+	case coseKtyOKP:
+		crv, _ := key[int64(coseOKPCrv)].(int64)
+		x, _ := key[int64(coseOKPX)].([]byte)
+		if alg != AlgEdDSA || crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("cose: unsupported OKP key")
+		}
+		return &PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case coseKtyRSA:
+		n, _ := key[int64(coseRSAN)].([]byte)
+		e, _ := key[int64(coseRSAE)].([]byte)
+		if alg != AlgRS256 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("cose: unsupported RSA key")
+		}
+		return &PublicKey{Algorithm: alg, Key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("cose: unsupported key type %d", kty)
+}
+
+// Verify checks the signature over data, as produced by an authenticator
+// for the key's algorithm.
+func (k *PublicKey) Verify(data []byte, signature []byte) bool {
+	return verifySignature(k.Key, k.Algorithm, data, signature)
+}
+
+func verifySignature(key crypto.PublicKey, alg int64, data []byte, signature []byte) bool {
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if alg != AlgES256 {
+			return false
+		}
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(pub, digest[:], signature)
+	case ed25519.PublicKey:
+		if alg != AlgEdDSA {
+			return false
+		}
+		return ed25519.Verify(pub, data, signature)
+	case *rsa.PublicKey:
+		if alg != AlgRS256 {
+			return false
+		}
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+// EncodeES256PublicKey encodes a P-256 public key as a COSE_Key, as an
+// authenticator would in its attested credential data.
+func EncodeES256PublicKey(pub *ecdsa.PublicKey) ([]byte, error) {
+	if pub.Curve != elliptic.P256() {
+		return nil, errors.New("cose: only P-256 keys are supported")
+	}
+	point, err := pub.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return CborEncode(map[any]any{
+		int64(coseKeyKty): int64(coseKtyEC2),
+		int64(coseKeyAlg): AlgES256,
+		int64(coseEC2Crv): int64(coseCrvP256),
+		int64(coseEC2X):   point[1:33],
+		int64(coseEC2Y):   point[33:65],
+	})
+}
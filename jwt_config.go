@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dracory/auth/internal/jwt"
+	"github.com/dracory/auth/types"
+)
+
+// validateJWTConfig validates the optional JWT access token configuration
+// shared by both authentication flows.
+func validateJWTConfig(config *types.JWTConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Expiration < 0 {
+		return errors.New("auth: JWT Expiration cannot be negative")
+	}
+
+	if err := jwt.KeyFromConfig(config).Validate(); err != nil {
+		return errors.New("auth: JWT " + strings.TrimPrefix(err.Error(), "jwt: "))
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/middlewares"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateJWTConfig(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte(strings.Repeat("s", 32))
+
+	tests := []struct {
+		name     string
+		config   *types.JWTConfig
+		expected string
+	}{
+		{"nil config", nil, ""},
+		{"HS256 by default", &types.JWTConfig{Secret: secret}, ""},
+		{"EdDSA", &types.JWTConfig{Algorithm: types.JWTAlgorithmEdDSA, PrivateKey: edKey}, ""},
+		{"short secret", &types.JWTConfig{Secret: []byte("secret")}, "auth: JWT HS256 secret must be at least 32 bytes"},
+		{"RS256 without key", &types.JWTConfig{Algorithm: types.JWTAlgorithmRS256, PrivateKey: edKey}, "auth: JWT RS256 requires an *rsa.PrivateKey"},
+		{"unsupported algorithm", &types.JWTConfig{Algorithm: "none"}, `auth: JWT unsupported algorithm "none"`},
+		{"negative expiration", &types.JWTConfig{Secret: secret, Expiration: -time.Minute}, "auth: JWT Expiration cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		err := validateJWTConfig(tt.config)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestNewUsernameAndPasswordAuth_JWTDoesNotRequireTokenStore(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncUserStoreAuthToken = nil
+	config.FuncUserFindByAuthToken = nil
+
+	if _, err := NewUsernameAndPasswordAuth(config); err == nil {
+		t.Fatal("expected error without token store and JWT")
+	}
+
+	config.JWT = &types.JWTConfig{Secret: []byte(strings.Repeat("s", 32))}
+	if _, err := NewUsernameAndPasswordAuth(config); err != nil {
+		t.Fatalf("unexpected error with JWT: %v", err)
+	}
+}
+
+func TestRouter_JWTLoginIsVerifiedLocally(t *testing.T) {
+	revoked := map[string]bool{}
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.UseCookies = false
+	config.UseLocalStorage = true
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+	config.FuncUserStoreAuthToken = func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error {
+		return errors.New("opaque tokens should not be stored in JWT mode")
+	}
+	config.FuncUserFindByAuthToken = func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+		return "", errors.New("JWT access tokens should not be looked up")
+	}
+	config.JWT = &types.JWTConfig{
+		Secret:   []byte(strings.Repeat("s", 32)),
+		Issuer:   "https://example.com",
+		Audience: "api",
+		FuncTokenRevoked: func(ctx context.Context, tokenID string, options types.UserAuthOptions) (bool, error) {
+			return revoked[tokenID], nil
+		},
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	var response struct {
+		Status string `json:"status"`
+		Data   struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("unexpected response %s: %v", recorder.Body.String(), err)
+	}
+	if response.Status != "success" || strings.Count(response.Data.Token, ".") != 2 {
+		t.Fatalf("expected a JWT access token, got %s", recorder.Body.String())
+	}
+
+	protected := func() string {
+		var userID string
+		handler := middlewares.ApiAuthOrErrorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ = r.Context().Value(types.AuthenticatedUserID{}).(string)
+		}), authShared)
+		req := httptest.NewRequest(http.MethodGet, "/api/resource", nil)
+		req.Header.Set("Authorization", "Bearer "+response.Data.Token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return userID
+	}
+
+	if userID := protected(); userID != "user-1" {
+		t.Fatalf("expected the JWT to authenticate user-1, got %q", userID)
+	}
+
+	payload := strings.Split(response.Data.Token, ".")[1]
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	revoked[claims["jti"].(string)] = true
+
+	if userID := protected(); userID != "" {
+		t.Fatalf("expected the revoked JWT to be rejected, got %q", userID)
+	}
+}
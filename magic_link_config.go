@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateMagicLinkConfig validates the optional magic link configuration of
+// the passwordless flow.
+func validateMagicLinkConfig(config *types.MagicLinkConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Secret) < types.MinMagicLinkSecretLength {
+		return errors.New("auth: MagicLink Secret must be at least 32 characters")
+	}
+
+	return nil
+}
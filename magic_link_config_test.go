@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateMagicLinkConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *types.MagicLinkConfig
+		expected string
+	}{
+		{"nil config", nil, ""},
+		{"valid", &types.MagicLinkConfig{Secret: strings.Repeat("s", types.MinMagicLinkSecretLength)}, ""},
+		{"short secret", &types.MagicLinkConfig{Secret: "secret"}, "auth: MagicLink Secret must be at least 32 characters"},
+	}
+
+	for _, tt := range tests {
+		err := validateMagicLinkConfig(tt.config)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestRouter_MagicLinkLogin(t *testing.T) {
+	store := map[string]string{}
+	var emailBody string
+
+	config := testutils.NewPasswordlessConfigForTest()
+	config.FuncTemporaryKeyGet = func(key string) (string, error) { return store[key], nil }
+	config.FuncTemporaryKeySet = func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	}
+	config.FuncEmailSend = func(ctx context.Context, email string, subject string, body string) error {
+		emailBody = body
+		return nil
+	}
+	config.MagicLink = &types.MagicLinkConfig{
+		Secret:             strings.Repeat("s", types.MinMagicLinkSecretLength),
+		RequireSameBrowser: true,
+	}
+
+	authShared, err := NewPasswordlessAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"email": {"test@test.com"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if !strings.Contains(recorder.Body.String(), `"status":"success"`) {
+		t.Fatalf("expected the login email to be sent, got %s", recorder.Body.String())
+	}
+
+	match := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(emailBody)
+	if match == nil {
+		t.Fatalf("expected a link in the email, got %s", emailBody)
+	}
+	link := strings.ReplaceAll(match[1], "&amp;", "&")
+
+	browserCookies := recorder.Result().Cookies()
+	if len(browserCookies) == 0 {
+		t.Fatal("expected the browser cookie to be set")
+	}
+
+	open := func(method string, withCookies bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, link, nil)
+		if withCookies {
+			for _, cookie := range browserCookies {
+				req.AddCookie(cookie)
+			}
+		}
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// The link carries a random token, never the code it redeems
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _, _ := strings.Cut(parsed.Query().Get("token"), ".")
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range store {
+		flow := struct {
+			Code string `json:"code"`
+		}{}
+		if strings.HasPrefix(key, "login_flow:") && json.Unmarshal([]byte(value), &flow) == nil && flow.Code != "" && strings.Contains(string(payload), flow.Code) {
+			t.Fatalf("expected the link not to reveal the code, got %s", payload)
+		}
+	}
+
+	if other := open(http.MethodGet, false); !strings.Contains(other.Body.String(), "Please open the login link in the browser you requested it from") {
+		t.Fatalf("expected the link to be rejected in another browser, got %d %s", other.Code, other.Body.String())
+	}
+
+	// Opening the link asks to confirm, and only the confirmation logs in
+	if confirm := open(http.MethodGet, true); !strings.Contains(confirm.Body.String(), `method="POST"`) {
+		t.Fatalf("expected the login to be confirmed, got %d %s", confirm.Code, confirm.Body.String())
+	}
+
+	login := open(http.MethodPost, true)
+	if login.Code != http.StatusSeeOther || login.Header().Get("Location") != "http://localhost/dashboard" {
+		t.Fatalf("expected a redirect after login, got %d %s", login.Code, login.Body.String())
+	}
+
+	authCookie := false
+	for _, cookie := range login.Result().Cookies() {
+		if cookie.Name == CookieName && cookie.Value != "" {
+			authCookie = true
+		}
+	}
+	if !authCookie {
+		t.Fatal("expected the auth cookie to be set")
+	}
+
+	if replay := open(http.MethodPost, true); !strings.Contains(replay.Body.String(), "Login link has expired or has already been used") {
+		t.Fatalf("expected the used link to be rejected, got %d %s", replay.Code, replay.Body.String())
+	}
+}
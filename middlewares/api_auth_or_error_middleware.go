@@ -1,7 +1,6 @@
 package middlewares
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/dracory/api"
@@ -12,10 +11,37 @@ import (
 
 // ApiAuthOrErrorMiddleware checks that an authentication token
 // exists, and then finds the userID based on it. On success appends
-// the user ID to the context. On failure it will return an
-// unauthenticated JSON response.
+// the user ID to the context, together with the types.Principal of the
+// request. On failure it will return an unauthenticated JSON response.
+//
+// Sessions past their lifetime or idle timeout are rejected, and activity
+// extends the idle window of the session.
+//
+// When API keys are configured, requests may authenticate with a key
+// instead. The key is then appended to the context too, so handlers can
+// check its scopes with types.ApiKeyFromContext.
 func ApiAuthOrErrorMiddleware(next http.Handler, a types.AuthSharedInterface) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := apiKeyRetrieve(r); apiKey != "" && a.GetApiKeys() != nil {
+			key, err := apiKeyAuthenticate(r, a, apiKey, types.UserAuthOptions{
+				UserIp:    req.GetIP(r),
+				UserAgent: r.UserAgent(),
+			})
+
+			if err != nil || key == nil {
+				api.Respond(w, r, api.Unauthenticated("api key is invalid"))
+				return
+			}
+
+			r = r.WithContext(types.ContextWithApiKey(contextWithApiRequest(r.Context()), *key))
+			ctx := contextWithPrincipal(r, a, types.Principal{
+				UserID:     key.UserID,
+				AuthMethod: types.AuthMethodApiKey,
+				Scopes:     key.Scopes,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
 
 		authToken := utils.AuthTokenRetrieve(r, a.GetUseCookies())
 
@@ -24,7 +50,7 @@ func ApiAuthOrErrorMiddleware(next http.Handler, a types.AuthSharedInterface) ht
 			return
 		}
 
-		userID, err := a.GetFuncUserFindByAuthToken()(r.Context(), authToken, types.UserAuthOptions{
+		principal, err := authTokenPrincipal(w, r, a, authToken, types.UserAuthOptions{
 			UserIp:    req.GetIP(r),
 			UserAgent: r.UserAgent(),
 		})
@@ -34,12 +60,13 @@ func ApiAuthOrErrorMiddleware(next http.Handler, a types.AuthSharedInterface) ht
 			return
 		}
 
-		if userID == "" {
+		if principal == nil {
 			api.Respond(w, r, api.Unauthenticated("user id is required"))
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), types.AuthenticatedUserID{}, userID)
+		r = r.WithContext(contextWithApiRequest(r.Context()))
+		ctx := contextWithPrincipal(r, a, *principal)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+	"github.com/dracory/req"
+)
+
+// apiKeyRetrieve returns the API key of the request, sent as a bearer token
+// or in the api_key parameter. Unlike session tokens, API keys are accepted
+// even when the auth token is kept in a cookie, as machine clients have no
+// cookies.
+func apiKeyRetrieve(r *http.Request) string {
+	if token := utils.BearerTokenFromHeader(r.Header.Get("Authorization")); core.IsApiKeyToken(token) {
+		return token
+	}
+
+	if token := req.GetStringTrimmed(r, "api_key"); core.IsApiKeyToken(token) {
+		return token
+	}
+
+	return ""
+}
+
+// apiKeyAuthenticate resolves the API key, returning nil when it is not
+// valid.
+func apiKeyAuthenticate(r *http.Request, a types.AuthSharedInterface, apiKey string, options types.UserAuthOptions) (*types.ApiKey, error) {
+	return core.ApiKeyAuthenticate(r.Context(), a.GetApiKeys(), apiKey, options, time.Now())
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+)
+
+// authTokenPrincipal resolves the caller of the auth token, extending the
+// idle window of the session on activity. The auth cookie is re-issued
+// whenever the session is touched, so it outlives the sliding window too.
+// It returns nil when the token is not valid.
+func authTokenPrincipal(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface, authToken string, options types.UserAuthOptions) (*types.Principal, error) {
+	principal, touched, err := core.AuthTokenAuthenticate(r.Context(), a, authToken, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if touched && a.GetUseCookies() {
+		a.SetAuthCookie(w, r, authToken)
+	}
+
+	if principal == nil || principal.UserID == "" {
+		return nil, nil
+	}
+
+	return principal, nil
+}
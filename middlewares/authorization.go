@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/ui/page_forbidden"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/req"
+)
+
+type authorizationCacheKey struct{}
+
+// authorizationCache keeps the roles and permissions of the authenticated
+// user for the rest of the request, so chained checks look them up once.
+type authorizationCache struct {
+	mu          sync.Mutex
+	userID      string
+	roles       *authorizationLookup
+	permissions *authorizationLookup
+}
+
+type authorizationLookup struct {
+	values []string
+	err    error
+}
+
+// UserRoles returns the roles of the authenticated user of the request,
+// looked up with FuncUserRoles once per request.
+func UserRoles(r *http.Request, a types.AuthSharedInterface) ([]string, error) {
+	return authorizationLookupCached(r, a, func(cache *authorizationCache) **authorizationLookup {
+		return &cache.roles
+	}, a.GetFuncUserRoles(), "FuncUserRoles")
+}
+
+// UserPermissions returns the permissions of the authenticated user of the
+// request, looked up with FuncUserPermissions once per request.
+func UserPermissions(r *http.Request, a types.AuthSharedInterface) ([]string, error) {
+	return authorizationLookupCached(r, a, func(cache *authorizationCache) **authorizationLookup {
+		return &cache.permissions
+	}, a.GetFuncUserPermissions(), "FuncUserPermissions")
+}
+
+func authorizationLookupCached(
+	r *http.Request,
+	a types.AuthSharedInterface,
+	field func(cache *authorizationCache) **authorizationLookup,
+	lookup func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error),
+	name string,
+) ([]string, error) {
+	userID := authenticatedUserID(r)
+	if userID == "" {
+		return nil, errors.New("user id is required")
+	}
+
+	cache, _ := r.Context().Value(authorizationCacheKey{}).(*authorizationCache)
+	if cache == nil || cache.userID != userID {
+		// The user ID was put into the context by other means; do not cache
+		cache = &authorizationCache{userID: userID}
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cached := field(cache)
+	if *cached != nil {
+		return (*cached).values, (*cached).err
+	}
+
+	result := &authorizationLookup{}
+	if lookup == nil {
+		result.err = errors.New("auth: " + name + " function is not configured")
+	} else {
+		result.values, result.err = lookup(r.Context(), userID, types.UserAuthOptions{
+			UserIp:    req.GetIP(r),
+			UserAgent: r.UserAgent(),
+		})
+	}
+
+	*cached = result
+	return result.values, result.err
+}
+
+// RequireRole allows the request when the authenticated user has any of the
+// roles, as returned by FuncUserRoles.
+//
+// It goes inside WebAuthOrRedirectMiddleware or ApiAuthOrErrorMiddleware.
+// Users lacking the roles get a 403 JSON response on API requests, and a
+// 403 forbidden page otherwise. Failed lookups are logged and treated as
+// lacking the roles.
+func RequireRole(next http.Handler, a types.AuthSharedInterface, roles ...string) http.Handler {
+	return authorizationMiddleware(next, a, func(r *http.Request) (bool, error) {
+		userRoles, err := UserRoles(r, a)
+		if err != nil {
+			return false, err
+		}
+		return containsAny(userRoles, roles), nil
+	})
+}
+
+// RequireAnyPermission allows the request when the authenticated user has
+// any of the permissions, as returned by FuncUserPermissions. It responds
+// like RequireRole otherwise.
+func RequireAnyPermission(next http.Handler, a types.AuthSharedInterface, permissions ...string) http.Handler {
+	return authorizationMiddleware(next, a, func(r *http.Request) (bool, error) {
+		userPermissions, err := UserPermissions(r, a)
+		if err != nil {
+			return false, err
+		}
+		return containsAny(userPermissions, permissions), nil
+	})
+}
+
+// RequireAllPermissions allows the request when the authenticated user has
+// all of the permissions, as returned by FuncUserPermissions. It responds
+// like RequireRole otherwise.
+func RequireAllPermissions(next http.Handler, a types.AuthSharedInterface, permissions ...string) http.Handler {
+	return authorizationMiddleware(next, a, func(r *http.Request) (bool, error) {
+		userPermissions, err := UserPermissions(r, a)
+		if err != nil {
+			return false, err
+		}
+		for _, permission := range permissions {
+			if !slices.Contains(userPermissions, permission) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func authorizationMiddleware(next http.Handler, a types.AuthSharedInterface, allowed func(r *http.Request) (bool, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Without an auth middleware in front there is no user to check
+		if authenticatedUserID(r) == "" {
+			http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
+			return
+		}
+
+		ok, err := allowed(r)
+		if err != nil && a.GetLogger() != nil {
+			a.GetLogger().Error("authorization lookup failed", "error", err)
+		}
+
+		if err != nil || !ok {
+			if isApiRequest(r) {
+				api.RespondWithStatusCode(w, r, api.Forbidden("you do not have permission to perform this action"), http.StatusForbidden)
+				return
+			}
+			page_forbidden.PageForbiddenWithAuth(w, r, a)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, value := range wanted {
+		if slices.Contains(values, value) {
+			return true
+		}
+	}
+	return false
+}
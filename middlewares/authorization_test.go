@@ -0,0 +1,169 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+// newAuthorizationAuthForTest returns an auth instance where the "valid"
+// token belongs to user-1, counting the role and permission lookups
+func newAuthorizationAuthForTest(roles []string, permissions []string, lookups *int) types.AuthSharedInterface {
+	authInstance := testutils.NewAuthSharedForTest()
+	testutils.SetUseCookiesForTest(authInstance, true)
+	testutils.SetLoginURLForTest(authInstance, "/auth/login")
+	testutils.SetFuncUserFindByAuthTokenForTest(authInstance, func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+		if token == "valid" {
+			return "user-1", nil
+		}
+		return "", nil
+	})
+	authInstance.SetFuncUserRoles(func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		*lookups++
+		return roles, nil
+	})
+	authInstance.SetFuncUserPermissions(func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		*lookups++
+		return permissions, nil
+	})
+	return authInstance
+}
+
+func newAuthorizationRequestForTest() *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "valid"})
+	return req
+}
+
+func TestRequireRole_Web(t *testing.T) {
+	lookups := 0
+	authInstance := newAuthorizationAuthForTest([]string{"editor"}, nil, &lookups)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	recorder := httptest.NewRecorder()
+	WebAuthOrRedirectMiddleware(RequireRole(next, authInstance, "admin", "editor"), authInstance).ServeHTTP(recorder, newAuthorizationRequestForTest())
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "ok" {
+		t.Fatalf("expected a user with any of the roles to pass, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	WebAuthOrRedirectMiddleware(RequireRole(next, authInstance, "admin"), authInstance).ServeHTTP(recorder, newAuthorizationRequestForTest())
+	if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), "You do not have permission to access this page") {
+		t.Fatalf("expected the forbidden page, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRequireRole_NotAuthenticated_RedirectsToLogin(t *testing.T) {
+	lookups := 0
+	authInstance := newAuthorizationAuthForTest([]string{"admin"}, nil, &lookups)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not be called without a user")
+	})
+
+	recorder := httptest.NewRecorder()
+	RequireRole(next, authInstance, "admin").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+	if recorder.Code != http.StatusTemporaryRedirect || recorder.Header().Get("Location") != authInstance.LinkLogin() {
+		t.Fatalf("expected a redirect to the login, got %d", recorder.Code)
+	}
+	if lookups != 0 {
+		t.Fatalf("expected no lookups without a user, got %d", lookups)
+	}
+}
+
+func TestRequirePermissions_Api(t *testing.T) {
+	lookups := 0
+	authInstance := newAuthorizationAuthForTest(nil, []string{"orders:read", "orders:write"}, &lookups)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		allowed bool
+	}{
+		{"any of", RequireAnyPermission(next, authInstance, "orders:delete", "orders:read"), true},
+		{"none of", RequireAnyPermission(next, authInstance, "orders:delete"), false},
+		{"all of", RequireAllPermissions(next, authInstance, "orders:read", "orders:write"), true},
+		{"not all of", RequireAllPermissions(next, authInstance, "orders:read", "orders:delete"), false},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		ApiAuthOrErrorMiddleware(tt.handler, authInstance).ServeHTTP(recorder, newAuthorizationRequestForTest())
+
+		if tt.allowed && recorder.Body.String() != "ok" {
+			t.Errorf("%s: expected to pass, got %d %s", tt.name, recorder.Code, recorder.Body.String())
+		}
+		if !tt.allowed && (recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), `"status":"forbidden"`)) {
+			t.Errorf("%s: expected a forbidden JSON response, got %d %s", tt.name, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestRequireRole_LooksUpOncePerRequest(t *testing.T) {
+	lookups := 0
+	authInstance := newAuthorizationAuthForTest([]string{"admin"}, []string{"orders:read"}, &lookups)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := UserRoles(r, authInstance); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("ok"))
+	})
+
+	handler := RequireRole(next, authInstance, "admin")
+	handler = RequireRole(handler, authInstance, "admin")
+	handler = RequireAnyPermission(handler, authInstance, "orders:read")
+	handler = RequireAllPermissions(handler, authInstance, "orders:read")
+
+	for range 2 {
+		recorder := httptest.NewRecorder()
+		ApiAuthOrErrorMiddleware(handler, authInstance).ServeHTTP(recorder, newAuthorizationRequestForTest())
+		if recorder.Body.String() != "ok" {
+			t.Fatalf("expected to pass, got %d %s", recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// One role and one permission lookup per request
+	if lookups != 4 {
+		t.Fatalf("expected 4 lookups over 2 requests, got %d", lookups)
+	}
+}
+
+func TestRequireRole_LookupErrorIsForbidden(t *testing.T) {
+	lookups := 0
+	authInstance := newAuthorizationAuthForTest(nil, nil, &lookups)
+	authInstance.SetFuncUserRoles(func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		return []string{"admin"}, errors.New("database is down")
+	})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not be called when the lookup fails")
+	})
+
+	recorder := httptest.NewRecorder()
+	ApiAuthOrErrorMiddleware(RequireRole(next, authInstance, "admin"), authInstance).ServeHTTP(recorder, newAuthorizationRequestForTest())
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected a failed lookup to be forbidden, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	authInstance.SetFuncUserRoles(nil)
+	recorder = httptest.NewRecorder()
+	ApiAuthOrErrorMiddleware(RequireRole(next, authInstance, "admin"), authInstance).ServeHTTP(recorder, newAuthorizationRequestForTest())
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected a missing lookup to be forbidden, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dracory/auth/types"
+)
+
+type apiRequestKey struct{}
+
+// contextWithPrincipal appends the principal and its user ID to the context
+// of the request, together with the cache for the authorization lookups.
+// The roles and permissions of the principal are only looked up when they
+// are first used.
+func contextWithPrincipal(r *http.Request, a types.AuthSharedInterface, principal types.Principal) context.Context {
+	ctx := context.WithValue(r.Context(), types.AuthenticatedUserID{}, principal.UserID)
+	ctx = context.WithValue(ctx, authorizationCacheKey{}, &authorizationCache{userID: principal.UserID})
+
+	lookupRequest := r.WithContext(ctx)
+	principal = principal.WithAuthorizationLookups(
+		func() ([]string, error) { return UserRoles(lookupRequest, a) },
+		func() ([]string, error) { return UserPermissions(lookupRequest, a) },
+	)
+
+	return types.ContextWithPrincipal(ctx, principal)
+}
+
+// contextWithApiRequest marks the request as an API request, so the
+// authorization middlewares answer it with JSON rather than with a page.
+func contextWithApiRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiRequestKey{}, true)
+}
+
+func isApiRequest(r *http.Request) bool {
+	isApi, _ := r.Context().Value(apiRequestKey{}).(bool)
+	return isApi
+}
+
+func authenticatedUserID(r *http.Request) string {
+	if principal := types.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.UserID
+	}
+	userID, _ := r.Context().Value(types.AuthenticatedUserID{}).(string)
+	return userID
+}
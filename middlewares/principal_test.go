@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestWebAuthOrRedirectMiddleware_AppendsPrincipal(t *testing.T) {
+	authInstance := testutils.NewAuthSharedForTest()
+	testutils.SetUseCookiesForTest(authInstance, true)
+
+	now := time.Now()
+	store := testutils.NewSessionStore()
+	store.Sessions["token-1"] = types.Session{
+		ID:              "token-1",
+		UserID:          "user-1",
+		CreatedAt:       now,
+		LastSeenAt:      now,
+		AuthMethod:      types.AuthMethodPasskey,
+		MFALevel:        types.MFALevelMultiFactor,
+		AuthenticatedAt: now.Add(-time.Minute),
+		ImpersonatorID:  "admin-1",
+	}
+	authInstance.SetSessionStore(store)
+
+	lookups := 0
+	authInstance.SetFuncUserRoles(func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		lookups++
+		return []string{"admin"}, nil
+	})
+
+	var principal *types.Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = types.PrincipalFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "token-1"})
+	WebAuthOrRedirectMiddleware(RequireRole(next, authInstance, "admin"), authInstance).ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal == nil {
+		t.Fatal("expected the principal in the context")
+	}
+	if principal.UserID != "user-1" || principal.SessionID != "token-1" || principal.AuthMethod != types.AuthMethodPasskey || principal.MFALevel != types.MFALevelMultiFactor {
+		t.Fatalf("expected the principal of the session, got %+v", principal)
+	}
+	if !principal.AuthenticatedAt.Equal(now.Add(-time.Minute)) || !principal.IsImpersonated() {
+		t.Fatalf("expected the sign in time and impersonator of the session, got %+v", principal)
+	}
+	if roles, err := principal.Roles(); err != nil || !slices.Equal(roles, []string{"admin"}) || principal.Scopes != nil {
+		t.Fatalf("expected the roles without scopes, got %v %+v (%v)", roles, principal, err)
+	}
+	if !principal.HasRole("admin") || principal.HasRole("owner") {
+		t.Fatal("expected the principal to have the admin role only")
+	}
+	if lookups != 1 {
+		t.Fatalf("expected the roles to be looked up once, got %d", lookups)
+	}
+}
+
+func TestWebAuthOrRedirectMiddleware_LooksUpRolesOnFirstUse(t *testing.T) {
+	authInstance := testutils.NewAuthSharedForTest()
+	testutils.SetUseCookiesForTest(authInstance, true)
+
+	store := testutils.NewSessionStore()
+	store.Sessions["token-1"] = types.Session{ID: "token-1", UserID: "user-1", CreatedAt: time.Now(), LastSeenAt: time.Now()}
+	authInstance.SetSessionStore(store)
+
+	lookups := 0
+	authInstance.SetFuncUserRoles(func(ctx context.Context, userID string, options types.UserAuthOptions) ([]string, error) {
+		lookups++
+		return []string{"admin"}, nil
+	})
+
+	var principal *types.Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = types.PrincipalFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "token-1"})
+	WebAuthOrRedirectMiddleware(next, authInstance).ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal == nil {
+		t.Fatal("expected the principal in the context")
+	}
+	if lookups != 0 {
+		t.Fatalf("expected no lookup before the roles are used, got %d", lookups)
+	}
+
+	if !principal.HasRole("admin") || !principal.HasRole("admin") {
+		t.Fatal("expected the principal to have the admin role")
+	}
+	if lookups != 1 {
+		t.Fatalf("expected the roles to be looked up once, got %d", lookups)
+	}
+	if principal.HasPermission("orders:read") {
+		t.Fatal("expected no permissions without FuncUserPermissions")
+	}
+}
+
+func TestApiAuthOrErrorMiddleware_ApiKeyPrincipal(t *testing.T) {
+	authInstance := testutils.NewAuthSharedForTest()
+	config := &types.ApiKeyConfig{Store: testutils.NewApiKeyStore(), Scopes: []string{"orders:read"}}
+	authInstance.SetApiKeys(config)
+
+	token, _, err := core.ApiKeyCreate(context.Background(), config, "user-1", "CI", []string{"orders:read"}, time.Time{}, types.UserAuthOptions{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var principal *types.Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = types.PrincipalFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	ApiAuthOrErrorMiddleware(next, authInstance).ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal == nil || principal.UserID != "user-1" || principal.AuthMethod != types.AuthMethodApiKey {
+		t.Fatalf("expected the principal of the key, got %+v", principal)
+	}
+	if principal.SessionID != "" || !principal.AuthenticatedAt.IsZero() || !slices.Equal(principal.Scopes, []string{"orders:read"}) {
+		t.Fatalf("expected the key scopes without a session, got %+v", principal)
+	}
+}
@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dracory/api"
+	"github.com/dracory/auth/internal/ui/page_forbidden"
+	"github.com/dracory/auth/types"
+)
+
+// RequireRecentAuth allows the request when the user proved their identity
+// within maxAge, e.g. before changing the email address or deleting the
+// account, even though their session is still valid.
+//
+// It goes inside WebAuthOrRedirectMiddleware or ApiAuthOrErrorMiddleware.
+// Web requests without a recent sign in are redirected to the
+// re-authentication page, which returns to the requested URL afterwards.
+// API requests get a 403 JSON response, and should send the user through
+// the re-authentication API first. Sessions restored from remember-me or
+// refresh tokens, and API keys, never count as recent.
+//
+// When the auth has neither a SessionStore nor JWT access tokens every
+// request is denied like by RequireRole, and the misconfiguration is logged:
+// the legacy callbacks do not record when the user signed in, and the
+// re-authentication routes are not registered without it.
+func RequireRecentAuth(next http.Handler, a types.AuthSharedInterface, maxAge time.Duration) http.Handler {
+	supported := a.GetSessionStore() != nil || a.GetJWT() != nil
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := types.PrincipalFromContext(r.Context())
+
+		// Without an auth middleware in front there is no user to check
+		if principal == nil || principal.UserID == "" {
+			http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
+			return
+		}
+
+		if !supported {
+			if a.GetLogger() != nil {
+				a.GetLogger().Error("RequireRecentAuth requires a SessionStore or JWT access tokens")
+			}
+			if isApiRequest(r) {
+				api.RespondWithStatusCode(w, r, api.Forbidden("recent authentication is not available"), http.StatusForbidden)
+				return
+			}
+			page_forbidden.PageForbiddenWithAuth(w, r, a)
+			return
+		}
+
+		if !principal.AuthenticatedAt.IsZero() && time.Since(principal.AuthenticatedAt) <= maxAge {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if isApiRequest(r) {
+			api.RespondWithStatusCode(w, r, api.Forbidden("recent authentication required"), http.StatusForbidden)
+			return
+		}
+
+		back := url.Values{"back": {r.URL.RequestURI()}}
+		http.Redirect(w, r, a.LinkReauth()+"?"+back.Encode(), http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+// newRecentAuthAuthForTest returns an auth instance where the "valid" token
+// belongs to a session of user-1 signed in at authenticatedAt
+func newRecentAuthAuthForTest(authenticatedAt time.Time) types.AuthSharedInterface {
+	authInstance := testutils.NewAuthSharedForTest()
+	testutils.SetUseCookiesForTest(authInstance, true)
+	testutils.SetLoginURLForTest(authInstance, "/auth/login")
+
+	now := time.Now()
+	store := testutils.NewSessionStore()
+	store.Sessions["valid"] = types.Session{
+		ID:              "valid",
+		UserID:          "user-1",
+		CreatedAt:       now.Add(-time.Hour),
+		LastSeenAt:      now,
+		AuthMethod:      types.AuthMethodPassword,
+		MFALevel:        types.MFALevelSingleFactor,
+		AuthenticatedAt: authenticatedAt,
+	}
+	authInstance.SetSessionStore(store)
+
+	return authInstance
+}
+
+func TestRequireRecentAuth_Web(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	authInstance := newRecentAuthAuthForTest(time.Now().Add(-time.Minute))
+	handler := WebAuthOrRedirectMiddleware(RequireRecentAuth(next, authInstance, 5*time.Minute), authInstance)
+
+	req := httptest.NewRequest(http.MethodGet, "/settings/email?tab=1", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "valid"})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "ok" {
+		t.Fatalf("expected a recent sign in to pass, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Stale sign ins, and sessions restored without one, are sent to re-authenticate
+	for _, authenticatedAt := range []time.Time{time.Now().Add(-time.Hour), {}} {
+		authInstance := newRecentAuthAuthForTest(authenticatedAt)
+		handler := WebAuthOrRedirectMiddleware(RequireRecentAuth(next, authInstance, 5*time.Minute), authInstance)
+
+		req := httptest.NewRequest(http.MethodGet, "/settings/email?tab=1", nil)
+		req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "valid"})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		expected := "http://localhost/auth/reauth?back=%2Fsettings%2Femail%3Ftab%3D1"
+		if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != expected {
+			t.Fatalf("expected a redirect to %s, got %d %q", expected, recorder.Code, recorder.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRequireRecentAuth_Api(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	authInstance := newRecentAuthAuthForTest(time.Now().Add(-time.Hour))
+	handler := ApiAuthOrErrorMiddleware(RequireRecentAuth(next, authInstance, 5*time.Minute), authInstance)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/account/delete", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "valid"})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, `"status":"forbidden"`) || !strings.Contains(body, "recent authentication required") {
+		t.Fatalf("expected the recent authentication error, got %s", body)
+	}
+}
+
+func TestRequireRecentAuth_WithoutUser(t *testing.T) {
+	authInstance := newRecentAuthAuthForTest(time.Now())
+
+	recorder := httptest.NewRecorder()
+	RequireRecentAuth(http.NotFoundHandler(), authInstance, time.Minute).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/settings", nil))
+
+	if recorder.Code != http.StatusTemporaryRedirect || recorder.Header().Get("Location") != "/auth/login" {
+		t.Fatalf("expected a redirect to login, got %d %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+}
+
+func TestRequireRecentAuth_DeniesWithLegacyCallbacks(t *testing.T) {
+	authInstance := testutils.NewAuthSharedForTest()
+	testutils.SetUseCookiesForTest(authInstance, true)
+	testutils.SetFuncUserFindByAuthTokenForTest(authInstance, func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+		if token == "valid" {
+			return "user-1", nil
+		}
+		return "", nil
+	})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not be called without a session store or JWT")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/account/delete", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "valid"})
+	recorder := httptest.NewRecorder()
+	WebAuthOrRedirectMiddleware(RequireRecentAuth(next, authInstance, time.Minute), authInstance).ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), "You do not have permission to access this page") {
+		t.Fatalf("expected the forbidden page, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/account/delete", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "valid"})
+	recorder = httptest.NewRecorder()
+	ApiAuthOrErrorMiddleware(RequireRecentAuth(next, authInstance, time.Minute), authInstance).ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), `"status":"forbidden"`) {
+		t.Fatalf("expected 403, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
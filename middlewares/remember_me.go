@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/dracory/auth/internal/core"
+	"github.com/dracory/auth/types"
+)
+
+// rememberMePrincipal signs the user in again from the remember-me cookie,
+// returning nil when there is none or it is not valid.
+func rememberMePrincipal(w http.ResponseWriter, r *http.Request, a types.AuthSharedInterface, options types.UserAuthOptions) *types.Principal {
+	principal, err := core.RememberMeLogin(w, r, a, options)
+	if err != nil {
+		if logger := a.GetLogger(); logger != nil {
+			logger.Error("remember me login failed", "error", err)
+		}
+		return nil
+	}
+
+	if principal == nil || principal.UserID == "" {
+		return nil
+	}
+
+	return principal
+}
@@ -1,7 +1,6 @@
 package middlewares
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/dracory/auth/types"
@@ -9,33 +8,45 @@ import (
 	"github.com/dracory/req"
 )
 
-// WebAppendUserIdIfExistsMiddleware appends the user ID to the context
-// if an authentication token exists in the requests. This middleware does
-// not have a side effect like for instance redirecting to the login
-// endpoint. This is why it is important to be added to places which
-// can be used by both guests and users (i.e. website pages), where authenticated
-// users may have some extra privileges
+// WebAppendUserIdIfExistsMiddleware appends the user ID and the
+// types.Principal to the context if an authentication token exists in the
+// requests. This middleware does not have a side effect like for instance
+// redirecting to the login endpoint. This is why it is important to be added
+// to places which can be used by both guests and users (i.e. website pages),
+// where authenticated users may have some extra privileges
+//
+// When remember me is configured and the session is gone, the remember-me
+// cookie is exchanged for a fresh session.
 //
 // If you need to redirect the user if authentication token not found,
 // or the user does not exist, take a look at the WebAuthOrRedirectMiddleware
 // middleware, which does exactly that
 func WebAppendUserIdIfExistsMiddleware(next http.Handler, a types.AuthSharedInterface) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		options := types.UserAuthOptions{
+			UserIp:    req.GetIP(r),
+			UserAgent: r.UserAgent(),
+		}
 
 		authToken := utils.AuthTokenRetrieve(r, a.GetUseCookies())
 
+		var principal *types.Principal
 		if authToken != "" {
-			userID, err := a.GetFuncUserFindByAuthToken()(r.Context(), authToken, types.UserAuthOptions{
-				UserIp:    req.GetIP(r),
-				UserAgent: r.UserAgent(),
-			})
+			var err error
+			principal, err = authTokenPrincipal(w, r, a, authToken, options)
 
 			if err != nil {
 				next.ServeHTTP(w, r)
 				return
 			}
+		}
+
+		if principal == nil {
+			principal = rememberMePrincipal(w, r, a, options)
+		}
 
-			ctx := context.WithValue(r.Context(), types.AuthenticatedUserID{}, userID)
+		if principal != nil {
+			ctx := contextWithPrincipal(r, a, *principal)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
@@ -1,7 +1,6 @@
 package middlewares
 
 import (
-	"context"
 	"net/http"
 
 	"github.com/dracory/auth/types"
@@ -11,38 +10,49 @@ import (
 
 // WebAuthOrRedirectMiddleware checks that an authentication token
 // exists, and then finds the userID based on it. On success appends
-// the user ID to the context. On failure it will redirect the user
-// to the login endpoint to reauthenticate.
+// the user ID to the context, together with the types.Principal of the
+// request. On failure it will redirect the user to the login endpoint to
+// reauthenticate.
+//
+// Sessions past their lifetime or idle timeout are rejected, and activity
+// extends the idle window of the session, re-issuing the auth cookie.
+//
+// When remember me is configured and the session is gone, the remember-me
+// cookie is exchanged for a fresh session before giving up.
 //
 // If you need to only find if the authentication token is successful
 // without redirection please use the WebAppendUserIdIfExistsMiddleware
 // which does exactly that without side effects
 func WebAuthOrRedirectMiddleware(next http.Handler, a types.AuthSharedInterface) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		options := types.UserAuthOptions{
+			UserIp:    req.GetIP(r),
+			UserAgent: r.UserAgent(),
+		}
 
 		authToken := utils.AuthTokenRetrieve(r, a.GetUseCookies())
 
-		if authToken == "" {
-			http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
-			return
-		}
+		var principal *types.Principal
+		if authToken != "" {
+			var err error
+			principal, err = authTokenPrincipal(w, r, a, authToken, options)
 
-		userID, err := a.GetFuncUserFindByAuthToken()(r.Context(), authToken, types.UserAuthOptions{
-			UserIp:    req.GetIP(r),
-			UserAgent: r.UserAgent(),
-		})
+			if err != nil {
+				http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
+				return
+			}
+		}
 
-		if err != nil {
-			http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
-			return
+		if principal == nil {
+			principal = rememberMePrincipal(w, r, a, options)
 		}
 
-		if userID == "" {
+		if principal == nil {
 			http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), types.AuthenticatedUserID{}, userID)
+		ctx := contextWithPrincipal(r, a, *principal)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -34,14 +34,24 @@ func NewPasswordlessAuth(config types.ConfigPasswordless) (types.AuthPasswordles
 	}
 	auth.funcTemporaryKeyGet = config.FuncTemporaryKeyGet
 	auth.funcTemporaryKeySet = config.FuncTemporaryKeySet
+	auth.funcTemporaryKeyDelete = config.FuncTemporaryKeyDelete
 	auth.funcUserLogout = config.FuncUserLogout
 	auth.funcUserFindByAuthToken = config.FuncUserFindByAuthToken
 	auth.funcUserStoreAuthToken = config.FuncUserStoreAuthToken
+	auth.sessionStore = config.SessionStore
+	auth.sessionLifetime = config.SessionLifetime
+	auth.sessionIdleTimeout = config.SessionIdleTimeout
 	auth.passwordlessFuncEmailTemplateLoginCode = config.FuncEmailTemplateLoginCode
 	// auth.passwordlessFuncEmailTemplateRegisterCode = config.FuncEmailTemplateRegisterCode
 	auth.passwordlessFuncEmailSend = config.FuncEmailSend
 	auth.passwordlessFuncUserFindByEmail = config.FuncUserFindByEmail
 	auth.passwordlessFuncUserRegister = config.FuncUserRegister
+	auth.passwordlessDisableLoginCodeBinding = config.DisableLoginCodeBinding
+	auth.passwordlessMagicLink = config.MagicLink
+	auth.passwordlessChannel = config.Channel
+	auth.passwordlessFuncUserFindByPhone = config.FuncUserFindByPhone
+	auth.passwordlessFuncSmsSend = config.FuncSmsSend
+	auth.passwordlessFuncSmsTemplateLoginCode = config.FuncSmsTemplateLoginCode
 
 	// If no user defined email template is set, use default
 	if auth.passwordlessFuncEmailTemplateLoginCode == nil {
@@ -50,6 +60,13 @@ func NewPasswordlessAuth(config types.ConfigPasswordless) (types.AuthPasswordles
 		}
 	}
 
+	// If no user defined text message template is set, use default
+	if auth.passwordlessFuncSmsTemplateLoginCode == nil {
+		auth.passwordlessFuncSmsTemplateLoginCode = func(ctx context.Context, phone string, code string, options types.UserAuthOptions) string {
+			return code + " is your login code. Do not share it with anyone."
+		}
+	}
+
 	// If no user defined email template is set, use default
 	if auth.passwordlessFuncEmailTemplateRegisterCode == nil {
 		auth.passwordlessFuncEmailTemplateRegisterCode = func(ctx context.Context, email string, code string, options types.UserAuthOptions) string {
@@ -60,24 +77,49 @@ func NewPasswordlessAuth(config types.ConfigPasswordless) (types.AuthPasswordles
 	// Initialize rate limiting
 	auth.disableRateLimit = config.DisableRateLimit
 	auth.funcCheckRateLimit = config.FuncCheckRateLimit
+	auth.funcRateLimitReset = config.FuncRateLimitReset
 
-	// If rate limiting is not disabled and no custom function provided, use default in-memory rate limiter
-	if !auth.disableRateLimit && auth.funcCheckRateLimit == nil {
-		// Use config values or defaults
-		maxAttempts := config.MaxLoginAttempts
-		if maxAttempts == 0 {
-			maxAttempts = 5 // Default: 5 attempts
-		}
+	// Use config values or defaults
+	maxAttempts := config.MaxLoginAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 5 // Default: 5 attempts
+	}
 
-		lockoutDuration := config.LockoutDuration
-		if lockoutDuration == 0 {
-			lockoutDuration = 15 * time.Minute // Default: 15 minutes
-		}
+	lockoutDuration := config.LockoutDuration
+	if lockoutDuration == 0 {
+		lockoutDuration = 15 * time.Minute // Default: 15 minutes
+	}
 
-		auth.rateLimiter = utils.NewInMemoryRateLimiter(maxAttempts, lockoutDuration, lockoutDuration)
+	auth.rateLimitPolicy = types.RateLimitPolicy{
+		Burst:   maxAttempts,
+		Refill:  lockoutDuration / time.Duration(maxAttempts),
+		Lockout: lockoutDuration,
+	}
+	auth.rateLimitPolicies = config.RateLimitPolicies
+
+	// If rate limiting is not disabled and no custom function provided, use the configured or default in-memory rate limiter
+	if !auth.disableRateLimit && auth.funcCheckRateLimit == nil {
+		auth.rateLimiter = config.RateLimiter
+		if auth.rateLimiter == nil {
+			auth.rateLimiter = utils.NewInMemoryRateLimiter(maxAttempts, lockoutDuration, lockoutDuration)
+		}
 	}
 
 	auth.logger = config.Logger
+	auth.webAuthn = config.WebAuthn
+	auth.oidc = config.OIDC
+	auth.jwt = config.JWT
+	auth.refreshToken = config.RefreshToken
+	auth.rememberMe = config.RememberMe
+	auth.authTokenHashing = config.AuthTokenHashing
+	auth.apiKeys = config.ApiKeys
+	auth.accountLockout = config.AccountLockout
+	auth.counterStore = config.CounterStore
+	if auth.counterStore == nil {
+		auth.counterStore = temporaryKeyCounterStore(auth)
+	}
+	auth.funcUserRoles = config.FuncUserRoles
+	auth.funcUserPermissions = config.FuncUserPermissions
 
 	return auth, nil
 }
@@ -102,15 +144,18 @@ func validatePasswordlessConfig(config types.ConfigPasswordless) error {
 		return errors.New("auth: FuncTemporaryKeySet function is required")
 	}
 
-	if config.FuncUserFindByAuthToken == nil {
+	// JWT access tokens are verified locally, so they are not stored
+	if config.JWT == nil && config.SessionStore == nil && config.FuncUserFindByAuthToken == nil {
 		return errors.New("auth: FuncUserFindByAuthToken function is required")
 	}
 
-	if config.FuncUserFindByEmail == nil {
+	byEmail := config.Channel == "" || config.Channel == types.PasswordlessChannelEmail
+
+	if byEmail && config.FuncUserFindByEmail == nil {
 		return errors.New("auth: FuncUserFindByEmail function is required")
 	}
 
-	if config.FuncUserLogout == nil {
+	if config.SessionStore == nil && config.FuncUserLogout == nil {
 		return errors.New("auth: FuncUserLogout function is required")
 	}
 
@@ -118,14 +163,18 @@ func validatePasswordlessConfig(config types.ConfigPasswordless) error {
 		return errors.New("auth: FuncUserRegister function is required")
 	}
 
-	if config.FuncUserStoreAuthToken == nil {
+	if config.JWT == nil && config.SessionStore == nil && config.FuncUserStoreAuthToken == nil {
 		return errors.New("auth: FuncUserStoreToken function is required")
 	}
 
-	if config.FuncEmailSend == nil {
+	if byEmail && config.FuncEmailSend == nil {
 		return errors.New("auth: FuncEmailSend function is required")
 	}
 
+	if err := validatePasswordlessChannel(config); err != nil {
+		return err
+	}
+
 	if config.UseCookies && config.UseLocalStorage {
 		return errors.New("auth: UseCookies and UseLocalStorage cannot be both true")
 	}
@@ -134,5 +183,49 @@ func validatePasswordlessConfig(config types.ConfigPasswordless) error {
 		return errors.New("auth: UseCookies and UseLocalStorage cannot be both false")
 	}
 
+	if err := validateWebAuthnConfig(config.WebAuthn); err != nil {
+		return err
+	}
+
+	if err := validateOIDCConfig(config.OIDC); err != nil {
+		return err
+	}
+
+	if err := validateJWTConfig(config.JWT); err != nil {
+		return err
+	}
+
+	if err := validateRefreshTokenConfig(config.RefreshToken); err != nil {
+		return err
+	}
+
+	if err := validateRememberMeConfig(config.RememberMe, config.UseCookies); err != nil {
+		return err
+	}
+
+	if err := validateAuthTokenHashingConfig(config.AuthTokenHashing, config.JWT); err != nil {
+		return err
+	}
+
+	if err := validateApiKeyConfig(config.ApiKeys); err != nil {
+		return err
+	}
+
+	if err := validateAccountLockoutConfig(config.AccountLockout); err != nil {
+		return err
+	}
+
+	if err := validateRateLimitConfig(config.RateLimiter, config.FuncCheckRateLimit, config.RateLimitPolicies); err != nil {
+		return err
+	}
+
+	if err := validateMagicLinkConfig(config.MagicLink); err != nil {
+		return err
+	}
+
+	if err := validateSessionTimeouts(config.SessionLifetime, config.SessionIdleTimeout, config.SessionStore, config.JWT); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -2,8 +2,11 @@ package auth
 
 import (
 	"context"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/dracory/auth/internal/testutils"
 	"github.com/dracory/auth/types"
 )
 
@@ -232,3 +235,41 @@ func TestNewPasswordlessAuth_UseCookiesAndLocalStorageCannotBeBothTruee(t *testi
 		t.Fatal("Auth SHOULD NOT be NULL, but found NULL")
 	}
 }
+
+func TestRouter_LoginCodesAreBoundByDefault(t *testing.T) {
+	for _, disableBinding := range []bool{false, true} {
+		store := map[string]string{}
+		sentCode := ""
+
+		config := testutils.NewPasswordlessConfigForTest()
+		config.DisableLoginCodeBinding = disableBinding
+		config.FuncTemporaryKeyGet = func(key string) (string, error) { return store[key], nil }
+		config.FuncTemporaryKeySet = func(key string, value string, expiresSeconds int) error {
+			store[key] = value
+			return nil
+		}
+		config.FuncEmailTemplateLoginCode = func(ctx context.Context, email string, code string, options types.UserAuthOptions) string {
+			sentCode = code
+			return code
+		}
+
+		authShared, err := NewPasswordlessAuth(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		login := postForm(authShared, authShared.LinkApiLogin(), url.Values{"email": {"test@test.com"}}, "")
+		if strings.Contains(login.Body.String(), `"flow_id":"`) == disableBinding {
+			t.Fatalf("binding disabled %v: unexpected login response %s", disableBinding, login.Body.String())
+		}
+
+		verify := postForm(authShared, authShared.LinkApiLoginCodeVerify(), url.Values{"verification_code": {sentCode}}, "")
+		body := verify.Body.String()
+		if !disableBinding && !strings.Contains(body, "Flow ID is required field") {
+			t.Fatalf("expected the flow ID to be required by default, got %s", body)
+		}
+		if disableBinding && !strings.Contains(body, `"status":"success"`) {
+			t.Fatalf("expected the unbound code to log the user in, got %s", body)
+		}
+	}
+}
@@ -37,13 +37,24 @@ func NewUsernameAndPasswordAuth(config types.ConfigUsernameAndPassword) (types.A
 	auth.funcLayout = config.FuncLayout
 	auth.funcTemporaryKeyGet = config.FuncTemporaryKeyGet
 	auth.funcTemporaryKeySet = config.FuncTemporaryKeySet
+	auth.funcTemporaryKeyDelete = config.FuncTemporaryKeyDelete
 	auth.funcUserLogin = config.FuncUserLogin
 	auth.funcUserLogout = config.FuncUserLogout
 	auth.funcUserPasswordChange = config.FuncUserPasswordChange
 	auth.funcUserRegister = config.FuncUserRegister
+	auth.funcUserRegisterWithPasswordHash = config.FuncUserRegisterWithPasswordHash
+	auth.funcPasswordHash = config.FuncPasswordHash
 	auth.funcUserFindByAuthToken = config.FuncUserFindByAuthToken
 	auth.funcUserFindByUsername = config.FuncUserFindByUsername
 	auth.funcUserStoreAuthToken = config.FuncUserStoreAuthToken
+	auth.sessionStore = config.SessionStore
+	auth.sessionLifetime = config.SessionLifetime
+	auth.sessionIdleTimeout = config.SessionIdleTimeout
+	auth.funcUserTotpSecretFind = config.FuncUserTotpSecretFind
+	auth.funcUserTotpSecretStore = config.FuncUserTotpSecretStore
+	auth.funcUserRecoveryCodesStore = config.FuncUserRecoveryCodesStore
+	auth.funcUserRecoveryCodeConsume = config.FuncUserRecoveryCodeConsume
+	auth.totpIssuer = config.TotpIssuer
 	auth.passwordStrength = config.PasswordStrength
 	if auth.passwordStrength == nil {
 		auth.passwordStrength = &types.PasswordStrengthConfig{
@@ -57,6 +68,20 @@ func NewUsernameAndPasswordAuth(config types.ConfigUsernameAndPassword) (types.A
 	}
 
 	auth.logger = config.Logger
+	auth.webAuthn = config.WebAuthn
+	auth.oidc = config.OIDC
+	auth.jwt = config.JWT
+	auth.refreshToken = config.RefreshToken
+	auth.rememberMe = config.RememberMe
+	auth.authTokenHashing = config.AuthTokenHashing
+	auth.apiKeys = config.ApiKeys
+	auth.accountLockout = config.AccountLockout
+	auth.counterStore = config.CounterStore
+	if auth.counterStore == nil {
+		auth.counterStore = temporaryKeyCounterStore(auth)
+	}
+	auth.funcUserRoles = config.FuncUserRoles
+	auth.funcUserPermissions = config.FuncUserPermissions
 
 	// If no user defined layout is set, use default
 	if auth.funcLayout == nil {
@@ -81,21 +106,32 @@ func NewUsernameAndPasswordAuth(config types.ConfigUsernameAndPassword) (types.A
 	// Initialize rate limiting
 	auth.disableRateLimit = config.DisableRateLimit
 	auth.funcCheckRateLimit = config.FuncCheckRateLimit
+	auth.funcRateLimitReset = config.FuncRateLimitReset
 
-	// If rate limiting is not disabled and no custom function provided, use default in-memory rate limiter
-	if !auth.disableRateLimit && auth.funcCheckRateLimit == nil {
-		// Use config values or defaults
-		maxAttempts := config.MaxLoginAttempts
-		if maxAttempts == 0 {
-			maxAttempts = 5 // Default: 5 attempts
-		}
+	// Use config values or defaults
+	maxAttempts := config.MaxLoginAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 5 // Default: 5 attempts
+	}
 
-		lockoutDuration := config.LockoutDuration
-		if lockoutDuration == 0 {
-			lockoutDuration = 15 * time.Minute // Default: 15 minutes
-		}
+	lockoutDuration := config.LockoutDuration
+	if lockoutDuration == 0 {
+		lockoutDuration = 15 * time.Minute // Default: 15 minutes
+	}
 
-		auth.rateLimiter = utils.NewInMemoryRateLimiter(maxAttempts, lockoutDuration, lockoutDuration)
+	auth.rateLimitPolicy = types.RateLimitPolicy{
+		Burst:   maxAttempts,
+		Refill:  lockoutDuration / time.Duration(maxAttempts),
+		Lockout: lockoutDuration,
+	}
+	auth.rateLimitPolicies = config.RateLimitPolicies
+
+	// If rate limiting is not disabled and no custom function provided, use the configured or default in-memory rate limiter
+	if !auth.disableRateLimit && auth.funcCheckRateLimit == nil {
+		auth.rateLimiter = config.RateLimiter
+		if auth.rateLimiter == nil {
+			auth.rateLimiter = utils.NewInMemoryRateLimiter(maxAttempts, lockoutDuration, lockoutDuration)
+		}
 	}
 
 	// Initialize CSRF protection
@@ -150,7 +186,8 @@ func validateUsernameAndPasswordConfig(config types.ConfigUsernameAndPassword) e
 		return errors.New("auth: FuncTemporaryKeySet function is required")
 	}
 
-	if config.FuncUserFindByAuthToken == nil {
+	// JWT access tokens are verified locally, so they are not stored
+	if config.JWT == nil && config.SessionStore == nil && config.FuncUserFindByAuthToken == nil {
 		return errors.New("auth: FuncUserFindByAuthToken function is required")
 	}
 
@@ -162,15 +199,15 @@ func validateUsernameAndPasswordConfig(config types.ConfigUsernameAndPassword) e
 		return errors.New("auth: FuncUserLogin function is required")
 	}
 
-	if config.FuncUserLogout == nil {
+	if config.SessionStore == nil && config.FuncUserLogout == nil {
 		return errors.New("auth: FuncUserLogout function is required")
 	}
 
-	if config.EnableRegistration && config.FuncUserRegister == nil {
+	if config.EnableRegistration && config.FuncUserRegister == nil && config.FuncUserRegisterWithPasswordHash == nil {
 		return errors.New("auth: FuncUserRegister function is required")
 	}
 
-	if config.FuncUserStoreAuthToken == nil {
+	if config.JWT == nil && config.SessionStore == nil && config.FuncUserStoreAuthToken == nil {
 		return errors.New("auth: FuncUserStoreToken function is required")
 	}
 
@@ -186,5 +223,45 @@ func validateUsernameAndPasswordConfig(config types.ConfigUsernameAndPassword) e
 		return errors.New("auth: UseCookies and UseLocalStorage cannot be both false")
 	}
 
+	if err := validateWebAuthnConfig(config.WebAuthn); err != nil {
+		return err
+	}
+
+	if err := validateOIDCConfig(config.OIDC); err != nil {
+		return err
+	}
+
+	if err := validateJWTConfig(config.JWT); err != nil {
+		return err
+	}
+
+	if err := validateRefreshTokenConfig(config.RefreshToken); err != nil {
+		return err
+	}
+
+	if err := validateRememberMeConfig(config.RememberMe, config.UseCookies); err != nil {
+		return err
+	}
+
+	if err := validateAuthTokenHashingConfig(config.AuthTokenHashing, config.JWT); err != nil {
+		return err
+	}
+
+	if err := validateApiKeyConfig(config.ApiKeys); err != nil {
+		return err
+	}
+
+	if err := validateAccountLockoutConfig(config.AccountLockout); err != nil {
+		return err
+	}
+
+	if err := validateRateLimitConfig(config.RateLimiter, config.FuncCheckRateLimit, config.RateLimitPolicies); err != nil {
+		return err
+	}
+
+	if err := validateSessionTimeouts(config.SessionLifetime, config.SessionIdleTimeout, config.SessionStore, config.JWT); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/dracory/auth/internal/testutils"
 	"github.com/dracory/auth/types"
 )
 
@@ -342,3 +343,16 @@ func TestNewUsernameAndPasswordAuth_CSRFEnabledWithSecretSucceeds(t *testing.T)
 		t.Fatal("csrfSecret SHOULD be 'super-secret', but found ", "'"+concrete.csrfSecret+"'")
 	}
 }
+
+func TestNewUsernameAndPasswordAuth_WebAuthnRPIDIsRequired(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.WebAuthn = &types.WebAuthnConfig{Origins: []string{"https://example.com"}}
+
+	_, err := NewUsernameAndPasswordAuth(config)
+	if err == nil {
+		t.Fatal("Error SHOULD NOT BE NULL")
+	}
+	if err.Error() != "auth: WebAuthn RPID is required" {
+		t.Fatal("Error SHOULD BE 'auth: WebAuthn RPID is required', but found ", "'"+err.Error()+"'")
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+	"github.com/dracory/str"
+)
+
+// oidcProviderNameChars are the characters allowed in provider names, which
+// are used in the login/{name} and callback/{name} routes.
+const oidcProviderNameChars = "abcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// validateOIDCConfig validates the optional OpenID Connect configuration
+// shared by both authentication flows.
+func validateOIDCConfig(config *types.OIDCConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Providers) == 0 {
+		return errors.New("auth: OIDC Providers are required")
+	}
+
+	if config.FuncUserFindOrCreateByExternalIdentity == nil {
+		return errors.New("auth: OIDC FuncUserFindOrCreateByExternalIdentity function is required")
+	}
+
+	names := map[string]bool{}
+	for _, provider := range config.Providers {
+		if provider.Name == "" || !str.ContainsOnly(provider.Name, oidcProviderNameChars) {
+			return errors.New("auth: OIDC provider Name is required and may only contain a-z, 0-9, - and _")
+		}
+
+		if names[provider.Name] {
+			return errors.New("auth: OIDC provider Name must be unique, found " + provider.Name + " twice")
+		}
+		names[provider.Name] = true
+
+		if provider.Issuer == "" {
+			return errors.New("auth: OIDC provider " + provider.Name + " Issuer is required")
+		}
+
+		if provider.ClientID == "" {
+			return errors.New("auth: OIDC provider " + provider.Name + " ClientID is required")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateOIDCConfig(t *testing.T) {
+	findOrCreate := func(ctx context.Context, identity types.ExternalIdentity, options types.UserAuthOptions) (string, error) {
+		return "", nil
+	}
+	provider := types.OIDCProvider{Name: "google", Issuer: "https://accounts.google.com", ClientID: "client"}
+
+	tests := []struct {
+		name     string
+		config   *types.OIDCConfig
+		expected string
+	}{
+		{"nil config", nil, ""},
+		{"valid", &types.OIDCConfig{Providers: []types.OIDCProvider{provider}, FuncUserFindOrCreateByExternalIdentity: findOrCreate}, ""},
+		{"no providers", &types.OIDCConfig{FuncUserFindOrCreateByExternalIdentity: findOrCreate}, "auth: OIDC Providers are required"},
+		{"no callback", &types.OIDCConfig{Providers: []types.OIDCProvider{provider}}, "auth: OIDC FuncUserFindOrCreateByExternalIdentity function is required"},
+		{"invalid name", &types.OIDCConfig{Providers: []types.OIDCProvider{{Name: "Google/1", Issuer: "x", ClientID: "y"}}, FuncUserFindOrCreateByExternalIdentity: findOrCreate}, "auth: OIDC provider Name is required and may only contain a-z, 0-9, - and _"},
+		{"duplicate name", &types.OIDCConfig{Providers: []types.OIDCProvider{provider, provider}, FuncUserFindOrCreateByExternalIdentity: findOrCreate}, "auth: OIDC provider Name must be unique, found google twice"},
+		{"no issuer", &types.OIDCConfig{Providers: []types.OIDCProvider{{Name: "google", ClientID: "y"}}, FuncUserFindOrCreateByExternalIdentity: findOrCreate}, "auth: OIDC provider google Issuer is required"},
+		{"no client ID", &types.OIDCConfig{Providers: []types.OIDCProvider{{Name: "google", Issuer: "x"}}, FuncUserFindOrCreateByExternalIdentity: findOrCreate}, "auth: OIDC provider google ClientID is required"},
+	}
+
+	for _, tt := range tests {
+		err := validateOIDCConfig(tt.config)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestRouter_OIDCSignIn(t *testing.T) {
+	provider, err := testutils.NewOIDCProvider("client-1", "secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Server.Close()
+
+	store := map[string]string{}
+	storedTokens := map[string]string{}
+	var identity types.ExternalIdentity
+
+	config := testutils.NewPasswordlessConfigForTest()
+	config.FuncTemporaryKeyGet = func(key string) (string, error) {
+		value, ok := store[key]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return value, nil
+	}
+	config.FuncTemporaryKeySet = func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	}
+	config.FuncUserStoreAuthToken = func(ctx context.Context, token string, userID string, options types.UserAuthOptions) error {
+		storedTokens[token] = userID
+		return nil
+	}
+	config.OIDC = &types.OIDCConfig{
+		Providers: []types.OIDCProvider{provider.Config("test")},
+		FuncUserFindOrCreateByExternalIdentity: func(ctx context.Context, id types.ExternalIdentity, options types.UserAuthOptions) (string, error) {
+			identity = id
+			return "user-1", nil
+		},
+	}
+
+	authShared, err := NewPasswordlessAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start the sign-in
+	req := httptest.NewRequest(http.MethodGet, authShared.LinkOIDCLogin("test"), nil)
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusFound || !strings.HasPrefix(recorder.Header().Get("Location"), provider.Issuer()+"/authorize?") {
+		t.Fatalf("expected redirect to the provider, got %d %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+	stateCookie := recorder.Result().Cookies()[0]
+
+	// Sign in at the provider
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	callbackURL := response.Header.Get("Location")
+	if !strings.HasPrefix(callbackURL, authShared.LinkOIDCCallback("test")+"?") {
+		t.Fatalf("expected provider to redirect to the callback, got %q", callbackURL)
+	}
+
+	// Complete the sign-in
+	req = httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	req.AddCookie(stateCookie)
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != authShared.LinkRedirectOnSuccess() {
+		t.Fatalf("expected redirect on success, got %d %q: %s", recorder.Code, recorder.Header().Get("Location"), recorder.Body.String())
+	}
+
+	if identity.Subject != provider.Subject || identity.Provider != "test" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	authToken := ""
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == CookieName {
+			authToken = cookie.Value
+		}
+	}
+	if authToken == "" || storedTokens[authToken] != "user-1" {
+		t.Fatalf("expected auth cookie with a stored token for user-1, got %q (%v)", authToken, storedTokens)
+	}
+}
+
+func TestRouter_OIDCRoutesRequireConfig(t *testing.T) {
+	authShared, err := NewUsernameAndPasswordAuth(testutils.NewUsernameAndPasswordConfigForTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, authShared.LinkOIDCLogin("test"), nil)
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusTemporaryRedirect || recorder.Header().Get("Location") != authShared.LinkLogin() {
+		t.Fatalf("expected redirect to login without OIDC configured, got %d %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// validatePasswordlessChannel validates the callbacks required by the channel
+// the passwordless login codes are sent through. The email callbacks are
+// checked with the other required callbacks; registration and magic links
+// are only available by email.
+func validatePasswordlessChannel(config types.ConfigPasswordless) error {
+	switch config.Channel {
+	case "", types.PasswordlessChannelEmail:
+		return nil
+	case types.PasswordlessChannelSms:
+	default:
+		return errors.New("auth: unknown passwordless Channel " + string(config.Channel))
+	}
+
+	if config.FuncUserFindByPhone == nil {
+		return errors.New("auth: FuncUserFindByPhone function is required")
+	}
+
+	if config.FuncSmsSend == nil {
+		return errors.New("auth: FuncSmsSend function is required")
+	}
+
+	if config.EnableRegistration {
+		return errors.New("auth: EnableRegistration is not supported with the sms channel")
+	}
+
+	if config.MagicLink != nil {
+		return errors.New("auth: MagicLink is not supported with the sms channel")
+	}
+
+	return nil
+}
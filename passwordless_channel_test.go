@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidatePasswordlessChannel(t *testing.T) {
+	sms := func(update func(*types.ConfigPasswordless)) types.ConfigPasswordless {
+		config := newSmsConfigForTest(map[string]string{}, func(phone string, message string) {})
+		update(&config)
+		return config
+	}
+
+	tests := []struct {
+		name     string
+		config   types.ConfigPasswordless
+		expected string
+	}{
+		{"default channel", types.ConfigPasswordless{}, ""},
+		{"email channel", types.ConfigPasswordless{Channel: types.PasswordlessChannelEmail}, ""},
+		{"unknown channel", types.ConfigPasswordless{Channel: "fax"}, "auth: unknown passwordless Channel fax"},
+		{"sms channel", sms(func(c *types.ConfigPasswordless) {}), ""},
+		{"sms without user lookup", sms(func(c *types.ConfigPasswordless) { c.FuncUserFindByPhone = nil }), "auth: FuncUserFindByPhone function is required"},
+		{"sms without sender", sms(func(c *types.ConfigPasswordless) { c.FuncSmsSend = nil }), "auth: FuncSmsSend function is required"},
+		{"sms with registration", sms(func(c *types.ConfigPasswordless) { c.EnableRegistration = true }), "auth: EnableRegistration is not supported with the sms channel"},
+		{"sms with magic links", sms(func(c *types.ConfigPasswordless) { c.MagicLink = &types.MagicLinkConfig{} }), "auth: MagicLink is not supported with the sms channel"},
+	}
+
+	for _, tt := range tests {
+		err := validatePasswordlessChannel(tt.config)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestNewPasswordlessAuth_SmsDoesNotRequireEmailCallbacks(t *testing.T) {
+	config := newSmsConfigForTest(map[string]string{}, func(phone string, message string) {})
+	config.FuncUserFindByEmail = nil
+	config.FuncEmailSend = nil
+
+	if _, err := NewPasswordlessAuth(config); err != nil {
+		t.Fatalf("expected the sms channel without email callbacks to be valid, got %v", err)
+	}
+}
+
+func TestRouter_SmsLogin(t *testing.T) {
+	store := map[string]string{}
+	var sentPhone, sentMessage string
+
+	authShared, err := NewPasswordlessAuth(newSmsConfigForTest(store, func(phone string, message string) {
+		sentPhone, sentMessage = phone, message
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := postForm(authShared, authShared.LinkApiLogin(), url.Values{"phone": {"+44 7700 900123"}}, "")
+	body := login.Body.String()
+	if !strings.Contains(body, `"status":"success"`) || !strings.Contains(body, `"flow_id":"`) {
+		t.Fatalf("expected the code to be sent, got %s", body)
+	}
+	if sentPhone != "+447700900123" {
+		t.Fatalf("expected the text message to go to the normalised number, got %q", sentPhone)
+	}
+
+	code := strings.Fields(sentMessage)[0]
+	if len(code) != 6 {
+		t.Fatalf("expected a 6-digit code, got %q", sentMessage)
+	}
+	flowID := body[strings.Index(body, `"flow_id":"`)+len(`"flow_id":"`):]
+	flowID = flowID[:strings.Index(flowID, `"`)]
+
+	if missing := postForm(authShared, authShared.LinkApiLoginCodeVerify(), url.Values{"verification_code": {code}}, ""); !strings.Contains(missing.Body.String(), "Flow ID is required field") {
+		t.Fatalf("expected the flow ID to be required, got %s", missing.Body.String())
+	}
+
+	verify := postForm(authShared, authShared.LinkApiLoginCodeVerify(), url.Values{"verification_code": {code}, "flow_id": {flowID}}, "")
+	if !strings.Contains(verify.Body.String(), `"status":"success"`) {
+		t.Fatalf("expected the code to log the user in, got %s", verify.Body.String())
+	}
+}
+
+func TestRouter_SmsLoginRateLimitedPerPhone(t *testing.T) {
+	config := newSmsConfigForTest(map[string]string{}, func(phone string, message string) {})
+	config.MaxLoginAttempts = 2
+
+	authShared, err := NewPasswordlessAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each request comes from another address, so only the per-phone limit applies
+	phones := []string{"+447700900123", "+44 7700 900123", "0044 (7700) 900-123"}
+	for i, phone := range phones {
+		recorder := postForm(authShared, authShared.LinkApiLogin(), url.Values{"phone": {phone}}, "10.0.0."+string(rune('1'+i)))
+
+		if i < config.MaxLoginAttempts && recorder.Code != http.StatusOK {
+			t.Fatalf("request %d: expected to be allowed, got %d %s", i, recorder.Code, recorder.Body.String())
+		}
+		if i == config.MaxLoginAttempts && recorder.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d: expected the phone number to be rate limited, got %d %s", i, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	other := postForm(authShared, authShared.LinkApiLogin(), url.Values{"phone": {"+447700900456"}}, "10.0.0.9")
+	if other.Code != http.StatusOK {
+		t.Fatalf("expected another phone number to be allowed, got %d %s", other.Code, other.Body.String())
+	}
+}
+
+// newSmsConfigForTest returns a passwordless configuration sending the codes
+// through an in-process fake sender, with the temporary keys kept in store
+func newSmsConfigForTest(store map[string]string, send func(phone string, message string)) types.ConfigPasswordless {
+	config := testutils.NewPasswordlessConfigForTest()
+	config.Channel = types.PasswordlessChannelSms
+	config.FuncTemporaryKeyGet = func(key string) (string, error) { return store[key], nil }
+	config.FuncTemporaryKeySet = func(key string, value string, expiresSeconds int) error {
+		store[key] = value
+		return nil
+	}
+	config.FuncUserFindByPhone = func(ctx context.Context, phone string, options types.UserAuthOptions) (string, error) {
+		if phone == "+447700900123" {
+			return "111", nil
+		}
+		return "", nil
+	}
+	config.FuncSmsSend = func(ctx context.Context, phone string, message string) error {
+		send(phone, message)
+		return nil
+	}
+	return config
+}
+
+// postForm posts the form to the router, from the given client address when set
+func postForm(authShared types.AuthPasswordlessInterface, link string, form url.Values, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if ip != "" {
+		req.Header.Set("X-Forwarded-For", ip)
+	}
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+	return recorder
+}
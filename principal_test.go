@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestPrincipal_PasswordLogin(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = testutils.NewSessionStore()
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	var session *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == CookieName {
+			session = cookie
+		}
+	}
+	if session == nil {
+		t.Fatalf("expected auth cookie, got %s", recorder.Body.String())
+	}
+
+	var principal *types.Principal
+	userID := ""
+	handler := authShared.WebAuthOrRedirectMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = types.PrincipalFromContext(r.Context())
+		userID = authShared.GetCurrentUserID(r)
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(session)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal == nil || userID != "user-1" || principal.UserID != "user-1" {
+		t.Fatalf("expected the principal of user-1, got %+v (%q)", principal, userID)
+	}
+	if principal.SessionID == "" || principal.AuthMethod != types.AuthMethodPassword || principal.MFALevel != types.MFALevelSingleFactor {
+		t.Fatalf("expected a single factor password session, got %+v", principal)
+	}
+	if time.Since(principal.AuthenticatedAt) > time.Minute {
+		t.Fatalf("expected a recent sign in, got %v", principal.AuthenticatedAt)
+	}
+}
+
+func TestGetCurrentUserID_WithoutPrincipal(t *testing.T) {
+	authShared, err := NewUsernameAndPasswordAuth(testutils.NewUsernameAndPasswordConfigForTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Contexts built by hand with only the user ID keep working
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), AuthenticatedUserID{}, "user-2"))
+
+	if userID := authShared.GetCurrentUserID(req); userID != "user-2" {
+		t.Fatalf("expected user-2, got %q", userID)
+	}
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateRateLimitConfig validates the optional rate limiter and endpoint
+// policies shared by both authentication flows.
+func validateRateLimitConfig(
+	rateLimiter types.RateLimiter,
+	funcCheckRateLimit func(ip string, endpoint string) (allowed bool, retryAfter time.Duration, err error),
+	policies map[string]types.RateLimitPolicy,
+) error {
+	if rateLimiter != nil && funcCheckRateLimit != nil {
+		return errors.New("auth: RateLimiter cannot be combined with FuncCheckRateLimit")
+	}
+
+	for endpoint, policy := range policies {
+		if policy.Burst < 1 {
+			return errors.New("auth: RateLimitPolicies " + endpoint + " Burst must be at least 1")
+		}
+
+		if policy.Refill <= 0 {
+			return errors.New("auth: RateLimitPolicies " + endpoint + " Refill must be positive")
+		}
+
+		if policy.Lockout < 0 {
+			return errors.New("auth: RateLimitPolicies " + endpoint + " Lockout cannot be negative")
+		}
+	}
+
+	return nil
+}
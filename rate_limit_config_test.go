@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+)
+
+func TestValidateRateLimitConfig(t *testing.T) {
+	limiter := utils.NewGCRARateLimiter()
+	defer limiter.Stop()
+
+	check := func(ip string, endpoint string) (bool, time.Duration, error) {
+		return true, 0, nil
+	}
+
+	tests := []struct {
+		name        string
+		rateLimiter types.RateLimiter
+		check       func(ip string, endpoint string) (bool, time.Duration, error)
+		policies    map[string]types.RateLimitPolicy
+		expected    string
+	}{
+		{"defaults", nil, nil, nil, ""},
+		{"rate limiter", limiter, nil, nil, ""},
+		{"custom check", nil, check, nil, ""},
+		{"rate limiter and custom check", limiter, check, nil, "auth: RateLimiter cannot be combined with FuncCheckRateLimit"},
+		{"valid policy", nil, nil, map[string]types.RateLimitPolicy{"login": {Burst: 5, Refill: time.Minute, Lockout: time.Hour}}, ""},
+		{"zero burst", nil, nil, map[string]types.RateLimitPolicy{"login": {Refill: time.Minute}}, "auth: RateLimitPolicies login Burst must be at least 1"},
+		{"zero refill", nil, nil, map[string]types.RateLimitPolicy{"login": {Burst: 5}}, "auth: RateLimitPolicies login Refill must be positive"},
+		{"negative lockout", nil, nil, map[string]types.RateLimitPolicy{"login": {Burst: 5, Refill: time.Minute, Lockout: -time.Minute}}, "auth: RateLimitPolicies login Lockout cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		err := validateRateLimitConfig(tt.rateLimiter, tt.check, tt.policies)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
+)
+
+func newRateLimitedPasswordAuthForTest(t *testing.T, config types.ConfigUsernameAndPassword) (login func(password string) int) {
+	t.Helper()
+
+	config.MaxLoginAttempts = 2
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		if password == "right" {
+			return "user-1", nil
+		}
+		return "", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func(password string) int {
+		form := url.Values{"email": {"user@test.com"}, "password": {password}}
+		req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+}
+
+func TestRateLimit_CountsOnlyFailedLogins(t *testing.T) {
+	login := newRateLimitedPasswordAuthForTest(t, testutils.NewUsernameAndPasswordConfigForTest())
+
+	// Logging in again and again, with the odd typo, is never locked out
+	for i := 0; i < 5; i++ {
+		login("wrong")
+		if code := login("right"); code != http.StatusOK {
+			t.Fatalf("login %d: expected to be allowed, got %d", i, code)
+		}
+	}
+
+	login("wrong")
+	login("wrong")
+	if code := login("right"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected repeated failures to be rate limited, got %d", code)
+	}
+}
+
+func TestRateLimit_ConcurrentFailuresCannotExceedLimit(t *testing.T) {
+	var logins atomic.Int32
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.MaxLoginAttempts = 3
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		logins.Add(1)
+		return "", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Far more parallel guesses than allowed, all sent before any fails
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			form := url.Values{"email": {"user@test.com"}, "password": {"wrong"}}
+			req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			authShared.Router().ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+
+	if got := logins.Load(); got != 3 {
+		t.Fatalf("expected exactly 3 attempts to reach the handler, got %d", got)
+	}
+}
+
+func TestRateLimit_CustomHooks(t *testing.T) {
+	failures := map[string]int{}
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncCheckRateLimit = func(ip string, endpoint string) (bool, time.Duration, error) {
+		if failures[ip+":"+endpoint] >= 2 {
+			return false, time.Minute, nil
+		}
+		failures[ip+":"+endpoint]++
+		return true, 0, nil
+	}
+	config.FuncRateLimitReset = func(ip string, endpoint string) error {
+		delete(failures, ip+":"+endpoint)
+		return nil
+	}
+
+	login := newRateLimitedPasswordAuthForTest(t, config)
+
+	login("wrong")
+	login("right")
+	if len(failures) != 0 {
+		t.Fatalf("expected the successful login to reset the failures, got %v", failures)
+	}
+
+	login("wrong")
+	login("wrong")
+	if code := login("right"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the custom rate limiter to block, got %d", code)
+	}
+}
+
+// policyRecordingRateLimiter allows every request, recording the policy
+// checked for each endpoint
+type policyRecordingRateLimiter struct {
+	policies map[string]types.RateLimitPolicy
+}
+
+func (l *policyRecordingRateLimiter) Allow(key string, endpoint string, policy types.RateLimitPolicy) types.RateLimitResult {
+	l.policies[endpoint] = policy
+	return types.RateLimitResult{Allowed: true}
+}
+
+func (l *policyRecordingRateLimiter) Reset(key string, endpoint string) {}
+
+func TestRateLimit_EndpointPolicies(t *testing.T) {
+	limiter := &policyRecordingRateLimiter{policies: map[string]types.RateLimitPolicy{}}
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.MaxLoginAttempts = 2
+	config.LockoutDuration = 10 * time.Minute
+	config.RateLimiter = limiter
+	config.RateLimitPolicies = map[string]types.RateLimitPolicy{
+		"login": {Burst: 7, Refill: time.Second},
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{
+		authShared.LinkApiLogin(),
+		"http://localhost/auth/" + PathApiLoginCodeVerify,
+		authShared.LinkApiPasswordReset(),
+		authShared.LinkApiPasswordRestore(),
+	} {
+		recorder, req := testutils.MakePostRequest(t, link, url.Values{})
+		authShared.Router().ServeHTTP(recorder, req)
+	}
+
+	expected := map[string]types.RateLimitPolicy{
+		"login":             {Burst: 7, Refill: time.Second},
+		"login_code_verify": rateLimitPolicyCodeVerify,
+		"password_reset":    {Burst: 2, Refill: 5 * time.Minute, Lockout: 10 * time.Minute},
+		"password_restore":  rateLimitPolicyPasswordRestore,
+	}
+	for endpoint, policy := range expected {
+		if got, ok := limiter.policies[endpoint]; !ok || got != policy {
+			t.Errorf("%s: expected policy %+v, got %+v", endpoint, policy, got)
+		}
+	}
+}
+
+func TestRateLimit_StrictPasswordRestore(t *testing.T) {
+	authShared, err := NewUsernameAndPasswordAuth(testutils.NewUsernameAndPasswordConfigForTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restore := func() int {
+		recorder, req := testutils.MakePostRequest(t, authShared.LinkApiPasswordRestore(), url.Values{"email": {"user@test.com"}})
+		authShared.Router().ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	for i := 0; i < rateLimitPolicyPasswordRestore.Burst; i++ {
+		if code := restore(); code == http.StatusTooManyRequests {
+			t.Fatalf("request %d: expected to be allowed", i+1)
+		}
+	}
+
+	if code := restore(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the restore emails to be rate limited, got %d", code)
+	}
+}
+
+func TestRateLimit_SharedAcrossInstances(t *testing.T) {
+	var mu sync.Mutex
+	store := map[string]string{}
+
+	counters := utils.NewTemporaryKeyCounterStore(
+		func(key string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return store[key], nil
+		},
+		func(key string, value string, expiresSeconds int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			store[key] = value
+			return nil
+		},
+		nil,
+	)
+
+	// Two replicas behind a load balancer
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.RateLimiter = utils.NewSharedRateLimiter(counters, nil)
+	first := newRateLimitedPasswordAuthForTest(t, config)
+
+	config.RateLimiter = utils.NewSharedRateLimiter(counters, nil)
+	second := newRateLimitedPasswordAuthForTest(t, config)
+
+	first("wrong")
+	second("wrong")
+	if code := first("right"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the failures on both replicas to be counted together, got %d", code)
+	}
+	if code := second("right"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected both replicas to lock the client out, got %d", code)
+	}
+}
+
+func TestRateLimit_SuccessfulCallsAreNotCounted(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.UseCookies = false
+	config.UseLocalStorage = true
+	config.SessionStore = testutils.NewSessionStore()
+	config.RefreshToken = testutils.NewRefreshTokenStore().Config()
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type tokenResponse struct {
+		Status string `json:"status"`
+		Data   struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		} `json:"data"`
+	}
+
+	post := func(link string, form url.Values, token string) tokenResponse {
+		req := httptest.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+
+		var response tokenResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("unexpected response %d %s: %v", recorder.Code, recorder.Body.String(), err)
+		}
+		return response
+	}
+
+	pair := post(authShared.LinkApiLogin(), url.Values{"email": {"test@test.com"}, "password": {"1234"}}, "")
+	if pair.Status != "success" {
+		t.Fatalf("expected to log in, got %+v", pair)
+	}
+
+	// Far more calls than MaxLoginAttempts, all of them successful
+	for i := 0; i < 10; i++ {
+		if sessions := post(authShared.LinkApiSessions(), url.Values{}, pair.Data.Token); sessions.Status != "success" {
+			t.Fatalf("session listing %d: expected success, got %+v", i+1, sessions)
+		}
+
+		pair = post(authShared.LinkApiTokenRefresh(), url.Values{"refresh_token": {pair.Data.RefreshToken}}, "")
+		if pair.Status != "success" {
+			t.Fatalf("refresh %d: expected success, got %+v", i+1, pair)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestReauth_RequireRecentAuth(t *testing.T) {
+	store := testutils.NewSessionStore()
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = store
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authCookie := func(recorder *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == CookieName && cookie.Value != "" {
+				return cookie
+			}
+		}
+		t.Fatalf("expected auth cookie, got %s", recorder.Body.String())
+		return nil
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+	oldCookie := authCookie(recorder)
+
+	// The sign in was an hour ago
+	session := store.Sessions[oldCookie.Value]
+	session.AuthenticatedAt = time.Now().Add(-time.Hour)
+	store.Sessions[oldCookie.Value] = session
+
+	protected := authShared.WebAuthOrRedirectMiddleware(authShared.RequireRecentAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), 5*time.Minute))
+
+	req = httptest.NewRequest(http.MethodGet, "/account/delete", nil)
+	req.AddCookie(oldCookie)
+	recorder = httptest.NewRecorder()
+	protected.ServeHTTP(recorder, req)
+
+	location := recorder.Header().Get("Location")
+	if recorder.Code != http.StatusSeeOther || location != authShared.LinkReauth()+"?back=%2Faccount%2Fdelete" {
+		t.Fatalf("expected a redirect to re-authenticate, got %d %q", recorder.Code, location)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, location, nil)
+	req.AddCookie(oldCookie)
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `var urlOnSuccess = "/account/delete";`) {
+		t.Fatalf("expected the re-authentication page returning to the account, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, authShared.LinkApiReauth(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(oldCookie)
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+	newCookie := authCookie(recorder)
+
+	if newCookie.Value == oldCookie.Value {
+		t.Fatal("expected the session to be replaced")
+	}
+	if _, ok := store.Sessions[oldCookie.Value]; ok {
+		t.Fatal("expected the replaced session to be ended")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/account/delete", nil)
+	req.AddCookie(newCookie)
+	recorder = httptest.NewRecorder()
+	protected.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "ok" {
+		t.Fatalf("expected the re-authenticated session to pass, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
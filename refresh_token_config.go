@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateRefreshTokenConfig validates the optional refresh token
+// configuration shared by both authentication flows.
+func validateRefreshTokenConfig(config *types.RefreshTokenConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Expiration < 0 {
+		return errors.New("auth: RefreshToken Expiration cannot be negative")
+	}
+
+	if config.FuncRefreshTokenStore == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenStore function is required")
+	}
+
+	if config.FuncRefreshTokenFindByHash == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenFindByHash function is required")
+	}
+
+	if config.FuncRefreshTokenMarkUsed == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenMarkUsed function is required")
+	}
+
+	if config.FuncRefreshTokenFindByFamily == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenFindByFamily function is required")
+	}
+
+	if config.FuncRefreshTokenFamilyRevoke == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenFamilyRevoke function is required")
+	}
+
+	if config.FuncRefreshTokenRevokeAllForUser == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenRevokeAllForUser function is required")
+	}
+
+	if config.FuncRefreshTokenRevokeBySession == nil {
+		return errors.New("auth: RefreshToken FuncRefreshTokenRevokeBySession function is required")
+	}
+
+	return nil
+}
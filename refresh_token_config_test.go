@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateRefreshTokenConfig(t *testing.T) {
+	valid := testutils.NewRefreshTokenStore().Config
+
+	tests := []struct {
+		name     string
+		config   func() *types.RefreshTokenConfig
+		expected string
+	}{
+		{"nil config", func() *types.RefreshTokenConfig { return nil }, ""},
+		{"valid", valid, ""},
+		{"negative expiration", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.Expiration = -time.Hour
+			return c
+		}, "auth: RefreshToken Expiration cannot be negative"},
+		{"no store", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenStore = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenStore function is required"},
+		{"no find", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenFindByHash = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenFindByHash function is required"},
+		{"no mark used", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenMarkUsed = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenMarkUsed function is required"},
+		{"no find by family", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenFindByFamily = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenFindByFamily function is required"},
+		{"no family revoke", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenFamilyRevoke = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenFamilyRevoke function is required"},
+		{"no revoke all for user", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenRevokeAllForUser = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenRevokeAllForUser function is required"},
+		{"no revoke by session", func() *types.RefreshTokenConfig {
+			c := valid()
+			c.FuncRefreshTokenRevokeBySession = nil
+			return c
+		}, "auth: RefreshToken FuncRefreshTokenRevokeBySession function is required"},
+	}
+
+	for _, tt := range tests {
+		err := validateRefreshTokenConfig(tt.config())
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestRouter_TokenRefreshRequiresConfig(t *testing.T) {
+	authShared, err := NewUsernameAndPasswordAuth(testutils.NewUsernameAndPasswordConfigForTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiTokenRefresh(), nil)
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the route to be disabled, got %d", recorder.Code)
+	}
+}
+
+func TestRouter_RefreshTokenRotation(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.UseCookies = false
+	config.UseLocalStorage = true
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+	config.RefreshToken = store.Config()
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type tokenResponse struct {
+		Status string `json:"status"`
+		Data   struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		} `json:"data"`
+	}
+
+	post := func(link string, form url.Values) tokenResponse {
+		req := httptest.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+
+		var response tokenResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("unexpected response %s: %v", recorder.Body.String(), err)
+		}
+		return response
+	}
+
+	login := post(authShared.LinkApiLogin(), url.Values{"email": {"test@test.com"}, "password": {"1234"}})
+	if login.Status != "success" || login.Data.Token == "" || login.Data.RefreshToken == "" {
+		t.Fatalf("expected a token pair on login, got %+v", login)
+	}
+
+	refreshed := post(authShared.LinkApiTokenRefresh(), url.Values{"refresh_token": {login.Data.RefreshToken}})
+	if refreshed.Status != "success" || refreshed.Data.RefreshToken == "" || refreshed.Data.RefreshToken == login.Data.RefreshToken {
+		t.Fatalf("expected a rotated token pair, got %+v", refreshed)
+	}
+
+	// Replaying the first refresh token revokes the whole family
+	if replay := post(authShared.LinkApiTokenRefresh(), url.Values{"refresh_token": {login.Data.RefreshToken}}); replay.Status != "unauthenticated" {
+		t.Fatalf("expected the replayed token to be rejected, got %+v", replay)
+	}
+	if latest := post(authShared.LinkApiTokenRefresh(), url.Values{"refresh_token": {refreshed.Data.RefreshToken}}); latest.Status != "unauthenticated" {
+		t.Fatalf("expected the family to be revoked, got %+v", latest)
+	}
+}
+
+func TestRouter_PasswordResetRevokesRefreshTokens(t *testing.T) {
+	store := testutils.NewRefreshTokenStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.UseCookies = false
+	config.UseLocalStorage = true
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+	config.FuncTemporaryKeyGet = func(key string) (string, error) {
+		if key == "reset-token" {
+			return "user-1", nil
+		}
+		return "", nil
+	}
+	config.FuncUserPasswordChange = func(ctx context.Context, userID string, password string, options types.UserAuthOptions) error {
+		return nil
+	}
+	config.RefreshToken = store.Config()
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type tokenResponse struct {
+		Status string `json:"status"`
+		Data   struct {
+			RefreshToken string `json:"refresh_token"`
+		} `json:"data"`
+	}
+
+	post := func(link string, form url.Values) tokenResponse {
+		req := httptest.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+
+		var response tokenResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("unexpected response %s: %v", recorder.Body.String(), err)
+		}
+		return response
+	}
+
+	login := post(authShared.LinkApiLogin(), url.Values{"email": {"test@test.com"}, "password": {"1234"}})
+	if login.Status != "success" || login.Data.RefreshToken == "" {
+		t.Fatalf("expected a refresh token on login, got %+v", login)
+	}
+
+	reset := post(authShared.LinkApiPasswordReset(), url.Values{
+		"token":            {"reset-token"},
+		"password":         {"password123"},
+		"password_confirm": {"password123"},
+	})
+	if reset.Status != "success" {
+		t.Fatalf("expected the password to be reset, got %+v", reset)
+	}
+
+	if refreshed := post(authShared.LinkApiTokenRefresh(), url.Values{"refresh_token": {login.Data.RefreshToken}}); refreshed.Status != "unauthenticated" {
+		t.Fatalf("expected the refresh token to be revoked by the password reset, got %+v", refreshed)
+	}
+}
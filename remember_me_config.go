@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateRememberMeConfig validates the optional remember-me configuration
+// shared by both authentication flows. The remember-me token is kept in a
+// cookie, so cookies must be enabled.
+func validateRememberMeConfig(config *types.RememberMeConfig, useCookies bool) error {
+	if config == nil {
+		return nil
+	}
+
+	if !useCookies {
+		return errors.New("auth: RememberMe requires UseCookies")
+	}
+
+	if config.Expiration < 0 {
+		return errors.New("auth: RememberMe Expiration cannot be negative")
+	}
+
+	if config.FuncRememberTokenStore == nil {
+		return errors.New("auth: RememberMe FuncRememberTokenStore function is required")
+	}
+
+	if config.FuncRememberTokenFindBySelector == nil {
+		return errors.New("auth: RememberMe FuncRememberTokenFindBySelector function is required")
+	}
+
+	if config.FuncRememberTokenDelete == nil {
+		return errors.New("auth: RememberMe FuncRememberTokenDelete function is required")
+	}
+
+	if config.FuncRememberTokenDeleteAllForUser == nil {
+		return errors.New("auth: RememberMe FuncRememberTokenDeleteAllForUser function is required")
+	}
+
+	if config.FuncRememberTokenDeleteBySession == nil {
+		return errors.New("auth: RememberMe FuncRememberTokenDeleteBySession function is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/middlewares"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateRememberMeConfig(t *testing.T) {
+	valid := testutils.NewRememberTokenStore().Config
+
+	tests := []struct {
+		name       string
+		config     func() *types.RememberMeConfig
+		useCookies bool
+		expected   string
+	}{
+		{"nil config", func() *types.RememberMeConfig { return nil }, false, ""},
+		{"valid", valid, true, ""},
+		{"no cookies", valid, false, "auth: RememberMe requires UseCookies"},
+		{"negative expiration", func() *types.RememberMeConfig {
+			c := valid()
+			c.Expiration = -time.Hour
+			return c
+		}, true, "auth: RememberMe Expiration cannot be negative"},
+		{"no store", func() *types.RememberMeConfig {
+			c := valid()
+			c.FuncRememberTokenStore = nil
+			return c
+		}, true, "auth: RememberMe FuncRememberTokenStore function is required"},
+		{"no find", func() *types.RememberMeConfig {
+			c := valid()
+			c.FuncRememberTokenFindBySelector = nil
+			return c
+		}, true, "auth: RememberMe FuncRememberTokenFindBySelector function is required"},
+		{"no delete", func() *types.RememberMeConfig {
+			c := valid()
+			c.FuncRememberTokenDelete = nil
+			return c
+		}, true, "auth: RememberMe FuncRememberTokenDelete function is required"},
+		{"no delete all", func() *types.RememberMeConfig {
+			c := valid()
+			c.FuncRememberTokenDeleteAllForUser = nil
+			return c
+		}, true, "auth: RememberMe FuncRememberTokenDeleteAllForUser function is required"},
+		{"no delete by session", func() *types.RememberMeConfig {
+			c := valid()
+			c.FuncRememberTokenDeleteBySession = nil
+			return c
+		}, true, "auth: RememberMe FuncRememberTokenDeleteBySession function is required"},
+	}
+
+	for _, tt := range tests {
+		err := validateRememberMeConfig(tt.config(), tt.useCookies)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestRememberMe_RestoresSessionInMiddleware(t *testing.T) {
+	sessions := testutils.NewSessionStore()
+	rememberTokens := testutils.NewRememberTokenStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = sessions
+	config.RememberMe = rememberTokens.Config()
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := func(recorder *httptest.ResponseRecorder) map[string]*http.Cookie {
+		found := map[string]*http.Cookie{}
+		for _, cookie := range recorder.Result().Cookies() {
+			found[cookie.Name] = cookie
+		}
+		return found
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}, "remember": {"1"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	remember := cookies(recorder)[types.RememberCookieName]
+	if remember == nil || remember.MaxAge <= 2*60*60 {
+		t.Fatalf("expected a long-lived remember me cookie, got %+v (%s)", remember, recorder.Body.String())
+	}
+
+	// The short session is gone, e.g. expired
+	for id := range sessions.Sessions {
+		delete(sessions.Sessions, id)
+	}
+
+	var userID string
+	handler := middlewares.WebAuthOrRedirectMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = r.Context().Value(types.AuthenticatedUserID{}).(string)
+	}), authShared)
+
+	req = httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(remember)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if userID != "user-1" {
+		t.Fatalf("expected the user to be signed in again, got %q (%d)", userID, recorder.Code)
+	}
+
+	issued := cookies(recorder)
+	if issued[CookieName] == nil || sessions.Sessions[issued[CookieName].Value].UserID != "user-1" {
+		t.Fatalf("expected a fresh session cookie, got %+v", issued[CookieName])
+	}
+	if issued[types.RememberCookieName] == nil || issued[types.RememberCookieName].Value == remember.Value {
+		t.Fatalf("expected the remember me cookie to be rotated, got %+v", issued[types.RememberCookieName])
+	}
+
+	// The old remember me cookie cannot be used again
+	userID = ""
+	req = httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(remember)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if userID != "" || recorder.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the used remember me cookie to be rejected, got %q (%d)", userID, recorder.Code)
+	}
+
+	// Logging out forgets the device
+	req = httptest.NewRequest(http.MethodPost, authShared.LinkApiLogout(), nil)
+	req.AddCookie(issued[CookieName])
+	req.AddCookie(issued[types.RememberCookieName])
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if len(rememberTokens.Tokens) != 0 {
+		t.Fatalf("expected the remember me token to be revoked on logout, got %d (%s)", len(rememberTokens.Tokens), recorder.Body.String())
+	}
+	if removed := cookies(recorder)[types.RememberCookieName]; removed == nil || removed.MaxAge >= 0 {
+		t.Fatalf("expected the remember me cookie to be removed, got %+v", removed)
+	}
+}
+
+func TestRememberMe_OnlyWhenRequested(t *testing.T) {
+	rememberTokens := testutils.NewRememberTokenStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.RememberMe = rememberTokens.Config()
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == types.RememberCookieName {
+			t.Fatalf("expected no remember me cookie, got %+v", cookie)
+		}
+	}
+	if len(rememberTokens.Tokens) != 0 {
+		t.Fatalf("expected no remember me token, got %d", len(rememberTokens.Tokens))
+	}
+}
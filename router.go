@@ -4,9 +4,12 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dracory/auth/internal/helpers"
 	"github.com/dracory/auth/internal/middlewares"
+	"github.com/dracory/auth/types"
+	"github.com/dracory/auth/utils"
 	"github.com/dracory/req"
 	"github.com/dracory/str"
 )
@@ -38,10 +41,50 @@ func (a authImplementation) AuthHandler(w http.ResponseWriter, r *http.Request)
 		uri = str.LeftFrom(uri, "?")
 	}
 
-	if strings.HasSuffix(uri, PathApiLogin) {
+	if provider := oidcRouteProvider(uri, PathOIDCLogin); provider != "" {
+		path = PathOIDCLogin
+		r.SetPathValue("provider", provider)
+	} else if provider := oidcRouteProvider(uri, PathOIDCCallback); provider != "" {
+		path = PathOIDCCallback
+		r.SetPathValue("provider", provider)
+	} else if strings.HasSuffix(uri, PathApiLogin) {
 		path = PathApiLogin
+	} else if strings.HasSuffix(uri, PathApiLogin2faVerify) {
+		path = PathApiLogin2faVerify
 	} else if strings.HasSuffix(uri, PathApiLoginCodeVerify) {
 		path = PathApiLoginCodeVerify
+	} else if strings.HasSuffix(uri, PathApiTwoFactorEnroll) {
+		path = PathApiTwoFactorEnroll
+	} else if strings.HasSuffix(uri, PathApiTwoFactorEnrollConfirm) {
+		path = PathApiTwoFactorEnrollConfirm
+	} else if strings.HasSuffix(uri, PathApiTwoFactorRecoveryCodes) {
+		path = PathApiTwoFactorRecoveryCodes
+	} else if strings.HasSuffix(uri, PathApiWebAuthnRegisterBegin) {
+		path = PathApiWebAuthnRegisterBegin
+	} else if strings.HasSuffix(uri, PathApiWebAuthnRegisterFinish) {
+		path = PathApiWebAuthnRegisterFinish
+	} else if strings.HasSuffix(uri, PathApiWebAuthnLoginBegin) {
+		path = PathApiWebAuthnLoginBegin
+	} else if strings.HasSuffix(uri, PathApiWebAuthnLoginFinish) {
+		path = PathApiWebAuthnLoginFinish
+	} else if strings.HasSuffix(uri, PathApiTokenRefresh) {
+		path = PathApiTokenRefresh
+	} else if strings.HasSuffix(uri, PathApiReauth) {
+		path = PathApiReauth
+	} else if strings.HasSuffix(uri, PathApiSessions) {
+		path = PathApiSessions
+	} else if strings.HasSuffix(uri, PathApiSessionRevoke) {
+		path = PathApiSessionRevoke
+	} else if strings.HasSuffix(uri, PathApiSessionsRevokeOthers) {
+		path = PathApiSessionsRevokeOthers
+	} else if strings.HasSuffix(uri, PathApiApiKeys) {
+		path = PathApiApiKeys
+	} else if strings.HasSuffix(uri, PathApiApiKeyCreate) {
+		path = PathApiApiKeyCreate
+	} else if strings.HasSuffix(uri, PathApiApiKeyRename) {
+		path = PathApiApiKeyRename
+	} else if strings.HasSuffix(uri, PathApiApiKeyRevoke) {
+		path = PathApiApiKeyRevoke
 	} else if strings.HasSuffix(uri, PathApiLogout) {
 		path = PathApiLogout
 	} else if strings.HasSuffix(uri, PathApiResetPassword) {
@@ -56,6 +99,20 @@ func (a authImplementation) AuthHandler(w http.ResponseWriter, r *http.Request)
 		path = PathLogin
 	} else if strings.HasSuffix(uri, PathLoginCodeVerify) {
 		path = PathLoginCodeVerify
+	} else if strings.HasSuffix(uri, PathLoginLink) {
+		path = PathLoginLink
+	} else if strings.HasSuffix(uri, PathLogin2faVerify) {
+		path = PathLogin2faVerify
+	} else if strings.HasSuffix(uri, PathTwoFactorEnroll) {
+		path = PathTwoFactorEnroll
+	} else if strings.HasSuffix(uri, PathPasskeys) {
+		path = PathPasskeys
+	} else if strings.HasSuffix(uri, PathSessions) {
+		path = PathSessions
+	} else if strings.HasSuffix(uri, PathReauth) {
+		path = PathReauth
+	} else if strings.HasSuffix(uri, PathAccountUnlock) {
+		path = PathAccountUnlock
 	} else if strings.HasSuffix(uri, PathLogout) {
 		path = PathLogout
 	} else if strings.HasSuffix(uri, PathRegister) {
@@ -95,6 +152,39 @@ func (a authImplementation) getRoute(route string) func(w http.ResponseWriter, r
 		routes[path] = handler
 	}
 
+	if a.twoFactorEnabled() {
+		routes[PathLogin2faVerify] = a.pageLogin2faVerify
+	}
+
+	if a.twoFactorEnrollEnabled() {
+		routes[PathTwoFactorEnroll] = a.withAuth(a.pageTwoFactorEnroll)
+	}
+
+	if a.webAuthn != nil {
+		routes[PathPasskeys] = a.withAuth(a.pagePasskeys)
+	}
+
+	if a.sessionsEnabled() {
+		routes[PathSessions] = a.withAuth(a.pageSessions)
+	}
+
+	if a.reauthEnabled() {
+		routes[PathReauth] = a.withAuth(a.pageReauth)
+	}
+
+	if a.accountUnlockEnabled() {
+		routes[PathAccountUnlock] = a.pageAccountUnlock
+	}
+
+	if a.magicLinkEnabled() {
+		routes[PathLoginLink] = a.pageLoginLink
+	}
+
+	if a.oidcEnabled() {
+		routes[PathOIDCLogin] = a.pageOIDCLogin
+		routes[PathOIDCCallback] = a.pageOIDCCallback
+	}
+
 	if a.enableRegistration {
 		routes[PathRegister] = a.pageRegister
 		routes[PathRegisterCodeVerify] = a.pageRegisterCodeVerify
@@ -107,33 +197,145 @@ func (a authImplementation) getRoute(route string) func(w http.ResponseWriter, r
 	return a.notFoundHandler
 }
 
+// apiRoute describes an API endpoint served by the router
+type apiRoute struct {
+	path     string
+	endpoint string
+	handler  func(http.ResponseWriter, *http.Request)
+	useCSRF  bool
+	// requireAuth guards the endpoint with WebAuthOrRedirectMiddleware
+	requireAuth bool
+	// rateLimitKey, when set, adds a second rate limit counted against the
+	// returned key (skipped when empty) under the endpoint name suffixed
+	// with rateLimitKeySuffix
+	rateLimitKey       func(*http.Request) string
+	rateLimitKeySuffix string
+	// rateLimitClears lists the endpoints whose failed attempts from the
+	// client IP a success clears as well, e.g. the login sending the code
+	rateLimitClears []string
+	// rateLimitPolicy, when set, replaces the default policy built from
+	// MaxLoginAttempts and LockoutDuration; RateLimitPolicies overrides both
+	rateLimitPolicy *types.RateLimitPolicy
+}
+
+var (
+	// rateLimitPolicyCodeVerify is generous, as codes are easily mistyped
+	// and each code allows only a few attempts anyway
+	rateLimitPolicyCodeVerify = types.RateLimitPolicy{Burst: 10, Refill: time.Minute, Lockout: 5 * time.Minute}
+
+	// rateLimitPolicyPasswordRestore is strict, as failed restores probe for
+	// the details of accounts: three an hour
+	rateLimitPolicyPasswordRestore = types.RateLimitPolicy{Burst: 3, Refill: 20 * time.Minute, Lockout: time.Hour}
+)
+
 func (a authImplementation) buildAPIRoutes(csrfCfg middlewares.CSRFConfig) map[string]func(http.ResponseWriter, *http.Request) {
 	routes := make(map[string]func(http.ResponseWriter, *http.Request))
 
-	apiRoutes := []struct {
-		path     string
-		endpoint string
-		handler  func(http.ResponseWriter, *http.Request)
-		useCSRF  bool
-	}{
-		{PathApiLogin, "login", a.apiLogin, true},
-		{PathApiLoginCodeVerify, "login_code_verify", a.apiLoginCodeVerify, false},
-		{PathApiRegister, "register", a.apiRegister, true},
-		{PathApiRegisterCodeVerify, "register_code_verify", a.apiRegisterCodeVerify, false},
-		{PathApiResetPassword, "password_reset", a.apiPasswordReset, true},
-		{PathApiRestorePassword, "password_restore", a.apiPasswordRestore, false},
+	apiRoutes := []apiRoute{
+		{path: PathApiLogin, endpoint: "login", handler: a.apiLogin, useCSRF: true, rateLimitKey: a.loginRateLimitKey(), rateLimitKeySuffix: "_phone"},
+		{path: PathApiLoginCodeVerify, endpoint: "login_code_verify", handler: a.apiLoginCodeVerify, rateLimitClears: []string{"login"}, rateLimitPolicy: &rateLimitPolicyCodeVerify},
+		{path: PathApiRegister, endpoint: "register", handler: a.apiRegister, useCSRF: true},
+		{path: PathApiRegisterCodeVerify, endpoint: "register_code_verify", handler: a.apiRegisterCodeVerify, rateLimitPolicy: &rateLimitPolicyCodeVerify},
+		{path: PathApiResetPassword, endpoint: "password_reset", handler: a.apiPasswordReset, useCSRF: true},
+		{path: PathApiRestorePassword, endpoint: "password_restore", handler: a.apiPasswordRestore, rateLimitPolicy: &rateLimitPolicyPasswordRestore},
+	}
+
+	if a.twoFactorEnabled() {
+		apiRoutes = append(apiRoutes, apiRoute{path: PathApiLogin2faVerify, endpoint: "login_2fa_verify", handler: a.apiLogin2faVerify, rateLimitClears: []string{"login"}})
+	}
+
+	if a.twoFactorEnrollEnabled() {
+		apiRoutes = append(apiRoutes,
+			apiRoute{path: PathApiTwoFactorEnroll, endpoint: "2fa_enroll", handler: a.apiTwoFactorEnroll, useCSRF: true, requireAuth: true},
+			apiRoute{path: PathApiTwoFactorEnrollConfirm, endpoint: "2fa_enroll_confirm", handler: a.apiTwoFactorEnrollConfirm, useCSRF: true, requireAuth: true},
+		)
+	}
+
+	if !a.passwordless && a.funcUserRecoveryCodesStore != nil {
+		apiRoutes = append(apiRoutes, apiRoute{path: PathApiTwoFactorRecoveryCodes, endpoint: "2fa_recovery_codes", handler: a.apiTwoFactorRecoveryCodes, useCSRF: true, requireAuth: true})
+	}
+
+	if a.webAuthn != nil {
+		apiRoutes = append(apiRoutes,
+			apiRoute{path: PathApiWebAuthnRegisterBegin, endpoint: "webauthn_register_begin", handler: a.apiWebAuthnRegisterBegin, useCSRF: true, requireAuth: true},
+			apiRoute{path: PathApiWebAuthnRegisterFinish, endpoint: "webauthn_register_finish", handler: a.apiWebAuthnRegisterFinish, useCSRF: true, requireAuth: true},
+			apiRoute{path: PathApiWebAuthnLoginBegin, endpoint: "webauthn_login_begin", handler: a.apiWebAuthnLoginBegin},
+			apiRoute{path: PathApiWebAuthnLoginFinish, endpoint: "webauthn_login_finish", handler: a.apiWebAuthnLoginFinish, rateLimitClears: []string{"webauthn_login_begin"}},
+		)
+	}
+
+	if a.refreshToken != nil {
+		apiRoutes = append(apiRoutes, apiRoute{path: PathApiTokenRefresh, endpoint: "token_refresh", handler: a.apiTokenRefresh})
+	}
+
+	if a.sessionsEnabled() {
+		apiRoutes = append(apiRoutes,
+			apiRoute{path: PathApiSessions, endpoint: "sessions", handler: a.apiSessions, requireAuth: true},
+			apiRoute{path: PathApiSessionRevoke, endpoint: "session_revoke", handler: a.apiSessionRevoke, useCSRF: true, requireAuth: true},
+			apiRoute{path: PathApiSessionsRevokeOthers, endpoint: "sessions_revoke_others", handler: a.apiSessionsRevokeOthers, useCSRF: true, requireAuth: true},
+		)
+	}
+
+	if a.reauthEnabled() {
+		apiRoutes = append(apiRoutes, apiRoute{path: PathApiReauth, endpoint: "reauth", handler: a.apiReauth, useCSRF: true, requireAuth: true})
+	}
+
+	if a.apiKeys != nil {
+		apiRoutes = append(apiRoutes,
+			apiRoute{path: PathApiApiKeys, endpoint: "api_keys", handler: a.apiApiKeys, requireAuth: true},
+			apiRoute{path: PathApiApiKeyCreate, endpoint: "api_key_create", handler: a.apiApiKeyCreate, useCSRF: true, requireAuth: true},
+			apiRoute{path: PathApiApiKeyRename, endpoint: "api_key_rename", handler: a.apiApiKeyRename, useCSRF: true, requireAuth: true},
+			apiRoute{path: PathApiApiKeyRevoke, endpoint: "api_key_revoke", handler: a.apiApiKeyRevoke, useCSRF: true, requireAuth: true},
+		)
 	}
 
 	for _, cfg := range apiRoutes {
 		h := cfg.handler
+		if cfg.rateLimitKey != nil {
+			keyFunc := cfg.rateLimitKey
+			h = middlewares.WithRateLimit(
+				middlewares.RateLimitConfig{
+					Check: func(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+						key := keyFunc(r)
+						if key == "" {
+							return true
+						}
+						return helpers.CheckRateLimitForKey(w, r, key, endpoint, a.disableRateLimit, a.funcCheckRateLimit, a.rateLimiter, a.rateLimitPolicyFor(endpoint, nil))
+					},
+					Record: func(r *http.Request, endpoint string, succeeded bool) {
+						if key := keyFunc(r); key != "" {
+							a.rateLimitRecord(key, endpoint, succeeded)
+						}
+					},
+					Endpoint: cfg.endpoint + cfg.rateLimitKeySuffix,
+				},
+				h,
+			)
+		}
+
 		if cfg.useCSRF {
 			h = middlewares.WithCSRF(csrfCfg, h)
 		}
 
+		if cfg.requireAuth {
+			h = a.withAuth(h)
+		}
+
+		clears := cfg.rateLimitClears
+		policy := a.rateLimitPolicyFor(cfg.endpoint, cfg.rateLimitPolicy)
 		routes[cfg.path] = middlewares.WithRateLimit(
 			middlewares.RateLimitConfig{
 				Check: func(w http.ResponseWriter, r *http.Request, endpoint string) bool {
-					return helpers.CheckRateLimit(w, r, endpoint, a.disableRateLimit, a.funcCheckRateLimit, a.rateLimiter)
+					return helpers.CheckRateLimit(w, r, endpoint, a.disableRateLimit, a.funcCheckRateLimit, a.rateLimiter, policy)
+				},
+				Record: func(r *http.Request, endpoint string, succeeded bool) {
+					ip := helpers.GetClientIP(r)
+					a.rateLimitRecord(ip, endpoint, succeeded)
+					if succeeded {
+						for _, cleared := range clears {
+							a.rateLimitRecord(ip, cleared, true)
+						}
+					}
 				},
 				Endpoint: cfg.endpoint,
 			},
@@ -144,6 +346,107 @@ func (a authImplementation) buildAPIRoutes(csrfCfg middlewares.CSRFConfig) map[s
 	return routes
 }
 
+// rateLimitRecord reports the outcome of a rate limited request counted
+// against the key, logging failures of a custom rate limiter, which do not
+// block requests
+func (a authImplementation) rateLimitRecord(key string, endpoint string, succeeded bool) {
+	err := helpers.RecordRateLimit(key, endpoint, succeeded, a.disableRateLimit, a.funcRateLimitReset, a.rateLimiter)
+	if err != nil && a.logger != nil {
+		a.logger.Error("rate limit record failed", "error", err, "endpoint", endpoint)
+	}
+}
+
+// rateLimitPolicyFor returns the policy of the endpoint: the configured one,
+// else the built-in one when given, else the default
+func (a authImplementation) rateLimitPolicyFor(endpoint string, builtin *types.RateLimitPolicy) types.RateLimitPolicy {
+	if policy, ok := a.rateLimitPolicies[endpoint]; ok {
+		return policy
+	}
+
+	if builtin != nil {
+		return *builtin
+	}
+
+	return a.rateLimitPolicy
+}
+
+// withAuth guards a route with WebAuthOrRedirectMiddleware
+func (a authImplementation) withAuth(h func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return a.WebAuthOrRedirectMiddleware(http.HandlerFunc(h)).ServeHTTP
+}
+
+// twoFactorEnabled reports whether the TOTP second step is configured
+func (a authImplementation) twoFactorEnabled() bool {
+	return !a.passwordless && a.funcUserTotpSecretFind != nil
+}
+
+// twoFactorEnrollEnabled reports whether users can enroll a TOTP secret
+func (a authImplementation) twoFactorEnrollEnabled() bool {
+	return !a.passwordless && a.funcUserTotpSecretStore != nil
+}
+
+// sessionsEnabled reports whether users can list and revoke their sessions,
+// which needs a session store; the legacy callbacks cannot list sessions
+func (a authImplementation) sessionsEnabled() bool {
+	return a.sessionStore != nil && a.jwt == nil
+}
+
+// reauthEnabled reports whether signed in users can re-authenticate, which
+// needs the sign in time recorded in a session store or JWT; the legacy
+// callbacks cannot record it
+func (a authImplementation) reauthEnabled() bool {
+	return a.sessionStore != nil || a.jwt != nil
+}
+
+// accountUnlockEnabled reports whether locked accounts can be unlocked with
+// the link of the unlock email
+func (a authImplementation) accountUnlockEnabled() bool {
+	return a.accountLockout != nil && a.accountLockout.FuncUnlockEmailSend != nil
+}
+
+// magicLinkEnabled reports whether login emails carry a magic link
+func (a authImplementation) magicLinkEnabled() bool {
+	return a.passwordless && a.passwordlessMagicLink != nil
+}
+
+// loginRateLimitKey returns the per-recipient rate limit key of the login
+// endpoint, so one phone number cannot be flooded with text messages from
+// many addresses; nil when the login does not send text messages
+func (a authImplementation) loginRateLimitKey() func(*http.Request) string {
+	if !a.passwordless || a.passwordlessChannel != types.PasswordlessChannelSms {
+		return nil
+	}
+
+	return func(r *http.Request) string {
+		phone := utils.NormalizePhone(req.GetStringTrimmed(r, "phone"))
+		if phone == "" {
+			return ""
+		}
+		return "phone:" + phone
+	}
+}
+
+// oidcEnabled reports whether OpenID Connect providers are configured
+func (a authImplementation) oidcEnabled() bool {
+	return a.oidc != nil && len(a.oidc.Providers) > 0
+}
+
+// oidcRouteProvider returns the provider name if the URI is the OpenID
+// Connect route with the prefix, e.g. ".../login/google"
+func oidcRouteProvider(uri string, prefix string) string {
+	i := strings.LastIndex(uri, "/"+prefix)
+	if i < 0 {
+		return ""
+	}
+
+	provider := uri[i+1+len(prefix):]
+	if strings.Contains(provider, "/") {
+		return ""
+	}
+
+	return provider
+}
+
 func (a authImplementation) notFoundHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, a.LinkLogin(), http.StatusTemporaryRedirect)
 }
@@ -1,12 +1,15 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
 	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/types"
 )
 
 func TestRouter_UnknownPathRedirectsToLogin(t *testing.T) {
@@ -55,3 +58,309 @@ func TestRouter_LoginPathServesLoginPage(t *testing.T) {
 		t.Fatalf("expected login page HTML to contain %q, got %s", "<span>Log in</span>", body)
 	}
 }
+
+func TestRouter_Login2faVerifyRequiresTotpConfig(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, authShared.LinkLogin2faVerify(), nil)
+	recorder := httptest.NewRecorder()
+
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if status := recorder.Code; status != http.StatusTemporaryRedirect {
+		t.Fatalf("expected status %d without TOTP configured, got %d", http.StatusTemporaryRedirect, status)
+	}
+}
+
+func TestRouter_Login2faVerifyPathServesPage(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncUserTotpSecretFind = func(ctx context.Context, userID string, options types.UserAuthOptions) (string, error) {
+		return "", nil
+	}
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, authShared.LinkLogin2faVerify()+"?t=abc", nil)
+	recorder := httptest.NewRecorder()
+
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, "Two-Factor Verification") {
+		t.Fatalf("expected 2FA page HTML, got %s", body)
+	}
+}
+
+func TestRouter_TwoFactorEnrollRequiresAuthentication(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncUserTotpSecretStore = func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error {
+		return nil
+	}
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, authShared.LinkTwoFactorEnroll(), nil)
+	recorder := httptest.NewRecorder()
+
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if status := recorder.Code; status != http.StatusTemporaryRedirect {
+		t.Fatalf("expected status %d, got %d", http.StatusTemporaryRedirect, status)
+	}
+	if location := recorder.Header().Get("Location"); location != authShared.LinkLogin() {
+		t.Fatalf("expected redirect to login, got %q", location)
+	}
+}
+
+func TestRouter_TwoFactorEnrollServesPageForAuthenticatedUser(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncUserFindByAuthToken = func(ctx context.Context, token string, options types.UserAuthOptions) (string, error) {
+		if token == "valid-token" {
+			return "user-1", nil
+		}
+		return "", nil
+	}
+	config.FuncUserTotpSecretStore = func(ctx context.Context, userID string, secret string, options types.UserAuthOptions) error {
+		return nil
+	}
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, authShared.LinkTwoFactorEnroll(), nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: "valid-token"})
+	recorder := httptest.NewRecorder()
+
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, "Enable Two-Factor Authentication") {
+		t.Fatalf("expected enrollment page HTML, got %s", body)
+	}
+}
+
+func newWebAuthnConfigForTest() *types.WebAuthnConfig {
+	return &types.WebAuthnConfig{
+		RPID:    "localhost",
+		Origins: []string{"http://localhost"},
+		FuncCredentialStore: func(ctx context.Context, credential types.WebAuthnCredential, options types.UserAuthOptions) error {
+			return nil
+		},
+		FuncCredentialFindByID: func(ctx context.Context, credentialID string, options types.UserAuthOptions) (*types.WebAuthnCredential, error) {
+			return nil, nil
+		},
+		FuncCredentialSignCountUpdate: func(ctx context.Context, credentialID string, signCount uint32, options types.UserAuthOptions) error {
+			return nil
+		},
+	}
+}
+
+func TestRouter_WebAuthnRoutesRequireConfig(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{authShared.LinkApiWebAuthnLoginBegin(), authShared.LinkPasskeys()} {
+		req := httptest.NewRequest(http.MethodPost, link, nil)
+		recorder := httptest.NewRecorder()
+
+		authShared.Router().ServeHTTP(recorder, req)
+
+		if status := recorder.Code; status != http.StatusTemporaryRedirect {
+			t.Fatalf("%s: expected status %d without WebAuthn configured, got %d", link, http.StatusTemporaryRedirect, status)
+		}
+	}
+}
+
+func TestRouter_WebAuthnLoginBeginServesChallenge(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.WebAuthn = newWebAuthnConfigForTest()
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiWebAuthnLoginBegin(), nil)
+	recorder := httptest.NewRecorder()
+
+	authShared.Router().ServeHTTP(recorder, req)
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, `"status":"success"`) || !strings.Contains(body, `"rpId":"localhost"`) {
+		t.Fatalf("expected passkey request options, got %s", body)
+	}
+}
+
+func TestRouter_PasskeysRequiresAuthentication(t *testing.T) {
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.WebAuthn = newWebAuthnConfigForTest()
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{authShared.LinkPasskeys(), authShared.LinkApiWebAuthnRegisterBegin()} {
+		req := httptest.NewRequest(http.MethodPost, link, nil)
+		recorder := httptest.NewRecorder()
+
+		authShared.Router().ServeHTTP(recorder, req)
+
+		if status := recorder.Code; status != http.StatusTemporaryRedirect {
+			t.Fatalf("%s: expected status %d, got %d", link, http.StatusTemporaryRedirect, status)
+		}
+		if location := recorder.Header().Get("Location"); location != authShared.LinkLogin() {
+			t.Fatalf("%s: expected redirect to login, got %q", link, location)
+		}
+	}
+}
+
+func TestRouter_SessionStoreReplacesTokenCallbacks(t *testing.T) {
+	store := testutils.NewSessionStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.FuncUserStoreAuthToken = nil
+	config.FuncUserFindByAuthToken = nil
+	config.FuncUserLogout = nil
+	config.SessionStore = store
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "test-agent")
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	var authCookie *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == CookieName {
+			authCookie = cookie
+		}
+	}
+	if authCookie == nil {
+		t.Fatalf("expected auth cookie, got %s", recorder.Body.String())
+	}
+
+	session := store.Sessions[authCookie.Value]
+	if session.UserID != "user-1" || session.UserAgent != "test-agent" || session.CreatedAt.IsZero() {
+		t.Fatalf("expected a session with metadata, got %+v", session)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, authShared.LinkApiLogout(), nil)
+	req.AddCookie(authCookie)
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if !strings.Contains(recorder.Body.String(), `"status":"success"`) {
+		t.Fatalf("expected logout success, got %s", recorder.Body.String())
+	}
+	if len(store.Sessions) != 0 {
+		t.Fatalf("expected the session to be deleted, got %d", len(store.Sessions))
+	}
+}
+
+func TestRouter_SessionsRequireSessionStore(t *testing.T) {
+	authShared, err := NewUsernameAndPasswordAuth(testutils.NewUsernameAndPasswordConfigForTest())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{authShared.LinkSessions(), authShared.LinkApiSessions(), authShared.LinkApiSessionsRevokeOthers()} {
+		req := httptest.NewRequest(http.MethodPost, link, nil)
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusTemporaryRedirect || recorder.Header().Get("Location") != authShared.LinkLogin() {
+			t.Fatalf("expected %s to be disabled, got %d", link, recorder.Code)
+		}
+	}
+}
+
+func TestRouter_SessionsListAndRevokeOthers(t *testing.T) {
+	store := testutils.NewSessionStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = store
+	config.FuncUserLogin = func(ctx context.Context, username string, password string, options types.UserAuthOptions) (string, error) {
+		return "user-1", nil
+	}
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(userAgent string) *http.Cookie {
+		form := url.Values{"email": {"test@test.com"}, "password": {"1234"}}
+		req := httptest.NewRequest(http.MethodPost, authShared.LinkApiLogin(), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("User-Agent", userAgent)
+		recorder := httptest.NewRecorder()
+		authShared.Router().ServeHTTP(recorder, req)
+
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == CookieName {
+				return cookie
+			}
+		}
+		t.Fatalf("expected auth cookie, got %s", recorder.Body.String())
+		return nil
+	}
+
+	laptop := login("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:131.0) Gecko/20100101 Firefox/131.0")
+	phone := login("Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36")
+
+	req := httptest.NewRequest(http.MethodPost, authShared.LinkApiSessions(), nil)
+	req.AddCookie(laptop)
+	recorder := httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	body := recorder.Body.String()
+	for _, expected := range []string{`"device":"Firefox on Windows"`, `"device":"Chrome on Android"`, `"current":true`, `"current":false`} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected %s in the sessions, got %s", expected, body)
+		}
+	}
+	if strings.Contains(body, laptop.Value) || strings.Contains(body, phone.Value) {
+		t.Fatalf("expected the auth tokens not to be exposed, got %s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, authShared.LinkApiSessionsRevokeOthers(), nil)
+	req.AddCookie(laptop)
+	recorder = httptest.NewRecorder()
+	authShared.Router().ServeHTTP(recorder, req)
+
+	if !strings.Contains(recorder.Body.String(), `"revoked":1`) {
+		t.Fatalf("expected the other session to be revoked, got %s", recorder.Body.String())
+	}
+	if _, ok := store.Sessions[phone.Value]; ok {
+		t.Fatal("expected the phone session to be deleted")
+	}
+	if _, ok := store.Sessions[laptop.Value]; !ok {
+		t.Fatal("expected the current session to be kept")
+	}
+}
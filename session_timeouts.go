@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateSessionTimeouts validates the optional session lifetime and idle
+// timeout shared by both authentication flows. They are enforced on the
+// timestamps of the stored sessions, which the legacy callbacks do not keep,
+// and JWT access tokens have their own expiration.
+func validateSessionTimeouts(lifetime time.Duration, idleTimeout time.Duration, store types.SessionStore, jwt *types.JWTConfig) error {
+	if lifetime < 0 {
+		return errors.New("auth: SessionLifetime cannot be negative")
+	}
+
+	if idleTimeout < 0 {
+		return errors.New("auth: SessionIdleTimeout cannot be negative")
+	}
+
+	if lifetime == 0 && idleTimeout == 0 {
+		return nil
+	}
+
+	if jwt != nil {
+		return errors.New("auth: SessionLifetime and SessionIdleTimeout cannot be used with JWT, use JWT Expiration instead")
+	}
+
+	if store == nil {
+		return errors.New("auth: SessionLifetime and SessionIdleTimeout require a SessionStore")
+	}
+
+	return nil
+}
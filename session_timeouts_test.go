@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dracory/auth/internal/testutils"
+	"github.com/dracory/auth/middlewares"
+	"github.com/dracory/auth/types"
+)
+
+func TestValidateSessionTimeouts(t *testing.T) {
+	store := testutils.NewSessionStore()
+	jwt := &types.JWTConfig{}
+
+	tests := []struct {
+		name        string
+		lifetime    time.Duration
+		idleTimeout time.Duration
+		store       types.SessionStore
+		jwt         *types.JWTConfig
+		expected    string
+	}{
+		{"not configured", 0, 0, nil, nil, ""},
+		{"valid", 8 * time.Hour, 30 * time.Minute, store, nil, ""},
+		{"negative lifetime", -time.Hour, 0, store, nil, "auth: SessionLifetime cannot be negative"},
+		{"negative idle timeout", 0, -time.Hour, store, nil, "auth: SessionIdleTimeout cannot be negative"},
+		{"no store", 0, 30 * time.Minute, nil, nil, "auth: SessionLifetime and SessionIdleTimeout require a SessionStore"},
+		{"jwt", 8 * time.Hour, 0, store, jwt, "auth: SessionLifetime and SessionIdleTimeout cannot be used with JWT, use JWT Expiration instead"},
+	}
+
+	for _, tt := range tests {
+		err := validateSessionTimeouts(tt.lifetime, tt.idleTimeout, tt.store, tt.jwt)
+		if tt.expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != "" && (err == nil || err.Error() != tt.expected) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestMiddlewares_SessionIdleTimeout(t *testing.T) {
+	store := testutils.NewSessionStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = store
+	config.SessionIdleTimeout = 30 * time.Minute
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	store.Sessions["active"] = types.Session{ID: "active", UserID: "user-1", CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-10 * time.Minute)}
+	store.Sessions["idle"] = types.Session{ID: "idle", UserID: "user-1", CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-31 * time.Minute)}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(handler http.Handler, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+		req.AddCookie(&http.Cookie{Name: types.CookieName, Value: token})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	web := middlewares.WebAuthOrRedirectMiddleware(next, authShared)
+
+	if recorder := serve(web, "idle"); recorder.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the idle session to be rejected, got %d", recorder.Code)
+	}
+
+	recorder := serve(web, "active")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the active session to be accepted, got %d", recorder.Code)
+	}
+	if cookies := recorder.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != types.CookieName || cookies[0].Value != "active" {
+		t.Fatalf("expected the auth cookie to be re-issued, got %+v", cookies)
+	}
+	if !store.Sessions["active"].LastSeenAt.After(now.Add(-time.Minute)) {
+		t.Fatalf("expected the session to be touched, got %v", store.Sessions["active"].LastSeenAt)
+	}
+
+	// Touched just now, so the next request neither writes nor re-issues
+	if recorder := serve(web, "active"); len(recorder.Result().Cookies()) != 0 {
+		t.Fatalf("expected the touch to be throttled, got %+v", recorder.Result().Cookies())
+	}
+
+	api := middlewares.ApiAuthOrErrorMiddleware(next, authShared)
+	if recorder := serve(api, "idle"); recorder.Code != http.StatusOK || recorder.Body.Len() == 0 {
+		t.Fatalf("expected an unauthenticated response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(api, "active"); recorder.Code != http.StatusOK || recorder.Body.Len() != 0 {
+		t.Fatalf("expected the active session to be accepted, got %s", recorder.Body.String())
+	}
+}
+
+func TestMiddlewares_SessionLifetime(t *testing.T) {
+	store := testutils.NewSessionStore()
+
+	config := testutils.NewUsernameAndPasswordConfigForTest()
+	config.SessionStore = store
+	config.SessionLifetime = 8 * time.Hour
+
+	authShared, err := NewUsernameAndPasswordAuth(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Created before the lifetime was lowered, still within its stored expiry
+	now := time.Now()
+	store.Sessions["old"] = types.Session{ID: "old", UserID: "user-1", CreatedAt: now.Add(-9 * time.Hour), LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(&http.Cookie{Name: types.CookieName, Value: "old"})
+	recorder := httptest.NewRecorder()
+	middlewares.WebAuthOrRedirectMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("expected the session past its lifetime to be rejected")
+	}), authShared).ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a redirect to the login, got %d", recorder.Code)
+	}
+}
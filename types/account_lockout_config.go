@@ -0,0 +1,63 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultAccountLockoutMaxFailures is the number of failed sign ins
+	// before an account is first locked when AccountLockoutConfig.MaxFailures
+	// is not set.
+	DefaultAccountLockoutMaxFailures = 5
+
+	// DefaultAccountLockoutBaseDelay is how long an account is first locked
+	// when AccountLockoutConfig.BaseDelay is not set.
+	DefaultAccountLockoutBaseDelay = time.Minute
+
+	// DefaultAccountLockoutMaxDelay caps the lock when
+	// AccountLockoutConfig.MaxDelay is not set.
+	DefaultAccountLockoutMaxDelay = time.Hour
+
+	// DefaultAccountLockoutResetAfter is how long failures are remembered
+	// when AccountLockoutConfig.ResetAfter is not set.
+	DefaultAccountLockoutResetAfter = 24 * time.Hour
+)
+
+// AccountLockoutConfig throttles sign ins per account, next to the per-IP
+// rate limit, so an attack spread over many addresses is throttled as well.
+// Failures are counted against what the user typed, the email address or
+// phone number, whether or not an account exists for it, so the lockout
+// does not tell an attacker which accounts exist. Successful sign ins clear
+// the count.
+//
+// Once MaxFailures is reached the account is locked for BaseDelay, doubling
+// with every further failure up to MaxDelay. Attempts while locked are
+// refused without checking the credentials, and are not counted.
+//
+// Wrong passwords, wrong codes of bound passwordless login flows and wrong
+// two-factor codes are counted; the latter against the user ID, as the
+// password was right. The failures are counted atomically in the
+// CounterStore of the config, and the locks kept in the temporary key
+// store.
+//
+// It can be added to both ConfigPasswordless and ConfigUsernameAndPassword.
+type AccountLockoutConfig struct {
+	MaxFailures int           // default: DefaultAccountLockoutMaxFailures
+	BaseDelay   time.Duration // default: DefaultAccountLockoutBaseDelay
+	MaxDelay    time.Duration // default: DefaultAccountLockoutMaxDelay
+	ResetAfter  time.Duration // default: DefaultAccountLockoutResetAfter
+
+	// FuncAccountLocked is called when the account is locked, e.g. to flag
+	// it or alert the security team. The account is the email address,
+	// phone number or, for two-factor codes, the user ID. Optional
+	FuncAccountLocked func(ctx context.Context, account string, lockedUntil time.Time, options UserAuthOptions) (err error)
+
+	// FuncUnlockEmailSend sends the link unlocking the account to its owner
+	// when the account is first locked by wrong passwords or login codes;
+	// two-factor locks expire by themselves. It is called for unknown
+	// accounts too, and should send nothing for them. The link is built from
+	// the Endpoint, which should be absolute. When set, the account unlock
+	// page is enabled. Optional
+	FuncUnlockEmailSend func(ctx context.Context, account string, unlockLink string, options UserAuthOptions) (err error)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 // AuthSharedInterface defines the common behavior shared by all auth modes.
@@ -16,12 +17,20 @@ type AuthSharedInterface interface {
 	IsRegistrationEnabled() bool
 	IsPasswordless() bool
 	IsVerificationEnabled() bool
+	IsLoginCodeBindingEnabled() bool
 
 	// Middlewares for protecting or enriching routes.
 	WebAuthOrRedirectMiddleware(next http.Handler) http.Handler
 	// ApiAuthOrErrorMiddleware(next http.Handler) http.Handler
 	WebAppendUserIdIfExistsMiddleware(next http.Handler) http.Handler
 
+	// Middlewares for authorization and step-up re-authentication, used
+	// inside the ones above.
+	RequireRole(next http.Handler, roles ...string) http.Handler
+	RequireAnyPermission(next http.Handler, permissions ...string) http.Handler
+	RequireAllPermissions(next http.Handler, permissions ...string) http.Handler
+	RequireRecentAuth(next http.Handler, maxAge time.Duration) http.Handler
+
 	// Current user lookup from the request context.
 	GetCurrentUserID(r *http.Request) string
 
@@ -31,12 +40,31 @@ type AuthSharedInterface interface {
 	LinkRegister() string
 	LinkRegisterCodeVerify() string
 	LinkRedirectOnSuccess() string
+	LinkPasskeys() string
+	LinkSessions() string
+	LinkReauth() string
+	LinkAccountUnlock(token string) string
+	LinkOIDCLogin(provider string) string
+	LinkOIDCCallback(provider string) string
 
 	// API URL helpers
 	LinkApiLogin() string
 	LinkApiLogout() string
 	LinkApiRegister() string
 	LinkApiRegisterCodeVerify() string
+	LinkApiWebAuthnRegisterBegin() string
+	LinkApiWebAuthnRegisterFinish() string
+	LinkApiWebAuthnLoginBegin() string
+	LinkApiWebAuthnLoginFinish() string
+	LinkApiTokenRefresh() string
+	LinkApiReauth() string
+	LinkApiSessions() string
+	LinkApiSessionRevoke() string
+	LinkApiSessionsRevokeOthers() string
+	LinkApiApiKeys() string
+	LinkApiApiKeyCreate() string
+	LinkApiApiKeyRename() string
+	LinkApiApiKeyRevoke() string
 
 	// ======================================================================
 	// Accessors (Setters and Getters)
@@ -57,12 +85,23 @@ type AuthSharedInterface interface {
 	GetFuncTemporaryKeySet() func(key string, value string, expiresSeconds int) error
 	SetFuncTemporaryKeySet(fn func(key string, value string, expiresSeconds int) error)
 
+	GetFuncTemporaryKeyDelete() func(key string) error
+	SetFuncTemporaryKeyDelete(fn func(key string) error)
+
 	GetUseCookies() bool
 	SetUseCookies(useCookies bool)
 
 	GetFuncUserFindByAuthToken() func(ctx context.Context, token string, options UserAuthOptions) (userID string, err error)
 	SetFuncUserFindByAuthToken(fn func(ctx context.Context, token string, options UserAuthOptions) (userID string, err error))
 
+	GetSessionStore() SessionStore
+	SetSessionStore(store SessionStore)
+
+	GetSessionLifetime() time.Duration
+	SetSessionLifetime(lifetime time.Duration)
+	GetSessionIdleTimeout() time.Duration
+	SetSessionIdleTimeout(timeout time.Duration)
+
 	// Additional accessors used by internal API flows.
 	GetDisableRateLimit() bool
 	SetDisableRateLimit(disable bool)
@@ -79,6 +118,12 @@ type AuthSharedInterface interface {
 	GetFuncUserRegister() func(ctx context.Context, username, password, firstName, lastName string, options UserAuthOptions) error
 	SetFuncUserRegister(fn func(ctx context.Context, username, password, firstName, lastName string, options UserAuthOptions) error)
 
+	GetFuncUserRegisterWithPasswordHash() func(ctx context.Context, username, passwordHash, firstName, lastName string, options UserAuthOptions) error
+	SetFuncUserRegisterWithPasswordHash(fn func(ctx context.Context, username, passwordHash, firstName, lastName string, options UserAuthOptions) error)
+
+	GetFuncPasswordHash() func(password string) (string, error)
+	SetFuncPasswordHash(fn func(password string) (string, error))
+
 	GetFuncUserPasswordChange() func(ctx context.Context, userID, password string, options UserAuthOptions) error
 	SetFuncUserPasswordChange(fn func(ctx context.Context, userID, password string, options UserAuthOptions) error)
 
@@ -109,12 +154,77 @@ type AuthSharedInterface interface {
 	GetPasswordlessFuncEmailSend() func(ctx context.Context, email string, emailSubject, emailBody string) error
 	SetPasswordlessFuncEmailSend(fn func(ctx context.Context, email string, emailSubject, emailBody string) error)
 
+	GetPasswordlessChannel() PasswordlessChannel
+	SetPasswordlessChannel(channel PasswordlessChannel)
+
+	GetPasswordlessUserFindByPhone() func(ctx context.Context, phone string, options UserAuthOptions) (string, error)
+	SetPasswordlessUserFindByPhone(fn func(ctx context.Context, phone string, options UserAuthOptions) (string, error))
+
+	GetPasswordlessFuncSmsTemplateLoginCode() func(ctx context.Context, phone string, code string, options UserAuthOptions) string
+	SetPasswordlessFuncSmsTemplateLoginCode(fn func(ctx context.Context, phone string, code string, options UserAuthOptions) string)
+
+	GetPasswordlessFuncSmsSend() func(ctx context.Context, phone string, message string) error
+	SetPasswordlessFuncSmsSend(fn func(ctx context.Context, phone string, message string) error)
+
+	GetWebAuthn() *WebAuthnConfig
+	SetWebAuthn(config *WebAuthnConfig)
+
+	GetOIDC() *OIDCConfig
+	SetOIDC(config *OIDCConfig)
+
+	GetJWT() *JWTConfig
+	SetJWT(config *JWTConfig)
+
+	GetRefreshToken() *RefreshTokenConfig
+	SetRefreshToken(config *RefreshTokenConfig)
+
+	GetRememberMe() *RememberMeConfig
+	SetRememberMe(config *RememberMeConfig)
+
+	GetAuthTokenHashing() *AuthTokenHashingConfig
+	SetAuthTokenHashing(config *AuthTokenHashingConfig)
+
+	GetApiKeys() *ApiKeyConfig
+	SetApiKeys(config *ApiKeyConfig)
+
+	GetAccountLockout() *AccountLockoutConfig
+	SetAccountLockout(config *AccountLockoutConfig)
+
+	GetCounterStore() CounterStore
+	SetCounterStore(store CounterStore)
+
+	GetFuncUserRoles() func(ctx context.Context, userID string, options UserAuthOptions) ([]string, error)
+	SetFuncUserRoles(fn func(ctx context.Context, userID string, options UserAuthOptions) ([]string, error))
+
+	GetFuncUserPermissions() func(ctx context.Context, userID string, options UserAuthOptions) ([]string, error)
+	SetFuncUserPermissions(fn func(ctx context.Context, userID string, options UserAuthOptions) ([]string, error))
+
+	GetMagicLink() *MagicLinkConfig
+	SetMagicLink(config *MagicLinkConfig)
+
+	GetFuncUserTotpSecretFind() func(ctx context.Context, userID string, options UserAuthOptions) (string, error)
+	SetFuncUserTotpSecretFind(fn func(ctx context.Context, userID string, options UserAuthOptions) (string, error))
+
+	GetFuncUserTotpSecretStore() func(ctx context.Context, userID string, secret string, options UserAuthOptions) error
+	SetFuncUserTotpSecretStore(fn func(ctx context.Context, userID string, secret string, options UserAuthOptions) error)
+
+	GetFuncUserRecoveryCodesStore() func(ctx context.Context, userID string, codeHashes []string, options UserAuthOptions) error
+	SetFuncUserRecoveryCodesStore(fn func(ctx context.Context, userID string, codeHashes []string, options UserAuthOptions) error)
+
+	GetFuncUserRecoveryCodeConsume() func(ctx context.Context, userID string, codeHash string, options UserAuthOptions) (bool, error)
+	SetFuncUserRecoveryCodeConsume(fn func(ctx context.Context, userID string, codeHash string, options UserAuthOptions) (bool, error))
+
+	GetTotpIssuer() string
+
 	GetFuncUserStoreAuthToken() func(ctx context.Context, token, userID string, options UserAuthOptions) error
 	SetFuncUserStoreAuthToken(fn func(ctx context.Context, token, userID string, options UserAuthOptions) error)
 
 	SetAuthCookie(w http.ResponseWriter, r *http.Request, token string)
 	RemoveAuthCookie(w http.ResponseWriter, r *http.Request)
 
+	SetRememberCookie(w http.ResponseWriter, r *http.Request, value string, expiresAt time.Time)
+	RemoveRememberCookie(w http.ResponseWriter, r *http.Request)
+
 	// Final authentication step helpers used by internal API flows.
 	AuthenticateViaUsername(w http.ResponseWriter, r *http.Request, email, firstName, lastName string)
 }
@@ -129,6 +239,16 @@ type AuthPasswordInterface interface {
 	LinkPasswordReset(token string) string
 	LinkApiPasswordRestore() string
 	LinkApiPasswordReset() string
+
+	// Two-factor (TOTP) verification URLs (web and API).
+	LinkLogin2faVerify() string
+	LinkApiLogin2faVerify() string
+
+	// Two-factor (TOTP) enrollment URLs (web and API).
+	LinkTwoFactorEnroll() string
+	LinkApiTwoFactorEnroll() string
+	LinkApiTwoFactorEnrollConfirm() string
+	LinkApiTwoFactorRecoveryCodes() string
 }
 
 // AuthPasswordlessInterface represents passwordless authentication flows.
@@ -0,0 +1,22 @@
+package types
+
+// MinAuthTokenPepperLength is the minimum length of AuthTokenHashingConfig.Pepper.
+const MinAuthTokenPepperLength = 32
+
+// AuthTokenHashingConfig stores auth tokens hashed instead of as they are
+// handed to the client. The session store, or FuncUserStoreAuthToken and
+// FuncUserFindByAuthToken, then only ever see the hex encoded HMAC-SHA256 of
+// the token keyed with the pepper, so a leaked sessions table cannot be used
+// to sign in.
+//
+// It can be added to both ConfigPasswordless and ConfigUsernameAndPassword.
+type AuthTokenHashingConfig struct {
+	// Pepper is the secret key of the HMAC, at least 32 characters. Keep it
+	// out of the database; changing it ends every session.
+	Pepper string
+
+	// AcceptUnhashed also looks up tokens as they are, so sessions stored
+	// before hashing was enabled keep working. Turn it off once they have
+	// expired.
+	AcceptUnhashed bool
+}
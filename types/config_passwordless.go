@@ -14,6 +14,7 @@ type ConfigPasswordless struct {
 	FuncLayout              func(content string) string
 	FuncTemporaryKeyGet     func(key string) (value string, err error)
 	FuncTemporaryKeySet     func(key string, value string, expiresSeconds int) (err error)
+	FuncTemporaryKeyDelete  func(key string) (err error) // optional: removes used codes (default: overwrites them with an empty value)
 	FuncUserFindByAuthToken func(ctx context.Context, sessionID string, options UserAuthOptions) (userID string, err error)
 	FuncUserLogout          func(ctx context.Context, userID string, options UserAuthOptions) (err error)
 	FuncUserStoreAuthToken  func(ctx context.Context, sessionID string, userID string, options UserAuthOptions) error
@@ -21,15 +22,63 @@ type ConfigPasswordless struct {
 	UseCookies              bool
 	UseLocalStorage         bool
 	CookieConfig            *CookieConfig
+	// SessionStore replaces FuncUserStoreAuthToken, FuncUserFindByAuthToken and
+	// FuncUserLogout, which become optional when it is set. Optional
+	SessionStore SessionStore
+	// SessionLifetime is the absolute lifetime of a session, counted from the
+	// login (default: 2 hours). Requires a SessionStore. Optional
+	SessionLifetime time.Duration
+	// SessionIdleTimeout ends a session after this long without requests, the
+	// middlewares extending it on activity. Requires a SessionStore. Optional
+	SessionIdleTimeout time.Duration
 	// Rate limiting options
 	DisableRateLimit   bool                                                                                 // Set to true to disable rate limiting (not recommended for production)
 	FuncCheckRateLimit func(ip string, endpoint string) (allowed bool, retryAfter time.Duration, err error) // Optional: override default rate limiter
-	MaxLoginAttempts   int                                                                                  // Maximum attempts before lockout (default: 5)
-	LockoutDuration    time.Duration                                                                        // Duration to lock after max attempts (default: 15 minutes)
+	// Optional: with FuncCheckRateLimit, count only failed attempts.
+	// FuncCheckRateLimit counts every request it allows, atomically so
+	// concurrent requests cannot get past the limit, and a successful login
+	// is reported to FuncRateLimitReset to clear the attempts
+	FuncRateLimitReset func(ip string, endpoint string) (err error)
+	MaxLoginAttempts   int           // Maximum attempts before lockout (default: 5)
+	LockoutDuration    time.Duration // Duration to lock after max attempts (default: 15 minutes)
+	// RateLimiter replaces the default sliding log limiter, e.g. with
+	// utils.NewTokenBucketRateLimiter or utils.NewGCRARateLimiter. It cannot
+	// be combined with FuncCheckRateLimit. Optional
+	RateLimiter RateLimiter
+	// RateLimitPolicies overrides the policies of endpoints by name, e.g.
+	// "login", "register" or "password_restore". Endpoints without one use
+	// the built-in policy of the endpoint, or one of MaxLoginAttempts
+	// failures per LockoutDuration. Optional
+	RateLimitPolicies map[string]RateLimitPolicy
 	// CSRF Protection
 	EnableCSRFProtection bool
 	CSRFSecret           string
 	Logger               *slog.Logger
+	// Passkeys (WebAuthn), optional
+	WebAuthn *WebAuthnConfig
+	// Sign in with OpenID Connect providers, optional
+	OIDC *OIDCConfig
+	// Signed JWT access tokens verified locally instead of opaque tokens, optional
+	JWT *JWTConfig
+	// Rotating refresh tokens returned by the API logins, optional
+	RefreshToken *RefreshTokenConfig
+	// Long-lived "remember me" logins, optional (requires cookies)
+	RememberMe *RememberMeConfig
+	// Store auth tokens hashed with a pepper, optional
+	AuthTokenHashing *AuthTokenHashingConfig
+	// Personal API keys with scopes for machine clients, optional
+	ApiKeys *ApiKeyConfig
+	// Per-account lockout after repeated failed sign ins, optional
+	AccountLockout *AccountLockoutConfig
+	// Atomic counters of the account lockout, code attempts and used TOTP
+	// codes, e.g. utils.NewRedisCounterStore, shared by all instances.
+	// Defaults to counters over the temporary key store, which are atomic
+	// within the process only. Optional
+	CounterStore CounterStore
+	// Roles and permissions of a user, checked by the RequireRole,
+	// RequireAnyPermission and RequireAllPermissions middlewares. Optional
+	FuncUserRoles       func(ctx context.Context, userID string, options UserAuthOptions) (roles []string, err error)
+	FuncUserPermissions func(ctx context.Context, userID string, options UserAuthOptions) (permissions []string, err error)
 
 	// ===== END: shared by all implementations
 
@@ -39,5 +88,20 @@ type ConfigPasswordless struct {
 	FuncEmailTemplateRegisterCode func(ctx context.Context, email string, registerLink string, options UserAuthOptions) string // optional
 	FuncEmailSend                 func(ctx context.Context, email string, emailSubject string, emailBody string) (err error)
 	FuncUserRegister              func(ctx context.Context, email string, firstName string, lastName string, options UserAuthOptions) (err error)
+	// DisableLoginCodeBinding accepts emailed login codes without the flow ID
+	// returned by api/login. Unbound codes are looked up by the code itself,
+	// so wrong codes cannot be counted against the issued code: there is no
+	// per-code attempt limit, and only the per-IP rate limit protects them.
+	// Optional
+	DisableLoginCodeBinding bool
+	// One-time login links sent along with the codes, optional
+	MagicLink *MagicLinkConfig
+	// Channel the login codes are sent through (default: PasswordlessChannelEmail).
+	// With PasswordlessChannelSms, FuncUserFindByPhone and FuncSmsSend replace
+	// FuncUserFindByEmail and FuncEmailSend, and registration is not available
+	Channel                  PasswordlessChannel
+	FuncUserFindByPhone      func(ctx context.Context, phone string, options UserAuthOptions) (userID string, err error) // phone in E.164 format, e.g. +447700900123
+	FuncSmsSend              func(ctx context.Context, phone string, message string) (err error)
+	FuncSmsTemplateLoginCode func(ctx context.Context, phone string, code string, options UserAuthOptions) string // optional
 	// ===== END: passwordless options
 }
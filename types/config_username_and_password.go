@@ -14,21 +14,70 @@ type ConfigUsernameAndPassword struct {
 	FuncLayout              func(content string) string
 	FuncTemporaryKeyGet     func(key string) (value string, err error)
 	FuncTemporaryKeySet     func(key string, value string, expiresSeconds int) (err error)
+	FuncTemporaryKeyDelete  func(key string) (err error) // optional: removes used codes (default: overwrites them with an empty value)
 	FuncUserStoreAuthToken  func(ctx context.Context, sessionID string, userID string, options UserAuthOptions) error
 	FuncUserFindByAuthToken func(ctx context.Context, sessionID string, options UserAuthOptions) (userID string, err error)
 	UrlRedirectOnSuccess    string
 	UseCookies              bool
 	UseLocalStorage         bool
 	CookieConfig            *CookieConfig
+	// SessionStore replaces FuncUserStoreAuthToken, FuncUserFindByAuthToken and
+	// FuncUserLogout, which become optional when it is set. Optional
+	SessionStore SessionStore
+	// SessionLifetime is the absolute lifetime of a session, counted from the
+	// login (default: 2 hours). Requires a SessionStore. Optional
+	SessionLifetime time.Duration
+	// SessionIdleTimeout ends a session after this long without requests, the
+	// middlewares extending it on activity. Requires a SessionStore. Optional
+	SessionIdleTimeout time.Duration
 	// Rate limiting options
 	DisableRateLimit   bool                                                                                 // Set to true to disable rate limiting (not recommended for production)
 	FuncCheckRateLimit func(ip string, endpoint string) (allowed bool, retryAfter time.Duration, err error) // Optional: override default rate limiter
-	MaxLoginAttempts   int                                                                                  // Maximum attempts before lockout (default: 5)
-	LockoutDuration    time.Duration                                                                        // Duration to lock after max attempts (default: 15 minutes)
+	// Optional: with FuncCheckRateLimit, count only failed attempts.
+	// FuncCheckRateLimit counts every request it allows, atomically so
+	// concurrent requests cannot get past the limit, and a successful login
+	// is reported to FuncRateLimitReset to clear the attempts
+	FuncRateLimitReset func(ip string, endpoint string) (err error)
+	MaxLoginAttempts   int           // Maximum attempts before lockout (default: 5)
+	LockoutDuration    time.Duration // Duration to lock after max attempts (default: 15 minutes)
+	// RateLimiter replaces the default sliding log limiter, e.g. with
+	// utils.NewTokenBucketRateLimiter or utils.NewGCRARateLimiter. It cannot
+	// be combined with FuncCheckRateLimit. Optional
+	RateLimiter RateLimiter
+	// RateLimitPolicies overrides the policies of endpoints by name, e.g.
+	// "login", "register" or "password_restore". Endpoints without one use
+	// the built-in policy of the endpoint, or one of MaxLoginAttempts
+	// failures per LockoutDuration. Optional
+	RateLimitPolicies map[string]RateLimitPolicy
 	// CSRF Protection
 	EnableCSRFProtection bool
 	CSRFSecret           string
 	Logger               *slog.Logger
+	// Passkeys (WebAuthn), optional
+	WebAuthn *WebAuthnConfig
+	// Sign in with OpenID Connect providers, optional
+	OIDC *OIDCConfig
+	// Signed JWT access tokens verified locally instead of opaque tokens, optional
+	JWT *JWTConfig
+	// Rotating refresh tokens returned by the API logins, optional
+	RefreshToken *RefreshTokenConfig
+	// Long-lived "remember me" logins, optional (requires cookies)
+	RememberMe *RememberMeConfig
+	// Store auth tokens hashed with a pepper, optional
+	AuthTokenHashing *AuthTokenHashingConfig
+	// Personal API keys with scopes for machine clients, optional
+	ApiKeys *ApiKeyConfig
+	// Per-account lockout after repeated failed sign ins, optional
+	AccountLockout *AccountLockoutConfig
+	// Atomic counters of the account lockout, code attempts and used TOTP
+	// codes, e.g. utils.NewRedisCounterStore, shared by all instances.
+	// Defaults to counters over the temporary key store, which are atomic
+	// within the process only. Optional
+	CounterStore CounterStore
+	// Roles and permissions of a user, checked by the RequireRole,
+	// RequireAnyPermission and RequireAllPermissions middlewares. Optional
+	FuncUserRoles       func(ctx context.Context, userID string, options UserAuthOptions) (roles []string, err error)
+	FuncUserPermissions func(ctx context.Context, userID string, options UserAuthOptions) (permissions []string, err error)
 
 	// ===== END: shared by all implementations
 
@@ -44,5 +93,34 @@ type ConfigUsernameAndPassword struct {
 	FuncUserRegister                 func(ctx context.Context, username string, password string, first_name string, last_name string, options UserAuthOptions) (err error)
 	PasswordStrength                 *PasswordStrengthConfig
 	LabelUsername                    string
+	// FuncUserRegisterWithPasswordHash replaces FuncUserRegister and receives
+	// the password hashed with FuncPasswordHash, so the plaintext password is
+	// never stored, not even in the temporary key store while the
+	// registration waits for verification. Optional
+	FuncUserRegisterWithPasswordHash func(ctx context.Context, username string, passwordHash string, firstName string, lastName string, options UserAuthOptions) (err error)
+	// FuncPasswordHash hashes the password for FuncUserRegisterWithPasswordHash
+	// (default: bcrypt). Optional
+	FuncPasswordHash func(password string) (passwordHash string, err error)
 	// ===== END: username(email) and password options
+
+	// ===== START: two-factor authentication (TOTP) options
+	// FuncUserTotpSecretFind returns the base32 TOTP secret of the user, or
+	// an empty string if the user has not enabled two-factor authentication.
+	// When set, users with a secret must enter a TOTP code after their password.
+	FuncUserTotpSecretFind func(ctx context.Context, userID string, options UserAuthOptions) (secret string, err error) // optional
+	// FuncUserTotpSecretStore persists a newly enrolled TOTP secret for the
+	// user. When set, authenticated users can enable two-factor authentication
+	// from the enrollment page. It is only called after the user has confirmed
+	// a valid code from their authenticator app.
+	FuncUserTotpSecretStore func(ctx context.Context, userID string, secret string, options UserAuthOptions) (err error) // optional
+	// FuncUserRecoveryCodesStore replaces the user's recovery codes with the
+	// given SHA-256 hashes. Plain codes are only shown to the user once.
+	FuncUserRecoveryCodesStore func(ctx context.Context, userID string, codeHashes []string, options UserAuthOptions) (err error) // optional
+	// FuncUserRecoveryCodeConsume removes the recovery code hash from the
+	// user's unused codes, reporting whether it was found. When set, users can
+	// sign in with a recovery code instead of a TOTP code.
+	FuncUserRecoveryCodeConsume func(ctx context.Context, userID string, codeHash string, options UserAuthOptions) (consumed bool, err error) // optional
+	// TotpIssuer is the issuer name shown in authenticator apps (default: the request host)
+	TotpIssuer string // optional
+	// ===== END: two-factor authentication (TOTP) options
 }
@@ -3,3 +3,6 @@ package types
 type AuthenticatedUserID struct{}
 
 const CookieName = "authtoken"
+
+// RememberCookieName is the name of the persistent remember-me cookie.
+const RememberCookieName = "authremember"
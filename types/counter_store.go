@@ -0,0 +1,21 @@
+package types
+
+import "time"
+
+// CounterStore is a key-value store of counters that expire, shared by all
+// instances of the application, e.g. Redis. It backs the SharedRateLimiter
+// of the utils package, and the account lockout and attempt counters when
+// set as the CounterStore of the configs.
+type CounterStore interface {
+	// Increment atomically adds one to the counter of the key and returns
+	// the new count. A missing or expired counter starts at zero and expires
+	// after ttl; incrementing it does not extend the ttl.
+	Increment(key string, ttl time.Duration) (count int64, err error)
+
+	// Get returns the count of the key and how long until it expires, zero
+	// when it is missing or expired.
+	Get(key string) (count int64, ttl time.Duration, err error)
+
+	// Delete removes the counter of the key.
+	Delete(key string) error
+}
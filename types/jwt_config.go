@@ -0,0 +1,46 @@
+package types
+
+import (
+	"context"
+	"crypto"
+	"time"
+)
+
+// JWT signing algorithms supported for access tokens.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// DefaultJWTExpiration is the lifetime of a JWT access token when
+// JWTConfig.Expiration is not set.
+const DefaultJWTExpiration = time.Hour
+
+// JWTConfig switches the auth tokens from opaque random strings, looked up
+// with FuncUserFindByAuthToken on every request, to signed JWTs which the
+// middlewares verify locally. It can be added to both ConfigPasswordless and
+// ConfigUsernameAndPassword.
+//
+// A JWT stays valid until it expires, so keep Expiration short and set
+// FuncTokenRevoked when tokens must be revocable before then.
+type JWTConfig struct {
+	Algorithm string // HS256 (default), RS256 or EdDSA
+
+	Secret     []byte            // HS256: shared secret, at least 32 bytes
+	PrivateKey crypto.PrivateKey // RS256: *rsa.PrivateKey, EdDSA: ed25519.PrivateKey
+	KeyID      string            // optional "kid" header, useful when rotating keys
+
+	Issuer     string        // "iss" claim, verified when set
+	Audience   string        // "aud" claim, verified when set
+	Expiration time.Duration // default: DefaultJWTExpiration
+
+	// FuncClaims returns additional claims added to the token, e.g. roles.
+	// Registered claims (iss, sub, aud, exp, nbf, iat, jti) cannot be overridden.
+	FuncClaims func(ctx context.Context, userID string, options UserAuthOptions) (claims map[string]any, err error) // optional
+
+	// FuncTokenRevoked reports whether the token with the given ID ("jti"
+	// claim) has been revoked. It is called on every authenticated request,
+	// so it should be backed by a fast store such as a cache.
+	FuncTokenRevoked func(ctx context.Context, tokenID string, options UserAuthOptions) (revoked bool, err error) // optional
+}
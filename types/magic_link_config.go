@@ -0,0 +1,28 @@
+package types
+
+import "context"
+
+// MinMagicLinkSecretLength is the minimum length of MagicLinkConfig.Secret.
+const MinMagicLinkSecretLength = 32
+
+// MagicLinkConfig adds a one-time login link to the passwordless login email,
+// next to the code. The link carries a random token, signed with the secret,
+// that the code is stored under, so the link and the typed code are two ways
+// to redeem the same code: whichever is used first consumes it.
+//
+// It can be added to ConfigPasswordless.
+type MagicLinkConfig struct {
+	// Secret is the key the links are signed with, at least 32 characters.
+	// Changing it invalidates the links already sent.
+	Secret string
+
+	// RequireSameBrowser only accepts the link in the browser the code was
+	// requested from, using a cookie set by api/login, so a forwarded or
+	// intercepted link cannot be used elsewhere. The typed code keeps
+	// working in any browser.
+	RequireSameBrowser bool
+
+	// FuncEmailTemplate builds the login email with both the code and the
+	// link. Optional, a default template is used when not set.
+	FuncEmailTemplate func(ctx context.Context, email string, code string, link string, options UserAuthOptions) string
+}
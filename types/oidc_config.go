@@ -0,0 +1,66 @@
+package types
+
+import (
+	"context"
+	"net/http"
+)
+
+// OIDCProvider is an OpenID Connect identity provider users can sign in with,
+// e.g. Google or Microsoft.
+type OIDCProvider struct {
+	Name        string // Used in the login/{name} and callback/{name} routes, e.g. "google"
+	DisplayName string // Label of the login button (default: Name)
+
+	Issuer       string // e.g. "https://accounts.google.com"
+	ClientID     string
+	ClientSecret string   // optional for public clients, PKCE is always used
+	Scopes       []string // default: openid, email, profile
+
+	// RedirectURL is the callback URL registered with the provider
+	// (default: the callback/{name} route on the request host)
+	RedirectURL string
+
+	// Endpoints are discovered from Issuer + "/.well-known/openid-configuration"
+	// unless all three are set
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+
+	HTTPClient *http.Client // optional, default: http.DefaultClient
+}
+
+// ExternalIdentity is the identity asserted by an OIDC provider in the
+// verified ID token.
+type ExternalIdentity struct {
+	Provider      string // OIDCProvider.Name
+	Issuer        string
+	Subject       string // stable user ID at the provider, use it with Issuer as the lookup key
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+}
+
+// OIDCConfig enables signing in with OpenID Connect providers. It can be
+// added to both ConfigPasswordless and ConfigUsernameAndPassword.
+type OIDCConfig struct {
+	Providers []OIDCProvider
+
+	// FuncUserFindOrCreateByExternalIdentity returns the ID of the user linked
+	// to the identity, creating or linking the user when needed
+	FuncUserFindOrCreateByExternalIdentity func(ctx context.Context, identity ExternalIdentity, options UserAuthOptions) (userID string, err error)
+}
+
+// Provider returns the provider with the given name, or nil if there is none.
+func (c *OIDCConfig) Provider(name string) *OIDCProvider {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Providers {
+		if c.Providers[i].Name == name {
+			return &c.Providers[i]
+		}
+	}
+	return nil
+}
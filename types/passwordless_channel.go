@@ -0,0 +1,15 @@
+package types
+
+// PasswordlessChannel is the channel the passwordless login codes are sent
+// through.
+type PasswordlessChannel string
+
+const (
+	// PasswordlessChannelEmail sends the codes by email (default).
+	PasswordlessChannelEmail PasswordlessChannel = "email"
+
+	// PasswordlessChannelSms sends shorter numeric codes by text message to
+	// the phone number of the user. The codes are always bound to the login
+	// flow they were issued for, see ConfigPasswordless.DisableLoginCodeBinding.
+	PasswordlessChannelSms PasswordlessChannel = "sms"
+)
@@ -0,0 +1,132 @@
+package types
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// AuthMethod is how the user proved their identity when the session or
+// token of the request was issued.
+type AuthMethod string
+
+const (
+	AuthMethodUnknown      AuthMethod = ""
+	AuthMethodPassword     AuthMethod = "password"
+	AuthMethodCode         AuthMethod = "code" // passwordless login or registration code
+	AuthMethodMagicLink    AuthMethod = "magic_link"
+	AuthMethodPasskey      AuthMethod = "passkey"
+	AuthMethodOIDC         AuthMethod = "oidc"
+	AuthMethodRememberMe   AuthMethod = "remember_me"
+	AuthMethodRefreshToken AuthMethod = "refresh_token"
+	AuthMethodApiKey       AuthMethod = "api_key"
+)
+
+// MFALevel is the number of factors the user signed in with.
+type MFALevel int
+
+const (
+	MFALevelUnknown      MFALevel = 0
+	MFALevelSingleFactor MFALevel = 1
+	MFALevelMultiFactor  MFALevel = 2
+)
+
+// Principal is the authenticated caller of a request, appended to the
+// context by the auth middlewares. See PrincipalFromContext.
+//
+// Values the session store or token does not record are left empty, e.g.
+// with the legacy session callbacks only UserID is known.
+type Principal struct {
+	UserID string
+
+	// SessionID is the ID of the session in the SessionStore, or the token
+	// ID of a JWT access token. It is empty for API keys.
+	SessionID string
+
+	AuthMethod AuthMethod
+
+	// AuthenticatedAt is when the user last proved their identity. It is
+	// zero when unknown, and for sessions restored from remember-me or
+	// refresh tokens, so they do not count as a recent sign in.
+	AuthenticatedAt time.Time
+
+	MFALevel MFALevel
+
+	// Scopes limits what the request may do. It is nil when the request is
+	// not scope limited, e.g. for sessions; see ApiKeyScopesFromContext.
+	Scopes []string
+
+	// ImpersonatorID is the user acting as UserID, for impersonation
+	// sessions created by the application with Session.ImpersonatorID set.
+	ImpersonatorID string
+
+	// roles and permissions look up the authorization of the user on first
+	// use, see WithAuthorizationLookups
+	roles       func() ([]string, error)
+	permissions func() ([]string, error)
+}
+
+// IsImpersonated reports whether another user is acting as the user.
+func (p Principal) IsImpersonated() bool {
+	return p.ImpersonatorID != ""
+}
+
+// WithAuthorizationLookups returns a copy of the principal looking up the
+// roles and permissions of the user with the functions. The middlewares set
+// them to FuncUserRoles and FuncUserPermissions, cached for the request.
+func (p Principal) WithAuthorizationLookups(roles func() ([]string, error), permissions func() ([]string, error)) Principal {
+	p.roles = roles
+	p.permissions = permissions
+	return p
+}
+
+// Roles returns the roles of the user, looked up with FuncUserRoles on the
+// first call. It is empty when there is no lookup.
+func (p Principal) Roles() ([]string, error) {
+	if p.roles == nil {
+		return nil, nil
+	}
+	return p.roles()
+}
+
+// Permissions returns the permissions of the user, looked up with
+// FuncUserPermissions on the first call. It is empty when there is no
+// lookup.
+func (p Principal) Permissions() ([]string, error) {
+	if p.permissions == nil {
+		return nil, nil
+	}
+	return p.permissions()
+}
+
+// HasRole reports whether the user has the role. A failed lookup counts as
+// lacking it.
+func (p Principal) HasRole(role string) bool {
+	roles, err := p.Roles()
+	return err == nil && slices.Contains(roles, role)
+}
+
+// HasPermission reports whether the user has the permission. A failed
+// lookup counts as lacking it.
+func (p Principal) HasPermission(permission string) bool {
+	permissions, err := p.Permissions()
+	return err == nil && slices.Contains(permissions, permission)
+}
+
+type principalContextKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the authenticated
+// caller of the request.
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller of the request, or
+// nil when the request is not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	if !ok {
+		return nil
+	}
+	return &principal
+}
@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// RateLimitPolicy is how many failed attempts an endpoint allows. Burst
+// failures are allowed at once, and another one every Refill after that.
+// Once the attempts are used up, the client is locked out for Lockout, or
+// until the next attempt is available when Lockout is zero.
+type RateLimitPolicy struct {
+	Burst   int           // at least 1
+	Refill  time.Duration // positive
+	Lockout time.Duration // optional
+}
+
+// RateLimitResult represents the result of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// RateLimiter counts attempts per key, usually the client IP, and endpoint
+// against the policy of the endpoint. Allow takes an attempt atomically
+// when it allows a request, so concurrent requests cannot get past the
+// limit; a denied request is not counted. The router calls Reset after a
+// successful request, so only failed attempts are left counted.
+//
+// The utils package ships sliding log, token bucket and GCRA
+// implementations.
+type RateLimiter interface {
+	Allow(key string, endpoint string, policy RateLimitPolicy) RateLimitResult
+	Reset(key string, endpoint string)
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRefreshTokenExpiration is the lifetime of a refresh token when
+// RefreshTokenConfig.Expiration is not set.
+const DefaultRefreshTokenExpiration = 30 * 24 * time.Hour
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the token
+// is stored, the token itself is returned to the client once.
+type RefreshToken struct {
+	TokenHash string // hex encoded SHA-256 of the token
+	FamilyID  string // shared by every token rotated from the same login
+	UserID    string
+	SessionID string // the session issued with the token, empty with JWT
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Used      bool // set once the token has been exchanged for a new pair
+}
+
+// RefreshTokenConfig enables refresh tokens. Logins through the API return a
+// refresh token next to the auth token, which can be exchanged at the
+// api/token/refresh endpoint for a new pair. Each refresh token can only be
+// used once: a used token showing up again means it was stolen, so every
+// token of its family is revoked and the user has to log in again.
+//
+// It can be added to both ConfigPasswordless and ConfigUsernameAndPassword.
+type RefreshTokenConfig struct {
+	Expiration time.Duration // default: DefaultRefreshTokenExpiration
+
+	FuncRefreshTokenStore      func(ctx context.Context, token RefreshToken, options UserAuthOptions) (err error)
+	FuncRefreshTokenFindByHash func(ctx context.Context, tokenHash string, options UserAuthOptions) (token *RefreshToken, err error) // nil when not found
+	// FuncRefreshTokenMarkUsed sets Used on the token, reporting false if it
+	// was already set. It must be atomic (e.g. UPDATE ... WHERE used = false),
+	// so two concurrent refreshes with the same token cannot both succeed.
+	FuncRefreshTokenMarkUsed func(ctx context.Context, tokenHash string, options UserAuthOptions) (marked bool, err error)
+	// FuncRefreshTokenFindByFamily returns every token of the family, so the
+	// sessions linked to them can be ended when a used token is presented again.
+	FuncRefreshTokenFindByFamily func(ctx context.Context, familyID string, options UserAuthOptions) (tokens []RefreshToken, err error)
+	// FuncRefreshTokenFamilyRevoke deletes (or invalidates) every token of the family.
+	FuncRefreshTokenFamilyRevoke func(ctx context.Context, familyID string, options UserAuthOptions) (err error)
+	// FuncRefreshTokenRevokeAllForUser deletes (or invalidates) every token
+	// of the user, e.g. after a password reset.
+	FuncRefreshTokenRevokeAllForUser func(ctx context.Context, userID string, options UserAuthOptions) (err error)
+	// FuncRefreshTokenRevokeBySession deletes (or invalidates) every token
+	// of the families with a token linked to the session, when the user
+	// revokes it.
+	FuncRefreshTokenRevokeBySession func(ctx context.Context, sessionID string, options UserAuthOptions) (err error)
+}
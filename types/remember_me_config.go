@@ -0,0 +1,49 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRememberMeExpiration is the lifetime of a remember-me token when
+// RememberMeConfig.Expiration is not set.
+const DefaultRememberMeExpiration = 30 * 24 * time.Hour
+
+// RememberToken is a stored remember-me token. The cookie holds a selector,
+// used to look the token up, and a validator, of which only the SHA-256
+// hash is stored.
+type RememberToken struct {
+	Selector      string
+	ValidatorHash string // hex encoded SHA-256 of the validator
+	UserID        string
+	SessionID     string // the session started with the token, empty with JWT
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+// RememberMeConfig enables "remember me" logins. When the user ticks the
+// checkbox on the login page (or sends remember=1 to the API), a long-lived
+// cookie is set next to the auth cookie. Once the session is gone, the web
+// middlewares exchange it for a fresh session and a new remember-me token,
+// so each token can only be used once. A known selector with a wrong
+// validator means the cookie was stolen, so every token of the user is
+// revoked.
+//
+// It requires cookies and can be added to both ConfigPasswordless and
+// ConfigUsernameAndPassword.
+type RememberMeConfig struct {
+	Expiration time.Duration // default: DefaultRememberMeExpiration
+
+	FuncRememberTokenStore          func(ctx context.Context, token RememberToken, options UserAuthOptions) (err error)
+	FuncRememberTokenFindBySelector func(ctx context.Context, selector string, options UserAuthOptions) (token *RememberToken, err error) // nil when not found
+	// FuncRememberTokenDelete deletes the token, reporting false if it was
+	// already gone. It must be atomic (e.g. DELETE ... and check the rows
+	// affected), so two concurrent requests cannot both exchange the token.
+	FuncRememberTokenDelete func(ctx context.Context, selector string, options UserAuthOptions) (deleted bool, err error)
+	// FuncRememberTokenDeleteAllForUser deletes every token of the user, e.g.
+	// after a password reset.
+	FuncRememberTokenDeleteAllForUser func(ctx context.Context, userID string, options UserAuthOptions) (err error)
+	// FuncRememberTokenDeleteBySession deletes the tokens linked to the
+	// session, when the user revokes it.
+	FuncRememberTokenDeleteBySession func(ctx context.Context, sessionID string, options UserAuthOptions) (err error)
+}
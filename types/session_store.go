@@ -0,0 +1,55 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultSessionExpiration is the lifetime of a session created on login,
+// unless SessionLifetime is configured.
+const DefaultSessionExpiration = 2 * time.Hour
+
+// ErrSessionListingNotSupported is returned by ListForUser of stores that
+// cannot enumerate sessions, such as the adapter over the legacy callbacks.
+var ErrSessionListingNotSupported = errors.New("auth: session store does not support listing sessions")
+
+// Session is a login session, identified by the auth token handed to the
+// client.
+type Session struct {
+	ID         string // the auth token, or its hash with AuthTokenHashing
+	UserID     string
+	UserIp     string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time // zero when unknown, e.g. with the legacy callbacks
+
+	// How the user signed in; see Principal
+	AuthMethod      AuthMethod
+	MFALevel        MFALevel
+	AuthenticatedAt time.Time
+	ImpersonatorID  string
+}
+
+// SessionStore persists login sessions. It replaces the FuncUserStoreAuthToken,
+// FuncUserFindByAuthToken and FuncUserLogout callbacks, which are wrapped in an
+// adapter when no SessionStore is configured.
+//
+// The IP address and user agent of the current request are available with
+// UserAuthOptionsFromContext.
+type SessionStore interface {
+	// Create stores a new session.
+	Create(ctx context.Context, session Session) error
+	// Get returns the session with the ID, or nil when not found.
+	Get(ctx context.Context, sessionID string) (*Session, error)
+	// Touch records activity on the session.
+	Touch(ctx context.Context, sessionID string, lastSeenAt time.Time) error
+	// Delete removes the session. Deleting an unknown session is not an error.
+	Delete(ctx context.Context, sessionID string) error
+	// DeleteAllForUser removes every session of the user.
+	DeleteAllForUser(ctx context.Context, userID string) error
+	// ListForUser returns the sessions of the user, or
+	// ErrSessionListingNotSupported.
+	ListForUser(ctx context.Context, userID string) ([]Session, error)
+}
@@ -1,6 +1,23 @@
 package types
 
+import "context"
+
 type UserAuthOptions struct {
 	UserIp    string
 	UserAgent string
 }
+
+type userAuthOptionsContextKey struct{}
+
+// ContextWithUserAuthOptions returns a copy of ctx carrying the options of
+// the current request.
+func ContextWithUserAuthOptions(ctx context.Context, options UserAuthOptions) context.Context {
+	return context.WithValue(ctx, userAuthOptionsContextKey{}, options)
+}
+
+// UserAuthOptionsFromContext returns the options of the current request
+// stored with ContextWithUserAuthOptions, e.g. inside a SessionStore.
+func UserAuthOptionsFromContext(ctx context.Context) UserAuthOptions {
+	options, _ := ctx.Value(userAuthOptionsContextKey{}).(UserAuthOptions)
+	return options
+}
@@ -0,0 +1,25 @@
+package types
+
+import "context"
+
+// WebAuthnCredential is a passkey registered by a user.
+type WebAuthnCredential struct {
+	ID                string // base64url encoded credential ID
+	UserID            string
+	PublicKey         []byte // COSE encoded public key
+	SignCount         uint32
+	AttestationFormat string
+}
+
+// WebAuthnConfig enables passkey (WebAuthn) registration and login. It can be
+// added to both ConfigPasswordless and ConfigUsernameAndPassword.
+type WebAuthnConfig struct {
+	RPID    string   // Relying party ID, usually the domain, e.g. "example.com"
+	RPName  string   // Name shown by the authenticator (default: RPID)
+	Origins []string // Allowed origins, e.g. "https://example.com"
+
+	FuncCredentialStore           func(ctx context.Context, credential WebAuthnCredential, options UserAuthOptions) (err error)
+	FuncCredentialFindByID        func(ctx context.Context, credentialID string, options UserAuthOptions) (credential *WebAuthnCredential, err error) // nil when not found
+	FuncCredentialSignCountUpdate func(ctx context.Context, credentialID string, signCount uint32, options UserAuthOptions) (err error)
+	FuncCredentialsFindByUserID   func(ctx context.Context, userID string, options UserAuthOptions) (credentials []WebAuthnCredential, err error) // optional: prevents registering the same authenticator twice
+}
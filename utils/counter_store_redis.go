@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisCounterStore is a CounterStore over a server speaking the Redis
+// protocol (RESP), such as Redis 2.6.12 or later, Valkey or KeyDB. Counters
+// are created with their expiry by SET NX PX, and incremented atomically
+// with INCR in the same transaction.
+//
+// It keeps a single connection, used by one command at a time, and dials
+// again after errors.
+type RedisCounterStore struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+var _ CounterStore = (*RedisCounterStore)(nil)
+
+// RedisError is an error reply of the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedisCounterStore creates a counter store over the server at the
+// address, e.g. "localhost:6379". The password is optional, and db selects
+// the database.
+func NewRedisCounterStore(address string, password string, db int) *RedisCounterStore {
+	return &RedisCounterStore{
+		address:  address,
+		password: password,
+		db:       db,
+		timeout:  5 * time.Second,
+	}
+}
+
+// Increment adds one to the counter of the key, setting its expiry when it
+// is new
+func (s *RedisCounterStore) Increment(key string, ttl time.Duration) (int64, error) {
+	milliseconds := strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+
+	replies, err := s.transaction(
+		[]string{"SET", key, "0", "PX", milliseconds, "NX"},
+		[]string{"INCR", key},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, ok := replies[1].(int64)
+	if !ok {
+		return 0, errors.New("redis: unexpected INCR reply")
+	}
+
+	return count, nil
+}
+
+// Get returns the count of the key and how long until it expires
+func (s *RedisCounterStore) Get(key string) (int64, time.Duration, error) {
+	replies, err := s.transaction(
+		[]string{"GET", key},
+		[]string{"PTTL", key},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	value, ok := replies[0].(string)
+	if !ok {
+		// Missing key
+		return 0, 0, nil
+	}
+
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	milliseconds, _ := replies[1].(int64)
+	if milliseconds < 0 {
+		// No expiry
+		milliseconds = 0
+	}
+
+	return count, time.Duration(milliseconds) * time.Millisecond, nil
+}
+
+// Delete removes the counter of the key
+func (s *RedisCounterStore) Delete(key string) error {
+	_, err := s.do([]string{"DEL", key})
+	return err
+}
+
+// Close closes the connection
+func (s *RedisCounterStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn, s.reader = nil, nil
+
+	return err
+}
+
+// transaction runs the commands in MULTI and EXEC, returning their replies
+func (s *RedisCounterStore) transaction(commands ...[]string) ([]any, error) {
+	all := append([][]string{{"MULTI"}}, commands...)
+	all = append(all, []string{"EXEC"})
+
+	replies, err := s.pipeline(all)
+	if err != nil {
+		return nil, err
+	}
+
+	results, ok := replies[len(replies)-1].([]any)
+	if !ok || len(results) != len(commands) {
+		return nil, errors.New("redis: transaction aborted")
+	}
+
+	for _, result := range results {
+		if err, ok := result.(RedisError); ok {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// do runs a single command, returning its reply
+func (s *RedisCounterStore) do(command []string) (any, error) {
+	replies, err := s.pipeline([][]string{command})
+	if err != nil {
+		return nil, err
+	}
+
+	return replies[0], nil
+}
+
+// pipeline sends the commands at once and reads their replies, failing on
+// the first error reply
+func (s *RedisCounterStore) pipeline(commands [][]string) ([]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	replies, err := s.roundTrip(commands)
+	if err != nil {
+		var replyErr RedisError
+		if !errors.As(err, &replyErr) {
+			// The connection may be out of step, start over next time
+			s.conn.Close()
+			s.conn, s.reader = nil, nil
+		}
+		return nil, err
+	}
+
+	return replies, nil
+}
+
+// connect dials the server unless connected, authenticating and selecting
+// the database
+func (s *RedisCounterStore) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+	if err != nil {
+		return err
+	}
+
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if s.password != "" {
+		setup = append(setup, []string{"AUTH", s.password})
+	}
+	if s.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+
+	if len(setup) == 0 {
+		return nil
+	}
+
+	if _, err := s.roundTrip(setup); err != nil {
+		conn.Close()
+		s.conn, s.reader = nil, nil
+		return err
+	}
+
+	return nil
+}
+
+func (s *RedisCounterStore) roundTrip(commands [][]string) ([]any, error) {
+	if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return nil, err
+	}
+
+	var request []byte
+	for _, command := range commands {
+		request = appendRedisCommand(request, command)
+	}
+
+	if _, err := s.conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	// All replies are read, so the connection stays in step after an error
+	// reply
+	replies := make([]any, len(commands))
+	var replyErr error
+	for i := range commands {
+		reply, err := readRedisReply(s.reader)
+		if err != nil {
+			return nil, err
+		}
+		if err, ok := reply.(RedisError); ok && replyErr == nil {
+			replyErr = err
+		}
+		replies[i] = reply
+	}
+
+	if replyErr != nil {
+		return nil, replyErr
+	}
+
+	return replies, nil
+}
+
+// appendRedisCommand encodes the command as an array of bulk strings
+func appendRedisCommand(buf []byte, command []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(command)), 10)
+	buf = append(buf, '\r', '\n')
+
+	for _, arg := range command {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	return buf
+}
+
+// readRedisReply reads a reply: a string for simple and bulk strings, nil
+// for a missing bulk string, an int64 for integers, a RedisError for errors
+// and a []any for arrays
+func readRedisReply(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return RedisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+
+		return string(data[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		items := make([]any, length)
+		for i := range items {
+			if items[i], err = readRedisReply(reader); err != nil {
+				return nil, err
+			}
+		}
+
+		return items, nil
+	}
+
+	return nil, errors.New("redis: unknown reply type " + string(kind))
+}
@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedisServer speaks enough of the Redis protocol for RedisCounterStore
+type fakeRedisServer struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]int64
+	expires  map[string]time.Time
+	now      time.Time
+	commands []string
+	conns    []net.Conn
+}
+
+func newFakeRedisServer(t *testing.T, password string) *fakeRedisServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeRedisServer{
+		listener: listener,
+		password: password,
+		values:   map[string]int64{},
+		expires:  map[string]time.Time{},
+		now:      time.Now(),
+	}
+	t.Cleanup(server.close)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *fakeRedisServer) address() string {
+	return s.listener.Addr().String()
+}
+
+// dropConnections closes the open connections, as a restarting server would
+func (s *fakeRedisServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeRedisServer) close() {
+	s.listener.Close()
+	s.dropConnections()
+}
+
+func (s *fakeRedisServer) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.now = s.now.Add(d)
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	var queued [][]string
+	inTransaction := false
+
+	for {
+		request, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+
+		var command []string
+		for _, arg := range request.([]any) {
+			command = append(command, arg.(string))
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(command, " "))
+		s.mu.Unlock()
+
+		name := strings.ToUpper(command[0])
+
+		var reply string
+		switch {
+		case name == "AUTH":
+			authenticated = command[1] == s.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case name == "MULTI":
+			inTransaction, queued = true, nil
+			reply = "+OK\r\n"
+		case name == "EXEC":
+			reply = "*" + strconv.Itoa(len(queued)) + "\r\n"
+			for _, command := range queued {
+				reply += s.execute(command)
+			}
+			inTransaction = false
+		case inTransaction:
+			queued = append(queued, command)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = s.execute(command)
+		}
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedisServer) execute(command []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ""
+	if len(command) > 1 {
+		key = command[1]
+	}
+
+	// Expire lazily
+	if expires, ok := s.expires[key]; ok && !s.now.Before(expires) {
+		delete(s.values, key)
+		delete(s.expires, key)
+	}
+
+	switch strings.ToUpper(command[0]) {
+	case "SELECT":
+		return "+OK\r\n"
+	case "INCR":
+		s.values[key]++
+		return ":" + strconv.FormatInt(s.values[key], 10) + "\r\n"
+	case "SET":
+		// SET key value [PX milliseconds] [NX], as understood since Redis 2.6.12
+		value, _ := strconv.ParseInt(command[2], 10, 64)
+		var expires time.Time
+		for i := 3; i < len(command); i++ {
+			switch strings.ToUpper(command[i]) {
+			case "NX":
+				if _, ok := s.values[key]; ok {
+					return "$-1\r\n"
+				}
+			case "PX":
+				milliseconds, _ := strconv.ParseInt(command[i+1], 10, 64)
+				expires = s.now.Add(time.Duration(milliseconds) * time.Millisecond)
+				i++
+			}
+		}
+		s.values[key] = value
+		delete(s.expires, key)
+		if !expires.IsZero() {
+			s.expires[key] = expires
+		}
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.values[key]
+		if !ok {
+			return "$-1\r\n"
+		}
+		text := strconv.FormatInt(value, 10)
+		return "$" + strconv.Itoa(len(text)) + "\r\n" + text + "\r\n"
+	case "PTTL":
+		if _, ok := s.values[key]; !ok {
+			return ":-2\r\n"
+		}
+		expires, ok := s.expires[key]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return ":" + strconv.FormatInt(expires.Sub(s.now).Milliseconds(), 10) + "\r\n"
+	case "DEL":
+		_, ok := s.values[key]
+		delete(s.values, key)
+		delete(s.expires, key)
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+
+	return "-ERR unknown command '" + command[0] + "'\r\n"
+}
+
+func TestRedisCounterStore(t *testing.T) {
+	server := newFakeRedisServer(t, "")
+	store := NewRedisCounterStore(server.address(), "", 0)
+	defer store.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		count, err := store.Increment("key", 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != i {
+			t.Fatalf("expected count %d, got %d", i, count)
+		}
+		server.advance(2 * time.Second)
+	}
+
+	// Incrementing did not extend the ten seconds
+	count, ttl, err := store.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || ttl != 4*time.Second {
+		t.Fatalf("expected 3 expiring in 4 seconds, got %d %v", count, ttl)
+	}
+
+	if count, ttl, err := store.Get("missing"); err != nil || count != 0 || ttl != 0 {
+		t.Fatalf("expected a missing key to count zero, got %d %v %v", count, ttl, err)
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := store.Get("key"); count != 0 {
+		t.Fatalf("expected the counter to be deleted, got %d", count)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.commands[0] != "MULTI" || server.commands[1] != "SET key 0 PX 10000 NX" || server.commands[2] != "INCR key" || server.commands[3] != "EXEC" {
+		t.Fatalf("expected SET NX PX and INCR in a transaction, got %v", server.commands[:4])
+	}
+}
+
+func TestRedisCounterStore_AuthAndReconnect(t *testing.T) {
+	server := newFakeRedisServer(t, "secret")
+
+	wrong := NewRedisCounterStore(server.address(), "wrong", 0)
+	defer wrong.Close()
+	if _, err := wrong.Increment("key", time.Minute); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("expected a wrong password to fail, got %v", err)
+	}
+
+	store := NewRedisCounterStore(server.address(), "secret", 2)
+	defer store.Close()
+	if _, err := store.Increment("key", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	server.dropConnections()
+
+	// The first command after the drop fails, the next one dials again
+	if _, err := store.Increment("key", time.Minute); err == nil {
+		t.Fatalf("expected the dropped connection to fail")
+	}
+	count, err := store.Increment("key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("expected the count to survive the reconnect, got %d", count)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !strings.Contains(strings.Join(server.commands, "\n"), "AUTH secret\nSELECT 2\nMULTI") {
+		t.Fatalf("expected the connection to authenticate and select the database, got %v", server.commands)
+	}
+}
+
+func TestSharedRateLimiter_OverRedis(t *testing.T) {
+	server := newFakeRedisServer(t, "")
+
+	// Two instances of the application, each with its own connection
+	first := NewRedisCounterStore(server.address(), "", 0)
+	defer first.Close()
+	second := NewRedisCounterStore(server.address(), "", 0)
+	defer second.Close()
+
+	policy := RateLimitPolicy{Burst: 2, Refill: time.Minute, Lockout: time.Hour}
+
+	NewSharedRateLimiter(first, nil).Allow("1.1.1.1", "login", policy)
+	NewSharedRateLimiter(second, nil).Allow("1.1.1.1", "login", policy)
+
+	res := NewSharedRateLimiter(first, nil).Allow("1.1.1.1", "login", policy)
+	if res.Allowed || res.RetryAfter != time.Hour {
+		t.Fatalf("expected the attempts on both instances to lock the client out, got %+v", res)
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TemporaryKeyCounterStore is a CounterStore over the FuncTemporaryKeyGet,
+// FuncTemporaryKeySet and optional FuncTemporaryKeyDelete callbacks of the
+// configs, storing each counter as "count:expiresAt" with expiresAt in Unix
+// seconds.
+//
+// It is a reference adapter: increments are atomic within the process only,
+// as the callbacks cannot increment atomically, so concurrent failures on
+// several instances may be counted once. Prefer a store with an atomic
+// increment, such as RedisCounterStore, when that matters.
+type TemporaryKeyCounterStore struct {
+	mu     sync.Mutex
+	get    func(key string) (value string, err error)
+	set    func(key string, value string, expiresSeconds int) (err error)
+	delete func(key string) (err error)
+	now    func() time.Time
+}
+
+var _ CounterStore = (*TemporaryKeyCounterStore)(nil)
+
+// NewTemporaryKeyCounterStore creates a counter store over the temporary
+// key callbacks. Without delete, counters are overwritten with an empty
+// value that expires right away.
+func NewTemporaryKeyCounterStore(
+	get func(key string) (value string, err error),
+	set func(key string, value string, expiresSeconds int) (err error),
+	delete func(key string) (err error),
+) *TemporaryKeyCounterStore {
+	return &TemporaryKeyCounterStore{
+		get:    get,
+		set:    set,
+		delete: delete,
+		now:    time.Now,
+	}
+}
+
+// Increment adds one to the counter of the key, keeping its expiry
+func (s *TemporaryKeyCounterStore) Increment(key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	count, expiresAt := s.load(key, now)
+	if count == 0 {
+		// Stored in whole seconds, rounded up
+		expiresAt = now.Add(ttl + time.Second - 1).Truncate(time.Second)
+	}
+	count++
+
+	value := strconv.FormatInt(count, 10) + ":" + strconv.FormatInt(expiresAt.Unix(), 10)
+	if err := s.set(key, value, expiresSeconds(expiresAt.Sub(now))); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Get returns the count of the key and how long until it expires
+func (s *TemporaryKeyCounterStore) Get(key string) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	count, expiresAt := s.load(key, now)
+	if count == 0 {
+		return 0, 0, nil
+	}
+
+	return count, expiresAt.Sub(now), nil
+}
+
+// Delete removes the counter of the key
+func (s *TemporaryKeyCounterStore) Delete(key string) error {
+	if s.delete != nil {
+		return s.delete(key)
+	}
+
+	return s.set(key, "", 1)
+}
+
+// load returns the count of the key and when it expires, zero when it is
+// missing or expired
+func (s *TemporaryKeyCounterStore) load(key string, now time.Time) (int64, time.Time) {
+	// Stores report unknown keys as errors
+	value, err := s.get(key)
+	if err != nil || value == "" {
+		return 0, time.Time{}
+	}
+
+	countValue, expiresAtValue, _ := strings.Cut(value, ":")
+
+	count, err := strconv.ParseInt(countValue, 10, 64)
+	if err != nil {
+		return 0, time.Time{}
+	}
+
+	unix, err := strconv.ParseInt(expiresAtValue, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return 0, time.Time{}
+	}
+
+	return count, time.Unix(unix, 0)
+}
+
+// expiresSeconds rounds the ttl up to whole seconds, at least one
+func expiresSeconds(ttl time.Duration) int {
+	return max(int((ttl+time.Second-1)/time.Second), 1)
+}
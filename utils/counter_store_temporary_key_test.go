@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTemporaryKeyCounterStore_IncrementKeepsExpiry(t *testing.T) {
+	store, now := newTestCounterStore()
+
+	for i := int64(1); i <= 3; i++ {
+		count, err := store.Increment("key", 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != i {
+			t.Fatalf("expected count %d, got %d", i, count)
+		}
+		*now = now.Add(2 * time.Second)
+	}
+
+	// Incrementing did not extend the ten seconds
+	count, ttl, err := store.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || ttl != 4*time.Second {
+		t.Fatalf("expected 3 expiring in 4 seconds, got %d %v", count, ttl)
+	}
+
+	*now = now.Add(5 * time.Second)
+	if count, ttl, _ := store.Get("key"); count != 0 || ttl != 0 {
+		t.Fatalf("expected the counter to expire, got %d %v", count, ttl)
+	}
+
+	if count, _ := store.Increment("key", 10*time.Second); count != 1 {
+		t.Fatalf("expected an expired counter to start again, got %d", count)
+	}
+}
+
+func TestTemporaryKeyCounterStore_DeleteWithoutDeleteFunc(t *testing.T) {
+	values := map[string]string{}
+	expires := map[string]int{}
+	store := NewTemporaryKeyCounterStore(
+		func(key string) (string, error) { return values[key], nil },
+		func(key string, value string, expiresSeconds int) error {
+			values[key], expires[key] = value, expiresSeconds
+			return nil
+		},
+		nil,
+	)
+
+	if _, err := store.Increment("key", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if expires["key"] < 60 || expires["key"] > 61 {
+		t.Fatalf("expected the value to expire with the counter, got %d seconds", expires["key"])
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _, _ := store.Get("key"); count != 0 || values["key"] != "" || expires["key"] != 1 {
+		t.Fatalf("expected the counter to be overwritten, got %d %q %d", count, values["key"], expires["key"])
+	}
+}
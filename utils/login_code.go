@@ -42,6 +42,29 @@ func GenerateVerificationCode(extraHardened bool) (string, error) {
 	return str.RandomFromGamma(LoginCodeLength(extraHardened), LoginCodeGamma(extraHardened))
 }
 
+// PhoneCodeLength returns the length of the numeric login codes sent by text
+// message, which have to be short to type. They are always bound to their
+// login flow, which caps the guesses per code. As with LoginCodeLength, the
+// hardened length is only used when rate limiting is disabled.
+func PhoneCodeLength(extraHardened bool) int {
+	if extraHardened {
+		return 10
+	}
+
+	return 6
+}
+
+// PhoneCodeGamma returns the character set of the codes sent by text message.
+func PhoneCodeGamma() string {
+	return "0123456789"
+}
+
+// GeneratePhoneVerificationCode generates a random numeric login code to be
+// sent by text message.
+func GeneratePhoneVerificationCode(extraHardened bool) (string, error) {
+	return str.RandomFromGamma(PhoneCodeLength(extraHardened), PhoneCodeGamma())
+}
+
 // GeneratePasswordResetToken generates a random password reset token.
 func GeneratePasswordResetToken() (string, error) {
 	return str.RandomFromGamma(32, "BCDFGHJKLMNPQRSTVXYZ")
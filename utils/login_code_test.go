@@ -89,6 +89,36 @@ func TestGenerateVerificationCode_DefaultAndHardened(t *testing.T) {
 	}
 }
 
+func TestGeneratePhoneVerificationCode_DefaultAndHardened(t *testing.T) {
+	tests := []struct {
+		name          string
+		extraHardened bool
+		want          int
+	}{
+		{"default_phone_code", false, 6},
+		{"hardened_phone_code", true, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := GeneratePhoneVerificationCode(tt.extraHardened)
+			if err != nil {
+				t.Fatalf("GeneratePhoneVerificationCode(%v) returned error: %v", tt.extraHardened, err)
+			}
+
+			if len(code) != tt.want || PhoneCodeLength(tt.extraHardened) != tt.want {
+				t.Fatalf("expected code length %d, got %d", tt.want, len(code))
+			}
+
+			for _, ch := range code {
+				if !containsRune(PhoneCodeGamma(), ch) {
+					t.Fatalf("code %q contains non-digit %q", code, ch)
+				}
+			}
+		})
+	}
+}
+
 func TestGeneratePasswordResetToken(t *testing.T) {
 	token, err := GeneratePasswordResetToken()
 	if err != nil {
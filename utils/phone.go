@@ -0,0 +1,49 @@
+package utils
+
+import "strings"
+
+// NormalizePhone returns the phone number in E.164 format, e.g.
+// "+447700900123", or an empty string when it is not a valid international
+// number. Spaces, dots, dashes and parentheses are ignored, and the "00"
+// international prefix is accepted in place of "+".
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+
+	if !strings.HasPrefix(phone, "+") {
+		return ""
+	}
+
+	digits := make([]byte, 0, len(phone))
+	for _, c := range phone[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, byte(c))
+		case c == ' ' || c == '.' || c == '-' || c == '(' || c == ')':
+		default:
+			return ""
+		}
+	}
+
+	// E.164: a country code not starting with 0, at most 15 digits overall
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return ""
+	}
+
+	return "+" + string(digits)
+}
+
+// ValidatePhoneFormat returns an error message for an invalid phone number.
+func ValidatePhoneFormat(phone string) string {
+	if phone == "" {
+		return ""
+	}
+
+	if NormalizePhone(phone) == "" {
+		return "This is not a valid phone number, include the country code (e.g. +44): " + phone
+	}
+
+	return ""
+}
@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := map[string]string{
+		"+447700900123":       "+447700900123",
+		" +44 7700 900123 ":   "+447700900123",
+		"+1 (415) 555-2671":   "+14155552671",
+		"0044.7700.900.123":   "+447700900123",
+		"07700900123":         "",
+		"+0447700900123":      "",
+		"+4477009001234567":   "",
+		"+44 7700 900123 x12": "",
+		"+1234567":            "",
+		"":                    "",
+	}
+
+	for phone, expected := range tests {
+		if normalized := NormalizePhone(phone); normalized != expected {
+			t.Errorf("NormalizePhone(%q) = %q, expected %q", phone, normalized, expected)
+		}
+	}
+}
+
+func TestValidatePhoneFormat(t *testing.T) {
+	if msg := ValidatePhoneFormat("+44 7700 900123"); msg != "" {
+		t.Fatalf("expected a valid phone number, got %q", msg)
+	}
+	if msg := ValidatePhoneFormat("07700900123"); msg == "" {
+		t.Fatal("expected a number without country code to be rejected")
+	}
+}
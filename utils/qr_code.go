@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"encoding/base64"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QrCodeDataURI renders the content as a PNG QR code of the given size in
+// pixels and returns it as a data URI, ready to be used as an image source.
+func QrCodeDataURI(content string, size int) (string, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
@@ -3,23 +3,32 @@ package utils
 import (
 	"sync"
 	"time"
+
+	"github.com/dracory/auth/types"
 )
 
+// RateLimiter is implemented by InMemoryRateLimiter, TokenBucketRateLimiter
+// and GCRARateLimiter; see types.RateLimiter
+type RateLimiter = types.RateLimiter
+
+// RateLimitPolicy is the policy of an endpoint; see types.RateLimitPolicy
+type RateLimitPolicy = types.RateLimitPolicy
+
 // RateLimitResult represents the result of a rate limit check
-type RateLimitResult struct {
-	Allowed    bool
-	RetryAfter time.Duration
-}
+type RateLimitResult = types.RateLimitResult
 
 // requestRecord tracks individual request timestamps for an IP
 type requestRecord struct {
 	timestamps  []time.Time
+	window      time.Duration
 	lockedUntil time.Time
 }
 
-// InMemoryRateLimiter provides thread-safe in-memory rate limiting
+// InMemoryRateLimiter provides thread-safe in-memory rate limiting. It keeps
+// a sliding log of the attempts within Burst times Refill of a policy.
 type InMemoryRateLimiter struct {
 	records          sync.Map // map[string]*requestRecord (key: "ip:endpoint")
+	mu               sync.Mutex
 	maxAttempts      int
 	windowDuration   time.Duration
 	lockoutDuration  time.Duration
@@ -28,6 +37,8 @@ type InMemoryRateLimiter struct {
 	cleanupWaitGroup sync.WaitGroup
 }
 
+var _ RateLimiter = (*InMemoryRateLimiter)(nil)
+
 // NewInMemoryRateLimiter creates a new in-memory rate limiter with default settings
 func NewInMemoryRateLimiter(maxAttempts int, windowDuration time.Duration, lockoutDuration time.Duration) *InMemoryRateLimiter {
 	limiter := &InMemoryRateLimiter{
@@ -45,11 +56,27 @@ func NewInMemoryRateLimiter(maxAttempts int, windowDuration time.Duration, locko
 	return limiter
 }
 
-// Check verifies if a request from the given IP to the given endpoint should be allowed
+// Check verifies if a request from the given IP to the given endpoint should
+// be allowed, counting every allowed request as an attempt. Use Allow
+// together with Reset to clear the attempts of successful requests.
 func (r *InMemoryRateLimiter) Check(ip string, endpoint string) RateLimitResult {
+	return r.Allow(ip, endpoint, RateLimitPolicy{})
+}
+
+// Allow verifies if a request from the given IP to the given endpoint should
+// be allowed and, if so, counts it as an attempt. Once maxAttempts attempts
+// fall within the window, further requests are denied and the IP is locked
+// out. Clear the attempts with Reset after a successful request. The zero
+// policy uses the settings the limiter was created with.
+func (r *InMemoryRateLimiter) Allow(ip string, endpoint string, policy RateLimitPolicy) RateLimitResult {
+	maxAttempts, window, lockout := r.limits(policy)
 	key := ip + ":" + endpoint
 	now := time.Now()
 
+	// Under the lock, so the cleanup cannot remove the record in between
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// Load or create record
 	recordInterface, _ := r.records.LoadOrStore(key, &requestRecord{
 		timestamps:  make([]time.Time, 0),
@@ -73,7 +100,7 @@ func (r *InMemoryRateLimiter) Check(ip string, endpoint string) RateLimitResult
 	}
 
 	// Remove timestamps outside the window
-	cutoff := now.Add(-r.windowDuration)
+	cutoff := now.Add(-window)
 	validTimestamps := make([]time.Time, 0)
 	for _, ts := range record.timestamps {
 		if ts.After(cutoff) {
@@ -83,19 +110,26 @@ func (r *InMemoryRateLimiter) Check(ip string, endpoint string) RateLimitResult
 	record.timestamps = validTimestamps
 
 	// Check if limit exceeded
-	if len(record.timestamps) >= r.maxAttempts {
+	if len(record.timestamps) >= maxAttempts {
+		// Without a lockout, wait for the oldest counted attempt to expire
+		if lockout <= 0 {
+			oldest := record.timestamps[len(record.timestamps)-maxAttempts]
+			return RateLimitResult{
+				Allowed:    false,
+				RetryAfter: oldest.Add(window).Sub(now),
+			}
+		}
+
 		// Lock out the IP
-		record.lockedUntil = now.Add(r.lockoutDuration)
-		r.records.Store(key, record)
+		record.lockedUntil = now.Add(lockout)
 		return RateLimitResult{
 			Allowed:    false,
-			RetryAfter: r.lockoutDuration,
+			RetryAfter: lockout,
 		}
 	}
 
-	// Add current timestamp and allow request
 	record.timestamps = append(record.timestamps, now)
-	r.records.Store(key, record)
+	record.window = window
 
 	return RateLimitResult{
 		Allowed:    true,
@@ -103,6 +137,25 @@ func (r *InMemoryRateLimiter) Check(ip string, endpoint string) RateLimitResult
 	}
 }
 
+// Reset clears the attempts of the given IP to the given endpoint, e.g.
+// after a successful login.
+func (r *InMemoryRateLimiter) Reset(ip string, endpoint string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records.Delete(ip + ":" + endpoint)
+}
+
+// limits returns the maximum attempts, window and lockout of the policy, or
+// those the limiter was created with for the zero policy
+func (r *InMemoryRateLimiter) limits(policy RateLimitPolicy) (int, time.Duration, time.Duration) {
+	if policy == (RateLimitPolicy{}) {
+		return r.maxAttempts, r.windowDuration, r.lockoutDuration
+	}
+
+	return policy.Burst, time.Duration(policy.Burst) * policy.Refill, policy.Lockout
+}
+
 // cleanupOldRecords periodically removes old records to prevent memory leaks
 func (r *InMemoryRateLimiter) cleanupOldRecords() {
 	defer r.cleanupWaitGroup.Done()
@@ -113,10 +166,11 @@ func (r *InMemoryRateLimiter) cleanupOldRecords() {
 		select {
 		case <-ticker.C:
 			now := time.Now()
-			cutoff := now.Add(-r.windowDuration - r.lockoutDuration)
 
+			r.mu.Lock()
 			r.records.Range(func(key, value interface{}) bool {
 				record := value.(*requestRecord)
+				cutoff := now.Add(-record.window)
 
 				// If no recent activity and not locked, remove the record
 				if len(record.timestamps) == 0 ||
@@ -128,6 +182,7 @@ func (r *InMemoryRateLimiter) cleanupOldRecords() {
 
 				return true // continue iteration
 			})
+			r.mu.Unlock()
 		case <-r.stopCleanup:
 			return
 		}
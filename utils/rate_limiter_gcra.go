@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// gcraState holds the theoretical arrival time of a key at an endpoint: the
+// time at which all its attempts would have been paid off at one per Refill
+type gcraState struct {
+	tat         time.Time
+	lockedUntil time.Time
+}
+
+// GCRARateLimiter provides thread-safe in-memory rate limiting with the
+// generic cell rate algorithm. It allows the same attempts as a token
+// bucket, Burst at once and one every Refill after that, but keeps a single
+// timestamp per key and endpoint.
+type GCRARateLimiter struct {
+	mu               sync.Mutex
+	states           map[string]*gcraState // key: "key:endpoint"
+	now              func() time.Time
+	cleanupInterval  time.Duration
+	stopCleanup      chan struct{}
+	cleanupWaitGroup sync.WaitGroup
+}
+
+var _ RateLimiter = (*GCRARateLimiter)(nil)
+
+// NewGCRARateLimiter creates a new GCRA rate limiter
+func NewGCRARateLimiter() *GCRARateLimiter {
+	limiter := &GCRARateLimiter{
+		states:          map[string]*gcraState{},
+		now:             time.Now,
+		cleanupInterval: 5 * time.Minute,
+		stopCleanup:     make(chan struct{}),
+	}
+
+	// Start background cleanup goroutine
+	limiter.cleanupWaitGroup.Add(1)
+	go limiter.cleanupPaidOffStates()
+
+	return limiter
+}
+
+// Allow verifies if a request from the given key to the given endpoint
+// should be allowed and, if so, counts it, locking the key out when it uses
+// up the attempts and the policy has a lockout
+func (r *GCRARateLimiter) Allow(key string, endpoint string, policy RateLimitPolicy) RateLimitResult {
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.states[key+":"+endpoint]
+	if !ok {
+		state = &gcraState{tat: now}
+		r.states[key+":"+endpoint] = state
+	}
+
+	if now.Before(state.lockedUntil) {
+		return RateLimitResult{Allowed: false, RetryAfter: state.lockedUntil.Sub(now)}
+	}
+
+	// Another attempt is allowed while the debt stays within Burst-1 attempts
+	excess := state.tat.Sub(now) - gcraTolerance(policy)
+	if excess > 0 {
+		return RateLimitResult{Allowed: false, RetryAfter: excess}
+	}
+
+	if state.tat.Before(now) {
+		state.tat = now
+	}
+	state.tat = state.tat.Add(policy.Refill)
+
+	if policy.Lockout > 0 && state.tat.Sub(now) > gcraTolerance(policy) {
+		state.lockedUntil = now.Add(policy.Lockout)
+	}
+
+	return RateLimitResult{Allowed: true}
+}
+
+// Reset clears the attempts of the given key at the given endpoint, e.g.
+// after a successful login
+func (r *GCRARateLimiter) Reset(key string, endpoint string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.states, key+":"+endpoint)
+}
+
+// gcraTolerance is how far ahead of now the theoretical arrival time may be
+// for another attempt to be allowed
+func gcraTolerance(policy RateLimitPolicy) time.Duration {
+	return time.Duration(policy.Burst-1) * policy.Refill
+}
+
+// cleanupPaidOffStates periodically removes the states whose attempts are
+// paid off, which are the same as no state, to prevent memory leaks
+func (r *GCRARateLimiter) cleanupPaidOffStates() {
+	defer r.cleanupWaitGroup.Done()
+	ticker := time.NewTicker(r.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := r.now()
+
+			r.mu.Lock()
+			for key, state := range r.states {
+				if now.After(state.tat) && now.After(state.lockedUntil) {
+					delete(r.states, key)
+				}
+			}
+			r.mu.Unlock()
+		case <-r.stopCleanup:
+			return
+		}
+	}
+}
+
+// Stop gracefully stops the rate limiter's background cleanup
+func (r *GCRARateLimiter) Stop() {
+	close(r.stopCleanup)
+	r.cleanupWaitGroup.Wait()
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCRARateLimiter_BurstThenRefill(t *testing.T) {
+	limiter := NewGCRARateLimiter()
+	defer limiter.Stop()
+
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	policy := RateLimitPolicy{Burst: 3, Refill: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		if res := limiter.Allow("1.1.1.1", "login", policy); !res.Allowed {
+			t.Fatalf("expected attempt %d to be allowed", i+1)
+		}
+	}
+
+	res := limiter.Allow("1.1.1.1", "login", policy)
+	if res.Allowed || res.RetryAfter != time.Minute {
+		t.Fatalf("expected to wait a minute for the next attempt, got %+v", res)
+	}
+
+	// Other keys and endpoints are counted separately
+	if !limiter.Allow("2.2.2.2", "login", policy).Allowed || !limiter.Allow("1.1.1.1", "register", policy).Allowed {
+		t.Fatalf("expected other keys to be allowed")
+	}
+
+	// One attempt is back after a minute, the next one a minute later
+	now = now.Add(time.Minute)
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected an attempt after the refill")
+	}
+	if res := limiter.Allow("1.1.1.1", "login", policy); res.Allowed || res.RetryAfter != time.Minute {
+		t.Fatalf("expected to wait another minute, got %+v", res)
+	}
+
+	limiter.Reset("1.1.1.1", "login")
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected reset to clear the attempts")
+	}
+}
+
+func TestGCRARateLimiter_Lockout(t *testing.T) {
+	limiter := NewGCRARateLimiter()
+	defer limiter.Stop()
+
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	policy := RateLimitPolicy{Burst: 2, Refill: time.Minute, Lockout: time.Hour}
+
+	limiter.Allow("1.1.1.1", "login", policy)
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected the second attempt to be allowed")
+	}
+
+	// The refill does not lift the lockout
+	now = now.Add(30 * time.Minute)
+	if res := limiter.Allow("1.1.1.1", "login", policy); res.Allowed || res.RetryAfter != 30*time.Minute {
+		t.Fatalf("expected the lockout to last an hour, got %+v", res)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected the lockout to expire")
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/dracory/auth/types"
+)
+
+// CounterStore is implemented by RedisCounterStore and
+// TemporaryKeyCounterStore; see types.CounterStore
+type CounterStore = types.CounterStore
+
+// sharedRateLimitKeyPrefix and sharedRateLimitLockKeyPrefix namespace the
+// attempt counters and lockouts in the counter store
+const (
+	sharedRateLimitKeyPrefix     = "rate_limit:"
+	sharedRateLimitLockKeyPrefix = "rate_limit_lock:"
+)
+
+// SharedRateLimiter provides rate limiting over a CounterStore, so the
+// instances of an application behind a load balancer see the same attempts
+// and lock clients out consistently.
+//
+// Attempts are counted in a fixed window of Burst times Refill of the
+// policy, starting with the first attempt, and a locked out key waits for
+// both the lockout and the window to pass. Store errors are logged and fail
+// open, like the other rate limiter errors.
+type SharedRateLimiter struct {
+	store  CounterStore
+	logger *slog.Logger
+}
+
+var _ RateLimiter = (*SharedRateLimiter)(nil)
+
+// NewSharedRateLimiter creates a rate limiter over the store. The logger is
+// optional.
+func NewSharedRateLimiter(store CounterStore, logger *slog.Logger) *SharedRateLimiter {
+	return &SharedRateLimiter{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Allow verifies if a request from the given key to the given endpoint
+// should be allowed, counting it with an atomic increment first, so
+// concurrent requests on any instance cannot get past the limit. The
+// request going over Burst locks the key out when the policy has a lockout.
+func (r *SharedRateLimiter) Allow(key string, endpoint string, policy RateLimitPolicy) RateLimitResult {
+	attemptsKey := sharedRateLimitKeyPrefix + key + ":" + endpoint
+	lockKey := sharedRateLimitLockKeyPrefix + key + ":" + endpoint
+
+	locked, lockTTL, err := r.store.Get(lockKey)
+	if err != nil {
+		r.logError("get", endpoint, err)
+		return RateLimitResult{Allowed: true}
+	}
+
+	if locked > 0 {
+		return RateLimitResult{Allowed: false, RetryAfter: lockTTL}
+	}
+
+	window := time.Duration(policy.Burst) * policy.Refill
+	if window <= 0 {
+		return RateLimitResult{Allowed: true}
+	}
+
+	attempts, err := r.store.Increment(attemptsKey, window)
+	if err != nil {
+		r.logError("increment", endpoint, err)
+		return RateLimitResult{Allowed: true}
+	}
+
+	if attempts <= int64(policy.Burst) {
+		return RateLimitResult{Allowed: true}
+	}
+
+	// Only the first request over the limit starts the lockout, so the
+	// others cannot extend it
+	if attempts == int64(policy.Burst)+1 && policy.Lockout > 0 {
+		if _, err := r.store.Increment(lockKey, policy.Lockout); err != nil {
+			r.logError("increment", endpoint, err)
+		}
+		return RateLimitResult{Allowed: false, RetryAfter: policy.Lockout}
+	}
+
+	_, ttl, err := r.store.Get(attemptsKey)
+	if err != nil {
+		r.logError("get", endpoint, err)
+		ttl = window
+	}
+
+	return RateLimitResult{Allowed: false, RetryAfter: ttl}
+}
+
+// Reset clears the attempts and lockout of the given key at the given
+// endpoint, e.g. after a successful login
+func (r *SharedRateLimiter) Reset(key string, endpoint string) {
+	for _, storeKey := range []string{
+		sharedRateLimitKeyPrefix + key + ":" + endpoint,
+		sharedRateLimitLockKeyPrefix + key + ":" + endpoint,
+	} {
+		if err := r.store.Delete(storeKey); err != nil {
+			r.logError("delete", endpoint, err)
+		}
+	}
+}
+
+func (r *SharedRateLimiter) logError(operation string, endpoint string, err error) {
+	if r.logger != nil {
+		r.logger.Error("rate limit store "+operation+" failed", "error", err, "endpoint", endpoint)
+	}
+}
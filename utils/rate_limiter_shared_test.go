@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestCounterStore returns a counter store over a map, with a clock the
+// test can move
+func newTestCounterStore() (*TemporaryKeyCounterStore, *time.Time) {
+	values := map[string]string{}
+	store := NewTemporaryKeyCounterStore(
+		func(key string) (string, error) {
+			value, ok := values[key]
+			if !ok {
+				return "", errors.New("not found")
+			}
+			return value, nil
+		},
+		func(key string, value string, expiresSeconds int) error {
+			values[key] = value
+			return nil
+		},
+		func(key string) error {
+			delete(values, key)
+			return nil
+		},
+	)
+
+	// Counters are stored in whole seconds
+	now := time.Now().Truncate(time.Second)
+	store.now = func() time.Time { return now }
+
+	return store, &now
+}
+
+func TestSharedRateLimiter_SharesFailuresAcrossInstances(t *testing.T) {
+	store, now := newTestCounterStore()
+	first := NewSharedRateLimiter(store, nil)
+	second := NewSharedRateLimiter(store, nil)
+
+	policy := RateLimitPolicy{Burst: 2, Refill: time.Minute}
+
+	first.Allow("1.1.1.1", "login", policy)
+	if !second.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected an attempt within the limit to be allowed")
+	}
+
+	// Both instances see both attempts
+	for _, limiter := range []*SharedRateLimiter{first, second} {
+		res := limiter.Allow("1.1.1.1", "login", policy)
+		if res.Allowed || res.RetryAfter != 2*time.Minute {
+			t.Fatalf("expected the client to wait for the window, got %+v", res)
+		}
+	}
+
+	if !first.Allow("2.2.2.2", "login", policy).Allowed || !first.Allow("1.1.1.1", "register", policy).Allowed {
+		t.Fatalf("expected other keys and endpoints to be allowed")
+	}
+
+	// The window is Burst times Refill from the first attempt
+	*now = now.Add(2 * time.Minute)
+	if !first.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected the attempts to expire with the window")
+	}
+
+	first.Allow("1.1.1.1", "login", policy)
+	second.Reset("1.1.1.1", "login")
+	if !first.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected a reset on one instance to clear the attempts on all")
+	}
+}
+
+func TestSharedRateLimiter_Lockout(t *testing.T) {
+	store, now := newTestCounterStore()
+	limiter := NewSharedRateLimiter(store, nil)
+
+	policy := RateLimitPolicy{Burst: 2, Refill: time.Second, Lockout: time.Hour}
+
+	limiter.Allow("1.1.1.1", "login", policy)
+	limiter.Allow("1.1.1.1", "login", policy)
+	if res := limiter.Allow("1.1.1.1", "login", policy); res.Allowed || res.RetryAfter != time.Hour {
+		t.Fatalf("expected the attempt over the limit to lock the client out, got %+v", res)
+	}
+
+	// The lockout outlasts the window
+	*now = now.Add(30 * time.Minute)
+	if res := limiter.Allow("1.1.1.1", "login", policy); res.Allowed || res.RetryAfter != 30*time.Minute {
+		t.Fatalf("expected the lockout to last an hour, got %+v", res)
+	}
+
+	*now = now.Add(30 * time.Minute)
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected the lockout to expire")
+	}
+
+	// The client starts afresh after the lockout
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected the attempts before the lockout to be cleared")
+	}
+}
+
+// failingCounterStore fails every operation
+type failingCounterStore struct{}
+
+func (failingCounterStore) Increment(key string, ttl time.Duration) (int64, error) {
+	return 0, errors.New("down")
+}
+
+func (failingCounterStore) Get(key string) (int64, time.Duration, error) {
+	return 0, 0, errors.New("down")
+}
+
+func (failingCounterStore) Delete(key string) error { return errors.New("down") }
+
+func TestSharedRateLimiter_FailsOpen(t *testing.T) {
+	limiter := NewSharedRateLimiter(failingCounterStore{}, nil)
+	policy := RateLimitPolicy{Burst: 1, Refill: time.Minute, Lockout: time.Hour}
+
+	limiter.Allow("1.1.1.1", "login", policy)
+	limiter.Reset("1.1.1.1", "login")
+	if !limiter.Allow("1.1.1.1", "login", policy).Allowed {
+		t.Fatalf("expected store errors not to block requests")
+	}
+}
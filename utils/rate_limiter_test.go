@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -105,3 +107,78 @@ func TestInMemoryRateLimiter_SeparatesKeysByIpAndEndpoint(t *testing.T) {
 		t.Fatalf("expected attempt from different IP to be allowed")
 	}
 }
+
+func TestInMemoryRateLimiter_ResetClearsAttempts(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(2, time.Second, time.Second)
+	defer limiter.Stop()
+
+	ip := "127.0.0.1"
+	endpoint := "login"
+
+	// A user logging in and out many times is never locked out
+	for i := 0; i < 5; i++ {
+		if res := limiter.Allow(ip, endpoint, RateLimitPolicy{}); !res.Allowed {
+			t.Fatalf("expected attempt %d to be allowed", i+1)
+		}
+		limiter.Reset(ip, endpoint)
+	}
+
+	limiter.Allow(ip, endpoint, RateLimitPolicy{})
+	limiter.Allow(ip, endpoint, RateLimitPolicy{})
+	if res := limiter.Allow(ip, endpoint, RateLimitPolicy{}); res.Allowed {
+		t.Fatalf("expected the IP to be locked out")
+	}
+
+	limiter.Reset(ip, endpoint)
+	if res := limiter.Allow(ip, endpoint, RateLimitPolicy{}); !res.Allowed {
+		t.Fatalf("expected reset to lift the lockout")
+	}
+}
+
+func TestInMemoryRateLimiter_ConcurrentRequestsCannotExceedLimit(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(3, time.Minute, time.Minute)
+	defer limiter.Stop()
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.Allow("127.0.0.1", "login", RateLimitPolicy{}).Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != 3 {
+		t.Fatalf("expected exactly 3 requests to be allowed, got %d", got)
+	}
+}
+
+func TestInMemoryRateLimiter_EndpointPolicies(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(5, time.Minute, time.Minute)
+	defer limiter.Stop()
+
+	ip := "127.0.0.1"
+	strict := RateLimitPolicy{Burst: 1, Refill: time.Minute, Lockout: time.Hour}
+	noLockout := RateLimitPolicy{Burst: 2, Refill: time.Minute}
+
+	limiter.Allow(ip, "password_restore", strict)
+	res := limiter.Allow(ip, "password_restore", strict)
+	if res.Allowed || res.RetryAfter != time.Hour {
+		t.Fatalf("expected the strict policy to lock out for an hour, got %+v", res)
+	}
+
+	limiter.Allow(ip, "login_code_verify", noLockout)
+	if !limiter.Allow(ip, "login_code_verify", noLockout).Allowed {
+		t.Fatalf("expected the second attempt to be allowed")
+	}
+
+	// Without a lockout, the oldest attempt expires after Burst times Refill
+	res = limiter.Allow(ip, "login_code_verify", noLockout)
+	if res.Allowed || res.RetryAfter <= time.Minute || res.RetryAfter > 2*time.Minute {
+		t.Fatalf("expected to wait for the oldest attempt to expire, got %+v", res)
+	}
+}
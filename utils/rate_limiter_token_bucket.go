@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket holds the attempts left to a key at an endpoint
+type tokenBucket struct {
+	tokens      float64
+	updated     time.Time
+	fullAt      time.Time
+	lockedUntil time.Time
+}
+
+// refill adds the attempts regained since the last update, up to Burst
+func (b *tokenBucket) refill(policy RateLimitPolicy, now time.Time) {
+	if policy.Refill > 0 && now.After(b.updated) {
+		b.tokens += float64(now.Sub(b.updated)) / float64(policy.Refill)
+	}
+	b.tokens = min(b.tokens, float64(policy.Burst))
+	b.updated = now
+}
+
+// TokenBucketRateLimiter provides thread-safe in-memory rate limiting with a
+// token bucket per key and endpoint. The bucket holds Burst attempts, each
+// allowed request takes one, and one is added back every Refill.
+type TokenBucketRateLimiter struct {
+	mu               sync.Mutex
+	buckets          map[string]*tokenBucket // key: "key:endpoint"
+	now              func() time.Time
+	cleanupInterval  time.Duration
+	stopCleanup      chan struct{}
+	cleanupWaitGroup sync.WaitGroup
+}
+
+var _ RateLimiter = (*TokenBucketRateLimiter)(nil)
+
+// NewTokenBucketRateLimiter creates a new token bucket rate limiter
+func NewTokenBucketRateLimiter() *TokenBucketRateLimiter {
+	limiter := &TokenBucketRateLimiter{
+		buckets:         map[string]*tokenBucket{},
+		now:             time.Now,
+		cleanupInterval: 5 * time.Minute,
+		stopCleanup:     make(chan struct{}),
+	}
+
+	// Start background cleanup goroutine
+	limiter.cleanupWaitGroup.Add(1)
+	go limiter.cleanupFullBuckets()
+
+	return limiter
+}
+
+// Allow verifies if a request from the given key to the given endpoint
+// should be allowed and, if so, takes an attempt from the bucket, locking
+// the key out when the bucket runs empty and the policy has a lockout
+func (r *TokenBucketRateLimiter) Allow(key string, endpoint string, policy RateLimitPolicy) RateLimitResult {
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bucket, ok := r.buckets[key+":"+endpoint]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(policy.Burst), updated: now}
+		r.buckets[key+":"+endpoint] = bucket
+	}
+
+	if now.Before(bucket.lockedUntil) {
+		return RateLimitResult{Allowed: false, RetryAfter: bucket.lockedUntil.Sub(now)}
+	}
+
+	bucket.refill(policy, now)
+	if bucket.tokens < 1 {
+		return RateLimitResult{
+			Allowed:    false,
+			RetryAfter: time.Duration((1 - bucket.tokens) * float64(policy.Refill)),
+		}
+	}
+
+	bucket.tokens--
+	bucket.fullAt = now.Add(time.Duration((float64(policy.Burst) - bucket.tokens) * float64(policy.Refill)))
+
+	if bucket.tokens < 1 && policy.Lockout > 0 {
+		bucket.lockedUntil = now.Add(policy.Lockout)
+	}
+
+	return RateLimitResult{Allowed: true}
+}
+
+// Reset refills the bucket of the given key at the given endpoint, e.g.
+// after a successful login
+func (r *TokenBucketRateLimiter) Reset(key string, endpoint string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.buckets, key+":"+endpoint)
+}
+
+// cleanupFullBuckets periodically removes buckets that refilled, which are
+// the same as no bucket, to prevent memory leaks
+func (r *TokenBucketRateLimiter) cleanupFullBuckets() {
+	defer r.cleanupWaitGroup.Done()
+	ticker := time.NewTicker(r.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := r.now()
+
+			r.mu.Lock()
+			for key, bucket := range r.buckets {
+				if now.After(bucket.fullAt) && now.After(bucket.lockedUntil) {
+					delete(r.buckets, key)
+				}
+			}
+			r.mu.Unlock()
+		case <-r.stopCleanup:
+			return
+		}
+	}
+}
+
+// Stop gracefully stops the rate limiter's background cleanup
+func (r *TokenBucketRateLimiter) Stop() {
+	close(r.stopCleanup)
+	r.cleanupWaitGroup.Wait()
+}
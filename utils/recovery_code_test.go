@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 19 || strings.Count(code, "-") != 3 {
+			t.Fatalf("expected XXXX-XXXX-XXXX-XXXX format, got %q", code)
+		}
+		if strings.Trim(strings.ReplaceAll(code, "-", ""), LoginCodeGamma(false)) != "" {
+			t.Fatalf("expected only login code gamma characters, got %q", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	expected := HashRecoveryCode("BCDF-GHJK-LMNP-QRST")
+
+	for _, variant := range []string{"bcdf-ghjk-lmnp-qrst", "BCDFGHJKLMNPQRST", " bcdf ghjk lmnp qrst "} {
+		if got := HashRecoveryCode(variant); got != expected {
+			t.Fatalf("expected %q to hash like the canonical code", variant)
+		}
+	}
+
+	if HashRecoveryCode("BCDF-GHJK-LMNP-QRSV") == expected {
+		t.Fatalf("expected different codes to have different hashes")
+	}
+}
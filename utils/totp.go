@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TotpDigits is the number of digits in a TOTP code.
+	TotpDigits = 6
+
+	// TotpPeriod is the time step of a TOTP code (RFC 6238 default).
+	TotpPeriod = 30 * time.Second
+
+	// totpSkew is the number of time steps accepted before and after the
+	// current one, to tolerate clock drift between server and authenticator.
+	totpSkew = 1
+
+	// totpModulo truncates the HOTP value to TotpDigits digits.
+	totpModulo = 1000000
+
+	// totpSecretBytes is the size of a generated secret (160 bits, as
+	// recommended by RFC 4226 for HMAC-SHA1).
+	totpSecretBytes = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTotpSecret generates a random base32 encoded TOTP secret, suitable
+// for authenticator apps such as Google Authenticator or Authy.
+func GenerateTotpSecret() (string, error) {
+	secret := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TotpCode returns the RFC 6238 TOTP code (HMAC-SHA1, 30 seconds, 6 digits)
+// for the base32 encoded secret at the given time.
+func TotpCode(secret string, t time.Time) (string, error) {
+	key, err := totpDecodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCodeAtStep(key, uint64(t.Unix())/uint64(TotpPeriod.Seconds())), nil
+}
+
+// ValidateTotpCode checks the code against the base32 encoded secret at the
+// given time, accepting one time step of clock drift in either direction.
+// The comparison is performed in constant time.
+func ValidateTotpCode(secret string, code string, t time.Time) bool {
+	_, valid := TotpCodeStep(secret, code, t)
+	return valid
+}
+
+// TotpCodeStep is ValidateTotpCode returning the time step the code belongs
+// to, so a caller can refuse a code that was already used within its window.
+func TotpCodeStep(secret string, code string, t time.Time) (step uint64, valid bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+
+	key, err := totpDecodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := uint64(t.Unix()) / uint64(TotpPeriod.Seconds())
+	for i := -totpSkew; i <= totpSkew; i++ {
+		candidate := uint64(int64(current) + int64(i))
+		expected := totpCodeAtStep(key, candidate)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			step = candidate
+			valid = true
+		}
+	}
+
+	return step, valid
+}
+
+func totpDecodeSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	normalized = strings.TrimRight(normalized, "=")
+	if normalized == "" {
+		return nil, errors.New("totp secret is empty")
+	}
+	key, err := totpEncoding.DecodeString(normalized)
+	if err != nil {
+		return nil, fmt.Errorf("totp secret is not valid base32: %w", err)
+	}
+	return key, nil
+}
+
+// totpCodeAtStep implements the HOTP truncation from RFC 4226 section 5.3.
+func totpCodeAtStep(key []byte, step uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], step)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TotpDigits, value%totpModulo)
+}
+
+// TotpProvisioningURI builds the otpauth:// key URI understood by
+// authenticator apps, as described by the Google Authenticator key URI format.
+func TotpProvisioningURI(issuer string, accountName string, secret string) string {
+	label := url.PathEscape(accountName)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(TotpDigits))
+	query.Set("period", strconv.Itoa(int(TotpPeriod.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 test key from RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTotpCode_RFC6238Vectors(t *testing.T) {
+	// RFC 6238 publishes 8 digit codes; a 6 digit code is the same value
+	// truncated to its last six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TotpCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TotpCode(%d) error = %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Fatalf("TotpCode(%d) = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTotpCode_AcceptsAdjacentStepsOnly(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	current, _ := TotpCode(rfc6238Secret, now)
+	previous, _ := TotpCode(rfc6238Secret, now.Add(-TotpPeriod))
+	tooOld, _ := TotpCode(rfc6238Secret, now.Add(-3*TotpPeriod))
+
+	if !ValidateTotpCode(rfc6238Secret, current, now) {
+		t.Fatalf("expected current code to be valid")
+	}
+	if !ValidateTotpCode(rfc6238Secret, previous, now) {
+		t.Fatalf("expected previous step code to be valid")
+	}
+	if ValidateTotpCode(rfc6238Secret, tooOld, now) {
+		t.Fatalf("expected code three steps old to be rejected")
+	}
+	if ValidateTotpCode(rfc6238Secret, "12345", now) {
+		t.Fatalf("expected short code to be rejected")
+	}
+	if ValidateTotpCode("not base32!", current, now) {
+		t.Fatalf("expected invalid secret to be rejected")
+	}
+}
+
+func TestTotpCodeStep_ReturnsTheStepOfTheCode(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := uint64(now.Unix()) / uint64(TotpPeriod.Seconds())
+
+	previous, _ := TotpCode(rfc6238Secret, now.Add(-TotpPeriod))
+	next, _ := TotpCode(rfc6238Secret, now.Add(TotpPeriod))
+
+	if step, valid := TotpCodeStep(rfc6238Secret, previous, now); !valid || step != current-1 {
+		t.Fatalf("expected previous step %d, got %d (valid %v)", current-1, step, valid)
+	}
+	if step, valid := TotpCodeStep(rfc6238Secret, next, now); !valid || step != current+1 {
+		t.Fatalf("expected next step %d, got %d (valid %v)", current+1, step, valid)
+	}
+	if _, valid := TotpCodeStep(rfc6238Secret, "000000", now); valid {
+		t.Fatalf("expected wrong code to be rejected")
+	}
+}
+
+func TestGenerateTotpSecret_RoundTrips(t *testing.T) {
+	secret, err := GenerateTotpSecret()
+	if err != nil {
+		t.Fatalf("GenerateTotpSecret() error = %v", err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("expected 32 base32 characters, got %d (%q)", len(secret), secret)
+	}
+
+	now := time.Now()
+	code, err := TotpCode(secret, now)
+	if err != nil {
+		t.Fatalf("TotpCode() error = %v", err)
+	}
+	if !ValidateTotpCode(secret, code, now) {
+		t.Fatalf("expected generated code to validate against generated secret")
+	}
+}
+
+func TestTotpProvisioningURI(t *testing.T) {
+	uri := TotpProvisioningURI("Acme Inc", "user@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("expected a valid URI, got error: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Fatalf("expected otpauth://totp URI, got %q", uri)
+	}
+	if parsed.Path != "/Acme Inc:user@example.com" {
+		t.Fatalf("unexpected label %q", parsed.Path)
+	}
+
+	query := parsed.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("unexpected secret %q", query.Get("secret"))
+	}
+	if query.Get("issuer") != "Acme Inc" {
+		t.Fatalf("unexpected issuer %q", query.Get("issuer"))
+	}
+	if query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Fatalf("unexpected digits/period in %q", uri)
+	}
+}
+
+func TestQrCodeDataURI(t *testing.T) {
+	dataURI, err := QrCodeDataURI("otpauth://totp/test?secret=JBSWY3DPEHPK3PXP", 128)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(dataURI, "data:image/png;base64,") {
+		t.Fatalf("expected PNG data URI, got %q", dataURI[:32])
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/dracory/auth/types"
+)
+
+// validateWebAuthnConfig validates the optional passkey configuration shared
+// by both authentication flows.
+func validateWebAuthnConfig(config *types.WebAuthnConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.RPID == "" {
+		return errors.New("auth: WebAuthn RPID is required")
+	}
+
+	if len(config.Origins) == 0 {
+		return errors.New("auth: WebAuthn Origins are required")
+	}
+
+	if config.FuncCredentialStore == nil {
+		return errors.New("auth: WebAuthn FuncCredentialStore function is required")
+	}
+
+	if config.FuncCredentialFindByID == nil {
+		return errors.New("auth: WebAuthn FuncCredentialFindByID function is required")
+	}
+
+	if config.FuncCredentialSignCountUpdate == nil {
+		return errors.New("auth: WebAuthn FuncCredentialSignCountUpdate function is required")
+	}
+
+	return nil
+}